@@ -402,7 +402,7 @@ func (am *analyticsProviderCore) GetPendingMutations(opts *GetPendingMutationsAn
 		IsIdempotent:  true,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 	resp, err := am.doMgmtRequest(opts.Context, req)
 	if err != nil {
@@ -461,7 +461,7 @@ func (am *analyticsProviderCore) CreateLink(link AnalyticsLink, opts *CreateAnal
 		Path:          endpoint,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 		Body:          data,
 		ContentType:   "application/x-www-form-urlencoded",
 	}
@@ -516,7 +516,7 @@ func (am *analyticsProviderCore) ReplaceLink(link AnalyticsLink, opts *ReplaceAn
 		Path:          endpoint,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 		Body:          data,
 		ContentType:   "application/x-www-form-urlencoded",
 	}
@@ -573,7 +573,7 @@ func (am *analyticsProviderCore) DropLink(linkName, dataverseName string, opts *
 		Path:          endpoint,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 		ContentType:   "application/x-www-form-urlencoded",
 		Body:          payload,
 	}
@@ -648,7 +648,7 @@ func (am *analyticsProviderCore) GetLinks(opts *GetAnalyticsLinksOptions) ([]Ana
 		Path:          endpoint,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 		IsIdempotent:  true,
 	}
 
@@ -106,7 +106,7 @@ func (ap *analyticsProviderCore) AnalyticsQuery(statement string, scope *Scope,
 	res, err := ap.provider.AnalyticsQuery(opts.Context, gocbcore.AnalyticsQueryOptions{
 		Payload:       reqBytes,
 		Priority:      int(priorityInt),
-		RetryStrategy: retryStrategy,
+		RetryStrategy: retryStrategy.forOperation(deadline, span),
 		Deadline:      deadline,
 		TraceContext:  span.Context(),
 		User:          opts.Internal.User,
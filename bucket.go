@@ -22,6 +22,7 @@ type Bucket struct {
 	bootstrapError    error
 	connectionManager connectionManager
 	getTransactions   func() *Transactions
+	queryPlanCapture  *queryPlanCapturer
 }
 
 func newBucket(c *Cluster, bucketName string) *Bucket {
@@ -45,6 +46,7 @@ func newBucket(c *Cluster, bucketName string) *Bucket {
 
 		connectionManager: c.connectionManager,
 		getTransactions:   c.Transactions,
+		queryPlanCapture:  c.queryPlanCapture,
 	}
 }
 
@@ -91,6 +93,19 @@ func (b *Bucket) getKvBulkProvider() (kvBulkProvider, error) {
 	return agent, nil
 }
 
+func (b *Bucket) getChangeStreamProvider() (changeStreamProvider, error) {
+	if b.bootstrapError != nil {
+		return nil, b.bootstrapError
+	}
+
+	provider, err := b.connectionManager.getChangeStreamProvider(b.bucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
 func (b *Bucket) getQueryProvider() (queryProvider, error) {
 	if b.bootstrapError != nil {
 		return nil, b.bootstrapError
@@ -240,6 +255,13 @@ func (b *Bucket) viewController() *providerController[viewProvider] {
 	}
 }
 
+func (b *Bucket) changeStreamController() *providerController[changeStreamProvider] {
+	return &providerController[changeStreamProvider]{
+		get:          b.getChangeStreamProvider,
+		opController: b.connectionManager,
+	}
+}
+
 func (b *Bucket) waitUntilReadyController() *providerController[waitUntilReadyProvider] {
 	return &providerController[waitUntilReadyProvider]{
 		get:          b.getWaitUntilReadyProvider,
@@ -1,6 +1,7 @@
 package gocb
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 )
@@ -77,6 +78,8 @@ type ViewResult struct {
 
 	currentRow ViewRow
 	jsonErr    error
+
+	ctx context.Context
 }
 
 func newViewResult(reader viewRowReader) *ViewResult {
@@ -197,6 +200,12 @@ func (b *Bucket) ViewQuery(designDoc string, viewName string, opts *ViewOptions)
 			opts = &ViewOptions{}
 		}
 
-		return provider.ViewQuery(designDoc, viewName, opts)
+		res, err := provider.ViewQuery(designDoc, viewName, opts)
+		if err != nil {
+			return nil, err
+		}
+		res.ctx = opts.Context
+
+		return res, nil
 	})
 }
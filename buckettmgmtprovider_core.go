@@ -171,10 +171,10 @@ func (bm *bucketManagementProviderCore) GetBucket(bucketName string, opts *GetBu
 	span.SetAttribute("db.operation", "GET "+path)
 	defer span.End()
 
-	return bm.get(opts.Context, span.Context(), path, opts.RetryStrategy, opts.Timeout)
+	return bm.get(opts.Context, span, path, opts.RetryStrategy, opts.Timeout)
 }
 
-func (bm *bucketManagementProviderCore) get(ctx context.Context, tracectx RequestSpanContext, path string,
+func (bm *bucketManagementProviderCore) get(ctx context.Context, span RequestSpan, path string,
 	strategy RetryStrategy, timeout time.Duration) (*BucketSettings, error) {
 
 	req := mgmtRequest{
@@ -185,7 +185,7 @@ func (bm *bucketManagementProviderCore) get(ctx context.Context, tracectx Reques
 		RetryStrategy: strategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       timeout,
-		parentSpanCtx: tracectx,
+		parentSpan:    span,
 	}
 
 	resp, err := bm.mgmtProvider.executeMgmtRequest(ctx, req)
@@ -233,7 +233,7 @@ func (bm *bucketManagementProviderCore) GetAllBuckets(opts *GetAllBucketsOptions
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := bm.mgmtProvider.executeMgmtRequest(opts.Context, req)
@@ -301,7 +301,7 @@ func (bm *bucketManagementProviderCore) CreateBucket(settings CreateBucketSettin
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := bm.mgmtProvider.executeMgmtRequest(opts.Context, req)
@@ -348,7 +348,7 @@ func (bm *bucketManagementProviderCore) UpdateBucket(settings BucketSettings, op
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := bm.mgmtProvider.executeMgmtRequest(opts.Context, req)
@@ -384,7 +384,7 @@ func (bm *bucketManagementProviderCore) DropBucket(name string, opts *DropBucket
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := bm.mgmtProvider.executeMgmtRequest(opts.Context, req)
@@ -421,7 +421,7 @@ func (bm *bucketManagementProviderCore) FlushBucket(name string, opts *FlushBuck
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := bm.mgmtProvider.executeMgmtRequest(opts.Context, req)
@@ -0,0 +1,732 @@
+package gocb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocbcore/v10"
+)
+
+const (
+	defaultChangeStreamBufferSize         = 1024
+	defaultChangeStreamCheckpointInterval = 10 * time.Second
+
+	// changeStreamOpenConcurrency is the number of vbucket streams which are opened at the same time.
+	changeStreamOpenConcurrency = 64
+)
+
+// ChangeEventType is the type of change described by a ChangeEvent.
+// UNCOMMITTED: This API may change in the future.
+type ChangeEventType uint
+
+const (
+	// ChangeEventMutation indicates that a document was created or changed.
+	ChangeEventMutation ChangeEventType = iota + 1
+
+	// ChangeEventDeletion indicates that a document was removed.
+	ChangeEventDeletion
+
+	// ChangeEventExpiration indicates that a document was removed because its expiry time was reached.
+	ChangeEventExpiration
+
+	// ChangeEventRollback indicates that the server no longer has the history of a partition after the sequence
+	// number of the MutationToken of the event, typically because of a failover. Any state derived from events of
+	// the partition with a higher sequence number must be discarded, the events which replace them follow.
+	ChangeEventRollback
+)
+
+// ChangeStreamStartPoint specifies where a change stream starts for the partitions not covered by the state it is
+// resumed from.
+// UNCOMMITTED: This API may change in the future.
+type ChangeStreamStartPoint uint
+
+const (
+	// ChangeStreamStartNow indicates that only changes made after the stream was opened are received.
+	ChangeStreamStartNow ChangeStreamStartPoint = iota
+
+	// ChangeStreamStartBeginning indicates that the stream starts with the current version of every document,
+	// followed by all subsequent changes.
+	ChangeStreamStartBeginning
+)
+
+// ChangeStreamCheckpointer persists the progress of a change stream so that it can be resumed.
+// UNCOMMITTED: This API may change in the future.
+type ChangeStreamCheckpointer interface {
+	// Load returns the state saved by Save, or nil if no state has been saved.
+	Load() (*MutationState, error)
+	Save(state *MutationState) error
+}
+
+type collectionCheckpointer struct {
+	collection *Collection
+	id         string
+}
+
+// NewCollectionCheckpointer returns a ChangeStreamCheckpointer which saves the state of a stream to a document.
+// UNCOMMITTED: This API may change in the future.
+func NewCollectionCheckpointer(c *Collection, id string) ChangeStreamCheckpointer {
+	return &collectionCheckpointer{
+		collection: c,
+		id:         id,
+	}
+}
+
+func (cp *collectionCheckpointer) Load() (*MutationState, error) {
+	res, err := cp.collection.Get(cp.id, nil)
+	if err != nil {
+		if errors.Is(err, ErrDocumentNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	state := &MutationState{}
+	if err := res.Content(state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func (cp *collectionCheckpointer) Save(state *MutationState) error {
+	_, err := cp.collection.Upsert(cp.id, state, nil)
+	return err
+}
+
+// ChangeStreamOptions are the options available to the Watch and StreamChanges operations.
+// UNCOMMITTED: This API may change in the future.
+type ChangeStreamOptions struct {
+	// StartFrom specifies where the stream starts for every partition which is not covered by ResumeFrom, or by the
+	// state loaded from the Checkpointer. Defaults to ChangeStreamStartNow.
+	StartFrom ChangeStreamStartPoint
+
+	// ResumeFrom continues a stream from the state returned by ChangeStream.State. Takes precedence over any state
+	// saved by the Checkpointer.
+	ResumeFrom *MutationState
+
+	// Checkpointer is used to load the state to resume from when ResumeFrom is not set, and to save the state of the
+	// stream every CheckpointInterval, when Checkpoint is called and when the stream is closed. When running multiple
+	// workers each must use a Checkpointer of its own.
+	Checkpointer ChangeStreamCheckpointer
+	// CheckpointInterval is how often the state is saved to the Checkpointer while events are being received.
+	// Defaults to 10 seconds.
+	CheckpointInterval time.Duration
+
+	// WorkerCount and WorkerIndex spread the partitions of the bucket across a number of streams, this stream
+	// receiving the changes of the partitions for which the partition ID modulo WorkerCount is WorkerIndex.
+	WorkerCount uint16
+	WorkerIndex uint16
+
+	// BufferSize is the number of events which are buffered before the stream stops reading from the server.
+	// Defaults to 1024.
+	BufferSize uint32
+
+	Transcoder Transcoder
+
+	// Timeout applies to each of the operations performed when opening the streams of the partitions.
+	// Defaults to the KV scan timeout.
+	Timeout time.Duration
+
+	// Context can be used to cancel the stream, after which Next returns nil.
+	Context context.Context
+}
+
+// ChangeEvent is a single change received from a ChangeStream.
+// UNCOMMITTED: This API may change in the future.
+type ChangeEvent struct {
+	Result
+	eventType      ChangeEventType
+	id             string
+	token          MutationToken
+	scopeName      string
+	collectionName string
+
+	transcoder Transcoder
+	flags      uint32
+	contents   []byte
+	expiryTime time.Time
+}
+
+// Type returns the type of the change.
+func (e *ChangeEvent) Type() ChangeEventType {
+	return e.eventType
+}
+
+// ID returns the id of the document which was changed, empty for rollback events.
+func (e *ChangeEvent) ID() string {
+	return e.id
+}
+
+// MutationToken returns the partition and sequence number of the change. For rollback events this is the point to
+// which the partition was rolled back.
+func (e *ChangeEvent) MutationToken() *MutationToken {
+	return &e.token
+}
+
+// ScopeName returns the name of the scope of the document which was changed.
+func (e *ChangeEvent) ScopeName() string {
+	return e.scopeName
+}
+
+// CollectionName returns the name of the collection of the document which was changed.
+func (e *ChangeEvent) CollectionName() string {
+	return e.collectionName
+}
+
+// Content assigns the new value of the document into the valuePtr using the transcoder of the stream.
+// Only mutation events have content.
+func (e *ChangeEvent) Content(valuePtr interface{}) error {
+	if e.eventType != ChangeEventMutation {
+		return makeInvalidArgumentsError("only mutation events have content")
+	}
+
+	return e.transcoder.Decode(e.contents, e.flags, valuePtr)
+}
+
+// ExpiryTime returns the expiry time of the document of a mutation event, or a zero time if it has none.
+func (e *ChangeEvent) ExpiryTime() time.Time {
+	return e.expiryTime
+}
+
+// ChangeStream is a stream of the changes made to the documents of a bucket or collection, as returned by
+// Bucket.StreamChanges and Collection.Watch. The changes of each partition are received in order, changes to a
+// document are only received once its previous changes have been. A stream must always be closed using Close.
+// UNCOMMITTED: This API may change in the future.
+type ChangeStream struct {
+	provider       changeStreamProvider
+	bucketName     string
+	scopeName      string
+	collectionName string
+	filter         *changeStreamFilter
+	transcoder     Transcoder
+	timeout        time.Duration
+
+	checkpointer       ChangeStreamCheckpointer
+	checkpointInterval time.Duration
+
+	events    chan *ChangeEvent
+	closeCh   chan struct{}
+	parentCtx context.Context
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+
+	lock           sync.Mutex
+	stopped        bool
+	err            error
+	received       map[uint16]changeStreamPosition
+	processed      map[uint16]changeStreamPosition
+	last           *ChangeEvent
+	lastCheckpoint time.Time
+}
+
+// StreamChanges opens a stream of the changes made to the documents in every collection of the bucket.
+// UNCOMMITTED: This API may change in the future.
+func (b *Bucket) StreamChanges(opts *ChangeStreamOptions) (*ChangeStream, error) {
+	if opts == nil {
+		opts = &ChangeStreamOptions{}
+	}
+
+	return autoOpControl(b.changeStreamController(), "", func(provider changeStreamProvider) (*ChangeStream, error) {
+		return openChangeStream(provider, b.Name(), nil, b.transcoder, b.timeoutsConfig.KVScanTimeout, opts)
+	})
+}
+
+// Watch opens a stream of the changes made to the documents of the collection.
+// UNCOMMITTED: This API may change in the future.
+func (c *Collection) Watch(opts *ChangeStreamOptions) (*ChangeStream, error) {
+	if opts == nil {
+		opts = &ChangeStreamOptions{}
+	}
+
+	controller := &providerController[changeStreamProvider]{
+		get:          c.bucket.getChangeStreamProvider,
+		opController: c.opController,
+	}
+
+	return autoOpControl(controller, "", func(provider changeStreamProvider) (*ChangeStream, error) {
+		return openChangeStream(provider, c.bucketName(), c, c.transcoder, c.timeoutsConfig.KVScanTimeout, opts)
+	})
+}
+
+func openChangeStream(provider changeStreamProvider, bucketName string, collection *Collection, transcoder Transcoder,
+	timeout time.Duration, opts *ChangeStreamOptions) (*ChangeStream, error) {
+	if opts.WorkerCount > 0 && opts.WorkerIndex >= opts.WorkerCount {
+		return nil, makeInvalidArgumentsError("worker index must be less than the worker count")
+	}
+
+	parentCtx := opts.Context
+	if parentCtx == nil {
+		parentCtx = context.Background()
+	}
+	if opts.Transcoder != nil {
+		transcoder = opts.Transcoder
+	}
+	if opts.Timeout > 0 {
+		timeout = opts.Timeout
+	}
+	bufferSize := opts.BufferSize
+	if bufferSize == 0 {
+		bufferSize = defaultChangeStreamBufferSize
+	}
+	checkpointInterval := opts.CheckpointInterval
+	if checkpointInterval == 0 {
+		checkpointInterval = defaultChangeStreamCheckpointInterval
+	}
+
+	ctx, cancel := context.WithCancel(parentCtx)
+	s := &ChangeStream{
+		provider:           provider,
+		bucketName:         bucketName,
+		transcoder:         transcoder,
+		timeout:            timeout,
+		checkpointer:       opts.Checkpointer,
+		checkpointInterval: checkpointInterval,
+		events:             make(chan *ChangeEvent, bufferSize),
+		closeCh:            make(chan struct{}),
+		parentCtx:          parentCtx,
+		ctx:                ctx,
+		cancel:             cancel,
+		received:           make(map[uint16]changeStreamPosition),
+		processed:          make(map[uint16]changeStreamPosition),
+		lastCheckpoint:     time.Now(),
+	}
+
+	positions, err := s.startPositions(collection, opts)
+	if err != nil {
+		cancel()
+		if closeErr := provider.Close(); closeErr != nil {
+			logDebugf("Failed to close change stream provider: %s", closeErr)
+		}
+		return nil, err
+	}
+
+	// The streams are opened in the background as the events of the streams which are already open must be consumed
+	// for the others to make progress.
+	s.lock.Lock()
+	for vbID, position := range positions {
+		s.received[vbID] = position
+		s.processed[vbID] = position
+	}
+	s.lock.Unlock()
+
+	sem := make(chan struct{}, changeStreamOpenConcurrency)
+	for vbID := range positions {
+		s.startOpen(vbID, sem)
+	}
+
+	return s, nil
+}
+
+// startPositions resolves the partitions assigned to this stream and the position from which each is streamed.
+func (s *ChangeStream) startPositions(collection *Collection, opts *ChangeStreamOptions) (map[uint16]changeStreamPosition,
+	error) {
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+
+	numVbuckets, err := s.provider.NumVbuckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if collection != nil {
+		cid, err := s.provider.CollectionID(ctx, collection.ScopeName(), collection.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		s.scopeName = collection.ScopeName()
+		s.collectionName = collection.Name()
+		s.filter = &changeStreamFilter{collectionIDs: []uint32{cid}}
+	}
+
+	assigned := make(map[uint16]struct{})
+	for vbID := 0; vbID < numVbuckets; vbID++ {
+		if opts.WorkerCount == 0 || uint16(vbID)%opts.WorkerCount == opts.WorkerIndex {
+			assigned[uint16(vbID)] = struct{}{}
+		}
+	}
+
+	state := opts.ResumeFrom
+	if state == nil && opts.Checkpointer != nil {
+		state, err = opts.Checkpointer.Load()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	positions := make(map[uint16]changeStreamPosition, len(assigned))
+	if state != nil {
+		for _, token := range state.tokens {
+			vbID := token.token.VbID
+			if _, ok := assigned[vbID]; !ok || token.bucketName != s.bucketName {
+				continue
+			}
+
+			positions[vbID] = changeStreamPosition{
+				vbUUID: uint64(token.token.VbUUID),
+				seqNo:  uint64(token.token.SeqNo),
+			}
+		}
+	}
+
+	var remaining []uint16
+	for vbID := range assigned {
+		if _, ok := positions[vbID]; !ok {
+			remaining = append(remaining, vbID)
+		}
+	}
+
+	if opts.StartFrom == ChangeStreamStartNow && len(remaining) > 0 {
+		highSeqNos, err := s.provider.HighSeqNos(ctx, remaining, s.filter)
+		if err != nil {
+			return nil, err
+		}
+
+		for vbID, position := range highSeqNos {
+			positions[vbID] = position
+		}
+	}
+
+	for _, vbID := range remaining {
+		if _, ok := positions[vbID]; !ok {
+			positions[vbID] = changeStreamPosition{}
+		}
+	}
+
+	return positions, nil
+}
+
+// startOpen opens the stream of the partition in the background, from the position of the last change received,
+// retrying until the stream is opened or the stream fails.
+func (s *ChangeStream) startOpen(vbID uint16, sem chan struct{}) {
+	s.lock.Lock()
+	if s.stopped {
+		s.lock.Unlock()
+		return
+	}
+	s.wg.Add(1)
+	s.lock.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+
+		backoff := gocbcore.ExponentialBackoff(10*time.Millisecond, 5*time.Second, 2)
+		for attempt := uint32(0); ; attempt++ {
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+				case <-s.closeCh:
+					return
+				}
+			}
+
+			err := s.openStream(vbID)
+			if sem != nil {
+				<-sem
+			}
+			if err == nil || s.isStopped() {
+				return
+			}
+
+			if changeStreamIsFatal(err) {
+				s.fail(err)
+				return
+			}
+
+			logWarnf("Failed to open change stream for vbucket %d, will retry: %s", vbID, err)
+
+			select {
+			case <-time.After(backoff(attempt)):
+			case <-s.closeCh:
+				return
+			}
+		}
+	}()
+}
+
+func (s *ChangeStream) openStream(vbID uint16) error {
+	ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+	defer cancel()
+
+	for {
+		s.lock.Lock()
+		from := s.received[vbID]
+		s.lock.Unlock()
+
+		vbUUID, err := s.provider.OpenStream(ctx, vbID, from, s.filter, s)
+		if err == nil {
+			s.lock.Lock()
+			if position := s.received[vbID]; position.seqNo == from.seqNo {
+				s.received[vbID] = changeStreamPosition{vbUUID: vbUUID, seqNo: from.seqNo}
+			}
+			s.lock.Unlock()
+
+			return nil
+		}
+
+		var rollbackErr *changeStreamRollbackError
+		if !errors.As(err, &rollbackErr) {
+			return err
+		}
+
+		logInfof("Change stream for vbucket %d rolled back from seqno %d to %d", vbID, from.seqNo,
+			rollbackErr.position.seqNo)
+
+		s.lock.Lock()
+		s.received[vbID] = rollbackErr.position
+		s.lock.Unlock()
+
+		s.emit(&ChangeEvent{
+			eventType: ChangeEventRollback,
+			token:     s.token(vbID, rollbackErr.position),
+		})
+	}
+}
+
+// changeStreamIsFatal returns whether an error opening a stream is one which retrying will not resolve.
+func changeStreamIsFatal(err error) bool {
+	return errors.Is(err, ErrShutdown) || errors.Is(err, ErrCollectionNotFound) ||
+		errors.Is(err, ErrScopeNotFound) || errors.Is(err, ErrBucketNotFound) ||
+		errors.Is(err, ErrAuthenticationFailure) || errors.Is(err, ErrInvalidArgument) ||
+		errors.Is(err, ErrFeatureNotAvailable) || errors.Is(err, ErrRequestCanceled)
+}
+
+func (s *ChangeStream) token(vbID uint16, position changeStreamPosition) MutationToken {
+	return MutationToken{
+		bucketName: s.bucketName,
+		token: gocbcore.MutationToken{
+			VbID:   vbID,
+			VbUUID: gocbcore.VbUUID(position.vbUUID),
+			SeqNo:  gocbcore.SeqNo(position.seqNo),
+		},
+	}
+}
+
+func (s *ChangeStream) changeStreamItem(item changeStreamItem) {
+	position := changeStreamPosition{vbUUID: item.vbUUID, seqNo: item.seqNo}
+	evt := &ChangeEvent{
+		Result:         Result{cas: item.cas},
+		eventType:      item.eventType,
+		id:             string(item.key),
+		token:          s.token(item.vbID, position),
+		scopeName:      s.scopeName,
+		collectionName: s.collectionName,
+		transcoder:     s.transcoder,
+		flags:          item.flags,
+		contents:       item.value,
+	}
+	if item.expiry > 0 {
+		evt.expiryTime = time.Unix(int64(item.expiry), 0)
+	}
+
+	if s.filter == nil {
+		ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+		scopeName, collectionName, err := s.provider.CollectionName(ctx, item.collectionID)
+		cancel()
+		if err != nil {
+			logWarnf("Failed to resolve collection %d of change stream event: %s", item.collectionID, err)
+		}
+		evt.scopeName = scopeName
+		evt.collectionName = collectionName
+	}
+
+	s.lock.Lock()
+	s.received[item.vbID] = position
+	s.lock.Unlock()
+
+	s.emit(evt)
+}
+
+func (s *ChangeStream) changeStreamEnd(vbID uint16, err error) {
+	if err != nil && changeStreamIsFatal(err) {
+		s.fail(err)
+		return
+	}
+
+	logDebugf("Change stream for vbucket %d ended, reopening: %v", vbID, err)
+	s.startOpen(vbID, nil)
+}
+
+func (s *ChangeStream) emit(evt *ChangeEvent) {
+	select {
+	case s.events <- evt:
+	case <-s.closeCh:
+	}
+}
+
+func (s *ChangeStream) isStopped() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.stopped
+}
+
+// stop prevents any more streams from being opened and wakes anything waiting on the stream.
+func (s *ChangeStream) stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+	close(s.closeCh)
+	s.cancel()
+}
+
+func (s *ChangeStream) fail(err error) {
+	s.lock.Lock()
+	if s.err == nil && !s.stopped {
+		s.err = err
+	}
+	s.lock.Unlock()
+
+	s.stop()
+}
+
+// commitLast marks the event most recently returned by Next as processed.
+func (s *ChangeStream) commitLast() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.last == nil {
+		return
+	}
+
+	token := s.last.token.token
+	s.processed[token.VbID] = changeStreamPosition{
+		vbUUID: uint64(token.VbUUID),
+		seqNo:  uint64(token.SeqNo),
+	}
+	s.last = nil
+}
+
+// Next blocks until the next event is received, returning nil once the stream is closed or has failed. Calling Next
+// marks the event returned by the previous call as processed, including it in the State of the stream.
+func (s *ChangeStream) Next() *ChangeEvent {
+	s.commitLast()
+	s.maybeCheckpoint()
+
+	select {
+	case <-s.closeCh:
+		return nil
+	default:
+	}
+
+	select {
+	case evt := <-s.events:
+		s.lock.Lock()
+		s.last = evt
+		s.lock.Unlock()
+
+		return evt
+	case <-s.closeCh:
+		return nil
+	case <-s.parentCtx.Done():
+		if errors.Is(s.parentCtx.Err(), context.DeadlineExceeded) {
+			s.fail(wrapError(ErrTimeout, "change stream context deadline exceeded"))
+		} else {
+			s.fail(wrapError(ErrRequestCanceled, "change stream context canceled"))
+		}
+
+		return nil
+	}
+}
+
+// State returns the position of the stream, covering every event which has been processed. It can be used as the
+// ResumeFrom option of a new stream to continue where this stream left off.
+func (s *ChangeStream) State() *MutationState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	vbIDs := make([]uint16, 0, len(s.processed))
+	for vbID := range s.processed {
+		vbIDs = append(vbIDs, vbID)
+	}
+	sort.Slice(vbIDs, func(i, j int) bool {
+		return vbIDs[i] < vbIDs[j]
+	})
+
+	state := NewMutationState()
+	for _, vbID := range vbIDs {
+		state.Add(s.token(vbID, s.processed[vbID]))
+	}
+
+	return state
+}
+
+// Checkpoint marks the event most recently returned by Next as processed and saves the State of the stream to the
+// Checkpointer.
+func (s *ChangeStream) Checkpoint() error {
+	if s.checkpointer == nil {
+		return makeInvalidArgumentsError("change stream has no checkpointer")
+	}
+
+	s.commitLast()
+	return s.saveCheckpoint()
+}
+
+func (s *ChangeStream) saveCheckpoint() error {
+	state := s.State()
+
+	s.lock.Lock()
+	s.lastCheckpoint = time.Now()
+	s.lock.Unlock()
+
+	return s.checkpointer.Save(state)
+}
+
+func (s *ChangeStream) maybeCheckpoint() {
+	if s.checkpointer == nil {
+		return
+	}
+
+	s.lock.Lock()
+	due := time.Since(s.lastCheckpoint) >= s.checkpointInterval
+	s.lock.Unlock()
+	if !due {
+		return
+	}
+
+	if err := s.saveCheckpoint(); err != nil {
+		logWarnf("Failed to save change stream checkpoint: %s", err)
+	}
+}
+
+// Err returns the error which caused the stream to fail, if any.
+func (s *ChangeStream) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.err
+}
+
+// Close closes the stream, saving its State to the Checkpointer if there is one. The event most recently returned
+// by Next is not considered processed. Returns the error which caused the stream to fail, if any.
+func (s *ChangeStream) Close() error {
+	s.closeOnce.Do(func() {
+		s.stop()
+		s.wg.Wait()
+
+		if err := s.provider.Close(); err != nil {
+			logDebugf("Failed to close change stream provider: %s", err)
+		}
+
+		if s.checkpointer != nil {
+			if err := s.saveCheckpoint(); err != nil {
+				s.closeErr = err
+				return
+			}
+		}
+
+		s.closeErr = s.Err()
+	})
+
+	return s.closeErr
+}
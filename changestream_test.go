@@ -0,0 +1,272 @@
+package gocb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// nextChangeEvents reads count events from the stream, failing if they are not received within a few seconds.
+func (suite *UnitTestSuite) nextChangeEvents(stream *ChangeStream, count int) []*ChangeEvent {
+	events := make([]*ChangeEvent, 0, count)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for len(events) < count {
+			evt := stream.Next()
+			if evt == nil {
+				return
+			}
+			events = append(events, evt)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		suite.Require().NoError(stream.Close())
+		<-done
+	}
+	suite.Require().NoError(stream.Err())
+	suite.Require().Len(events, count)
+
+	return events
+}
+
+func (suite *UnitTestSuite) TestChangeStreamWatch() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	var now time.Time
+	var nowLock sync.Mutex
+	suite.Require().NoError(cluster.Internal().SetFakeClock(func() time.Time {
+		nowLock.Lock()
+		defer nowLock.Unlock()
+		if now.IsZero() {
+			return time.Now()
+		}
+		return now
+	}))
+
+	_, err := col.Upsert("before", "existing", nil)
+	suite.Require().NoError(err)
+
+	other := cluster.Bucket("default").Scope("scope").Collection("other")
+
+	stream, err := col.Watch(&ChangeStreamOptions{StartFrom: ChangeStreamStartBeginning})
+	suite.Require().NoError(err)
+	defer stream.Close()
+
+	evt := suite.nextChangeEvents(stream, 1)[0]
+	suite.Assert().Equal(ChangeEventMutation, evt.Type())
+	suite.Assert().Equal("before", evt.ID())
+
+	_, err = other.Upsert("ignored", "value", nil)
+	suite.Require().NoError(err)
+
+	mutRes, err := col.Upsert("doc", map[string]int{"count": 1}, nil)
+	suite.Require().NoError(err)
+	_, err = col.Upsert("expiring", "value", &UpsertOptions{Expiry: time.Minute})
+	suite.Require().NoError(err)
+
+	evts := suite.nextChangeEvents(stream, 2)
+	byID := map[string]*ChangeEvent{evts[0].ID(): evts[0], evts[1].ID(): evts[1]}
+	suite.Require().Contains(byID, "doc")
+	suite.Require().Contains(byID, "expiring")
+
+	evt = byID["doc"]
+	suite.Assert().Equal(mutRes.Cas(), evt.Cas())
+	suite.Assert().Equal(*mutRes.MutationToken(), *evt.MutationToken())
+	suite.Assert().Equal("_default", evt.ScopeName())
+	suite.Assert().Equal("_default", evt.CollectionName())
+
+	var content map[string]int
+	suite.Require().NoError(evt.Content(&content))
+	suite.Assert().Equal(map[string]int{"count": 1}, content)
+	suite.Assert().False(byID["expiring"].ExpiryTime().IsZero())
+
+	_, err = col.Remove("doc", nil)
+	suite.Require().NoError(err)
+
+	evt = suite.nextChangeEvents(stream, 1)[0]
+	suite.Assert().Equal(ChangeEventDeletion, evt.Type())
+	suite.Assert().Equal("doc", evt.ID())
+	suite.Assert().ErrorIs(evt.Content(&content), ErrInvalidArgument)
+
+	nowLock.Lock()
+	now = time.Now().Add(2 * time.Minute)
+	nowLock.Unlock()
+	_, err = col.Get("expiring", nil)
+	suite.Require().ErrorIs(err, ErrDocumentNotFound)
+
+	evt = suite.nextChangeEvents(stream, 1)[0]
+	suite.Assert().Equal(ChangeEventExpiration, evt.Type())
+	suite.Assert().Equal("expiring", evt.ID())
+
+	suite.Require().NoError(stream.Close())
+	suite.Assert().Nil(stream.Next())
+}
+
+func (suite *UnitTestSuite) TestChangeStreamBucket() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	other := cluster.Bucket("default").Scope("scope").Collection("other")
+	_, err := other.Upsert("a", "value", nil)
+	suite.Require().NoError(err)
+
+	stream, err := cluster.Bucket("default").StreamChanges(&ChangeStreamOptions{StartFrom: ChangeStreamStartBeginning})
+	suite.Require().NoError(err)
+	defer stream.Close()
+
+	_, err = col.Upsert("b", "value", nil)
+	suite.Require().NoError(err)
+
+	collections := make(map[string]string)
+	for _, evt := range suite.nextChangeEvents(stream, 2) {
+		collections[evt.ID()] = evt.ScopeName() + "." + evt.CollectionName()
+	}
+	suite.Assert().Equal(map[string]string{"a": "scope.other", "b": "_default._default"}, collections)
+}
+
+func (suite *UnitTestSuite) TestChangeStreamStartNow() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	_, err := col.Upsert("before", "value", nil)
+	suite.Require().NoError(err)
+
+	stream, err := col.Watch(nil)
+	suite.Require().NoError(err)
+	defer stream.Close()
+
+	_, err = col.Upsert("after", "value", nil)
+	suite.Require().NoError(err)
+
+	suite.Assert().Equal("after", suite.nextChangeEvents(stream, 1)[0].ID())
+}
+
+func (suite *UnitTestSuite) TestChangeStreamCheckpointResume() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	for i := 0; i < 5; i++ {
+		_, err := col.Upsert(fmt.Sprintf("doc-%d", i), i, nil)
+		suite.Require().NoError(err)
+	}
+
+	checkpoints := cluster.Bucket("default").Scope("meta").Collection("checkpoints")
+	opts := &ChangeStreamOptions{
+		StartFrom:    ChangeStreamStartBeginning,
+		Checkpointer: NewCollectionCheckpointer(checkpoints, "watcher"),
+	}
+
+	stream, err := col.Watch(opts)
+	suite.Require().NoError(err)
+
+	seen := make(map[string]struct{})
+	for _, evt := range suite.nextChangeEvents(stream, 5) {
+		seen[evt.ID()] = struct{}{}
+	}
+	suite.Require().Len(seen, 5)
+	suite.Require().NoError(stream.Checkpoint())
+	suite.Require().NoError(stream.Close())
+
+	_, err = col.Upsert("doc-5", 5, nil)
+	suite.Require().NoError(err)
+
+	// The resumed stream only receives the changes which were not processed by the first.
+	stream, err = col.Watch(opts)
+	suite.Require().NoError(err)
+	defer stream.Close()
+
+	evt := suite.nextChangeEvents(stream, 1)[0]
+	suite.Assert().Equal("doc-5", evt.ID())
+
+	// Resuming from the explicit state of a stream behaves the same.
+	state := stream.State()
+	suite.Require().NoError(stream.Checkpoint())
+
+	_, err = col.Upsert("doc-6", 6, nil)
+	suite.Require().NoError(err)
+
+	resumed, err := col.Watch(&ChangeStreamOptions{ResumeFrom: state})
+	suite.Require().NoError(err)
+	defer resumed.Close()
+
+	evts := suite.nextChangeEvents(resumed, 2)
+	suite.Assert().ElementsMatch([]string{"doc-5", "doc-6"}, []string{evts[0].ID(), evts[1].ID()})
+}
+
+func (suite *UnitTestSuite) TestChangeStreamRollback() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	res, err := col.Upsert("doc", "value", nil)
+	suite.Require().NoError(err)
+
+	// A state from a history which the server does not have, as happens after a failover.
+	token := *res.MutationToken()
+	token.token.VbUUID++
+	token.token.SeqNo += 10
+
+	stream, err := col.Watch(&ChangeStreamOptions{ResumeFrom: NewMutationState(token)})
+	suite.Require().NoError(err)
+	defer stream.Close()
+
+	evts := suite.nextChangeEvents(stream, 2)
+	suite.Assert().Equal(ChangeEventRollback, evts[0].Type())
+	suite.Assert().Equal(token.PartitionID(), evts[0].MutationToken().PartitionID())
+	suite.Assert().Zero(evts[0].MutationToken().SequenceNumber())
+
+	suite.Assert().Equal(ChangeEventMutation, evts[1].Type())
+	suite.Assert().Equal("doc", evts[1].ID())
+}
+
+func (suite *UnitTestSuite) TestChangeStreamWorkers() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	_, err := col.Watch(&ChangeStreamOptions{WorkerCount: 2, WorkerIndex: 2})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	var streams []*ChangeStream
+	for i := uint16(0); i < 2; i++ {
+		stream, err := col.Watch(&ChangeStreamOptions{WorkerCount: 2, WorkerIndex: i})
+		suite.Require().NoError(err)
+		defer stream.Close()
+		streams = append(streams, stream)
+	}
+
+	// Find a document in the partitions of each worker.
+	ids := make([]string, 2)
+	for i := 0; ids[0] == "" || ids[1] == ""; i++ {
+		id := fmt.Sprintf("doc-%d", i)
+		ids[fakeVbucketForKey(id)%2] = id
+	}
+	for _, id := range ids {
+		_, err := col.Upsert(id, "value", nil)
+		suite.Require().NoError(err)
+	}
+
+	for i, stream := range streams {
+		evt := suite.nextChangeEvents(stream, 1)[0]
+		suite.Assert().Equal(ids[i], evt.ID())
+		suite.Assert().Equal(uint64(i), evt.MutationToken().PartitionID()%2)
+	}
+}
+
+func (suite *UnitTestSuite) TestChangeStreamContextCancel() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := col.Watch(&ChangeStreamOptions{Context: ctx})
+	suite.Require().NoError(err)
+
+	cancel()
+	suite.Assert().Nil(stream.Next())
+	suite.Assert().ErrorIs(stream.Err(), ErrRequestCanceled)
+	suite.Assert().ErrorIs(stream.Close(), ErrRequestCanceled)
+}
@@ -0,0 +1,81 @@
+package gocb
+
+import (
+	"context"
+	"fmt"
+)
+
+// changeStreamPosition is the point in the history of a vbucket from which a stream is opened.
+type changeStreamPosition struct {
+	vbUUID uint64
+	seqNo  uint64
+}
+
+// changeStreamFilter restricts a stream to the changes of a set of collections, a nil filter streams every collection
+// of the bucket.
+type changeStreamFilter struct {
+	collectionIDs []uint32
+}
+
+func (f *changeStreamFilter) matches(collectionID uint32) bool {
+	if f == nil {
+		return true
+	}
+
+	for _, cid := range f.collectionIDs {
+		if cid == collectionID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// changeStreamItem is a single document change received from a vbucket stream.
+type changeStreamItem struct {
+	eventType    ChangeEventType
+	vbID         uint16
+	vbUUID       uint64
+	seqNo        uint64
+	cas          Cas
+	collectionID uint32
+	key          []byte
+	value        []byte
+	flags        uint32
+	expiry       uint32
+}
+
+// changeStreamSink receives the changes of the vbucket streams opened against a changeStreamProvider. The methods may
+// be called concurrently for different vbuckets, but are called in order for any one vbucket.
+type changeStreamSink interface {
+	changeStreamItem(item changeStreamItem)
+
+	// changeStreamEnd is called when a stream ends for any reason other than CloseStream or Close.
+	changeStreamEnd(vbID uint16, err error)
+}
+
+// changeStreamRollbackError is returned when opening a stream from a position which the server no longer has, for
+// example after a failover, and the stream must be opened from the returned position instead.
+type changeStreamRollbackError struct {
+	position changeStreamPosition
+}
+
+func (e *changeStreamRollbackError) Error() string {
+	return fmt.Sprintf("stream must be rolled back to seqno %d", e.position.seqNo)
+}
+
+// changeStreamProvider is a connection to a bucket used by a single ChangeStream.
+type changeStreamProvider interface {
+	NumVbuckets(ctx context.Context) (int, error)
+	CollectionID(ctx context.Context, scopeName, collectionName string) (uint32, error)
+	CollectionName(ctx context.Context, collectionID uint32) (string, string, error)
+
+	// HighSeqNos returns the position of the most recent change of each of the vbuckets.
+	HighSeqNos(ctx context.Context, vbIDs []uint16, filter *changeStreamFilter) (map[uint16]changeStreamPosition, error)
+
+	// OpenStream opens a stream of the changes after the position, returning the vbucket UUID of the stream.
+	OpenStream(ctx context.Context, vbID uint16, from changeStreamPosition, filter *changeStreamFilter,
+		sink changeStreamSink) (uint64, error)
+	CloseStream(vbID uint16) error
+	Close() error
+}
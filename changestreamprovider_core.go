@@ -0,0 +1,457 @@
+package gocb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocbcore/v10"
+	"github.com/couchbase/gocbcore/v10/memd"
+)
+
+// changeStreamProviderCore serves change streams using a dedicated DCP connection to the bucket. The key-value agent of
+// the bucket is used for resolving collections.
+type changeStreamProviderCore struct {
+	agent      *gocbcore.Agent
+	dcpAgent   *gocbcore.DCPAgent
+	bucketName string
+
+	lock      sync.Mutex
+	manifest  *gocbcore.Manifest
+	observers map[uint16]*changeStreamObserverCore
+}
+
+var _ changeStreamProvider = &changeStreamProviderCore{}
+
+func (p *changeStreamProviderCore) NumVbuckets(ctx context.Context) (int, error) {
+	// This is the first call made by a stream so is where we wait for the DCP connections to be established.
+	err := changeStreamCoreWait(ctx, func(deadline time.Time, cb func(error)) (gocbcore.PendingOp, error) {
+		return p.dcpAgent.WaitUntilReady(deadline, gocbcore.WaitUntilReadyOptions{},
+			func(res *gocbcore.WaitUntilReadyResult, err error) {
+				cb(err)
+			})
+	})
+	if err != nil {
+		return 0, maybeEnhanceKVErr(err, p.bucketName, "", "", "")
+	}
+
+	snapshot, err := p.dcpAgent.ConfigSnapshot()
+	if err != nil {
+		return 0, maybeEnhanceKVErr(err, p.bucketName, "", "", "")
+	}
+
+	numVbuckets, err := snapshot.NumVbuckets()
+	if err != nil {
+		return 0, err
+	}
+	if numVbuckets == 0 {
+		return 0, makeInvalidArgumentsError("can only use change streams with couchbase buckets")
+	}
+
+	return numVbuckets, nil
+}
+
+func (p *changeStreamProviderCore) CollectionID(ctx context.Context, scopeName, collectionName string) (uint32, error) {
+	if (scopeName == "" || scopeName == "_default") && (collectionName == "" || collectionName == "_default") {
+		return 0, nil
+	}
+
+	var cid uint32
+	err := changeStreamCoreWait(ctx, func(deadline time.Time, cb func(error)) (gocbcore.PendingOp, error) {
+		return p.agent.GetCollectionID(scopeName, collectionName, gocbcore.GetCollectionIDOptions{
+			Deadline: deadline,
+		}, func(res *gocbcore.GetCollectionIDResult, err error) {
+			if err == nil {
+				cid = res.CollectionID
+			}
+			cb(err)
+		})
+	})
+	if err != nil {
+		return 0, maybeEnhanceKVErr(err, p.bucketName, scopeName, collectionName, "")
+	}
+
+	return cid, nil
+}
+
+func (p *changeStreamProviderCore) CollectionName(ctx context.Context, collectionID uint32) (string, string, error) {
+	p.lock.Lock()
+	manifest := p.manifest
+	p.lock.Unlock()
+
+	if manifest != nil {
+		if scopeName, collectionName, ok := changeStreamManifestLookup(manifest, collectionID); ok {
+			return scopeName, collectionName, nil
+		}
+	}
+
+	// The collection may have been created since the manifest was fetched.
+	var manifestBytes []byte
+	err := changeStreamCoreWait(ctx, func(deadline time.Time, cb func(error)) (gocbcore.PendingOp, error) {
+		return p.agent.GetCollectionManifest(gocbcore.GetCollectionManifestOptions{
+			Deadline: deadline,
+		}, func(res *gocbcore.GetCollectionManifestResult, err error) {
+			if err == nil {
+				manifestBytes = res.Manifest
+			}
+			cb(err)
+		})
+	})
+	if err != nil {
+		return "", "", maybeEnhanceKVErr(err, p.bucketName, "", "", "")
+	}
+
+	manifest = &gocbcore.Manifest{}
+	if err := manifest.UnmarshalJSON(manifestBytes); err != nil {
+		return "", "", err
+	}
+
+	p.lock.Lock()
+	p.manifest = manifest
+	p.lock.Unlock()
+
+	scopeName, collectionName, ok := changeStreamManifestLookup(manifest, collectionID)
+	if !ok {
+		return "", "", ErrCollectionNotFound
+	}
+
+	return scopeName, collectionName, nil
+}
+
+func changeStreamManifestLookup(manifest *gocbcore.Manifest, collectionID uint32) (string, string, bool) {
+	for _, scope := range manifest.Scopes {
+		for _, collection := range scope.Collections {
+			if collection.UID == collectionID {
+				return scope.Name, collection.Name, true
+			}
+		}
+	}
+
+	return "", "", false
+}
+
+func (p *changeStreamProviderCore) HighSeqNos(ctx context.Context, vbIDs []uint16,
+	filter *changeStreamFilter) (map[uint16]changeStreamPosition, error) {
+	snapshot, err := p.dcpAgent.ConfigSnapshot()
+	if err != nil {
+		return nil, maybeEnhanceKVErr(err, p.bucketName, "", "", "")
+	}
+
+	numServers, err := snapshot.NumServers()
+	if err != nil {
+		return nil, err
+	}
+
+	var seqNoOpts gocbcore.GetVbucketSeqnoOptions
+	if filter != nil && len(filter.collectionIDs) == 1 {
+		seqNoOpts.FilterOptions = &gocbcore.GetVbucketSeqnoFilterOptions{CollectionID: filter.collectionIDs[0]}
+	}
+
+	var lock sync.Mutex
+	seqNos := make(map[uint16]uint64)
+	for serverIdx := 0; serverIdx < numServers; serverIdx++ {
+		serverIdx := serverIdx
+		err := changeStreamCoreWait(ctx, func(deadline time.Time, cb func(error)) (gocbcore.PendingOp, error) {
+			return p.dcpAgent.GetVbucketSeqnos(serverIdx, memd.VbucketStateActive, seqNoOpts,
+				func(entries []gocbcore.VbSeqNoEntry, err error) {
+					lock.Lock()
+					for _, entry := range entries {
+						seqNos[entry.VbID] = uint64(entry.SeqNo)
+					}
+					lock.Unlock()
+					cb(err)
+				})
+		})
+		if err != nil {
+			return nil, maybeEnhanceKVErr(err, p.bucketName, "", "", "")
+		}
+	}
+
+	positions := make(map[uint16]changeStreamPosition, len(vbIDs))
+	errCh := make(chan error, len(vbIDs))
+	var wg sync.WaitGroup
+	for _, vbID := range vbIDs {
+		wg.Add(1)
+		go func(vbID uint16) {
+			defer wg.Done()
+
+			entries, err := p.failoverLog(ctx, vbID)
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			lock.Lock()
+			positions[vbID] = changeStreamPosition{
+				vbUUID: uint64(entries[0].VbUUID),
+				seqNo:  seqNos[vbID],
+			}
+			lock.Unlock()
+		}(vbID)
+	}
+	wg.Wait()
+	close(errCh)
+
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+
+	return positions, nil
+}
+
+// failoverLog returns the failover log of the vbucket, most recent entry first.
+func (p *changeStreamProviderCore) failoverLog(ctx context.Context, vbID uint16) ([]gocbcore.FailoverEntry, error) {
+	var entries []gocbcore.FailoverEntry
+	err := changeStreamCoreWait(ctx, func(deadline time.Time, cb func(error)) (gocbcore.PendingOp, error) {
+		return p.dcpAgent.GetFailoverLog(vbID, func(log []gocbcore.FailoverEntry, err error) {
+			entries = log
+			cb(err)
+		})
+	})
+	if err != nil {
+		return nil, maybeEnhanceKVErr(err, p.bucketName, "", "", "")
+	}
+	if len(entries) == 0 {
+		return nil, makeGenericError(ErrInternalServerFailure, map[string]interface{}{"vbucket": vbID})
+	}
+
+	return entries, nil
+}
+
+func (p *changeStreamProviderCore) OpenStream(ctx context.Context, vbID uint16, from changeStreamPosition,
+	filter *changeStreamFilter, sink changeStreamSink) (uint64, error) {
+	observer := &changeStreamObserverCore{
+		sink:     sink,
+		provider: p,
+	}
+
+	var opts gocbcore.OpenStreamOptions
+	if filter != nil {
+		opts.FilterOptions = &gocbcore.OpenStreamFilterOptions{CollectionIDs: filter.collectionIDs}
+	}
+
+	p.lock.Lock()
+	p.observers[vbID] = observer
+	p.lock.Unlock()
+
+	var entries []gocbcore.FailoverEntry
+	err := changeStreamCoreWait(ctx, func(deadline time.Time, cb func(error)) (gocbcore.PendingOp, error) {
+		return p.dcpAgent.OpenStream(vbID, 0, gocbcore.VbUUID(from.vbUUID), gocbcore.SeqNo(from.seqNo),
+			gocbcore.SeqNo(^uint64(0)), gocbcore.SeqNo(from.seqNo), gocbcore.SeqNo(from.seqNo), observer, opts,
+			func(log []gocbcore.FailoverEntry, err error) {
+				entries = log
+				if len(log) > 0 {
+					observer.setVbUUID(uint64(log[0].VbUUID))
+				}
+				cb(err)
+			})
+	})
+	if err != nil {
+		p.lock.Lock()
+		if p.observers[vbID] == observer {
+			delete(p.observers, vbID)
+		}
+		p.lock.Unlock()
+
+		var rollbackErr gocbcore.DCPRollbackError
+		if errors.As(err, &rollbackErr) {
+			return 0, p.rollbackPosition(ctx, vbID, uint64(rollbackErr.SeqNo))
+		}
+
+		return 0, maybeEnhanceKVErr(err, p.bucketName, "", "", "")
+	}
+	if len(entries) == 0 {
+		return from.vbUUID, nil
+	}
+
+	return uint64(entries[0].VbUUID), nil
+}
+
+// rollbackPosition returns the error telling the stream where to roll back to, finding the vbucket UUID of the
+// history to which the rollback seqno belongs.
+func (p *changeStreamProviderCore) rollbackPosition(ctx context.Context, vbID uint16, seqNo uint64) error {
+	if seqNo == 0 {
+		return &changeStreamRollbackError{}
+	}
+
+	entries, err := p.failoverLog(ctx, vbID)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if uint64(entry.SeqNo) <= seqNo {
+			return &changeStreamRollbackError{
+				position: changeStreamPosition{vbUUID: uint64(entry.VbUUID), seqNo: seqNo},
+			}
+		}
+	}
+
+	return &changeStreamRollbackError{}
+}
+
+func (p *changeStreamProviderCore) CloseStream(vbID uint16) error {
+	p.lock.Lock()
+	observer := p.observers[vbID]
+	delete(p.observers, vbID)
+	p.lock.Unlock()
+
+	if observer != nil {
+		observer.close()
+	}
+
+	return changeStreamCoreWait(context.Background(), func(deadline time.Time, cb func(error)) (gocbcore.PendingOp, error) {
+		return p.dcpAgent.CloseStream(vbID, gocbcore.CloseStreamOptions{}, cb)
+	})
+}
+
+func (p *changeStreamProviderCore) Close() error {
+	p.lock.Lock()
+	for _, observer := range p.observers {
+		observer.close()
+	}
+	p.observers = make(map[uint16]*changeStreamObserverCore)
+	p.lock.Unlock()
+
+	return p.dcpAgent.Close()
+}
+
+// changeStreamCoreWait performs an asynchronous gocbcore operation, waiting for it to complete or for the context to
+// be done.
+func changeStreamCoreWait(ctx context.Context,
+	fn func(deadline time.Time, cb func(error)) (gocbcore.PendingOp, error)) error {
+	var deadline time.Time
+	if d, ok := ctx.Deadline(); ok {
+		deadline = d
+	}
+
+	errCh := make(chan error, 1)
+	op, err := fn(deadline, func(err error) {
+		errCh <- err
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		op.Cancel()
+		<-errCh
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrTimeout
+		}
+
+		return ErrRequestCanceled
+	}
+}
+
+// changeStreamObserverCore forwards the events of a single vbucket stream to the sink.
+type changeStreamObserverCore struct {
+	sink     changeStreamSink
+	provider *changeStreamProviderCore
+
+	lock   sync.Mutex
+	vbUUID uint64
+	closed bool
+}
+
+// setVbUUID is called when the stream is opened, before any of its events are received.
+func (o *changeStreamObserverCore) setVbUUID(vbUUID uint64) {
+	o.lock.Lock()
+	o.vbUUID = vbUUID
+	o.lock.Unlock()
+}
+
+func (o *changeStreamObserverCore) close() {
+	o.lock.Lock()
+	o.closed = true
+	o.lock.Unlock()
+}
+
+func (o *changeStreamObserverCore) isClosed() bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.closed
+}
+
+func (o *changeStreamObserverCore) item(item changeStreamItem) {
+	o.lock.Lock()
+	closed := o.closed
+	item.vbUUID = o.vbUUID
+	o.lock.Unlock()
+	if closed {
+		return
+	}
+
+	o.sink.changeStreamItem(item)
+}
+
+func (o *changeStreamObserverCore) Mutation(mutation gocbcore.DcpMutation) {
+	o.item(changeStreamItem{
+		eventType:    ChangeEventMutation,
+		vbID:         mutation.VbID,
+		seqNo:        mutation.SeqNo,
+		cas:          Cas(mutation.Cas),
+		collectionID: mutation.CollectionID,
+		key:          mutation.Key,
+		value:        append([]byte(nil), mutation.Value...),
+		flags:        mutation.Flags,
+		expiry:       mutation.Expiry,
+	})
+}
+
+func (o *changeStreamObserverCore) Deletion(deletion gocbcore.DcpDeletion) {
+	o.item(changeStreamItem{
+		eventType:    ChangeEventDeletion,
+		vbID:         deletion.VbID,
+		seqNo:        deletion.SeqNo,
+		cas:          Cas(deletion.Cas),
+		collectionID: deletion.CollectionID,
+		key:          deletion.Key,
+	})
+}
+
+func (o *changeStreamObserverCore) Expiration(expiration gocbcore.DcpExpiration) {
+	o.item(changeStreamItem{
+		eventType:    ChangeEventExpiration,
+		vbID:         expiration.VbID,
+		seqNo:        expiration.SeqNo,
+		cas:          Cas(expiration.Cas),
+		collectionID: expiration.CollectionID,
+		key:          expiration.Key,
+	})
+}
+
+func (o *changeStreamObserverCore) End(end gocbcore.DcpStreamEnd, err error) {
+	if o.isClosed() || errors.Is(err, gocbcore.ErrDCPStreamClosed) {
+		return
+	}
+
+	o.provider.lock.Lock()
+	if o.provider.observers[end.VbID] == o {
+		delete(o.provider.observers, end.VbID)
+	}
+	o.provider.lock.Unlock()
+
+	if errors.Is(err, gocbcore.ErrDCPStreamFilterEmpty) {
+		err = ErrCollectionNotFound
+	} else if err != nil {
+		err = maybeEnhanceKVErr(err, o.provider.bucketName, "", "", "")
+	}
+
+	o.sink.changeStreamEnd(end.VbID, err)
+}
+
+func (o *changeStreamObserverCore) SnapshotMarker(gocbcore.DcpSnapshotMarker)           {}
+func (o *changeStreamObserverCore) CreateCollection(gocbcore.DcpCollectionCreation)     {}
+func (o *changeStreamObserverCore) DeleteCollection(gocbcore.DcpCollectionDeletion)     {}
+func (o *changeStreamObserverCore) FlushCollection(gocbcore.DcpCollectionFlush)         {}
+func (o *changeStreamObserverCore) CreateScope(gocbcore.DcpScopeCreation)               {}
+func (o *changeStreamObserverCore) DeleteScope(gocbcore.DcpScopeDeletion)               {}
+func (o *changeStreamObserverCore) ModifyCollection(gocbcore.DcpCollectionModification) {}
+func (o *changeStreamObserverCore) OSOSnapshot(gocbcore.DcpOSOSnapshot)                 {}
+func (o *changeStreamObserverCore) SeqNoAdvanced(gocbcore.DcpSeqNoAdvanced)             {}
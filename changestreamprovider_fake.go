@@ -0,0 +1,327 @@
+package gocb
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fakeFirstCollectionID is the ID the server gives to the first collection created in a bucket.
+const fakeFirstCollectionID = 8
+
+type fakeChangeKey struct {
+	ks fakeKeyspace
+	id string
+}
+
+type fakeChange struct {
+	key       fakeChangeKey
+	eventType ChangeEventType
+	seqNo     uint64
+	cas       Cas
+	value     []byte
+	flags     uint32
+	expiry    time.Time
+}
+
+// recordChange records the latest change of a document for the change streams, waking any which are waiting. The
+// lock must be held.
+func (s *fakeStore) recordChange(ks fakeKeyspace, id string, eventType ChangeEventType, doc *fakeDocument) {
+	vbID := fakeVbucketForKey(id)
+	if s.changes[vbID] == nil {
+		s.changes[vbID] = make(map[fakeChangeKey]*fakeChange)
+	}
+
+	key := fakeChangeKey{ks: ks, id: id}
+	change := &fakeChange{
+		key:       key,
+		eventType: eventType,
+		seqNo:     doc.seqNo,
+		cas:       doc.cas,
+	}
+	if eventType == ChangeEventMutation {
+		change.value = doc.value
+		change.flags = doc.flags
+		change.expiry = doc.expiry
+	}
+	s.changes[vbID][key] = change
+
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// collectionID returns the ID of the collection, assigning one the first time the collection is seen. The lock must be
+// held.
+func (s *fakeStore) collectionID(ks fakeKeyspace) uint32 {
+	if (ks.scope == "" || ks.scope == "_default") && (ks.collection == "" || ks.collection == "_default") {
+		return 0
+	}
+
+	cid, ok := s.collectionIDs[ks]
+	if !ok {
+		cid = fakeFirstCollectionID
+		for existing, existingID := range s.collectionIDs {
+			if existing.bucket == ks.bucket && existingID >= cid {
+				cid = existingID + 1
+			}
+		}
+		s.collectionIDs[ks] = cid
+	}
+
+	return cid
+}
+
+type fakeChangeStream struct {
+	seqNo  uint64
+	filter *changeStreamFilter
+	sink   changeStreamSink
+}
+
+// changeStreamProviderFake serves change streams from a fakeStore, a single goroutine delivers the changes of every
+// open stream.
+type changeStreamProviderFake struct {
+	store      *fakeStore
+	bucketName string
+
+	lock    sync.Mutex
+	streams map[uint16]*fakeChangeStream
+	started bool
+	wakeCh  chan struct{}
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+var _ changeStreamProvider = &changeStreamProviderFake{}
+
+func newChangeStreamProviderFake(store *fakeStore, bucketName string) *changeStreamProviderFake {
+	return &changeStreamProviderFake{
+		store:      store,
+		bucketName: bucketName,
+		streams:    make(map[uint16]*fakeChangeStream),
+		wakeCh:     make(chan struct{}, 1),
+		closeCh:    make(chan struct{}),
+	}
+}
+
+func (p *changeStreamProviderFake) NumVbuckets(ctx context.Context) (int, error) {
+	return fakeNumVbuckets, nil
+}
+
+func (p *changeStreamProviderFake) CollectionID(ctx context.Context, scopeName, collectionName string) (uint32, error) {
+	p.store.lock.Lock()
+	defer p.store.lock.Unlock()
+
+	return p.store.collectionID(fakeKeyspace{
+		bucket:     p.bucketName,
+		scope:      scopeName,
+		collection: collectionName,
+	}), nil
+}
+
+func (p *changeStreamProviderFake) CollectionName(ctx context.Context, collectionID uint32) (string, string, error) {
+	if collectionID == 0 {
+		return "_default", "_default", nil
+	}
+
+	p.store.lock.Lock()
+	defer p.store.lock.Unlock()
+
+	for ks, cid := range p.store.collectionIDs {
+		if ks.bucket == p.bucketName && cid == collectionID {
+			return ks.scope, ks.collection, nil
+		}
+	}
+
+	return "", "", ErrCollectionNotFound
+}
+
+func (p *changeStreamProviderFake) HighSeqNos(ctx context.Context, vbIDs []uint16,
+	filter *changeStreamFilter) (map[uint16]changeStreamPosition, error) {
+	p.store.lock.Lock()
+	defer p.store.lock.Unlock()
+
+	positions := make(map[uint16]changeStreamPosition, len(vbIDs))
+	for _, vbID := range vbIDs {
+		positions[vbID] = changeStreamPosition{
+			vbUUID: p.store.vbUUID,
+			seqNo:  p.store.seqNos[vbID],
+		}
+	}
+
+	return positions, nil
+}
+
+func (p *changeStreamProviderFake) OpenStream(ctx context.Context, vbID uint16, from changeStreamPosition,
+	filter *changeStreamFilter, sink changeStreamSink) (uint64, error) {
+	select {
+	case <-p.closeCh:
+		return 0, ErrShutdown
+	default:
+	}
+
+	p.store.lock.Lock()
+	vbUUID := p.store.vbUUID
+	highSeqNo := p.store.seqNos[vbID]
+	p.store.lock.Unlock()
+
+	// As on the server a stream from a history the vbucket does not have must first be rolled back, either to the
+	// start if the vbucket UUID is unknown or to the latest seqno if the requested seqno is in the future.
+	if from.seqNo > 0 && from.vbUUID != vbUUID {
+		return 0, &changeStreamRollbackError{}
+	}
+	if from.seqNo > highSeqNo {
+		return 0, &changeStreamRollbackError{
+			position: changeStreamPosition{vbUUID: vbUUID, seqNo: highSeqNo},
+		}
+	}
+
+	p.lock.Lock()
+	p.streams[vbID] = &fakeChangeStream{
+		seqNo:  from.seqNo,
+		filter: filter,
+		sink:   sink,
+	}
+	if !p.started {
+		p.started = true
+		p.wg.Add(1)
+		go p.run()
+	}
+	p.lock.Unlock()
+
+	p.wake()
+
+	return vbUUID, nil
+}
+
+func (p *changeStreamProviderFake) CloseStream(vbID uint16) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.streams[vbID]; !ok {
+		return ErrRequestCanceled
+	}
+	delete(p.streams, vbID)
+
+	return nil
+}
+
+func (p *changeStreamProviderFake) Close() error {
+	p.lock.Lock()
+	select {
+	case <-p.closeCh:
+		p.lock.Unlock()
+		return ErrShutdown
+	default:
+	}
+	close(p.closeCh)
+	p.lock.Unlock()
+
+	p.wg.Wait()
+	return nil
+}
+
+func (p *changeStreamProviderFake) wake() {
+	select {
+	case p.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (p *changeStreamProviderFake) run() {
+	defer p.wg.Done()
+
+	for {
+		p.store.lock.Lock()
+		changedCh := p.store.changed
+		p.store.lock.Unlock()
+
+		for _, vbID := range p.openVbuckets() {
+			p.deliver(vbID)
+		}
+
+		select {
+		case <-changedCh:
+		case <-p.wakeCh:
+		case <-p.closeCh:
+			return
+		}
+	}
+}
+
+func (p *changeStreamProviderFake) openVbuckets() []uint16 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	vbIDs := make([]uint16, 0, len(p.streams))
+	for vbID := range p.streams {
+		vbIDs = append(vbIDs, vbID)
+	}
+
+	return vbIDs
+}
+
+// deliver sends the changes made to the vbucket since the last delivery to its stream, in seqno order.
+func (p *changeStreamProviderFake) deliver(vbID uint16) {
+	p.lock.Lock()
+	stream := p.streams[vbID]
+	p.lock.Unlock()
+	if stream == nil {
+		return
+	}
+
+	var items []changeStreamItem
+	p.store.lock.Lock()
+	for key, change := range p.store.changes[vbID] {
+		if key.ks.bucket != p.bucketName || change.seqNo <= stream.seqNo {
+			continue
+		}
+
+		cid := p.store.collectionID(key.ks)
+		if !stream.filter.matches(cid) {
+			continue
+		}
+
+		item := changeStreamItem{
+			eventType:    change.eventType,
+			vbID:         vbID,
+			vbUUID:       p.store.vbUUID,
+			seqNo:        change.seqNo,
+			cas:          change.cas,
+			collectionID: cid,
+			key:          []byte(key.id),
+			value:        change.value,
+			flags:        change.flags,
+		}
+		if !change.expiry.IsZero() {
+			item.expiry = uint32(change.expiry.Unix())
+		}
+		items = append(items, item)
+	}
+	highSeqNo := p.store.seqNos[vbID]
+	p.store.lock.Unlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].seqNo < items[j].seqNo
+	})
+
+	for _, item := range items {
+		select {
+		case <-p.closeCh:
+			return
+		default:
+		}
+
+		p.lock.Lock()
+		current := p.streams[vbID]
+		p.lock.Unlock()
+		if current != stream {
+			return
+		}
+
+		stream.sink.changeStreamItem(item)
+	}
+
+	// Changes to other buckets or collections advance the stream without being delivered.
+	stream.seqNo = highSeqNo
+}
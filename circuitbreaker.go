@@ -1,6 +1,11 @@
 package gocb
 
-import "time"
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
 
 // CircuitBreakerCallback is the callback used by the circuit breaker to determine if an error should count toward
 // the circuit breaker failure count.
@@ -15,4 +20,464 @@ type CircuitBreakerConfig struct {
 	RollingWindow            time.Duration
 	CompletionCallback       CircuitBreakerCallback
 	CanaryTimeout            time.Duration
+
+	// EnforceCouchbase2 fast fails key value operations with ErrCircuitBreakerOpen whilst the circuit breaker of a
+	// couchbase2 connection is open. Otherwise the breaker only observes operations, as it does for the couchbase
+	// protocol.
+	// UNCOMMITTED: This API may change in the future.
+	EnforceCouchbase2 bool
+}
+
+// CircuitBreakerState describes the state of a circuit breaker.
+// UNCOMMITTED: This API may change in the future.
+type CircuitBreakerState uint32
+
+const (
+	// CircuitBreakerStateDisabled indicates that circuit breakers are disabled.
+	CircuitBreakerStateDisabled CircuitBreakerState = iota
+
+	// CircuitBreakerStateClosed indicates that requests are being allowed through.
+	CircuitBreakerStateClosed
+
+	// CircuitBreakerStateHalfOpen indicates that a single request is being allowed through to test whether the
+	// endpoint has recovered.
+	CircuitBreakerStateHalfOpen
+
+	// CircuitBreakerStateOpen indicates that requests are being fast failed.
+	CircuitBreakerStateOpen
+)
+
+// String returns the string representation of this state.
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitBreakerStateDisabled:
+		return "disabled"
+	case CircuitBreakerStateClosed:
+		return "closed"
+	case CircuitBreakerStateHalfOpen:
+		return "half_open"
+	case CircuitBreakerStateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerEndpointState is a point in time view of a single circuit breaker.
+// UNCOMMITTED: This API may change in the future.
+type CircuitBreakerEndpointState struct {
+	Service ServiceType
+
+	// Endpoint is the endpoint that the circuit breaker applies to. When using the couchbase protocol the per node
+	// circuit breakers are managed internally and cannot be observed directly, instead the state is derived from the
+	// outcome of operations against each node of the bucket. Endpoint is empty for operations whose node could not
+	// be determined.
+	Endpoint string
+	Bucket   string
+
+	State CircuitBreakerState
+
+	// TotalCount and FailureCount are the number of operations, and failed operations, in the current rolling window.
+	TotalCount   int64
+	FailureCount int64
+
+	// RejectedCount is the total number of operations that were fast failed because the circuit breaker was open.
+	RejectedCount uint64
+
+	// TransitionCount is the total number of state changes.
+	TransitionCount     uint64
+	LastTransition      time.Time
+	LastFailureEndpoint string
+}
+
+type circuitBreakerKey struct {
+	service  ServiceType
+	bucket   string
+	endpoint string
+}
+
+// circuitBreakerRegistry tracks the circuit breakers that the SDK can observe, reporting state changes through the
+// meter and logger.
+type circuitBreakerRegistry struct {
+	config   CircuitBreakerConfig
+	callback CircuitBreakerCallback
+	meter    Meter
+
+	lock     sync.Mutex
+	breakers map[circuitBreakerKey]*observedCircuitBreaker
+}
+
+func newCircuitBreakerRegistry(config CircuitBreakerConfig, meter Meter) *circuitBreakerRegistry {
+	// These match the defaults used by gocbcore so that observed state tracks the internal breakers.
+	if config.VolumeThreshold == 0 {
+		config.VolumeThreshold = 20
+	}
+	if config.ErrorThresholdPercentage == 0 {
+		config.ErrorThresholdPercentage = 50
+	}
+	if config.SleepWindow == 0 {
+		config.SleepWindow = 5 * time.Second
+	}
+	if config.RollingWindow == 0 {
+		config.RollingWindow = 1 * time.Minute
+	}
+
+	callback := config.CompletionCallback
+	if callback == nil {
+		callback = func(err error) bool {
+			return !errors.Is(err, ErrTimeout)
+		}
+	}
+
+	return &circuitBreakerRegistry{
+		config:   config,
+		callback: callback,
+		meter:    meter,
+		breakers: make(map[circuitBreakerKey]*observedCircuitBreaker),
+	}
+}
+
+// get returns the breaker for the given key, creating it if required. Enforcing breakers fast fail requests when
+// open, otherwise the breaker only observes outcomes.
+func (r *circuitBreakerRegistry) get(service ServiceType, bucket, endpoint string, enforcing bool) *observedCircuitBreaker {
+	if r == nil {
+		return nil
+	}
+
+	key := circuitBreakerKey{service: service, bucket: bucket, endpoint: endpoint}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	breaker, ok := r.breakers[key]
+	if !ok {
+		breaker = &observedCircuitBreaker{
+			registry:  r,
+			key:       key,
+			enforcing: enforcing,
+			state:     CircuitBreakerStateClosed,
+		}
+		if r.config.Disabled {
+			breaker.state = CircuitBreakerStateDisabled
+		}
+		r.breakers[key] = breaker
+	}
+
+	return breaker
+}
+
+func (r *circuitBreakerRegistry) enforceCouchbase2() bool {
+	return r != nil && r.config.EnforceCouchbase2
+}
+
+func (r *circuitBreakerRegistry) snapshot() []CircuitBreakerEndpointState {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	breakers := make([]*observedCircuitBreaker, 0, len(r.breakers))
+	for _, breaker := range r.breakers {
+		breakers = append(breakers, breaker)
+	}
+	r.lock.Unlock()
+
+	states := make([]CircuitBreakerEndpointState, len(breakers))
+	for i, breaker := range breakers {
+		states[i] = breaker.snapshot()
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Service != states[j].Service {
+			return states[i].Service < states[j].Service
+		}
+		if states[i].Bucket != states[j].Bucket {
+			return states[i].Bucket < states[j].Bucket
+		}
+		return states[i].Endpoint < states[j].Endpoint
+	})
+
+	return states
+}
+
+func (r *circuitBreakerRegistry) emitTransition(key circuitBreakerKey, from, to CircuitBreakerState) {
+	target := key.endpoint
+	if target == "" {
+		target = key.bucket
+	}
+	logInfof("Circuit breaker for %s %s moved from %s to %s", serviceTypeToString(key.service), target, from, to)
+
+	if r.meter == nil {
+		return
+	}
+
+	tags := map[string]string{
+		meterAttribServiceKey:             serviceTypeToString(key.service),
+		meterAttribCircuitBreakerStateKey: to.String(),
+	}
+	if key.bucket != "" {
+		tags[meterAttribBucketNameKey] = key.bucket
+	}
+	counter, err := r.meter.Counter(meterNameCircuitBreakerTransitions, tags)
+	if err != nil {
+		logDebugf("Failed to create circuit breaker counter: %v", err)
+		return
+	}
+	counter.IncrementBy(1)
+}
+
+// kvCircuitBreakers observes the outcome of operations against each node of a bucket when using the couchbase
+// protocol, under which the breakers which fast fail requests are internal to gocbcore.
+type kvCircuitBreakers struct {
+	registry *circuitBreakerRegistry
+	bucket   string
+
+	// endpointForKey returns the node that operations against the key are sent to, or an empty string if it is not
+	// known. It is used when the error of an operation does not say where it was dispatched to.
+	endpointForKey func(key []byte) string
+}
+
+func newKvCircuitBreakers(registry *circuitBreakerRegistry, bucket string,
+	endpointForKey func(key []byte) string) *kvCircuitBreakers {
+	if registry == nil {
+		return nil
+	}
+
+	return &kvCircuitBreakers{
+		registry:       registry,
+		bucket:         bucket,
+		endpointForKey: endpointForKey,
+	}
+}
+
+// record feeds the outcome of an operation against the key into the breaker of the node that it was sent to.
+func (b *kvCircuitBreakers) record(key []byte, err error) {
+	if b == nil || b.registry.config.Disabled {
+		return
+	}
+
+	endpoint := errLastDispatchedTo(err)
+	if endpoint == "" && b.endpointForKey != nil {
+		endpoint = b.endpointForKey(key)
+	}
+
+	b.registry.get(ServiceTypeKeyValue, b.bucket, endpoint, false).record(err)
+}
+
+// observedCircuitBreaker applies the same lazy circuit breaker algorithm as gocbcore. A nil observedCircuitBreaker
+// allows every request and records nothing.
+type observedCircuitBreaker struct {
+	registry  *circuitBreakerRegistry
+	key       circuitBreakerKey
+	enforcing bool
+
+	lock                sync.Mutex
+	state               CircuitBreakerState
+	windowStart         time.Time
+	total               int64
+	failed              int64
+	openedAt            time.Time
+	canaryInFlight      bool
+	rejected            uint64
+	transitions         uint64
+	lastTransition      time.Time
+	lastFailureEndpoint string
+}
+
+// transition must be called with the lock held, it returns a function to emit the event once the lock is released.
+func (b *observedCircuitBreaker) transition(to CircuitBreakerState, now time.Time) func() {
+	from := b.state
+	if from == to {
+		return func() {}
+	}
+
+	b.state = to
+	b.transitions++
+	b.lastTransition = now
+	switch to {
+	case CircuitBreakerStateOpen:
+		b.openedAt = now
+		b.canaryInFlight = false
+	case CircuitBreakerStateClosed:
+		b.windowStart = now
+		b.total = 0
+		b.failed = 0
+		b.canaryInFlight = false
+	}
+
+	return func() {
+		b.registry.emitTransition(b.key, from, to)
+	}
+}
+
+// allowsRequest reports whether a request may be sent. Once the sleep window has elapsed an open breaker moves to
+// half open and allows a single request through as the canary.
+func (b *observedCircuitBreaker) allowsRequest() bool {
+	if b == nil || !b.enforcing {
+		return true
+	}
+
+	now := time.Now()
+	b.lock.Lock()
+	var emit func()
+	allowed := true
+	switch b.state {
+	case CircuitBreakerStateOpen:
+		if now.Sub(b.openedAt) > b.registry.config.SleepWindow {
+			emit = b.transition(CircuitBreakerStateHalfOpen, now)
+			b.canaryInFlight = true
+		} else {
+			allowed = false
+		}
+	case CircuitBreakerStateHalfOpen:
+		if b.canaryInFlight {
+			allowed = false
+		} else {
+			b.canaryInFlight = true
+		}
+	}
+	if !allowed {
+		b.rejected++
+	}
+	b.lock.Unlock()
+
+	if emit != nil {
+		emit()
+	}
+
+	return allowed
+}
+
+// record feeds the outcome of an operation into the breaker.
+func (b *observedCircuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+
+	now := time.Now()
+	b.lock.Lock()
+	if b.state == CircuitBreakerStateDisabled {
+		b.lock.Unlock()
+		return
+	}
+
+	var emit func()
+	if isCircuitBreakerRejection(err) {
+		// Operations are only fast failed by an open breaker, so if we haven't seen it open yet then it must be.
+		b.rejected++
+		emit = b.transition(CircuitBreakerStateOpen, now)
+	} else if b.registry.callback(err) {
+		emit = b.markSuccessful(now)
+	} else {
+		if endpoint := errLastDispatchedTo(err); endpoint != "" {
+			b.lastFailureEndpoint = endpoint
+		}
+		emit = b.markFailure(now)
+	}
+	b.lock.Unlock()
+
+	if emit != nil {
+		emit()
+	}
+}
+
+func (b *observedCircuitBreaker) maybeResetRollingWindow(now time.Time) {
+	if now.Sub(b.windowStart) <= b.registry.config.RollingWindow {
+		return
+	}
+
+	b.windowStart = now
+	b.total = 0
+	b.failed = 0
+}
+
+func (b *observedCircuitBreaker) markSuccessful(now time.Time) func() {
+	switch b.state {
+	case CircuitBreakerStateHalfOpen:
+		return b.transition(CircuitBreakerStateClosed, now)
+	case CircuitBreakerStateOpen:
+		if !b.enforcing {
+			// The internal breaker must have let a request through and closed.
+			return b.transition(CircuitBreakerStateClosed, now)
+		}
+	}
+
+	b.maybeResetRollingWindow(now)
+	b.total++
+	return nil
+}
+
+func (b *observedCircuitBreaker) markFailure(now time.Time) func() {
+	if b.state == CircuitBreakerStateHalfOpen {
+		return b.transition(CircuitBreakerStateOpen, now)
+	}
+
+	b.maybeResetRollingWindow(now)
+	b.total++
+	b.failed++
+
+	if b.state != CircuitBreakerStateClosed || b.total < b.registry.config.VolumeThreshold {
+		return nil
+	}
+
+	if float64(b.failed)/float64(b.total)*100 >= b.registry.config.ErrorThresholdPercentage {
+		return b.transition(CircuitBreakerStateOpen, now)
+	}
+
+	return nil
+}
+
+func (b *observedCircuitBreaker) snapshot() CircuitBreakerEndpointState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return CircuitBreakerEndpointState{
+		Service:             b.key.service,
+		Endpoint:            b.key.endpoint,
+		Bucket:              b.key.bucket,
+		State:               b.state,
+		TotalCount:          b.total,
+		FailureCount:        b.failed,
+		RejectedCount:       b.rejected,
+		TransitionCount:     b.transitions,
+		LastTransition:      b.lastTransition,
+		LastFailureEndpoint: b.lastFailureEndpoint,
+	}
+}
+
+func isCircuitBreakerRejection(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrCircuitBreakerOpen) {
+		return true
+	}
+
+	var reasons []RetryReason
+	var kvErr *KeyValueError
+	var timeoutErr *TimeoutError
+	if errors.As(err, &kvErr) {
+		reasons = kvErr.RetryReasons
+	} else if errors.As(err, &timeoutErr) {
+		reasons = timeoutErr.RetryReasons
+	}
+	for _, reason := range reasons {
+		if reason == CircuitBreakerOpenRetryReason {
+			return true
+		}
+	}
+
+	return false
+}
+
+func errLastDispatchedTo(err error) string {
+	var kvErr *KeyValueError
+	if errors.As(err, &kvErr) {
+		return kvErr.LastDispatchedTo
+	}
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return timeoutErr.LastDispatchedTo
+	}
+
+	return ""
 }
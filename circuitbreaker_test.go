@@ -0,0 +1,208 @@
+package gocb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/couchbase/gocbcore/v10"
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *UnitTestSuite) TestCircuitBreakerStateString() {
+	suite.Assert().Equal("disabled", CircuitBreakerStateDisabled.String())
+	suite.Assert().Equal("closed", CircuitBreakerStateClosed.String())
+	suite.Assert().Equal("half_open", CircuitBreakerStateHalfOpen.String())
+	suite.Assert().Equal("open", CircuitBreakerStateOpen.String())
+}
+
+func (suite *UnitTestSuite) TestCircuitBreakerObservedOpensAndCloses() {
+	meter := newTestMeter()
+	registry := newCircuitBreakerRegistry(CircuitBreakerConfig{VolumeThreshold: 4}, meter)
+	breaker := registry.get(ServiceTypeKeyValue, "default", "", false)
+	suite.Require().Same(breaker, registry.get(ServiceTypeKeyValue, "default", "", false))
+
+	breaker.record(nil)
+	breaker.record(nil)
+	breaker.record(&TimeoutError{InnerError: ErrUnambiguousTimeout, LastDispatchedTo: "10.0.0.1:11210"})
+	suite.Assert().Equal(CircuitBreakerStateClosed, breaker.snapshot().State)
+
+	// Non timeout errors do not count as failures by default.
+	breaker.record(ErrDocumentNotFound)
+	suite.Assert().Equal(CircuitBreakerStateClosed, breaker.snapshot().State)
+
+	breaker.record(&TimeoutError{InnerError: ErrUnambiguousTimeout, LastDispatchedTo: "10.0.0.2:11210"})
+	breaker.record(&TimeoutError{InnerError: ErrUnambiguousTimeout})
+
+	states := registry.snapshot()
+	suite.Require().Len(states, 1)
+	state := states[0]
+	suite.Assert().Equal(ServiceTypeKeyValue, state.Service)
+	suite.Assert().Equal("default", state.Bucket)
+	suite.Assert().Equal(CircuitBreakerStateOpen, state.State)
+	suite.Assert().Equal(int64(6), state.TotalCount)
+	suite.Assert().Equal(int64(3), state.FailureCount)
+	suite.Assert().Equal(uint64(1), state.TransitionCount)
+	suite.Assert().Equal("10.0.0.2:11210", state.LastFailureEndpoint)
+	suite.Assert().False(state.LastTransition.IsZero())
+
+	// An observed breaker never fast fails itself, the internal breaker is responsible for that.
+	suite.Assert().True(breaker.allowsRequest())
+
+	breaker.record(nil)
+	state = breaker.snapshot()
+	suite.Assert().Equal(CircuitBreakerStateClosed, state.State)
+	suite.Assert().Equal(int64(0), state.TotalCount)
+	suite.Assert().Equal(uint64(2), state.TransitionCount)
+
+	suite.Assert().Equal(uint64(2), meter.counters[meterNameCircuitBreakerTransitions+":"].count)
+}
+
+func (suite *UnitTestSuite) TestCircuitBreakerObservedRejection() {
+	registry := newCircuitBreakerRegistry(CircuitBreakerConfig{}, nil)
+	breaker := registry.get(ServiceTypeKeyValue, "default", "", false)
+
+	breaker.record(&TimeoutError{
+		InnerError:   ErrUnambiguousTimeout,
+		RetryReasons: []RetryReason{CircuitBreakerOpenRetryReason},
+	})
+
+	state := breaker.snapshot()
+	suite.Assert().Equal(CircuitBreakerStateOpen, state.State)
+	suite.Assert().Equal(uint64(1), state.RejectedCount)
+	suite.Assert().Equal(int64(0), state.FailureCount)
+}
+
+func (suite *UnitTestSuite) TestCircuitBreakerEnforcing() {
+	registry := newCircuitBreakerRegistry(CircuitBreakerConfig{
+		VolumeThreshold: 2,
+		SleepWindow:     20 * time.Millisecond,
+	}, nil)
+	breaker := registry.get(ServiceTypeKeyValue, "", "localhost:18098", true)
+
+	suite.Require().True(breaker.allowsRequest())
+	breaker.record(ErrTimeout)
+	suite.Require().True(breaker.allowsRequest())
+	breaker.record(ErrTimeout)
+
+	suite.Assert().Equal(CircuitBreakerStateOpen, breaker.snapshot().State)
+	suite.Assert().False(breaker.allowsRequest())
+
+	time.Sleep(30 * time.Millisecond)
+
+	// Only a single canary is allowed through once the sleep window has elapsed.
+	suite.Assert().True(breaker.allowsRequest())
+	suite.Assert().Equal(CircuitBreakerStateHalfOpen, breaker.snapshot().State)
+	suite.Assert().False(breaker.allowsRequest())
+
+	breaker.record(ErrTimeout)
+	suite.Assert().Equal(CircuitBreakerStateOpen, breaker.snapshot().State)
+
+	time.Sleep(30 * time.Millisecond)
+	suite.Assert().True(breaker.allowsRequest())
+	breaker.record(nil)
+
+	state := breaker.snapshot()
+	suite.Assert().Equal(CircuitBreakerStateClosed, state.State)
+	suite.Assert().Equal(uint64(2), state.RejectedCount)
+	suite.Assert().Equal(uint64(5), state.TransitionCount)
+	suite.Assert().Equal("localhost:18098", state.Endpoint)
+}
+
+func (suite *UnitTestSuite) TestCircuitBreakerCompletionCallback() {
+	errCustom := errors.New("custom")
+	registry := newCircuitBreakerRegistry(CircuitBreakerConfig{
+		VolumeThreshold: 1,
+		CompletionCallback: func(err error) bool {
+			return !errors.Is(err, errCustom)
+		},
+	}, nil)
+	breaker := registry.get(ServiceTypeKeyValue, "default", "", false)
+
+	breaker.record(ErrTimeout)
+	suite.Assert().Equal(CircuitBreakerStateClosed, breaker.snapshot().State)
+
+	breaker.record(errCustom)
+	suite.Assert().Equal(CircuitBreakerStateOpen, breaker.snapshot().State)
+}
+
+func (suite *UnitTestSuite) TestCircuitBreakerDisabled() {
+	registry := newCircuitBreakerRegistry(CircuitBreakerConfig{Disabled: true, VolumeThreshold: 1}, nil)
+	breaker := registry.get(ServiceTypeKeyValue, "", "localhost:18098", true)
+
+	breaker.record(ErrTimeout)
+	suite.Assert().True(breaker.allowsRequest())
+	suite.Assert().Equal(CircuitBreakerStateDisabled, breaker.snapshot().State)
+
+	var nilRegistry *circuitBreakerRegistry
+	suite.Assert().Nil(nilRegistry.get(ServiceTypeKeyValue, "", "", true))
+	suite.Assert().Empty(nilRegistry.snapshot())
+}
+
+func (suite *UnitTestSuite) TestCircuitBreakerObservesKvOperations() {
+	provider := new(mockKvProviderCoreProvider)
+	provider.
+		On("Get", mock.MatchedBy(func(opts gocbcore.GetOptions) bool {
+			return string(opts.Key) == "someid"
+		}), mock.AnythingOfType("gocbcore.GetCallback")).
+		Run(func(args mock.Arguments) {
+			cb := args.Get(1).(gocbcore.GetCallback)
+			cb(nil, &gocbcore.TimeoutError{
+				InnerError:       gocbcore.ErrUnambiguousTimeout,
+				LastDispatchedTo: "10.0.0.1:11210",
+			})
+		}).
+		Return(new(mockPendingOp), nil)
+	provider.
+		On("Get", mock.MatchedBy(func(opts gocbcore.GetOptions) bool {
+			return string(opts.Key) == "otherid"
+		}), mock.AnythingOfType("gocbcore.GetCallback")).
+		Run(func(args mock.Arguments) {
+			cb := args.Get(1).(gocbcore.GetCallback)
+			cb(&gocbcore.GetResult{Value: []byte(`{}`)}, nil)
+		}).
+		Return(new(mockPendingOp), nil)
+
+	cluster := suite.newCluster(nil)
+	cluster.circuitBreakers = newCircuitBreakerRegistry(CircuitBreakerConfig{VolumeThreshold: 2}, nil)
+
+	agent := suite.kvProviderCore(provider, nil)
+	// Successful operations do not say where they were sent, so the node is worked out from the key.
+	agent.circuitBreakers = newKvCircuitBreakers(cluster.circuitBreakers, "mock", func(key []byte) string {
+		return "10.0.0.2:11210"
+	})
+	col := suite.collection("mock", "", "", agent)
+
+	for i := 0; i < 2; i++ {
+		_, err := col.Get("someid", nil)
+		suite.Require().ErrorIs(err, ErrTimeout)
+	}
+	_, err := col.Get("otherid", nil)
+	suite.Require().NoError(err)
+
+	// Each node has its own breaker, so the failures of one node do not open the breaker of another.
+	states := cluster.CircuitBreakers()
+	suite.Require().Len(states, 2)
+	suite.Assert().Equal("10.0.0.1:11210", states[0].Endpoint)
+	suite.Assert().Equal("mock", states[0].Bucket)
+	suite.Assert().Equal(CircuitBreakerStateOpen, states[0].State)
+	suite.Assert().Equal(int64(2), states[0].FailureCount)
+	suite.Assert().Equal("10.0.0.1:11210", states[0].LastFailureEndpoint)
+	suite.Assert().Equal("10.0.0.2:11210", states[1].Endpoint)
+	suite.Assert().Equal(CircuitBreakerStateClosed, states[1].State)
+	suite.Assert().Equal(int64(1), states[1].TotalCount)
+}
+
+func (suite *UnitTestSuite) TestCircuitBreakerCouchbase2ObservesByDefault() {
+	registry := newCircuitBreakerRegistry(CircuitBreakerConfig{VolumeThreshold: 1}, nil)
+	breaker := registry.get(ServiceTypeKeyValue, "", "localhost:18098", registry.enforceCouchbase2())
+
+	breaker.record(ErrTimeout)
+	suite.Assert().Equal(CircuitBreakerStateOpen, breaker.snapshot().State)
+	suite.Assert().True(breaker.allowsRequest())
+
+	registry = newCircuitBreakerRegistry(CircuitBreakerConfig{VolumeThreshold: 1, EnforceCouchbase2: true}, nil)
+	breaker = registry.get(ServiceTypeKeyValue, "", "localhost:18098", registry.enforceCouchbase2())
+
+	breaker.record(ErrTimeout)
+	suite.Assert().False(breaker.allowsRequest())
+}
@@ -15,6 +15,7 @@ type connectionManager interface {
 	getKvProvider(bucketName string) (kvProvider, error)
 	getKvBulkProvider(bucketName string) (kvBulkProvider, error)
 	getKvCapabilitiesProvider(bucketName string) (kvCapabilityVerifier, error)
+	getChangeStreamProvider(bucketName string) (changeStreamProvider, error)
 	getViewProvider(bucketName string) (viewProvider, error)
 	getViewIndexProvider(bucketName string) (viewIndexProvider, error)
 	getQueryProvider() (queryProvider, error)
@@ -91,20 +92,41 @@ func autoOpControlErrorOnly[P any](controller *providerController[P], operation
 }
 
 type newConnectionMgrOptions struct {
-	tracer *tracerWrapper
-	meter  *meterWrapper
+	tracer          *tracerWrapper
+	meter           *meterWrapper
+	rateLimiters    *rateLimiters
+	circuitBreakers *circuitBreakerRegistry
+	recorder        *httpRecorder
 
 	preferredServerGroup string
 }
 
 func (c *Cluster) newConnectionMgr(protocol string, opts *newConnectionMgrOptions) connectionManager {
+	// Replayed traffic never reaches a cluster, so whatever the scheme everything is served from memory.
+	if opts.recorder.replaying() {
+		protocol = "fake"
+	}
+
 	switch protocol {
+	case "fake":
+		return &fakeConnectionMgr{
+			store:                newFakeStore(),
+			retryStrategyWrapper: c.retryStrategyWrapper,
+			transcoder:           c.transcoder,
+			timeouts:             c.timeoutsConfig,
+			tracer:               opts.tracer,
+			meter:                opts.meter,
+			rateLimiters:         opts.rateLimiters,
+			recorder:             opts.recorder,
+		}
 	case "couchbase2":
 		return &psConnectionMgr{
 			timeouts:     c.timeoutsConfig,
 			tracer:       opts.tracer,
 			meter:        opts.meter,
 			defaultRetry: c.retryStrategyWrapper.wrapped,
+
+			circuitBreakers: opts.circuitBreakers,
 		}
 	default:
 		return &stdConnectionMgr{
@@ -113,6 +135,9 @@ func (c *Cluster) newConnectionMgr(protocol string, opts *newConnectionMgrOption
 			timeouts:             c.timeoutsConfig,
 			tracer:               opts.tracer,
 			meter:                opts.meter,
+			rateLimiters:         opts.rateLimiters,
+			circuitBreakers:      opts.circuitBreakers,
+			recorder:             opts.recorder,
 			preferredServerGroup: opts.preferredServerGroup,
 		}
 	}
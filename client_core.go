@@ -3,10 +3,13 @@ package gocb
 import (
 	"crypto/x509"
 	"errors"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/couchbase/gocbcore/v10"
+	"github.com/couchbase/gocbcore/v10/memd"
+	"github.com/google/uuid"
 )
 
 type stdConnectionMgr struct {
@@ -19,6 +22,9 @@ type stdConnectionMgr struct {
 	timeouts             TimeoutsConfig
 	tracer               *tracerWrapper
 	meter                *meterWrapper
+	rateLimiters         *rateLimiters
+	circuitBreakers      *circuitBreakerRegistry
+	recorder             *httpRecorder
 	txns                 *transactionsProviderCore
 	preferredServerGroup string
 
@@ -181,10 +187,40 @@ func (c *stdConnectionMgr) getKvProvider(bucketName string) (kvProvider, error)
 		snapshotProvider: &stdCoreConfigSnapshotProvider{agent: agent},
 
 		tracer:               c.tracer,
+		rateLimiter:          c.rateLimiters.kvLimiter(),
+		circuitBreakers:      newKvCircuitBreakers(c.circuitBreakers, bucketName, coreKvEndpointResolver(agent)),
 		preferredServerGroup: c.preferredServerGroup,
 	}, nil
 }
 
+// coreKvEndpointResolver returns a function mapping a key to the address of the node holding its active vbucket, in
+// the same form as the LastDispatchedTo of errors.
+func coreKvEndpointResolver(agent *gocbcore.Agent) func(key []byte) string {
+	return func(key []byte) string {
+		snapshot, err := agent.ConfigSnapshot()
+		if err != nil {
+			return ""
+		}
+
+		serverIdx, err := snapshot.KeyToServer(key, 0)
+		if err != nil {
+			return ""
+		}
+
+		endpoints := agent.MemdEps()
+		if serverIdx < 0 || serverIdx >= len(endpoints) {
+			return ""
+		}
+
+		endpoint := endpoints[serverIdx]
+		if idx := strings.Index(endpoint, "://"); idx >= 0 {
+			endpoint = endpoint[idx+3:]
+		}
+
+		return endpoint
+	}
+}
+
 func (c *stdConnectionMgr) getKvBulkProvider(bucketName string) (kvBulkProvider, error) {
 	if err := c.canPerformOp(); err != nil {
 		return nil, err
@@ -200,8 +236,9 @@ func (c *stdConnectionMgr) getKvBulkProvider(bucketName string) (kvBulkProvider,
 	return &kvBulkProviderCore{
 		agent: agent,
 
-		tracer: c.tracer,
-		meter:  c.meter,
+		tracer:      c.tracer,
+		meter:       c.meter,
+		rateLimiter: c.rateLimiters.kvLimiter(),
 	}, nil
 }
 
@@ -220,6 +257,55 @@ func (c *stdConnectionMgr) getKvCapabilitiesProvider(bucketName string) (kvCapab
 	return agent.Internal(), nil
 }
 
+func (c *stdConnectionMgr) getChangeStreamProvider(bucketName string) (changeStreamProvider, error) {
+	if err := c.canPerformOp(); err != nil {
+		return nil, err
+	}
+
+	if c.agentgroup == nil {
+		return nil, errors.New("cluster not yet connected")
+	}
+	agent := c.agentgroup.GetAgent(bucketName)
+	if agent == nil {
+		return nil, errors.New("bucket not yet connected")
+	}
+
+	c.lock.Lock()
+	config := &gocbcore.DCPAgentConfig{
+		UserAgent:          c.config.UserAgent,
+		BucketName:         bucketName,
+		SeedConfig:         c.config.SeedConfig,
+		SecurityConfig:     c.config.SecurityConfig,
+		CompressionConfig:  c.config.CompressionConfig,
+		ConfigPollerConfig: c.config.ConfigPollerConfig,
+		IoConfig: gocbcore.IoConfig{
+			NetworkType:    c.config.IoConfig.NetworkType,
+			UseCollections: true,
+		},
+		KVConfig: gocbcore.KVConfig{
+			ConnectTimeout: c.config.KVConfig.ConnectTimeout,
+		},
+		HTTPConfig: c.config.HTTPConfig,
+		DCPConfig: gocbcore.DCPConfig{
+			UseExpiryOpcode: true,
+		},
+	}
+	c.lock.Unlock()
+
+	dcpAgent, err := gocbcore.CreateDcpAgent(config, "gocb-changes-"+uuid.NewString(),
+		memd.DcpOpenFlagProducer|memd.DcpOpenFlagIncludeDeleteTimes)
+	if err != nil {
+		return nil, maybeEnhanceKVErr(err, bucketName, "", "", "")
+	}
+
+	return &changeStreamProviderCore{
+		agent:      agent,
+		dcpAgent:   dcpAgent,
+		bucketName: bucketName,
+		observers:  make(map[uint16]*changeStreamObserverCore),
+	}, nil
+}
+
 func (c *stdConnectionMgr) getViewProvider(bucketName string) (viewProvider, error) {
 	if err := c.canPerformOp(); err != nil {
 		return nil, err
@@ -235,7 +321,7 @@ func (c *stdConnectionMgr) getViewProvider(bucketName string) (viewProvider, err
 	}
 
 	return &viewProviderCore{
-		provider:             &viewProviderWrapper{provider: agent},
+		provider:             c.recorder.viewProvider(&viewProviderWrapper{provider: agent}),
 		retryStrategyWrapper: c.retryStrategyWrapper,
 		transcoder:           c.transcoder,
 		timeouts:             c.timeouts,
@@ -279,12 +365,13 @@ func (c *stdConnectionMgr) getQueryProvider() (queryProvider, error) {
 	}
 
 	return &queryProviderCore{
-		provider: &queryProviderWrapper{provider: c.agentgroup},
+		provider: c.recorder.queryProvider(&queryProviderWrapper{provider: c.agentgroup}),
 
 		retryStrategyWrapper: c.retryStrategyWrapper,
 		transcoder:           c.transcoder,
 		timeouts:             c.timeouts,
 		tracer:               c.tracer,
+		rateLimiter:          c.rateLimiters.queryLimiter(),
 	}, nil
 }
 
@@ -298,12 +385,13 @@ func (c *stdConnectionMgr) getQueryIndexProvider() (queryIndexProvider, error) {
 	}
 
 	return &queryProviderCore{
-		provider: &queryProviderWrapper{provider: c.agentgroup},
+		provider: c.recorder.queryProvider(&queryProviderWrapper{provider: c.agentgroup}),
 
 		retryStrategyWrapper: c.retryStrategyWrapper,
 		transcoder:           c.transcoder,
 		timeouts:             c.timeouts,
 		tracer:               c.tracer,
+		rateLimiter:          c.rateLimiters.queryLimiter(),
 	}, nil
 }
 
@@ -322,7 +410,7 @@ func (c *stdConnectionMgr) getAnalyticsProvider() (analyticsProvider, error) {
 	}
 
 	return &analyticsProviderCore{
-		provider: &analyticsProviderWrapper{provider: c.agentgroup},
+		provider: c.recorder.analyticsProvider(&analyticsProviderWrapper{provider: c.agentgroup}),
 		mgmtProvider: &mgmtProviderCore{
 			provider:             mgmtProvider,
 			mgmtTimeout:          c.timeouts.ManagementTimeout,
@@ -351,7 +439,7 @@ func (c *stdConnectionMgr) getAnalyticsIndexProvider() (analyticsIndexProvider,
 	}
 
 	return &analyticsProviderCore{
-		provider: &analyticsProviderWrapper{provider: c.agentgroup},
+		provider: c.recorder.analyticsProvider(&analyticsProviderWrapper{provider: c.agentgroup}),
 		mgmtProvider: &mgmtProviderCore{
 			provider:             mgmtProvider,
 			mgmtTimeout:          c.timeouts.ManagementTimeout,
@@ -375,11 +463,12 @@ func (c *stdConnectionMgr) getSearchProvider() (searchProvider, error) {
 	}
 
 	return &searchProviderCore{
-		provider:             &searchProviderWrapper{agent: c.agentgroup},
+		provider:             c.recorder.searchProvider(&searchProviderWrapper{agent: c.agentgroup}),
 		retryStrategyWrapper: c.retryStrategyWrapper,
 		transcoder:           c.transcoder,
 		timeouts:             c.timeouts,
 		tracer:               c.tracer,
+		rateLimiter:          c.rateLimiters.searchLimiter(),
 	}, nil
 }
 
@@ -431,9 +520,9 @@ func (c *stdConnectionMgr) getHTTPProvider(bucketName string) (httpProvider, err
 	}
 
 	if bucketName == "" {
-		return &httpProviderWrapper{
+		return c.recorder.httpProvider(&httpProviderWrapper{
 			provider: c.agentgroup,
-		}, nil
+		}), nil
 	}
 
 	agent := c.agentgroup.GetAgent(bucketName)
@@ -441,9 +530,9 @@ func (c *stdConnectionMgr) getHTTPProvider(bucketName string) (httpProvider, err
 		return nil, errors.New("bucket not yet connected")
 	}
 
-	return &httpProviderWrapper{
+	return c.recorder.httpProvider(&httpProviderWrapper{
 		provider: agent,
-	}, nil
+	}), nil
 }
 
 func (c *stdConnectionMgr) getDiagnosticsProvider(bucketName string) (diagnosticsProvider, error) {
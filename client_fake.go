@@ -0,0 +1,428 @@
+package gocb
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/gocbcore/v10"
+)
+
+// FakeOperation describes a key-value operation performed against a cluster connected using the fake:// scheme.
+// Internal: This should never be used and is not supported, use the gocbtest package instead.
+type FakeOperation struct {
+	// Name is the name of the operation as used in tracing, e.g. "get" or "mutate_in".
+	Name           string
+	BucketName     string
+	ScopeName      string
+	CollectionName string
+	DocumentID     string
+}
+
+// FakeFault describes a fault injected into an operation against a cluster connected using the fake:// scheme.
+// Internal: This should never be used and is not supported, use the gocbtest package instead.
+type FakeFault struct {
+	// Delay is how long to wait before the operation is processed. If the operation times out or its context is
+	// cancelled during the delay then it fails without being applied.
+	Delay time.Duration
+
+	// Timeout fails the operation with a TimeoutError without it being applied, ambiguous for mutations.
+	Timeout bool
+
+	// Err fails the operation with the error without it being applied. Errors which the SDK would retry, such as
+	// ErrTemporaryFailure, are retried according to the retry strategy of the operation.
+	Err error
+}
+
+// FakeHook is called before each attempt of an operation against a cluster connected using the fake:// scheme.
+// Returning nil lets the attempt proceed normally.
+// Internal: This should never be used and is not supported, use the gocbtest package instead.
+type FakeHook func(op FakeOperation) *FakeFault
+
+// fakeConnectionMgr is used for the fake:// scheme, serving key-value operations from an in-memory store rather than
+// a cluster. It is also used when replaying recorded traffic, in which case the HTTP based services are served from
+// the recordings. Every other service is unavailable.
+type fakeConnectionMgr struct {
+	store                *fakeStore
+	retryStrategyWrapper *coreRetryStrategyWrapper
+	transcoder           Transcoder
+	timeouts             TimeoutsConfig
+	tracer               *tracerWrapper
+	meter                *meterWrapper
+	rateLimiters         *rateLimiters
+	recorder             *httpRecorder
+
+	closed      atomic.Bool
+	activeOpsWg sync.WaitGroup
+}
+
+func (c *fakeConnectionMgr) connect() error {
+	return nil
+}
+
+func (c *fakeConnectionMgr) openBucket(bucketName string) error {
+	return nil
+}
+
+func (c *fakeConnectionMgr) buildConfig(cluster *Cluster) error {
+	return nil
+}
+
+func (c *fakeConnectionMgr) canPerformOp() error {
+	if c.closed.Load() {
+		return ErrShutdown
+	}
+
+	return nil
+}
+
+func (c *fakeConnectionMgr) canReplay() error {
+	if err := c.canPerformOp(); err != nil {
+		return err
+	}
+
+	if !c.recorder.replaying() {
+		return ErrFeatureNotAvailable
+	}
+
+	return nil
+}
+
+func (c *fakeConnectionMgr) MarkOpBeginning() {
+	c.activeOpsWg.Add(1)
+}
+
+func (c *fakeConnectionMgr) MarkOpCompleted() {
+	c.activeOpsWg.Done()
+}
+
+func (c *fakeConnectionMgr) getKvProvider(bucketName string) (kvProvider, error) {
+	if err := c.canPerformOp(); err != nil {
+		return nil, err
+	}
+
+	return &kvProviderFake{
+		store:  c.store,
+		tracer: c.tracer,
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getKvBulkProvider(bucketName string) (kvBulkProvider, error) {
+	if err := c.canPerformOp(); err != nil {
+		return nil, err
+	}
+
+	return &kvBulkProviderFake{
+		kv: &kvProviderFake{
+			store:  c.store,
+			tracer: c.tracer,
+		},
+		meter: c.meter,
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getKvCapabilitiesProvider(bucketName string) (kvCapabilityVerifier, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &replayCapabilityVerifier{}, nil
+}
+
+func (c *fakeConnectionMgr) getChangeStreamProvider(bucketName string) (changeStreamProvider, error) {
+	if err := c.canPerformOp(); err != nil {
+		return nil, err
+	}
+
+	return newChangeStreamProviderFake(c.store, bucketName), nil
+}
+
+func (c *fakeConnectionMgr) getViewProvider(bucketName string) (viewProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &viewProviderCore{
+		provider:             c.recorder.viewProvider(nil),
+		retryStrategyWrapper: c.retryStrategyWrapper,
+		transcoder:           c.transcoder,
+		timeouts:             c.timeouts,
+		tracer:               c.tracer,
+		bucketName:           bucketName,
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getViewIndexProvider(bucketName string) (viewIndexProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &viewIndexProviderCore{
+		mgmtProvider: &mgmtProviderCore{
+			provider:             c.recorder.httpProvider(nil),
+			mgmtTimeout:          c.timeouts.ManagementTimeout,
+			retryStrategyWrapper: c.retryStrategyWrapper,
+		},
+		bucketName: bucketName,
+		tracer:     c.tracer,
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getQueryProvider() (queryProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &queryProviderCore{
+		provider: c.recorder.queryProvider(nil),
+
+		retryStrategyWrapper: c.retryStrategyWrapper,
+		transcoder:           c.transcoder,
+		timeouts:             c.timeouts,
+		tracer:               c.tracer,
+		rateLimiter:          c.rateLimiters.queryLimiter(),
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getQueryIndexProvider() (queryIndexProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &queryProviderCore{
+		provider: c.recorder.queryProvider(nil),
+
+		retryStrategyWrapper: c.retryStrategyWrapper,
+		transcoder:           c.transcoder,
+		timeouts:             c.timeouts,
+		tracer:               c.tracer,
+		rateLimiter:          c.rateLimiters.queryLimiter(),
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getAnalyticsProvider() (analyticsProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &analyticsProviderCore{
+		provider: c.recorder.analyticsProvider(nil),
+		mgmtProvider: &mgmtProviderCore{
+			provider:             c.recorder.httpProvider(nil),
+			mgmtTimeout:          c.timeouts.ManagementTimeout,
+			retryStrategyWrapper: c.retryStrategyWrapper,
+		},
+
+		retryStrategyWrapper: c.retryStrategyWrapper,
+		transcoder:           c.transcoder,
+		analyticsTimeout:     c.timeouts.AnalyticsTimeout,
+		tracer:               c.tracer,
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getAnalyticsIndexProvider() (analyticsIndexProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &analyticsProviderCore{
+		provider: c.recorder.analyticsProvider(nil),
+		mgmtProvider: &mgmtProviderCore{
+			provider:             c.recorder.httpProvider(nil),
+			mgmtTimeout:          c.timeouts.ManagementTimeout,
+			retryStrategyWrapper: c.retryStrategyWrapper,
+		},
+
+		retryStrategyWrapper: c.retryStrategyWrapper,
+		transcoder:           c.transcoder,
+		analyticsTimeout:     c.timeouts.AnalyticsTimeout,
+		tracer:               c.tracer,
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getSearchProvider() (searchProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &searchProviderCore{
+		provider:             c.recorder.searchProvider(nil),
+		retryStrategyWrapper: c.retryStrategyWrapper,
+		transcoder:           c.transcoder,
+		timeouts:             c.timeouts,
+		tracer:               c.tracer,
+		rateLimiter:          c.rateLimiters.searchLimiter(),
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getHTTPProvider(bucketName string) (httpProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return c.recorder.httpProvider(nil), nil
+}
+
+func (c *fakeConnectionMgr) getDiagnosticsProvider(bucketName string) (diagnosticsProvider, error) {
+	return nil, ErrFeatureNotAvailable
+}
+
+func (c *fakeConnectionMgr) getWaitUntilReadyProvider(bucketName string) (waitUntilReadyProvider, error) {
+	if err := c.canPerformOp(); err != nil {
+		return nil, err
+	}
+
+	return &waitUntilReadyProviderFake{}, nil
+}
+
+func (c *fakeConnectionMgr) getCollectionsManagementProvider(bucketName string) (collectionsManagementProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &collectionsManagementProviderCore{
+		mgmtProvider: &mgmtProviderCore{
+			provider:             c.recorder.httpProvider(nil),
+			mgmtTimeout:          c.timeouts.ManagementTimeout,
+			retryStrategyWrapper: c.retryStrategyWrapper,
+		},
+		featureVerifier: &replayCapabilityVerifier{},
+		bucketName:      bucketName,
+		tracer:          c.tracer,
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getBucketManagementProvider() (bucketManagementProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &bucketManagementProviderCore{
+		mgmtProvider: &mgmtProviderCore{
+			provider:             c.recorder.httpProvider(nil),
+			mgmtTimeout:          c.timeouts.ManagementTimeout,
+			retryStrategyWrapper: c.retryStrategyWrapper,
+		},
+		tracer: c.tracer,
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getSearchIndexProvider() (searchIndexProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &searchIndexProviderCore{
+		mgmtProvider: &mgmtProviderCore{
+			provider:             c.recorder.httpProvider(nil),
+			mgmtTimeout:          c.timeouts.ManagementTimeout,
+			retryStrategyWrapper: c.retryStrategyWrapper,
+		},
+		searchCapVerifier: &replayCapabilityVerifier{},
+		tracer:            c.tracer,
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getSearchCapabilitiesProvider() (searchCapabilityVerifier, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &replayCapabilityVerifier{}, nil
+}
+
+func (c *fakeConnectionMgr) getEventingManagementProvider() (eventingManagementProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &eventingManagementProviderCore{
+		mgmtProvider: &mgmtProviderCore{
+			provider:             c.recorder.httpProvider(nil),
+			mgmtTimeout:          c.timeouts.ManagementTimeout,
+			retryStrategyWrapper: c.retryStrategyWrapper,
+		},
+		tracer: c.tracer,
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getUserManagerProvider() (userManagerProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &userManagerProviderCore{
+		provider: &mgmtProviderCore{
+			provider:             c.recorder.httpProvider(nil),
+			mgmtTimeout:          c.timeouts.ManagementTimeout,
+			retryStrategyWrapper: c.retryStrategyWrapper,
+		},
+		tracer: c.tracer,
+	}, nil
+}
+
+func (c *fakeConnectionMgr) getInternalProvider() (internalProvider, error) {
+	if err := c.canReplay(); err != nil {
+		return nil, err
+	}
+
+	return &internalProviderCore{
+		provider: &mgmtProviderCore{
+			provider:             c.recorder.httpProvider(nil),
+			mgmtTimeout:          c.timeouts.ManagementTimeout,
+			retryStrategyWrapper: c.retryStrategyWrapper,
+		},
+		tracer: c.tracer,
+		meter:  c.meter,
+	}, nil
+}
+
+func (c *fakeConnectionMgr) initTransactions(config TransactionsConfig, cluster *Cluster) error {
+	return nil
+}
+
+func (c *fakeConnectionMgr) getTransactionsProvider() (transactionsProvider, error) {
+	return nil, ErrFeatureNotAvailable
+}
+
+func (c *fakeConnectionMgr) connection(bucketName string) (*gocbcore.Agent, error) {
+	return nil, ErrFeatureNotAvailable
+}
+
+func (c *fakeConnectionMgr) close() error {
+	if !c.closed.CompareAndSwap(false, true) {
+		return ErrShutdown
+	}
+
+	logDebugf("Waiting for any active requests to complete")
+	c.activeOpsWg.Wait()
+
+	if c.tracer != nil {
+		tracerDecRef(c.tracer.tracer)
+		c.tracer = nil
+	}
+	if c.meter != nil {
+		if meter, ok := c.meter.meter.(*LoggingMeter); ok {
+			meter.close()
+		}
+		c.meter = nil
+	}
+
+	return nil
+}
+
+func (c *fakeConnectionMgr) getMeter() *meterWrapper {
+	return c.meter
+}
+
+type waitUntilReadyProviderFake struct{}
+
+func (wpw *waitUntilReadyProviderFake) WaitUntilReady(ctx context.Context, deadline time.Time,
+	opts *WaitUntilReadyOptions) error {
+	if opts.DesiredState == ClusterStateOffline {
+		return makeInvalidArgumentsError("cannot use offline as a desired state")
+	}
+
+	return nil
+}
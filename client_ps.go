@@ -28,6 +28,8 @@ type psConnectionMgr struct {
 	meter        *meterWrapper
 	defaultRetry RetryStrategy
 
+	circuitBreakers *circuitBreakerRegistry
+
 	closed      atomic.Bool
 	activeOpsWg sync.WaitGroup
 }
@@ -116,7 +118,8 @@ func (c *psConnectionMgr) getKvProvider(bucketName string) (kvProvider, error) {
 	return &kvProviderPs{
 		client: kv,
 
-		tracer: c.tracer,
+		tracer:         c.tracer,
+		circuitBreaker: c.circuitBreakers.get(ServiceTypeKeyValue, "", c.host, c.circuitBreakers.enforceCouchbase2()),
 	}, nil
 }
 
@@ -253,6 +256,10 @@ func (c *psConnectionMgr) getWaitUntilReadyProvider(bucketName string) (waitUnti
 	}, nil
 }
 
+func (c *psConnectionMgr) getChangeStreamProvider(bucketName string) (changeStreamProvider, error) {
+	return nil, ErrFeatureNotAvailable
+}
+
 func (c *psConnectionMgr) getEventingManagementProvider() (eventingManagementProvider, error) {
 	return nil, ErrFeatureNotAvailable
 }
@@ -362,6 +369,7 @@ type psOpManager interface {
 	RetryReasonFor(error) RetryReason
 	Timeout() time.Duration
 	Context() context.Context
+	CircuitBreaker() *observedCircuitBreaker
 }
 
 type psOpManagerDefault struct {
@@ -493,6 +501,10 @@ func (m *psOpManagerDefault) Context() context.Context {
 	return m.ctx
 }
 
+func (m *psOpManagerDefault) CircuitBreaker() *observedCircuitBreaker {
+	return nil
+}
+
 func wrapPSOpCtx[ReqT any, RespT any](ctx context.Context, m psOpManager,
 	req ReqT,
 	fn func(context.Context, ReqT, ...grpc.CallOption) (RespT, error)) (RespT, error) {
@@ -514,9 +526,19 @@ func wrapPSOpCtxWithPeek[ReqT any, RespT any](ctx context.Context,
 	fn func(context.Context, ReqT, ...grpc.CallOption) (RespT, error),
 	peekResult func(RespT) error) (RespT, error) {
 	retryReq := newRetriableRequestPS(m.OpName(), m.IsIdempotent(), parentSpan, m.OperationID(), m.RetryStrategy())
+	if deadline, ok := ctx.Deadline(); ok {
+		retryReq.deadline = deadline
+	}
 	m.SetRetryRequest(retryReq)
 
+	breaker := m.CircuitBreaker()
+	if !breaker.allowsRequest() {
+		var emptyResp RespT
+		return emptyResp, wrapError(ErrCircuitBreakerOpen, "circuit breaker open for "+m.OpName())
+	}
+
 	res, err := handleRetriableRequest(ctx, m.CreatedAt(), m.Tracer(), req, retryReq, fn, m.RetryReasonFor, peekResult)
+	breaker.record(err)
 	if err != nil {
 		var emptyResp RespT
 		return emptyResp, err
@@ -31,6 +31,7 @@ type Cluster struct {
 	orphanLoggerSampleSize uint32
 
 	circuitBreakerConfig CircuitBreakerConfig
+	circuitBreakers      *circuitBreakerRegistry
 	securityConfig       SecurityConfig
 	internalConfig       InternalConfig
 	transactionsConfig   TransactionsConfig
@@ -42,6 +43,8 @@ type Cluster struct {
 	keyspace keyspace
 
 	preferredServerGroup string
+
+	queryPlanCapture *queryPlanCapturer
 }
 
 // IoConfig specifies IO related configuration options.
@@ -136,6 +139,15 @@ type ClusterOptions struct {
 	// CircuitBreakerConfig specifies options for the circuit breakers.
 	CircuitBreakerConfig CircuitBreakerConfig
 
+	// RateLimitConfig specifies options for client side rate limiting.
+	// UNCOMMITTED: This API may change in the future.
+	RateLimitConfig RateLimitConfig
+
+	// RecordingConfig specifies options for recording and replaying query, search, analytics, view and management
+	// traffic.
+	// UNCOMMITTED: This API may change in the future.
+	RecordingConfig RecordingConfig
+
 	// IoConfig specifies IO related configuration options.
 	IoConfig IoConfig
 
@@ -148,6 +160,10 @@ type ClusterOptions struct {
 	// CompressionConfig specifies compression related configuration options.
 	CompressionConfig CompressionConfig
 
+	// QueryPlanCaptureConfig specifies options for capturing the plans of slow queries.
+	// UNCOMMITTED: This API may change in the future.
+	QueryPlanCaptureConfig QueryPlanCaptureConfig
+
 	// PreferredServerGroup specifies the name of the server group to use with operations supporting ReadPreference.
 	// UNCOMMITTED: This API may change in the future.
 	PreferredServerGroup string
@@ -275,6 +291,14 @@ func Connect(connStr string, opts ClusterOptions) (*Cluster, error) {
 		return nil, err
 	}
 
+	recorder, err := newHTTPRecorder(opts.RecordingConfig)
+	if err != nil {
+		return nil, err
+	}
+	if recorder.recording() && (connSpec.Scheme == "couchbase2" || connSpec.Scheme == "fake") {
+		return nil, makeInvalidArgumentsError("recording is not supported with the " + connSpec.Scheme + " scheme")
+	}
+
 	var initialTracer RequestTracer
 	if opts.Tracer != nil {
 		initialTracer = opts.Tracer
@@ -282,6 +306,7 @@ func Connect(connStr string, opts ClusterOptions) (*Cluster, error) {
 		initialTracer = NewThresholdLoggingTracer(nil)
 	}
 	tracerAddRef(initialTracer)
+	cluster.queryPlanCapture = newQueryPlanCapturer(opts.QueryPlanCaptureConfig, initialTracer)
 
 	meter := opts.Meter
 	if meter == nil {
@@ -289,9 +314,19 @@ func Connect(connStr string, opts ClusterOptions) (*Cluster, error) {
 		meter = agMeter
 	}
 
+	limiters, err := newRateLimiters(opts.RateLimitConfig, meter)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster.circuitBreakers = newCircuitBreakerRegistry(cluster.circuitBreakerConfig, meter)
+
 	cli := cluster.newConnectionMgr(connSpec.Scheme, &newConnectionMgrOptions{
 		tracer:               newTracerWrapper(initialTracer),
 		meter:                newMeterWrapper(meter),
+		rateLimiters:         limiters,
+		circuitBreakers:      cluster.circuitBreakers,
+		recorder:             recorder,
 		preferredServerGroup: opts.PreferredServerGroup,
 	})
 	err = cli.buildConfig(cluster)
@@ -448,6 +483,13 @@ func (c *Cluster) WaitUntilReady(timeout time.Duration, opts *WaitUntilReadyOpti
 	})
 }
 
+// CircuitBreakers returns a point in time view of the circuit breakers that the SDK has observed, which can be used
+// to understand why requests are being fast failed.
+// UNCOMMITTED: This API may change in the future.
+func (c *Cluster) CircuitBreakers() []CircuitBreakerEndpointState {
+	return c.circuitBreakers.snapshot()
+}
+
 // Close shuts down all buckets in this cluster and invalidates any references this cluster has.
 func (c *Cluster) Close(opts *ClusterCloseOptions) error {
 	var overallErr error
@@ -1,6 +1,7 @@
 package gocb
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"time"
@@ -129,6 +130,8 @@ type AnalyticsResult struct {
 	reader analyticsRowReader
 
 	rowBytes []byte
+
+	ctx context.Context
 }
 
 func newAnalyticsResult(reader analyticsRowReader) *AnalyticsResult {
@@ -268,7 +271,13 @@ func (c *Cluster) AnalyticsQuery(statement string, opts *AnalyticsOptions) (*Ana
 			opts = &AnalyticsOptions{}
 		}
 
-		return provider.AnalyticsQuery(statement, nil, opts)
+		res, err := provider.AnalyticsQuery(statement, nil, opts)
+		if err != nil {
+			return nil, err
+		}
+		res.ctx = opts.Context
+
+		return res, nil
 	})
 }
 
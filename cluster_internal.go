@@ -89,3 +89,38 @@ func (ic *InternalCluster) GetNodesMetadata(opts *GetNodesMetadataOptions) ([]No
 type clusterLabelsProvider interface {
 	ClusterLabels() gocbcore.ClusterLabels
 }
+
+// SetFakeHook sets the hook called before each attempt of a key-value operation against a cluster connected using
+// the fake:// scheme, replacing any existing hook. A nil hook removes it.
+// Internal: This should never be used and is not supported, use the gocbtest package instead.
+func (ic *InternalCluster) SetFakeHook(hook FakeHook) error {
+	store, err := ic.fakeStore()
+	if err != nil {
+		return err
+	}
+
+	store.setHook(hook)
+	return nil
+}
+
+// SetFakeClock sets the function used by a cluster connected using the fake:// scheme to tell the time, which
+// determines when documents expire and locks are released. A nil function restores the system clock.
+// Internal: This should never be used and is not supported, use the gocbtest package instead.
+func (ic *InternalCluster) SetFakeClock(now func() time.Time) error {
+	store, err := ic.fakeStore()
+	if err != nil {
+		return err
+	}
+
+	store.setClock(now)
+	return nil
+}
+
+func (ic *InternalCluster) fakeStore() (*fakeStore, error) {
+	mgr, ok := ic.cluster.connectionManager.(*fakeConnectionMgr)
+	if !ok {
+		return nil, wrapError(ErrFeatureNotAvailable, "cluster was not connected using the fake:// scheme")
+	}
+
+	return mgr.store, nil
+}
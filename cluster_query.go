@@ -1,6 +1,7 @@
 package gocb
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"time"
@@ -128,16 +129,29 @@ type QueryResultRaw struct {
 	reader        queryRowReader
 	transactionID string
 	nextRowBytes  []byte
+
+	finished func(elapsed time.Duration)
 }
 
 // NextBytes returns the next row as bytes.
 func (qrr *QueryResultRaw) NextBytes() []byte {
 	rowBytes := qrr.nextRowBytes
 	qrr.nextRowBytes = qrr.reader.NextRow()
+	if rowBytes == nil {
+		qrr.finish()
+	}
 
 	return rowBytes
 }
 
+func (qrr *QueryResultRaw) finish() {
+	if qrr.finished != nil {
+		finished := qrr.finished
+		qrr.finished = nil
+		finishQueryResult(qrr.reader, finished)
+	}
+}
+
 // Err returns any errors that have occurred on the stream
 func (qrr *QueryResultRaw) Err() error {
 	err := qrr.reader.Err()
@@ -155,6 +169,7 @@ func (qrr *QueryResultRaw) Err() error {
 // Close marks the results as closed, returning any errors that occurred during reading the results.
 func (qrr *QueryResultRaw) Close() error {
 	err := qrr.reader.Close()
+	qrr.finish()
 	if err != nil {
 		if qrr.transactionID != "" {
 			return singleQueryErrToTransactionError(err, qrr.transactionID)
@@ -179,6 +194,11 @@ type QueryResult struct {
 	nextRowBytes  []byte
 	rowBytes      []byte
 	endpoint      string
+
+	ctx context.Context
+
+	// finished is called once, when the rows of the result have all been read or the result is closed.
+	finished func(elapsed time.Duration)
 }
 
 func newQueryResult(reader queryRowReader) *QueryResult {
@@ -197,14 +217,46 @@ func (r *QueryResult) Raw() *QueryResultRaw {
 		reader:        r.reader,
 		transactionID: r.transactionID,
 		nextRowBytes:  r.nextRowBytes,
+		finished:      r.finished,
 	}
 
 	r.reader = nil
 	r.transactionID = ""
 	r.nextRowBytes = nil
+	r.finished = nil
 	return vr
 }
 
+func (r *QueryResult) finish() {
+	if r.finished != nil {
+		finished := r.finished
+		r.finished = nil
+		finishQueryResult(r.reader, finished)
+	}
+}
+
+// finishQueryResult calls finished with the elapsed time reported by the server, which is only known once every row
+// has been read. The time taken to read the rows is not used as it includes however long the application spent
+// processing each of them.
+func finishQueryResult(reader queryRowReader, finished func(elapsed time.Duration)) {
+	metaDataBytes, err := reader.MetaData()
+	if err != nil {
+		return
+	}
+
+	var jsonResp jsonQueryResponse
+	if err := json.Unmarshal(metaDataBytes, &jsonResp); err != nil || jsonResp.Metrics == nil {
+		return
+	}
+
+	elapsed, err := time.ParseDuration(jsonResp.Metrics.ElapsedTime)
+	if err != nil || elapsed <= 0 {
+		return
+	}
+
+	finished(elapsed)
+}
+
 func (r *QueryResult) peekNext() []byte {
 	return r.nextRowBytes
 }
@@ -216,6 +268,7 @@ func (r *QueryResult) Next() bool {
 	}
 
 	if len(r.nextRowBytes) == 0 {
+		r.finish()
 		return false
 	}
 
@@ -268,6 +321,7 @@ func (r *QueryResult) Close() error {
 	}
 
 	err := r.reader.Close()
+	r.finish()
 	if err != nil {
 		if r.transactionID != "" {
 			return singleQueryErrToTransactionError(err, r.transactionID)
@@ -299,6 +353,7 @@ func (r *QueryResult) One(valuePtr interface{}) error {
 		// do nothing with the row
 	}
 	r.nextRowBytes = nil
+	r.finish()
 
 	return json.Unmarshal(valueBytes, valuePtr)
 }
@@ -312,6 +367,7 @@ func (r *QueryResult) MetaData() (*QueryMetaData, error) {
 	}
 
 	metaDataBytes, err := r.reader.MetaData()
+	r.finish()
 	if err != nil {
 		return nil, err
 	}
@@ -361,6 +417,18 @@ func (c *Cluster) Query(statement string, opts *QueryOptions) (*QueryResult, err
 			return c.Transactions().singleQuery(statement, nil, *opts)
 		}
 
-		return provider.Query(statement, nil, opts)
+		// Slow queries are captured once their result has been read, using the elapsed time reported by the server.
+		finished := c.queryPlanCapture.begin(statement, opts, nil, c.ExplainQuery, c.AdviseQuery)
+		res, err := provider.Query(statement, nil, opts)
+		if err != nil {
+			if finished != nil {
+				finished(0)
+			}
+			return nil, err
+		}
+		res.ctx = opts.Context
+		res.finished = finished
+
+		return res, nil
 	})
 }
@@ -0,0 +1,522 @@
+package gocb
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExplainQueryOptions is the set of options available to an ExplainQuery operation.
+// UNCOMMITTED: This API may change in the future.
+type ExplainQueryOptions struct {
+	// PositionalParameters and NamedParameters are the parameters of the statement, which the plan can depend upon.
+	PositionalParameters []interface{}
+	NamedParameters      map[string]interface{}
+
+	Timeout       time.Duration
+	RetryStrategy RetryStrategy
+	ParentSpan    RequestSpan
+
+	// Using a deadlined Context alongside a Timeout will cause the shorter of the two to cause cancellation, this
+	// also applies to global level timeouts.
+	// UNCOMMITTED: This API may change in the future.
+	Context context.Context
+}
+
+func (opts *ExplainQueryOptions) toQueryOptions() *QueryOptions {
+	if opts == nil {
+		opts = &ExplainQueryOptions{}
+	}
+
+	return &QueryOptions{
+		PositionalParameters: opts.PositionalParameters,
+		NamedParameters:      opts.NamedParameters,
+		Timeout:              opts.Timeout,
+		RetryStrategy:        opts.RetryStrategy,
+		ParentSpan:           opts.ParentSpan,
+		Context:              opts.Context,
+		Adhoc:                true,
+	}
+}
+
+// AdviseQueryOptions is the set of options available to an AdviseQuery operation.
+// UNCOMMITTED: This API may change in the future.
+type AdviseQueryOptions struct {
+	// PositionalParameters and NamedParameters are the parameters of the statement.
+	PositionalParameters []interface{}
+	NamedParameters      map[string]interface{}
+
+	Timeout       time.Duration
+	RetryStrategy RetryStrategy
+	ParentSpan    RequestSpan
+
+	// Using a deadlined Context alongside a Timeout will cause the shorter of the two to cause cancellation, this
+	// also applies to global level timeouts.
+	// UNCOMMITTED: This API may change in the future.
+	Context context.Context
+}
+
+func (opts *AdviseQueryOptions) toQueryOptions() *QueryOptions {
+	if opts == nil {
+		opts = &AdviseQueryOptions{}
+	}
+
+	return &QueryOptions{
+		PositionalParameters: opts.PositionalParameters,
+		NamedParameters:      opts.NamedParameters,
+		Timeout:              opts.Timeout,
+		RetryStrategy:        opts.RetryStrategy,
+		ParentSpan:           opts.ParentSpan,
+		Context:              opts.Context,
+		Adhoc:                true,
+	}
+}
+
+// QueryPlanOperator is an operator within the plan of a query, such as an index scan or a fetch.
+// UNCOMMITTED: This API may change in the future.
+type QueryPlanOperator struct {
+	// Operator is the name of the operator, such as IndexScan3, PrimaryScan3 or Fetch.
+	Operator string
+	// Index is the name of the index used by a scan, and Using is the type of the index, such as gsi or fts.
+	Index string
+	Using string
+
+	Namespace string
+	Bucket    string
+	Scope     string
+	Keyspace  string
+
+	// Covers are the expressions covered by the index of a scan, in which case no fetch is needed for them.
+	Covers []string
+
+	Children []*QueryPlanOperator
+
+	// Properties are all of the properties of the operator other than its children, as returned by the server.
+	Properties map[string]interface{}
+}
+
+// IsScan returns whether the operator reads from a keyspace or index, rather than processing documents.
+func (op *QueryPlanOperator) IsScan() bool {
+	return strings.Contains(op.Operator, "Scan")
+}
+
+// IsCovering returns whether the operator is an index scan covering the expressions of the query.
+func (op *QueryPlanOperator) IsCovering() bool {
+	return len(op.Covers) > 0
+}
+
+// QueryPlan is the plan of a query, as returned by ExplainQuery.
+// UNCOMMITTED: This API may change in the future.
+type QueryPlan struct {
+	// Statement is the statement which was explained, as returned by the server.
+	Statement string
+	Root      *QueryPlanOperator
+	// Raw is the plan as returned by the server.
+	Raw json.RawMessage
+}
+
+// Walk calls fn for each operator of the plan, parents before their children, stopping early if fn returns false.
+func (p *QueryPlan) Walk(fn func(op *QueryPlanOperator) bool) {
+	var walk func(op *QueryPlanOperator) bool
+	walk = func(op *QueryPlanOperator) bool {
+		if !fn(op) {
+			return false
+		}
+		for _, child := range op.Children {
+			if !walk(child) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if p.Root != nil {
+		walk(p.Root)
+	}
+}
+
+// Operators returns each operator of the plan with the given name.
+func (p *QueryPlan) Operators(name string) []*QueryPlanOperator {
+	var ops []*QueryPlanOperator
+	p.Walk(func(op *QueryPlanOperator) bool {
+		if op.Operator == name {
+			ops = append(ops, op)
+		}
+		return true
+	})
+
+	return ops
+}
+
+// Scans returns each scan of the plan.
+func (p *QueryPlan) Scans() []*QueryPlanOperator {
+	var scans []*QueryPlanOperator
+	p.Walk(func(op *QueryPlanOperator) bool {
+		if op.IsScan() {
+			scans = append(scans, op)
+		}
+		return true
+	})
+
+	return scans
+}
+
+// Fetches returns each fetch of documents in the plan.
+func (p *QueryPlan) Fetches() []*QueryPlanOperator {
+	return p.Operators("Fetch")
+}
+
+// IndexesUsed returns the names of the indexes scanned by the plan.
+func (p *QueryPlan) IndexesUsed() []string {
+	var indexes []string
+	seen := make(map[string]struct{})
+	for _, scan := range p.Scans() {
+		if scan.Index == "" {
+			continue
+		}
+		if _, ok := seen[scan.Index]; ok {
+			continue
+		}
+		seen[scan.Index] = struct{}{}
+		indexes = append(indexes, scan.Index)
+	}
+
+	return indexes
+}
+
+// UsesPrimaryScan returns whether the plan scans a primary index, which usually means that a secondary index is
+// missing.
+func (p *QueryPlan) UsesPrimaryScan() bool {
+	for _, scan := range p.Scans() {
+		if strings.HasPrefix(scan.Operator, "PrimaryScan") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsCovered returns whether the plan is answered from its indexes alone, without fetching any documents.
+func (p *QueryPlan) IsCovered() bool {
+	covered := false
+	for _, scan := range p.Scans() {
+		if scan.Index == "" {
+			continue
+		}
+		if !scan.IsCovering() {
+			return false
+		}
+		covered = true
+	}
+
+	return covered && len(p.Fetches()) == 0
+}
+
+type jsonQueryPlanOperator map[string]json.RawMessage
+
+func parseQueryPlanOperator(data json.RawMessage) (*QueryPlanOperator, error) {
+	var raw jsonQueryPlanOperator
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	op := &QueryPlanOperator{
+		Properties: make(map[string]interface{}),
+	}
+
+	// Child operators are found under different names depending on the operator, such as ~children for a Sequence,
+	// ~child for a Parallel and scans for an IntersectScan, so any operator within the properties is a child.
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := raw[key]
+		if children, ok := parseQueryPlanChildren(value); ok {
+			for _, childData := range children {
+				child, err := parseQueryPlanOperator(childData)
+				if err != nil {
+					return nil, err
+				}
+				op.Children = append(op.Children, child)
+			}
+			continue
+		}
+
+		var property interface{}
+		if err := json.Unmarshal(value, &property); err != nil {
+			return nil, err
+		}
+		op.Properties[key] = property
+
+		switch key {
+		case "#operator":
+			op.Operator, _ = property.(string)
+		case "index":
+			op.Index, _ = property.(string)
+		case "using":
+			op.Using, _ = property.(string)
+		case "namespace":
+			op.Namespace, _ = property.(string)
+		case "bucket":
+			op.Bucket, _ = property.(string)
+		case "scope":
+			op.Scope, _ = property.(string)
+		case "keyspace":
+			op.Keyspace, _ = property.(string)
+		case "covers":
+			if covers, ok := property.([]interface{}); ok {
+				for _, cover := range covers {
+					if s, ok := cover.(string); ok {
+						op.Covers = append(op.Covers, s)
+					}
+				}
+			}
+		}
+	}
+
+	return op, nil
+}
+
+// parseQueryPlanChildren returns the operators within value, if it is an operator or an array of operators.
+func parseQueryPlanChildren(value json.RawMessage) ([]json.RawMessage, bool) {
+	var single struct {
+		Operator *string `json:"#operator"`
+	}
+	if err := json.Unmarshal(value, &single); err == nil && single.Operator != nil {
+		return []json.RawMessage{value}, true
+	}
+
+	var children []json.RawMessage
+	if err := json.Unmarshal(value, &children); err != nil || len(children) == 0 {
+		return nil, false
+	}
+	for _, child := range children {
+		var op struct {
+			Operator *string `json:"#operator"`
+		}
+		if err := json.Unmarshal(child, &op); err != nil || op.Operator == nil {
+			return nil, false
+		}
+	}
+
+	return children, true
+}
+
+type jsonExplainResult struct {
+	Plan json.RawMessage `json:"plan"`
+	Text string          `json:"text"`
+}
+
+func parseQueryPlan(result *QueryResult) (*QueryPlan, error) {
+	var row jsonExplainResult
+	if err := result.One(&row); err != nil {
+		return nil, err
+	}
+	if len(row.Plan) == 0 {
+		return nil, wrapError(ErrNoResult, "no query plan was returned")
+	}
+
+	root, err := parseQueryPlanOperator(row.Plan)
+	if err != nil {
+		return nil, wrapError(err, "failed to parse query plan")
+	}
+
+	return &QueryPlan{
+		Statement: strings.TrimSpace(row.Text),
+		Root:      root,
+		Raw:       row.Plan,
+	}, nil
+}
+
+// QueryIndexAdvice is an index considered by the index advisor.
+// UNCOMMITTED: This API may change in the future.
+type QueryIndexAdvice struct {
+	// Statement creates the index.
+	Statement        string
+	KeyspaceAlias    string
+	RecommendingRule string
+	IndexProperty    string
+}
+
+// QueryAdvice is the advice of the index advisor for a statement, as returned by AdviseQuery.
+// UNCOMMITTED: This API may change in the future.
+type QueryAdvice struct {
+	Statement string
+	// CurrentIndexes are the existing indexes which the statement uses.
+	CurrentIndexes []QueryIndexAdvice
+	// RecommendedIndexes are indexes which would help the statement, and RecommendedCoveringIndexes are those which
+	// would also cover it.
+	RecommendedIndexes         []QueryIndexAdvice
+	RecommendedCoveringIndexes []QueryIndexAdvice
+	// Message explains why there are no recommendations, when there are none.
+	Message string
+}
+
+type jsonQueryIndexAdvice struct {
+	IndexStatement   string `json:"index_statement"`
+	KeyspaceAlias    string `json:"keyspace_alias"`
+	RecommendingRule string `json:"recommending_rule"`
+	IndexProperty    string `json:"index_property"`
+}
+
+func (advice jsonQueryIndexAdvice) toAdvice() QueryIndexAdvice {
+	return QueryIndexAdvice{
+		Statement:        advice.IndexStatement,
+		KeyspaceAlias:    advice.KeyspaceAlias,
+		RecommendingRule: advice.RecommendingRule,
+		IndexProperty:    advice.IndexProperty,
+	}
+}
+
+type jsonAdviseInfo struct {
+	CurrentIndexes     []jsonQueryIndexAdvice `json:"current_indexes"`
+	RecommendedIndexes json.RawMessage        `json:"recommended_indexes"`
+}
+
+type jsonAdviseResult struct {
+	Query  string `json:"query"`
+	Advice struct {
+		AdviseInfo json.RawMessage `json:"adviseinfo"`
+	} `json:"advice"`
+}
+
+func parseQueryAdvice(result *QueryResult) (*QueryAdvice, error) {
+	var row jsonAdviseResult
+	if err := result.One(&row); err != nil {
+		return nil, err
+	}
+
+	// Depending on the server version the advice for a statement is either an object or an array of objects.
+	var infos []jsonAdviseInfo
+	if err := json.Unmarshal(row.Advice.AdviseInfo, &infos); err != nil {
+		var info jsonAdviseInfo
+		if err := json.Unmarshal(row.Advice.AdviseInfo, &info); err != nil {
+			return nil, wrapError(err, "failed to parse query advice")
+		}
+		infos = []jsonAdviseInfo{info}
+	}
+
+	advice := &QueryAdvice{Statement: strings.TrimSpace(row.Query)}
+	var messages []string
+	for _, info := range infos {
+		for _, current := range info.CurrentIndexes {
+			advice.CurrentIndexes = append(advice.CurrentIndexes, current.toAdvice())
+		}
+		if len(info.RecommendedIndexes) == 0 {
+			continue
+		}
+
+		// Without a recommendation the server explains why in place of the recommendations.
+		var message string
+		if err := json.Unmarshal(info.RecommendedIndexes, &message); err == nil {
+			messages = append(messages, message)
+			continue
+		}
+
+		var recommended struct {
+			Indexes         []jsonQueryIndexAdvice `json:"indexes"`
+			CoveringIndexes []jsonQueryIndexAdvice `json:"covering_indexes"`
+		}
+		if err := json.Unmarshal(info.RecommendedIndexes, &recommended); err != nil {
+			return nil, wrapError(err, "failed to parse query advice")
+		}
+		for _, index := range recommended.Indexes {
+			advice.RecommendedIndexes = append(advice.RecommendedIndexes, index.toAdvice())
+		}
+		for _, index := range recommended.CoveringIndexes {
+			advice.RecommendedCoveringIndexes = append(advice.RecommendedCoveringIndexes, index.toAdvice())
+		}
+	}
+	advice.Message = strings.Join(messages, " ")
+
+	return advice, nil
+}
+
+// queryStatementVerb returns the first keyword of a statement, in upper case.
+func queryStatementVerb(statement string) string {
+	statement = strings.TrimLeft(statement, " \t\r\n(")
+	end := strings.IndexAny(statement, " \t\r\n(")
+	if end >= 0 {
+		statement = statement[:end]
+	}
+
+	return strings.ToUpper(statement)
+}
+
+// isExplainableStatement returns whether a statement can be explained or advised upon, which is only the case for
+// queries and DML.
+func isExplainableStatement(statement string) bool {
+	switch queryStatementVerb(statement) {
+	case "SELECT", "WITH", "INSERT", "UPSERT", "UPDATE", "DELETE", "MERGE":
+		return true
+	default:
+		return false
+	}
+}
+
+// ExplainQuery returns the plan the query service would use to execute the statement, without executing it.
+// UNCOMMITTED: This API may change in the future.
+func (c *Cluster) ExplainQuery(statement string, opts *ExplainQueryOptions) (*QueryPlan, error) {
+	if !isExplainableStatement(statement) {
+		return nil, makeInvalidArgumentsError("only queries and DML statements can be explained")
+	}
+
+	result, err := c.Query("EXPLAIN "+statement, opts.toQueryOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseQueryPlan(result)
+}
+
+// AdviseQuery asks the index advisor for the indexes which would help the statement, without executing it.
+// UNCOMMITTED: This API may change in the future.
+func (c *Cluster) AdviseQuery(statement string, opts *AdviseQueryOptions) (*QueryAdvice, error) {
+	if !isExplainableStatement(statement) {
+		return nil, makeInvalidArgumentsError("only queries and DML statements can be advised upon")
+	}
+
+	result, err := c.Query("ADVISE "+statement, opts.toQueryOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseQueryAdvice(result)
+}
+
+// ExplainQuery returns the plan the query service would use to execute the statement within the scope, without
+// executing it.
+// UNCOMMITTED: This API may change in the future.
+func (s *Scope) ExplainQuery(statement string, opts *ExplainQueryOptions) (*QueryPlan, error) {
+	if !isExplainableStatement(statement) {
+		return nil, makeInvalidArgumentsError("only queries and DML statements can be explained")
+	}
+
+	result, err := s.Query("EXPLAIN "+statement, opts.toQueryOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseQueryPlan(result)
+}
+
+// AdviseQuery asks the index advisor for the indexes which would help the statement within the scope, without
+// executing it.
+// UNCOMMITTED: This API may change in the future.
+func (s *Scope) AdviseQuery(statement string, opts *AdviseQueryOptions) (*QueryAdvice, error) {
+	if !isExplainableStatement(statement) {
+		return nil, makeInvalidArgumentsError("only queries and DML statements can be advised upon")
+	}
+
+	result, err := s.Query("ADVISE "+statement, opts.toQueryOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseQueryAdvice(result)
+}
@@ -0,0 +1,348 @@
+package gocb
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v10"
+	"github.com/stretchr/testify/mock"
+)
+
+var testCoveredQueryPlan = map[string]interface{}{
+	"#operator": "Sequence",
+	"~children": []interface{}{
+		map[string]interface{}{
+			"#operator": "Sequence",
+			"~children": []interface{}{
+				map[string]interface{}{
+					"#operator": "IndexScan3",
+					"bucket":    "travel-sample",
+					"scope":     "inventory",
+					"keyspace":  "airline",
+					"namespace": "default",
+					"index":     "def_inventory_airline_country",
+					"using":     "gsi",
+					"covers":    []interface{}{"cover ((`airline`.`country`))", "cover ((meta(`airline`).`id`))"},
+				},
+				map[string]interface{}{
+					"#operator": "Parallel",
+					"~child": map[string]interface{}{
+						"#operator": "Sequence",
+						"~children": []interface{}{
+							map[string]interface{}{"#operator": "Filter", "condition": "(cover ((`airline`.`country`)) = $1)"},
+							map[string]interface{}{"#operator": "InitialProject", "result_terms": []interface{}{}},
+						},
+					},
+				},
+			},
+		},
+		map[string]interface{}{"#operator": "Stream"},
+	},
+}
+
+func (suite *UnitTestSuite) queryPlanResult(rows ...interface{}) *QueryResult {
+	return newQueryResult(&repositoryRowReader{
+		rows: rows,
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			Meta:  []byte(`{"requestID": "1"}`),
+			Suite: suite,
+		},
+	})
+}
+
+func (suite *UnitTestSuite) TestExplainQuery() {
+	reader := &repositoryRowReader{
+		rows: []interface{}{
+			map[string]interface{}{"plan": testCoveredQueryPlan, "text": "SELECT country FROM airline WHERE country = $1"},
+		},
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			Meta:  []byte(`{"requestID": "1"}`),
+			Suite: suite,
+		},
+	}
+
+	cluster := suite.queryCluster(false, reader, func(args mock.Arguments) {
+		opts := args.Get(1).(gocbcore.N1QLQueryOptions)
+
+		var payload map[string]interface{}
+		suite.Require().NoError(json.Unmarshal(opts.Payload, &payload))
+		suite.Assert().Equal("EXPLAIN SELECT country FROM `travel-sample`.inventory.airline WHERE country = $1",
+			payload["statement"])
+		suite.Assert().Equal([]interface{}{"United States"}, payload["args"])
+	})
+
+	plan, err := cluster.ExplainQuery("SELECT country FROM `travel-sample`.inventory.airline WHERE country = $1",
+		&ExplainQueryOptions{PositionalParameters: []interface{}{"United States"}})
+	suite.Require().NoError(err)
+	suite.Assert().Equal("SELECT country FROM airline WHERE country = $1", plan.Statement)
+	suite.Require().NotNil(plan.Root)
+	suite.Assert().Equal("Sequence", plan.Root.Operator)
+	suite.Require().Len(plan.Root.Children, 2)
+
+	scans := plan.Scans()
+	suite.Require().Len(scans, 1)
+	suite.Assert().Equal("def_inventory_airline_country", scans[0].Index)
+	suite.Assert().Equal("gsi", scans[0].Using)
+	suite.Assert().Equal("inventory", scans[0].Scope)
+	suite.Assert().Equal("airline", scans[0].Keyspace)
+	suite.Assert().True(scans[0].IsCovering())
+	suite.Assert().NotContains(scans[0].Properties, "~children")
+
+	suite.Assert().Equal([]string{"def_inventory_airline_country"}, plan.IndexesUsed())
+	suite.Assert().True(plan.IsCovered())
+	suite.Assert().False(plan.UsesPrimaryScan())
+	suite.Assert().Len(plan.Operators("Filter"), 1)
+
+	_, err = cluster.ExplainQuery("CREATE INDEX idx ON airline(country)", nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+	_, err = cluster.AdviseQuery("EXPLAIN SELECT 1", nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
+
+func (suite *UnitTestSuite) TestQueryPlanPrimaryScan() {
+	plan, err := parseQueryPlan(suite.queryPlanResult(map[string]interface{}{
+		"plan": map[string]interface{}{
+			"#operator": "Sequence",
+			"~children": []interface{}{
+				map[string]interface{}{
+					"#operator": "IntersectScan",
+					"scans": []interface{}{
+						map[string]interface{}{"#operator": "PrimaryScan3", "index": "#primary", "keyspace": "route"},
+						map[string]interface{}{"#operator": "IndexScan3", "index": "def_route_src", "keyspace": "route"},
+					},
+				},
+				map[string]interface{}{"#operator": "Fetch", "keyspace": "route"},
+			},
+		},
+		"text": "SELECT * FROM route",
+	}))
+	suite.Require().NoError(err)
+
+	suite.Assert().Equal([]string{"#primary", "def_route_src"}, plan.IndexesUsed())
+	suite.Assert().True(plan.UsesPrimaryScan())
+	suite.Assert().False(plan.IsCovered())
+	suite.Assert().Len(plan.Fetches(), 1)
+
+	_, err = parseQueryPlan(suite.queryPlanResult())
+	suite.Assert().ErrorIs(err, ErrNoResult)
+}
+
+func (suite *UnitTestSuite) TestAdviseQuery() {
+	advice, err := parseQueryAdvice(suite.queryPlanResult(map[string]interface{}{
+		"#operator": "Advise",
+		"advice": map[string]interface{}{
+			"#operator": "IndexAdvice",
+			"adviseinfo": map[string]interface{}{
+				"current_indexes": []interface{}{
+					map[string]interface{}{
+						"index_statement": "CREATE PRIMARY INDEX def_primary ON `travel-sample`",
+						"keyspace_alias":  "travel-sample",
+					},
+				},
+				"recommended_indexes": map[string]interface{}{
+					"indexes": []interface{}{
+						map[string]interface{}{
+							"index_statement":   "CREATE INDEX adv_type ON `travel-sample`(`type`)",
+							"keyspace_alias":    "travel-sample",
+							"recommending_rule": "Index keys follow order of predicate types: 2. equality/null/missing.",
+						},
+					},
+					"covering_indexes": []interface{}{
+						map[string]interface{}{
+							"index_statement": "CREATE INDEX adv_type_name ON `travel-sample`(`type`,`name`)",
+							"keyspace_alias":  "travel-sample",
+							"index_property":  "FULL GROUPBY",
+						},
+					},
+				},
+			},
+		},
+		"query": "SELECT name FROM `travel-sample` WHERE type = 'hotel'",
+	}))
+	suite.Require().NoError(err)
+	suite.Assert().Equal("SELECT name FROM `travel-sample` WHERE type = 'hotel'", advice.Statement)
+	suite.Require().Len(advice.CurrentIndexes, 1)
+	suite.Require().Len(advice.RecommendedIndexes, 1)
+	suite.Assert().Equal("CREATE INDEX adv_type ON `travel-sample`(`type`)", advice.RecommendedIndexes[0].Statement)
+	suite.Assert().NotEmpty(advice.RecommendedIndexes[0].RecommendingRule)
+	suite.Require().Len(advice.RecommendedCoveringIndexes, 1)
+	suite.Assert().Equal("FULL GROUPBY", advice.RecommendedCoveringIndexes[0].IndexProperty)
+	suite.Assert().Empty(advice.Message)
+
+	advice, err = parseQueryAdvice(suite.queryPlanResult(map[string]interface{}{
+		"#operator": "Advise",
+		"advice": map[string]interface{}{
+			"#operator": "IndexAdvice",
+			"adviseinfo": []interface{}{
+				map[string]interface{}{
+					"recommended_indexes": "No index recommendation at this time: no predicate.",
+				},
+			},
+		},
+		"query": "SELECT * FROM `travel-sample`",
+	}))
+	suite.Require().NoError(err)
+	suite.Assert().Empty(advice.RecommendedIndexes)
+	suite.Assert().Equal("No index recommendation at this time: no predicate.", advice.Message)
+}
+
+func (suite *UnitTestSuite) TestQueryPlanCapture() {
+	suite.Assert().Nil(newQueryPlanCapturer(QueryPlanCaptureConfig{}, nil))
+
+	// A nil capturer is disabled.
+	var disabled *queryPlanCapturer
+	suite.Assert().Nil(disabled.begin("SELECT 1", &QueryOptions{}, nil, nil, nil))
+
+	capturer := newQueryPlanCapturer(QueryPlanCaptureConfig{Enabled: true, IncludeAdvice: true},
+		NewThresholdLoggingTracer(&ThresholdLoggingOptions{QueryThreshold: 100 * time.Millisecond}))
+	suite.Require().NotNil(capturer)
+	suite.Assert().Equal(100*time.Millisecond, capturer.threshold)
+	suite.Assert().Equal(time.Second, newQueryPlanCapturer(QueryPlanCaptureConfig{Enabled: true}, &NoopTracer{}).threshold)
+
+	entries := make(chan *slowQueryPlanEntry, 10)
+	capturer.report = func(entry *slowQueryPlanEntry) {
+		entries <- entry
+	}
+
+	var explained []*ExplainQueryOptions
+	explain := func(statement string, opts *ExplainQueryOptions) (*QueryPlan, error) {
+		explained = append(explained, opts)
+		return parseQueryPlan(suite.queryPlanResult(map[string]interface{}{"plan": testCoveredQueryPlan}))
+	}
+	advise := func(statement string, opts *AdviseQueryOptions) (*QueryAdvice, error) {
+		return &QueryAdvice{
+			RecommendedIndexes: []QueryIndexAdvice{{Statement: "CREATE INDEX adv_country ON airline(country)"}},
+		}, nil
+	}
+
+	opts := &ExplainQueryOptions{PositionalParameters: []interface{}{"France"}}
+	statement := "SELECT country FROM airline WHERE country = $1"
+
+	suite.Assert().Nil(capturer.begin(statement, &QueryOptions{AsTransaction: &SingleQueryTransactionOptions{}},
+		nil, explain, advise))
+	capturer.maybeCapture(statement, 50*time.Millisecond, opts, nil, explain, advise)
+	capturer.maybeCapture("CREATE INDEX idx ON airline(country)", time.Second, opts, nil, explain, advise)
+	capturer.maybeCapture(statement, 200*time.Millisecond, opts, nil, explain, advise)
+
+	var entry *slowQueryPlanEntry
+	select {
+	case entry = <-entries:
+	case <-time.After(5 * time.Second):
+		suite.FailNow("slow query plan was not captured")
+	}
+	suite.Assert().Equal(statement, entry.Statement)
+	suite.Assert().EqualValues(200000, entry.DurationUs)
+	suite.Assert().EqualValues(100000, entry.ThresholdUs)
+	suite.Assert().Equal([]string{"def_inventory_airline_country"}, entry.IndexesUsed)
+	suite.Assert().True(entry.Covered)
+	suite.Assert().False(entry.PrimaryScan)
+	suite.Assert().NotEmpty(entry.Plan)
+	suite.Require().Len(entry.RecommendedIndexes, 1)
+	suite.Assert().Empty(entry.Error)
+	suite.Require().Len(explained, 1)
+	suite.Assert().Equal([]interface{}{"France"}, explained[0].PositionalParameters)
+
+	// The same statement is not captured again within the interval.
+	capturer.maybeCapture(statement, time.Second, opts, nil, explain, advise)
+
+	capturer.maybeCapture("SELECT * FROM route", time.Second, opts, nil,
+		func(string, *ExplainQueryOptions) (*QueryPlan, error) {
+			return nil, errors.New("explain failed")
+		}, advise)
+	select {
+	case entry = <-entries:
+	case <-time.After(5 * time.Second):
+		suite.FailNow("slow query plan was not captured")
+	}
+	suite.Assert().Equal("SELECT * FROM route", entry.Statement)
+	suite.Assert().Equal("explain failed", entry.Error)
+
+	select {
+	case entry = <-entries:
+		suite.Failf("unexpected capture", "captured %s", entry.Statement)
+	default:
+	}
+}
+
+func (suite *UnitTestSuite) TestQueryPlanCaptureOnFinish() {
+	capturer := newQueryPlanCapturer(QueryPlanCaptureConfig{Enabled: true, Threshold: 20 * time.Millisecond}, nil)
+	entries := make(chan *slowQueryPlanEntry, 10)
+	capturer.report = func(entry *slowQueryPlanEntry) {
+		entries <- entry
+	}
+	explain := func(statement string, opts *ExplainQueryOptions) (*QueryPlan, error) {
+		suite.Assert().Equal([]interface{}{"France"}, opts.PositionalParameters)
+		return parseQueryPlan(suite.queryPlanResult(map[string]interface{}{"plan": testCoveredQueryPlan}))
+	}
+	result := func(elapsed string) *QueryResult {
+		return newQueryResult(&repositoryRowReader{
+			rows: []interface{}{map[string]interface{}{"country": "France"}},
+			mockQueryRowReaderBase: mockQueryRowReaderBase{
+				Meta:  []byte(`{"requestID": "1", "metrics": {"elapsedTime": "` + elapsed + `"}}`),
+				Suite: suite,
+			},
+		})
+	}
+
+	opts := &QueryOptions{PositionalParameters: []interface{}{"France"}}
+	finished := capturer.begin("SELECT country FROM airline WHERE country = $1", opts, nil, explain, nil)
+	suite.Require().NotNil(finished)
+	// The options may be reused by the caller before the query finishes.
+	opts.PositionalParameters[0] = "Spain"
+
+	res := result("30ms")
+	res.finished = finished
+
+	// Nothing is captured until the result is read, as the elapsed time is only known then.
+	select {
+	case <-entries:
+		suite.FailNow("query was captured before its result was read")
+	default:
+	}
+
+	suite.Require().True(res.Next())
+	suite.Require().False(res.Next())
+	suite.Require().NoError(res.Close())
+
+	var entry *slowQueryPlanEntry
+	select {
+	case entry = <-entries:
+	case <-time.After(5 * time.Second):
+		suite.FailNow("slow query plan was not captured")
+	}
+	suite.Assert().EqualValues(30000, entry.DurationUs)
+
+	// The result only finishes once.
+	suite.Assert().Nil(res.finished)
+
+	// A fast query is not captured however long the application takes to process its rows.
+	res = result("5ms")
+	res.finished = capturer.begin("SELECT name FROM airline", &QueryOptions{}, nil, explain, nil)
+	suite.Require().True(res.Next())
+	time.Sleep(30 * time.Millisecond)
+	suite.Require().False(res.Next())
+
+	// Results read through Raw are captured once they are closed.
+	res = result("40ms")
+	res.finished = capturer.begin("SELECT country FROM airline WHERE country = $1 LIMIT 1",
+		&QueryOptions{PositionalParameters: []interface{}{"France"}}, nil, explain, nil)
+	raw := res.Raw()
+	suite.Assert().Nil(res.finished)
+	suite.Require().NotNil(raw.NextBytes())
+	suite.Require().NoError(raw.Close())
+
+	select {
+	case entry = <-entries:
+	case <-time.After(5 * time.Second):
+		suite.FailNow("slow query plan was not captured")
+	}
+	suite.Assert().Equal("SELECT country FROM airline WHERE country = $1 LIMIT 1", entry.Statement)
+	suite.Assert().EqualValues(40000, entry.DurationUs)
+
+	select {
+	case entry = <-entries:
+		suite.FailNow("fast query was captured: " + entry.Statement)
+	default:
+	}
+}
@@ -0,0 +1,230 @@
+package gocb
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// QueryPlanCaptureConfig specifies options for capturing the plans of slow queries, so that missing indexes can be
+// found from production traffic.
+// UNCOMMITTED: This API may change in the future.
+type QueryPlanCaptureConfig struct {
+	// Enabled explains each query which takes longer than Threshold, logging its plan at info level.
+	Enabled bool
+
+	// Threshold is the duration above which queries are captured, compared against the elapsed time reported by the
+	// server once the result has been read. It defaults to the QueryThreshold of the ThresholdLoggingTracer, or one
+	// second if a different tracer is used.
+	Threshold time.Duration
+
+	// Interval is the minimum time between captures of the same statement, which defaults to one minute.
+	Interval time.Duration
+
+	// IncludeAdvice also asks the index advisor for the indexes which would help each captured statement.
+	IncludeAdvice bool
+
+	// Timeout is the timeout of each EXPLAIN and ADVISE, which defaults to the query timeout.
+	Timeout time.Duration
+}
+
+const (
+	// queryPlanCaptureMaxInFlight is the maximum number of captures running at once, further slow queries are not
+	// captured until one completes.
+	queryPlanCaptureMaxInFlight = 4
+	// queryPlanCaptureMaxStatements bounds the number of statements whose last capture is remembered.
+	queryPlanCaptureMaxStatements = 1024
+)
+
+type slowQueryPlanEntry struct {
+	Statement          string             `json:"statement"`
+	DurationUs         uint64             `json:"duration_us"`
+	ThresholdUs        uint64             `json:"threshold_us"`
+	BucketName         string             `json:"bucket,omitempty"`
+	ScopeName          string             `json:"scope,omitempty"`
+	IndexesUsed        []string           `json:"indexes_used,omitempty"`
+	PrimaryScan        bool               `json:"primary_scan"`
+	Covered            bool               `json:"covered"`
+	Plan               json.RawMessage    `json:"plan,omitempty"`
+	RecommendedIndexes []QueryIndexAdvice `json:"recommended_indexes,omitempty"`
+	Error              string             `json:"error,omitempty"`
+}
+
+type queryPlanExplainFn func(statement string, opts *ExplainQueryOptions) (*QueryPlan, error)
+
+type queryPlanAdviseFn func(statement string, opts *AdviseQueryOptions) (*QueryAdvice, error)
+
+// queryPlanCapturer captures the plans of slow queries in the background.
+type queryPlanCapturer struct {
+	threshold     time.Duration
+	interval      time.Duration
+	includeAdvice bool
+	timeout       time.Duration
+
+	lock         sync.Mutex
+	lastCaptured map[string]time.Time
+	inFlight     chan struct{}
+
+	report func(entry *slowQueryPlanEntry)
+}
+
+func newQueryPlanCapturer(config QueryPlanCaptureConfig, tracer RequestTracer) *queryPlanCapturer {
+	if !config.Enabled {
+		return nil
+	}
+
+	threshold := config.Threshold
+	if threshold == 0 {
+		threshold = time.Second
+		if thresholdTracer, ok := tracer.(*ThresholdLoggingTracer); ok {
+			threshold = thresholdTracer.QueryThreshold
+		}
+	}
+	interval := config.Interval
+	if interval == 0 {
+		interval = time.Minute
+	}
+
+	return &queryPlanCapturer{
+		threshold:     threshold,
+		interval:      interval,
+		includeAdvice: config.IncludeAdvice,
+		timeout:       config.Timeout,
+		lastCaptured:  make(map[string]time.Time),
+		inFlight:      make(chan struct{}, queryPlanCaptureMaxInFlight),
+		report:        logSlowQueryPlan,
+	}
+}
+
+func logSlowQueryPlan(entry *slowQueryPlanEntry) {
+	jsonBytes, err := json.Marshal(entry)
+	if err != nil {
+		logDebugf("Failed to generate slow query plan JSON: %s", err)
+		return
+	}
+
+	logInfof("Slow Query Plan: %s", jsonBytes)
+}
+
+// shouldCapture returns whether a query which took elapsed should be captured, recording the capture if so.
+func (qpc *queryPlanCapturer) shouldCapture(statement string, elapsed time.Duration) bool {
+	if elapsed < qpc.threshold || !isExplainableStatement(statement) {
+		return false
+	}
+
+	qpc.lock.Lock()
+	defer qpc.lock.Unlock()
+
+	now := time.Now()
+	if last, ok := qpc.lastCaptured[statement]; ok && now.Sub(last) < qpc.interval {
+		return false
+	}
+
+	if len(qpc.lastCaptured) >= queryPlanCaptureMaxStatements {
+		for capturedStatement, last := range qpc.lastCaptured {
+			if now.Sub(last) >= qpc.interval {
+				delete(qpc.lastCaptured, capturedStatement)
+			}
+		}
+		if len(qpc.lastCaptured) >= queryPlanCaptureMaxStatements {
+			return false
+		}
+	}
+
+	qpc.lastCaptured[statement] = now
+	return true
+}
+
+// begin returns a function to call with the elapsed time of the query once it has finished, which explains the
+// statement in the background if the query took longer than the threshold. An elapsed time of 0 means that the time
+// since begin was called is used instead. The capturer can be nil, in which case capturing is disabled and nil is
+// returned.
+func (qpc *queryPlanCapturer) begin(statement string, opts *QueryOptions, scope *Scope, explain queryPlanExplainFn,
+	advise queryPlanAdviseFn) func(elapsed time.Duration) {
+	if qpc == nil || opts.AsTransaction != nil {
+		return nil
+	}
+
+	start := time.Now()
+
+	// The options of the query are copied as they may be reused by the caller before the query finishes, and its
+	// context and parent span are not used as they may have ended by the time the capture runs.
+	explainOpts := &ExplainQueryOptions{
+		PositionalParameters: append([]interface{}(nil), opts.PositionalParameters...),
+		NamedParameters:      make(map[string]interface{}, len(opts.NamedParameters)),
+		Timeout:              qpc.timeout,
+	}
+	for name, value := range opts.NamedParameters {
+		explainOpts.NamedParameters[name] = value
+	}
+
+	return func(elapsed time.Duration) {
+		if elapsed == 0 {
+			elapsed = time.Since(start)
+		}
+		qpc.maybeCapture(statement, elapsed, explainOpts, scope, explain, advise)
+	}
+}
+
+func (qpc *queryPlanCapturer) maybeCapture(statement string, elapsed time.Duration, opts *ExplainQueryOptions,
+	scope *Scope, explain queryPlanExplainFn, advise queryPlanAdviseFn) {
+	if !qpc.shouldCapture(statement, elapsed) {
+		return
+	}
+
+	select {
+	case qpc.inFlight <- struct{}{}:
+	default:
+		logDebugf("Skipping capture of slow query plan as too many captures are in progress")
+		return
+	}
+
+	entry := &slowQueryPlanEntry{
+		Statement:   statement,
+		DurationUs:  uint64(elapsed / time.Microsecond),
+		ThresholdUs: uint64(qpc.threshold / time.Microsecond),
+	}
+	if scope != nil {
+		entry.BucketName = scope.BucketName()
+		entry.ScopeName = scope.Name()
+	}
+
+	go func() {
+		defer func() {
+			<-qpc.inFlight
+		}()
+
+		qpc.capture(entry, opts, explain, advise)
+		qpc.report(entry)
+	}()
+}
+
+func (qpc *queryPlanCapturer) capture(entry *slowQueryPlanEntry, opts *ExplainQueryOptions,
+	explain queryPlanExplainFn, advise queryPlanAdviseFn) {
+	plan, err := explain(entry.Statement, opts)
+	if err != nil {
+		entry.Error = err.Error()
+		return
+	}
+
+	entry.IndexesUsed = plan.IndexesUsed()
+	entry.PrimaryScan = plan.UsesPrimaryScan()
+	entry.Covered = plan.IsCovered()
+	entry.Plan = plan.Raw
+
+	if !qpc.includeAdvice {
+		return
+	}
+
+	advice, err := advise(entry.Statement, &AdviseQueryOptions{
+		PositionalParameters: opts.PositionalParameters,
+		NamedParameters:      opts.NamedParameters,
+		Timeout:              opts.Timeout,
+	})
+	if err != nil {
+		entry.Error = err.Error()
+		return
+	}
+
+	entry.RecommendedIndexes = append(advice.RecommendedIndexes, advice.RecommendedCoveringIndexes...)
+}
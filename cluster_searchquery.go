@@ -14,7 +14,13 @@ func (c *Cluster) SearchQuery(indexName string, query cbsearch.Query, opts *Sear
 			opts = &SearchOptions{}
 		}
 
-		return provider.SearchQuery(indexName, query, opts)
+		res, err := provider.SearchQuery(indexName, query, opts)
+		if err != nil {
+			return nil, err
+		}
+		res.ctx = opts.Context
+
+		return res, nil
 	})
 }
 
@@ -29,7 +35,13 @@ func (c *Cluster) Search(indexName string, request SearchRequest, opts *SearchOp
 			opts = &SearchOptions{}
 		}
 
-		return provider.Search(nil, indexName, request, opts)
+		res, err := provider.Search(nil, indexName, request, opts)
+		if err != nil {
+			return nil, err
+		}
+		res.ctx = opts.Context
+
+		return res, nil
 	})
 }
 
@@ -0,0 +1,225 @@
+// Command gocbtransfer exports the documents of a collection to a file, or imports them from one, using
+// Collection.Export and Collection.Import.
+//
+// Usage:
+//
+//	gocbtransfer export -bucket travel-sample -collection airline -file airlines.jsonl
+//	gocbtransfer import -bucket staging -collection airline -file airlines.jsonl -mode insert
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+type commonFlags struct {
+	connStr    string
+	username   string
+	password   string
+	bucket     string
+	scope      string
+	collection string
+	file       string
+	format     string
+	timeout    time.Duration
+}
+
+func (f *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.connStr, "connstr", "couchbase://localhost", "connection string of the cluster")
+	fs.StringVar(&f.username, "username", "Administrator", "username to connect with")
+	fs.StringVar(&f.password, "password", "password", "password to connect with")
+	fs.StringVar(&f.bucket, "bucket", "", "name of the bucket")
+	fs.StringVar(&f.scope, "scope", "_default", "name of the scope")
+	fs.StringVar(&f.collection, "collection", "_default", "name of the collection")
+	fs.StringVar(&f.file, "file", "-", "file to read or write, - for stdin or stdout")
+	fs.StringVar(&f.format, "format", "jsonl", "format of the file, jsonl or csv")
+	fs.DurationVar(&f.timeout, "timeout", 10*time.Second, "timeout for connecting and for each operation")
+}
+
+func (f *commonFlags) transferFormat() (gocb.TransferFormat, error) {
+	switch f.format {
+	case "jsonl":
+		return gocb.TransferFormatJSONLines, nil
+	case "csv":
+		return gocb.TransferFormatCSV, nil
+	default:
+		return 0, fmt.Errorf("unknown format %q", f.format)
+	}
+}
+
+func (f *commonFlags) connect() (*gocb.Cluster, *gocb.Collection, error) {
+	if f.bucket == "" {
+		return nil, nil, fmt.Errorf("a bucket must be specified")
+	}
+
+	cluster, err := gocb.Connect(f.connStr, gocb.ClusterOptions{
+		Authenticator: gocb.PasswordAuthenticator{
+			Username: f.username,
+			Password: f.password,
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bucket := cluster.Bucket(f.bucket)
+	if err := bucket.WaitUntilReady(f.timeout, nil); err != nil {
+		_ = cluster.Close(nil)
+		return nil, nil, err
+	}
+
+	return cluster, bucket.Scope(f.scope).Collection(f.collection), nil
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	var common commonFlags
+	common.register(fs)
+	concurrency := fs.Uint("concurrency", 4, "number of vbuckets to scan at the same time")
+	prefix := fs.String("prefix", "", "only export documents whose IDs start with this prefix")
+	_ = fs.Parse(args)
+
+	format, err := common.transferFormat()
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if common.file != "-" {
+		f, err := os.Create(common.file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	cluster, collection, err := common.connect()
+	if err != nil {
+		return err
+	}
+	defer cluster.Close(nil)
+
+	opts := &gocb.ExportOptions{
+		Format:      format,
+		Concurrency: uint16(*concurrency),
+		Timeout:     common.timeout,
+	}
+	if *prefix != "" {
+		opts.ScanType = gocb.NewRangeScanForPrefix(*prefix)
+	}
+
+	start := time.Now()
+	res, err := collection.Export(out, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d documents (%d bytes) in %s\n", res.DocumentsExported, res.BytesWritten,
+		time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var common commonFlags
+	common.register(fs)
+	mode := fs.String("mode", "upsert", "how to write documents, upsert or insert")
+	documents := fs.Bool("documents", false, "each record is a document rather than the output of export")
+	key := fs.String("key", "", "template for the IDs of documents, required with -documents")
+	batchSize := fs.Int("batch", 128, "number of documents to write at once")
+	dryRun := fs.Bool("dry-run", false, "validate the records without writing any documents")
+	rate := fs.Float64("rate", 0, "maximum documents written per second, 0 for no limit")
+	maxErrors := fs.Int("max-errors", 0, "abort once more than this many records fail, 0 for no limit")
+	_ = fs.Parse(args)
+
+	format, err := common.transferFormat()
+	if err != nil {
+		return err
+	}
+
+	importMode := gocb.ImportModeUpsert
+	switch *mode {
+	case "upsert":
+	case "insert":
+		importMode = gocb.ImportModeInsert
+	default:
+		return fmt.Errorf("unknown mode %q", *mode)
+	}
+
+	in := io.Reader(os.Stdin)
+	if common.file != "-" {
+		f, err := os.Open(common.file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	cluster, collection, err := common.connect()
+	if err != nil {
+		return err
+	}
+	defer cluster.Close(nil)
+
+	start := time.Now()
+	res, err := collection.Import(in, &gocb.ImportOptions{
+		Format:      format,
+		Mode:        importMode,
+		Documents:   *documents,
+		KeyTemplate: *key,
+		BatchSize:   *batchSize,
+		DryRun:      *dryRun,
+		RateLimit:   gocb.ServiceRateLimit{OpsPerSecond: *rate},
+		MaxErrors:   *maxErrors,
+		Timeout:     common.timeout,
+	})
+	if res != nil {
+		for _, importErr := range res.Errors {
+			fmt.Fprintln(os.Stderr, importErr)
+		}
+		verb := "imported"
+		if *dryRun {
+			verb = "would import"
+		}
+		fmt.Fprintf(os.Stderr, "read %d records, %s %d documents, skipped %d expired, %d failed in %s\n",
+			res.RecordsRead, verb, res.DocumentsImported, res.DocumentsSkipped, len(res.Errors),
+			time.Since(start).Round(time.Millisecond))
+	}
+	if err != nil {
+		return err
+	}
+	if len(res.Errors) > 0 {
+		return fmt.Errorf("%d records failed", len(res.Errors))
+	}
+
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: gocbtransfer export|import [flags]")
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected export or import\n", os.Args[1])
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -8,12 +8,20 @@ import (
 
 type bulkOp struct {
 	finishFn func()
+
+	// rateLimitRelease releases the rate limit held by the op once it has completed, it is nil if the op was not
+	// rate limited.
+	rateLimitRelease func(error)
 }
 
 func (op *bulkOp) finish() {
 	op.finishFn()
 }
 
+func (op *bulkOp) base() *bulkOp {
+	return op
+}
+
 // BulkOp represents a single operation that can be submitted (within a list of more operations) to .Do()
 // You can create a bulk operation by instantiating one of the implementations of BulkOp,
 // such as GetOp, UpsertOp, ReplaceOp, and more.
@@ -21,6 +29,7 @@ func (op *bulkOp) finish() {
 type BulkOp interface {
 	isBulkOp()
 	finish()
+	base() *bulkOp
 }
 
 // BulkOpOptions are the set of options available when performing BulkOps using Do.
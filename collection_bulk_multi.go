@@ -0,0 +1,444 @@
+package gocb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMultiBatchSize   = 128
+	defaultMultiConcurrency = 4
+)
+
+// BulkMultiError is returned from the Err function of multi-key results when one or more keys failed. It
+// summarises the failures so that large batches can be reported without listing every key.
+// UNCOMMITTED: This API may change in the future.
+type BulkMultiError struct {
+	// Errors contains the error for each key that failed.
+	Errors map[string]error
+
+	// Total is the total number of keys that were operated on.
+	Total int
+}
+
+// Summary returns the number of keys which failed for each distinct error message.
+func (e *BulkMultiError) Summary() map[string]int {
+	summary := make(map[string]int)
+	for _, err := range e.Errors {
+		summary[err.Error()]++
+	}
+
+	return summary
+}
+
+// Error returns the string representation of this error.
+func (e *BulkMultiError) Error() string {
+	summary := e.Summary()
+	reasons := make([]string, 0, len(summary))
+	for reason, count := range summary {
+		reasons = append(reasons, fmt.Sprintf("%dx %s", count, reason))
+	}
+	sort.Strings(reasons)
+
+	return fmt.Sprintf("%d of %d operations failed: %s", len(e.Errors), e.Total, strings.Join(reasons, ", "))
+}
+
+// Is reports whether every key failed with an error matching target. This allows, for example, checking whether
+// all failures were ErrDocumentNotFound.
+func (e *BulkMultiError) Is(target error) bool {
+	if len(e.Errors) == 0 {
+		return false
+	}
+
+	for _, err := range e.Errors {
+		if !errors.Is(err, target) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MultiOptions are the options which control how multi-key operations are batched and dispatched.
+// UNCOMMITTED: This API may change in the future.
+type MultiOptions struct {
+	// BatchSize is the maximum number of operations dispatched together in a single bulk call.
+	// Defaults to 128.
+	BatchSize uint
+
+	// Concurrency is the maximum number of batches in flight at any one time.
+	// Defaults to 4.
+	Concurrency uint
+
+	// Timeout is applied to each batch, rather than to the operation as a whole.
+	Timeout       time.Duration
+	Transcoder    Transcoder
+	RetryStrategy RetryStrategy
+	ParentSpan    RequestSpan
+
+	// Context can be used to cancel the operation. Once canceled no further batches will be dispatched and any
+	// keys which have not been processed will fail with ErrRequestCanceled or ErrTimeout.
+	Context context.Context
+}
+
+func (opts *MultiOptions) batchSize() int {
+	if opts.BatchSize == 0 {
+		return defaultMultiBatchSize
+	}
+
+	return int(opts.BatchSize)
+}
+
+func (opts *MultiOptions) concurrency() int {
+	if opts.Concurrency == 0 {
+		return defaultMultiConcurrency
+	}
+
+	return int(opts.Concurrency)
+}
+
+func (opts *MultiOptions) bulkOpOptions() *BulkOpOptions {
+	return &BulkOpOptions{
+		Timeout:       opts.Timeout,
+		Transcoder:    opts.Transcoder,
+		RetryStrategy: opts.RetryStrategy,
+		ParentSpan:    opts.ParentSpan,
+		Context:       opts.Context,
+	}
+}
+
+func multiContextErr(ctx context.Context) error {
+	if ctx == nil || ctx.Err() == nil {
+		return nil
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+
+	return ErrRequestCanceled
+}
+
+// runMulti splits count operations into batches and dispatches them through Do with bounded concurrency. For each
+// batch makeOps is called to create the operations and handleOps is called once they have completed, or with the
+// error that prevented the batch from being dispatched. handleOps may be called concurrently.
+func (c *Collection) runMulti(count int, opts *MultiOptions, stopCh <-chan struct{}, makeOps func(start, end int) []BulkOp,
+	handleOps func(ops []BulkOp, err error)) {
+	batchSize := opts.batchSize()
+	bulkOpts := opts.bulkOpOptions()
+
+	sem := make(chan struct{}, opts.concurrency())
+	var wg sync.WaitGroup
+	for start := 0; start < count; start += batchSize {
+		end := start + batchSize
+		if end > count {
+			end = count
+		}
+
+		var stopped bool
+		select {
+		case sem <- struct{}{}:
+			// Both cases may be ready once a batch completes, so check that we haven't been stopped in the meantime.
+			select {
+			case <-stopCh:
+				<-sem
+				stopped = true
+			default:
+			}
+		case <-stopCh:
+			stopped = true
+		}
+
+		ops := makeOps(start, end)
+		if stopped {
+			handleOps(ops, ErrRequestCanceled)
+			continue
+		}
+		if err := multiContextErr(opts.Context); err != nil {
+			<-sem
+			handleOps(ops, err)
+			continue
+		}
+
+		wg.Add(1)
+		go func(ops []BulkOp) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			err := c.Do(ops, bulkOpts)
+			handleOps(ops, err)
+		}(ops)
+	}
+
+	wg.Wait()
+}
+
+// GetMultiResult is the return type of GetMulti operations.
+// UNCOMMITTED: This API may change in the future.
+type GetMultiResult struct {
+	// Results contains the result for each key which was successfully fetched.
+	Results map[string]*GetResult
+
+	// Errors contains the error for each key which could not be fetched.
+	Errors map[string]error
+}
+
+// Err returns a BulkMultiError summarising any keys which failed, or nil if every key succeeded.
+func (r *GetMultiResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+
+	return &BulkMultiError{
+		Errors: r.Errors,
+		Total:  len(r.Results) + len(r.Errors),
+	}
+}
+
+func makeGetOps(ids []string) []BulkOp {
+	ops := make([]BulkOp, len(ids))
+	for i, id := range ids {
+		ops[i] = &GetOp{ID: id}
+	}
+
+	return ops
+}
+
+// GetMulti fetches a set of documents, dispatching them in batches with bounded concurrency. Per key results and
+// errors are available from the returned GetMultiResult. An error is only returned when the request itself is
+// invalid, use GetMultiResult.Err to check whether any keys failed.
+// UNCOMMITTED: This API may change in the future.
+func (c *Collection) GetMulti(ids []string, opts *MultiOptions) (*GetMultiResult, error) {
+	if opts == nil {
+		opts = &MultiOptions{}
+	}
+	for _, id := range ids {
+		if id == "" {
+			return nil, makeInvalidArgumentsError("document ids cannot be empty")
+		}
+	}
+
+	res := &GetMultiResult{
+		Results: make(map[string]*GetResult, len(ids)),
+		Errors:  make(map[string]error),
+	}
+
+	var lock sync.Mutex
+	c.runMulti(len(ids), opts, nil, func(start, end int) []BulkOp {
+		return makeGetOps(ids[start:end])
+	}, func(ops []BulkOp, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		for _, op := range ops {
+			getOp := op.(*GetOp)
+			opErr := getOp.Err
+			if err != nil {
+				opErr = err
+			}
+
+			if opErr != nil {
+				res.Errors[getOp.ID] = opErr
+				continue
+			}
+
+			res.Results[getOp.ID] = getOp.Result
+		}
+	})
+
+	return res, nil
+}
+
+// GetMultiItem represents the outcome of fetching a single key as part of a GetMultiStream operation.
+// UNCOMMITTED: This API may change in the future.
+type GetMultiItem struct {
+	ID     string
+	Result *GetResult
+	Err    error
+}
+
+// GetMultiStreamResult is the return type of GetMultiStream operations.
+// UNCOMMITTED: This API may change in the future.
+type GetMultiStreamResult struct {
+	itemCh   chan *GetMultiItem
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// Next returns the next completed item, if there are no items remaining then nil is returned.
+func (r *GetMultiStreamResult) Next() *GetMultiItem {
+	item, ok := <-r.itemCh
+	if !ok {
+		return nil
+	}
+
+	return item
+}
+
+// Close stops any further batches from being dispatched. Batches which are already in flight are allowed to
+// complete but their items are discarded.
+func (r *GetMultiStreamResult) Close() error {
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+	})
+
+	// Drain so that in flight batches are not blocked on delivery.
+	for range r.itemCh {
+	}
+
+	return nil
+}
+
+// GetMultiStream fetches a set of documents in the same way as GetMulti but delivers each item as soon as its batch
+// completes rather than holding every result in memory. Items are delivered in batch completion order. Close must be
+// called if the stream is not read to completion. An empty ID is not fetched, instead an item for it is delivered
+// before any others with an ErrInvalidArgument error.
+// UNCOMMITTED: This API may change in the future.
+func (c *Collection) GetMultiStream(ids []string, opts *MultiOptions) *GetMultiStreamResult {
+	if opts == nil {
+		opts = &MultiOptions{}
+	}
+
+	res := &GetMultiStreamResult{
+		itemCh: make(chan *GetMultiItem, opts.batchSize()),
+		stopCh: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(res.itemCh)
+
+		validIDs := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if id != "" {
+				validIDs = append(validIDs, id)
+				continue
+			}
+
+			select {
+			case res.itemCh <- &GetMultiItem{Err: makeInvalidArgumentsError("document ids cannot be empty")}:
+			case <-res.stopCh:
+				return
+			}
+		}
+
+		c.runMulti(len(validIDs), opts, res.stopCh, func(start, end int) []BulkOp {
+			return makeGetOps(validIDs[start:end])
+		}, func(ops []BulkOp, err error) {
+			for _, op := range ops {
+				getOp := op.(*GetOp)
+				item := &GetMultiItem{
+					ID:     getOp.ID,
+					Result: getOp.Result,
+					Err:    getOp.Err,
+				}
+				if err != nil {
+					item.Result = nil
+					item.Err = err
+				}
+
+				select {
+				case res.itemCh <- item:
+				case <-res.stopCh:
+					return
+				}
+			}
+		})
+	}()
+
+	return res
+}
+
+// UpsertMultiOptions are the options available to UpsertMulti operations.
+// UNCOMMITTED: This API may change in the future.
+type UpsertMultiOptions struct {
+	MultiOptions
+
+	Expiry time.Duration
+}
+
+// MutateMultiResult is the return type of multi-key mutation operations.
+// UNCOMMITTED: This API may change in the future.
+type MutateMultiResult struct {
+	// Results contains the result for each key which was successfully mutated.
+	Results map[string]*MutationResult
+
+	// Errors contains the error for each key which could not be mutated.
+	Errors map[string]error
+}
+
+// Err returns a BulkMultiError summarising any keys which failed, or nil if every key succeeded.
+func (r *MutateMultiResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+
+	return &BulkMultiError{
+		Errors: r.Errors,
+		Total:  len(r.Results) + len(r.Errors),
+	}
+}
+
+// UpsertMulti upserts a set of documents, keyed by ID, dispatching them in batches with bounded concurrency. Per key
+// results and errors are available from the returned MutateMultiResult. An error is only returned when the request
+// itself is invalid, use MutateMultiResult.Err to check whether any keys failed.
+// UNCOMMITTED: This API may change in the future.
+func (c *Collection) UpsertMulti(docs map[string]interface{}, opts *UpsertMultiOptions) (*MutateMultiResult, error) {
+	if opts == nil {
+		opts = &UpsertMultiOptions{}
+	}
+
+	ids := make([]string, 0, len(docs))
+	for id := range docs {
+		if id == "" {
+			return nil, makeInvalidArgumentsError("document ids cannot be empty")
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	res := &MutateMultiResult{
+		Results: make(map[string]*MutationResult, len(ids)),
+		Errors:  make(map[string]error),
+	}
+
+	var lock sync.Mutex
+	c.runMulti(len(ids), &opts.MultiOptions, nil, func(start, end int) []BulkOp {
+		ops := make([]BulkOp, 0, end-start)
+		for _, id := range ids[start:end] {
+			ops = append(ops, &UpsertOp{
+				ID:     id,
+				Value:  docs[id],
+				Expiry: opts.Expiry,
+			})
+		}
+
+		return ops
+	}, func(ops []BulkOp, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		for _, op := range ops {
+			upsertOp := op.(*UpsertOp)
+			opErr := upsertOp.Err
+			if err != nil {
+				opErr = err
+			}
+
+			if opErr != nil {
+				res.Errors[upsertOp.ID] = opErr
+				continue
+			}
+
+			res.Results[upsertOp.ID] = upsertOp.Result
+		}
+	})
+
+	return res, nil
+}
@@ -0,0 +1,239 @@
+package gocb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// testMultiBulkProvider implements kvBulkProvider, completing get and upsert ops in memory.
+type testMultiBulkProvider struct {
+	lock     sync.Mutex
+	docs     map[string]interface{}
+	calls    int32
+	inFlight int32
+	maxSeen  int32
+	opCounts []int
+	block    chan struct{}
+}
+
+func (p *testMultiBulkProvider) Do(c *Collection, ops []BulkOp, opts *BulkOpOptions) error {
+	atomic.AddInt32(&p.calls, 1)
+	inFlight := atomic.AddInt32(&p.inFlight, 1)
+	defer atomic.AddInt32(&p.inFlight, -1)
+	for {
+		seen := atomic.LoadInt32(&p.maxSeen)
+		if inFlight <= seen || atomic.CompareAndSwapInt32(&p.maxSeen, seen, inFlight) {
+			break
+		}
+	}
+
+	if p.block != nil {
+		<-p.block
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.opCounts = append(p.opCounts, len(ops))
+
+	for _, op := range ops {
+		switch typedOp := op.(type) {
+		case *GetOp:
+			doc, ok := p.docs[typedOp.ID]
+			if !ok {
+				typedOp.Err = ErrDocumentNotFound
+				continue
+			}
+			typedOp.Result = &GetResult{
+				transcoder: NewJSONTranscoder(),
+				contents:   []byte(fmt.Sprintf("%q", doc)),
+			}
+		case *UpsertOp:
+			if strings.HasPrefix(typedOp.ID, "locked") {
+				typedOp.Err = ErrDocumentLocked
+				continue
+			}
+			p.docs[typedOp.ID] = typedOp.Value
+			typedOp.Result = &MutationResult{Result: Result{cas: 1}}
+		}
+	}
+
+	return nil
+}
+
+func (suite *UnitTestSuite) multiCollection(provider kvBulkProvider) *Collection {
+	col := suite.collection("mock", "", "", nil)
+	col.getKvBulkProvider = func() (kvBulkProvider, error) {
+		return provider, nil
+	}
+
+	return col
+}
+
+func (suite *UnitTestSuite) TestGetMulti() {
+	provider := &testMultiBulkProvider{docs: make(map[string]interface{})}
+	var ids []string
+	for i := 0; i < 25; i++ {
+		id := fmt.Sprintf("key%d", i)
+		ids = append(ids, id)
+		if i%5 != 0 {
+			provider.docs[id] = id
+		}
+	}
+
+	col := suite.multiCollection(provider)
+	res, err := col.GetMulti(ids, &MultiOptions{BatchSize: 10, Concurrency: 2})
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Len(res.Results, 20)
+	suite.Assert().Len(res.Errors, 5)
+	suite.Assert().Equal(int32(3), provider.calls)
+	suite.Assert().ElementsMatch([]int{10, 10, 5}, provider.opCounts)
+	suite.Assert().LessOrEqual(provider.maxSeen, int32(2))
+
+	val, err := ContentAs[string](res.Results["key1"])
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal("key1", val)
+
+	suite.Assert().ErrorIs(res.Errors["key0"], ErrDocumentNotFound)
+
+	aggErr := res.Err()
+	suite.Require().NotNil(aggErr)
+	suite.Assert().ErrorIs(aggErr, ErrDocumentNotFound)
+
+	var multiErr *BulkMultiError
+	suite.Require().ErrorAs(aggErr, &multiErr)
+	suite.Assert().Equal(25, multiErr.Total)
+	suite.Assert().Equal(map[string]int{ErrDocumentNotFound.Error(): 5}, multiErr.Summary())
+	suite.Assert().Contains(multiErr.Error(), "5 of 25 operations failed")
+}
+
+func (suite *UnitTestSuite) TestGetMultiAllSucceed() {
+	provider := &testMultiBulkProvider{docs: map[string]interface{}{"a": "a", "b": "b"}}
+
+	res, err := suite.multiCollection(provider).GetMulti([]string{"a", "b"}, nil)
+	suite.Require().Nil(err, err)
+	suite.Assert().Len(res.Results, 2)
+	suite.Assert().Nil(res.Err())
+}
+
+func (suite *UnitTestSuite) TestGetMultiInvalidID() {
+	provider := &testMultiBulkProvider{docs: map[string]interface{}{}}
+
+	_, err := suite.multiCollection(provider).GetMulti([]string{"a", ""}, nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+	suite.Assert().Equal(int32(0), provider.calls)
+}
+
+func (suite *UnitTestSuite) TestGetMultiContextCanceled() {
+	provider := &testMultiBulkProvider{docs: map[string]interface{}{"a": "a"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res, err := suite.multiCollection(provider).GetMulti([]string{"a", "b"}, &MultiOptions{Context: ctx})
+	suite.Require().Nil(err, err)
+	suite.Assert().Len(res.Errors, 2)
+	suite.Assert().ErrorIs(res.Err(), ErrRequestCanceled)
+	suite.Assert().Equal(int32(0), provider.calls)
+}
+
+func (suite *UnitTestSuite) TestGetMultiStream() {
+	provider := &testMultiBulkProvider{docs: make(map[string]interface{})}
+	var ids []string
+	for i := 0; i < 50; i++ {
+		id := fmt.Sprintf("key%d", i)
+		ids = append(ids, id)
+		provider.docs[id] = id
+	}
+
+	stream := suite.multiCollection(provider).GetMultiStream(ids, &MultiOptions{BatchSize: 7, Concurrency: 3})
+
+	seen := make(map[string]bool)
+	for item := stream.Next(); item != nil; item = stream.Next() {
+		suite.Require().Nil(item.Err, item.Err)
+		seen[item.ID] = true
+	}
+
+	suite.Assert().Len(seen, 50)
+	suite.Assert().Nil(stream.Close())
+}
+
+func (suite *UnitTestSuite) TestGetMultiStreamEmptyIDs() {
+	provider := &testMultiBulkProvider{docs: map[string]interface{}{"a": "a", "b": "b"}}
+
+	stream := suite.multiCollection(provider).GetMultiStream([]string{"a", "", "b", ""}, &MultiOptions{BatchSize: 10})
+
+	var invalid int
+	seen := make(map[string]bool)
+	for item := stream.Next(); item != nil; item = stream.Next() {
+		if item.ID == "" {
+			suite.Assert().ErrorIs(item.Err, ErrInvalidArgument)
+			suite.Assert().Nil(item.Result)
+			invalid++
+			continue
+		}
+		suite.Require().Nil(item.Err, item.Err)
+		seen[item.ID] = true
+	}
+
+	suite.Assert().Equal(2, invalid)
+	suite.Assert().Equal(map[string]bool{"a": true, "b": true}, seen)
+	suite.Assert().Nil(stream.Close())
+
+	// Empty IDs are never sent.
+	suite.Assert().Equal([]int{2}, provider.opCounts)
+}
+
+func (suite *UnitTestSuite) TestGetMultiStreamClose() {
+	provider := &testMultiBulkProvider{
+		docs:  make(map[string]interface{}),
+		block: make(chan struct{}),
+	}
+	var ids []string
+	for i := 0; i < 100; i++ {
+		ids = append(ids, fmt.Sprintf("key%d", i))
+	}
+
+	stream := suite.multiCollection(provider).GetMultiStream(ids, &MultiOptions{BatchSize: 10, Concurrency: 1})
+	suite.Require().Eventually(func() bool {
+		return atomic.LoadInt32(&provider.calls) == 1
+	}, time.Second, time.Millisecond)
+
+	closeCh := make(chan error)
+	go func() {
+		closeCh <- stream.Close()
+	}()
+	// Only release the in flight batch once the stream has been stopped.
+	<-stream.stopCh
+	close(provider.block)
+
+	suite.Require().Nil(<-closeCh)
+	suite.Assert().Equal(int32(1), atomic.LoadInt32(&provider.calls))
+}
+
+func (suite *UnitTestSuite) TestUpsertMulti() {
+	provider := &testMultiBulkProvider{docs: make(map[string]interface{})}
+
+	docs := map[string]interface{}{
+		"a":       "a",
+		"b":       "b",
+		"c":       "c",
+		"locked1": "d",
+	}
+
+	res, err := suite.multiCollection(provider).UpsertMulti(docs, &UpsertMultiOptions{
+		MultiOptions: MultiOptions{BatchSize: 2},
+	})
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Len(res.Results, 3)
+	suite.Assert().Len(res.Errors, 1)
+	suite.Assert().ErrorIs(res.Errors["locked1"], ErrDocumentLocked)
+	suite.Assert().ErrorIs(res.Err(), ErrDocumentLocked)
+	suite.Assert().Equal(int32(2), provider.calls)
+	suite.Assert().Equal("b", provider.docs["b"])
+}
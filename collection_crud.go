@@ -129,6 +129,11 @@ type GetOptions struct {
 	RetryStrategy RetryStrategy
 	ParentSpan    RequestSpan
 
+	// Hedge enables hedged reads, sending a replica read if the active does not respond in time. Cannot be used
+	// with Project or WithExpiry.
+	// UNCOMMITTED: This API may change in the future.
+	Hedge *HedgeOptions
+
 	// Using a deadlined Context alongside a Timeout will cause the shorter of the two to cause cancellation, this
 	// also applies to global level timeouts.
 	// UNCOMMITTED: This API may change in the future.
@@ -144,6 +149,10 @@ type GetOptions struct {
 // fetch, a subdocument full document fetch also fetching document expiry (when WithExpiry is set),
 // or a subdocument fetch (when Project is used).
 func (c *Collection) Get(id string, opts *GetOptions) (docOut *GetResult, errOut error) {
+	if opts != nil && opts.Hedge != nil {
+		return c.hedgedGet(id, opts)
+	}
+
 	return autoOpControl(c.kvController(), "get", func(agent kvProvider) (*GetResult, error) {
 		if opts == nil {
 			opts = &GetOptions{}
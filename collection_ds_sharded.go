@@ -0,0 +1,559 @@
+package gocb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v10"
+)
+
+const (
+	// shardedElementSeparator separates the id of a sharded structure from the sequence number of an element in the
+	// ids of the element documents.
+	shardedElementSeparator = "::"
+
+	// shardedPendingRetries is the number of times that popping an element waits for it to be written, as its
+	// sequence number is reserved before the element document is written.
+	shardedPendingRetries = 10
+	shardedPendingBackoff = 5 * time.Millisecond
+
+	// shardedTombstoneExpiry is the expiry of the tombstones which claim the sequence numbers of elements which were
+	// not written in time. It is far longer than any operation timeout so that the insert of a late writer always
+	// finds the tombstone, rather than writing an element behind the head where it would never be popped.
+	shardedTombstoneExpiry = time.Hour
+)
+
+// shardedTombstone is the content of a tombstone, which is stored as binary so that it cannot be mistaken for an
+// element written by the JSON transcoder.
+var shardedTombstone = []byte("\x00gocb-sharded-tombstone")
+
+func isShardedTombstone(flags uint32, contents []byte) bool {
+	valueType, _ := gocbcore.DecodeCommonFlags(flags)
+	return valueType == gocbcore.BinaryType && bytes.Equal(contents, shardedTombstone)
+}
+
+// shardedIndex is the content of the index document of a sharded structure, elements have sequence numbers from
+// Head up to but not including Tail.
+type shardedIndex struct {
+	Head uint64 `json:"head"`
+	Tail uint64 `json:"tail"`
+}
+
+// shardedSequence holds the elements of a sharded structure, each as its own document, along with a small index
+// document recording the range of sequence numbers in use.
+type shardedSequence[T any] struct {
+	collection *Collection
+	id         string
+}
+
+func (ss *shardedSequence[T]) elementID(seq uint64) string {
+	// Padding the sequence number keeps the element ids in order when scanned.
+	return fmt.Sprintf("%s%s%020d", ss.id, shardedElementSeparator, seq)
+}
+
+func (ss *shardedSequence[T]) elementPrefix() string {
+	return ss.id + shardedElementSeparator
+}
+
+func (ss *shardedSequence[T]) elementSeq(elementID string) (uint64, bool) {
+	suffix := strings.TrimPrefix(elementID, ss.elementPrefix())
+	if len(suffix) != 20 {
+		return 0, false
+	}
+
+	seq, err := strconv.ParseUint(suffix, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return seq, true
+}
+
+// push reserves the next sequence number by incrementing the tail of the index, which is atomic on the server, and
+// then writes the element document. If a pop gave up waiting for the element and claimed its sequence number with a
+// tombstone then the insert fails, and so a new sequence number is reserved.
+func (ss *shardedSequence[T]) push(agent kvProvider, span RequestSpan, val T) (uint64, error) {
+	for i := 0; i < dsMaxCasRetries; i++ {
+		ops := make([]MutateInSpec, 1)
+		ops[0] = IncrementSpec("tail", 1, &CounterSpecOptions{CreatePath: true})
+		result, err := agent.MutateIn(ss.collection, ss.id, ops, &MutateInOptions{
+			StoreSemantic: StoreSemanticsUpsert,
+			ParentSpan:    span,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		var tail uint64
+		err = result.ContentAt(0, &tail)
+		if err != nil {
+			return 0, err
+		}
+
+		seq := tail - 1
+		_, err = agent.Insert(ss.collection, ss.elementID(seq), val, &InsertOptions{
+			ParentSpan: span,
+		})
+		if errors.Is(err, ErrDocumentExists) {
+			logDebugf("Element %d of sharded structure %s was claimed before it was written", seq, ss.id)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		return seq, nil
+	}
+
+	return 0, dsCasRetriesExhausted()
+}
+
+func (ss *shardedSequence[T]) index(agent kvProvider, span RequestSpan) (*shardedIndex, Cas, error) {
+	ops := make([]LookupInSpec, 2)
+	ops[0] = GetSpec("head", nil)
+	ops[1] = GetSpec("tail", nil)
+	result, err := agent.LookupIn(ss.collection, ss.id, ops, &LookupInOptions{
+		ParentSpan: span,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The head is only written once the first element has been removed.
+	var index shardedIndex
+	if result.Exists(0) {
+		err = result.ContentAt(0, &index.Head)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	err = result.ContentAt(1, &index.Tail)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &index, result.Cas(), nil
+}
+
+// popFront claims the element at the head of the sequence by advancing the head of the index using its CAS, so that
+// each element is claimed exactly once, and then removes the element document. The element is read before the head
+// is advanced, so that an element which has not been written yet is either waited for or claimed by a tombstone
+// rather than skipped whilst its writer may still write it.
+func (ss *shardedSequence[T]) popFront(agent kvProvider, span RequestSpan) (T, error) {
+	var empty T
+	for i := 0; i < dsMaxCasRetries; {
+		index, cas, err := ss.index(agent, span)
+		if errors.Is(err, ErrDocumentNotFound) {
+			return empty, wrapError(ErrNoResult, "sharded structure is empty")
+		}
+		if err != nil {
+			return empty, err
+		}
+		if index.Head >= index.Tail {
+			return empty, wrapError(ErrNoResult, "sharded structure is empty")
+		}
+
+		element, err := ss.awaitElement(agent, span, index.Head)
+		if err != nil {
+			return empty, err
+		}
+
+		ops := make([]MutateInSpec, 1)
+		ops[0] = UpsertSpec("head", index.Head+1, nil)
+		_, err = agent.MutateIn(ss.collection, ss.id, ops, &MutateInOptions{
+			Cas:        cas,
+			ParentSpan: span,
+		})
+		if dsIsCasConflict(err) {
+			i++
+			continue
+		}
+		if err != nil {
+			return empty, err
+		}
+
+		if element == nil {
+			// The writer of the element failed after reserving its sequence number, so there is nothing to take.
+			logDebugf("Skipping missing element %d of sharded structure %s", index.Head, ss.id)
+			continue
+		}
+
+		var val T
+		err = element.Content(&val)
+		if err != nil {
+			return empty, err
+		}
+
+		_, err = agent.Remove(ss.collection, ss.elementID(index.Head), &RemoveOptions{
+			Cas:        element.Cas(),
+			ParentSpan: span,
+		})
+		if err != nil && !errors.Is(err, ErrDocumentNotFound) {
+			return empty, err
+		}
+
+		return val, nil
+	}
+
+	return empty, dsCasRetriesExhausted()
+}
+
+// awaitElement gets the element with the given sequence number, waiting for it to be written. If it is not written
+// in time then the sequence number is claimed with a tombstone, and nil is returned as there is no element.
+func (ss *shardedSequence[T]) awaitElement(agent kvProvider, span RequestSpan, seq uint64) (*GetResult, error) {
+	elementID := ss.elementID(seq)
+	for i := 0; ; i++ {
+		element, err := agent.Get(ss.collection, elementID, &GetOptions{
+			ParentSpan: span,
+		})
+		if err == nil {
+			if isShardedTombstone(element.flags, element.contents) {
+				return nil, nil
+			}
+
+			return element, nil
+		}
+		if !errors.Is(err, ErrDocumentNotFound) {
+			return nil, err
+		}
+
+		if i < shardedPendingRetries {
+			// The element may still be being written.
+			time.Sleep(shardedPendingBackoff)
+			continue
+		}
+
+		_, err = agent.Insert(ss.collection, elementID, shardedTombstone, &InsertOptions{
+			Transcoder: NewRawBinaryTranscoder(),
+			Expiry:     shardedTombstoneExpiry,
+			ParentSpan: span,
+		})
+		if errors.Is(err, ErrDocumentExists) {
+			// Either the element was written at last or another pop claimed it, so read it again.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+// get returns the element with the given sequence number, ErrDocumentNotFound is returned if it has been removed or
+// was never written.
+func (ss *shardedSequence[T]) get(agent kvProvider, span RequestSpan, seq uint64) (T, error) {
+	var val T
+	element, err := agent.Get(ss.collection, ss.elementID(seq), &GetOptions{
+		ParentSpan: span,
+	})
+	if err != nil {
+		return val, err
+	}
+	if isShardedTombstone(element.flags, element.contents) {
+		return val, wrapError(ErrDocumentNotFound, "sharded element was never written")
+	}
+
+	err = element.Content(&val)
+	if err != nil {
+		return val, err
+	}
+
+	return val, nil
+}
+
+func (ss *shardedSequence[T]) size(agent kvProvider, span RequestSpan) (int, error) {
+	index, _, err := ss.index(agent, span)
+	if err != nil {
+		return 0, err
+	}
+	if index.Head >= index.Tail {
+		return 0, nil
+	}
+
+	return int(index.Tail - index.Head), nil
+}
+
+// scan calls fn with every element between the head and tail of the index in the order returned by the scan, which
+// is not necessarily the order of the elements. Documents outside of that range are elements which are being popped
+// or were written after the scan began, or are tombstones, and so are not elements of the structure.
+func (ss *shardedSequence[T]) scan(agent kvProvider, span RequestSpan, fn func(seq uint64, item *ScanResultItem) error) error {
+	index, _, err := ss.index(agent, span)
+	if errors.Is(err, ErrDocumentNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return ss.scanDocuments(agent, span, false, func(seq uint64, item *ScanResultItem) error {
+		if seq < index.Head || seq >= index.Tail || isShardedTombstone(item.flags, item.contents) {
+			return nil
+		}
+
+		return fn(seq, item)
+	})
+}
+
+// scanDocuments calls fn with every element document, including tombstones, in the order returned by the scan.
+func (ss *shardedSequence[T]) scanDocuments(agent kvProvider, span RequestSpan, idsOnly bool,
+	fn func(seq uint64, item *ScanResultItem) error) error {
+	result, err := agent.Scan(ss.collection, NewRangeScanForPrefix(ss.elementPrefix()), &ScanOptions{
+		Timeout:    ss.collection.timeoutsConfig.KVScanTimeout,
+		ParentSpan: span,
+		IDsOnly:    idsOnly,
+	})
+	if err != nil {
+		return err
+	}
+
+	for item := result.Next(); item != nil; item = result.Next() {
+		seq, ok := ss.elementSeq(item.ID())
+		if !ok {
+			continue
+		}
+
+		if err := fn(seq, item); err != nil {
+			_ = result.Close()
+			return err
+		}
+	}
+
+	return result.Err()
+}
+
+type shardedElement[T any] struct {
+	seq uint64
+	val T
+}
+
+func (ss *shardedSequence[T]) items(agent kvProvider, span RequestSpan) ([]T, error) {
+	var elements []shardedElement[T]
+	err := ss.scan(agent, span, func(seq uint64, item *ScanResultItem) error {
+		var val T
+		if err := item.Content(&val); err != nil {
+			return err
+		}
+
+		elements = append(elements, shardedElement[T]{seq: seq, val: val})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Scans only return keys in order within each vbucket.
+	sort.Slice(elements, func(i, j int) bool {
+		return elements[i].seq < elements[j].seq
+	})
+
+	items := make([]T, len(elements))
+	for i, element := range elements {
+		items[i] = element.val
+	}
+
+	return items, nil
+}
+
+func (ss *shardedSequence[T]) clear(agent kvProvider, span RequestSpan) error {
+	var ids []string
+	err := ss.scanDocuments(agent, span, true, func(seq uint64, item *ScanResultItem) error {
+		ids = append(ids, item.ID())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, elementID := range ids {
+		_, err := agent.Remove(ss.collection, elementID, &RemoveOptions{
+			ParentSpan: span,
+		})
+		if err != nil && !errors.Is(err, ErrDocumentNotFound) {
+			return err
+		}
+	}
+
+	_, err = agent.Remove(ss.collection, ss.id, &RemoveOptions{
+		ParentSpan: span,
+	})
+	if err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// ShardedList represents a list whose items are each stored in their own document, alongside a small index document
+// holding the range of positions in use. Unlike CouchbaseList it is not limited by the maximum size of a document and
+// appends do not contend on one large document. Items are iterated using a range scan over the ids of the item
+// documents, which requires a server which supports range scans.
+// UNCOMMITTED: This API may change in the future.
+type ShardedList[T any] struct {
+	seq shardedSequence[T]
+}
+
+// NewShardedList returns a new ShardedList with the index document specified by id.
+// UNCOMMITTED: This API may change in the future.
+func NewShardedList[T any](c *Collection, id string) *ShardedList[T] {
+	return &ShardedList[T]{
+		seq: shardedSequence[T]{
+			collection: c,
+			id:         id,
+		},
+	}
+}
+
+// Append appends an item to the list, returning its position in the list.
+func (sl *ShardedList[T]) Append(val T) (uint64, error) {
+	return autoOpControl(sl.seq.collection.kvController(), "sharded_list_append", func(agent kvProvider) (uint64, error) {
+		span := agent.StartKvOpTrace(sl.seq.collection, "sharded_list_append", nil, false)
+		defer span.End()
+
+		return sl.seq.push(agent, span, val)
+	})
+}
+
+// At retrieves the item at the given position, as returned by Append. ErrDocumentNotFound is returned if the item
+// has been removed from the list.
+func (sl *ShardedList[T]) At(position uint64) (T, error) {
+	return autoOpControl(sl.seq.collection.kvController(), "sharded_list_at", func(agent kvProvider) (T, error) {
+		span := agent.StartKvOpTrace(sl.seq.collection, "sharded_list_at", nil, false)
+		defer span.End()
+
+		return sl.seq.get(agent, span, position)
+	})
+}
+
+// RemoveFirst removes and returns the oldest item in the list. ErrNoResult is returned if the list is empty.
+func (sl *ShardedList[T]) RemoveFirst() (T, error) {
+	return autoOpControl(sl.seq.collection.kvController(), "sharded_list_remove_first", func(agent kvProvider) (T, error) {
+		span := agent.StartKvOpTrace(sl.seq.collection, "sharded_list_remove_first", nil, false)
+		defer span.End()
+
+		return sl.seq.popFront(agent, span)
+	})
+}
+
+// Items returns all of the items in the list in the order that they were appended.
+func (sl *ShardedList[T]) Items() ([]T, error) {
+	return autoOpControl(sl.seq.collection.kvController(), "sharded_list_iterator", func(agent kvProvider) ([]T, error) {
+		span := agent.StartKvOpTrace(sl.seq.collection, "sharded_list_iterator", nil, false)
+		defer span.End()
+
+		return sl.seq.items(agent, span)
+	})
+}
+
+// ForEach calls fn with each item in the list along with its position, stopping at the first error returned by fn.
+// Unlike Items the list is not loaded into memory, and so the items are not necessarily visited in order.
+func (sl *ShardedList[T]) ForEach(fn func(position uint64, val T) error) error {
+	return autoOpControlErrorOnly(sl.seq.collection.kvController(), "sharded_list_for_each", func(agent kvProvider) error {
+		span := agent.StartKvOpTrace(sl.seq.collection, "sharded_list_for_each", nil, false)
+		defer span.End()
+
+		return sl.seq.scan(agent, span, func(seq uint64, item *ScanResultItem) error {
+			var val T
+			if err := item.Content(&val); err != nil {
+				return err
+			}
+
+			return fn(seq, val)
+		})
+	})
+}
+
+// Size returns the size of the list.
+func (sl *ShardedList[T]) Size() (int, error) {
+	return autoOpControl(sl.seq.collection.kvController(), "sharded_list_size", func(agent kvProvider) (int, error) {
+		span := agent.StartKvOpTrace(sl.seq.collection, "sharded_list_size", nil, false)
+		defer span.End()
+
+		return sl.seq.size(agent, span)
+	})
+}
+
+// Clear clears a list, removing the item documents and the index document.
+func (sl *ShardedList[T]) Clear() error {
+	return autoOpControlErrorOnly(sl.seq.collection.kvController(), "sharded_list_clear", func(agent kvProvider) error {
+		span := agent.StartKvOpTrace(sl.seq.collection, "sharded_list_clear", nil, false)
+		defer span.End()
+
+		return sl.seq.clear(agent, span)
+	})
+}
+
+// ShardedQueue represents a first in first out queue whose items are each stored in their own document, alongside a
+// small index document holding the range of positions in use. Unlike CouchbaseQueue it is not limited by the maximum
+// size of a document, and pushes and pops do not contend on one large document.
+// UNCOMMITTED: This API may change in the future.
+type ShardedQueue[T any] struct {
+	seq shardedSequence[T]
+}
+
+// NewShardedQueue returns a new ShardedQueue with the index document specified by id.
+// UNCOMMITTED: This API may change in the future.
+func NewShardedQueue[T any](c *Collection, id string) *ShardedQueue[T] {
+	return &ShardedQueue[T]{
+		seq: shardedSequence[T]{
+			collection: c,
+			id:         id,
+		},
+	}
+}
+
+// Push pushes a value onto the end of the queue.
+func (sq *ShardedQueue[T]) Push(val T) error {
+	return autoOpControlErrorOnly(sq.seq.collection.kvController(), "sharded_queue_push", func(agent kvProvider) error {
+		span := agent.StartKvOpTrace(sq.seq.collection, "sharded_queue_push", nil, false)
+		defer span.End()
+
+		_, err := sq.seq.push(agent, span, val)
+		return err
+	})
+}
+
+// Pop removes and returns the value at the front of the queue. ErrNoResult is returned if the queue is empty.
+func (sq *ShardedQueue[T]) Pop() (T, error) {
+	return autoOpControl(sq.seq.collection.kvController(), "sharded_queue_pop", func(agent kvProvider) (T, error) {
+		span := agent.StartKvOpTrace(sq.seq.collection, "sharded_queue_pop", nil, false)
+		defer span.End()
+
+		return sq.seq.popFront(agent, span)
+	})
+}
+
+// Items returns all of the items in the queue, from front to back.
+func (sq *ShardedQueue[T]) Items() ([]T, error) {
+	return autoOpControl(sq.seq.collection.kvController(), "sharded_queue_iterator", func(agent kvProvider) ([]T, error) {
+		span := agent.StartKvOpTrace(sq.seq.collection, "sharded_queue_iterator", nil, false)
+		defer span.End()
+
+		return sq.seq.items(agent, span)
+	})
+}
+
+// Size returns the size of the queue.
+func (sq *ShardedQueue[T]) Size() (int, error) {
+	return autoOpControl(sq.seq.collection.kvController(), "sharded_queue_size", func(agent kvProvider) (int, error) {
+		span := agent.StartKvOpTrace(sq.seq.collection, "sharded_queue_size", nil, false)
+		defer span.End()
+
+		return sq.seq.size(agent, span)
+	})
+}
+
+// Clear clears a queue, removing the item documents and the index document.
+func (sq *ShardedQueue[T]) Clear() error {
+	return autoOpControlErrorOnly(sq.seq.collection.kvController(), "sharded_queue_clear", func(agent kvProvider) error {
+		span := agent.StartKvOpTrace(sq.seq.collection, "sharded_queue_clear", nil, false)
+		defer span.End()
+
+		return sq.seq.clear(agent, span)
+	})
+}
@@ -0,0 +1,179 @@
+package gocb
+
+import (
+	"sync"
+)
+
+func (suite *UnitTestSuite) TestShardedList() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	// Documents which merely share a prefix with the list are not items of it.
+	_, err := col.Upsert("feed::other", "unrelated", nil)
+	suite.Require().NoError(err)
+
+	list := NewShardedList[testDsItem](col, "feed")
+	for i := 0; i < 12; i++ {
+		position, err := list.Append(testDsItem{Count: i})
+		suite.Require().NoError(err)
+		suite.Assert().Equal(uint64(i), position)
+	}
+
+	item, err := list.At(10)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(10, item.Count)
+
+	size, err := list.Size()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(12, size)
+
+	first, err := list.RemoveFirst()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(0, first.Count)
+
+	_, err = list.At(0)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+
+	items, err := list.Items()
+	suite.Require().NoError(err)
+	suite.Require().Len(items, 11)
+	for i, item := range items {
+		suite.Assert().Equal(i+1, item.Count)
+	}
+
+	var visited int
+	suite.Require().NoError(list.ForEach(func(position uint64, val testDsItem) error {
+		suite.Assert().Equal(int(position), val.Count)
+		visited++
+		return nil
+	}))
+	suite.Assert().Equal(11, visited)
+
+	suite.Require().NoError(list.Clear())
+	_, err = list.Size()
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+
+	_, err = col.Get("feed::other", nil)
+	suite.Assert().NoError(err)
+}
+
+func (suite *UnitTestSuite) TestShardedQueueConcurrentPops() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	queue := NewShardedQueue[int](col, "jobs")
+
+	_, err := queue.Pop()
+	suite.Assert().ErrorIs(err, ErrNoResult)
+
+	for i := 0; i < 20; i++ {
+		suite.Require().NoError(queue.Push(i))
+	}
+
+	var lock sync.Mutex
+	seen := make(map[int]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				val, err := queue.Pop()
+				if !suite.Assert().NoError(err) {
+					return
+				}
+
+				lock.Lock()
+				seen[val]++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every item is popped exactly once.
+	suite.Assert().Len(seen, 20)
+	for val, count := range seen {
+		suite.Assert().Equal(1, count, val)
+	}
+
+	size, err := queue.Size()
+	suite.Require().NoError(err)
+	suite.Assert().Zero(size)
+
+	_, err = queue.Pop()
+	suite.Assert().ErrorIs(err, ErrNoResult)
+
+	suite.Require().NoError(queue.Push(20))
+	items, err := queue.Items()
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int{20}, items)
+
+	val, err := queue.Pop()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(20, val)
+
+	suite.Require().NoError(queue.Clear())
+}
+
+func (suite *UnitTestSuite) TestShardedQueueSkipsAbandonedElements() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	queue := NewShardedQueue[int](col, "jobs")
+	suite.Require().NoError(queue.Push(1))
+	suite.Require().NoError(queue.Push(2))
+
+	// Simulate a writer which reserved a position and then failed before writing the item.
+	_, err := col.Remove(queue.seq.elementID(0), nil)
+	suite.Require().NoError(err)
+
+	val, err := queue.Pop()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(2, val)
+}
+
+func (suite *UnitTestSuite) TestShardedQueueClaimsLateElements() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	queue := NewShardedQueue[int](col, "jobs")
+
+	// Simulate a writer which has reserved a position but not yet written the item.
+	_, err := col.MutateIn(queue.seq.id, []MutateInSpec{
+		IncrementSpec("tail", 1, &CounterSpecOptions{CreatePath: true}),
+	}, &MutateInOptions{StoreSemantic: StoreSemanticsUpsert})
+	suite.Require().NoError(err)
+
+	_, err = queue.Pop()
+	suite.Assert().ErrorIs(err, ErrNoResult)
+
+	// The position was claimed, so the late write fails rather than writing an item which is never popped.
+	_, err = col.Insert(queue.seq.elementID(0), 1, nil)
+	suite.Assert().ErrorIs(err, ErrDocumentExists)
+
+	// A push whose position has already been claimed reserves another.
+	_, err = col.Insert(queue.seq.elementID(1), shardedTombstone, &InsertOptions{Transcoder: NewRawBinaryTranscoder()})
+	suite.Require().NoError(err)
+	suite.Require().NoError(queue.Push(2))
+
+	// Documents outside of the range of the index are not items.
+	_, err = col.Upsert(queue.seq.elementID(10), 10, nil)
+	suite.Require().NoError(err)
+
+	items, err := queue.Items()
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int{2}, items)
+
+	val, err := queue.Pop()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(2, val)
+
+	size, err := queue.Size()
+	suite.Require().NoError(err)
+	suite.Assert().Zero(size)
+
+	list := NewShardedList[int](col, "jobs")
+	_, err = list.At(0)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+}
@@ -0,0 +1,767 @@
+package gocb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// dsMaxCasRetries is the number of times that a data structure operation which depends on the current state of the
+// document is attempted before giving up because the document keeps being changed concurrently.
+const dsMaxCasRetries = 16
+
+func dsIsCasConflict(err error) bool {
+	return errors.Is(err, ErrCasMismatch) || errors.Is(err, ErrDocumentExists)
+}
+
+func dsCasRetriesExhausted() error {
+	return wrapError(ErrCasMismatch, fmt.Sprintf("failed to perform operation after %d retries", dsMaxCasRetries))
+}
+
+// CappedList represents a list document which holds at most a fixed number of items, appending an item to a full
+// list removes the oldest item from the start of it.
+// UNCOMMITTED: This API may change in the future.
+type CappedList[T any] struct {
+	collection *Collection
+	id         string
+	capacity   int
+}
+
+// NewCappedList returns a new CappedList for the document specified by id which holds at most capacity items.
+// UNCOMMITTED: This API may change in the future.
+func NewCappedList[T any](c *Collection, id string, capacity int) *CappedList[T] {
+	return &CappedList[T]{
+		collection: c,
+		id:         id,
+		capacity:   capacity,
+	}
+}
+
+// Capacity returns the maximum number of items in the list.
+func (cl *CappedList[T]) Capacity() int {
+	return cl.capacity
+}
+
+// Append appends an item to the list, removing items from the start of the list so that it does not exceed its
+// capacity.
+func (cl *CappedList[T]) Append(val T) error {
+	if cl.capacity <= 0 {
+		return makeInvalidArgumentsError("capped list capacity must be greater than zero")
+	}
+
+	return autoOpControlErrorOnly(cl.collection.kvController(), "capped_list_append", func(agent kvProvider) error {
+		span := agent.StartKvOpTrace(cl.collection, "capped_list_append", nil, false)
+		defer span.End()
+
+		for i := 0; i < dsMaxCasRetries; i++ {
+			result, err := agent.LookupIn(cl.collection, cl.id, []LookupInSpec{CountSpec("", nil)}, &LookupInOptions{
+				ParentSpan: span,
+			})
+			if errors.Is(err, ErrDocumentNotFound) {
+				_, err = agent.MutateIn(cl.collection, cl.id, []MutateInSpec{ArrayAppendSpec("", val, nil)}, &MutateInOptions{
+					StoreSemantic: StoreSemanticsInsert,
+					ParentSpan:    span,
+				})
+				if dsIsCasConflict(err) {
+					continue
+				}
+
+				return err
+			}
+			if err != nil {
+				return err
+			}
+
+			var count int
+			err = result.ContentAt(0, &count)
+			if err != nil {
+				return err
+			}
+
+			// Should the capacity have been reduced then the list is trimmed over several appends, as only so many
+			// items can be removed in a single operation.
+			excess := count + 1 - cl.capacity
+			if excess < 0 {
+				excess = 0
+			} else if excess > subdocMaxSpecs-1 {
+				excess = subdocMaxSpecs - 1
+			}
+
+			ops := make([]MutateInSpec, 0, excess+1)
+			for j := 0; j < excess; j++ {
+				ops = append(ops, RemoveSpec("[0]", nil))
+			}
+			ops = append(ops, ArrayAppendSpec("", val, nil))
+
+			_, err = agent.MutateIn(cl.collection, cl.id, ops, &MutateInOptions{
+				Cas:        result.Cas(),
+				ParentSpan: span,
+			})
+			if dsIsCasConflict(err) {
+				continue
+			}
+
+			return err
+		}
+
+		return dsCasRetriesExhausted()
+	})
+}
+
+// Items returns all of the items in the list, oldest first.
+func (cl *CappedList[T]) Items() ([]T, error) {
+	return autoOpControl(cl.collection.kvController(), "capped_list_iterator", func(agent kvProvider) ([]T, error) {
+		span := agent.StartKvOpTrace(cl.collection, "capped_list_iterator", nil, false)
+		defer span.End()
+
+		return dsGetContent[[]T](agent, span, cl.collection, cl.id)
+	})
+}
+
+// Size returns the size of the list.
+func (cl *CappedList[T]) Size() (int, error) {
+	return autoOpControl(cl.collection.kvController(), "capped_list_size", func(agent kvProvider) (int, error) {
+		span := agent.StartKvOpTrace(cl.collection, "capped_list_size", nil, false)
+		defer span.End()
+
+		return dsListSize(agent, span, cl.collection, cl.id)
+	})
+}
+
+// Clear clears a list, also removing it.
+func (cl *CappedList[T]) Clear() error {
+	return autoOpControlErrorOnly(cl.collection.kvController(), "capped_list_clear", func(agent kvProvider) error {
+		span := agent.StartKvOpTrace(cl.collection, "capped_list_clear", nil, false)
+		defer span.End()
+
+		return dsListClear(agent, span, cl.collection, cl.id)
+	})
+}
+
+// SortedSetMember is a member of a SortedSet along with its score.
+// UNCOMMITTED: This API may change in the future.
+type SortedSetMember struct {
+	Member string
+	Score  float64
+}
+
+// SortedSet represents a sorted set document, a set of unique members which are ordered by their scores. Members
+// with equal scores are ordered by the member itself.
+// UNCOMMITTED: This API may change in the future.
+type SortedSet struct {
+	collection *Collection
+	id         string
+}
+
+// SortedSet returns a new SortedSet.
+// UNCOMMITTED: This API may change in the future.
+func (c *Collection) SortedSet(id string) *SortedSet {
+	return &SortedSet{
+		collection: c,
+		id:         id,
+	}
+}
+
+// Add adds a member to the set with the given score, replacing the score of the member if it is already present.
+func (ss *SortedSet) Add(member string, score float64) error {
+	return autoOpControlErrorOnly(ss.collection.kvController(), "sorted_set_add", func(agent kvProvider) error {
+		span := agent.StartKvOpTrace(ss.collection, "sorted_set_add", nil, false)
+		defer span.End()
+		ops := make([]MutateInSpec, 1)
+		ops[0] = UpsertSpec(subdocPathKey(member), score, nil)
+		_, err := agent.MutateIn(ss.collection, ss.id, ops, &MutateInOptions{
+			StoreSemantic: StoreSemanticsUpsert,
+			ParentSpan:    span,
+		})
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// IncrementScore adds delta to the score of a member, adding the member with a score of delta if it is not present,
+// and returns the new score.
+func (ss *SortedSet) IncrementScore(member string, delta float64) (float64, error) {
+	return autoOpControl(ss.collection.kvController(), "sorted_set_increment_score", func(agent kvProvider) (float64, error) {
+		span := agent.StartKvOpTrace(ss.collection, "sorted_set_increment_score", nil, false)
+		defer span.End()
+
+		path := subdocPathKey(member)
+		for i := 0; i < dsMaxCasRetries; i++ {
+			var score float64
+			opts := &MutateInOptions{
+				StoreSemantic: StoreSemanticsInsert,
+				ParentSpan:    span,
+			}
+
+			result, err := agent.LookupIn(ss.collection, ss.id, []LookupInSpec{GetSpec(path, nil)}, &LookupInOptions{
+				ParentSpan: span,
+			})
+			if err != nil && !errors.Is(err, ErrDocumentNotFound) {
+				return 0, err
+			}
+			if err == nil {
+				opts.StoreSemantic = StoreSemanticsReplace
+				opts.Cas = result.Cas()
+				if result.Exists(0) {
+					err = result.ContentAt(0, &score)
+					if err != nil {
+						return 0, err
+					}
+				}
+			}
+
+			score += delta
+			_, err = agent.MutateIn(ss.collection, ss.id, []MutateInSpec{UpsertSpec(path, score, nil)}, opts)
+			if dsIsCasConflict(err) || errors.Is(err, ErrDocumentNotFound) {
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+
+			return score, nil
+		}
+
+		return 0, dsCasRetriesExhausted()
+	})
+}
+
+// Score returns the score of a member, ErrPathNotFound is returned if the member is not present.
+func (ss *SortedSet) Score(member string) (float64, error) {
+	return autoOpControl(ss.collection.kvController(), "sorted_set_score", func(agent kvProvider) (float64, error) {
+		span := agent.StartKvOpTrace(ss.collection, "sorted_set_score", nil, false)
+		defer span.End()
+
+		return dsLookupPath[float64](agent, span, ss.collection, ss.id, subdocPathKey(member))
+	})
+}
+
+// Remove removes a member from the set.
+func (ss *SortedSet) Remove(member string) error {
+	return (&CouchbaseMap{collection: ss.collection, id: ss.id}).Remove(subdocPathKey(member))
+}
+
+// Members returns all of the members of the set, ordered by score from lowest to highest.
+func (ss *SortedSet) Members() ([]SortedSetMember, error) {
+	return autoOpControl(ss.collection.kvController(), "sorted_set_iterator", func(agent kvProvider) ([]SortedSetMember, error) {
+		span := agent.StartKvOpTrace(ss.collection, "sorted_set_iterator", nil, false)
+		defer span.End()
+
+		scores, err := dsGetContent[map[string]float64](agent, span, ss.collection, ss.id)
+		if err != nil {
+			return nil, err
+		}
+
+		return sortedSetMembers(scores), nil
+	})
+}
+
+func sortedSetMembers(scores map[string]float64) []SortedSetMember {
+	members := make([]SortedSetMember, 0, len(scores))
+	for member, score := range scores {
+		members = append(members, SortedSetMember{Member: member, Score: score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Score != members[j].Score {
+			return members[i].Score < members[j].Score
+		}
+
+		return members[i].Member < members[j].Member
+	})
+
+	return members
+}
+
+// Range returns the members of the set with ranks from start up to but not including stop, ordered by score from
+// lowest to highest. A stop beyond the size of the set returns all of the members from start onwards.
+func (ss *SortedSet) Range(start, stop int) ([]SortedSetMember, error) {
+	if start < 0 || stop < start {
+		return nil, makeInvalidArgumentsError("range start must not be negative or greater than stop")
+	}
+
+	members, err := ss.Members()
+	if err != nil {
+		return nil, err
+	}
+
+	if start > len(members) {
+		start = len(members)
+	}
+	if stop > len(members) {
+		stop = len(members)
+	}
+
+	return members[start:stop], nil
+}
+
+// RangeByScore returns the members of the set whose scores are between min and max inclusive, ordered by score from
+// lowest to highest.
+func (ss *SortedSet) RangeByScore(min, max float64) ([]SortedSetMember, error) {
+	members, err := ss.Members()
+	if err != nil {
+		return nil, err
+	}
+
+	var inRange []SortedSetMember
+	for _, member := range members {
+		if member.Score >= min && member.Score <= max {
+			inRange = append(inRange, member)
+		}
+	}
+
+	return inRange, nil
+}
+
+// Rank returns the position of a member in the set ordered by score from lowest to highest, or -1 if the member is
+// not present.
+func (ss *SortedSet) Rank(member string) (int, error) {
+	members, err := ss.Members()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, m := range members {
+		if m.Member == member {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// PopMin removes and returns the member of the set with the lowest score. ErrNoResult is returned if the set is
+// empty.
+func (ss *SortedSet) PopMin() (*SortedSetMember, error) {
+	return autoOpControl(ss.collection.kvController(), "sorted_set_pop_min", func(agent kvProvider) (*SortedSetMember, error) {
+		span := agent.StartKvOpTrace(ss.collection, "sorted_set_pop_min", nil, false)
+		defer span.End()
+
+		for i := 0; i < dsMaxCasRetries; i++ {
+			content, err := agent.Get(ss.collection, ss.id, &GetOptions{
+				ParentSpan: span,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			var scores map[string]float64
+			err = content.Content(&scores)
+			if err != nil {
+				return nil, err
+			}
+
+			members := sortedSetMembers(scores)
+			if len(members) == 0 {
+				return nil, wrapError(ErrNoResult, "sorted set is empty")
+			}
+
+			ops := make([]MutateInSpec, 1)
+			ops[0] = RemoveSpec(subdocPathKey(members[0].Member), nil)
+			_, err = agent.MutateIn(ss.collection, ss.id, ops, &MutateInOptions{
+				Cas:        content.Cas(),
+				ParentSpan: span,
+			})
+			if dsIsCasConflict(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			return &members[0], nil
+		}
+
+		return nil, dsCasRetriesExhausted()
+	})
+}
+
+// Size returns the number of members in the set.
+func (ss *SortedSet) Size() (int, error) {
+	return (&CouchbaseMap{collection: ss.collection, id: ss.id}).Size()
+}
+
+// Clear clears a set, also removing it.
+func (ss *SortedSet) Clear() error {
+	return (&CouchbaseMap{collection: ss.collection, id: ss.id}).Clear()
+}
+
+// CounterMap represents a document holding a map of named counters, each of which is changed atomically on the
+// server.
+// UNCOMMITTED: This API may change in the future.
+type CounterMap struct {
+	collection *Collection
+	id         string
+}
+
+// CounterMap returns a new CounterMap.
+// UNCOMMITTED: This API may change in the future.
+func (c *Collection) CounterMap(id string) *CounterMap {
+	return &CounterMap{
+		collection: c,
+		id:         id,
+	}
+}
+
+// Increment adds delta to the named counter, creating it with a value of delta if it does not exist, and returns the
+// new value.
+func (cm *CounterMap) Increment(key string, delta int64) (int64, error) {
+	return cm.counter("counter_map_increment", IncrementSpec(subdocPathKey(key), delta, &CounterSpecOptions{
+		CreatePath: true,
+	}))
+}
+
+// Decrement subtracts delta from the named counter, creating it with a value of -delta if it does not exist, and
+// returns the new value.
+func (cm *CounterMap) Decrement(key string, delta int64) (int64, error) {
+	return cm.counter("counter_map_decrement", DecrementSpec(subdocPathKey(key), delta, &CounterSpecOptions{
+		CreatePath: true,
+	}))
+}
+
+func (cm *CounterMap) counter(operation string, spec MutateInSpec) (int64, error) {
+	return autoOpControl(cm.collection.kvController(), operation, func(agent kvProvider) (int64, error) {
+		span := agent.StartKvOpTrace(cm.collection, operation, nil, false)
+		defer span.End()
+		result, err := agent.MutateIn(cm.collection, cm.id, []MutateInSpec{spec}, &MutateInOptions{
+			StoreSemantic: StoreSemanticsUpsert,
+			ParentSpan:    span,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		var value int64
+		err = result.ContentAt(0, &value)
+		if err != nil {
+			return 0, err
+		}
+
+		return value, nil
+	})
+}
+
+// Get returns the value of the named counter, counters which do not exist have a value of zero.
+func (cm *CounterMap) Get(key string) (int64, error) {
+	return autoOpControl(cm.collection.kvController(), "counter_map_at", func(agent kvProvider) (int64, error) {
+		span := agent.StartKvOpTrace(cm.collection, "counter_map_at", nil, false)
+		defer span.End()
+
+		value, err := dsLookupPath[int64](agent, span, cm.collection, cm.id, subdocPathKey(key))
+		if errors.Is(err, ErrDocumentNotFound) || errors.Is(err, ErrPathNotFound) {
+			return 0, nil
+		}
+
+		return value, err
+	})
+}
+
+// All returns the values of all of the counters.
+func (cm *CounterMap) All() (map[string]int64, error) {
+	return autoOpControl(cm.collection.kvController(), "counter_map_iterator", func(agent kvProvider) (map[string]int64, error) {
+		span := agent.StartKvOpTrace(cm.collection, "counter_map_iterator", nil, false)
+		defer span.End()
+
+		values, err := dsGetContent[map[string]int64](agent, span, cm.collection, cm.id)
+		if errors.Is(err, ErrDocumentNotFound) {
+			return map[string]int64{}, nil
+		}
+
+		return values, err
+	})
+}
+
+// Remove removes the named counter.
+func (cm *CounterMap) Remove(key string) error {
+	return (&CouchbaseMap{collection: cm.collection, id: cm.id}).Remove(subdocPathKey(key))
+}
+
+// Clear removes all of the counters, also removing the document.
+func (cm *CounterMap) Clear() error {
+	return (&CouchbaseMap{collection: cm.collection, id: cm.id}).Clear()
+}
+
+// blockingQueueEntry is a message as it is stored in a queue document.
+type blockingQueueEntry[T any] struct {
+	ID           string `json:"id"`
+	Value        T      `json:"value"`
+	VisibleAt    int64  `json:"visible_at"`
+	Receipt      string `json:"receipt,omitempty"`
+	ReceiveCount int    `json:"receive_count"`
+}
+
+type blockingQueueDocument[T any] struct {
+	Messages []blockingQueueEntry[T] `json:"messages"`
+}
+
+// QueueMessage is a message received from a BlockingQueue.
+// UNCOMMITTED: This API may change in the future.
+type QueueMessage[T any] struct {
+	ID    string
+	Value T
+	// ReceiveCount is the number of times that the message has been received, including this time.
+	ReceiveCount int
+
+	receipt string
+}
+
+// QueueReceiveOptions are the options available to BlockingQueue.Receive.
+// UNCOMMITTED: This API may change in the future.
+type QueueReceiveOptions struct {
+	// VisibilityTimeout is how long a received message is hidden from other receivers for, should the message not be
+	// acknowledged within this time then it is delivered again. Defaults to 30 seconds.
+	VisibilityTimeout time.Duration
+	// WaitTimeout is how long to wait for a message to become available. If not set then ErrNoResult is returned
+	// immediately if there are no messages available.
+	WaitTimeout time.Duration
+	// PollInterval is how often the queue is checked while waiting for a message. Defaults to 100 milliseconds.
+	PollInterval time.Duration
+
+	// UNCOMMITTED: This API may change in the future.
+	Context context.Context
+}
+
+// BlockingQueue represents a first in first out queue document with at least once delivery. Received messages are
+// hidden from other receivers until they are acknowledged or their visibility timeout passes, at which point they are
+// delivered again.
+// UNCOMMITTED: This API may change in the future.
+type BlockingQueue[T any] struct {
+	collection *Collection
+	id         string
+}
+
+// NewBlockingQueue returns a new BlockingQueue for the document specified by id.
+// UNCOMMITTED: This API may change in the future.
+func NewBlockingQueue[T any](c *Collection, id string) *BlockingQueue[T] {
+	return &BlockingQueue[T]{
+		collection: c,
+		id:         id,
+	}
+}
+
+// Push pushes a value onto the end of the queue.
+func (bq *BlockingQueue[T]) Push(val T) error {
+	return autoOpControlErrorOnly(bq.collection.kvController(), "blocking_queue_push", func(agent kvProvider) error {
+		span := agent.StartKvOpTrace(bq.collection, "blocking_queue_push", nil, false)
+		defer span.End()
+		ops := make([]MutateInSpec, 1)
+		ops[0] = ArrayAppendSpec("messages", blockingQueueEntry[T]{
+			ID:    uuid.New().String(),
+			Value: val,
+		}, &ArrayAppendSpecOptions{CreatePath: true})
+		_, err := agent.MutateIn(bq.collection, bq.id, ops, &MutateInOptions{
+			StoreSemantic: StoreSemanticsUpsert,
+			ParentSpan:    span,
+		})
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// Receive returns the oldest message in the queue which is not hidden, hiding it from other receivers until it is
+// acknowledged or its visibility timeout passes. ErrNoResult is returned if no message becomes available before the
+// wait timeout.
+func (bq *BlockingQueue[T]) Receive(opts *QueueReceiveOptions) (*QueueMessage[T], error) {
+	if opts == nil {
+		opts = &QueueReceiveOptions{}
+	}
+
+	visibility := opts.VisibilityTimeout
+	if visibility == 0 {
+		visibility = 30 * time.Second
+	}
+	pollInterval := opts.PollInterval
+	if pollInterval == 0 {
+		pollInterval = 100 * time.Millisecond
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return autoOpControl(bq.collection.kvController(), "blocking_queue_receive", func(agent kvProvider) (*QueueMessage[T], error) {
+		span := agent.StartKvOpTrace(bq.collection, "blocking_queue_receive", nil, false)
+		defer span.End()
+
+		deadline := time.Now().Add(opts.WaitTimeout)
+		for {
+			msg, err := bq.tryReceive(agent, span, visibility)
+			if !errors.Is(err, ErrNoResult) {
+				return msg, err
+			}
+
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return nil, err
+			}
+
+			wait := pollInterval
+			if wait > remaining {
+				wait = remaining
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, backoffContextErr(ctx)
+			case <-timer.C:
+			}
+		}
+	})
+}
+
+func (bq *BlockingQueue[T]) tryReceive(agent kvProvider, span RequestSpan, visibility time.Duration) (*QueueMessage[T], error) {
+	for i := 0; i < dsMaxCasRetries; i++ {
+		content, err := agent.Get(bq.collection, bq.id, &GetOptions{
+			ParentSpan: span,
+		})
+		if errors.Is(err, ErrDocumentNotFound) {
+			return nil, wrapError(ErrNoResult, "queue is empty")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var doc blockingQueueDocument[T]
+		err = content.Content(&doc)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		index := -1
+		for j, entry := range doc.Messages {
+			if entry.VisibleAt <= now.UnixNano() {
+				index = j
+				break
+			}
+		}
+		if index < 0 {
+			return nil, wrapError(ErrNoResult, "no messages are available")
+		}
+
+		entry := doc.Messages[index]
+		entry.VisibleAt = now.Add(visibility).UnixNano()
+		entry.Receipt = uuid.New().String()
+		entry.ReceiveCount++
+
+		ops := make([]MutateInSpec, 1)
+		ops[0] = ReplaceSpec(fmt.Sprintf("messages[%d]", index), entry, nil)
+		_, err = agent.MutateIn(bq.collection, bq.id, ops, &MutateInOptions{
+			Cas:        content.Cas(),
+			ParentSpan: span,
+		})
+		if dsIsCasConflict(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &QueueMessage[T]{
+			ID:           entry.ID,
+			Value:        entry.Value,
+			ReceiveCount: entry.ReceiveCount,
+			receipt:      entry.Receipt,
+		}, nil
+	}
+
+	return nil, dsCasRetriesExhausted()
+}
+
+// Ack acknowledges that a received message has been processed, removing it from the queue. ErrQueueReceiptInvalid is
+// returned if the message has since been received again, after its visibility timeout passed, or already removed.
+func (bq *BlockingQueue[T]) Ack(msg *QueueMessage[T]) error {
+	return autoOpControlErrorOnly(bq.collection.kvController(), "blocking_queue_ack", func(agent kvProvider) error {
+		span := agent.StartKvOpTrace(bq.collection, "blocking_queue_ack", nil, false)
+		defer span.End()
+
+		for i := 0; i < dsMaxCasRetries; i++ {
+			content, err := agent.Get(bq.collection, bq.id, &GetOptions{
+				ParentSpan: span,
+			})
+			if errors.Is(err, ErrDocumentNotFound) {
+				return wrapError(ErrQueueReceiptInvalid, "queue no longer exists")
+			}
+			if err != nil {
+				return err
+			}
+
+			var doc blockingQueueDocument[T]
+			err = content.Content(&doc)
+			if err != nil {
+				return err
+			}
+
+			index := -1
+			for j, entry := range doc.Messages {
+				if entry.ID == msg.ID && entry.Receipt == msg.receipt {
+					index = j
+					break
+				}
+			}
+			if index < 0 {
+				return wrapError(ErrQueueReceiptInvalid, fmt.Sprintf("message %s is no longer held", msg.ID))
+			}
+
+			ops := make([]MutateInSpec, 1)
+			ops[0] = RemoveSpec(fmt.Sprintf("messages[%d]", index), nil)
+			_, err = agent.MutateIn(bq.collection, bq.id, ops, &MutateInOptions{
+				Cas:        content.Cas(),
+				ParentSpan: span,
+			})
+			if dsIsCasConflict(err) {
+				continue
+			}
+
+			return err
+		}
+
+		return dsCasRetriesExhausted()
+	})
+}
+
+// Size returns the number of messages in the queue, including those which are hidden.
+func (bq *BlockingQueue[T]) Size() (int, error) {
+	return autoOpControl(bq.collection.kvController(), "blocking_queue_size", func(agent kvProvider) (int, error) {
+		span := agent.StartKvOpTrace(bq.collection, "blocking_queue_size", nil, false)
+		defer span.End()
+
+		ops := make([]LookupInSpec, 1)
+		ops[0] = CountSpec("messages", nil)
+		result, err := agent.LookupIn(bq.collection, bq.id, ops, &LookupInOptions{
+			ParentSpan: span,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		var count int
+		err = result.ContentAt(0, &count)
+		if err != nil {
+			return 0, err
+		}
+
+		return count, nil
+	})
+}
+
+// Clear clears a queue, also removing it.
+func (bq *BlockingQueue[T]) Clear() error {
+	return autoOpControlErrorOnly(bq.collection.kvController(), "blocking_queue_clear", func(agent kvProvider) error {
+		span := agent.StartKvOpTrace(bq.collection, "blocking_queue_clear", nil, false)
+		defer span.End()
+
+		return dsListClear(agent, span, bq.collection, bq.id)
+	})
+}
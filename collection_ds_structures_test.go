@@ -0,0 +1,235 @@
+package gocb
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+func (suite *UnitTestSuite) TestCappedList() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	list := NewCappedList[int](col, "capped", 3)
+	for i := 1; i <= 5; i++ {
+		suite.Require().NoError(list.Append(i))
+	}
+
+	items, err := list.Items()
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int{3, 4, 5}, items)
+
+	// Reducing the capacity trims the list on the next append.
+	smaller := NewCappedList[int](col, "capped", 2)
+	suite.Require().NoError(smaller.Append(6))
+	items, err = smaller.Items()
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]int{5, 6}, items)
+
+	suite.Assert().ErrorIs(NewCappedList[int](col, "capped", 0).Append(1), ErrInvalidArgument)
+
+	suite.Require().NoError(list.Clear())
+	_, err = list.Size()
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+}
+
+func (suite *UnitTestSuite) TestCappedListConcurrentAppends() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	list := NewCappedList[int](col, "capped", 5)
+	suite.Require().NoError(list.Append(0))
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			suite.Assert().NoError(list.Append(i))
+		}(i)
+	}
+	wg.Wait()
+
+	size, err := list.Size()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(5, size)
+}
+
+func (suite *UnitTestSuite) TestSortedSet() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	set := col.SortedSet("scores")
+	suite.Require().NoError(set.Add("carol", 30))
+	suite.Require().NoError(set.Add("alice", 10))
+	suite.Require().NoError(set.Add("bob", 20))
+	suite.Require().NoError(set.Add("a.dotted", 20))
+
+	score, err := set.IncrementScore("alice", 15.5)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(25.5, score)
+
+	score, err = set.IncrementScore("dave", 5)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(5.0, score)
+
+	score, err = set.Score("a.dotted")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(20.0, score)
+
+	members, err := set.Members()
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]SortedSetMember{
+		{"dave", 5}, {"a.dotted", 20}, {"bob", 20}, {"alice", 25.5}, {"carol", 30},
+	}, members)
+
+	members, err = set.Range(1, 3)
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]SortedSetMember{{"a.dotted", 20}, {"bob", 20}}, members)
+
+	members, err = set.Range(4, 10)
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]SortedSetMember{{"carol", 30}}, members)
+
+	members, err = set.RangeByScore(20, 26)
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]SortedSetMember{{"a.dotted", 20}, {"bob", 20}, {"alice", 25.5}}, members)
+
+	rank, err := set.Rank("alice")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(3, rank)
+
+	rank, err = set.Rank("missing")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(-1, rank)
+
+	popped, err := set.PopMin()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(SortedSetMember{"dave", 5}, *popped)
+
+	suite.Require().NoError(set.Remove("carol"))
+	size, err := set.Size()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(3, size)
+
+	_, err = set.Range(2, 1)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	for i := 0; i < 3; i++ {
+		_, err = set.PopMin()
+		suite.Require().NoError(err)
+	}
+	_, err = set.PopMin()
+	suite.Assert().ErrorIs(err, ErrNoResult)
+
+	suite.Require().NoError(set.Clear())
+}
+
+func (suite *UnitTestSuite) TestCounterMap() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	counters := col.CounterMap("counters")
+
+	value, err := counters.Get("hits")
+	suite.Require().NoError(err)
+	suite.Assert().Zero(value)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := counters.Increment("hits", 2)
+			suite.Assert().NoError(err)
+		}()
+	}
+	wg.Wait()
+
+	value, err = counters.Decrement("misses", 3)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(-3), value)
+
+	value, err = counters.Increment("hits", 1)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(int64(21), value)
+
+	value, err = counters.Get("missing")
+	suite.Require().NoError(err)
+	suite.Assert().Zero(value)
+
+	all, err := counters.All()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(map[string]int64{"hits": 21, "misses": -3}, all)
+
+	suite.Require().NoError(counters.Remove("misses"))
+	suite.Require().NoError(counters.Clear())
+
+	all, err = counters.All()
+	suite.Require().NoError(err)
+	suite.Assert().Empty(all)
+}
+
+func (suite *UnitTestSuite) TestBlockingQueue() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	queue := NewBlockingQueue[testDsItem](col, "queue")
+
+	_, err := queue.Receive(nil)
+	suite.Assert().ErrorIs(err, ErrNoResult)
+
+	for i := 0; i < 3; i++ {
+		suite.Require().NoError(queue.Push(testDsItem{Name: strconv.Itoa(i), Count: i}))
+	}
+
+	first, err := queue.Receive(&QueueReceiveOptions{VisibilityTimeout: time.Hour})
+	suite.Require().NoError(err)
+	suite.Assert().Equal(testDsItem{"0", 0}, first.Value)
+	suite.Assert().Equal(1, first.ReceiveCount)
+
+	// A message which is not acknowledged within its visibility timeout is delivered again.
+	second, err := queue.Receive(&QueueReceiveOptions{VisibilityTimeout: time.Millisecond})
+	suite.Require().NoError(err)
+	suite.Assert().Equal(testDsItem{"1", 1}, second.Value)
+
+	time.Sleep(5 * time.Millisecond)
+	redelivered, err := queue.Receive(&QueueReceiveOptions{VisibilityTimeout: time.Hour})
+	suite.Require().NoError(err)
+	suite.Assert().Equal(second.ID, redelivered.ID)
+	suite.Assert().Equal(2, redelivered.ReceiveCount)
+
+	suite.Assert().ErrorIs(queue.Ack(second), ErrQueueReceiptInvalid)
+	suite.Require().NoError(queue.Ack(redelivered))
+	suite.Require().NoError(queue.Ack(first))
+	suite.Assert().ErrorIs(queue.Ack(first), ErrQueueReceiptInvalid)
+
+	size, err := queue.Size()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(1, size)
+
+	third, err := queue.Receive(nil)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(testDsItem{"2", 2}, third.Value)
+
+	// Waiting receivers are given messages pushed while they wait.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		suite.Assert().NoError(queue.Push(testDsItem{Name: "late"}))
+	}()
+
+	late, err := queue.Receive(&QueueReceiveOptions{WaitTimeout: 5 * time.Second, PollInterval: 5 * time.Millisecond})
+	suite.Require().NoError(err)
+	suite.Assert().Equal("late", late.Value.Name)
+
+	_, err = queue.Receive(&QueueReceiveOptions{WaitTimeout: 10 * time.Millisecond, PollInterval: time.Millisecond})
+	suite.Assert().ErrorIs(err, ErrNoResult)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = queue.Receive(&QueueReceiveOptions{WaitTimeout: 5 * time.Second, PollInterval: time.Second, Context: ctx})
+	suite.Assert().ErrorIs(err, ErrRequestCanceled)
+
+	suite.Require().NoError(queue.Clear())
+}
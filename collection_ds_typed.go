@@ -0,0 +1,200 @@
+package gocb
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// List represents a list document whose items are of type T.
+// UNCOMMITTED: This API may change in the future.
+type List[T any] struct {
+	collection *Collection
+	id         string
+}
+
+// NewList returns a new List for the document specified by id.
+// UNCOMMITTED: This API may change in the future.
+func NewList[T any](c *Collection, id string) *List[T] {
+	return &List[T]{
+		collection: c,
+		id:         id,
+	}
+}
+
+// Items returns all of the items in the list.
+func (cl *List[T]) Items() ([]T, error) {
+	return autoOpControl(cl.collection.kvController(), "list_iterator", func(agent kvProvider) ([]T, error) {
+		span := agent.StartKvOpTrace(cl.collection, "list_iterator", nil, false)
+		defer span.End()
+
+		return dsGetContent[[]T](agent, span, cl.collection, cl.id)
+	})
+}
+
+// At retrieves the item at the given index from the list.
+func (cl *List[T]) At(index int) (T, error) {
+	return autoOpControl(cl.collection.kvController(), "list_at", func(agent kvProvider) (T, error) {
+		span := agent.StartKvOpTrace(cl.collection, "list_at", nil, false)
+		defer span.End()
+
+		return dsLookupPath[T](agent, span, cl.collection, cl.id, fmt.Sprintf("[%d]", index))
+	})
+}
+
+// RemoveAt removes the item at the given index from the list.
+func (cl *List[T]) RemoveAt(index int) error {
+	return (&CouchbaseList{collection: cl.collection, id: cl.id}).RemoveAt(index)
+}
+
+// Append appends an item to the list.
+func (cl *List[T]) Append(val T) error {
+	return (&CouchbaseList{collection: cl.collection, id: cl.id}).Append(val)
+}
+
+// Prepend prepends an item to the list.
+func (cl *List[T]) Prepend(val T) error {
+	return (&CouchbaseList{collection: cl.collection, id: cl.id}).Prepend(val)
+}
+
+// IndexOf gets the index of the first item in the list which is deeply equal to val, or -1 if there is none.
+func (cl *List[T]) IndexOf(val T) (int, error) {
+	items, err := cl.Items()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, item := range items {
+		if reflect.DeepEqual(item, val) {
+			return i, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// Size returns the size of the list.
+func (cl *List[T]) Size() (int, error) {
+	return (&CouchbaseList{collection: cl.collection, id: cl.id}).Size()
+}
+
+// Clear clears a list, also removing it.
+func (cl *List[T]) Clear() error {
+	return (&CouchbaseList{collection: cl.collection, id: cl.id}).Clear()
+}
+
+// Map represents a map document whose values are of type T.
+// UNCOMMITTED: This API may change in the future.
+type Map[T any] struct {
+	collection *Collection
+	id         string
+}
+
+// NewMap returns a new Map for the document specified by id.
+// UNCOMMITTED: This API may change in the future.
+func NewMap[T any](c *Collection, id string) *Map[T] {
+	return &Map[T]{
+		collection: c,
+		id:         id,
+	}
+}
+
+// Items returns all of the items in the map.
+func (cl *Map[T]) Items() (map[string]T, error) {
+	return autoOpControl(cl.collection.kvController(), "map_iterator", func(agent kvProvider) (map[string]T, error) {
+		span := agent.StartKvOpTrace(cl.collection, "map_iterator", nil, false)
+		defer span.End()
+
+		return dsGetContent[map[string]T](agent, span, cl.collection, cl.id)
+	})
+}
+
+// At retrieves the item for the given key from the map.
+func (cl *Map[T]) At(key string) (T, error) {
+	return autoOpControl(cl.collection.kvController(), "map_at", func(agent kvProvider) (T, error) {
+		span := agent.StartKvOpTrace(cl.collection, "map_at", nil, false)
+		defer span.End()
+
+		return dsLookupPath[T](agent, span, cl.collection, cl.id, subdocPathKey(key))
+	})
+}
+
+// Add adds an item to the map, replacing any existing item with the same key.
+func (cl *Map[T]) Add(key string, val T) error {
+	return (&CouchbaseMap{collection: cl.collection, id: cl.id}).Add(subdocPathKey(key), val)
+}
+
+// Remove removes an item from the map.
+func (cl *Map[T]) Remove(key string) error {
+	return (&CouchbaseMap{collection: cl.collection, id: cl.id}).Remove(subdocPathKey(key))
+}
+
+// Exists verifies whether or not a key exists in the map.
+func (cl *Map[T]) Exists(key string) (bool, error) {
+	return (&CouchbaseMap{collection: cl.collection, id: cl.id}).Exists(subdocPathKey(key))
+}
+
+// Size returns the size of the map.
+func (cl *Map[T]) Size() (int, error) {
+	return (&CouchbaseMap{collection: cl.collection, id: cl.id}).Size()
+}
+
+// Keys returns all of the keys within the map.
+func (cl *Map[T]) Keys() ([]string, error) {
+	return (&CouchbaseMap{collection: cl.collection, id: cl.id}).Keys()
+}
+
+// Values returns all of the values within the map.
+func (cl *Map[T]) Values() ([]T, error) {
+	items, err := cl.Items()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]T, 0, len(items))
+	for _, val := range items {
+		values = append(values, val)
+	}
+
+	return values, nil
+}
+
+// Clear clears a map, also removing it.
+func (cl *Map[T]) Clear() error {
+	return (&CouchbaseMap{collection: cl.collection, id: cl.id}).Clear()
+}
+
+func dsGetContent[T any](agent kvProvider, span RequestSpan, collection *Collection, id string) (T, error) {
+	var contents T
+	content, err := agent.Get(collection, id, &GetOptions{
+		ParentSpan: span,
+	})
+	if err != nil {
+		return contents, err
+	}
+
+	err = content.Content(&contents)
+	if err != nil {
+		return contents, err
+	}
+
+	return contents, nil
+}
+
+func dsLookupPath[T any](agent kvProvider, span RequestSpan, collection *Collection, id, path string) (T, error) {
+	var val T
+	ops := make([]LookupInSpec, 1)
+	ops[0] = GetSpec(path, nil)
+	result, err := agent.LookupIn(collection, id, ops, &LookupInOptions{
+		ParentSpan: span,
+	})
+	if err != nil {
+		return val, err
+	}
+
+	err = result.ContentAt(0, &val)
+	if err != nil {
+		return val, err
+	}
+
+	return val, nil
+}
@@ -0,0 +1,82 @@
+package gocb
+
+type testDsItem struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func (suite *UnitTestSuite) TestTypedList() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	list := NewList[testDsItem](col, "list")
+	suite.Require().NoError(list.Append(testDsItem{Name: "b", Count: 2}))
+	suite.Require().NoError(list.Prepend(testDsItem{Name: "a", Count: 1}))
+	suite.Require().NoError(list.Append(testDsItem{Name: "c", Count: 3}))
+
+	items, err := list.Items()
+	suite.Require().NoError(err)
+	suite.Assert().Equal([]testDsItem{{"a", 1}, {"b", 2}, {"c", 3}}, items)
+
+	item, err := list.At(1)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(testDsItem{"b", 2}, item)
+
+	index, err := list.IndexOf(testDsItem{"c", 3})
+	suite.Require().NoError(err)
+	suite.Assert().Equal(2, index)
+
+	index, err = list.IndexOf(testDsItem{"c", 4})
+	suite.Require().NoError(err)
+	suite.Assert().Equal(-1, index)
+
+	suite.Require().NoError(list.RemoveAt(0))
+	size, err := list.Size()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(2, size)
+
+	suite.Require().NoError(list.Clear())
+	_, err = list.Items()
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+}
+
+func (suite *UnitTestSuite) TestTypedMap() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	m := NewMap[testDsItem](col, "map")
+	suite.Require().NoError(m.Add("a", testDsItem{Name: "a", Count: 1}))
+	suite.Require().NoError(m.Add("dotted.key", testDsItem{Name: "dotted", Count: 2}))
+
+	item, err := m.At("dotted.key")
+	suite.Require().NoError(err)
+	suite.Assert().Equal(testDsItem{"dotted", 2}, item)
+
+	exists, err := m.Exists("dotted.key")
+	suite.Require().NoError(err)
+	suite.Assert().True(exists)
+
+	items, err := m.Items()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(map[string]testDsItem{"a": {"a", 1}, "dotted.key": {"dotted", 2}}, items)
+
+	keys, err := m.Keys()
+	suite.Require().NoError(err)
+	suite.Assert().ElementsMatch([]string{"a", "dotted.key"}, keys)
+
+	values, err := m.Values()
+	suite.Require().NoError(err)
+	suite.Assert().ElementsMatch([]testDsItem{{"a", 1}, {"dotted", 2}}, values)
+
+	suite.Require().NoError(m.Remove("dotted.key"))
+	size, err := m.Size()
+	suite.Require().NoError(err)
+	suite.Assert().Equal(1, size)
+
+	_, err = m.At("dotted.key")
+	suite.Assert().ErrorIs(err, ErrPathNotFound)
+
+	suite.Require().NoError(m.Clear())
+	_, err = m.Items()
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+}
@@ -0,0 +1,182 @@
+package gocb
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultHedgeDelay = 10 * time.Millisecond
+
+	// hedgeMinSamples is the number of recorded gets required before the delay is derived from their latency.
+	hedgeMinSamples = 100
+)
+
+// HedgeOptions enables hedged reads for Get. The active copy of the document is read first and, if it has not
+// responded within the hedge delay, a replica read is also sent. Whichever responds first is returned and the other
+// read is cancelled.
+// Any response from the active, including an error, is authoritative and is returned immediately. A replica error is
+// only returned if the active also fails.
+// The number of hedged gets, replica reads sent and replica reads returned are recorded as counters, the default
+// LoggingMeter logs these alongside operation latencies.
+// UNCOMMITTED: This API may change in the future.
+type HedgeOptions struct {
+	// Delay is how long to wait for the active before sending the replica read, defaults to 10ms.
+	Delay time.Duration
+
+	// Percentile derives the delay from the latency of recent gets at the given percentile, e.g. 95. This requires
+	// the default LoggingMeter, the latencies are those recorded in its current emit interval. Delay is used until
+	// enough gets have been recorded, or if another Meter is in use.
+	Percentile float64
+
+	// MaxDelay caps the delay derived from Percentile. 0 means no cap.
+	MaxDelay time.Duration
+
+	// ReadPreference is used for the replica read.
+	ReadPreference ReadPreference
+}
+
+type hedgedGetResult struct {
+	doc     *GetResult
+	err     error
+	replica bool
+}
+
+func (c *Collection) hedgedGet(id string, opts *GetOptions) (*GetResult, error) {
+	hedge := opts.Hedge
+	if len(opts.Project) > 0 || opts.WithExpiry {
+		return nil, makeInvalidArgumentsError("hedged reads cannot be used with Project or WithExpiry")
+	}
+	if hedge.Percentile < 0 || hedge.Percentile > 100 {
+		return nil, makeInvalidArgumentsError("hedge percentile must be between 0 and 100")
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = c.timeoutsConfig.KVTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	parentCtx := opts.Context
+	if parentCtx == nil {
+		parentCtx = context.Background()
+	}
+	activeCtx, activeCancel := context.WithCancel(parentCtx)
+	defer activeCancel()
+	replicaCtx, replicaCancel := context.WithCancel(parentCtx)
+	defer replicaCancel()
+
+	c.incrementHedgeCounter(meterNameHedgeRequests)
+
+	resultCh := make(chan hedgedGetResult, 2)
+	go func() {
+		activeOpts := *opts
+		activeOpts.Hedge = nil
+		activeOpts.Timeout = timeout
+		activeOpts.Context = activeCtx
+		doc, err := c.Get(id, &activeOpts)
+		resultCh <- hedgedGetResult{doc: doc, err: err}
+	}()
+
+	timer := time.NewTimer(c.hedgeDelay(hedge))
+	defer timer.Stop()
+
+	select {
+	case res := <-resultCh:
+		return res.doc, res.err
+	case <-timer.C:
+	}
+
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		res := <-resultCh
+		return res.doc, res.err
+	}
+
+	c.incrementHedgeCounter(meterNameHedgeSent)
+	go func() {
+		doc, err := c.GetAnyReplica(id, &GetAnyReplicaOptions{
+			Transcoder:     opts.Transcoder,
+			Timeout:        remaining,
+			RetryStrategy:  opts.RetryStrategy,
+			ParentSpan:     opts.ParentSpan,
+			ReadPreference: hedge.ReadPreference,
+			Context:        replicaCtx,
+			Internal:       opts.Internal,
+		})
+		if err != nil {
+			resultCh <- hedgedGetResult{err: err, replica: true}
+			return
+		}
+
+		res := doc.GetResult
+		res.replica = doc.IsReplica()
+		resultCh <- hedgedGetResult{doc: &res, replica: true}
+	}()
+
+	for {
+		res := <-resultCh
+		if !res.replica {
+			return res.doc, res.err
+		}
+		if res.err != nil {
+			logDebugf("Hedged replica read for %s failed, waiting for active: %v", id, res.err)
+			continue
+		}
+
+		activeCancel()
+		c.incrementHedgeCounter(meterNameHedgeWon)
+		return res.doc, nil
+	}
+}
+
+func (c *Collection) hedgeDelay(hedge *HedgeOptions) time.Duration {
+	delay := hedge.Delay
+	if delay == 0 {
+		delay = defaultHedgeDelay
+	}
+	if hedge.Percentile == 0 || c.bucket.connectionManager == nil {
+		return delay
+	}
+
+	meter := c.bucket.connectionManager.getMeter()
+	if meter == nil {
+		return delay
+	}
+	loggingMeter, ok := meter.meter.(*LoggingMeter)
+	if !ok {
+		return delay
+	}
+
+	latency, samples := loggingMeter.latencyAtPercentile(serviceValueKV, "get", hedge.Percentile)
+	if samples < hedgeMinSamples {
+		return delay
+	}
+	if hedge.MaxDelay > 0 && latency > hedge.MaxDelay {
+		return hedge.MaxDelay
+	}
+
+	return latency
+}
+
+func (c *Collection) incrementHedgeCounter(name string) {
+	if c.bucket.connectionManager == nil {
+		return
+	}
+	meter := c.bucket.connectionManager.getMeter()
+	if meter == nil || meter.isNoopMeter {
+		return
+	}
+
+	counter, err := meter.meter.Counter(name, map[string]string{
+		meterAttribServiceKey:    serviceValueKV,
+		meterAttribOperationKey:  "get",
+		meterAttribBucketNameKey: c.bucketName(),
+	})
+	if err != nil {
+		logDebugf("Failed to create hedge counter: %v", err)
+		return
+	}
+
+	counter.IncrementBy(1)
+}
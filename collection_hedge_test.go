@@ -0,0 +1,196 @@
+package gocb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *UnitTestSuite) hedgeCollection(provider *mockKvProvider, meter Meter) *Collection {
+	cli := new(mockConnectionManager)
+	cli.On("getMeter").Return(newMeterWrapper(meter))
+
+	col := suite.collection("mock", "", "", provider)
+	col.bucket.connectionManager = cli
+	return col
+}
+
+func (suite *UnitTestSuite) TestHedgedGetActiveResponds() {
+	provider := new(mockKvProvider)
+	provider.On("Get", mock.Anything, "someid", mock.AnythingOfType("*gocb.GetOptions")).
+		Return(&GetResult{contents: []byte(`{}`)}, nil)
+
+	meter := newTestMeter()
+	col := suite.hedgeCollection(provider, meter)
+
+	res, err := col.Get("someid", &GetOptions{Hedge: &HedgeOptions{Delay: time.Second}})
+	suite.Require().Nil(err, err)
+	suite.Assert().False(res.IsReplica())
+
+	provider.AssertNotCalled(suite.T(), "GetAnyReplica", mock.Anything, mock.Anything, mock.Anything)
+	suite.Assert().Equal(uint64(1), meter.counters[meterNameHedgeRequests+":get"].count)
+	suite.Assert().Nil(meter.counters[meterNameHedgeSent+":get"])
+}
+
+func (suite *UnitTestSuite) TestHedgedGetLoggingMeterCounters() {
+	provider := new(mockKvProvider)
+	provider.On("Get", mock.Anything, "someid", mock.AnythingOfType("*gocb.GetOptions")).
+		Return(&GetResult{contents: []byte(`{}`)}, nil)
+
+	meter := newAggregatingMeter(nil)
+	col := suite.hedgeCollection(provider, meter)
+
+	_, err := col.Get("someid", &GetOptions{Hedge: &HedgeOptions{Delay: time.Second}})
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal(map[string]interface{}{
+		meterNameHedgeRequests: uint64(1),
+	}, meter.generateOutput()["counters"])
+}
+
+func (suite *UnitTestSuite) TestHedgedGetReplicaWins() {
+	activeCancelled := make(chan struct{})
+	provider := new(mockKvProvider)
+	provider.On("Get", mock.Anything, "someid", mock.AnythingOfType("*gocb.GetOptions")).
+		Return(func(_ *Collection, _ string, opts *GetOptions) (*GetResult, error) {
+			suite.Assert().Nil(opts.Hedge)
+			<-opts.Context.Done()
+			close(activeCancelled)
+			return nil, ErrRequestCanceled
+		})
+	provider.On("GetAnyReplica", mock.Anything, "someid", mock.AnythingOfType("*gocb.GetAnyReplicaOptions")).
+		Run(func(args mock.Arguments) {
+			opts := args.Get(2).(*GetAnyReplicaOptions)
+			suite.Assert().Equal(ReadPreferenceSelectedServerGroup, opts.ReadPreference)
+			suite.Assert().Greater(opts.Timeout, time.Duration(0))
+		}).
+		Return(&GetReplicaResult{GetResult: GetResult{contents: []byte(`{"replica":true}`)}, isReplica: true}, nil)
+
+	meter := newTestMeter()
+	col := suite.hedgeCollection(provider, meter)
+
+	res, err := col.Get("someid", &GetOptions{Hedge: &HedgeOptions{
+		Delay:          5 * time.Millisecond,
+		ReadPreference: ReadPreferenceSelectedServerGroup,
+	}})
+	suite.Require().Nil(err, err)
+	suite.Assert().True(res.IsReplica())
+	suite.Assert().Equal([]byte(`{"replica":true}`), res.contents)
+
+	select {
+	case <-activeCancelled:
+	case <-time.After(time.Second):
+		suite.T().Fatal("active read was not cancelled")
+	}
+
+	suite.Assert().Equal(uint64(1), meter.counters[meterNameHedgeSent+":get"].count)
+	suite.Assert().Equal(uint64(1), meter.counters[meterNameHedgeWon+":get"].count)
+}
+
+func (suite *UnitTestSuite) TestHedgedGetReplicaFails() {
+	provider := new(mockKvProvider)
+	provider.On("Get", mock.Anything, "someid", mock.AnythingOfType("*gocb.GetOptions")).
+		Return(func(_ *Collection, _ string, opts *GetOptions) (*GetResult, error) {
+			time.Sleep(50 * time.Millisecond)
+			return &GetResult{contents: []byte(`{}`)}, nil
+		})
+	provider.On("GetAnyReplica", mock.Anything, "someid", mock.AnythingOfType("*gocb.GetAnyReplicaOptions")).
+		Return(nil, ErrDocumentUnretrievable)
+
+	col := suite.hedgeCollection(provider, newTestMeter())
+
+	res, err := col.Get("someid", &GetOptions{Hedge: &HedgeOptions{Delay: time.Millisecond}})
+	suite.Require().Nil(err, err)
+	suite.Assert().False(res.IsReplica())
+}
+
+func (suite *UnitTestSuite) TestHedgedGetActiveErrorIsAuthoritative() {
+	provider := new(mockKvProvider)
+	provider.On("Get", mock.Anything, "someid", mock.AnythingOfType("*gocb.GetOptions")).
+		Return(func(_ *Collection, _ string, opts *GetOptions) (*GetResult, error) {
+			time.Sleep(20 * time.Millisecond)
+			return nil, ErrDocumentNotFound
+		})
+	provider.On("GetAnyReplica", mock.Anything, "someid", mock.AnythingOfType("*gocb.GetAnyReplicaOptions")).
+		Return(func(_ *Collection, _ string, opts *GetAnyReplicaOptions) (*GetReplicaResult, error) {
+			<-opts.Context.Done()
+			return nil, ErrRequestCanceled
+		})
+
+	col := suite.hedgeCollection(provider, newTestMeter())
+
+	_, err := col.Get("someid", &GetOptions{Hedge: &HedgeOptions{Delay: time.Millisecond}})
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+}
+
+func (suite *UnitTestSuite) TestHedgedGetInvalidOptions() {
+	col := suite.hedgeCollection(new(mockKvProvider), newTestMeter())
+
+	_, err := col.Get("someid", &GetOptions{Project: []string{"name"}, Hedge: &HedgeOptions{}})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = col.Get("someid", &GetOptions{WithExpiry: true, Hedge: &HedgeOptions{}})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = col.Get("someid", &GetOptions{Hedge: &HedgeOptions{Percentile: 101}})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
+
+func (suite *UnitTestSuite) TestHedgeDelayFromLoggingMeter() {
+	meter := newAggregatingMeter(nil)
+	col := suite.hedgeCollection(new(mockKvProvider), meter)
+	hedge := &HedgeOptions{Delay: 3 * time.Millisecond, Percentile: 90}
+
+	// Not enough samples have been recorded.
+	suite.Assert().Equal(3*time.Millisecond, col.hedgeDelay(hedge))
+
+	recorder, err := meter.ValueRecorder(meterNameCBOperations, map[string]string{
+		meterAttribServiceKey:   serviceValueKV,
+		meterAttribOperationKey: "get",
+	})
+	suite.Require().Nil(err, err)
+	for i := 0; i < 95; i++ {
+		recorder.RecordValue(500)
+	}
+	for i := 0; i < 5; i++ {
+		recorder.RecordValue(100000)
+	}
+
+	// All of the 90th percentile is in the lowest bin.
+	suite.Assert().Equal(time.Millisecond, col.hedgeDelay(hedge))
+
+	hedge.Percentile = 99
+	delay := col.hedgeDelay(hedge)
+	suite.Assert().GreaterOrEqual(delay, 100*time.Millisecond)
+	suite.Assert().Less(delay, 150*time.Millisecond)
+
+	hedge.MaxDelay = 20 * time.Millisecond
+	suite.Assert().Equal(20*time.Millisecond, col.hedgeDelay(hedge))
+
+	// Other meters fall back to the configured delay.
+	col = suite.hedgeCollection(new(mockKvProvider), newTestMeter())
+	suite.Assert().Equal(3*time.Millisecond, col.hedgeDelay(hedge))
+}
+
+func (suite *UnitTestSuite) TestHedgedGetContextCancelled() {
+	provider := new(mockKvProvider)
+	provider.On("Get", mock.Anything, "someid", mock.AnythingOfType("*gocb.GetOptions")).
+		Return(func(_ *Collection, _ string, opts *GetOptions) (*GetResult, error) {
+			<-opts.Context.Done()
+			return nil, ErrRequestCanceled
+		})
+	provider.On("GetAnyReplica", mock.Anything, "someid", mock.AnythingOfType("*gocb.GetAnyReplicaOptions")).
+		Return(func(_ *Collection, _ string, opts *GetAnyReplicaOptions) (*GetReplicaResult, error) {
+			<-opts.Context.Done()
+			return nil, ErrRequestCanceled
+		})
+
+	col := suite.hedgeCollection(provider, newTestMeter())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := col.Get("someid", &GetOptions{Context: ctx, Hedge: &HedgeOptions{Delay: time.Millisecond}})
+	suite.Assert().True(errors.Is(err, ErrRequestCanceled))
+}
@@ -0,0 +1,361 @@
+package gocb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	lockDocumentPrefix = "_lock::"
+
+	spanEventLockContended = "lock_contended"
+
+	defaultLockTTL = 30 * time.Second
+)
+
+// lockDocument is the content of the document which represents a held lock.
+type lockDocument struct {
+	Owner    string `json:"owner"`
+	Acquired int64  `json:"acquired"`
+}
+
+func lockDocumentID(name string) string {
+	return lockDocumentPrefix + name
+}
+
+// defaultLockOwner identifies this process as the owner of a lock, the random suffix keeps owners unique between
+// processes on the same host.
+func defaultLockOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.New().String()[:8])
+}
+
+// LockOptions are the options available to the Lock operation.
+// UNCOMMITTED: This API may change in the future.
+type LockOptions struct {
+	// TTL is how long the lock is held for without being renewed, should the owner stop renewing it then it becomes
+	// available to others once this has passed. Defaults to 30 seconds.
+	TTL time.Duration
+	// RenewInterval is how often the lease is automatically renewed. Defaults to a third of TTL.
+	RenewInterval time.Duration
+	// DisableRenewal prevents the lease from being renewed automatically, it must instead be renewed using
+	// Lease.Renew before TTL passes.
+	DisableRenewal bool
+	// Owner identifies the owner of the lock. Defaults to an identifier made from the hostname and process ID.
+	Owner string
+	// WaitTimeout is how long to wait for the lock to become available if it is held by another owner. If not set
+	// then ErrLockContended is returned immediately.
+	WaitTimeout time.Duration
+	// Backoff calculates how long to wait before each new attempt to acquire a contended lock, defaults to a full
+	// jitter exponential backoff.
+	Backoff BackoffCalculator
+
+	// Timeout and RetryStrategy apply to each individual operation performed on the lock document, including
+	// renewals.
+	Timeout       time.Duration
+	RetryStrategy RetryStrategy
+	ParentSpan    RequestSpan
+
+	// Context applies only to acquiring the lock, not to the lifetime of the lease.
+	// UNCOMMITTED: This API may change in the future.
+	Context context.Context
+}
+
+// Lock acquires the lock identified by name, which is represented by a document in the collection whose expiry is
+// the TTL of the lock. The returned Lease is renewed in the background until it is released, or until it is lost
+// because a renewal failed and the TTL passed. A lease should always be released once it is no longer required.
+//
+// Every Lease carries a fencing token, derived from the CAS at which the lock was acquired, which increases each time
+// the lock is acquired. Passing the token to any resource protected by the lock, and having the resource reject
+// tokens lower than the highest it has seen, guards against a former owner which does not yet know that its lease has
+// been lost.
+// UNCOMMITTED: This API may change in the future.
+func (c *Collection) Lock(name string, opts *LockOptions) (*Lease, error) {
+	if opts == nil {
+		opts = &LockOptions{}
+	}
+	if name == "" {
+		return nil, makeInvalidArgumentsError("lock name cannot be empty")
+	}
+
+	ttl := opts.TTL
+	if ttl == 0 {
+		ttl = defaultLockTTL
+	}
+	if ttl < time.Second {
+		return nil, makeInvalidArgumentsError("lock ttl must be at least one second")
+	}
+	renewInterval := opts.RenewInterval
+	if renewInterval == 0 {
+		renewInterval = ttl / 3
+	}
+	if renewInterval >= ttl {
+		return nil, makeInvalidArgumentsError("lock renew interval must be less than the ttl")
+	}
+	owner := opts.Owner
+	if owner == "" {
+		owner = defaultLockOwner()
+	}
+	backoff := opts.Backoff
+	if backoff == nil {
+		backoff = FullJitterBackoff(10*time.Millisecond, time.Second, 2)
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return autoOpControl(c.kvController(), "lock", func(agent kvProvider) (*Lease, error) {
+		span := agent.StartKvOpTrace(c, "lock", opts.ParentSpan, false)
+		defer span.End()
+
+		id := lockDocumentID(name)
+		deadline := time.Now().Add(opts.WaitTimeout)
+		for attempt := uint32(0); ; attempt++ {
+			now := time.Now()
+			res, err := c.Insert(id, lockDocument{Owner: owner, Acquired: now.UnixNano()}, &InsertOptions{
+				Expiry:        ttl,
+				Timeout:       opts.Timeout,
+				RetryStrategy: opts.RetryStrategy,
+				ParentSpan:    span,
+				Context:       opts.Context,
+			})
+			if err == nil {
+				span.SetAttribute(spanAttribRetries, attempt)
+				return newLease(c, name, owner, res.Cas(), now, ttl, renewInterval, opts), nil
+			}
+			if !errors.Is(err, ErrDocumentExists) {
+				return nil, err
+			}
+
+			span.AddEvent(fmt.Sprintf("%s attempt=%d", spanEventLockContended, attempt+1), time.Now())
+
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return nil, wrapError(ErrLockContended, fmt.Sprintf("lock %s is held by %s", name, c.lockOwner(id, span, opts)))
+			}
+
+			wait := backoff(attempt)
+			if wait > remaining {
+				wait = remaining
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, backoffContextErr(ctx)
+			case <-timer.C:
+			}
+		}
+	})
+}
+
+// lockOwner fetches the owner of a held lock for reporting, it is only informational so any failure is reported as
+// the owner being unknown.
+func (c *Collection) lockOwner(id string, span RequestSpan, opts *LockOptions) string {
+	res, err := c.Get(id, &GetOptions{
+		Timeout:       opts.Timeout,
+		RetryStrategy: opts.RetryStrategy,
+		ParentSpan:    span,
+		Context:       opts.Context,
+	})
+	if err != nil {
+		return "unknown owner"
+	}
+
+	var doc lockDocument
+	if err := res.Content(&doc); err != nil {
+		return "unknown owner"
+	}
+
+	return doc.Owner
+}
+
+// Lease represents a held lock, as returned by Lock.
+// UNCOMMITTED: This API may change in the future.
+type Lease struct {
+	collection    *Collection
+	name          string
+	id            string
+	owner         string
+	token         uint64
+	acquired      time.Time
+	ttl           time.Duration
+	timeout       time.Duration
+	retryStrategy RetryStrategy
+
+	lock        sync.Mutex
+	cas         Cas
+	lastRenewed time.Time
+	released    bool
+
+	lost     chan struct{}
+	lostOnce sync.Once
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	doneCh   chan struct{}
+}
+
+func newLease(c *Collection, name, owner string, cas Cas, acquired time.Time, ttl, renewInterval time.Duration,
+	opts *LockOptions) *Lease {
+	l := &Lease{
+		collection:    c,
+		name:          name,
+		id:            lockDocumentID(name),
+		owner:         owner,
+		token:         uint64(cas),
+		acquired:      acquired,
+		ttl:           ttl,
+		timeout:       opts.Timeout,
+		retryStrategy: opts.RetryStrategy,
+		cas:           cas,
+		lastRenewed:   acquired,
+		lost:          make(chan struct{}),
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+
+	if opts.DisableRenewal {
+		close(l.doneCh)
+	} else {
+		go l.renewLoop(renewInterval)
+	}
+
+	return l
+}
+
+// Name returns the name of the lock.
+func (l *Lease) Name() string {
+	return l.name
+}
+
+// Owner returns the owner which holds the lease.
+func (l *Lease) Owner() string {
+	return l.owner
+}
+
+// Token returns the fencing token of the lease, which is greater than that of any lease on the same lock acquired
+// before it.
+func (l *Lease) Token() uint64 {
+	return l.token
+}
+
+// Lost returns a channel which is closed if the lease is lost, after which the lock may be held by another owner. The
+// channel is not closed when the lease is released.
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *Lease) markLost() {
+	l.lostOnce.Do(func() {
+		logWarnf("Lease on lock %s held by %s has been lost", l.name, l.owner)
+		close(l.lost)
+	})
+}
+
+func (l *Lease) renewLoop(interval time.Duration) {
+	defer close(l.doneCh)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stopCh:
+			return
+		case <-l.lost:
+			return
+		case <-ticker.C:
+		}
+
+		if err := l.Renew(); err != nil {
+			logDebugf("Failed to renew lease on lock %s: %v", l.name, err)
+		}
+	}
+}
+
+// Renew extends the lease by its TTL. Renewal happens automatically unless it was disabled when the lock was
+// acquired. ErrLeaseLost is returned if the lease is no longer held.
+func (l *Lease) Renew() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.released {
+		return wrapError(ErrLeaseLost, "lease has been released")
+	}
+
+	select {
+	case <-l.lost:
+		return wrapError(ErrLeaseLost, fmt.Sprintf("lease on lock %s has been lost", l.name))
+	default:
+	}
+
+	now := time.Now()
+	res, err := l.collection.Replace(l.id, lockDocument{Owner: l.owner, Acquired: l.acquired.UnixNano()}, &ReplaceOptions{
+		Cas:           l.cas,
+		Expiry:        l.ttl,
+		Timeout:       l.timeout,
+		RetryStrategy: l.retryStrategy,
+	})
+	if err != nil {
+		if errors.Is(err, ErrCasMismatch) || errors.Is(err, ErrDocumentNotFound) || time.Since(l.lastRenewed) >= l.ttl {
+			l.markLost()
+			return wrapError(ErrLeaseLost, fmt.Sprintf("lease on lock %s has been lost: %v", l.name, err))
+		}
+
+		return err
+	}
+
+	l.cas = res.Cas()
+	l.lastRenewed = now
+	return nil
+}
+
+// Release stops the lease from being renewed and releases the lock so that others can acquire it. The lock is only
+// removed if it is still held by this lease, if it is not then ErrLeaseLost is returned.
+func (l *Lease) Release() error {
+	l.stopOnce.Do(func() {
+		close(l.stopCh)
+	})
+	<-l.doneCh
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.released {
+		return nil
+	}
+	l.released = true
+
+	select {
+	case <-l.lost:
+		return wrapError(ErrLeaseLost, fmt.Sprintf("lease on lock %s has been lost", l.name))
+	default:
+	}
+
+	_, err := l.collection.Remove(l.id, &RemoveOptions{
+		Cas:           l.cas,
+		Timeout:       l.timeout,
+		RetryStrategy: l.retryStrategy,
+	})
+	if err != nil {
+		if errors.Is(err, ErrCasMismatch) || errors.Is(err, ErrDocumentNotFound) {
+			return wrapError(ErrLeaseLost, fmt.Sprintf("lease on lock %s has been lost: %v", l.name, err))
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package gocb
+
+import (
+	"context"
+	"time"
+)
+
+func (suite *UnitTestSuite) TestLock() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	lease, err := col.Lock("job", &LockOptions{Owner: "pod-a"})
+	suite.Require().NoError(err)
+	suite.Assert().Equal("job", lease.Name())
+	suite.Assert().Equal("pod-a", lease.Owner())
+
+	_, err = col.Lock("job", &LockOptions{Owner: "pod-b"})
+	suite.Require().ErrorIs(err, ErrLockContended)
+	suite.Assert().Contains(err.Error(), "pod-a")
+
+	// Other locks are independent.
+	other, err := col.Lock("other-job", nil)
+	suite.Require().NoError(err)
+	suite.Assert().NotEmpty(other.Owner())
+	suite.Require().NoError(other.Release())
+
+	suite.Require().NoError(lease.Release())
+	suite.Require().NoError(lease.Release())
+	suite.Assert().ErrorIs(lease.Renew(), ErrLeaseLost)
+
+	next, err := col.Lock("job", &LockOptions{Owner: "pod-b"})
+	suite.Require().NoError(err)
+	suite.Assert().Greater(next.Token(), lease.Token())
+	suite.Require().NoError(next.Release())
+
+	_, err = col.Lock("", nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = col.Lock("job", &LockOptions{TTL: time.Second, RenewInterval: time.Second})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
+
+func (suite *UnitTestSuite) TestLockWaitsForRelease() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	lease, err := col.Lock("job", &LockOptions{Owner: "pod-a"})
+	suite.Require().NoError(err)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		suite.Assert().NoError(lease.Release())
+	}()
+
+	next, err := col.Lock("job", &LockOptions{
+		Owner:       "pod-b",
+		WaitTimeout: 5 * time.Second,
+		Backoff: func(retryAttempts uint32) time.Duration {
+			return 5 * time.Millisecond
+		},
+	})
+	suite.Require().NoError(err)
+	suite.Assert().Equal("pod-b", next.Owner())
+
+	// Ending the context whilst waiting reports the error as the rest of the SDK does.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = col.Lock("job", &LockOptions{
+		Owner:       "pod-c",
+		WaitTimeout: 5 * time.Second,
+		Backoff: func(retryAttempts uint32) time.Duration {
+			return time.Second
+		},
+		Context: ctx,
+	})
+	suite.Assert().ErrorIs(err, ErrRequestCanceled)
+
+	suite.Require().NoError(next.Release())
+}
+
+func (suite *UnitTestSuite) TestLockLeaseLostOnExpiry() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	now := time.Now()
+	suite.Require().NoError(cluster.Internal().SetFakeClock(func() time.Time {
+		return now
+	}))
+
+	lease, err := col.Lock("job", &LockOptions{Owner: "pod-a", TTL: 2 * time.Second, DisableRenewal: true})
+	suite.Require().NoError(err)
+
+	now = now.Add(time.Second)
+	suite.Require().NoError(lease.Renew())
+
+	// Renewal pushed the expiry back so the lock is still held.
+	now = now.Add(1500 * time.Millisecond)
+	_, err = col.Lock("job", &LockOptions{Owner: "pod-b"})
+	suite.Require().ErrorIs(err, ErrLockContended)
+
+	now = now.Add(time.Second)
+	next, err := col.Lock("job", &LockOptions{Owner: "pod-b", DisableRenewal: true})
+	suite.Require().NoError(err)
+	suite.Assert().Greater(next.Token(), lease.Token())
+
+	suite.Assert().ErrorIs(lease.Renew(), ErrLeaseLost)
+	select {
+	case <-lease.Lost():
+	default:
+		suite.Fail("lease should have been lost")
+	}
+
+	// Releasing a lost lease must not release the lock of the new owner.
+	suite.Assert().ErrorIs(lease.Release(), ErrLeaseLost)
+	_, err = col.Lock("job", &LockOptions{Owner: "pod-c"})
+	suite.Assert().ErrorIs(err, ErrLockContended)
+
+	suite.Require().NoError(next.Release())
+}
+
+func (suite *UnitTestSuite) TestLockRenewsAutomatically() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	lease, err := col.Lock("job", &LockOptions{TTL: time.Second, RenewInterval: 5 * time.Millisecond})
+	suite.Require().NoError(err)
+
+	res, err := col.Get(lockDocumentID("job"), nil)
+	suite.Require().NoError(err)
+	acquiredCas := res.Cas()
+
+	suite.Assert().Eventually(func() bool {
+		res, err := col.Get(lockDocumentID("job"), nil)
+		return err == nil && res.Cas() != acquiredCas
+	}, time.Second, 5*time.Millisecond)
+
+	suite.Require().NoError(lease.Release())
+
+	_, err = col.Get(lockDocumentID("job"), nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+}
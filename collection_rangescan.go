@@ -21,6 +21,11 @@ type ScanOptions struct {
 	IDsOnly        bool
 	ConsistentWith *MutationState
 
+	// ResumeFrom continues a range scan from the point recorded by ScanResult.ResumeToken, skipping the vbuckets and
+	// keys which had already been returned. The scan must be of the same range of the same collection.
+	// UNCOMMITTED: This API may change in the future.
+	ResumeFrom *ScanResumeToken
+
 	// BatchByteLimit specifies a limit to how many bytes are sent from server to client on each partition batch.
 	// Defaults to 15000. A value of 0 is equivalent to no limit.
 	BatchByteLimit *uint32
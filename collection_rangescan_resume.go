@@ -0,0 +1,358 @@
+package gocb
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/couchbase/gocbcore/v10"
+)
+
+// ScanResumeToken records how far a range scan got through each vbucket, allowing a later scan of the same range to
+// continue from where it stopped rather than starting again. The token can be marshalled to JSON so that it can be
+// persisted across process restarts.
+// UNCOMMITTED: This API may change in the future.
+type ScanResumeToken struct {
+	bucketName     string
+	scopeName      string
+	collectionName string
+	from           ScanTerm
+	to             ScanTerm
+	numVbuckets    int
+	vbuckets       map[uint16]scanVbucketProgress
+}
+
+// scanVbucketProgress is the progress of a single vbucket, along with the snapshot that the scan of it must observe.
+type scanVbucketProgress struct {
+	lastKey  []byte
+	complete bool
+	snapshot *gocbcore.RangeScanCreateSnapshotRequirements
+}
+
+// jsonScanTerm holds the term as bytes as terms, such as ScanTermMaximum, need not be valid UTF-8.
+type jsonScanTerm struct {
+	Term      []byte `json:"term"`
+	Exclusive bool   `json:"exclusive,omitempty"`
+}
+
+type jsonScanVbucketProgress struct {
+	ID       uint16 `json:"id"`
+	LastKey  []byte `json:"last_key,omitempty"`
+	Complete bool   `json:"complete,omitempty"`
+	VbUUID   string `json:"vb_uuid,omitempty"`
+	SeqNo    uint64 `json:"seq_no,omitempty"`
+}
+
+type jsonScanResumeToken struct {
+	Bucket      string                    `json:"bucket"`
+	Scope       string                    `json:"scope"`
+	Collection  string                    `json:"collection"`
+	From        jsonScanTerm              `json:"from"`
+	To          jsonScanTerm              `json:"to"`
+	NumVbuckets int                       `json:"num_vbuckets"`
+	Vbuckets    []jsonScanVbucketProgress `json:"vbuckets,omitempty"`
+}
+
+// MarshalJSON marshal's this resume token to JSON.
+func (t *ScanResumeToken) MarshalJSON() ([]byte, error) {
+	data := jsonScanResumeToken{
+		Bucket:      t.bucketName,
+		Scope:       t.scopeName,
+		Collection:  t.collectionName,
+		From:        jsonScanTerm{Term: []byte(t.from.Term), Exclusive: t.from.Exclusive},
+		To:          jsonScanTerm{Term: []byte(t.to.Term), Exclusive: t.to.Exclusive},
+		NumVbuckets: t.numVbuckets,
+	}
+
+	for vbID, progress := range t.vbuckets {
+		vbucket := jsonScanVbucketProgress{
+			ID:       vbID,
+			LastKey:  progress.lastKey,
+			Complete: progress.complete,
+		}
+		if progress.snapshot != nil {
+			vbucket.VbUUID = strconv.FormatUint(uint64(progress.snapshot.VbUUID), 10)
+			vbucket.SeqNo = uint64(progress.snapshot.SeqNo)
+		}
+		data.Vbuckets = append(data.Vbuckets, vbucket)
+	}
+	sort.Slice(data.Vbuckets, func(i, j int) bool {
+		return data.Vbuckets[i].ID < data.Vbuckets[j].ID
+	})
+
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON unmarshal's a resume token from JSON.
+func (t *ScanResumeToken) UnmarshalJSON(data []byte) error {
+	var tokenData jsonScanResumeToken
+	err := json.Unmarshal(data, &tokenData)
+	if err != nil {
+		return err
+	}
+
+	vbuckets := make(map[uint16]scanVbucketProgress, len(tokenData.Vbuckets))
+	for _, vbucket := range tokenData.Vbuckets {
+		if int(vbucket.ID) >= tokenData.NumVbuckets {
+			return makeInvalidArgumentsError("resume token contains progress for an unknown vbucket")
+		}
+
+		progress := scanVbucketProgress{
+			lastKey:  vbucket.LastKey,
+			complete: vbucket.Complete,
+		}
+		if vbucket.VbUUID != "" {
+			vbUUID, err := strconv.ParseUint(vbucket.VbUUID, 10, 64)
+			if err != nil {
+				return err
+			}
+			progress.snapshot = &gocbcore.RangeScanCreateSnapshotRequirements{
+				VbUUID: gocbcore.VbUUID(vbUUID),
+				SeqNo:  gocbcore.SeqNo(vbucket.SeqNo),
+			}
+		}
+		vbuckets[vbucket.ID] = progress
+	}
+
+	*t = ScanResumeToken{
+		bucketName:     tokenData.Bucket,
+		scopeName:      tokenData.Scope,
+		collectionName: tokenData.Collection,
+		from:           ScanTerm{Term: string(tokenData.From.Term), Exclusive: tokenData.From.Exclusive},
+		to:             ScanTerm{Term: string(tokenData.To.Term), Exclusive: tokenData.To.Exclusive},
+		numVbuckets:    tokenData.NumVbuckets,
+		vbuckets:       vbuckets,
+	}
+
+	return nil
+}
+
+// newScanResumeToken creates the token for the start of a range scan, recording the snapshot requirements so that a
+// resumed scan observes the same mutations.
+func newScanResumeToken(c *Collection, scan RangeScan, numVbuckets int,
+	snapshots map[uint16]gocbcore.RangeScanCreateSnapshotRequirements) *ScanResumeToken {
+	from, to := scanResumeRange(scan)
+	token := &ScanResumeToken{
+		bucketName:     c.bucketName(),
+		scopeName:      c.ScopeName(),
+		collectionName: c.Name(),
+		from:           from,
+		to:             to,
+		numVbuckets:    numVbuckets,
+		vbuckets:       make(map[uint16]scanVbucketProgress, len(snapshots)),
+	}
+	for vbID, snapshot := range snapshots {
+		snapshot := snapshot
+		token.vbuckets[vbID] = scanVbucketProgress{snapshot: &snapshot}
+	}
+
+	return token
+}
+
+// scanResumeRange returns the terms of the range scan, defaulting them in the same way as the scan itself does.
+func scanResumeRange(scan RangeScan) (ScanTerm, ScanTerm) {
+	from := ScanTermMinimum()
+	if scan.From != nil {
+		from = scan.From
+	}
+	to := ScanTermMaximum()
+	if scan.To != nil {
+		to = scan.To
+	}
+
+	return *from, *to
+}
+
+// validate checks that the token was created by a scan of the same range of the same collection.
+func (t *ScanResumeToken) validate(c *Collection, scan RangeScan) error {
+	if t.bucketName != c.bucketName() || t.scopeName != c.ScopeName() || t.collectionName != c.Name() {
+		return makeInvalidArgumentsError("resume token was created by a scan of a different collection")
+	}
+
+	from, to := scanResumeRange(scan)
+	if t.from != from || t.to != to {
+		return makeInvalidArgumentsError("resume token was created by a scan of a different range")
+	}
+
+	return nil
+}
+
+// complete returns whether the vbucket was fully scanned.
+func (t *ScanResumeToken) complete(vbID uint16) bool {
+	return t != nil && t.vbuckets[vbID].complete
+}
+
+// vbucketProgress returns the progress of each vbucket, nil if there is no token.
+func (t *ScanResumeToken) vbucketProgress() map[uint16]scanVbucketProgress {
+	if t == nil {
+		return nil
+	}
+
+	return t.vbuckets
+}
+
+// lastKey returns the last key of the vbucket which was returned by the scan, nil if no keys were returned.
+func (t *ScanResumeToken) lastKey(vbID uint16) []byte {
+	if t == nil {
+		return nil
+	}
+
+	return t.vbuckets[vbID].lastKey
+}
+
+// ScanProgress describes how far a scan has progressed.
+// UNCOMMITTED: This API may change in the future.
+type ScanProgress struct {
+	// VbucketsCompleted is the number of vbuckets whose items have all been returned, including any which were
+	// completed by the scan that the scan was resumed from.
+	VbucketsCompleted int
+	VbucketsTotal     int
+
+	// ItemsStreamed and BytesStreamed are the number of items, and the bytes of their keys and contents, that have
+	// been returned by this scan.
+	ItemsStreamed uint64
+	BytesStreamed uint64
+}
+
+type scanVbucketState struct {
+	produced uint64
+	consumed uint64
+	scanned  bool
+	lastKey  []byte
+}
+
+func (s *scanVbucketState) complete() bool {
+	return s.scanned && s.consumed == s.produced
+}
+
+// scanProgressTracker tracks the items of each vbucket sent by the scan and returned to the application, a vbucket is
+// only complete once every item of it has been returned.
+type scanProgressTracker struct {
+	lock sync.Mutex
+
+	// token is nil for sampling scans, which cannot be resumed.
+	token       *ScanResumeToken
+	numVbuckets int
+	vbuckets    map[uint16]*scanVbucketState
+
+	items uint64
+	bytes uint64
+}
+
+func newScanProgressTracker(token *ScanResumeToken, numVbuckets int, resumeFrom *ScanResumeToken) *scanProgressTracker {
+	t := &scanProgressTracker{
+		token:       token,
+		numVbuckets: numVbuckets,
+		vbuckets:    make(map[uint16]*scanVbucketState),
+	}
+	if resumeFrom != nil {
+		for vbID, progress := range resumeFrom.vbuckets {
+			if progress.complete || len(progress.lastKey) > 0 {
+				t.vbuckets[vbID] = &scanVbucketState{
+					scanned: progress.complete,
+					lastKey: progress.lastKey,
+				}
+			}
+		}
+	}
+
+	return t
+}
+
+func (t *scanProgressTracker) vbucket(vbID uint16) *scanVbucketState {
+	state, ok := t.vbuckets[vbID]
+	if !ok {
+		state = &scanVbucketState{}
+		t.vbuckets[vbID] = state
+	}
+
+	return state
+}
+
+// itemProduced must be called before an item of the vbucket is sent to the result.
+func (t *scanProgressTracker) itemProduced(vbID uint16) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	t.vbucket(vbID).produced++
+	t.lock.Unlock()
+}
+
+// partitionScanned is called once every item of the vbucket has been sent to the result.
+func (t *scanProgressTracker) partitionScanned(vbID uint16) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	t.vbucket(vbID).scanned = true
+	t.lock.Unlock()
+}
+
+// itemConsumed is called when an item is returned to the application.
+func (t *scanProgressTracker) itemConsumed(item *ScanResultItem) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	state := t.vbucket(item.vbID)
+	state.consumed++
+	state.lastKey = []byte(item.id)
+	t.items++
+	t.bytes += uint64(len(item.id) + len(item.contents))
+	t.lock.Unlock()
+}
+
+func (t *scanProgressTracker) progress() ScanProgress {
+	if t == nil {
+		return ScanProgress{}
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	progress := ScanProgress{
+		VbucketsTotal: t.numVbuckets,
+		ItemsStreamed: t.items,
+		BytesStreamed: t.bytes,
+	}
+	for _, state := range t.vbuckets {
+		if state.complete() {
+			progress.VbucketsCompleted++
+		}
+	}
+
+	return progress
+}
+
+func (t *scanProgressTracker) resumeToken() *ScanResumeToken {
+	if t == nil || t.token == nil {
+		return nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	token := *t.token
+	token.vbuckets = make(map[uint16]scanVbucketProgress, len(t.vbuckets))
+	for vbID, progress := range t.token.vbuckets {
+		token.vbuckets[vbID] = progress
+	}
+	for vbID, state := range t.vbuckets {
+		progress := token.vbuckets[vbID]
+		if state.complete() {
+			progress.complete = true
+		} else if len(state.lastKey) > 0 {
+			progress.lastKey = state.lastKey
+		} else {
+			continue
+		}
+		token.vbuckets[vbID] = progress
+	}
+
+	return &token
+}
@@ -0,0 +1,494 @@
+package gocb
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const repositoryTagName = "gocb"
+
+// repositoryZeroTimeJSON is the JSON of the zero time.Time.
+const repositoryZeroTimeJSON = `"0001-01-01T00:00:00Z"`
+
+// Repository stores entities of type T, which must be a struct, as documents in a collection.
+//
+// The fields of T which have a special meaning are marked with a gocb struct tag:
+//
+//	type User struct {
+//		ID        string     `json:"-" gocb:"id,prefix=user::"`
+//		Cas       gocb.Cas   `json:"-" gocb:"cas"`
+//		Email     string     `json:"email"`
+//		CreatedAt time.Time  `json:"createdAt" gocb:"createdAt"`
+//		UpdatedAt time.Time  `json:"updatedAt" gocb:"updatedAt"`
+//		DeletedAt *time.Time `json:"deletedAt,omitempty" gocb:"deletedAt"`
+//	}
+//
+// id marks the string field holding the ID of the entity, which is required. The ID of its document is the ID of the
+// entity with the prefix given to the tag, if any, in front of it. A new ID is generated when saving an entity with
+// an empty ID.
+//
+// cas marks a Cas field which enables optimistic locking: an entity with no CAS is inserted, failing if it already
+// exists, and one with a CAS is only replaced if its document has not been changed since the entity was read.
+//
+// createdAt and updatedAt mark time.Time or *time.Time fields which are set when an entity is first saved, and on
+// every save, respectively.
+//
+// deletedAt marks a time.Time or *time.Time field which enables soft deletion: Delete sets the field rather than
+// removing the document, and entities which have been deleted are not returned by FindByID or FindAll.
+// UNCOMMITTED: This API may change in the future.
+type Repository[T any] struct {
+	collection *Collection
+
+	prefix    string
+	idField   []int
+	casField  []int
+	createdAt []int
+	updatedAt []int
+	deletedAt []int
+
+	// deletedAtName is the name of the deletedAt field in the JSON of the document.
+	deletedAtName string
+	// deletedAtIsValue is set when the deletedAt field is a time.Time rather than a *time.Time, which is always
+	// written to the document and so holds the zero time when the entity has not been deleted.
+	deletedAtIsValue bool
+}
+
+// NewRepository returns a new Repository for entities of type T stored in the collection. An error wrapping
+// ErrInvalidArgument is returned if T is not a struct or its gocb struct tags are invalid.
+// UNCOMMITTED: This API may change in the future.
+func NewRepository[T any](c *Collection) (*Repository[T], error) {
+	r := &Repository[T]{
+		collection: c,
+	}
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return nil, makeInvalidArgumentsError(fmt.Sprintf("repository type %s must be a struct", typ))
+	}
+
+	timeType := reflect.TypeOf(time.Time{})
+	isTime := func(t reflect.Type) bool {
+		return t == timeType || (t.Kind() == reflect.Pointer && t.Elem() == timeType)
+	}
+
+	for _, field := range reflect.VisibleFields(typ) {
+		tag, ok := field.Tag.Lookup(repositoryTagName)
+		if !ok || !field.IsExported() {
+			continue
+		}
+		if !repositoryFieldReachable(typ, field.Index) {
+			return nil, makeInvalidArgumentsError(fmt.Sprintf("repository field %s cannot be within an embedded "+
+				"pointer", field.Name))
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		switch name {
+		case "id":
+			if field.Type.Kind() != reflect.String {
+				return nil, makeInvalidArgumentsError(fmt.Sprintf("repository id field %s must be a string", field.Name))
+			}
+			if options != "" {
+				if !strings.HasPrefix(options, "prefix=") {
+					return nil, makeInvalidArgumentsError(fmt.Sprintf("unknown option %q on repository id field %s",
+						options, field.Name))
+				}
+				r.prefix = strings.TrimPrefix(options, "prefix=")
+			}
+			r.idField = field.Index
+		case "cas":
+			if field.Type != reflect.TypeOf(Cas(0)) {
+				return nil, makeInvalidArgumentsError(fmt.Sprintf("repository cas field %s must be a Cas", field.Name))
+			}
+			r.casField = field.Index
+		case "createdAt", "updatedAt", "deletedAt":
+			if !isTime(field.Type) {
+				return nil, makeInvalidArgumentsError(fmt.Sprintf("repository %s field %s must be a time.Time or "+
+					"*time.Time", name, field.Name))
+			}
+			switch name {
+			case "createdAt":
+				r.createdAt = field.Index
+			case "updatedAt":
+				r.updatedAt = field.Index
+			default:
+				r.deletedAt = field.Index
+				r.deletedAtName = repositoryJSONName(field)
+				r.deletedAtIsValue = field.Type.Kind() != reflect.Pointer
+				if r.deletedAtName == "" {
+					return nil, makeInvalidArgumentsError(fmt.Sprintf("repository deletedAt field %s must be "+
+						"stored in the document", field.Name))
+				}
+			}
+		default:
+			return nil, makeInvalidArgumentsError(fmt.Sprintf("unknown repository tag %q on field %s", name,
+				field.Name))
+		}
+	}
+
+	if r.idField == nil {
+		return nil, makeInvalidArgumentsError(fmt.Sprintf("repository type %s must have a field tagged gocb:\"id\"",
+			typ))
+	}
+
+	return r, nil
+}
+
+// repositoryFieldReachable returns whether a field can be reached without following an embedded pointer, which
+// could be nil.
+func repositoryFieldReachable(typ reflect.Type, index []int) bool {
+	for _, i := range index[:len(index)-1] {
+		typ = typ.Field(i).Type
+		if typ.Kind() != reflect.Struct {
+			return false
+		}
+	}
+
+	return true
+}
+
+// repositoryJSONName returns the name that encoding/json gives to a field, or an empty string if it is not encoded.
+func repositoryJSONName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+// DocumentID returns the ID of the document which stores the entity with the given ID.
+func (r *Repository[T]) DocumentID(id string) string {
+	return r.prefix + id
+}
+
+// Save writes the entity, setting its timestamps, its CAS if it has a cas field, and its ID if that was empty.
+// Should the entity have a CAS and its document have been changed since it was read, then an error wrapping
+// ErrCasMismatch is returned. Should it have no CAS and a document with its ID already exist, then an error wrapping
+// ErrDocumentExists is returned. The entity is left unchanged if an error is returned, other than a generated ID.
+func (r *Repository[T]) Save(entity *T) error {
+	if entity == nil {
+		return makeInvalidArgumentsError("entity cannot be nil")
+	}
+
+	v := reflect.ValueOf(entity).Elem()
+	idValue := v.FieldByIndex(r.idField)
+	if idValue.String() == "" {
+		idValue.SetString(uuid.NewString())
+	}
+	docID := r.DocumentID(idValue.String())
+
+	now := time.Now().UTC()
+	restore := r.setTimestamps(v, now)
+
+	var res *MutationResult
+	var err error
+	switch {
+	case r.casField == nil:
+		res, err = r.collection.Upsert(docID, entity, nil)
+	case Cas(v.FieldByIndex(r.casField).Uint()) == 0:
+		res, err = r.collection.Insert(docID, entity, nil)
+	default:
+		res, err = r.collection.Replace(docID, entity, &ReplaceOptions{
+			Cas: Cas(v.FieldByIndex(r.casField).Uint()),
+		})
+	}
+	if err != nil {
+		restore()
+		return err
+	}
+
+	if r.casField != nil {
+		v.FieldByIndex(r.casField).SetUint(uint64(res.Cas()))
+	}
+
+	return nil
+}
+
+// setTimestamps sets the createdAt and updatedAt fields of an entity, returning a function which restores them.
+func (r *Repository[T]) setTimestamps(v reflect.Value, now time.Time) func() {
+	var restores []func()
+	set := func(index []int, onlyIfZero bool) {
+		if index == nil {
+			return
+		}
+
+		field := v.FieldByIndex(index)
+		if onlyIfZero && !repositoryTimeIsZero(field) {
+			return
+		}
+
+		old := reflect.New(field.Type()).Elem()
+		old.Set(field)
+		restores = append(restores, func() {
+			field.Set(old)
+		})
+		repositorySetTime(field, now)
+	}
+
+	set(r.createdAt, true)
+	set(r.updatedAt, false)
+
+	return func() {
+		for _, restore := range restores {
+			restore()
+		}
+	}
+}
+
+func repositoryTimeIsZero(field reflect.Value) bool {
+	if field.Kind() == reflect.Pointer {
+		return field.IsNil() || field.Elem().Interface().(time.Time).IsZero()
+	}
+
+	return field.Interface().(time.Time).IsZero()
+}
+
+func repositorySetTime(field reflect.Value, t time.Time) {
+	if field.Kind() == reflect.Pointer {
+		field.Set(reflect.ValueOf(&t))
+		return
+	}
+
+	field.Set(reflect.ValueOf(t))
+}
+
+// FindByID fetches the entity with the given ID. An error wrapping ErrDocumentNotFound is returned if there is no
+// such entity, or if it has been soft deleted.
+func (r *Repository[T]) FindByID(id string) (*T, error) {
+	res, err := r.collection.Get(r.DocumentID(id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var entity T
+	if err := res.Content(&entity); err != nil {
+		return nil, err
+	}
+
+	v := reflect.ValueOf(&entity).Elem()
+	if r.deleted(v) {
+		return nil, wrapError(ErrDocumentNotFound, fmt.Sprintf("entity %s has been deleted", id))
+	}
+	r.setMeta(v, id, res.Cas())
+
+	return &entity, nil
+}
+
+func (r *Repository[T]) deleted(v reflect.Value) bool {
+	return r.deletedAt != nil && !repositoryTimeIsZero(v.FieldByIndex(r.deletedAt))
+}
+
+func (r *Repository[T]) setMeta(v reflect.Value, id string, cas Cas) {
+	v.FieldByIndex(r.idField).SetString(id)
+	if r.casField != nil {
+		v.FieldByIndex(r.casField).SetUint(uint64(cas))
+	}
+}
+
+// Delete deletes the entity, using its CAS if it has a cas field so that an entity which has been changed since it
+// was read is not deleted. If T has a deletedAt field then the entity is soft deleted by setting that field and
+// saving it, otherwise its document is removed.
+func (r *Repository[T]) Delete(entity *T) error {
+	if entity == nil {
+		return makeInvalidArgumentsError("entity cannot be nil")
+	}
+
+	v := reflect.ValueOf(entity).Elem()
+	if r.deletedAt == nil {
+		return r.remove(v)
+	}
+
+	field := v.FieldByIndex(r.deletedAt)
+	old := reflect.New(field.Type()).Elem()
+	old.Set(field)
+	repositorySetTime(field, time.Now().UTC())
+
+	// Soft deleting an entity which has never been saved would insert it.
+	if r.casField != nil && v.FieldByIndex(r.casField).Uint() == 0 {
+		field.Set(old)
+		return makeInvalidArgumentsError("entity must have been read or saved before it can be soft deleted")
+	}
+
+	if err := r.Save(entity); err != nil {
+		field.Set(old)
+		return err
+	}
+
+	return nil
+}
+
+// Purge removes the document of the entity, even if T has a deletedAt field.
+func (r *Repository[T]) Purge(entity *T) error {
+	if entity == nil {
+		return makeInvalidArgumentsError("entity cannot be nil")
+	}
+
+	return r.remove(reflect.ValueOf(entity).Elem())
+}
+
+func (r *Repository[T]) remove(v reflect.Value) error {
+	opts := &RemoveOptions{}
+	if r.casField != nil {
+		opts.Cas = Cas(v.FieldByIndex(r.casField).Uint())
+	}
+
+	_, err := r.collection.Remove(r.DocumentID(v.FieldByIndex(r.idField).String()), opts)
+	return err
+}
+
+// RepositoryQuery selects the entities returned by FindAll.
+// UNCOMMITTED: This API may change in the future.
+type RepositoryQuery struct {
+	// Where is a N1QL condition on the fields of the documents, which can refer to Args as $1, $2 and so on.
+	Where string
+	Args  []interface{}
+
+	// OrderBy is a N1QL ordering, such as "name ASC".
+	OrderBy string
+	Limit   uint32
+	Offset  uint32
+
+	// IncludeDeleted includes entities which have been soft deleted.
+	IncludeDeleted bool
+
+	// QueryOptions are the options of the query. Any positional parameters are replaced by Args.
+	QueryOptions *QueryOptions
+}
+
+// FindAll fetches the entities selected by the query, or every entity if it is nil, using a N1QL query against the
+// collection. Only documents whose IDs start with the prefix of the repository are returned.
+func (r *Repository[T]) FindAll(query *RepositoryQuery) ([]*T, error) {
+	if query == nil {
+		query = &RepositoryQuery{}
+	}
+
+	statement, args := r.findAllStatement(query)
+
+	var opts QueryOptions
+	if query.QueryOptions != nil {
+		opts = *query.QueryOptions
+	}
+	opts.PositionalParameters = args
+
+	res, err := r.collection.bucket.Scope(r.collection.ScopeName()).Query(statement, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	entities := []*T{}
+	for res.Next() {
+		var meta struct {
+			ID  string `json:"__id"`
+			Cas uint64 `json:"__cas"`
+		}
+		var row json.RawMessage
+		if err := res.Row(&row); err != nil {
+			_ = res.Close()
+			return nil, err
+		}
+		if err := json.Unmarshal(row, &meta); err != nil {
+			_ = res.Close()
+			return nil, err
+		}
+
+		var entity T
+		if err := json.Unmarshal(row, &entity); err != nil {
+			_ = res.Close()
+			return nil, err
+		}
+		r.setMeta(reflect.ValueOf(&entity).Elem(), strings.TrimPrefix(meta.ID, r.prefix), Cas(meta.Cas))
+		entities = append(entities, &entity)
+	}
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+// FindBy fetches the entities whose field, given as a path such as "address.city", has the given value.
+func (r *Repository[T]) FindBy(field string, value interface{}) ([]*T, error) {
+	if field == "" {
+		return nil, makeInvalidArgumentsError("field cannot be empty")
+	}
+
+	return r.FindAll(&RepositoryQuery{
+		Where: repositoryFieldPath(field) + " = $1",
+		Args:  []interface{}{value},
+	})
+}
+
+func (r *Repository[T]) findAllStatement(query *RepositoryQuery) (string, []interface{}) {
+	keyspace := repositoryEscape(r.collection.Name())
+	args := append([]interface{}{}, query.Args...)
+
+	var conditions []string
+	if query.Where != "" {
+		conditions = append(conditions, "("+query.Where+")")
+	}
+	if r.prefix != "" {
+		args = append(args, repositoryEscapeLike(r.prefix)+"%")
+		conditions = append(conditions, "META().id LIKE $"+strconv.Itoa(len(args)))
+	}
+	if r.deletedAt != nil && !query.IncludeDeleted {
+		deletedAt := repositoryEscape(r.deletedAtName)
+		if r.deletedAtIsValue {
+			conditions = append(conditions, "("+deletedAt+" IS NOT VALUED OR "+deletedAt+" = "+
+				repositoryZeroTimeJSON+")")
+		} else {
+			conditions = append(conditions, deletedAt+" IS NOT VALUED")
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT META().id AS `__id`, META().cas AS `__cas`, ")
+	sb.WriteString(keyspace)
+	sb.WriteString(".* FROM ")
+	sb.WriteString(keyspace)
+	if len(conditions) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(conditions, " AND "))
+	}
+	if query.OrderBy != "" {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(query.OrderBy)
+	}
+	if query.Limit > 0 {
+		sb.WriteString(" LIMIT ")
+		sb.WriteString(strconv.FormatUint(uint64(query.Limit), 10))
+	}
+	if query.Offset > 0 {
+		sb.WriteString(" OFFSET ")
+		sb.WriteString(strconv.FormatUint(uint64(query.Offset), 10))
+	}
+
+	return sb.String(), args
+}
+
+func repositoryEscape(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+func repositoryFieldPath(path string) string {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		parts[i] = repositoryEscape(part)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+func repositoryEscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,261 @@
+package gocb
+
+import (
+	"encoding/json"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v10"
+	"github.com/stretchr/testify/mock"
+)
+
+type testRepositoryUser struct {
+	ID        string     `json:"-" gocb:"id,prefix=user::"`
+	Cas       Cas        `json:"-" gocb:"cas"`
+	Name      string     `json:"name"`
+	City      string     `json:"city"`
+	CreatedAt time.Time  `json:"createdAt" gocb:"createdAt"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty" gocb:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty" gocb:"deletedAt"`
+}
+
+type testRepositoryTask struct {
+	ID        string    `json:"-" gocb:"id,prefix=task::"`
+	Cas       Cas       `json:"-" gocb:"cas"`
+	Title     string    `json:"title"`
+	DeletedAt time.Time `json:"deletedAt" gocb:"deletedAt"`
+}
+
+type testRepositoryNote struct {
+	Key  string `json:"key" gocb:"id"`
+	Text string `json:"text"`
+}
+
+func (suite *UnitTestSuite) TestRepositoryTags() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	_, err := NewRepository[string](col)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = NewRepository[struct {
+		Name string
+	}](col)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = NewRepository[struct {
+		ID int `gocb:"id"`
+	}](col)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = NewRepository[struct {
+		ID  string `gocb:"id"`
+		Cas uint64 `gocb:"cas"`
+	}](col)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = NewRepository[struct {
+		ID      string    `gocb:"id"`
+		Deleted time.Time `json:"-" gocb:"deletedAt"`
+	}](col)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = NewRepository[struct {
+		ID string `gocb:"id,suffix=x"`
+	}](col)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	repo, err := NewRepository[testRepositoryUser](col)
+	suite.Require().NoError(err)
+	suite.Assert().Equal("user::alice", repo.DocumentID("alice"))
+}
+
+func (suite *UnitTestSuite) TestRepositorySaveFindDelete() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	repo, err := NewRepository[testRepositoryUser](col)
+	suite.Require().NoError(err)
+
+	user := &testRepositoryUser{ID: "alice", Name: "Alice", City: "london"}
+	suite.Require().NoError(repo.Save(user))
+	suite.Assert().NotZero(user.Cas)
+	suite.Assert().False(user.CreatedAt.IsZero())
+	suite.Require().NotNil(user.UpdatedAt)
+	suite.Assert().Equal(user.CreatedAt, *user.UpdatedAt)
+
+	var raw map[string]interface{}
+	res, err := col.Get("user::alice", nil)
+	suite.Require().NoError(err)
+	suite.Require().NoError(res.Content(&raw))
+	suite.Assert().Equal("Alice", raw["name"])
+	suite.Assert().NotContains(raw, "ID")
+
+	// Saving a new entity with the same ID fails rather than overwriting the existing one.
+	err = repo.Save(&testRepositoryUser{ID: "alice", Name: "Imposter"})
+	suite.Assert().ErrorIs(err, ErrDocumentExists)
+
+	found, err := repo.FindByID("alice")
+	suite.Require().NoError(err)
+	suite.Assert().Equal("alice", found.ID)
+	suite.Assert().Equal(user.Cas, found.Cas)
+	suite.Assert().Equal("london", found.City)
+	suite.Assert().True(user.CreatedAt.Equal(found.CreatedAt))
+
+	// A stale copy cannot overwrite a newer version of the entity, and is left unchanged by the failed save.
+	stale := *found
+	found.City = "paris"
+	suite.Require().NoError(repo.Save(found))
+	suite.Assert().NotEqual(stale.Cas, found.Cas)
+
+	staleUpdatedAt := stale.UpdatedAt
+	stale.City = "rome"
+	err = repo.Save(&stale)
+	suite.Assert().ErrorIs(err, ErrCasMismatch)
+	suite.Assert().Same(staleUpdatedAt, stale.UpdatedAt)
+	suite.Assert().ErrorIs(repo.Delete(&stale), ErrCasMismatch)
+	suite.Assert().Nil(stale.DeletedAt)
+
+	suite.Require().NoError(repo.Delete(found))
+	suite.Assert().NotNil(found.DeletedAt)
+	_, err = repo.FindByID("alice")
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+
+	// Soft deletion keeps the document around until it is purged.
+	_, err = col.Get("user::alice", nil)
+	suite.Require().NoError(err)
+	suite.Require().NoError(repo.Purge(found))
+	_, err = col.Get("user::alice", nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+
+	suite.Assert().ErrorIs(repo.Delete(&testRepositoryUser{ID: "bob"}), ErrInvalidArgument)
+	suite.Assert().ErrorIs(repo.Save(nil), ErrInvalidArgument)
+
+	// Without a cas field entities are upserted, and without a deletedAt field they are removed.
+	notes, err := NewRepository[testRepositoryNote](col)
+	suite.Require().NoError(err)
+
+	note := &testRepositoryNote{Text: "first"}
+	suite.Require().NoError(notes.Save(note))
+	suite.Assert().NotEmpty(note.Key)
+	suite.Require().NoError(notes.Save(&testRepositoryNote{Key: note.Key, Text: "second"}))
+
+	foundNote, err := notes.FindByID(note.Key)
+	suite.Require().NoError(err)
+	suite.Assert().Equal("second", foundNote.Text)
+
+	suite.Require().NoError(notes.Delete(foundNote))
+	_, err = col.Get(note.Key, nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+}
+
+func (suite *UnitTestSuite) TestRepositoryFindAllStatement() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	repo, err := NewRepository[testRepositoryUser](col)
+	suite.Require().NoError(err)
+
+	statement, args := repo.findAllStatement(&RepositoryQuery{})
+	suite.Assert().Equal("SELECT META().id AS `__id`, META().cas AS `__cas`, `_default`.* FROM `_default` "+
+		"WHERE META().id LIKE $1 AND `deletedAt` IS NOT VALUED", statement)
+	suite.Assert().Equal([]interface{}{"user::%"}, args)
+
+	statement, args = repo.findAllStatement(&RepositoryQuery{
+		Where:          "city = $1 AND age > $2",
+		Args:           []interface{}{"london", 30},
+		OrderBy:        "name",
+		Limit:          10,
+		Offset:         20,
+		IncludeDeleted: true,
+	})
+	suite.Assert().Equal("SELECT META().id AS `__id`, META().cas AS `__cas`, `_default`.* FROM `_default` "+
+		"WHERE (city = $1 AND age > $2) AND META().id LIKE $3 ORDER BY name LIMIT 10 OFFSET 20", statement)
+	suite.Assert().Equal([]interface{}{"london", 30, "user::%"}, args)
+
+	notes, err := NewRepository[testRepositoryNote](col)
+	suite.Require().NoError(err)
+	statement, args = notes.findAllStatement(&RepositoryQuery{})
+	suite.Assert().Equal("SELECT META().id AS `__id`, META().cas AS `__cas`, `_default`.* FROM `_default`", statement)
+	suite.Assert().Empty(args)
+
+	suite.Assert().Equal(`a\%b\_c\\`, repositoryEscapeLike(`a%b_c\`))
+	suite.Assert().Equal("`address`.`ci``ty`", repositoryFieldPath("address.ci`ty"))
+}
+
+func (suite *UnitTestSuite) TestRepositoryTimeDeletedAt() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	// A time.Time deletedAt field holds the zero time, rather than being missing, when the entity is not deleted.
+	tasks, err := NewRepository[testRepositoryTask](col)
+	suite.Require().NoError(err)
+	statement, _ := tasks.findAllStatement(&RepositoryQuery{})
+	suite.Assert().Equal("SELECT META().id AS `__id`, META().cas AS `__cas`, `_default`.* FROM `_default` "+
+		"WHERE META().id LIKE $1 AND (`deletedAt` IS NOT VALUED OR `deletedAt` = \"0001-01-01T00:00:00Z\")",
+		statement)
+
+	task := &testRepositoryTask{ID: "1", Title: "write tests"}
+	suite.Require().NoError(tasks.Save(task))
+	var raw map[string]interface{}
+	res, err := col.Get("task::1", nil)
+	suite.Require().NoError(err)
+	suite.Require().NoError(res.Content(&raw))
+	suite.Assert().Equal("0001-01-01T00:00:00Z", raw["deletedAt"])
+
+	_, err = tasks.FindByID("1")
+	suite.Require().NoError(err)
+	suite.Require().NoError(tasks.Delete(task))
+	suite.Assert().False(task.DeletedAt.IsZero())
+	_, err = tasks.FindByID("1")
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+}
+
+// repositoryRowReader is a query row reader returning arbitrary rows.
+type repositoryRowReader struct {
+	rows []interface{}
+	mockQueryRowReaderBase
+}
+
+func (r *repositoryRowReader) NextRow() []byte {
+	if r.idx == len(r.rows) {
+		return nil
+	}
+
+	r.idx++
+	return r.Suite.mustConvertToBytes(r.rows[r.idx-1])
+}
+
+func (suite *UnitTestSuite) TestRepositoryFindBy() {
+	reader := &repositoryRowReader{
+		rows: []interface{}{
+			map[string]interface{}{"__id": "user::alice", "__cas": uint64(1 << 60), "name": "Alice", "city": "paris"},
+			map[string]interface{}{"__id": "user::bob", "__cas": 42, "name": "Bob", "city": "paris"},
+		},
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			Meta:  []byte(`{"requestID": "1"}`),
+			Suite: suite,
+		},
+	}
+
+	scope := suite.queryScope(true, reader, func(args mock.Arguments) {
+		opts := args.Get(1).(gocbcore.N1QLQueryOptions)
+
+		var payload map[string]interface{}
+		suite.Require().NoError(json.Unmarshal(opts.Payload, &payload))
+		suite.Assert().Equal("SELECT META().id AS `__id`, META().cas AS `__cas`, `users`.* FROM `users` "+
+			"WHERE (`city` = $1) AND META().id LIKE $2 AND `deletedAt` IS NOT VALUED", payload["statement"])
+		suite.Assert().Equal([]interface{}{"paris", "user::%"}, payload["args"])
+	})
+
+	repo, err := NewRepository[testRepositoryUser](scope.Collection("users"))
+	suite.Require().NoError(err)
+
+	users, err := repo.FindBy("city", "paris")
+	suite.Require().NoError(err)
+	suite.Require().Len(users, 2)
+	suite.Assert().Equal("alice", users[0].ID)
+	suite.Assert().Equal(Cas(1<<60), users[0].Cas)
+	suite.Assert().Equal("Alice", users[0].Name)
+	suite.Assert().Equal("bob", users[1].ID)
+	suite.Assert().Equal(Cas(42), users[1].Cas)
+}
@@ -0,0 +1,319 @@
+package gocb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// subdocMaxSpecs is the maximum number of specs that the server accepts in a single subdocument operation.
+const subdocMaxSpecs = 16
+
+// subdocPathKey returns a subdocument path element for a field name, escaping it if required.
+func subdocPathKey(key string) string {
+	if !strings.ContainsAny(key, ".[]`") {
+		return key
+	}
+
+	return "`" + strings.ReplaceAll(key, "`", "``") + "`"
+}
+
+func subdocPathJoin(parent, key string) string {
+	if parent == "" {
+		return subdocPathKey(key)
+	}
+
+	return parent + "." + subdocPathKey(key)
+}
+
+// subdocStructFieldNames returns the names that encoding/json uses for the fields of a struct type, including those
+// of embedded structs.
+func subdocStructFieldNames(t reflect.Type, names []string, seen map[string]struct{}) []string {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+
+			if fieldType.Kind() == reflect.Struct {
+				names = subdocStructFieldNames(fieldType, names, seen)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func subdocStructFields(valuePtr interface{}) ([]string, error) {
+	t := reflect.TypeOf(valuePtr)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil, makeInvalidArgumentsError("value must be a pointer to a struct")
+	}
+
+	return subdocStructFieldNames(t.Elem(), nil, make(map[string]struct{})), nil
+}
+
+// LookupInSpecsFromStruct returns a GetSpec for each field of the struct pointed to by valuePtr, using the names
+// from the json tags of the fields. Fields of embedded structs are treated as fields of the outer struct, as they
+// are by encoding/json.
+// UNCOMMITTED: This API may change in the future.
+func LookupInSpecsFromStruct(valuePtr interface{}) ([]LookupInSpec, error) {
+	names, err := subdocStructFields(valuePtr)
+	if err != nil {
+		return nil, err
+	}
+
+	specs := make([]LookupInSpec, len(names))
+	for i, name := range names {
+		specs[i] = GetSpec(subdocPathKey(name), nil)
+	}
+
+	return specs, nil
+}
+
+// LookupInStruct fetches only the fields of the document identified by id which are present in the struct pointed
+// to by valuePtr, as named by the json tags of the fields, and populates the struct with them. Fields which are not
+// present in the document are left untouched. If the struct has more fields than can be fetched in a single
+// operation then the whole document is fetched instead.
+// UNCOMMITTED: This API may change in the future.
+func (c *Collection) LookupInStruct(id string, valuePtr interface{}, opts *LookupInOptions) (*LookupInResult, error) {
+	names, err := subdocStructFields(valuePtr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) > subdocMaxSpecs {
+		res, err := c.LookupIn(id, []LookupInSpec{GetSpec("", nil)}, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return res, res.ContentAt(0, valuePtr)
+	}
+
+	specs := make([]LookupInSpec, len(names))
+	for i, name := range names {
+		specs[i] = GetSpec(subdocPathKey(name), nil)
+	}
+
+	res, err := c.LookupIn(id, specs, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage, len(names))
+	for i, name := range names {
+		partial := res.contents[i]
+		if partial.err != nil {
+			if errors.Is(partial.err, ErrPathNotFound) {
+				continue
+			}
+
+			return nil, partial.err
+		}
+
+		fields[name] = partial.data
+	}
+
+	// Reassembling the fields into an object lets encoding/json apply the same tag handling as it does to a
+	// whole document.
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, valuePtr); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func subdocDecodeJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
+
+// MutateInSpecsFromDiff returns the specs required to change a document from oldValue to newValue, both of which
+// must encode to JSON objects. Fields which are added or changed are upserted, fields which are no longer present are
+// removed and arrays which have only had elements added to their end are appended to.
+// UNCOMMITTED: This API may change in the future.
+func MutateInSpecsFromDiff(oldValue, newValue interface{}) ([]MutateInSpec, error) {
+	oldDecoded, err := subdocDecodeJSON(oldValue)
+	if err != nil {
+		return nil, err
+	}
+
+	newDecoded, err := subdocDecodeJSON(newValue)
+	if err != nil {
+		return nil, err
+	}
+
+	oldObject, ok := oldDecoded.(map[string]interface{})
+	if !ok {
+		return nil, makeInvalidArgumentsError("old value must encode to a JSON object")
+	}
+
+	newObject, ok := newDecoded.(map[string]interface{})
+	if !ok {
+		return nil, makeInvalidArgumentsError("new value must encode to a JSON object")
+	}
+
+	return subdocDiffObjects(nil, "", oldObject, newObject), nil
+}
+
+func subdocDiffObjects(specs []MutateInSpec, path string, oldObject, newObject map[string]interface{}) []MutateInSpec {
+	keys := make([]string, 0, len(oldObject)+len(newObject))
+	for key := range oldObject {
+		keys = append(keys, key)
+	}
+	for key := range newObject {
+		if _, ok := oldObject[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := subdocPathJoin(path, key)
+		oldField, inOld := oldObject[key]
+		newField, inNew := newObject[key]
+
+		switch {
+		case !inNew:
+			specs = append(specs, RemoveSpec(keyPath, nil))
+		case !inOld:
+			specs = append(specs, UpsertSpec(keyPath, newField, nil))
+		default:
+			specs = subdocDiffValues(specs, keyPath, oldField, newField, false)
+		}
+	}
+
+	return specs
+}
+
+// subdocDiffValues appends the specs required to change the value at path, inArray indicates that path refers to an
+// element of an array rather than a field of an object.
+func subdocDiffValues(specs []MutateInSpec, path string, oldValue, newValue interface{}, inArray bool) []MutateInSpec {
+	if reflect.DeepEqual(oldValue, newValue) {
+		return specs
+	}
+
+	switch newTyped := newValue.(type) {
+	case map[string]interface{}:
+		if oldTyped, ok := oldValue.(map[string]interface{}); ok {
+			return subdocDiffObjects(specs, path, oldTyped, newTyped)
+		}
+	case []interface{}:
+		if oldTyped, ok := oldValue.([]interface{}); ok {
+			if len(newTyped) > len(oldTyped) && reflect.DeepEqual(oldTyped, newTyped[:len(oldTyped)]) {
+				added := newTyped[len(oldTyped):]
+				if len(added) == 1 {
+					return append(specs, ArrayAppendSpec(path, added[0], nil))
+				}
+
+				return append(specs, ArrayAppendSpec(path, added, &ArrayAppendSpecOptions{HasMultiple: true}))
+			}
+
+			if len(newTyped) == len(oldTyped) {
+				for i := range newTyped {
+					specs = subdocDiffValues(specs, path+"["+strconv.Itoa(i)+"]", oldTyped[i], newTyped[i], true)
+				}
+
+				return specs
+			}
+		}
+	}
+
+	if inArray {
+		return append(specs, ReplaceSpec(path, newValue, nil))
+	}
+
+	return append(specs, UpsertSpec(path, newValue, nil))
+}
+
+// MutateInFromDiff changes the document identified by id from oldValue to newValue by mutating only the fields which
+// differ, using the specs from MutateInSpecsFromDiff. If more specs are required than the server accepts in a single
+// operation then the specs are split across several operations, each using the CAS from the one before, so that the
+// document cannot be changed by anything else in between. The operations are not atomic as a whole, should one fail
+// then the changes made by those before it remain. The result is that of the final operation, or nil if the values
+// do not differ and so no operation was performed.
+// UNCOMMITTED: This API may change in the future.
+func (c *Collection) MutateInFromDiff(id string, oldValue, newValue interface{}, opts *MutateInOptions) (*MutateInResult, error) {
+	if opts == nil {
+		opts = &MutateInOptions{}
+	}
+
+	specs, err := MutateInSpecsFromDiff(oldValue, newValue)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *MutateInResult
+	chunkOpts := *opts
+	// A mutation resets the expiry of the document unless told to preserve it, which cannot be done when inserting.
+	if chunkOpts.Expiry == 0 && chunkOpts.StoreSemantic != StoreSemanticsInsert {
+		chunkOpts.PreserveExpiry = true
+	}
+	for len(specs) > 0 {
+		n := len(specs)
+		if n > subdocMaxSpecs {
+			n = subdocMaxSpecs
+		}
+
+		res, err = c.MutateIn(id, specs[:n], &chunkOpts)
+		if err != nil {
+			return nil, err
+		}
+		specs = specs[n:]
+
+		// The document must exist after the first operation, and any expiry has already been applied to it which
+		// later operations must preserve.
+		chunkOpts.Cas = res.Cas()
+		chunkOpts.StoreSemantic = StoreSemanticsReplace
+		chunkOpts.Expiry = 0
+		chunkOpts.PreserveExpiry = true
+	}
+
+	return res, nil
+}
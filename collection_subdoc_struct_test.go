@@ -0,0 +1,235 @@
+package gocb
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/couchbase/gocbcore/v10/memd"
+)
+
+type testSubdocAddress struct {
+	City string `json:"city"`
+}
+
+type testSubdocUser struct {
+	testSubdocAddress
+	Name     string   `json:"name"`
+	Age      int      `json:"age,omitempty"`
+	Tags     []string `json:"tags"`
+	Dotted   string   `json:"a.b"`
+	Untagged string
+	Ignored  string `json:"-"`
+	internal string
+}
+
+func (suite *UnitTestSuite) TestLookupInSpecsFromStruct() {
+	specs, err := LookupInSpecsFromStruct(&testSubdocUser{})
+	suite.Require().NoError(err)
+
+	var paths []string
+	for _, spec := range specs {
+		suite.Assert().Equal(memd.SubDocOpGet, spec.op)
+		paths = append(paths, spec.path)
+	}
+	suite.Assert().Equal([]string{"city", "name", "age", "tags", "`a.b`", "Untagged"}, paths)
+
+	_, err = LookupInSpecsFromStruct(testSubdocUser{})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = LookupInSpecsFromStruct(&map[string]interface{}{})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
+
+func (suite *UnitTestSuite) TestLookupInStruct() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	_, err := col.Upsert("user", map[string]interface{}{
+		"name":    "frank",
+		"city":    "london",
+		"a.b":     "dotted",
+		"tags":    []string{"x"},
+		"address": map[string]string{"line1": "unfetched"},
+	}, nil)
+	suite.Require().NoError(err)
+
+	user := testSubdocUser{Untagged: "untouched", Age: 7}
+	res, err := col.LookupInStruct("user", &user, nil)
+	suite.Require().NoError(err)
+	suite.Assert().NotZero(res.Cas())
+
+	suite.Assert().Equal(testSubdocUser{
+		testSubdocAddress: testSubdocAddress{City: "london"},
+		Name:              "frank",
+		Age:               7,
+		Tags:              []string{"x"},
+		Dotted:            "dotted",
+		Untagged:          "untouched",
+	}, user)
+
+	_, err = col.LookupInStruct("missing", &user, nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+}
+
+func (suite *UnitTestSuite) TestMutateInSpecsFromDiff() {
+	oldValue := map[string]interface{}{
+		"name":    "frank",
+		"removed": true,
+		"nested":  map[string]interface{}{"a": 1, "b": 2},
+		"list":    []int{1, 2},
+		"objects": []map[string]int{{"x": 1}, {"x": 2}},
+		"shrunk":  []int{1, 2, 3},
+		"scalars": []int{1, 2},
+		"typed":   "string",
+		"a.b":     1,
+	}
+	newValue := map[string]interface{}{
+		"name":    "frank",
+		"added":   "yes",
+		"nested":  map[string]interface{}{"a": 1, "b": 3},
+		"list":    []int{1, 2, 3, 4},
+		"objects": []map[string]int{{"x": 1}, {"x": 5}},
+		"shrunk":  []int{1},
+		"scalars": []int{1, 9},
+		"typed":   map[string]int{"now": 1},
+		"a.b":     2,
+	}
+
+	specs, err := MutateInSpecsFromDiff(oldValue, newValue)
+	suite.Require().NoError(err)
+
+	type specSummary struct {
+		op       memd.SubDocOpType
+		path     string
+		value    string
+		multiple bool
+	}
+	var summaries []specSummary
+	for _, spec := range specs {
+		var value string
+		if spec.value != nil {
+			value = string(suite.mustConvertToBytes(spec.value))
+		}
+		summaries = append(summaries, specSummary{op: spec.op, path: spec.path, value: value, multiple: spec.multiValue})
+	}
+
+	suite.Assert().Equal([]specSummary{
+		{op: memd.SubDocOpDictSet, path: "`a.b`", value: "2"},
+		{op: memd.SubDocOpDictSet, path: "added", value: `"yes"`},
+		{op: memd.SubDocOpArrayPushLast, path: "list", value: "[3,4]", multiple: true},
+		{op: memd.SubDocOpDictSet, path: "nested.b", value: "3"},
+		{op: memd.SubDocOpDictSet, path: "objects[1].x", value: "5"},
+		{op: memd.SubDocOpDelete, path: "removed"},
+		{op: memd.SubDocOpReplace, path: "scalars[1]", value: "9"},
+		{op: memd.SubDocOpDictSet, path: "shrunk", value: "[1]"},
+		{op: memd.SubDocOpDictSet, path: "typed", value: `{"now":1}`},
+	}, summaries)
+
+	specs, err = MutateInSpecsFromDiff(oldValue, oldValue)
+	suite.Require().NoError(err)
+	suite.Assert().Empty(specs)
+
+	_, err = MutateInSpecsFromDiff([]int{1}, oldValue)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
+
+func (suite *UnitTestSuite) TestMutateInFromDiff() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	oldValue := map[string]interface{}{"keep": "same", "list": []int{1}}
+	_, err := col.Upsert("doc", oldValue, nil)
+	suite.Require().NoError(err)
+
+	newValue := map[string]interface{}{"keep": "same", "list": []int{1, 2}}
+	for i := 0; i < 40; i++ {
+		newValue["field"+strconv.Itoa(i)] = i
+	}
+
+	// A stale CAS must fail the first operation without anything being changed.
+	_, err = col.MutateInFromDiff("doc", oldValue, newValue, &MutateInOptions{Cas: 1})
+	suite.Assert().ErrorIs(err, ErrDocumentExists)
+
+	getRes, err := col.Get("doc", nil)
+	suite.Require().NoError(err)
+
+	var hooked int
+	suite.Require().NoError(cluster.Internal().SetFakeHook(func(op FakeOperation) *FakeFault {
+		if op.Name == "mutate_in" {
+			hooked++
+		}
+		return nil
+	}))
+
+	res, err := col.MutateInFromDiff("doc", oldValue, newValue, &MutateInOptions{Cas: getRes.Cas()})
+	suite.Require().NoError(err)
+	suite.Require().NotNil(res)
+	suite.Assert().Equal(3, hooked)
+
+	getRes, err = col.Get("doc", nil)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(res.Cas(), getRes.Cas())
+
+	var doc map[string]interface{}
+	suite.Require().NoError(getRes.Content(&doc))
+	suite.Assert().Equal(string(suite.mustConvertToBytes(newValue)), string(suite.mustConvertToBytes(doc)))
+
+	res, err = col.MutateInFromDiff("doc", newValue, newValue, nil)
+	suite.Require().NoError(err)
+	suite.Assert().Nil(res)
+	suite.Assert().Equal(3, hooked)
+}
+
+func (suite *UnitTestSuite) TestMutateInFromDiffPreservesExpiry() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	oldValue := map[string]interface{}{"keep": "same"}
+	_, err := col.Upsert("doc", oldValue, &UpsertOptions{Expiry: time.Hour})
+	suite.Require().NoError(err)
+
+	newValue := map[string]interface{}{"keep": "same"}
+	for i := 0; i < 40; i++ {
+		newValue["field"+strconv.Itoa(i)] = i
+	}
+
+	// Each of the operations must preserve the expiry, as the server otherwise resets it.
+	_, err = col.MutateInFromDiff("doc", oldValue, newValue, nil)
+	suite.Require().NoError(err)
+
+	getRes, err := col.Get("doc", &GetOptions{WithExpiry: true})
+	suite.Require().NoError(err)
+	suite.Assert().WithinDuration(time.Now().Add(time.Hour), getRes.ExpiryTime(), time.Minute)
+
+	// An expiry given by the caller is applied by the first operation and kept by the rest.
+	_, err = col.MutateInFromDiff("doc", newValue, oldValue, &MutateInOptions{Expiry: 2 * time.Hour})
+	suite.Require().NoError(err)
+
+	getRes, err = col.Get("doc", &GetOptions{WithExpiry: true})
+	suite.Require().NoError(err)
+	suite.Assert().WithinDuration(time.Now().Add(2*time.Hour), getRes.ExpiryTime(), time.Minute)
+}
+
+func (suite *UnitTestSuite) TestMutateInFromDiffStructs() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	oldUser := testSubdocUser{Name: "frank", Tags: []string{"a"}}
+	_, err := col.Upsert("user", oldUser, nil)
+	suite.Require().NoError(err)
+
+	newUser := oldUser
+	newUser.Tags = []string{"a", "b"}
+	newUser.Age = 40
+	_, err = col.MutateInFromDiff("user", oldUser, newUser, nil)
+	suite.Require().NoError(err)
+
+	getRes, err := col.Get("user", nil)
+	suite.Require().NoError(err)
+
+	var raw map[string]json.RawMessage
+	suite.Require().NoError(getRes.Content(&raw))
+	suite.Assert().JSONEq(`["a","b"]`, string(raw["tags"]))
+	suite.Assert().JSONEq(`40`, string(raw["age"]))
+}
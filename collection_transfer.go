@@ -0,0 +1,721 @@
+package gocb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocbcore/v10"
+	"github.com/google/uuid"
+)
+
+const defaultImportBatchSize = 128
+
+// TransferFormat is the format of the data written by Export and read by Import.
+// UNCOMMITTED: This API may change in the future.
+type TransferFormat uint8
+
+const (
+	// TransferFormatJSONLines writes one JSON object per line. Each object has the id, cas, expiry and flags of the
+	// document, along with the document itself as value if it is JSON or as value_base64 otherwise.
+	TransferFormatJSONLines TransferFormat = iota
+
+	// TransferFormatCSV writes a header row followed by one row per document, with the columns id, cas, expiry,
+	// flags, value and value_base64. Only one of value and value_base64 is set, as with TransferFormatJSONLines.
+	TransferFormatCSV
+)
+
+var transferCSVHeader = []string{"id", "cas", "expiry", "flags", "value", "value_base64"}
+
+// transferRecord is a single document in the export format. Expiry is in seconds since the epoch, 0 if the document
+// does not expire.
+type transferRecord struct {
+	ID          string          `json:"id"`
+	Cas         Cas             `json:"cas"`
+	Expiry      int64           `json:"expiry"`
+	Flags       uint32          `json:"flags"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	ValueBase64 []byte          `json:"value_base64,omitempty"`
+}
+
+func newTransferRecord(item *ScanResultItem) *transferRecord {
+	record := &transferRecord{
+		ID:    item.id,
+		Cas:   item.cas,
+		Flags: item.flags,
+	}
+	if !item.expiryTime.IsZero() {
+		record.Expiry = item.expiryTime.Unix()
+	}
+
+	// Only documents which are valid JSON can be written as is, anything else would corrupt the output.
+	valueType, compression := gocbcore.DecodeCommonFlags(item.flags)
+	if valueType == gocbcore.JSONType && compression == gocbcore.NoCompression && json.Valid(item.contents) {
+		record.Value = item.contents
+	} else {
+		record.ValueBase64 = item.contents
+	}
+
+	return record
+}
+
+func (r *transferRecord) content() []byte {
+	if r.Value != nil {
+		return r.Value
+	}
+
+	return r.ValueBase64
+}
+
+// ExportOptions are the set of options available to Export.
+// UNCOMMITTED: This API may change in the future.
+type ExportOptions struct {
+	Format TransferFormat
+
+	// ScanType is the scan used to find the documents to export. Defaults to a RangeScan of every document.
+	ScanType ScanType
+
+	// Concurrency is the number of vbuckets which are scanned at the same time, see ScanOptions.
+	Concurrency    uint16
+	ConsistentWith *MutationState
+
+	Timeout    time.Duration
+	ParentSpan RequestSpan
+
+	// Using a deadlined Context alongside a Timeout will cause the shorter of the two to cause cancellation, this
+	// also applies to global level timeouts.
+	Context context.Context
+}
+
+// ExportResult is the result of an Export.
+// UNCOMMITTED: This API may change in the future.
+type ExportResult struct {
+	DocumentsExported uint64
+	BytesWritten      uint64
+}
+
+type transferCountingWriter struct {
+	w     io.Writer
+	count uint64
+}
+
+func (w *transferCountingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.count += uint64(n)
+	return n, err
+}
+
+// Export writes every document found by a scan of the collection to w, along with its metadata, so that it can later
+// be loaded with Import.
+// UNCOMMITTED: This API may change in the future.
+func (c *Collection) Export(w io.Writer, opts *ExportOptions) (*ExportResult, error) {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+	if opts.Format != TransferFormatJSONLines && opts.Format != TransferFormatCSV {
+		return nil, makeInvalidArgumentsError("unknown transfer format")
+	}
+
+	scanType := opts.ScanType
+	if scanType == nil {
+		scanType = RangeScan{}
+	}
+
+	res, err := c.Scan(scanType, &ScanOptions{
+		Timeout:        opts.Timeout,
+		ParentSpan:     opts.ParentSpan,
+		Context:        opts.Context,
+		ConsistentWith: opts.ConsistentWith,
+		Concurrency:    opts.Concurrency,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counter := &transferCountingWriter{w: w}
+	buffered := bufio.NewWriter(counter)
+	var encode func(record *transferRecord) error
+	if opts.Format == TransferFormatCSV {
+		csvWriter := csv.NewWriter(buffered)
+		if err := csvWriter.Write(transferCSVHeader); err != nil {
+			_ = res.Close()
+			return nil, err
+		}
+		encode = func(record *transferRecord) error {
+			row := []string{
+				record.ID,
+				strconv.FormatUint(uint64(record.Cas), 10),
+				strconv.FormatInt(record.Expiry, 10),
+				strconv.FormatUint(uint64(record.Flags), 10),
+				string(record.Value),
+				base64.StdEncoding.EncodeToString(record.ValueBase64),
+			}
+			if err := csvWriter.Write(row); err != nil {
+				return err
+			}
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	} else {
+		encoder := json.NewEncoder(buffered)
+		encode = func(record *transferRecord) error {
+			return encoder.Encode(record)
+		}
+	}
+
+	result := &ExportResult{}
+	for item := res.Next(); item != nil; item = res.Next() {
+		if err := encode(newTransferRecord(item)); err != nil {
+			_ = res.Close()
+			return nil, err
+		}
+		result.DocumentsExported++
+	}
+	if err := res.Err(); err != nil {
+		return nil, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return nil, err
+	}
+	result.BytesWritten = counter.count
+
+	return result, nil
+}
+
+// ImportMode specifies how Import writes documents.
+// UNCOMMITTED: This API may change in the future.
+type ImportMode uint8
+
+const (
+	// ImportModeUpsert replaces any existing document with the same ID.
+	ImportModeUpsert ImportMode = iota
+
+	// ImportModeInsert fails the record for any document which already exists.
+	ImportModeInsert
+)
+
+// ImportOptions are the set of options available to Import.
+// UNCOMMITTED: This API may change in the future.
+type ImportOptions struct {
+	Format TransferFormat
+	Mode   ImportMode
+
+	// Documents indicates that each record is a document rather than the output of Export. A TransferFormatJSONLines
+	// line is a JSON document, and a TransferFormatCSV row is a JSON object with a field per column of the header
+	// row. KeyTemplate must be set.
+	Documents bool
+
+	// KeyTemplate generates the ID of each document. %field% is replaced with the value of a field of the document,
+	// which may be nested using dots, #ID# with the ID the document was exported with, #MONO_INCR# with a number
+	// which increases by 1 for each record starting at 1, and #UUID# with a random UUID.
+	// Defaults to the ID the document was exported with.
+	KeyTemplate string
+
+	// BatchSize is the number of documents written with each Do. Defaults to 128.
+	BatchSize int
+
+	// DryRun reads and validates the records, generating their IDs, without writing any documents.
+	DryRun bool
+
+	// RateLimit limits the rate at which documents are written, in the same way as RateLimitConfig limits
+	// operations.
+	RateLimit ServiceRateLimit
+
+	// MaxErrors is the number of records which can fail before the import is aborted. 0 means that the import is
+	// never aborted because of failed records.
+	MaxErrors int
+
+	// Timeout is the timeout for writing each batch.
+	Timeout       time.Duration
+	RetryStrategy RetryStrategy
+	ParentSpan    RequestSpan
+
+	// Using a deadlined Context alongside a Timeout will cause the shorter of the two to cause cancellation, this
+	// also applies to global level timeouts.
+	Context context.Context
+}
+
+// ImportError is the failure of a single record of an Import.
+// UNCOMMITTED: This API may change in the future.
+type ImportError struct {
+	// Record is the 1-based number of the record in the input, not counting any CSV header row.
+	Record uint64
+	ID     string
+	Err    error
+}
+
+func (e ImportError) Error() string {
+	if e.ID == "" {
+		return fmt.Sprintf("record %d: %s", e.Record, e.Err)
+	}
+
+	return fmt.Sprintf("record %d (%s): %s", e.Record, e.ID, e.Err)
+}
+
+func (e ImportError) Unwrap() error {
+	return e.Err
+}
+
+// ImportResult is the result of an Import.
+// UNCOMMITTED: This API may change in the future.
+type ImportResult struct {
+	RecordsRead uint64
+
+	// DocumentsImported is the number of documents written, or which would have been written when DryRun is set.
+	DocumentsImported uint64
+
+	// DocumentsSkipped is the number of documents not written because their expiry time has passed.
+	DocumentsSkipped uint64
+
+	Errors []ImportError
+}
+
+// importValue is a document which is written as is, with its original flags, by importTranscoder.
+type importValue struct {
+	content []byte
+	flags   uint32
+}
+
+type importTranscoder struct{}
+
+func (t importTranscoder) Decode(bytes []byte, flags uint32, out interface{}) error {
+	return makeInvalidArgumentsError("import transcoder cannot decode values")
+}
+
+func (t importTranscoder) Encode(value interface{}) ([]byte, uint32, error) {
+	v, ok := value.(importValue)
+	if !ok {
+		return nil, 0, makeInvalidArgumentsError("import transcoder can only encode import values")
+	}
+
+	return v.content, v.flags, nil
+}
+
+type importKeyTemplateSegment struct {
+	literal string
+	field   []string
+}
+
+// importKeyTemplate is a parsed ImportOptions.KeyTemplate.
+type importKeyTemplate struct {
+	segments []importKeyTemplateSegment
+	counter  uint64
+}
+
+func newImportKeyTemplate(template string) (*importKeyTemplate, error) {
+	t := &importKeyTemplate{}
+	rest := template
+	for len(rest) > 0 {
+		idx := strings.IndexByte(rest, '%')
+		if idx < 0 {
+			t.segments = append(t.segments, importKeyTemplateSegment{literal: rest})
+			break
+		}
+		if idx > 0 {
+			t.segments = append(t.segments, importKeyTemplateSegment{literal: rest[:idx]})
+		}
+
+		end := strings.IndexByte(rest[idx+1:], '%')
+		if end <= 0 {
+			return nil, makeInvalidArgumentsError("key template contains an unterminated or empty field")
+		}
+		t.segments = append(t.segments, importKeyTemplateSegment{
+			field: strings.Split(rest[idx+1:idx+1+end], "."),
+		})
+		rest = rest[idx+end+2:]
+	}
+
+	return t, nil
+}
+
+// generate returns the ID of a document. The document is only parsed if the template refers to its fields.
+func (t *importKeyTemplate) generate(id string, content []byte) (string, error) {
+	var doc map[string]interface{}
+	var key strings.Builder
+	for _, segment := range t.segments {
+		if segment.field == nil {
+			literal := strings.ReplaceAll(segment.literal, "#ID#", id)
+			if strings.Contains(literal, "#MONO_INCR#") {
+				t.counter++
+				literal = strings.ReplaceAll(literal, "#MONO_INCR#", strconv.FormatUint(t.counter, 10))
+			}
+			if strings.Contains(literal, "#UUID#") {
+				literal = strings.ReplaceAll(literal, "#UUID#", uuid.NewString())
+			}
+			key.WriteString(literal)
+			continue
+		}
+
+		if doc == nil {
+			if err := json.Unmarshal(content, &doc); err != nil {
+				return "", wrapError(err, "key template refers to a field but the document is not a JSON object")
+			}
+		}
+
+		var value interface{} = doc
+		for _, name := range segment.field {
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = obj[name]
+		}
+
+		switch v := value.(type) {
+		case string:
+			key.WriteString(v)
+		case float64, bool:
+			key.WriteString(fmt.Sprint(v))
+		default:
+			return "", makeInvalidArgumentsError("key template field " + strings.Join(segment.field, ".") +
+				" is missing or is not a string, number or boolean")
+		}
+	}
+
+	if key.Len() == 0 {
+		return "", makeInvalidArgumentsError("key template generated an empty key")
+	}
+
+	return key.String(), nil
+}
+
+// importReader reads records in any of the transfer formats.
+type importReader struct {
+	documents bool
+	next      func() (*transferRecord, error)
+}
+
+func newImportReader(r io.Reader, format TransferFormat, documents bool) (*importReader, error) {
+	ir := &importReader{documents: documents}
+	switch format {
+	case TransferFormatJSONLines:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 32*1024*1024)
+		ir.next = func() (*transferRecord, error) {
+			for scanner.Scan() {
+				line := scanner.Bytes()
+				if len(bytes.TrimSpace(line)) == 0 {
+					continue
+				}
+				return ir.parseJSON(append([]byte(nil), line...))
+			}
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+	case TransferFormatCSV:
+		csvReader := csv.NewReader(r)
+		csvReader.FieldsPerRecord = -1
+		header, err := csvReader.Read()
+		if err == io.EOF {
+			ir.next = func() (*transferRecord, error) {
+				return nil, io.EOF
+			}
+			return ir, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		ir.next = func() (*transferRecord, error) {
+			row, err := csvReader.Read()
+			if err != nil {
+				var parseErr *csv.ParseError
+				if errors.As(err, &parseErr) {
+					return nil, importRecordError{err}
+				}
+				return nil, err
+			}
+			return ir.parseCSV(header, row)
+		}
+	default:
+		return nil, makeInvalidArgumentsError("unknown transfer format")
+	}
+
+	return ir, nil
+}
+
+// importRecordError is returned by the importReader when a single record is invalid, rather than the input.
+type importRecordError struct {
+	err error
+}
+
+func (e importRecordError) Error() string {
+	return e.err.Error()
+}
+
+func (ir *importReader) parseJSON(line []byte) (*transferRecord, error) {
+	if ir.documents {
+		if !json.Valid(line) {
+			return nil, importRecordError{makeInvalidArgumentsError("record is not valid JSON")}
+		}
+		return &transferRecord{
+			Value: line,
+			Flags: gocbcore.EncodeCommonFlags(gocbcore.JSONType, gocbcore.NoCompression),
+		}, nil
+	}
+
+	var record transferRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil, importRecordError{err}
+	}
+	if record.ID == "" {
+		return nil, importRecordError{makeInvalidArgumentsError("record has no id")}
+	}
+
+	return &record, nil
+}
+
+func (ir *importReader) parseCSV(header, row []string) (*transferRecord, error) {
+	if len(row) != len(header) {
+		return nil, importRecordError{makeInvalidArgumentsError("record has a different number of fields to the header")}
+	}
+
+	if ir.documents {
+		doc := make(map[string]string, len(header))
+		for i, name := range header {
+			doc[name] = row[i]
+		}
+		value, err := json.Marshal(doc)
+		if err != nil {
+			return nil, importRecordError{err}
+		}
+		return &transferRecord{
+			Value: value,
+			Flags: gocbcore.EncodeCommonFlags(gocbcore.JSONType, gocbcore.NoCompression),
+		}, nil
+	}
+
+	fields := make(map[string]string, len(header))
+	for i, name := range header {
+		fields[name] = row[i]
+	}
+
+	record := &transferRecord{ID: fields["id"]}
+	if record.ID == "" {
+		return nil, importRecordError{makeInvalidArgumentsError("record has no id")}
+	}
+	expiry, err := strconv.ParseInt(fields["expiry"], 10, 64)
+	if err != nil {
+		return nil, importRecordError{wrapError(err, "invalid expiry")}
+	}
+	record.Expiry = expiry
+	flags, err := strconv.ParseUint(fields["flags"], 10, 32)
+	if err != nil {
+		return nil, importRecordError{wrapError(err, "invalid flags")}
+	}
+	record.Flags = uint32(flags)
+	if fields["value"] != "" {
+		if !json.Valid([]byte(fields["value"])) {
+			return nil, importRecordError{makeInvalidArgumentsError("value is not valid JSON")}
+		}
+		record.Value = json.RawMessage(fields["value"])
+	} else {
+		record.ValueBase64, err = base64.StdEncoding.DecodeString(fields["value_base64"])
+		if err != nil {
+			return nil, importRecordError{wrapError(err, "invalid value_base64")}
+		}
+	}
+
+	return record, nil
+}
+
+type importPending struct {
+	record  uint64
+	release func(error)
+	op      BulkOp
+}
+
+// Import writes the documents read from r, in the format written by Export, to the collection using bulk operations.
+// Records which cannot be read or written are reported in the result rather than failing the import, unless more
+// than MaxErrors fail. The result is returned even if an error occurs, covering the records before it.
+// UNCOMMITTED: This API may change in the future.
+func (c *Collection) Import(r io.Reader, opts *ImportOptions) (*ImportResult, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+	if opts.Mode != ImportModeUpsert && opts.Mode != ImportModeInsert {
+		return nil, makeInvalidArgumentsError("unknown import mode")
+	}
+	if opts.Documents && opts.KeyTemplate == "" {
+		return nil, makeInvalidArgumentsError("a key template must be specified when importing documents")
+	}
+	if opts.BatchSize < 0 || opts.MaxErrors < 0 {
+		return nil, makeInvalidArgumentsError("batch size and max errors cannot be negative")
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize == 0 {
+		batchSize = defaultImportBatchSize
+	}
+	// Each document holds a concurrency slot until its batch is written, so a batch cannot be larger than the slots.
+	if opts.RateLimit.MaxConcurrency > 0 && uint(batchSize) > opts.RateLimit.MaxConcurrency {
+		batchSize = int(opts.RateLimit.MaxConcurrency)
+	}
+
+	var template *importKeyTemplate
+	if opts.KeyTemplate != "" {
+		var err error
+		template, err = newImportKeyTemplate(opts.KeyTemplate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	limiter, err := newServiceRateLimiter("import", opts.RateLimit, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := newImportReader(r, opts.Format, opts.Documents)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	result := &ImportResult{}
+	fail := func(record uint64, id string, err error) error {
+		result.Errors = append(result.Errors, ImportError{Record: record, ID: id, Err: err})
+		if opts.MaxErrors > 0 && len(result.Errors) > opts.MaxErrors {
+			return makeGenericError(errors.New("import aborted as too many records failed"), map[string]interface{}{
+				"failed_records": len(result.Errors),
+			})
+		}
+		return nil
+	}
+
+	var batch []importPending
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		ops := make([]BulkOp, len(batch))
+		for i, pending := range batch {
+			ops[i] = pending.op
+		}
+		err := c.Do(ops, &BulkOpOptions{
+			Timeout:       opts.Timeout,
+			Transcoder:    importTranscoder{},
+			RetryStrategy: opts.RetryStrategy,
+			ParentSpan:    opts.ParentSpan,
+			Context:       ctx,
+		})
+
+		var firstErr error
+		for _, pending := range batch {
+			var id string
+			opErr := err
+			switch op := pending.op.(type) {
+			case *UpsertOp:
+				id = op.ID
+				if opErr == nil {
+					opErr = op.Err
+				}
+			case *InsertOp:
+				id = op.ID
+				if opErr == nil {
+					opErr = op.Err
+				}
+			}
+			pending.release(opErr)
+
+			if opErr == nil {
+				result.DocumentsImported++
+				continue
+			}
+			if failErr := fail(pending.record, id, opErr); failErr != nil && firstErr == nil {
+				firstErr = failErr
+			}
+		}
+		batch = batch[:0]
+
+		return firstErr
+	}
+
+	var recordNum uint64
+	for {
+		record, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		recordNum++
+		if err != nil {
+			var recordErr importRecordError
+			if !errors.As(err, &recordErr) {
+				return result, err
+			}
+			result.RecordsRead++
+			if err := fail(recordNum, "", recordErr.err); err != nil {
+				return result, err
+			}
+			continue
+		}
+		result.RecordsRead++
+
+		id := record.ID
+		if template != nil {
+			id, err = template.generate(record.ID, record.content())
+			if err != nil {
+				if err := fail(recordNum, record.ID, err); err != nil {
+					return result, err
+				}
+				continue
+			}
+		}
+
+		var expiry time.Duration
+		if record.Expiry > 0 {
+			expiry = time.Until(time.Unix(record.Expiry, 0))
+			if expiry <= 0 {
+				result.DocumentsSkipped++
+				continue
+			}
+		}
+
+		if opts.DryRun {
+			result.DocumentsImported++
+			continue
+		}
+
+		release, err := limiter.acquire(ctx, time.Now().Add(c.timeoutsConfig.KVTimeout))
+		if err != nil {
+			return result, err
+		}
+
+		value := importValue{content: record.content(), flags: record.Flags}
+		var op BulkOp
+		if opts.Mode == ImportModeInsert {
+			op = &InsertOp{ID: id, Value: value, Expiry: expiry}
+		} else {
+			op = &UpsertOp{ID: id, Value: value, Expiry: expiry}
+		}
+		batch = append(batch, importPending{record: recordNum, release: release, op: op})
+
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,183 @@
+package gocb
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (suite *UnitTestSuite) TestCollectionExportImport() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	_, err := col.Upsert("json", map[string]string{"name": "json"}, nil)
+	suite.Require().NoError(err)
+	_, err = col.Upsert("string", "some text", &UpsertOptions{Transcoder: NewRawStringTranscoder()})
+	suite.Require().NoError(err)
+	_, err = col.Upsert("binary", []byte{0, 1, 2, 0xff}, &UpsertOptions{Transcoder: NewRawBinaryTranscoder()})
+	suite.Require().NoError(err)
+	_, err = col.Upsert("expiring", 1, &UpsertOptions{Expiry: time.Hour})
+	suite.Require().NoError(err)
+
+	for _, format := range []TransferFormat{TransferFormatJSONLines, TransferFormatCSV} {
+		suite.Run(fmt.Sprintf("Format%d", format), func() {
+			var buf bytes.Buffer
+			exportRes, err := col.Export(&buf, &ExportOptions{Format: format, Concurrency: 4})
+			suite.Require().NoError(err)
+			suite.Assert().EqualValues(4, exportRes.DocumentsExported)
+			suite.Assert().EqualValues(buf.Len(), exportRes.BytesWritten)
+
+			target := cluster.Bucket("default").Scope("scope").Collection(fmt.Sprintf("target-%d", format))
+			importRes, err := target.Import(&buf, &ImportOptions{Format: format})
+			suite.Require().NoError(err)
+			suite.Assert().Empty(importRes.Errors)
+			suite.Assert().EqualValues(4, importRes.RecordsRead)
+			suite.Assert().EqualValues(4, importRes.DocumentsImported)
+
+			var doc map[string]string
+			res, err := target.Get("json", nil)
+			suite.Require().NoError(err)
+			suite.Require().NoError(res.Content(&doc))
+			suite.Assert().Equal(map[string]string{"name": "json"}, doc)
+
+			var text string
+			res, err = target.Get("string", &GetOptions{Transcoder: NewRawStringTranscoder()})
+			suite.Require().NoError(err)
+			suite.Require().NoError(res.Content(&text))
+			suite.Assert().Equal("some text", text)
+
+			var binary []byte
+			res, err = target.Get("binary", &GetOptions{Transcoder: NewRawBinaryTranscoder()})
+			suite.Require().NoError(err)
+			suite.Require().NoError(res.Content(&binary))
+			suite.Assert().Equal([]byte{0, 1, 2, 0xff}, binary)
+
+			res, err = target.Get("expiring", &GetOptions{WithExpiry: true})
+			suite.Require().NoError(err)
+			suite.Assert().WithinDuration(time.Now().Add(time.Hour), res.ExpiryTime(), time.Minute)
+		})
+	}
+}
+
+func (suite *UnitTestSuite) TestCollectionImportDocuments() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	input := strings.Join([]string{
+		`{"name": "alice", "address": {"city": "london"}}`,
+		`not json`,
+		`{"address": {"city": "paris"}}`,
+		``,
+		`{"name": "bob", "address": {"city": "paris"}}`,
+	}, "\n")
+	opts := &ImportOptions{
+		Documents:   true,
+		KeyTemplate: "user::%address.city%::%name%::#MONO_INCR#",
+	}
+
+	opts.DryRun = true
+	res, err := col.Import(strings.NewReader(input), opts)
+	suite.Require().NoError(err)
+	suite.Assert().EqualValues(4, res.RecordsRead)
+	suite.Assert().EqualValues(2, res.DocumentsImported)
+	suite.Require().Len(res.Errors, 2)
+	suite.Assert().EqualValues(2, res.Errors[0].Record)
+	suite.Assert().EqualValues(3, res.Errors[1].Record)
+	suite.Assert().ErrorIs(res.Errors[1], ErrInvalidArgument)
+
+	_, err = col.Get("user::london::alice::1", nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+
+	opts.DryRun = false
+	res, err = col.Import(strings.NewReader(input), opts)
+	suite.Require().NoError(err)
+	suite.Assert().EqualValues(2, res.DocumentsImported)
+
+	var doc map[string]interface{}
+	getRes, err := col.Get("user::paris::bob::2", nil)
+	suite.Require().NoError(err)
+	suite.Require().NoError(getRes.Content(&doc))
+	suite.Assert().Equal("bob", doc["name"])
+
+	// Inserting the same documents again fails each of them, aborting once more than MaxErrors have failed.
+	opts.KeyTemplate = "user::%name%"
+	opts.Mode = ImportModeInsert
+	_, err = col.Import(strings.NewReader(input), opts)
+	suite.Require().NoError(err)
+
+	res, err = col.Import(strings.NewReader(input), opts)
+	suite.Require().NoError(err)
+	suite.Assert().Zero(res.DocumentsImported)
+	suite.Require().Len(res.Errors, 4)
+	suite.Assert().ErrorIs(res.Errors[2], ErrDocumentExists)
+	suite.Assert().Equal("user::alice", res.Errors[2].ID)
+
+	opts.MaxErrors = 1
+	res, err = col.Import(strings.NewReader(input), opts)
+	suite.Assert().Error(err)
+	suite.Require().NotNil(res)
+	suite.Assert().Len(res.Errors, 2)
+
+	csvInput := "name,city\ncarol,rome\n"
+	res, err = col.Import(strings.NewReader(csvInput), &ImportOptions{
+		Format:      TransferFormatCSV,
+		Documents:   true,
+		KeyTemplate: "#ID#%city%::%name%",
+	})
+	suite.Require().NoError(err)
+	suite.Assert().EqualValues(1, res.DocumentsImported)
+
+	var row map[string]string
+	getRes, err = col.Get("rome::carol", nil)
+	suite.Require().NoError(err)
+	suite.Require().NoError(getRes.Content(&row))
+	suite.Assert().Equal(map[string]string{"name": "carol", "city": "rome"}, row)
+
+	_, err = col.Import(strings.NewReader(input), &ImportOptions{Documents: true})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+	_, err = col.Import(strings.NewReader(input), &ImportOptions{Documents: true, KeyTemplate: "%unterminated"})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
+
+func (suite *UnitTestSuite) TestCollectionImportSkipsExpired() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	input := fmt.Sprintf("{\"id\":\"expired\",\"expiry\":%d,\"flags\":33554432,\"value\":1}\n"+
+		"{\"id\":\"live\",\"expiry\":0,\"flags\":33554432,\"value\":2}\n", time.Now().Add(-time.Minute).Unix())
+
+	res, err := col.Import(strings.NewReader(input), &ImportOptions{
+		RateLimit: ServiceRateLimit{OpsPerSecond: 1000, MaxConcurrency: 1},
+	})
+	suite.Require().NoError(err)
+	suite.Assert().EqualValues(1, res.DocumentsImported)
+	suite.Assert().EqualValues(1, res.DocumentsSkipped)
+
+	_, err = col.Get("expired", nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+	_, err = col.Get("live", nil)
+	suite.Assert().NoError(err)
+}
+
+func (suite *UnitTestSuite) TestCollectionImportInvalidCSVValue() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	input := "id,expiry,flags,value,value_base64\n" +
+		"bad,0,33554432,{not json,\n" +
+		"good,0,33554432,\"{\"\"name\"\":\"\"good\"\"}\",\n"
+
+	res, err := col.Import(strings.NewReader(input), &ImportOptions{Format: TransferFormatCSV})
+	suite.Require().NoError(err)
+	suite.Assert().EqualValues(2, res.RecordsRead)
+	suite.Assert().EqualValues(1, res.DocumentsImported)
+	suite.Require().Len(res.Errors, 1)
+	suite.Assert().EqualValues(1, res.Errors[0].Record)
+	suite.Assert().ErrorIs(res.Errors[0], ErrInvalidArgument)
+
+	_, err = col.Get("bad", nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+	_, err = col.Get("good", nil)
+	suite.Assert().NoError(err)
+}
@@ -0,0 +1,282 @@
+package gocb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	spanEventUpdateConflict = "update_conflict"
+
+	defaultUpdateMaxAttempts = 10
+)
+
+// UpdateFunc is called by Update with the current state of the document and returns the value that it should be
+// changed to. If the document does not exist and UpdateOptions.InsertIfMissing is set then current is nil. Returning
+// an error causes Update to stop and return that error without changing the document.
+// UNCOMMITTED: This API may change in the future.
+type UpdateFunc func(current *GetResult) (newValue interface{}, err error)
+
+// UpdateOptions are the options available to the Update operation.
+// UNCOMMITTED: This API may change in the future.
+type UpdateOptions struct {
+	// MaxAttempts is the number of times that the document is read and written before giving up because it keeps
+	// being changed concurrently. Defaults to 10.
+	MaxAttempts uint32
+	// Backoff calculates how long to wait before each new attempt after a conflict, defaults to a full jitter
+	// exponential backoff.
+	Backoff BackoffCalculator
+	// InsertIfMissing causes the document to be created if it does not already exist, rather than failing with
+	// ErrDocumentNotFound.
+	InsertIfMissing bool
+	// Subdoc causes only the fields of the document which differ from the new value to be written, using MutateIn,
+	// rather than the whole document being replaced. Both the document and the new value must be JSON objects.
+	Subdoc bool
+	// Expiry sets a new expiry on the document. If not set then the existing expiry of the document is preserved.
+	Expiry          time.Duration
+	PersistTo       uint
+	ReplicateTo     uint
+	DurabilityLevel DurabilityLevel
+	// Transcoder is used to decode the current document and, when not using Subdoc, to encode the new value.
+	Transcoder Transcoder
+	// Timeout applies to each individual operation performed by Update.
+	Timeout       time.Duration
+	RetryStrategy RetryStrategy
+	ParentSpan    RequestSpan
+
+	// Using a deadlined Context alongside a Timeout will cause the shorter of the two to cause cancellation, this
+	// also applies to global level timeouts.
+	// UNCOMMITTED: This API may change in the future.
+	Context context.Context
+
+	// Internal: This should never be used and is not supported.
+	Internal struct {
+		User string
+	}
+}
+
+// UpdateResult is the return type of the Update operation.
+// UNCOMMITTED: This API may change in the future.
+type UpdateResult struct {
+	MutationResult
+	attempts uint32
+}
+
+// Attempts returns the number of times that the document was read and written before the update succeeded.
+func (r *UpdateResult) Attempts() uint32 {
+	return r.attempts
+}
+
+// Update performs an optimistic concurrency read-modify-write of the document identified by id. The document is
+// fetched and passed to fn, and the value returned by fn is then written back using the CAS of the fetched document.
+// Should the document be changed by something else in between then the write fails, a conflict event is added to the
+// span of the operation, and the whole cycle is retried after a backoff. Once MaxAttempts is reached an error
+// wrapping ErrCasMismatch is returned. fn may be called several times and so should not have side effects.
+// UNCOMMITTED: This API may change in the future.
+func (c *Collection) Update(id string, fn UpdateFunc, opts *UpdateOptions) (*UpdateResult, error) {
+	if opts == nil {
+		opts = &UpdateOptions{}
+	}
+	if fn == nil {
+		return nil, makeInvalidArgumentsError("update function cannot be nil")
+	}
+
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultUpdateMaxAttempts
+	}
+	backoff := opts.Backoff
+	if backoff == nil {
+		backoff = FullJitterBackoff(time.Millisecond, 100*time.Millisecond, 2)
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return autoOpControl(c.kvController(), "update", func(agent kvProvider) (*UpdateResult, error) {
+		span := agent.StartKvOpTrace(c, "update", opts.ParentSpan, false)
+		defer span.End()
+
+		for attempt := uint32(1); ; attempt++ {
+			res, err := c.updateAttempt(id, fn, span, opts)
+			if err == nil {
+				span.SetAttribute(spanAttribRetries, attempt-1)
+				return &UpdateResult{
+					MutationResult: *res,
+					attempts:       attempt,
+				}, nil
+			}
+
+			var conflict updateConflictError
+			if !errors.As(err, &conflict) {
+				return nil, err
+			}
+
+			span.AddEvent(fmt.Sprintf("%s attempt=%d reason=%s", spanEventUpdateConflict, attempt, conflict.err), time.Now())
+			logDebugf("Update of %s conflicted on attempt %d: %v", id, attempt, conflict.err)
+
+			if attempt >= maxAttempts {
+				span.SetAttribute(spanAttribRetries, attempt-1)
+				return nil, wrapError(ErrCasMismatch, fmt.Sprintf("update did not succeed after %d attempts", attempt))
+			}
+
+			timer := time.NewTimer(backoff(attempt - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, backoffContextErr(ctx)
+			case <-timer.C:
+			}
+		}
+	})
+}
+
+// backoffContextErr translates the error of a context which ended whilst backing off between attempts.
+func backoffContextErr(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return wrapError(ErrTimeout, "operation context deadline exceeded whilst backing off")
+	}
+
+	return wrapError(ErrRequestCanceled, "operation context canceled whilst backing off")
+}
+
+// updateConflictError indicates that the document was changed concurrently during an update attempt.
+type updateConflictError struct {
+	err error
+}
+
+func (e updateConflictError) Error() string {
+	return e.err.Error()
+}
+
+func (e updateConflictError) Unwrap() error {
+	return e.err
+}
+
+// isUpdateConflict returns whether an error from writing the document indicates that it was changed, created or
+// removed since it was read.
+func isUpdateConflict(err error) bool {
+	return errors.Is(err, ErrCasMismatch) || errors.Is(err, ErrDocumentExists) || errors.Is(err, ErrDocumentNotFound)
+}
+
+func (c *Collection) updateAttempt(id string, fn UpdateFunc, span RequestSpan, opts *UpdateOptions) (*MutationResult, error) {
+	current, err := c.Get(id, &GetOptions{
+		Transcoder:    opts.Transcoder,
+		Timeout:       opts.Timeout,
+		RetryStrategy: opts.RetryStrategy,
+		ParentSpan:    span,
+		Context:       opts.Context,
+		Internal:      opts.Internal,
+	})
+	if err != nil {
+		if !opts.InsertIfMissing || !errors.Is(err, ErrDocumentNotFound) {
+			return nil, err
+		}
+	}
+
+	newValue, err := fn(current)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *MutationResult
+	switch {
+	case opts.Subdoc:
+		res, err = c.updateSubdoc(id, current, newValue, span, opts)
+	case current == nil:
+		res, err = c.Insert(id, newValue, &InsertOptions{
+			Expiry:          opts.Expiry,
+			PersistTo:       opts.PersistTo,
+			ReplicateTo:     opts.ReplicateTo,
+			DurabilityLevel: opts.DurabilityLevel,
+			Transcoder:      opts.Transcoder,
+			Timeout:         opts.Timeout,
+			RetryStrategy:   opts.RetryStrategy,
+			ParentSpan:      span,
+			Context:         opts.Context,
+			Internal:        opts.Internal,
+		})
+	default:
+		res, err = c.Replace(id, newValue, &ReplaceOptions{
+			Expiry:          opts.Expiry,
+			PreserveExpiry:  opts.Expiry == 0,
+			Cas:             current.Cas(),
+			PersistTo:       opts.PersistTo,
+			ReplicateTo:     opts.ReplicateTo,
+			DurabilityLevel: opts.DurabilityLevel,
+			Transcoder:      opts.Transcoder,
+			Timeout:         opts.Timeout,
+			RetryStrategy:   opts.RetryStrategy,
+			ParentSpan:      span,
+			Context:         opts.Context,
+			Internal:        opts.Internal,
+		})
+	}
+	if err != nil {
+		if isUpdateConflict(err) {
+			return nil, updateConflictError{err: err}
+		}
+
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (c *Collection) updateSubdoc(id string, current *GetResult, newValue interface{}, span RequestSpan, opts *UpdateOptions) (*MutationResult, error) {
+	mutateOpts := &MutateInOptions{
+		Expiry:          opts.Expiry,
+		PersistTo:       opts.PersistTo,
+		ReplicateTo:     opts.ReplicateTo,
+		DurabilityLevel: opts.DurabilityLevel,
+		Timeout:         opts.Timeout,
+		RetryStrategy:   opts.RetryStrategy,
+		ParentSpan:      span,
+		Context:         opts.Context,
+	}
+	mutateOpts.Internal.User = opts.Internal.User
+
+	var oldValue interface{} = map[string]interface{}{}
+	if current == nil {
+		mutateOpts.StoreSemantic = StoreSemanticsInsert
+	} else {
+		if err := current.Content(&oldValue); err != nil {
+			return nil, err
+		}
+		mutateOpts.StoreSemantic = StoreSemanticsReplace
+		mutateOpts.Cas = current.Cas()
+		mutateOpts.PreserveExpiry = opts.Expiry == 0
+	}
+
+	res, err := c.MutateInFromDiff(id, oldValue, newValue, mutateOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if res == nil {
+		if current == nil {
+			// An empty object has nothing to diff against an empty object, so create the document whole.
+			return c.Insert(id, newValue, &InsertOptions{
+				Expiry:          opts.Expiry,
+				PersistTo:       opts.PersistTo,
+				ReplicateTo:     opts.ReplicateTo,
+				DurabilityLevel: opts.DurabilityLevel,
+				Timeout:         opts.Timeout,
+				RetryStrategy:   opts.RetryStrategy,
+				ParentSpan:      span,
+				Context:         opts.Context,
+				Internal:        opts.Internal,
+			})
+		}
+
+		// The value is unchanged so there is nothing to write.
+		return &MutationResult{
+			Result: Result{cas: current.Cas()},
+		}, nil
+	}
+
+	return &res.MutationResult, nil
+}
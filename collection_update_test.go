@@ -0,0 +1,197 @@
+package gocb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func (suite *UnitTestSuite) TestUpdate() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	now := time.Now()
+	suite.Require().NoError(cluster.Internal().SetFakeClock(func() time.Time {
+		return now
+	}))
+
+	_, err := col.Upsert("counter", map[string]int{"count": 1}, &UpsertOptions{Expiry: time.Hour})
+	suite.Require().NoError(err)
+
+	increment := func(current *GetResult) (interface{}, error) {
+		doc := map[string]int{}
+		if current != nil {
+			if err := current.Content(&doc); err != nil {
+				return nil, err
+			}
+		}
+		doc["count"]++
+		return doc, nil
+	}
+
+	for _, subdoc := range []bool{false, true} {
+		res, err := col.Update("counter", increment, &UpdateOptions{Subdoc: subdoc})
+		suite.Require().NoError(err)
+		suite.Assert().Equal(uint32(1), res.Attempts())
+		suite.Assert().NotZero(res.Cas())
+	}
+
+	getRes, err := col.Get("counter", &GetOptions{WithExpiry: true})
+	suite.Require().NoError(err)
+	var doc map[string]int
+	suite.Require().NoError(getRes.Content(&doc))
+	suite.Assert().Equal(3, doc["count"])
+	suite.Require().NotNil(getRes.ExpiryTime())
+	suite.Assert().Equal(now.Add(time.Hour).Unix(), getRes.ExpiryTime().Unix())
+
+	_, err = col.Update("missing", increment, nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+
+	for _, id := range []string{"inserted", "inserted-subdoc"} {
+		res, err := col.Update(id, increment, &UpdateOptions{InsertIfMissing: true, Subdoc: id == "inserted-subdoc"})
+		suite.Require().NoError(err)
+		suite.Assert().Equal(uint32(1), res.Attempts())
+
+		getRes, err = col.Get(id, nil)
+		suite.Require().NoError(err)
+		suite.Require().NoError(getRes.Content(&doc))
+		suite.Assert().Equal(map[string]int{"count": 1}, doc)
+	}
+
+	fnErr := errors.New("abort")
+	_, err = col.Update("counter", func(current *GetResult) (interface{}, error) {
+		return nil, fnErr
+	}, nil)
+	suite.Assert().ErrorIs(err, fnErr)
+
+	_, err = col.Update("counter", nil, nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
+
+func (suite *UnitTestSuite) TestUpdateRetriesConflicts() {
+	tracer := newTestTracer()
+	cluster, err := Connect("fake://", ClusterOptions{
+		Tracer: tracer,
+		Meter:  &NoopMeter{},
+	})
+	suite.Require().NoError(err)
+	defer cluster.Close(nil)
+	col := cluster.Bucket("default").DefaultCollection()
+
+	_, err = col.Upsert("doc", map[string]string{"name": "a"}, nil)
+	suite.Require().NoError(err)
+
+	// Change the document behind the back of the update for its first two attempts.
+	var lock sync.Mutex
+	conflicts := 2
+	suite.Require().NoError(cluster.Internal().SetFakeHook(func(op FakeOperation) *FakeFault {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if (op.Name == "replace" || op.Name == "mutate_in") && conflicts > 0 {
+			conflicts--
+			return &FakeFault{Err: ErrCasMismatch}
+		}
+		return nil
+	}))
+
+	var calls int
+	rename := func(current *GetResult) (interface{}, error) {
+		calls++
+		return map[string]string{"name": strconv.Itoa(calls)}, nil
+	}
+	backoff := func(retryAttempts uint32) time.Duration {
+		return time.Millisecond
+	}
+
+	tracer.Reset()
+	res, err := col.Update("doc", rename, &UpdateOptions{Backoff: backoff})
+	suite.Require().NoError(err)
+	suite.Assert().Equal(uint32(3), res.Attempts())
+	suite.Assert().Equal(3, calls)
+
+	spans := tracer.GetSpans()[nil]
+	suite.Require().Len(spans, 1)
+	suite.Assert().Equal("update", spans[0].Name)
+	suite.Assert().Equal(uint32(2), spans[0].Tags[spanAttribRetries])
+	suite.Require().Len(spans[0].Events, 2)
+	for _, event := range spans[0].Events {
+		suite.Assert().True(strings.HasPrefix(event, spanEventUpdateConflict), event)
+	}
+
+	lock.Lock()
+	conflicts = 10
+	lock.Unlock()
+
+	_, err = col.Update("doc", rename, &UpdateOptions{Subdoc: true, MaxAttempts: 3, Backoff: backoff})
+	suite.Assert().ErrorIs(err, ErrCasMismatch)
+	suite.Assert().Equal(6, calls)
+}
+
+func (suite *UnitTestSuite) TestUpdateSubdocPreservesExpiry() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	now := time.Now()
+	suite.Require().NoError(cluster.Internal().SetFakeClock(func() time.Time {
+		return now
+	}))
+
+	_, err := col.Upsert("doc", map[string]string{"name": "a"}, &UpsertOptions{Expiry: time.Hour})
+	suite.Require().NoError(err)
+
+	rename := func(name string) UpdateFunc {
+		return func(current *GetResult) (interface{}, error) {
+			return map[string]string{"name": name}, nil
+		}
+	}
+
+	_, err = col.Update("doc", rename("b"), &UpdateOptions{Subdoc: true})
+	suite.Require().NoError(err)
+
+	getRes, err := col.Get("doc", &GetOptions{WithExpiry: true})
+	suite.Require().NoError(err)
+	suite.Assert().Equal(now.Add(time.Hour).Unix(), getRes.ExpiryTime().Unix())
+
+	_, err = col.Update("doc", rename("c"), &UpdateOptions{Subdoc: true, Expiry: 2 * time.Hour})
+	suite.Require().NoError(err)
+
+	getRes, err = col.Get("doc", &GetOptions{WithExpiry: true})
+	suite.Require().NoError(err)
+	suite.Assert().Equal(now.Add(2*time.Hour).Unix(), getRes.ExpiryTime().Unix())
+}
+
+func (suite *UnitTestSuite) TestUpdateContextEndsDuringBackoff() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	_, err := col.Upsert("doc", map[string]string{"name": "a"}, nil)
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(cluster.Internal().SetFakeHook(func(op FakeOperation) *FakeFault {
+		if op.Name == "replace" {
+			return &FakeFault{Err: ErrCasMismatch}
+		}
+		return nil
+	}))
+
+	rename := func(current *GetResult) (interface{}, error) {
+		return map[string]string{"name": "b"}, nil
+	}
+	backoff := func(retryAttempts uint32) time.Duration {
+		return time.Hour
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = col.Update("doc", rename, &UpdateOptions{Backoff: backoff, Context: ctx})
+	suite.Assert().ErrorIs(err, ErrTimeout)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = col.Update("doc", rename, &UpdateOptions{Backoff: backoff, Context: ctx})
+	suite.Assert().ErrorIs(err, ErrRequestCanceled)
+}
@@ -35,7 +35,7 @@ func (cm *collectionsManagementProviderCore) GetAllScopes(opts *GetAllScopesOpti
 		IsIdempotent:  true,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := cm.mgmtProvider.executeMgmtRequest(opts.Context, req)
@@ -156,7 +156,7 @@ func (cm *collectionsManagementProviderCore) CreateCollection(scopeName string,
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := cm.mgmtProvider.executeMgmtRequest(opts.Context, req)
@@ -228,7 +228,7 @@ func (cm *collectionsManagementProviderCore) UpdateCollection(scopeName string,
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := cm.mgmtProvider.executeMgmtRequest(opts.Context, req)
@@ -282,7 +282,7 @@ func (cm *collectionsManagementProviderCore) DropCollection(scopeName string, co
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := cm.mgmtProvider.executeMgmtRequest(opts.Context, req)
@@ -340,7 +340,7 @@ func (cm *collectionsManagementProviderCore) CreateScope(scopeName string, opts
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := cm.mgmtProvider.executeMgmtRequest(opts.Context, req)
@@ -385,7 +385,7 @@ func (cm *collectionsManagementProviderCore) DropScope(scopeName string, opts *D
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := cm.mgmtProvider.executeMgmtRequest(opts.Context, req)
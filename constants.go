@@ -361,6 +361,18 @@ const (
 	meterAttribClusterUUIDKey    = "db.couchbase.cluster_uuid"
 	meterAttribClusterNameKey    = "db.couchbase.cluster_name"
 
+	meterNameRateLimiterThrottled = "db.couchbase.ratelimiter.throttled"
+	meterNameRateLimiterRejected  = "db.couchbase.ratelimiter.rejected"
+	meterNameRateLimiterWait      = "db.couchbase.ratelimiter.wait_duration"
+	meterNameRateLimiterRate      = "db.couchbase.ratelimiter.rate"
+
+	meterNameHedgeRequests = "db.couchbase.hedge.requests"
+	meterNameHedgeSent     = "db.couchbase.hedge.sent"
+	meterNameHedgeWon      = "db.couchbase.hedge.won"
+
+	meterNameCircuitBreakerTransitions = "db.couchbase.circuitbreaker.transitions"
+	meterAttribCircuitBreakerStateKey  = "db.couchbase.circuitbreaker.state"
+
 	serviceValueKV         = "kv"
 	serviceValueQuery      = "query"
 	serviceValueAnalytics  = "analytics"
@@ -342,5 +342,22 @@ var (
 	// ErrPathTooDeep when other schemes are used.
 	ErrDocumentTooDeep = errors.New("document too deep")
 
+	// ErrRecordingNotFound occurs when a cluster is replaying recorded traffic and no recording matches a request.
+	// UNCOMMITTED: This API may change in the future.
+	ErrRecordingNotFound = errors.New("no recording matches the request")
+
+	// ErrLockContended occurs when a lock could not be acquired because it is held by another owner.
+	// UNCOMMITTED: This API may change in the future.
+	ErrLockContended = errors.New("lock is held by another owner")
+
+	// ErrLeaseLost occurs when a lease on a lock is no longer held, because it expired or was taken by another owner.
+	// UNCOMMITTED: This API may change in the future.
+	ErrLeaseLost = errors.New("lease lost")
+
+	// ErrQueueReceiptInvalid occurs when acknowledging a queue message which is no longer held by the receiver, because
+	// its visibility timeout passed and it was received again or it was already acknowledged.
+	// UNCOMMITTED: This API may change in the future.
+	ErrQueueReceiptInvalid = errors.New("queue message receipt is no longer valid")
+
 	ErrShutdown = errors.New("cluster closed")
 )
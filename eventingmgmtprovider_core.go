@@ -465,7 +465,7 @@ func (emp *eventingManagementProviderCore) doRequest(scope *Scope, path string,
 		Path:          path,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 		Body:          b,
 	}
 	resp, err := emp.doMgmtRequest(opts.Context, req)
@@ -0,0 +1,223 @@
+// Package gocbtest provides an in-memory fake of a Couchbase cluster for unit testing code which uses gocb.
+//
+// Connecting with ConnStr gives a gocb.Cluster whose buckets, scopes and collections are created on first use and
+// whose key-value operations, including sub-document operations, are served from memory with the same semantics as
+// the server: cas, expiry, locking and errors such as gocb.ErrDocumentExists behave as they would against a cluster.
+// Other services, such as query and search, return gocb.ErrFeatureNotAvailable.
+//
+// Faults such as timeouts and temporary failures can be injected using a Fake, which also controls the clock used
+// for expiry and locking.
+// UNCOMMITTED: This API may change in the future.
+package gocbtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// ConnStr is the connection string which connects to an in-memory fake rather than a cluster.
+const ConnStr = "fake://"
+
+// Operation describes a key-value operation performed against the fake.
+// UNCOMMITTED: This API may change in the future.
+type Operation = gocb.FakeOperation
+
+// Fault describes a fault to inject into operations against the fake. The fault is injected before the operation
+// is applied, so an operation which fails because of a fault never changes the stored documents.
+// UNCOMMITTED: This API may change in the future.
+type Fault struct {
+	// Operations limits the fault to operations with these names, e.g. "get" or "upsert". Empty matches all.
+	Operations []string
+
+	// DocumentID limits the fault to operations against this document. Empty matches all.
+	DocumentID string
+
+	// Match, if set, further limits the fault to operations for which it returns true.
+	Match func(op Operation) bool
+
+	// Delay is how long to wait before the operation is processed. If the delay is longer than the timeout of the
+	// operation then it times out.
+	Delay time.Duration
+
+	// Timeout fails the operation with a gocb.TimeoutError, ambiguous for mutations.
+	Timeout bool
+
+	// Err fails the operation with the error. Errors which the SDK would retry, such as gocb.ErrTemporaryFailure,
+	// are retried according to the retry strategy of the operation and each retry is a new attempt.
+	Err error
+
+	// Times is the number of attempts the fault is injected into before it is removed. 0 means until removed.
+	Times int
+}
+
+func (f *Fault) matches(op Operation) bool {
+	if f.DocumentID != "" && f.DocumentID != op.DocumentID {
+		return false
+	}
+
+	if len(f.Operations) > 0 {
+		found := false
+		for _, name := range f.Operations {
+			if name == op.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return f.Match == nil || f.Match(op)
+}
+
+// Fake controls the in-memory cluster behind a gocb.Cluster connected using ConnStr.
+// UNCOMMITTED: This API may change in the future.
+type Fake struct {
+	lock   sync.Mutex
+	faults []*Fault
+	offset time.Duration
+}
+
+// Connect connects to a new, empty, in-memory fake. Every call returns an independent fake. If opts is nil then
+// default options are used.
+// UNCOMMITTED: This API may change in the future.
+func Connect(opts *gocb.ClusterOptions) (*gocb.Cluster, *Fake, error) {
+	if opts == nil {
+		opts = &gocb.ClusterOptions{}
+	}
+
+	cluster, err := gocb.Connect(ConnStr, *opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fake, err := New(cluster)
+	if err != nil {
+		_ = cluster.Close(nil)
+		return nil, nil, err
+	}
+
+	return cluster, fake, nil
+}
+
+// New returns a Fake controlling a cluster which was connected using ConnStr.
+// UNCOMMITTED: This API may change in the future.
+func New(cluster *gocb.Cluster) (*Fake, error) {
+	f := &Fake{}
+
+	err := cluster.Internal().SetFakeHook(f.hook)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cluster.Internal().SetFakeClock(f.Now)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// AddFault injects a fault into matching operations, returning a function which removes it. When several faults
+// match an operation the one added first is used.
+func (f *Fake) AddFault(fault Fault) (remove func()) {
+	added := &fault
+
+	f.lock.Lock()
+	f.faults = append(f.faults, added)
+	f.lock.Unlock()
+
+	return func() {
+		f.lock.Lock()
+		f.removeLocked(added)
+		f.lock.Unlock()
+	}
+}
+
+// FailNext fails the next attempt of the named operation with err. An empty name matches any operation.
+func (f *Fake) FailNext(operation string, err error) {
+	f.AddFault(Fault{
+		Operations: operationNames(operation),
+		Err:        err,
+		Times:      1,
+	})
+}
+
+// TimeoutNext times out the next attempt of the named operation. An empty name matches any operation.
+func (f *Fake) TimeoutNext(operation string) {
+	f.AddFault(Fault{
+		Operations: operationNames(operation),
+		Timeout:    true,
+		Times:      1,
+	})
+}
+
+// ClearFaults removes all faults.
+func (f *Fake) ClearFaults() {
+	f.lock.Lock()
+	f.faults = nil
+	f.lock.Unlock()
+}
+
+// Now returns the current time of the fake, which is the system time moved forward by any calls to Advance.
+func (f *Fake) Now() time.Time {
+	f.lock.Lock()
+	offset := f.offset
+	f.lock.Unlock()
+
+	return time.Now().Add(offset)
+}
+
+// Advance moves the clock of the fake forward, expiring documents and releasing locks as if d had passed. It does
+// not affect operation timeouts, which always use the system clock.
+func (f *Fake) Advance(d time.Duration) {
+	f.lock.Lock()
+	f.offset += d
+	f.lock.Unlock()
+}
+
+func (f *Fake) hook(op Operation) *gocb.FakeFault {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, fault := range f.faults {
+		if !fault.matches(op) {
+			continue
+		}
+
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				f.removeLocked(fault)
+			}
+		}
+
+		return &gocb.FakeFault{
+			Delay:   fault.Delay,
+			Timeout: fault.Timeout,
+			Err:     fault.Err,
+		}
+	}
+
+	return nil
+}
+
+func (f *Fake) removeLocked(fault *Fault) {
+	for i, existing := range f.faults {
+		if existing == fault {
+			f.faults = append(f.faults[:i:i], f.faults[i+1:]...)
+			return
+		}
+	}
+}
+
+func operationNames(operation string) []string {
+	if operation == "" {
+		return nil
+	}
+
+	return []string{operation}
+}
@@ -0,0 +1,357 @@
+package gocbtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+type testDoc struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+func newTestCollection(t *testing.T) (*gocb.Collection, *Fake) {
+	cluster, fake, err := Connect(nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = cluster.Close(nil)
+	})
+
+	return cluster.Bucket("default").Scope("app").Collection("users"), fake
+}
+
+func TestCrudAndCas(t *testing.T) {
+	col, _ := newTestCollection(t)
+
+	insertRes, err := col.Insert("u1", testDoc{Name: "alice"}, nil)
+	require.NoError(t, err)
+	assert.NotZero(t, insertRes.Cas())
+	assert.NotNil(t, insertRes.MutationToken())
+
+	_, err = col.Insert("u1", testDoc{Name: "bob"}, nil)
+	assert.ErrorIs(t, err, gocb.ErrDocumentExists)
+
+	getRes, err := col.Get("u1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, insertRes.Cas(), getRes.Cas())
+
+	var doc testDoc
+	require.NoError(t, getRes.Content(&doc))
+	assert.Equal(t, "alice", doc.Name)
+
+	_, err = col.Replace("u1", testDoc{Name: "carol"}, &gocb.ReplaceOptions{Cas: insertRes.Cas() + 1})
+	assert.ErrorIs(t, err, gocb.ErrCasMismatch)
+
+	replaceRes, err := col.Replace("u1", testDoc{Name: "carol"}, &gocb.ReplaceOptions{Cas: insertRes.Cas()})
+	require.NoError(t, err)
+	assert.NotEqual(t, insertRes.Cas(), replaceRes.Cas())
+
+	exists, err := col.Exists("u1", nil)
+	require.NoError(t, err)
+	assert.True(t, exists.Exists())
+
+	_, err = col.Remove("u1", nil)
+	require.NoError(t, err)
+
+	_, err = col.Get("u1", nil)
+	assert.ErrorIs(t, err, gocb.ErrDocumentNotFound)
+
+	var kvErr *gocb.KeyValueError
+	require.ErrorAs(t, err, &kvErr)
+	assert.Equal(t, "u1", kvErr.DocumentID)
+	assert.Equal(t, "default", kvErr.BucketName)
+	assert.Equal(t, "app", kvErr.ScopeName)
+	assert.Equal(t, "users", kvErr.CollectionName)
+
+	exists, err = col.Exists("u1", nil)
+	require.NoError(t, err)
+	assert.False(t, exists.Exists())
+}
+
+func TestCollectionsAreIsolated(t *testing.T) {
+	cluster, _, err := Connect(nil)
+	require.NoError(t, err)
+	defer cluster.Close(nil)
+
+	_, err = cluster.Bucket("default").DefaultCollection().Upsert("k", "v", nil)
+	require.NoError(t, err)
+
+	_, err = cluster.Bucket("other").DefaultCollection().Get("k", nil)
+	assert.ErrorIs(t, err, gocb.ErrDocumentNotFound)
+
+	_, err = cluster.Bucket("default").Scope("_default").Collection("_default").Get("k", nil)
+	assert.NoError(t, err)
+
+	other, _, err := Connect(nil)
+	require.NoError(t, err)
+	defer other.Close(nil)
+
+	_, err = other.Bucket("default").DefaultCollection().Get("k", nil)
+	assert.ErrorIs(t, err, gocb.ErrDocumentNotFound)
+}
+
+func TestExpiry(t *testing.T) {
+	col, fake := newTestCollection(t)
+
+	_, err := col.Upsert("session", "data", &gocb.UpsertOptions{Expiry: time.Minute})
+	require.NoError(t, err)
+
+	res, err := col.Get("session", &gocb.GetOptions{WithExpiry: true})
+	require.NoError(t, err)
+	assert.WithinDuration(t, fake.Now().Add(time.Minute), res.ExpiryTime(), 2*time.Second)
+
+	_, err = col.Upsert("session", "data2", &gocb.UpsertOptions{PreserveExpiry: true})
+	require.NoError(t, err)
+
+	fake.Advance(59 * time.Second)
+	_, err = col.Get("session", nil)
+	require.NoError(t, err)
+
+	fake.Advance(2 * time.Second)
+	_, err = col.Get("session", nil)
+	assert.ErrorIs(t, err, gocb.ErrDocumentNotFound)
+
+	// Expired documents can be inserted again.
+	_, err = col.Insert("session", "data", nil)
+	assert.NoError(t, err)
+}
+
+func TestLocking(t *testing.T) {
+	col, fake := newTestCollection(t)
+
+	_, err := col.Upsert("doc", "v1", nil)
+	require.NoError(t, err)
+
+	locked, err := col.GetAndLock("doc", 10*time.Second, nil)
+	require.NoError(t, err)
+
+	getRes, err := col.Get("doc", nil)
+	require.NoError(t, err)
+	assert.Equal(t, gocb.Cas(^uint64(0)), getRes.Cas())
+
+	// Mutations of a locked document are retried until they time out.
+	_, err = col.Upsert("doc", "v2", &gocb.UpsertOptions{Timeout: 50 * time.Millisecond})
+	var tErr *gocb.TimeoutError
+	require.ErrorAs(t, err, &tErr)
+	assert.Contains(t, tErr.RetryReasons, gocb.KVLockedRetryReason)
+
+	err = col.Unlock("doc", locked.Cas()+1, nil)
+	assert.ErrorIs(t, err, gocb.ErrCasMismatch)
+
+	_, err = col.Replace("doc", "v2", &gocb.ReplaceOptions{Cas: locked.Cas()})
+	require.NoError(t, err)
+
+	err = col.Unlock("doc", locked.Cas(), nil)
+	assert.ErrorIs(t, err, gocb.ErrDocumentNotLocked)
+
+	_, err = col.GetAndLock("doc", 10*time.Second, nil)
+	require.NoError(t, err)
+
+	fake.Advance(11 * time.Second)
+	_, err = col.Upsert("doc", "v3", nil)
+	assert.NoError(t, err)
+}
+
+func TestCounters(t *testing.T) {
+	col, _ := newTestCollection(t)
+
+	_, err := col.Binary().Increment("counter", &gocb.IncrementOptions{Delta: 1, Initial: -1})
+	assert.ErrorIs(t, err, gocb.ErrDocumentNotFound)
+
+	res, err := col.Binary().Increment("counter", &gocb.IncrementOptions{Delta: 5, Initial: 10})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), res.Content())
+
+	res, err = col.Binary().Increment("counter", &gocb.IncrementOptions{Delta: 5})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(15), res.Content())
+
+	res, err = col.Binary().Decrement("counter", &gocb.DecrementOptions{Delta: 20})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), res.Content())
+
+	_, err = col.Binary().Prepend("counter", []byte("1"), nil)
+	require.NoError(t, err)
+
+	getRes, err := col.Get("counter", nil)
+	require.NoError(t, err)
+	var val int
+	require.NoError(t, getRes.Content(&val))
+	assert.Equal(t, 10, val)
+}
+
+func TestSubdoc(t *testing.T) {
+	col, _ := newTestCollection(t)
+
+	_, err := col.Upsert("u1", testDoc{Name: "alice", Count: 1, Tags: []string{"a"}}, nil)
+	require.NoError(t, err)
+
+	mutRes, err := col.MutateIn("u1", []gocb.MutateInSpec{
+		gocb.UpsertSpec("name", "bob", nil),
+		gocb.IncrementSpec("count", 2, nil),
+		gocb.ArrayAppendSpec("tags", "b", nil),
+		gocb.InsertSpec("address.city", "London", &gocb.InsertSpecOptions{CreatePath: true}),
+		gocb.UpsertSpec("meta.updated", gocb.MutationMacroCAS, &gocb.UpsertSpecOptions{IsXattr: true, CreatePath: true}),
+	}, nil)
+	require.NoError(t, err)
+
+	var count int
+	require.NoError(t, mutRes.ContentAt(1, &count))
+	assert.Equal(t, 3, count)
+
+	lookupRes, err := col.LookupIn("u1", []gocb.LookupInSpec{
+		gocb.GetSpec("name", nil),
+		gocb.GetSpec("tags[-1]", nil),
+		gocb.ExistsSpec("missing", nil),
+		gocb.CountSpec("tags", nil),
+		gocb.GetSpec("meta.updated", &gocb.GetSpecOptions{IsXattr: true}),
+		gocb.GetSpec("$document.CAS", &gocb.GetSpecOptions{IsXattr: true}),
+		gocb.GetSpec("address.city", nil),
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, mutRes.Cas(), lookupRes.Cas())
+
+	var name, lastTag, macroCas, docCas, city string
+	var tagCount int
+	require.NoError(t, lookupRes.ContentAt(0, &name))
+	require.NoError(t, lookupRes.ContentAt(1, &lastTag))
+	assert.False(t, lookupRes.Exists(2))
+	require.NoError(t, lookupRes.ContentAt(3, &tagCount))
+	require.NoError(t, lookupRes.ContentAt(4, &macroCas))
+	require.NoError(t, lookupRes.ContentAt(5, &docCas))
+	require.NoError(t, lookupRes.ContentAt(6, &city))
+	assert.Equal(t, "bob", name)
+	assert.Equal(t, "b", lastTag)
+	assert.Equal(t, 2, tagCount)
+	assert.Equal(t, docCas, macroCas)
+	assert.Equal(t, "London", city)
+
+	_, err = col.MutateIn("u1", []gocb.MutateInSpec{
+		gocb.UpsertSpec("name", "carol", nil),
+		gocb.ReplaceSpec("missing", "x", nil),
+	}, nil)
+	assert.ErrorIs(t, err, gocb.ErrPathNotFound)
+
+	// A failed MutateIn leaves the document unchanged.
+	projected, err := col.Get("u1", &gocb.GetOptions{Project: []string{"name"}})
+	require.NoError(t, err)
+	var doc testDoc
+	require.NoError(t, projected.Content(&doc))
+	assert.Equal(t, testDoc{Name: "bob"}, doc)
+
+	_, err = col.MutateIn("u1", []gocb.MutateInSpec{
+		gocb.UpsertSpec("name", "dave", nil),
+	}, &gocb.MutateInOptions{Cas: mutRes.Cas() + 1})
+	assert.ErrorIs(t, err, gocb.ErrDocumentExists)
+
+	_, err = col.MutateIn("new", []gocb.MutateInSpec{
+		gocb.UpsertSpec("name", "erin", nil),
+	}, &gocb.MutateInOptions{StoreSemantic: gocb.StoreSemanticsInsert})
+	require.NoError(t, err)
+
+	_, err = col.MutateIn("new", []gocb.MutateInSpec{
+		gocb.UpsertSpec("name", "erin", nil),
+	}, &gocb.MutateInOptions{StoreSemantic: gocb.StoreSemanticsInsert})
+	assert.ErrorIs(t, err, gocb.ErrDocumentExists)
+}
+
+func TestFailNextRetriesTemporaryFailure(t *testing.T) {
+	col, fake := newTestCollection(t)
+
+	fake.FailNext("upsert", gocb.ErrTemporaryFailure)
+
+	_, err := col.Upsert("doc", "v", nil)
+	require.NoError(t, err)
+
+	// The fault only applies once.
+	_, err = col.Upsert("doc", "v", nil)
+	require.NoError(t, err)
+}
+
+func TestFailNextNonRetriable(t *testing.T) {
+	col, fake := newTestCollection(t)
+
+	errCustom := errors.New("custom")
+	fake.FailNext("", errCustom)
+
+	_, err := col.Get("doc", nil)
+	assert.ErrorIs(t, err, errCustom)
+
+	var kvErr *gocb.KeyValueError
+	require.ErrorAs(t, err, &kvErr)
+	assert.Equal(t, "doc", kvErr.DocumentID)
+}
+
+func TestTimeoutFaults(t *testing.T) {
+	col, fake := newTestCollection(t)
+
+	fake.TimeoutNext("get")
+	_, err := col.Get("doc", nil)
+	assert.ErrorIs(t, err, gocb.ErrUnambiguousTimeout)
+
+	fake.TimeoutNext("upsert")
+	_, err = col.Upsert("doc", "v", nil)
+	assert.ErrorIs(t, err, gocb.ErrAmbiguousTimeout)
+
+	// The upsert timed out before it was applied.
+	_, err = col.Get("doc", nil)
+	assert.ErrorIs(t, err, gocb.ErrDocumentNotFound)
+
+	remove := fake.AddFault(Fault{
+		DocumentID: "slow",
+		Delay:      time.Second,
+	})
+	start := time.Now()
+	_, err = col.Get("slow", &gocb.GetOptions{Timeout: 50 * time.Millisecond})
+	assert.ErrorIs(t, err, gocb.ErrUnambiguousTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	_, err = col.Get("doc", &gocb.GetOptions{Timeout: 50 * time.Millisecond})
+	assert.ErrorIs(t, err, gocb.ErrDocumentNotFound)
+
+	remove()
+	_, err = col.Get("slow", &gocb.GetOptions{Timeout: 50 * time.Millisecond})
+	assert.ErrorIs(t, err, gocb.ErrDocumentNotFound)
+}
+
+func TestTemporaryFailureTimesOut(t *testing.T) {
+	col, fake := newTestCollection(t)
+
+	fake.AddFault(Fault{Operations: []string{"insert"}, Err: gocb.ErrTemporaryFailure})
+
+	_, err := col.Insert("doc", "v", &gocb.InsertOptions{Timeout: 50 * time.Millisecond})
+	var tErr *gocb.TimeoutError
+	require.ErrorAs(t, err, &tErr)
+	assert.Contains(t, tErr.RetryReasons, gocb.KVTemporaryFailureRetryReason)
+
+	fake.ClearFaults()
+	_, err = col.Insert("doc", "v", nil)
+	assert.NoError(t, err)
+}
+
+func TestOtherServicesUnavailable(t *testing.T) {
+	cluster, _, err := Connect(nil)
+	require.NoError(t, err)
+	defer cluster.Close(nil)
+
+	require.NoError(t, cluster.WaitUntilReady(time.Second, nil))
+
+	_, err = cluster.Query("SELECT 1", nil)
+	assert.ErrorIs(t, err, gocb.ErrFeatureNotAvailable)
+}
+
+func TestNewRequiresFakeCluster(t *testing.T) {
+	cluster, err := gocb.Connect("couchbase://localhost", gocb.ClusterOptions{})
+	require.NoError(t, err)
+	defer cluster.Close(nil)
+
+	_, err = New(cluster)
+	assert.ErrorIs(t, err, gocb.ErrFeatureNotAvailable)
+}
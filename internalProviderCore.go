@@ -27,7 +27,7 @@ func (ic *internalProviderCore) GetNodesMetadata(opts *GetNodesMetadataOptions)
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := ic.provider.executeMgmtRequest(opts.Context, req)
@@ -1,6 +1,7 @@
 package gocb
 
 import (
+	"context"
 	"time"
 
 	gocbcore "github.com/couchbase/gocbcore/v10"
@@ -9,8 +10,9 @@ import (
 type kvBulkProviderCore struct {
 	agent kvProviderCoreProvider
 
-	tracer *tracerWrapper
-	meter  *meterWrapper
+	tracer      *tracerWrapper
+	meter       *meterWrapper
+	rateLimiter *serviceRateLimiter
 }
 
 func (p *kvBulkProviderCore) Do(c *Collection, ops []BulkOp, opts *BulkOpOptions) error {
@@ -36,30 +38,66 @@ func (p *kvBulkProviderCore) Do(c *Collection, ops []BulkOp, opts *BulkOpOptions
 	//   we get delayed inside execute (don't want to block the
 	//   individual op handlers when they dispatch their signal).
 	signal := make(chan BulkOp, len(ops))
+
+	// Rate limited ops release their limit as soon as they complete, rather than once every op has been dispatched,
+	// as otherwise a concurrency limit lower than the number of ops would never be released.
+	dispatched := signal
+	if p.rateLimiter != nil {
+		dispatched = make(chan BulkOp, len(ops))
+		go func() {
+			for range ops {
+				item := <-dispatched
+				if release := item.base().rateLimitRelease; release != nil {
+					release(*bulkOpErr(item))
+				}
+				signal <- item
+			}
+		}()
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	for _, item := range ops {
+		deadline := time.Now().Add(timeout)
+		if p.rateLimiter != nil {
+			release, err := p.rateLimiter.acquire(ctx, deadline)
+			if err != nil {
+				*bulkOpErr(item) = err
+				item.base().finishFn = func() {}
+				dispatched <- item
+				continue
+			}
+			item.base().rateLimitRelease = release
+		}
+
+		opRetryWrapper := p.rateLimiter.wrapRetryStrategy(retryWrapper.forOperation(deadline, span))
+
 		switch i := item.(type) {
 		case *GetOp:
-			p.Get(i, span, c, transcoder, signal, retryWrapper, time.Now().Add(timeout))
+			p.Get(i, span, c, transcoder, dispatched, opRetryWrapper, deadline)
 		case *GetAndTouchOp:
-			p.GetAndTouch(i, span, c, transcoder, signal, retryWrapper, time.Now().Add(timeout))
+			p.GetAndTouch(i, span, c, transcoder, dispatched, opRetryWrapper, deadline)
 		case *TouchOp:
-			p.Touch(i, span, c, signal, retryWrapper, time.Now().Add(timeout))
+			p.Touch(i, span, c, dispatched, opRetryWrapper, deadline)
 		case *RemoveOp:
-			p.Delete(i, span, c, signal, retryWrapper, time.Now().Add(timeout))
+			p.Delete(i, span, c, dispatched, opRetryWrapper, deadline)
 		case *UpsertOp:
-			p.Set(i, span, c, transcoder, signal, retryWrapper, time.Now().Add(timeout))
+			p.Set(i, span, c, transcoder, dispatched, opRetryWrapper, deadline)
 		case *InsertOp:
-			p.Add(i, span, c, transcoder, signal, retryWrapper, time.Now().Add(timeout))
+			p.Add(i, span, c, transcoder, dispatched, opRetryWrapper, deadline)
 		case *ReplaceOp:
-			p.Replace(i, span, c, transcoder, signal, retryWrapper, time.Now().Add(timeout))
+			p.Replace(i, span, c, transcoder, dispatched, opRetryWrapper, deadline)
 		case *AppendOp:
-			p.Append(i, span, c, signal, retryWrapper, time.Now().Add(timeout))
+			p.Append(i, span, c, dispatched, opRetryWrapper, deadline)
 		case *PrependOp:
-			p.Prepend(i, span, c, signal, retryWrapper, time.Now().Add(timeout))
+			p.Prepend(i, span, c, dispatched, opRetryWrapper, deadline)
 		case *IncrementOp:
-			p.Increment(i, span, c, signal, retryWrapper, time.Now().Add(timeout))
+			p.Increment(i, span, c, dispatched, opRetryWrapper, deadline)
 		case *DecrementOp:
-			p.Decrement(i, span, c, signal, retryWrapper, time.Now().Add(timeout))
+			p.Decrement(i, span, c, dispatched, opRetryWrapper, deadline)
 		}
 	}
 
@@ -72,6 +110,37 @@ func (p *kvBulkProviderCore) Do(c *Collection, ops []BulkOp, opts *BulkOpOptions
 	return nil
 }
 
+// bulkOpErr returns the error field of the op.
+func bulkOpErr(item BulkOp) *error {
+	switch i := item.(type) {
+	case *GetOp:
+		return &i.Err
+	case *GetAndTouchOp:
+		return &i.Err
+	case *TouchOp:
+		return &i.Err
+	case *RemoveOp:
+		return &i.Err
+	case *UpsertOp:
+		return &i.Err
+	case *InsertOp:
+		return &i.Err
+	case *ReplaceOp:
+		return &i.Err
+	case *AppendOp:
+		return &i.Err
+	case *PrependOp:
+		return &i.Err
+	case *IncrementOp:
+		return &i.Err
+	case *DecrementOp:
+		return &i.Err
+	}
+
+	var err error
+	return &err
+}
+
 func (p *kvBulkProviderCore) Get(item *GetOp, parentSpan RequestSpan, c *Collection, transcoder Transcoder, signal chan BulkOp,
 	retryWrapper *coreRetryStrategyWrapper, deadline time.Time) {
 	span := p.StartKvOpTrace(c, "get", parentSpan, false)
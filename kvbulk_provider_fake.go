@@ -0,0 +1,148 @@
+package gocb
+
+import (
+	"context"
+	"time"
+)
+
+// kvBulkProviderFake performs bulk operations one at a time against a fakeStore.
+type kvBulkProviderFake struct {
+	kv    *kvProviderFake
+	meter *meterWrapper
+}
+
+func (p *kvBulkProviderFake) Do(c *Collection, ops []BulkOp, opts *BulkOpOptions) error {
+	span := p.kv.StartKvOpTrace(c, "bulk", opts.ParentSpan, false)
+	defer span.End()
+
+	timeout := opts.Timeout
+	if opts.Timeout == 0 {
+		timeout = c.timeoutsConfig.KVTimeout * time.Duration(len(ops))
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, item := range ops {
+		start := time.Now()
+		var opName string
+		var err error
+		switch i := item.(type) {
+		case *GetOp:
+			opName = "get"
+			i.Result, i.Err = p.kv.Get(c, i.ID, &GetOptions{
+				Transcoder: opts.Transcoder,
+				Timeout:    timeout,
+				ParentSpan: span,
+				Context:    ctx,
+			})
+			err = i.Err
+		case *GetAndTouchOp:
+			opName = "get_and_touch"
+			i.Result, i.Err = p.kv.GetAndTouch(c, i.ID, i.Expiry, &GetAndTouchOptions{
+				Transcoder: opts.Transcoder,
+				Timeout:    timeout,
+				ParentSpan: span,
+				Context:    ctx,
+			})
+			err = i.Err
+		case *TouchOp:
+			opName = "touch"
+			i.Result, i.Err = p.kv.Touch(c, i.ID, i.Expiry, &TouchOptions{
+				Timeout:    timeout,
+				ParentSpan: span,
+				Context:    ctx,
+			})
+			err = i.Err
+		case *RemoveOp:
+			opName = "remove"
+			i.Result, i.Err = p.kv.Remove(c, i.ID, &RemoveOptions{
+				Cas:        i.Cas,
+				Timeout:    timeout,
+				ParentSpan: span,
+				Context:    ctx,
+			})
+			err = i.Err
+		case *UpsertOp:
+			opName = "upsert"
+			i.Result, i.Err = p.kv.Upsert(c, i.ID, i.Value, &UpsertOptions{
+				Expiry:     i.Expiry,
+				Transcoder: opts.Transcoder,
+				Timeout:    timeout,
+				ParentSpan: span,
+				Context:    ctx,
+			})
+			err = i.Err
+		case *InsertOp:
+			opName = "insert"
+			i.Result, i.Err = p.kv.Insert(c, i.ID, i.Value, &InsertOptions{
+				Expiry:     i.Expiry,
+				Transcoder: opts.Transcoder,
+				Timeout:    timeout,
+				ParentSpan: span,
+				Context:    ctx,
+			})
+			err = i.Err
+		case *ReplaceOp:
+			opName = "replace"
+			i.Result, i.Err = p.kv.Replace(c, i.ID, i.Value, &ReplaceOptions{
+				Expiry:     i.Expiry,
+				Cas:        i.Cas,
+				Transcoder: opts.Transcoder,
+				Timeout:    timeout,
+				ParentSpan: span,
+				Context:    ctx,
+			})
+			err = i.Err
+		case *AppendOp:
+			opName = "append"
+			i.Result, i.Err = p.kv.Append(c, i.ID, []byte(i.Value), &AppendOptions{
+				Timeout:    timeout,
+				ParentSpan: span,
+				Context:    ctx,
+			})
+			err = i.Err
+		case *PrependOp:
+			opName = "prepend"
+			i.Result, i.Err = p.kv.Prepend(c, i.ID, []byte(i.Value), &PrependOptions{
+				Timeout:    timeout,
+				ParentSpan: span,
+				Context:    ctx,
+			})
+			err = i.Err
+		case *IncrementOp:
+			opName = "increment"
+			i.Result, i.Err = p.kv.Increment(c, i.ID, &IncrementOptions{
+				Delta:      uint64(i.Delta),
+				Initial:    i.Initial,
+				Expiry:     i.Expiry,
+				Timeout:    timeout,
+				ParentSpan: span,
+				Context:    ctx,
+			})
+			err = i.Err
+		case *DecrementOp:
+			opName = "decrement"
+			i.Result, i.Err = p.kv.Decrement(c, i.ID, &DecrementOptions{
+				Delta:      uint64(i.Delta),
+				Initial:    i.Initial,
+				Expiry:     i.Expiry,
+				Timeout:    timeout,
+				ParentSpan: span,
+				Context:    ctx,
+			})
+			err = i.Err
+		default:
+			continue
+		}
+
+		p.meter.ValueRecord(serviceValueKV, opName, start, &c.keyspace, err)
+	}
+
+	return nil
+}
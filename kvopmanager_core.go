@@ -36,6 +36,10 @@ type kvOpManagerCore struct {
 	operationName string
 	preserveTTL   bool
 
+	rateLimitRelease func(error)
+	opErr            error
+	dispatched       bool
+
 	ctx context.Context
 }
 
@@ -163,6 +167,12 @@ func (m *kvOpManagerCore) SetPreserveExpiry(preserveTTL bool) {
 }
 
 func (m *kvOpManagerCore) Finish() {
+	if m.rateLimitRelease != nil {
+		m.rateLimitRelease(m.opErr)
+	}
+	if m.dispatched {
+		m.kv.circuitBreakers.record(m.DocumentID(), m.opErr)
+	}
 	m.span.End()
 }
 
@@ -252,6 +262,17 @@ func (m *kvOpManagerCore) CheckReadyForOp() error {
 		return errors.New("op manager had no timeout specified")
 	}
 
+	m.retryStrategy = m.retryStrategy.forOperation(m.Deadline(), m.span)
+
+	if m.kv.rateLimiter != nil {
+		release, err := m.kv.rateLimiter.acquire(m.ctx, m.Deadline())
+		if err != nil {
+			return err
+		}
+		m.rateLimitRelease = release
+		m.retryStrategy = m.kv.rateLimiter.wrapRetryStrategy(m.retryStrategy)
+	}
+
 	return nil
 }
 
@@ -260,7 +281,8 @@ func (m *kvOpManagerCore) NeedsObserve() bool {
 }
 
 func (m *kvOpManagerCore) EnhanceErr(err error) error {
-	return maybeEnhanceCollKVErr(err, m.parent, m.documentID)
+	m.opErr = maybeEnhanceCollKVErr(err, m.parent, m.documentID)
+	return m.opErr
 }
 
 func (m *kvOpManagerCore) EnhanceMt(token gocbcore.MutationToken) *MutationToken {
@@ -288,6 +310,7 @@ func (m *kvOpManagerCore) Wait(op gocbcore.PendingOp, err error) error {
 	if err != nil {
 		return err
 	}
+	m.dispatched = true
 	if m.err != nil {
 		op.Cancel()
 	}
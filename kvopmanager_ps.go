@@ -242,6 +242,10 @@ func (m *kvOpManagerPs) Context() context.Context {
 	return m.ctx
 }
 
+func (m *kvOpManagerPs) CircuitBreaker() *observedCircuitBreaker {
+	return m.provider.circuitBreaker
+}
+
 func (m *kvOpManagerPs) Timeout() time.Duration {
 	return m.getTimeout()
 }
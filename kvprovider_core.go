@@ -13,6 +13,8 @@ type kvProviderCore struct {
 	snapshotProvider kvProviderConfigSnapshotProvider
 
 	tracer               *tracerWrapper
+	rateLimiter          *serviceRateLimiter
+	circuitBreakers      *kvCircuitBreakers
 	preferredServerGroup string
 }
 
@@ -20,7 +22,7 @@ var _ kvProvider = &kvProviderCore{}
 
 func (p *kvProviderCore) Scan(c *Collection, scanType ScanType, opts *ScanOptions) (*ScanResult, error) {
 	opm, err := p.newRangeScanOpManager(c, scanType, p.agent, opts.ParentSpan, opts.ConsistentWith,
-		opts.IDsOnly)
+		opts.ResumeFrom, opts.IDsOnly)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,972 @@
+package gocb
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocbcore/v10"
+	"github.com/google/uuid"
+)
+
+const (
+	fakeNumVbuckets = 1024
+
+	// fakeLockedCas is the cas returned when reading a locked document, as the server does.
+	fakeLockedCas = Cas(^uint64(0))
+
+	fakeDefaultLockTime = 15 * time.Second
+	fakeMaxLockTime     = 30 * time.Second
+)
+
+var _ kvProvider = &kvProviderFake{}
+
+type fakeKeyspace struct {
+	bucket     string
+	scope      string
+	collection string
+}
+
+type fakeDocument struct {
+	value  []byte
+	flags  uint32
+	cas    Cas
+	expiry time.Time
+
+	// xattrs is the JSON object holding all of the extended attributes of the document, nil if there are none.
+	xattrs []byte
+
+	lockedUntil time.Time
+	seqNo       uint64
+}
+
+func (d *fakeDocument) isLocked(now time.Time) bool {
+	return now.Before(d.lockedUntil)
+}
+
+// fakeStore holds the documents of a cluster connected using the fake:// scheme.
+type fakeStore struct {
+	lock    sync.Mutex
+	docs    map[fakeKeyspace]map[string]*fakeDocument
+	lastCas uint64
+	seqNos  [fakeNumVbuckets]uint64
+	vbUUID  uint64
+
+	// changes holds the most recent change of each document by vbucket, as a deduplicated stream would deliver them.
+	changes       [fakeNumVbuckets]map[fakeChangeKey]*fakeChange
+	changed       chan struct{}
+	collectionIDs map[fakeKeyspace]uint32
+
+	now  func() time.Time
+	hook FakeHook
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		docs:          make(map[fakeKeyspace]map[string]*fakeDocument),
+		vbUUID:        rand.Uint64(), // #nosec G404
+		changed:       make(chan struct{}),
+		collectionIDs: make(map[fakeKeyspace]uint32),
+		now:           time.Now,
+	}
+}
+
+func (s *fakeStore) setHook(hook FakeHook) {
+	s.lock.Lock()
+	s.hook = hook
+	s.lock.Unlock()
+}
+
+func (s *fakeStore) setClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+
+	s.lock.Lock()
+	s.now = now
+	s.lock.Unlock()
+}
+
+func (s *fakeStore) fault(op FakeOperation) *FakeFault {
+	s.lock.Lock()
+	hook := s.hook
+	s.lock.Unlock()
+
+	if hook == nil {
+		return nil
+	}
+
+	return hook(op)
+}
+
+// get returns the document if it exists, lazily removing it if it has expired. The lock must be held.
+func (s *fakeStore) get(ks fakeKeyspace, id string, now time.Time) *fakeDocument {
+	docs := s.docs[ks]
+	doc, ok := docs[id]
+	if !ok {
+		return nil
+	}
+
+	if !doc.expiry.IsZero() && !now.Before(doc.expiry) {
+		delete(docs, id)
+
+		tombstone := &fakeDocument{cas: s.nextCas(now)}
+		s.nextMutationToken(ks, id, tombstone)
+		s.recordChange(ks, id, ChangeEventExpiration, tombstone)
+		return nil
+	}
+
+	return doc
+}
+
+// store writes the document, assigning it a new cas and sequence number. The lock must be held.
+func (s *fakeStore) store(ks fakeKeyspace, id string, doc *fakeDocument, now time.Time) *MutationToken {
+	doc.cas = s.nextCas(now)
+	doc.lockedUntil = time.Time{}
+
+	docs, ok := s.docs[ks]
+	if !ok {
+		docs = make(map[string]*fakeDocument)
+		s.docs[ks] = docs
+	}
+	docs[id] = doc
+
+	token := s.nextMutationToken(ks, id, doc)
+	s.recordChange(ks, id, ChangeEventMutation, doc)
+
+	return token
+}
+
+// remove deletes the document, returning the mutation token of the removal. The lock must be held.
+func (s *fakeStore) remove(ks fakeKeyspace, id string, now time.Time) (Cas, *MutationToken) {
+	tombstone := &fakeDocument{cas: s.nextCas(now)}
+	delete(s.docs[ks], id)
+
+	token := s.nextMutationToken(ks, id, tombstone)
+	s.recordChange(ks, id, ChangeEventDeletion, tombstone)
+
+	return tombstone.cas, token
+}
+
+// nextCas returns a cas which is derived from the time, as it is on the server, but always increases.
+func (s *fakeStore) nextCas(now time.Time) Cas {
+	cas := uint64(now.UnixNano())
+	if cas <= s.lastCas {
+		cas = s.lastCas + 1
+	}
+	s.lastCas = cas
+
+	return Cas(cas)
+}
+
+func (s *fakeStore) nextMutationToken(ks fakeKeyspace, id string, doc *fakeDocument) *MutationToken {
+	vbID := fakeVbucketForKey(id)
+	s.seqNos[vbID]++
+	doc.seqNo = s.seqNos[vbID]
+
+	return &MutationToken{
+		bucketName: ks.bucket,
+		token: gocbcore.MutationToken{
+			VbID:   vbID,
+			VbUUID: gocbcore.VbUUID(s.vbUUID),
+			SeqNo:  gocbcore.SeqNo(doc.seqNo),
+		},
+	}
+}
+
+func fakeVbucketForKey(id string) uint16 {
+	crc := crc32.ChecksumIEEE([]byte(id))
+	return uint16((crc>>16)&0x7fff) % fakeNumVbuckets
+}
+
+func fakeExpiryTime(expiry time.Duration, now time.Time) time.Time {
+	if expiry <= 0 {
+		return time.Time{}
+	}
+
+	// The server works in seconds and a value of 0 means never expire, so round up as durationToExpiry does.
+	if expiry < time.Second {
+		expiry = time.Second
+	}
+
+	return now.Add(expiry.Truncate(time.Second))
+}
+
+// kvProviderFake serves key-value operations from a fakeStore.
+type kvProviderFake struct {
+	store  *fakeStore
+	tracer *tracerWrapper
+}
+
+// fakeOp tracks a single operation against the store, mirroring the role of the op managers of the other providers.
+type fakeOp struct {
+	provider *kvProviderFake
+	parent   *Collection
+
+	name        string
+	documentID  string
+	span        RequestSpan
+	timeout     time.Duration
+	ctx         context.Context
+	idempotent  bool
+	createdTime time.Time
+
+	req *retriableRequestPs
+}
+
+func (p *kvProviderFake) newOp(c *Collection, opName, id string, parentSpan RequestSpan, timeout time.Duration,
+	ctx context.Context, retryStrategy RetryStrategy, idempotent bool) *fakeOp {
+	span := p.StartKvOpTrace(c, opName, parentSpan, false)
+
+	if timeout == 0 {
+		timeout = c.timeoutsConfig.KVTimeout
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if retryStrategy == nil {
+		retryStrategy = c.retryStrategyWrapper.wrapped
+	}
+
+	return &fakeOp{
+		provider:    p,
+		parent:      c,
+		name:        opName,
+		documentID:  id,
+		span:        span,
+		timeout:     timeout,
+		ctx:         ctx,
+		idempotent:  idempotent,
+		createdTime: time.Now(),
+		req:         newRetriableRequestPS(opName, idempotent, span, uuid.NewString()[:6], retryStrategy),
+	}
+}
+
+func (op *fakeOp) Finish() {
+	op.span.SetAttribute(spanAttribRetries, op.req.RetryAttempts())
+	op.span.End()
+}
+
+func (op *fakeOp) keyspace() fakeKeyspace {
+	return fakeKeyspace{
+		bucket:     op.parent.bucketName(),
+		scope:      op.parent.ScopeName(),
+		collection: op.parent.name(),
+	}
+}
+
+// Run applies fn to the store, first applying any fault injected by the hook. Errors which the SDK would retry are
+// retried using the retry strategy of the operation until it times out.
+func (op *fakeOp) Run(fn func(s *fakeStore, ks fakeKeyspace, now time.Time) error) error {
+	ctx, cancel := context.WithDeadline(op.ctx, op.createdTime.Add(op.timeout))
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); ok {
+		op.req.deadline = deadline
+	}
+
+	for {
+		err := op.attempt(ctx, fn)
+		if err == nil {
+			return nil
+		}
+
+		var tErr *TimeoutError
+		if errors.As(err, &tErr) || errors.Is(err, ErrRequestCanceled) {
+			return err
+		}
+
+		reason := fakeRetryReason(err)
+		if reason == nil {
+			return op.EnhanceErr(err)
+		}
+
+		shouldRetry, retryAfter := retryOrchMaybeRetry(op.req, reason)
+		if !shouldRetry {
+			return op.EnhanceErr(err)
+		}
+
+		select {
+		case <-time.After(time.Until(retryAfter)):
+		case <-ctx.Done():
+			return op.contextErr(ctx)
+		}
+	}
+}
+
+func (op *fakeOp) attempt(ctx context.Context, fn func(s *fakeStore, ks fakeKeyspace, now time.Time) error) error {
+	ks := op.keyspace()
+	fault := op.provider.store.fault(FakeOperation{
+		Name:           op.name,
+		BucketName:     ks.bucket,
+		ScopeName:      ks.scope,
+		CollectionName: ks.collection,
+		DocumentID:     op.documentID,
+	})
+	if fault != nil {
+		if fault.Delay > 0 {
+			select {
+			case <-time.After(fault.Delay):
+			case <-ctx.Done():
+				return op.contextErr(ctx)
+			}
+		}
+		if fault.Timeout {
+			return op.timeoutErr()
+		}
+		if fault.Err != nil {
+			return fault.Err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return op.contextErr(ctx)
+	}
+
+	s := op.provider.store
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return fn(s, ks, s.now())
+}
+
+func (op *fakeOp) contextErr(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return op.timeoutErr()
+	}
+
+	return makeGenericError(ErrRequestCanceled, nil)
+}
+
+func (op *fakeOp) timeoutErr() error {
+	inner := ErrAmbiguousTimeout
+	if op.idempotent {
+		inner = ErrUnambiguousTimeout
+	}
+
+	return &TimeoutError{
+		InnerError:    inner,
+		OperationID:   op.name,
+		Opaque:        op.req.Identifier(),
+		TimeObserved:  time.Since(op.createdTime),
+		RetryReasons:  op.req.RetryReasons(),
+		RetryAttempts: op.req.RetryAttempts(),
+	}
+}
+
+// EnhanceErr wraps errors from the store, or injected by the hook, in a KeyValueError in the same way as errors
+// from the server.
+func (op *fakeOp) EnhanceErr(err error) error {
+	var kvErr *KeyValueError
+	var tErr *TimeoutError
+	var genericErr *GenericError
+	if errors.As(err, &kvErr) || errors.As(err, &tErr) || errors.As(err, &genericErr) {
+		return err
+	}
+
+	ks := op.keyspace()
+	return &KeyValueError{
+		InnerError:     err,
+		DocumentID:     op.documentID,
+		BucketName:     ks.bucket,
+		ScopeName:      ks.scope,
+		CollectionName: ks.collection,
+		RetryReasons:   op.req.RetryReasons(),
+		RetryAttempts:  op.req.RetryAttempts(),
+	}
+}
+
+func fakeRetryReason(err error) RetryReason {
+	switch {
+	case errors.Is(err, ErrTemporaryFailure):
+		return KVTemporaryFailureRetryReason
+	case errors.Is(err, ErrDocumentLocked):
+		return KVLockedRetryReason
+	case errors.Is(err, ErrServiceNotAvailable):
+		return ServiceNotAvailableRetryReason
+	}
+
+	return nil
+}
+
+func (p *kvProviderFake) encode(c *Collection, span RequestSpan, transcoder Transcoder, val interface{}) ([]byte, uint32, Transcoder, error) {
+	if transcoder == nil {
+		transcoder = c.transcoder
+	}
+
+	espan := p.StartKvOpTrace(c, "request_encoding", span, true)
+	defer espan.End()
+
+	bytes, flags, err := transcoder.Encode(val)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	return bytes, flags, transcoder, nil
+}
+
+func (p *kvProviderFake) Insert(c *Collection, id string, val interface{}, opts *InsertOptions) (*MutationResult, error) {
+	op := p.newOp(c, "insert", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy, false)
+	defer op.Finish()
+
+	bytes, flags, _, err := p.encode(c, op.span, opts.Transcoder, val)
+	if err != nil {
+		return nil, err
+	}
+
+	mutOut := &MutationResult{}
+	err = op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		if s.get(ks, id, now) != nil {
+			return ErrDocumentExists
+		}
+
+		doc := &fakeDocument{
+			value:  bytes,
+			flags:  flags,
+			expiry: fakeExpiryTime(opts.Expiry, now),
+		}
+		mutOut.mt = s.store(ks, id, doc, now)
+		mutOut.cas = doc.cas
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mutOut, nil
+}
+
+func (p *kvProviderFake) Upsert(c *Collection, id string, val interface{}, opts *UpsertOptions) (*MutationResult, error) {
+	op := p.newOp(c, "upsert", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy, false)
+	defer op.Finish()
+
+	bytes, flags, _, err := p.encode(c, op.span, opts.Transcoder, val)
+	if err != nil {
+		return nil, err
+	}
+
+	mutOut := &MutationResult{}
+	err = op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		doc := &fakeDocument{
+			value:  bytes,
+			flags:  flags,
+			expiry: fakeExpiryTime(opts.Expiry, now),
+		}
+
+		if existing := s.get(ks, id, now); existing != nil {
+			if existing.isLocked(now) {
+				return ErrDocumentLocked
+			}
+			if opts.PreserveExpiry {
+				doc.expiry = existing.expiry
+			}
+		}
+
+		mutOut.mt = s.store(ks, id, doc, now)
+		mutOut.cas = doc.cas
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mutOut, nil
+}
+
+// checkMutable verifies that a document can be mutated using the cas given, which may unlock a locked document.
+func fakeCheckMutable(doc *fakeDocument, cas Cas, now time.Time) error {
+	if doc.isLocked(now) {
+		if cas != doc.cas {
+			return ErrDocumentLocked
+		}
+
+		return nil
+	}
+
+	if cas != 0 && cas != doc.cas {
+		return ErrCasMismatch
+	}
+
+	return nil
+}
+
+func (p *kvProviderFake) Replace(c *Collection, id string, val interface{}, opts *ReplaceOptions) (*MutationResult, error) {
+	op := p.newOp(c, "replace", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy, false)
+	defer op.Finish()
+
+	bytes, flags, _, err := p.encode(c, op.span, opts.Transcoder, val)
+	if err != nil {
+		return nil, err
+	}
+
+	mutOut := &MutationResult{}
+	err = op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		existing := s.get(ks, id, now)
+		if existing == nil {
+			return ErrDocumentNotFound
+		}
+		if err := fakeCheckMutable(existing, opts.Cas, now); err != nil {
+			return err
+		}
+
+		doc := &fakeDocument{
+			value:  bytes,
+			flags:  flags,
+			expiry: fakeExpiryTime(opts.Expiry, now),
+			xattrs: existing.xattrs,
+		}
+		if opts.PreserveExpiry {
+			doc.expiry = existing.expiry
+		}
+
+		mutOut.mt = s.store(ks, id, doc, now)
+		mutOut.cas = doc.cas
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mutOut, nil
+}
+
+func (p *kvProviderFake) Remove(c *Collection, id string, opts *RemoveOptions) (*MutationResult, error) {
+	op := p.newOp(c, "remove", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy, false)
+	defer op.Finish()
+
+	mutOut := &MutationResult{}
+	err := op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		existing := s.get(ks, id, now)
+		if existing == nil {
+			return ErrDocumentNotFound
+		}
+		if err := fakeCheckMutable(existing, opts.Cas, now); err != nil {
+			return err
+		}
+
+		mutOut.cas, mutOut.mt = s.remove(ks, id, now)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mutOut, nil
+}
+
+func (p *kvProviderFake) Get(c *Collection, id string, opts *GetOptions) (*GetResult, error) {
+	if opts == nil {
+		opts = &GetOptions{}
+	}
+
+	op := p.newOp(c, "get", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy, true)
+	defer op.Finish()
+
+	transcoder := opts.Transcoder
+	if transcoder == nil {
+		transcoder = c.transcoder
+	}
+
+	docOut := &GetResult{transcoder: transcoder}
+	var value []byte
+	err := op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		doc := s.get(ks, id, now)
+		if doc == nil {
+			return ErrDocumentNotFound
+		}
+
+		docOut.cas = doc.cas
+		if doc.isLocked(now) {
+			docOut.cas = fakeLockedCas
+		}
+		docOut.flags = doc.flags
+		if opts.WithExpiry {
+			var expiryTime time.Time
+			if !doc.expiry.IsZero() {
+				expiryTime = time.Unix(doc.expiry.Unix(), 0)
+			}
+			docOut.expiryTime = &expiryTime
+		}
+
+		value = doc.value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.Project) == 0 {
+		docOut.contents = value
+		return docOut, nil
+	}
+
+	return docOut, p.project(op, docOut, value, opts.Project)
+}
+
+// project builds the contents of a projected get by performing the lookups which would otherwise be sent to the
+// server against the value of the document.
+func (p *kvProviderFake) project(op *fakeOp, docOut *GetResult, value []byte, project []string) error {
+	if len(project) > 16 {
+		ops := []LookupInSpec{GetSpec("", nil)}
+		result := &LookupInResult{contents: []lookupInPartial{{data: value, op: ops[0].op}}}
+		return docOut.fromFullProjection(ops, result, project)
+	}
+
+	ops := make([]LookupInSpec, len(project))
+	for i, path := range project {
+		ops[i] = GetSpec(path, nil)
+	}
+
+	result := &LookupInResult{contents: make([]lookupInPartial, len(ops))}
+	body, bodyErr := fakeSubdocDecode(value)
+	for i, spec := range ops {
+		result.contents[i].op = spec.op
+		if bodyErr != nil {
+			result.contents[i].err = op.EnhanceErr(bodyErr)
+			continue
+		}
+
+		data, err := fakeSubdocLookup(body, spec)
+		if err != nil {
+			result.contents[i].err = op.EnhanceErr(err)
+			continue
+		}
+		result.contents[i].data = data
+	}
+
+	return docOut.fromSubDoc(ops, result)
+}
+
+func (p *kvProviderFake) Exists(c *Collection, id string, opts *ExistsOptions) (*ExistsResult, error) {
+	op := p.newOp(c, "exists", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy, true)
+	defer op.Finish()
+
+	resOut := &ExistsResult{}
+	err := op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		doc := s.get(ks, id, now)
+		if doc == nil {
+			return nil
+		}
+
+		resOut.docExists = true
+		resOut.cas = doc.cas
+		if doc.isLocked(now) {
+			resOut.cas = fakeLockedCas
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resOut, nil
+}
+
+func (p *kvProviderFake) GetAndTouch(c *Collection, id string, expiry time.Duration, opts *GetAndTouchOptions) (*GetResult, error) {
+	op := p.newOp(c, "get_and_touch", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy, false)
+	defer op.Finish()
+
+	transcoder := opts.Transcoder
+	if transcoder == nil {
+		transcoder = c.transcoder
+	}
+
+	docOut := &GetResult{transcoder: transcoder}
+	err := op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		doc := s.get(ks, id, now)
+		if doc == nil {
+			return ErrDocumentNotFound
+		}
+		if doc.isLocked(now) {
+			return ErrDocumentLocked
+		}
+
+		doc.expiry = fakeExpiryTime(expiry, now)
+		doc.cas = s.nextCas(now)
+
+		docOut.cas = doc.cas
+		docOut.flags = doc.flags
+		docOut.contents = doc.value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docOut, nil
+}
+
+func (p *kvProviderFake) GetAndLock(c *Collection, id string, lockTime time.Duration, opts *GetAndLockOptions) (*GetResult, error) {
+	op := p.newOp(c, "get_and_lock", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy, false)
+	defer op.Finish()
+
+	transcoder := opts.Transcoder
+	if transcoder == nil {
+		transcoder = c.transcoder
+	}
+
+	if lockTime <= 0 {
+		lockTime = fakeDefaultLockTime
+	} else if lockTime > fakeMaxLockTime {
+		lockTime = fakeMaxLockTime
+	}
+
+	docOut := &GetResult{transcoder: transcoder}
+	err := op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		doc := s.get(ks, id, now)
+		if doc == nil {
+			return ErrDocumentNotFound
+		}
+		if doc.isLocked(now) {
+			return ErrDocumentLocked
+		}
+
+		doc.cas = s.nextCas(now)
+		doc.lockedUntil = now.Add(lockTime)
+
+		docOut.cas = doc.cas
+		docOut.flags = doc.flags
+		docOut.contents = doc.value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docOut, nil
+}
+
+func (p *kvProviderFake) Unlock(c *Collection, id string, cas Cas, opts *UnlockOptions) error {
+	op := p.newOp(c, "unlock", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy, false)
+	defer op.Finish()
+
+	return op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		doc := s.get(ks, id, now)
+		if doc == nil {
+			return ErrDocumentNotFound
+		}
+		if !doc.isLocked(now) {
+			return ErrDocumentNotLocked
+		}
+		if cas != doc.cas {
+			return ErrCasMismatch
+		}
+
+		doc.lockedUntil = time.Time{}
+		return nil
+	})
+}
+
+func (p *kvProviderFake) Touch(c *Collection, id string, expiry time.Duration, opts *TouchOptions) (*MutationResult, error) {
+	op := p.newOp(c, "touch", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy, false)
+	defer op.Finish()
+
+	mutOut := &MutationResult{}
+	err := op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		doc := s.get(ks, id, now)
+		if doc == nil {
+			return ErrDocumentNotFound
+		}
+		if doc.isLocked(now) {
+			return ErrDocumentLocked
+		}
+
+		doc.expiry = fakeExpiryTime(expiry, now)
+		doc.cas = s.nextCas(now)
+
+		mutOut.cas = doc.cas
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mutOut, nil
+}
+
+// fakeReplicasResult returns the results of a replica read, the fake has no replicas so there is only the active.
+type fakeReplicasResult struct {
+	lock    sync.Mutex
+	results []interface{}
+}
+
+func (r *fakeReplicasResult) Next() interface{} {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.results) == 0 {
+		return nil
+	}
+
+	res := r.results[0]
+	r.results = r.results[1:]
+	return res
+}
+
+func (r *fakeReplicasResult) Close() error {
+	r.lock.Lock()
+	r.results = nil
+	r.lock.Unlock()
+
+	return nil
+}
+
+func (p *kvProviderFake) GetAllReplicas(c *Collection, id string, opts *GetAllReplicaOptions) (*GetAllReplicasResult, error) {
+	doc, err := p.Get(c, id, &GetOptions{
+		Transcoder:    opts.Transcoder,
+		Timeout:       opts.Timeout,
+		RetryStrategy: opts.RetryStrategy,
+		ParentSpan:    opts.ParentSpan,
+		Context:       opts.Context,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetAllReplicasResult{
+		res: &fakeReplicasResult{
+			results: []interface{}{&GetReplicaResult{GetResult: *doc}},
+		},
+	}, nil
+}
+
+func (p *kvProviderFake) GetAnyReplica(c *Collection, id string, opts *GetAnyReplicaOptions) (*GetReplicaResult, error) {
+	doc, err := p.Get(c, id, &GetOptions{
+		Transcoder:    opts.Transcoder,
+		Timeout:       opts.Timeout,
+		RetryStrategy: opts.RetryStrategy,
+		ParentSpan:    opts.ParentSpan,
+		Context:       opts.Context,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetReplicaResult{GetResult: *doc}, nil
+}
+
+func (p *kvProviderFake) adjoin(c *Collection, opName, id string, val []byte, cas Cas, parentSpan RequestSpan,
+	timeout time.Duration, ctx context.Context, retryStrategy RetryStrategy, prepend bool) (*MutationResult, error) {
+	op := p.newOp(c, opName, id, parentSpan, timeout, ctx, retryStrategy, false)
+	defer op.Finish()
+
+	mutOut := &MutationResult{}
+	err := op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		existing := s.get(ks, id, now)
+		if existing == nil {
+			return ErrDocumentNotFound
+		}
+		if err := fakeCheckMutable(existing, cas, now); err != nil {
+			return err
+		}
+
+		value := make([]byte, 0, len(existing.value)+len(val))
+		if prepend {
+			value = append(append(value, val...), existing.value...)
+		} else {
+			value = append(append(value, existing.value...), val...)
+		}
+
+		doc := &fakeDocument{
+			value:  value,
+			flags:  existing.flags,
+			expiry: existing.expiry,
+			xattrs: existing.xattrs,
+		}
+		mutOut.mt = s.store(ks, id, doc, now)
+		mutOut.cas = doc.cas
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mutOut, nil
+}
+
+func (p *kvProviderFake) Append(c *Collection, id string, val []byte, opts *AppendOptions) (*MutationResult, error) {
+	return p.adjoin(c, "append", id, val, opts.Cas, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy,
+		false)
+}
+
+func (p *kvProviderFake) Prepend(c *Collection, id string, val []byte, opts *PrependOptions) (*MutationResult, error) {
+	return p.adjoin(c, "prepend", id, val, opts.Cas, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy,
+		true)
+}
+
+func (p *kvProviderFake) counter(c *Collection, opName, id string, delta uint64, initial int64, expiry time.Duration,
+	parentSpan RequestSpan, timeout time.Duration, ctx context.Context, retryStrategy RetryStrategy,
+	decrement bool) (*CounterResult, error) {
+	op := p.newOp(c, opName, id, parentSpan, timeout, ctx, retryStrategy, false)
+	defer op.Finish()
+
+	countOut := &CounterResult{}
+	err := op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		existing := s.get(ks, id, now)
+		if existing == nil {
+			if initial < 0 {
+				return ErrDocumentNotFound
+			}
+
+			doc := &fakeDocument{
+				value:  []byte(strconv.FormatUint(uint64(initial), 10)),
+				expiry: fakeExpiryTime(expiry, now),
+			}
+			countOut.mt = s.store(ks, id, doc, now)
+			countOut.cas = doc.cas
+			countOut.content = uint64(initial)
+			return nil
+		}
+		if existing.isLocked(now) {
+			return ErrDocumentLocked
+		}
+
+		current, err := strconv.ParseUint(string(existing.value), 10, 64)
+		if err != nil {
+			return ErrDeltaInvalid
+		}
+
+		if decrement {
+			if delta > current {
+				current = 0
+			} else {
+				current -= delta
+			}
+		} else {
+			current += delta
+		}
+
+		doc := &fakeDocument{
+			value:  []byte(strconv.FormatUint(current, 10)),
+			flags:  existing.flags,
+			expiry: existing.expiry,
+			xattrs: existing.xattrs,
+		}
+		countOut.mt = s.store(ks, id, doc, now)
+		countOut.cas = doc.cas
+		countOut.content = current
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return countOut, nil
+}
+
+func (p *kvProviderFake) Increment(c *Collection, id string, opts *IncrementOptions) (*CounterResult, error) {
+	return p.counter(c, "increment", id, opts.Delta, opts.Initial, opts.Expiry, opts.ParentSpan, opts.Timeout,
+		opts.Context, opts.RetryStrategy, false)
+}
+
+func (p *kvProviderFake) Decrement(c *Collection, id string, opts *DecrementOptions) (*CounterResult, error) {
+	return p.counter(c, "decrement", id, opts.Delta, opts.Initial, opts.Expiry, opts.ParentSpan, opts.Timeout,
+		opts.Context, opts.RetryStrategy, true)
+}
+
+func (p *kvProviderFake) StartKvOpTrace(c *Collection, operationName string, parentSpan RequestSpan, noAttributes bool) RequestSpan {
+	return c.startKvOpTrace(operationName, parentSpan, p.tracer, noAttributes)
+}
@@ -0,0 +1,376 @@
+package gocb
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+func (suite *UnitTestSuite) fakeCollection() (*Cluster, *Collection) {
+	cluster, err := Connect("fake://", ClusterOptions{
+		Tracer: &NoopTracer{},
+		Meter:  &NoopMeter{},
+	})
+	suite.Require().NoError(err)
+
+	return cluster, cluster.Bucket("default").DefaultCollection()
+}
+
+func (suite *UnitTestSuite) TestFakeSubdocPathParsing() {
+	type test struct {
+		path     string
+		expected []fakeSubdocPathElem
+		err      error
+	}
+
+	tests := []test{
+		{path: "", expected: nil},
+		{path: "a", expected: []fakeSubdocPathElem{{key: "a"}}},
+		{path: "a.b", expected: []fakeSubdocPathElem{{key: "a"}, {key: "b"}}},
+		{path: "a[0].b", expected: []fakeSubdocPathElem{{key: "a"}, {index: 0, isIndex: true}, {key: "b"}}},
+		{path: "a[-1][2]", expected: []fakeSubdocPathElem{{key: "a"}, {index: -1, isIndex: true}, {index: 2, isIndex: true}}},
+		{path: "[1]", expected: []fakeSubdocPathElem{{index: 1, isIndex: true}}},
+		{path: "`a.b`.c", expected: []fakeSubdocPathElem{{key: "a.b"}, {key: "c"}}},
+		{path: "`a``b`", expected: []fakeSubdocPathElem{{key: "a`b"}}},
+		{path: "a.", err: ErrPathInvalid},
+		{path: ".a", err: ErrPathInvalid},
+		{path: "a..b", err: ErrPathInvalid},
+		{path: "a[x]", err: ErrPathInvalid},
+		{path: "a[1", err: ErrPathInvalid},
+		{path: "`a", err: ErrPathInvalid},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.path, func() {
+			elems, err := parseFakeSubdocPath(tt.path)
+			if tt.err != nil {
+				suite.Assert().ErrorIs(err, tt.err)
+				return
+			}
+
+			suite.Require().NoError(err)
+			suite.Assert().Equal(tt.expected, elems)
+		})
+	}
+}
+
+func (suite *UnitTestSuite) TestFakeMutateInArrays() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	_, err := col.Upsert("doc", map[string]interface{}{"arr": []int{2, 3}, "n": 5}, nil)
+	suite.Require().NoError(err)
+
+	_, err = col.MutateIn("doc", []MutateInSpec{
+		ArrayPrependSpec("arr", 1, nil),
+		ArrayAppendSpec("arr", []int{4, 5}, &ArrayAppendSpecOptions{HasMultiple: true}),
+		ArrayInsertSpec("arr[1]", 9, nil),
+		RemoveSpec("arr[-1]", nil),
+		ArrayAddUniqueSpec("set", "x", &ArrayAddUniqueSpecOptions{CreatePath: true}),
+		DecrementSpec("n", 2, nil),
+	}, nil)
+	suite.Require().NoError(err)
+
+	res, err := col.Get("doc", nil)
+	suite.Require().NoError(err)
+
+	var doc struct {
+		Arr []int    `json:"arr"`
+		Set []string `json:"set"`
+		N   int      `json:"n"`
+	}
+	suite.Require().NoError(res.Content(&doc))
+	suite.Assert().Equal([]int{1, 9, 2, 3, 4}, doc.Arr)
+	suite.Assert().Equal([]string{"x"}, doc.Set)
+	suite.Assert().Equal(3, doc.N)
+
+	_, err = col.MutateIn("doc", []MutateInSpec{ArrayAddUniqueSpec("set", "x", nil)}, nil)
+	suite.Assert().ErrorIs(err, ErrPathExists)
+
+	_, err = col.MutateIn("doc", []MutateInSpec{ArrayAppendSpec("n", 1, nil)}, nil)
+	suite.Assert().ErrorIs(err, ErrPathMismatch)
+
+	_, err = col.MutateIn("doc", []MutateInSpec{IncrementSpec("arr", 1, nil)}, nil)
+	suite.Assert().ErrorIs(err, ErrPathMismatch)
+
+	_, err = col.MutateIn("doc", []MutateInSpec{ArrayInsertSpec("arr[10]", 1, nil)}, nil)
+	suite.Assert().ErrorIs(err, ErrPathNotFound)
+
+	_, err = col.MutateIn("doc", []MutateInSpec{UpsertSpec("$document.foo", 1, &UpsertSpecOptions{IsXattr: true})}, nil)
+	suite.Assert().ErrorIs(err, ErrXattrCannotModifyVirtualAttribute)
+}
+
+func (suite *UnitTestSuite) TestFakeMutateInDocumentLevel() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	_, err := col.MutateIn("doc", []MutateInSpec{UpsertSpec("a", 1, nil)}, nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+
+	_, err = col.MutateIn("doc", []MutateInSpec{
+		UpsertSpec("a", 1, nil),
+		UpsertSpec("meta", "x", &UpsertSpecOptions{IsXattr: true}),
+	}, &MutateInOptions{StoreSemantic: StoreSemanticsUpsert})
+	suite.Require().NoError(err)
+
+	// Replacing the body keeps the xattrs.
+	_, err = col.Replace("doc", map[string]int{"b": 2}, nil)
+	suite.Require().NoError(err)
+
+	res, err := col.LookupIn("doc", []LookupInSpec{
+		GetSpec("meta", &GetSpecOptions{IsXattr: true}),
+		GetSpec("", nil),
+	}, nil)
+	suite.Require().NoError(err)
+
+	var meta string
+	suite.Require().NoError(res.ContentAt(0, &meta))
+	suite.Assert().Equal("x", meta)
+
+	var body map[string]int
+	suite.Require().NoError(res.ContentAt(1, &body))
+	suite.Assert().Equal(map[string]int{"b": 2}, body)
+
+	_, err = col.MutateIn("doc", []MutateInSpec{RemoveSpec("", nil)}, nil)
+	suite.Require().NoError(err)
+
+	_, err = col.Get("doc", nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+
+	_, err = col.Upsert("binary", []byte{0x01, 0x02}, &UpsertOptions{Transcoder: NewRawBinaryTranscoder()})
+	suite.Require().NoError(err)
+
+	_, err = col.MutateIn("binary", []MutateInSpec{UpsertSpec("a", 1, nil)}, nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotJSON)
+}
+
+func (suite *UnitTestSuite) TestFakeBulk() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	ops := []BulkOp{
+		&UpsertOp{ID: "a", Value: "1"},
+		&InsertOp{ID: "b", Value: "2"},
+		&IncrementOp{ID: "c", Delta: 1, Initial: 5},
+	}
+	suite.Require().NoError(col.Do(ops, nil))
+
+	for _, op := range ops {
+		switch i := op.(type) {
+		case *UpsertOp:
+			suite.Assert().NoError(i.Err)
+		case *InsertOp:
+			suite.Assert().NoError(i.Err)
+		case *IncrementOp:
+			suite.Require().NoError(i.Err)
+			suite.Assert().Equal(uint64(5), i.Result.Content())
+		}
+	}
+
+	getOps := []BulkOp{&GetOp{ID: "a"}, &GetOp{ID: "missing"}}
+	suite.Require().NoError(col.Do(getOps, nil))
+	suite.Assert().NoError(getOps[0].(*GetOp).Err)
+	suite.Assert().ErrorIs(getOps[1].(*GetOp).Err, ErrDocumentNotFound)
+}
+
+func (suite *UnitTestSuite) TestFakeHookSeesEachAttempt() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	var lock sync.Mutex
+	var seen []FakeOperation
+	failures := 2
+	err := cluster.Internal().SetFakeHook(func(op FakeOperation) *FakeFault {
+		lock.Lock()
+		defer lock.Unlock()
+
+		seen = append(seen, op)
+		if failures > 0 {
+			failures--
+			return &FakeFault{Err: ErrTemporaryFailure}
+		}
+		return nil
+	})
+	suite.Require().NoError(err)
+
+	_, err = col.Upsert("doc", "v", nil)
+	suite.Require().NoError(err)
+
+	lock.Lock()
+	defer lock.Unlock()
+	suite.Require().Len(seen, 3)
+	suite.Assert().Equal(FakeOperation{
+		Name:           "upsert",
+		BucketName:     "default",
+		ScopeName:      "_default",
+		CollectionName: "_default",
+		DocumentID:     "doc",
+	}, seen[0])
+}
+
+func (suite *UnitTestSuite) TestFakeDeadlineAwareRetries() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	var lock sync.Mutex
+	var attempts int
+	err := cluster.Internal().SetFakeHook(func(op FakeOperation) *FakeFault {
+		lock.Lock()
+		defer lock.Unlock()
+
+		attempts++
+		return &FakeFault{Err: ErrTemporaryFailure}
+	})
+	suite.Require().NoError(err)
+
+	// The retry would not happen before the deadline so the underlying error is returned, rather than a timeout.
+	_, err = col.Upsert("doc", "v", &UpsertOptions{
+		Timeout: 50 * time.Millisecond,
+		RetryStrategy: NewDeadlineAwareRetryStrategy(NewBestEffortRetryStrategy(func(uint32) time.Duration {
+			return time.Second
+		})),
+	})
+	suite.Assert().ErrorIs(err, ErrTemporaryFailure)
+
+	lock.Lock()
+	defer lock.Unlock()
+	suite.Assert().Equal(1, attempts)
+}
+
+func (suite *UnitTestSuite) TestFakeClock() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	now := time.Now()
+	suite.Require().NoError(cluster.Internal().SetFakeClock(func() time.Time {
+		return now
+	}))
+
+	_, err := col.Upsert("doc", "v", &UpsertOptions{Expiry: 500 * time.Millisecond})
+	suite.Require().NoError(err)
+
+	res, err := col.LookupIn("doc", []LookupInSpec{GetSpec("$document.exptime", &GetSpecOptions{IsXattr: true})}, nil)
+	suite.Require().NoError(err)
+	var exptime int64
+	suite.Require().NoError(res.ContentAt(0, &exptime))
+	suite.Assert().Equal(now.Add(time.Second).Unix(), exptime)
+
+	now = now.Add(time.Second)
+	_, err = col.Get("doc", nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+}
+
+func (suite *UnitTestSuite) TestFakeHooksRequireFakeScheme() {
+	cluster := suite.newCluster(&mockConnectionManager{})
+
+	suite.Assert().ErrorIs(cluster.Internal().SetFakeHook(nil), ErrFeatureNotAvailable)
+	suite.Assert().ErrorIs(cluster.Internal().SetFakeClock(nil), ErrFeatureNotAvailable)
+}
+
+func (suite *UnitTestSuite) TestFakeScan() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	for _, id := range []string{"b::2", "a::1", "b::1", "c", "b::3"} {
+		_, err := col.Upsert(id, id, nil)
+		suite.Require().NoError(err)
+	}
+
+	scanIDs := func(scanType ScanType, opts *ScanOptions) []string {
+		res, err := col.Scan(scanType, opts)
+		suite.Require().NoError(err)
+
+		var ids []string
+		for item := res.Next(); item != nil; item = res.Next() {
+			ids = append(ids, item.ID())
+		}
+		suite.Require().NoError(res.Err())
+		return ids
+	}
+
+	suite.Assert().Equal([]string{"b::1", "b::2", "b::3"}, scanIDs(NewRangeScanForPrefix("b::"), nil))
+	suite.Assert().Equal([]string{"b::2", "b::3", "c"}, scanIDs(RangeScan{
+		From: &ScanTerm{Term: "b::1", Exclusive: true},
+		To:   &ScanTerm{Term: "c"},
+	}, nil))
+	suite.Assert().Len(scanIDs(SamplingScan{Limit: 2, Seed: 1}, nil), 2)
+
+	res, err := col.Scan(NewRangeScanForPrefix("a::"), &ScanOptions{IDsOnly: true})
+	suite.Require().NoError(err)
+	item := res.Next()
+	suite.Require().NotNil(item)
+	suite.Assert().True(item.IDOnly())
+	suite.Assert().Error(item.Content(new(string)))
+
+	res, err = col.Scan(NewRangeScanForPrefix("a::"), nil)
+	suite.Require().NoError(err)
+	var content string
+	suite.Require().NoError(res.Next().Content(&content))
+	suite.Assert().Equal("a::1", content)
+	suite.Require().NoError(res.Close())
+
+	_, err = col.Scan(SamplingScan{}, nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
+
+func (suite *UnitTestSuite) TestFakeScanResume() {
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	expected := make(map[string]struct{})
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("doc-%02d", i)
+		_, err := col.Upsert(id, i, nil)
+		suite.Require().NoError(err)
+		expected[id] = struct{}{}
+	}
+
+	res, err := col.Scan(NewRangeScanForPrefix("doc-"), nil)
+	suite.Require().NoError(err)
+
+	seen := make(map[string]struct{})
+	for i := 0; i < 7; i++ {
+		item := res.Next()
+		suite.Require().NotNil(item)
+		seen[item.ID()] = struct{}{}
+	}
+	progress := res.Progress()
+	suite.Assert().Equal(fakeNumVbuckets, progress.VbucketsTotal)
+	suite.Assert().EqualValues(7, progress.ItemsStreamed)
+	suite.Assert().NotZero(progress.BytesStreamed)
+
+	// The token survives a process restart.
+	data, err := json.Marshal(res.ResumeToken())
+	suite.Require().NoError(err)
+	suite.Require().NoError(res.Close())
+
+	var token ScanResumeToken
+	suite.Require().NoError(json.Unmarshal(data, &token))
+
+	res, err = col.Scan(NewRangeScanForPrefix("doc-"), &ScanOptions{ResumeFrom: &token})
+	suite.Require().NoError(err)
+	for item := res.Next(); item != nil; item = res.Next() {
+		suite.Assert().NotContains(seen, item.ID())
+		seen[item.ID()] = struct{}{}
+	}
+	suite.Require().NoError(res.Err())
+	suite.Assert().Equal(expected, seen)
+
+	progress = res.Progress()
+	suite.Assert().Equal(fakeNumVbuckets, progress.VbucketsCompleted)
+	suite.Assert().EqualValues(13, progress.ItemsStreamed)
+
+	_, err = col.Scan(NewRangeScanForPrefix("other-"), &ScanOptions{ResumeFrom: &token})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	other := cluster.Bucket("default").Scope("scope").Collection("other")
+	_, err = other.Scan(NewRangeScanForPrefix("doc-"), &ScanOptions{ResumeFrom: &token})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	res, err = col.Scan(SamplingScan{Limit: 2}, nil)
+	suite.Require().NoError(err)
+	suite.Assert().Nil(res.ResumeToken())
+	_, err = col.Scan(SamplingScan{Limit: 2}, &ScanOptions{ResumeFrom: &token})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
@@ -0,0 +1,155 @@
+package gocb
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+func (p *kvProviderFake) Scan(c *Collection, scanType ScanType, opts *ScanOptions) (*ScanResult, error) {
+	var rangeScan *RangeScan
+	var samplingScan *SamplingScan
+	switch st := scanType.(type) {
+	case RangeScan:
+		rangeScan = &st
+	case *RangeScan:
+		rangeScan = st
+	case SamplingScan:
+		samplingScan = &st
+	case *SamplingScan:
+		samplingScan = st
+	default:
+		return nil, makeInvalidArgumentsError("only RangeScan and SamplingScan are supported for ScanType")
+	}
+	if samplingScan != nil && samplingScan.Limit == 0 {
+		return nil, makeInvalidArgumentsError("sampling scan limit must be greater than 0")
+	}
+
+	var resumeToken *ScanResumeToken
+	if opts.ResumeFrom != nil {
+		if samplingScan != nil {
+			return nil, makeInvalidArgumentsError("sampling scans cannot be resumed")
+		}
+		if err := opts.ResumeFrom.validate(c, *rangeScan); err != nil {
+			return nil, err
+		}
+		if opts.ResumeFrom.numVbuckets != fakeNumVbuckets {
+			return nil, makeInvalidArgumentsError("resume token was created by a scan of a bucket with a different " +
+				"number of vbuckets")
+		}
+	}
+	if rangeScan != nil {
+		resumeToken = newScanResumeToken(c, *rangeScan, fakeNumVbuckets, nil)
+	}
+
+	op := p.newOp(c, "range_scan", "", opts.ParentSpan, opts.Timeout, opts.Context, nil, true)
+	defer op.Finish()
+
+	transcoder := opts.Transcoder
+	if transcoder == nil {
+		transcoder = c.transcoder
+	}
+
+	var items []*ScanResultItem
+	err := op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		items = items[:0]
+		for id := range s.docs[ks] {
+			if rangeScan != nil && !fakeScanInRange(*rangeScan, id) {
+				continue
+			}
+
+			vbID := fakeVbucketForKey(id)
+			if opts.ResumeFrom.complete(vbID) {
+				continue
+			}
+			if lastKey := opts.ResumeFrom.lastKey(vbID); lastKey != nil && bytes.Compare([]byte(id), lastKey) <= 0 {
+				continue
+			}
+
+			doc := s.get(ks, id, now)
+			if doc == nil {
+				continue
+			}
+
+			item := &ScanResultItem{
+				Result:     Result{cas: doc.cas},
+				transcoder: transcoder,
+				id:         id,
+				keysOnly:   opts.IDsOnly,
+				vbID:       vbID,
+			}
+			if !opts.IDsOnly {
+				item.flags = doc.flags
+				item.contents = doc.value
+				if !doc.expiry.IsZero() {
+					item.expiryTime = time.Unix(doc.expiry.Unix(), 0)
+				}
+			}
+			items = append(items, item)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The server returns the keys of each vbucket in order, as the fake has no vbuckets all of the keys are in order.
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].id < items[j].id
+	})
+
+	var limit uint64
+	if samplingScan != nil {
+		limit = samplingScan.Limit
+		rnd := rand.New(rand.NewSource(int64(samplingScan.Seed))) // #nosec G404
+		rnd.Shuffle(len(items), func(i, j int) {
+			items[i], items[j] = items[j], items[i]
+		})
+	}
+
+	// Every vbucket is scanned up front, so is complete once its items have been returned.
+	progress := newScanProgressTracker(resumeToken, fakeNumVbuckets, opts.ResumeFrom)
+	resultCh := make(chan *ScanResultItem, len(items))
+	for _, item := range items {
+		progress.itemProduced(item.vbID)
+		resultCh <- item
+	}
+	close(resultCh)
+	for vbID := uint16(0); vbID < fakeNumVbuckets; vbID++ {
+		progress.partitionScanned(vbID)
+	}
+
+	res := &ScanResult{
+		resultChan: resultCh,
+		ctx:        op.ctx,
+		progress:   progress,
+		limit:      limit,
+	}
+	res.cancelFn = func(err error) {
+		if err != nil && err != ErrRequestCanceled {
+			res.setErr(err)
+		}
+	}
+
+	return res, nil
+}
+
+func fakeScanInRange(scan RangeScan, id string) bool {
+	key := []byte(id)
+	if scan.From != nil {
+		cmp := bytes.Compare(key, []byte(scan.From.Term))
+		if cmp < 0 || (cmp == 0 && scan.From.Exclusive) {
+			return false
+		}
+	}
+	if scan.To != nil {
+		cmp := bytes.Compare(key, []byte(scan.To.Term))
+		if cmp > 0 || (cmp == 0 && scan.To.Exclusive) {
+			return false
+		}
+	}
+
+	return true
+}
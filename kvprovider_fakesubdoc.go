@@ -0,0 +1,890 @@
+package gocb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocbcore/v10"
+	"github.com/couchbase/gocbcore/v10/memd"
+)
+
+const (
+	fakeMaxSubdocSpecs = 16
+
+	fakeVirtualXattrDocument = "$document"
+)
+
+var fakeCrc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+type fakeSubdocPathElem struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseFakeSubdocPath parses a sub-document path such as a.b[0].`c.d` into its elements.
+func parseFakeSubdocPath(path string) ([]fakeSubdocPathElem, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	var elems []fakeSubdocPathElem
+	var key strings.Builder
+	hasKey := false
+	i := 0
+	for i < len(path) {
+		switch ch := path[i]; ch {
+		case '`':
+			i++
+			for {
+				if i >= len(path) {
+					return nil, ErrPathInvalid
+				}
+				if path[i] == '`' {
+					// A doubled backtick is an escaped backtick.
+					if i+1 < len(path) && path[i+1] == '`' {
+						key.WriteByte('`')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				key.WriteByte(path[i])
+				i++
+			}
+			hasKey = true
+		case '.':
+			if !hasKey {
+				if len(elems) == 0 || !elems[len(elems)-1].isIndex || i == len(path)-1 {
+					return nil, ErrPathInvalid
+				}
+			} else {
+				elems = append(elems, fakeSubdocPathElem{key: key.String()})
+				key.Reset()
+				hasKey = false
+			}
+			i++
+			if i == len(path) {
+				return nil, ErrPathInvalid
+			}
+		case '[':
+			if hasKey {
+				elems = append(elems, fakeSubdocPathElem{key: key.String()})
+				key.Reset()
+				hasKey = false
+			} else if len(elems) == 0 && i != 0 {
+				return nil, ErrPathInvalid
+			}
+
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, ErrPathInvalid
+			}
+			idx, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil {
+				return nil, ErrPathInvalid
+			}
+			elems = append(elems, fakeSubdocPathElem{index: idx, isIndex: true})
+			i += end + 1
+		case ']':
+			return nil, ErrPathInvalid
+		default:
+			key.WriteByte(ch)
+			hasKey = true
+			i++
+		}
+	}
+	if hasKey {
+		elems = append(elems, fakeSubdocPathElem{key: key.String()})
+	}
+
+	return elems, nil
+}
+
+func fakeSubdocDecode(value []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+
+	var out interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, ErrDocumentNotJSON
+	}
+	if dec.More() {
+		return nil, ErrDocumentNotJSON
+	}
+
+	return out, nil
+}
+
+func fakeSubdocDecodeXattrs(xattrs []byte) (map[string]interface{}, error) {
+	if len(xattrs) == 0 {
+		return make(map[string]interface{}), nil
+	}
+
+	root, err := fakeSubdocDecode(xattrs)
+	if err != nil {
+		return nil, err
+	}
+
+	out, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, ErrDocumentNotJSON
+	}
+
+	return out, nil
+}
+
+func fakeSubdocEncode(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// fakeSubdocChild returns the child of node identified by elem, a negative index counts from the end of an array.
+func fakeSubdocChild(node interface{}, elem fakeSubdocPathElem) (interface{}, bool, error) {
+	if elem.isIndex {
+		arr, ok := node.([]interface{})
+		if !ok {
+			return nil, false, ErrPathMismatch
+		}
+
+		idx := elem.index
+		if idx < 0 {
+			idx += len(arr)
+		}
+		if idx < 0 || idx >= len(arr) {
+			return nil, false, nil
+		}
+
+		return arr[idx], true, nil
+	}
+
+	obj, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, false, ErrPathMismatch
+	}
+
+	child, ok := obj[elem.key]
+	return child, ok, nil
+}
+
+func fakeSubdocSetChild(node interface{}, elem fakeSubdocPathElem, value interface{}) (interface{}, error) {
+	if elem.isIndex {
+		arr, ok := node.([]interface{})
+		if !ok {
+			return nil, ErrPathMismatch
+		}
+
+		idx := elem.index
+		if idx < 0 {
+			idx += len(arr)
+		}
+		if idx < 0 || idx >= len(arr) {
+			return nil, ErrPathNotFound
+		}
+
+		arr[idx] = value
+		return arr, nil
+	}
+
+	obj, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, ErrPathMismatch
+	}
+
+	obj[elem.key] = value
+	return obj, nil
+}
+
+// fakeSubdocApply walks path from node, creating missing objects along the way if createPath is set, and calls fn
+// with the container of the final element. fn returns the new container, which replaces the old one in its parent.
+func fakeSubdocApply(node interface{}, path []fakeSubdocPathElem, createPath bool,
+	fn func(container interface{}, elem fakeSubdocPathElem) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	child, found, err := fakeSubdocChild(node, path[0])
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if !createPath || path[0].isIndex || path[1].isIndex {
+			return nil, ErrPathNotFound
+		}
+		child = make(map[string]interface{})
+	}
+
+	newChild, err := fakeSubdocApply(child, path[1:], createPath, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	return fakeSubdocSetChild(node, path[0], newChild)
+}
+
+func fakeSubdocFind(root interface{}, path []fakeSubdocPathElem) (interface{}, error) {
+	node := root
+	for _, elem := range path {
+		child, found, err := fakeSubdocChild(node, elem)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, ErrPathNotFound
+		}
+		node = child
+	}
+
+	return node, nil
+}
+
+// fakeSubdocLookup performs a lookup spec against root, returning the value of the spec as the server would.
+func fakeSubdocLookup(root interface{}, spec LookupInSpec) ([]byte, error) {
+	path, err := parseFakeSubdocPath(spec.path)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := fakeSubdocFind(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch spec.op {
+	case memd.SubDocOpGet, memd.SubDocOpGetDoc:
+		return fakeSubdocEncode(value)
+	case memd.SubDocOpExists:
+		return []byte("true"), nil
+	case memd.SubDocOpGetCount:
+		switch v := value.(type) {
+		case []interface{}:
+			return []byte(strconv.Itoa(len(v))), nil
+		case map[string]interface{}:
+			return []byte(strconv.Itoa(len(v))), nil
+		default:
+			return nil, ErrPathMismatch
+		}
+	}
+
+	return nil, makeInvalidArgumentsError("unknown lookupin op")
+}
+
+// fakeVirtualXattrs returns the virtual extended attributes of a document.
+func fakeVirtualXattrs(doc *fakeDocument) map[string]interface{} {
+	var exptime int64
+	if !doc.expiry.IsZero() {
+		exptime = doc.expiry.Unix()
+	}
+
+	return map[string]interface{}{
+		fakeVirtualXattrDocument: map[string]interface{}{
+			"CAS":           fakeMacroCas(doc.cas),
+			"seqno":         fmt.Sprintf("0x%016x", doc.seqNo),
+			"exptime":       json.Number(strconv.FormatInt(exptime, 10)),
+			"flags":         json.Number(strconv.FormatUint(uint64(doc.flags), 10)),
+			"value_bytes":   json.Number(strconv.Itoa(len(doc.value))),
+			"value_crc32c":  fakeMacroCrc32c(doc.value),
+			"deleted":       false,
+			"last_modified": strconv.FormatUint(uint64(doc.cas)/uint64(time.Second), 10),
+			"datatype":      []interface{}{"json"},
+		},
+	}
+}
+
+// fakeMacroCas formats a cas in the same way as the server expands the ${Mutation.CAS} macro.
+func fakeMacroCas(cas Cas) string {
+	return fmt.Sprintf("0x%016x", bits.ReverseBytes64(uint64(cas)))
+}
+
+func fakeMacroCrc32c(value []byte) string {
+	return fmt.Sprintf("0x%08x", crc32.Checksum(value, fakeCrc32cTable))
+}
+
+// fakeXattrRoot returns the root to perform an xattr spec against, which is the virtual attributes if the path
+// refers to one.
+func fakeXattrRoot(path string, xattrs map[string]interface{}, doc *fakeDocument) (interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return xattrs, nil
+	}
+
+	if path != fakeVirtualXattrDocument && !strings.HasPrefix(path, fakeVirtualXattrDocument+".") {
+		return nil, ErrXattrUnknownVirtualAttribute
+	}
+
+	return fakeVirtualXattrs(doc), nil
+}
+
+func (p *kvProviderFake) LookupIn(c *Collection, id string, ops []LookupInSpec, opts *LookupInOptions) (*LookupInResult, error) {
+	op := p.newOp(c, "lookup_in", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy, true)
+	defer op.Finish()
+
+	return p.lookupIn(op, ops)
+}
+
+func (p *kvProviderFake) lookupIn(op *fakeOp, ops []LookupInSpec) (*LookupInResult, error) {
+	if len(ops) > fakeMaxSubdocSpecs {
+		return nil, makeInvalidArgumentsError("too many specs, the maximum is 16")
+	}
+	for _, spec := range ops {
+		if spec.path == "" && spec.isXattr {
+			return nil, makeInvalidArgumentsError("invalid xattr fetch with no path")
+		}
+	}
+
+	docOut := &LookupInResult{}
+	err := op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		doc := s.get(ks, op.documentID, now)
+		if doc == nil {
+			return ErrDocumentNotFound
+		}
+
+		docOut.cas = doc.cas
+		if doc.isLocked(now) {
+			docOut.cas = fakeLockedCas
+		}
+		docOut.contents = make([]lookupInPartial, len(ops))
+
+		var body interface{}
+		var bodyErr error
+		bodyDecoded := false
+		var xattrs map[string]interface{}
+		for i, spec := range ops {
+			docOut.contents[i].op = spec.op
+
+			var root interface{}
+			var err error
+			if spec.isXattr {
+				if xattrs == nil {
+					xattrs, err = fakeSubdocDecodeXattrs(doc.xattrs)
+				}
+				if err == nil {
+					root, err = fakeXattrRoot(spec.path, xattrs, doc)
+				}
+			} else {
+				if !bodyDecoded {
+					body, bodyErr = fakeSubdocDecode(doc.value)
+					bodyDecoded = true
+				}
+				root, err = body, bodyErr
+			}
+
+			var data []byte
+			if spec.path == "" && spec.op == memd.SubDocOpGet {
+				// The full document is returned as it was stored rather than re-encoded.
+				data, err = doc.value, nil
+			} else if err == nil {
+				data, err = fakeSubdocLookup(root, spec)
+			}
+
+			if spec.op == memd.SubDocOpExists {
+				if err == nil {
+					data = []byte("true")
+				} else if errors.Is(err, ErrPathNotFound) {
+					data = []byte("false")
+					err = nil
+				}
+			}
+
+			if err != nil {
+				docOut.contents[i].err = op.EnhanceErr(err)
+				continue
+			}
+			docOut.contents[i].data = data
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docOut, nil
+}
+
+func (p *kvProviderFake) LookupInAnyReplica(c *Collection, id string, ops []LookupInSpec,
+	opts *LookupInAnyReplicaOptions) (*LookupInReplicaResult, error) {
+	op := p.newOp(c, "lookup_in_any_replica", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy,
+		true)
+	defer op.Finish()
+
+	res, err := p.lookupIn(op, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LookupInReplicaResult{LookupInResult: res}, nil
+}
+
+func (p *kvProviderFake) LookupInAllReplicas(*Collection, string, []LookupInSpec, *LookupInAllReplicaOptions) (*LookupInAllReplicasResult, error) {
+	return nil, ErrFeatureNotAvailable
+}
+
+// fakeMutateSpec is a MutateInSpec with its value decoded in the same way as the server would see it.
+type fakeMutateSpec struct {
+	MutateInSpec
+	path  []fakeSubdocPathElem
+	value interface{}
+	delta int64
+
+	// macro is set if the value is a MutationMacro, in which case value holds the unexpanded macro until the cas of
+	// the mutation is known.
+	macro MutationMacro
+}
+
+func fakePrepareMutateSpecs(ops []MutateInSpec) ([]fakeMutateSpec, error) {
+	if len(ops) > fakeMaxSubdocSpecs {
+		return nil, makeInvalidArgumentsError("too many specs, the maximum is 16")
+	}
+
+	specs := make([]fakeMutateSpec, len(ops))
+	for i, op := range ops {
+		if op.path == "" {
+			switch op.op {
+			case memd.SubDocOpDictAdd:
+				return nil, makeInvalidArgumentsError("cannot specify a blank path with InsertSpec")
+			case memd.SubDocOpDictSet:
+				return nil, makeInvalidArgumentsError("cannot specify a blank path with UpsertSpec")
+			case memd.SubDocOpDelete:
+				op.op = memd.SubDocOpDeleteDoc
+			case memd.SubDocOpReplace:
+				op.op = memd.SubDocOpSetDoc
+			default:
+			}
+			if op.isXattr {
+				return nil, makeInvalidArgumentsError("invalid xattr mutation with no path")
+			}
+		}
+
+		path, err := parseFakeSubdocPath(op.path)
+		if err != nil {
+			return nil, err
+		}
+		if op.isXattr && path[0].isIndex {
+			return nil, ErrPathInvalid
+		}
+
+		spec := fakeMutateSpec{MutateInSpec: op, path: path}
+		switch {
+		case op.op == memd.SubDocOpCounter:
+			delta, ok := op.value.(int64)
+			if !ok || delta == 0 {
+				return nil, ErrDeltaInvalid
+			}
+			spec.delta = delta
+		default:
+			if macro, ok := op.value.(MutationMacro); ok {
+				if !op.isXattr {
+					return nil, ErrXattrInvalidFlagCombo
+				}
+				if macro != MutationMacroCAS && macro != MutationMacroSeqNo && macro != MutationMacroValueCRC32c {
+					return nil, ErrXattrUnknownMacro
+				}
+				spec.macro = macro
+			}
+
+			bytes, _, err := jsonMarshalMutateSpec(op)
+			if err != nil {
+				return nil, err
+			}
+			if bytes != nil {
+				if op.multiValue {
+					bytes = append(append([]byte{'['}, bytes...), ']')
+				}
+				spec.value, err = fakeSubdocDecode(bytes)
+				if err != nil {
+					return nil, ErrValueInvalid
+				}
+			}
+		}
+
+		specs[i] = spec
+	}
+
+	return specs, nil
+}
+
+func fakeSubdocIsPrimitive(value interface{}) bool {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		return false
+	}
+
+	return true
+}
+
+// fakeSubdocMutate performs a mutate spec against root, returning the new root and the value of the spec.
+func fakeSubdocMutate(root interface{}, spec fakeMutateSpec) (interface{}, []byte, error) {
+	if len(spec.path) == 0 {
+		switch spec.op {
+		case memd.SubDocOpSetDoc:
+			return spec.value, nil, nil
+		case memd.SubDocOpArrayPushLast, memd.SubDocOpArrayPushFirst, memd.SubDocOpArrayAddUnique:
+			newRoot, err := fakeSubdocMutateArray(root, spec)
+			return newRoot, nil, err
+		default:
+			return nil, nil, ErrPathInvalid
+		}
+	}
+
+	var result []byte
+	newRoot, err := fakeSubdocApply(root, spec.path, spec.createPath,
+		func(container interface{}, elem fakeSubdocPathElem) (interface{}, error) {
+			child, found, err := fakeSubdocChild(container, elem)
+			if err != nil {
+				return nil, err
+			}
+
+			switch spec.op {
+			case memd.SubDocOpDictAdd:
+				if elem.isIndex {
+					return nil, ErrPathInvalid
+				}
+				if found {
+					return nil, ErrPathExists
+				}
+				return fakeSubdocSetChild(container, elem, spec.value)
+			case memd.SubDocOpDictSet:
+				if elem.isIndex {
+					return nil, ErrPathInvalid
+				}
+				return fakeSubdocSetChild(container, elem, spec.value)
+			case memd.SubDocOpReplace:
+				if !found {
+					return nil, ErrPathNotFound
+				}
+				return fakeSubdocSetChild(container, elem, spec.value)
+			case memd.SubDocOpDelete:
+				if !found {
+					return nil, ErrPathNotFound
+				}
+				return fakeSubdocDeleteChild(container, elem)
+			case memd.SubDocOpArrayPushLast, memd.SubDocOpArrayPushFirst, memd.SubDocOpArrayAddUnique:
+				if !found {
+					if !spec.createPath || elem.isIndex {
+						return nil, ErrPathNotFound
+					}
+					child = []interface{}{}
+				}
+				newChild, err := fakeSubdocMutateArray(child, spec)
+				if err != nil {
+					return nil, err
+				}
+				return fakeSubdocSetChild(container, elem, newChild)
+			case memd.SubDocOpArrayInsert:
+				return fakeSubdocArrayInsert(container, elem, spec.value)
+			case memd.SubDocOpCounter:
+				var current int64
+				if found {
+					num, ok := child.(json.Number)
+					if !ok {
+						return nil, ErrPathMismatch
+					}
+					current, err = num.Int64()
+					if err != nil {
+						return nil, ErrNumberTooBig
+					}
+				} else if elem.isIndex {
+					return nil, ErrPathNotFound
+				}
+
+				if (spec.delta > 0 && current > math.MaxInt64-spec.delta) ||
+					(spec.delta < 0 && current < math.MinInt64-spec.delta) {
+					return nil, ErrValueInvalid
+				}
+				current += spec.delta
+
+				result = []byte(strconv.FormatInt(current, 10))
+				return fakeSubdocSetChild(container, elem, json.Number(result))
+			}
+
+			return nil, makeInvalidArgumentsError("unknown mutatein op")
+		})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return newRoot, result, nil
+}
+
+func fakeSubdocDeleteChild(container interface{}, elem fakeSubdocPathElem) (interface{}, error) {
+	if !elem.isIndex {
+		delete(container.(map[string]interface{}), elem.key)
+		return container, nil
+	}
+
+	arr := container.([]interface{})
+	idx := elem.index
+	if idx < 0 {
+		idx += len(arr)
+	}
+
+	out := make([]interface{}, 0, len(arr)-1)
+	out = append(out, arr[:idx]...)
+	return append(out, arr[idx+1:]...), nil
+}
+
+func fakeSubdocMutateArray(node interface{}, spec fakeMutateSpec) (interface{}, error) {
+	arr, ok := node.([]interface{})
+	if !ok {
+		return nil, ErrPathMismatch
+	}
+
+	values := []interface{}{spec.value}
+	if spec.multiValue {
+		values, ok = spec.value.([]interface{})
+		if !ok {
+			return nil, ErrValueInvalid
+		}
+	}
+
+	switch spec.op {
+	case memd.SubDocOpArrayPushLast:
+		return append(arr, values...), nil
+	case memd.SubDocOpArrayPushFirst:
+		out := make([]interface{}, 0, len(arr)+len(values))
+		out = append(out, values...)
+		return append(out, arr...), nil
+	case memd.SubDocOpArrayAddUnique:
+		if !fakeSubdocIsPrimitive(spec.value) {
+			return nil, ErrValueInvalid
+		}
+		for _, existing := range arr {
+			if !fakeSubdocIsPrimitive(existing) {
+				return nil, ErrPathMismatch
+			}
+			if existing == spec.value {
+				return nil, ErrPathExists
+			}
+		}
+		return append(arr, spec.value), nil
+	}
+
+	return nil, makeInvalidArgumentsError("unknown mutatein op")
+}
+
+func fakeSubdocArrayInsert(container interface{}, elem fakeSubdocPathElem, value interface{}) (interface{}, error) {
+	if !elem.isIndex || elem.index < 0 {
+		return nil, ErrPathInvalid
+	}
+
+	arr, ok := container.([]interface{})
+	if !ok {
+		return nil, ErrPathMismatch
+	}
+	if elem.index > len(arr) {
+		return nil, ErrPathNotFound
+	}
+
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+
+	out := make([]interface{}, 0, len(arr)+len(values))
+	out = append(out, arr[:elem.index]...)
+	out = append(out, values...)
+	return append(out, arr[elem.index:]...), nil
+}
+
+// fakeSubdocNewRoot returns the body of a document created by a mutation, which the server makes an array if the
+// first body path refers to an array and an object otherwise.
+func fakeSubdocNewRoot(specs []fakeMutateSpec) []byte {
+	for _, spec := range specs {
+		if spec.isXattr {
+			continue
+		}
+
+		isArrayOp := spec.op == memd.SubDocOpArrayPushLast || spec.op == memd.SubDocOpArrayPushFirst ||
+			spec.op == memd.SubDocOpArrayAddUnique
+		if (len(spec.path) > 0 && spec.path[0].isIndex) || (len(spec.path) == 0 && isArrayOp) {
+			return []byte("[]")
+		}
+
+		return []byte("{}")
+	}
+
+	return []byte("{}")
+}
+
+func (p *kvProviderFake) MutateIn(c *Collection, id string, ops []MutateInSpec, opts *MutateInOptions) (*MutateInResult, error) {
+	op := p.newOp(c, "mutate_in", id, opts.ParentSpan, opts.Timeout, opts.Context, opts.RetryStrategy, false)
+	defer op.Finish()
+
+	switch opts.StoreSemantic {
+	case StoreSemanticsReplace:
+		if opts.Expiry > 0 && opts.PreserveExpiry {
+			return nil, makeInvalidArgumentsError("cannot use preserve expiry with expiry for replace store semantics")
+		}
+	case StoreSemanticsUpsert:
+	case StoreSemanticsInsert:
+		if opts.PreserveExpiry {
+			return nil, makeInvalidArgumentsError("cannot use preserve ttl with insert store semantics")
+		}
+	default:
+		return nil, makeInvalidArgumentsError("invalid StoreSemantics value provided")
+	}
+
+	espan := p.StartKvOpTrace(c, "request_encoding", op.span, true)
+	specs, err := fakePrepareMutateSpecs(ops)
+	espan.End()
+	if err != nil {
+		return nil, err
+	}
+
+	mutOut := &MutateInResult{contents: make([]mutateInPartial, len(specs))}
+	err = op.Run(func(s *fakeStore, ks fakeKeyspace, now time.Time) error {
+		existing := s.get(ks, id, now)
+		switch opts.StoreSemantic {
+		case StoreSemanticsReplace:
+			if existing == nil {
+				return ErrDocumentNotFound
+			}
+		case StoreSemanticsInsert:
+			if existing != nil {
+				return ErrDocumentExists
+			}
+		}
+		if existing != nil {
+			if err := fakeCheckMutable(existing, opts.Cas, now); err != nil {
+				if errors.Is(err, ErrCasMismatch) {
+					// The server reports a cas mismatch for subdoc as the document existing, see GOCBC-1019.
+					return ErrDocumentExists
+				}
+				return err
+			}
+		}
+
+		doc := &fakeDocument{
+			value:  fakeSubdocNewRoot(specs),
+			flags:  gocbcore.EncodeCommonFlags(gocbcore.JSONType, gocbcore.NoCompression),
+			expiry: fakeExpiryTime(opts.Expiry, now),
+		}
+		if existing != nil {
+			doc.value = existing.value
+			doc.flags = existing.flags
+			doc.xattrs = existing.xattrs
+			if opts.PreserveExpiry {
+				doc.expiry = existing.expiry
+			}
+		}
+
+		body, bodyErr := fakeSubdocDecode(doc.value)
+		bodyChanged := false
+		xattrs, err := fakeSubdocDecodeXattrs(doc.xattrs)
+		if err != nil {
+			return err
+		}
+		xattrsChanged := false
+		deleteDoc := false
+
+		hasMacros := false
+		for i, spec := range specs {
+			if spec.macro != "" {
+				hasMacros = true
+			}
+
+			if spec.op == memd.SubDocOpDeleteDoc {
+				deleteDoc = true
+				continue
+			}
+
+			if spec.isXattr {
+				if strings.HasPrefix(spec.path[0].key, "$") {
+					if spec.path[0].key == fakeVirtualXattrDocument {
+						return ErrXattrCannotModifyVirtualAttribute
+					}
+					return ErrXattrUnknownVirtualAttribute
+				}
+
+				newXattrs, result, err := fakeSubdocMutate(xattrs, spec)
+				if err != nil {
+					return err
+				}
+				xattrs = newXattrs.(map[string]interface{})
+				xattrsChanged = true
+				mutOut.contents[i].data = result
+				continue
+			}
+
+			if bodyErr != nil && spec.op != memd.SubDocOpSetDoc {
+				return bodyErr
+			}
+			newBody, result, err := fakeSubdocMutate(body, spec)
+			if err != nil {
+				return err
+			}
+			body, bodyErr = newBody, nil
+			bodyChanged = true
+			mutOut.contents[i].data = result
+		}
+
+		if deleteDoc {
+			if existing == nil {
+				return ErrDocumentNotFound
+			}
+			mutOut.cas, mutOut.mt = s.remove(ks, id, now)
+			return nil
+		}
+
+		if bodyChanged {
+			if doc.value, err = fakeSubdocEncode(body); err != nil {
+				return err
+			}
+		}
+		if xattrsChanged {
+			if doc.xattrs, err = fakeSubdocEncode(xattrs); err != nil {
+				return err
+			}
+		}
+
+		mutOut.mt = s.store(ks, id, doc, now)
+		mutOut.cas = doc.cas
+
+		if hasMacros {
+			doc.xattrs, err = fakeSubdocEncode(fakeExpandMacros(doc, xattrs))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mutOut, nil
+}
+
+// fakeExpandMacros replaces any unexpanded macros in the xattrs now that the cas of the mutation is known.
+func fakeExpandMacros(doc *fakeDocument, node interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = fakeExpandMacros(doc, child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = fakeExpandMacros(doc, child)
+		}
+	case string:
+		switch "\"" + v + "\"" {
+		case string(MutationMacroCAS):
+			return fakeMacroCas(doc.cas)
+		case string(MutationMacroSeqNo):
+			return fmt.Sprintf("0x%016x", doc.seqNo)
+		case string(MutationMacroValueCRC32c):
+			return fakeMacroCrc32c(doc.value)
+		}
+	}
+
+	return node
+}
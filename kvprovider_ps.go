@@ -20,7 +20,8 @@ var _ kvProvider = &kvProviderPs{}
 type kvProviderPs struct {
 	client kv_v1.KvServiceClient
 
-	tracer *tracerWrapper
+	tracer         *tracerWrapper
+	circuitBreaker *observedCircuitBreaker
 }
 
 // this is uint8 to int32, need to check overflows etc
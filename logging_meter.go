@@ -37,6 +37,8 @@ type LoggingMeter struct {
 	interval time.Duration
 
 	valueRecorderGroups map[string]*aggregatingMeterGroup
+	counterLock         sync.Mutex
+	counters            map[string]*aggregatingCounter
 	stopCh              chan struct{}
 }
 
@@ -102,7 +104,8 @@ func newAggregatingMeter(opts *LoggingMeterOptions) *LoggingMeter {
 				recorders: make(map[string]*aggregatingValueRecorder),
 			},
 		},
-		stopCh: make(chan struct{}),
+		counters: make(map[string]*aggregatingCounter),
+		stopCh:   make(chan struct{}),
 	}
 
 	return am
@@ -164,11 +167,43 @@ func (am *LoggingMeter) generateOutput() map[string]interface{} {
 		}
 	}
 
+	counters := make(map[string]interface{})
+	for name, counter := range am.snapshotCounters() {
+		// Don't log counters which haven't been incremented.
+		if count := counter.GetAndReset(); count > 0 {
+			counters[name] = count
+		}
+	}
+	if len(counters) > 0 {
+		output["counters"] = counters
+	}
+
 	return output
 }
 
-func (am *LoggingMeter) Counter(_ string, _ map[string]string) (Counter, error) {
-	return defaultNoopCounter, nil
+// Counter returns a counter which is aggregated across all tags and logged alongside operation latencies.
+func (am *LoggingMeter) Counter(name string, _ map[string]string) (Counter, error) {
+	am.counterLock.Lock()
+	counter := am.counters[name]
+	if counter == nil {
+		counter = &aggregatingCounter{}
+		am.counters[name] = counter
+	}
+	am.counterLock.Unlock()
+
+	return counter, nil
+}
+
+// snapshotCounters returns a copy of the counters which have been created, by name.
+func (am *LoggingMeter) snapshotCounters() map[string]*aggregatingCounter {
+	am.counterLock.Lock()
+	counters := make(map[string]*aggregatingCounter, len(am.counters))
+	for name, counter := range am.counters {
+		counters[name] = counter
+	}
+	am.counterLock.Unlock()
+
+	return counters
 }
 
 func (am *LoggingMeter) ValueRecorder(name string, tags map[string]string) (ValueRecorder, error) {
@@ -202,6 +237,25 @@ func (am *LoggingMeter) ValueRecorder(name string, tags map[string]string) (Valu
 	return recorder, nil
 }
 
+// latencyAtPercentile returns the latency of the operation at the given percentile over the current emit interval,
+// along with the number of samples that it was derived from.
+func (am *LoggingMeter) latencyAtPercentile(service, operation string, percentile float64) (time.Duration, uint64) {
+	recorderGroup, ok := am.valueRecorderGroups[service]
+	if !ok {
+		return 0, 0
+	}
+
+	recorderGroup.lock.Lock()
+	recorder := recorderGroup.recorders[operation]
+	recorderGroup.lock.Unlock()
+	if recorder == nil {
+		return 0, 0
+	}
+
+	value, count := recorder.hist.ValueAtPercentile(percentile)
+	return time.Duration(value) * time.Microsecond, count
+}
+
 func (am *LoggingMeter) close() {
 	am.stopCh <- struct{}{}
 }
@@ -250,6 +304,34 @@ func (lh *latencyHistogram) RecordValue(value uint64) {
 	atomic.AddUint64(&lh.bins[bin], 1)
 }
 
+// ValueAtPercentile returns the upper bound of the bin containing the given percentile, without resetting the
+// histogram, along with the number of values recorded.
+func (lh *latencyHistogram) ValueAtPercentile(percentile float64) (float64, uint64) {
+	bins := make([]uint64, len(lh.bins))
+	var total uint64
+	for i := 0; i < len(lh.bins); i++ {
+		bins[i] = atomic.LoadUint64(&lh.bins[i])
+		total += bins[i]
+	}
+	if total == 0 {
+		return 0, 0
+	}
+
+	count := uint64(math.Ceil((percentile / 100) * float64(total)))
+	var countSoFar uint64
+	for i, bin := range bins {
+		countSoFar += bin
+		if countSoFar >= count {
+			if i == len(bins)-1 {
+				return lh.maxValue, total
+			}
+			return math.Pow(lh.commonRatio, float64(i)) * lh.startValue, total
+		}
+	}
+
+	return lh.maxValue, total
+}
+
 func (lh *latencyHistogram) AggregateAndReset() *cumulativeLatencyHistogram {
 	bins := make([]uint64, len(lh.bins))
 	var countSoFar uint64
@@ -315,3 +397,15 @@ func (bc *aggregatingValueRecorder) GetAndResetValues() (uint64, map[string]inte
 		},
 	}
 }
+
+type aggregatingCounter struct {
+	count uint64
+}
+
+func (ac *aggregatingCounter) IncrementBy(num uint64) {
+	atomic.AddUint64(&ac.count, num)
+}
+
+func (ac *aggregatingCounter) GetAndReset() uint64 {
+	return atomic.SwapUint64(&ac.count, 0)
+}
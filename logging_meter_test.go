@@ -115,3 +115,44 @@ func (suite *UnitTestSuite) TestAggregatingMeter() {
 	suite.Assert().Equal("<= 129746.34", percentilesq["99.9"])
 	suite.Assert().Equal("<= 129746.34", percentilesq["100.0"])
 }
+
+func (suite *UnitTestSuite) TestAggregatingMeterCounters() {
+	meter := newAggregatingMeter(&LoggingMeterOptions{
+		EmitInterval: 10 * time.Second,
+	})
+	tags := map[string]string{
+		meterAttribServiceKey:   "kv",
+		meterAttribOperationKey: "get",
+	}
+
+	sent, err := meter.Counter(meterNameHedgeSent, tags)
+	suite.Require().Nil(err)
+	won, err := meter.Counter(meterNameHedgeWon, tags)
+	suite.Require().Nil(err)
+	throttled, err := meter.Counter(meterNameRateLimiterThrottled, map[string]string{meterAttribServiceKey: "query"})
+	suite.Require().Nil(err)
+	unused, err := meter.Counter(meterNameCircuitBreakerTransitions, nil)
+	suite.Require().Nil(err)
+	suite.Require().NotNil(unused)
+
+	// The same counter is returned for a name whatever the tags.
+	again, err := meter.Counter(meterNameHedgeSent, nil)
+	suite.Require().Nil(err)
+	suite.Assert().Same(sent, again)
+
+	sent.IncrementBy(1)
+	again.IncrementBy(2)
+	won.IncrementBy(1)
+	throttled.IncrementBy(4)
+
+	output := meter.generateOutput()
+	suite.Require().Contains(output, "counters")
+	suite.Assert().Equal(map[string]interface{}{
+		meterNameHedgeSent:            uint64(3),
+		meterNameHedgeWon:             uint64(1),
+		meterNameRateLimiterThrottled: uint64(4),
+	}, output["counters"])
+
+	// Counters are reset once they have been output.
+	suite.Assert().NotContains(meter.generateOutput(), "counters")
+}
@@ -23,7 +23,7 @@ type mgmtRequest struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
 
-	parentSpanCtx RequestSpanContext
+	parentSpan RequestSpan
 }
 
 type mgmtResponse struct {
@@ -48,11 +48,18 @@ func (mpc *mgmtProviderCore) executeMgmtRequest(ctx context.Context, req mgmtReq
 		timeout = mpc.mgmtTimeout
 	}
 
+	deadline := time.Now().Add(timeout)
+
 	retryStrategy := mpc.retryStrategyWrapper
 	if req.RetryStrategy != nil {
 		retryStrategy = newCoreRetryStrategyWrapper(req.RetryStrategy)
 	}
 
+	var traceCtx RequestSpanContext
+	if req.parentSpan != nil {
+		traceCtx = req.parentSpan.Context()
+	}
+
 	corereq := &gocbcore.HTTPRequest{
 		Service:       gocbcore.ServiceType(req.Service),
 		Method:        req.Method,
@@ -62,9 +69,9 @@ func (mpc *mgmtProviderCore) executeMgmtRequest(ctx context.Context, req mgmtReq
 		ContentType:   req.ContentType,
 		IsIdempotent:  req.IsIdempotent,
 		UniqueID:      req.UniqueID,
-		Deadline:      time.Now().Add(timeout),
-		RetryStrategy: retryStrategy,
-		TraceContext:  req.parentSpanCtx,
+		Deadline:      deadline,
+		RetryStrategy: retryStrategy.forOperation(deadline, req.parentSpan),
+		TraceContext:  traceCtx,
 		Endpoint:      req.Endpoint,
 	}
 
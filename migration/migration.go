@@ -0,0 +1,400 @@
+// Package migration runs versioned migrations of the documents in a collection, such as renaming fields or splitting
+// documents apart.
+//
+// Migrations are numbered Go functions registered with a Runner. Each transforms a single document at a time, and is
+// run over every document of a collection, or those selected by a query. The versions which have been applied are
+// recorded in a metadata document, along with a checkpoint of the migration in progress so that a run which is
+// interrupted continues from where it stopped.
+// UNCOMMITTED: This API may change in the future.
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	meterNameMigrationScanned   = "db.couchbase.migration.documents_scanned"
+	meterNameMigrationChanged   = "db.couchbase.migration.documents_changed"
+	meterNameMigrationConflicts = "db.couchbase.migration.conflicts"
+	meterNameMigrationDuration  = "db.couchbase.migration.duration"
+
+	meterAttribMigrationVersion = "db.couchbase.migration.version"
+	meterAttribBucketName       = "db.couchbase.bucket"
+	meterAttribScopeName        = "db.couchbase.scope"
+	meterAttribCollectionName   = "db.couchbase.collection"
+	meterAttribDryRun           = "db.couchbase.migration.dry_run"
+)
+
+// Document is a document passed to the transform of a migration.
+// UNCOMMITTED: This API may change in the future.
+type Document struct {
+	ID  string
+	Cas gocb.Cas
+
+	content func(valuePtr interface{}) error
+}
+
+// Content decodes the content of the document into valuePtr.
+func (d *Document) Content(valuePtr interface{}) error {
+	return d.content(valuePtr)
+}
+
+// Change is the change that a transform makes to a document. At most one of Replace, MutateIn and Remove can be set,
+// if none are then the document itself is left unchanged.
+// UNCOMMITTED: This API may change in the future.
+type Change struct {
+	// Replace replaces the content of the document.
+	Replace interface{}
+
+	// MutateIn changes the fields of the document using the subdocument API.
+	MutateIn []gocb.MutateInSpec
+
+	// Remove removes the document.
+	Remove bool
+
+	// Upsert writes other documents, by ID, such as those that a document is being split into. They are written
+	// before the document itself is changed.
+	Upsert map[string]interface{}
+}
+
+// Transform is called with each document that a migration is run over and returns the change to make to it, or nil
+// if the document does not need to be changed.
+//
+// Transforms must be idempotent, returning nil for a document which has already been migrated: should the document
+// be changed concurrently then the transform is called again with the new version of it, and documents may be seen
+// again when a run is resumed, or when they are written by the migration itself during the scan.
+// UNCOMMITTED: This API may change in the future.
+type Transform func(doc *Document) (*Change, error)
+
+// Migration is a single numbered migration.
+// UNCOMMITTED: This API may change in the future.
+type Migration struct {
+	// Version orders the migrations, which are applied from the lowest version up. It must be greater than zero.
+	Version     uint64
+	Description string
+
+	// Collection is the collection whose documents are migrated. Defaults to the collection of the Runner.
+	Collection *gocb.Collection
+
+	// Scan selects the documents which are migrated, defaulting to every document in the collection.
+	Scan gocb.ScanType
+
+	// Query selects the documents which are migrated with a N1QL query, run against the scope of the collection,
+	// which must return the ID of each document as a field named id. A migration can use either Scan or Query.
+	//
+	// Only scans can be resumed from the exact point at which they stopped, a query is run again in full.
+	Query        string
+	QueryOptions *gocb.QueryOptions
+
+	Transform Transform
+}
+
+func (m *Migration) validate() error {
+	if m.Version == 0 {
+		return invalidMigration("version must be greater than zero")
+	}
+	if m.Transform == nil {
+		return invalidMigration("migration %d must have a transform", m.Version)
+	}
+	if m.Scan != nil && m.Query != "" {
+		return invalidMigration("migration %d cannot use both a scan and a query", m.Version)
+	}
+	if _, ok := m.Scan.(gocb.SamplingScan); ok {
+		return invalidMigration("migration %d cannot use a sampling scan", m.Version)
+	}
+
+	return nil
+}
+
+// Status is the content of the metadata document.
+// UNCOMMITTED: This API may change in the future.
+type Status struct {
+	Applied []AppliedMigration `json:"applied"`
+
+	// InProgress is the checkpoint of the migration which was being run, if a run did not complete.
+	InProgress *Checkpoint `json:"inProgress,omitempty"`
+}
+
+// Version returns the highest version which has been applied, zero if none have.
+func (s *Status) Version() uint64 {
+	var version uint64
+	for _, applied := range s.Applied {
+		if applied.Version > version {
+			version = applied.Version
+		}
+	}
+
+	return version
+}
+
+func (s *Status) applied(version uint64) bool {
+	for _, applied := range s.Applied {
+		if applied.Version == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AppliedMigration records a migration which has been applied.
+// UNCOMMITTED: This API may change in the future.
+type AppliedMigration struct {
+	Version          uint64    `json:"version"`
+	Description      string    `json:"description,omitempty"`
+	AppliedAt        time.Time `json:"appliedAt"`
+	DocumentsScanned uint64    `json:"documentsScanned"`
+	DocumentsChanged uint64    `json:"documentsChanged"`
+}
+
+// Checkpoint records how far through a migration a run has got.
+// UNCOMMITTED: This API may change in the future.
+type Checkpoint struct {
+	Version          uint64                `json:"version"`
+	ResumeToken      *gocb.ScanResumeToken `json:"resumeToken,omitempty"`
+	DocumentsScanned uint64                `json:"documentsScanned"`
+	DocumentsChanged uint64                `json:"documentsChanged"`
+	UpdatedAt        time.Time             `json:"updatedAt"`
+}
+
+// RunnerOptions are the options available when creating a Runner.
+// UNCOMMITTED: This API may change in the future.
+type RunnerOptions struct {
+	// DocumentID is the ID of the metadata document. Defaults to _migrations.
+	DocumentID string
+
+	// CheckpointInterval is the number of documents processed between each checkpoint. Defaults to 1000.
+	CheckpointInterval uint64
+
+	// MaxAttempts is the number of times that a document is transformed before giving up because it keeps being
+	// changed concurrently. Defaults to 10.
+	MaxAttempts uint32
+
+	// ScanConcurrency is the number of vbuckets which are scanned at the same time. Defaults to 1.
+	ScanConcurrency uint16
+
+	// Meter receives the progress of each migration. Defaults to no metrics being recorded.
+	Meter gocb.Meter
+
+	// Timeout is the timeout of each individual operation.
+	Timeout time.Duration
+}
+
+// Runner applies registered migrations, recording those which have been applied in a metadata document.
+// Only one runner should be run against the same metadata document at a time.
+// UNCOMMITTED: This API may change in the future.
+type Runner struct {
+	collection *gocb.Collection
+	migrations map[uint64]Migration
+
+	documentID         string
+	checkpointInterval uint64
+	maxAttempts        uint32
+	scanConcurrency    uint16
+	meter              gocb.Meter
+	timeout            time.Duration
+}
+
+// NewRunner creates a Runner which stores its metadata document in the given collection, which is also the
+// collection migrated by any migration which does not set its own.
+// UNCOMMITTED: This API may change in the future.
+func NewRunner(collection *gocb.Collection, opts *RunnerOptions) *Runner {
+	if opts == nil {
+		opts = &RunnerOptions{}
+	}
+
+	r := &Runner{
+		collection:         collection,
+		migrations:         make(map[uint64]Migration),
+		documentID:         opts.DocumentID,
+		checkpointInterval: opts.CheckpointInterval,
+		maxAttempts:        opts.MaxAttempts,
+		scanConcurrency:    opts.ScanConcurrency,
+		meter:              opts.Meter,
+		timeout:            opts.Timeout,
+	}
+	if r.documentID == "" {
+		r.documentID = "_migrations"
+	}
+	if r.checkpointInterval == 0 {
+		r.checkpointInterval = 1000
+	}
+	if r.maxAttempts == 0 {
+		r.maxAttempts = 10
+	}
+	if r.meter == nil {
+		r.meter = &gocb.NoopMeter{}
+	}
+
+	return r
+}
+
+// Register adds a migration to the runner. An error wrapping gocb.ErrInvalidArgument is returned if the migration is
+// invalid or another has already been registered with the same version.
+// UNCOMMITTED: This API may change in the future.
+func (r *Runner) Register(migration Migration) error {
+	if err := migration.validate(); err != nil {
+		return err
+	}
+	if _, ok := r.migrations[migration.Version]; ok {
+		return invalidMigration("migration %d is already registered", migration.Version)
+	}
+	if migration.Collection == nil {
+		migration.Collection = r.collection
+	}
+
+	r.migrations[migration.Version] = migration
+	return nil
+}
+
+// Status fetches the metadata document, returning an empty Status if no migrations have been applied.
+// UNCOMMITTED: This API may change in the future.
+func (r *Runner) Status() (*Status, error) {
+	res, err := r.collection.Get(r.documentID, &gocb.GetOptions{Timeout: r.timeout})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return &Status{}, nil
+		}
+		return nil, err
+	}
+
+	var status Status
+	if err := res.Content(&status); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// Pending returns the registered migrations which have not been applied, in the order that they would be run.
+// UNCOMMITTED: This API may change in the future.
+func (r *Runner) Pending() ([]Migration, error) {
+	status, err := r.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.pending(status, 0), nil
+}
+
+func (r *Runner) pending(status *Status, target uint64) []Migration {
+	var pending []Migration
+	for version, migration := range r.migrations {
+		if status.applied(version) || (target > 0 && version > target) {
+			continue
+		}
+		pending = append(pending, migration)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Version < pending[j].Version
+	})
+
+	return pending
+}
+
+// RunOptions are the options available to Run.
+// UNCOMMITTED: This API may change in the future.
+type RunOptions struct {
+	// DryRun calls the transforms of the pending migrations and counts the documents which would be changed, without
+	// changing any documents or recording anything in the metadata document.
+	DryRun bool
+
+	// Target stops the run once every migration up to and including this version has been applied. Defaults to
+	// applying every pending migration.
+	Target uint64
+}
+
+// MigrationResult is the outcome of running a single migration.
+// UNCOMMITTED: This API may change in the future.
+type MigrationResult struct {
+	Version          uint64
+	DocumentsScanned uint64
+	DocumentsChanged uint64
+
+	// Resumed is whether the migration continued from the checkpoint of an earlier run.
+	Resumed bool
+}
+
+// RunResult is the outcome of Run.
+// UNCOMMITTED: This API may change in the future.
+type RunResult struct {
+	Migrations []MigrationResult
+}
+
+// Run applies each pending migration in order of version, recording each in the metadata document once it has
+// completed. Should a migration fail then Run stops and returns the error along with the results of the migrations
+// run so far, and the checkpoint of the failed migration is recorded so that the next run continues from it.
+// UNCOMMITTED: This API may change in the future.
+func (r *Runner) Run(opts *RunOptions) (*RunResult, error) {
+	if opts == nil {
+		opts = &RunOptions{}
+	}
+
+	status, err := r.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RunResult{}
+	for _, migration := range r.pending(status, opts.Target) {
+		var checkpoint *Checkpoint
+		if !opts.DryRun && status.InProgress != nil && status.InProgress.Version == migration.Version {
+			checkpoint = status.InProgress
+		}
+
+		run := r.newMigrationRun(migration, checkpoint, opts.DryRun)
+		err := run.run()
+		result.Migrations = append(result.Migrations, run.result())
+		if err != nil {
+			return result, fmt.Errorf("migration %d failed: %w", migration.Version, err)
+		}
+	}
+
+	return result, nil
+}
+
+func (r *Runner) updateStatus(fn func(status *Status)) error {
+	_, err := r.collection.Update(r.documentID, func(current *gocb.GetResult) (interface{}, error) {
+		var status Status
+		if current != nil {
+			if err := current.Content(&status); err != nil {
+				return nil, err
+			}
+		}
+
+		fn(&status)
+		return status, nil
+	}, &gocb.UpdateOptions{
+		InsertIfMissing: true,
+		Timeout:         r.timeout,
+	})
+
+	return err
+}
+
+func (r *Runner) isMetadataDocument(collection *gocb.Collection, id string) bool {
+	return id == r.documentID &&
+		collection.Bucket().Name() == r.collection.Bucket().Name() &&
+		collection.ScopeName() == r.collection.ScopeName() &&
+		collection.Name() == r.collection.Name()
+}
+
+func (r *Runner) meterTags(migration Migration, dryRun bool) map[string]string {
+	return map[string]string{
+		meterAttribMigrationVersion: strconv.FormatUint(migration.Version, 10),
+		meterAttribBucketName:       migration.Collection.Bucket().Name(),
+		meterAttribScopeName:        migration.Collection.ScopeName(),
+		meterAttribCollectionName:   migration.Collection.Name(),
+		meterAttribDryRun:           strconv.FormatBool(dryRun),
+	}
+}
+
+func invalidMigration(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", gocb.ErrInvalidArgument, fmt.Sprintf(format, args...))
+}
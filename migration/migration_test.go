@@ -0,0 +1,280 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/couchbase/gocb/v2/gocbtest"
+)
+
+type userV1 struct {
+	Name string `json:"name"`
+	City string `json:"city,omitempty"`
+}
+
+type userV2 struct {
+	FullName string `json:"fullName"`
+	City     string `json:"city,omitempty"`
+}
+
+type testMeter struct {
+	lock   sync.Mutex
+	counts map[string]uint64
+}
+
+func (m *testMeter) Counter(name string, tags map[string]string) (gocb.Counter, error) {
+	return &testCounter{
+		meter: m,
+		name:  name + "/" + tags[meterAttribMigrationVersion] + "/" + tags[meterAttribDryRun],
+	}, nil
+}
+
+func (m *testMeter) ValueRecorder(name string, tags map[string]string) (gocb.ValueRecorder, error) {
+	return &testCounter{meter: m, name: name}, nil
+}
+
+type testCounter struct {
+	meter *testMeter
+	name  string
+}
+
+func (c *testCounter) IncrementBy(num uint64) {
+	c.meter.lock.Lock()
+	c.meter.counts[c.name] += num
+	c.meter.lock.Unlock()
+}
+
+func (c *testCounter) RecordValue(val uint64) {}
+
+func newTestCollection(t *testing.T, numDocs int) *gocb.Collection {
+	cluster, _, err := gocbtest.Connect(nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = cluster.Close(nil)
+	})
+
+	col := cluster.Bucket("default").Scope("app").Collection("users")
+	for i := 0; i < numDocs; i++ {
+		_, err := col.Upsert(fmt.Sprintf("user::%d", i), userV1{Name: fmt.Sprintf("user %d", i), City: "london"}, nil)
+		require.NoError(t, err)
+	}
+
+	return col
+}
+
+// renameName moves the name field to fullName, leaving documents which have already been migrated alone.
+func renameName(doc *Document) (*Change, error) {
+	var user map[string]interface{}
+	if err := doc.Content(&user); err != nil {
+		return nil, err
+	}
+	if _, ok := user["name"]; !ok {
+		return nil, nil
+	}
+
+	return &Change{
+		Replace: userV2{FullName: user["name"].(string), City: user["city"].(string)},
+	}, nil
+}
+
+func TestRegister(t *testing.T) {
+	runner := NewRunner(newTestCollection(t, 0), nil)
+
+	require.NoError(t, runner.Register(Migration{Version: 1, Transform: renameName}))
+	assert.ErrorIs(t, runner.Register(Migration{Version: 1, Transform: renameName}), gocb.ErrInvalidArgument)
+	assert.ErrorIs(t, runner.Register(Migration{Transform: renameName}), gocb.ErrInvalidArgument)
+	assert.ErrorIs(t, runner.Register(Migration{Version: 2}), gocb.ErrInvalidArgument)
+	assert.ErrorIs(t, runner.Register(Migration{
+		Version:   3,
+		Scan:      gocb.RangeScan{},
+		Query:     "SELECT META().id AS id FROM users",
+		Transform: renameName,
+	}), gocb.ErrInvalidArgument)
+	assert.ErrorIs(t, runner.Register(Migration{
+		Version:   4,
+		Scan:      gocb.SamplingScan{Limit: 10},
+		Transform: renameName,
+	}), gocb.ErrInvalidArgument)
+}
+
+func TestRun(t *testing.T) {
+	col := newTestCollection(t, 20)
+	meter := &testMeter{counts: make(map[string]uint64)}
+	runner := NewRunner(col, &RunnerOptions{Meter: meter})
+
+	require.NoError(t, runner.Register(Migration{
+		Version:     2,
+		Description: "split the city of each user into its own document",
+		Transform: func(doc *Document) (*Change, error) {
+			var user userV2
+			if err := doc.Content(&user); err != nil {
+				return nil, err
+			}
+			if user.City == "" {
+				return nil, nil
+			}
+
+			return &Change{
+				MutateIn: []gocb.MutateInSpec{gocb.RemoveSpec("city", nil)},
+				Upsert: map[string]interface{}{
+					"address::" + doc.ID: map[string]string{"city": user.City},
+				},
+			}, nil
+		},
+		Scan: gocb.NewRangeScanForPrefix("user::"),
+	}))
+	require.NoError(t, runner.Register(Migration{
+		Version:     1,
+		Description: "rename name to fullName",
+		Transform:   renameName,
+	}))
+
+	pending, err := runner.Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.EqualValues(t, 1, pending[0].Version)
+
+	res, err := runner.Run(&RunOptions{DryRun: true})
+	require.NoError(t, err)
+	require.Len(t, res.Migrations, 2)
+	assert.EqualValues(t, 20, res.Migrations[0].DocumentsChanged)
+
+	var user userV1
+	getRes, err := col.Get("user::3", nil)
+	require.NoError(t, err)
+	require.NoError(t, getRes.Content(&user))
+	assert.Equal(t, "user 3", user.Name)
+
+	status, err := runner.Status()
+	require.NoError(t, err)
+	assert.Empty(t, status.Applied)
+
+	res, err = runner.Run(&RunOptions{Target: 1})
+	require.NoError(t, err)
+	require.Len(t, res.Migrations, 1)
+	assert.EqualValues(t, 20, res.Migrations[0].DocumentsScanned)
+	assert.EqualValues(t, 20, res.Migrations[0].DocumentsChanged)
+
+	res, err = runner.Run(nil)
+	require.NoError(t, err)
+	require.Len(t, res.Migrations, 1)
+	assert.EqualValues(t, 2, res.Migrations[0].Version)
+	assert.EqualValues(t, 20, res.Migrations[0].DocumentsChanged)
+
+	var migrated map[string]interface{}
+	getRes, err = col.Get("user::3", nil)
+	require.NoError(t, err)
+	require.NoError(t, getRes.Content(&migrated))
+	assert.Equal(t, map[string]interface{}{"fullName": "user 3"}, migrated)
+
+	var address map[string]string
+	getRes, err = col.Get("address::user::3", nil)
+	require.NoError(t, err)
+	require.NoError(t, getRes.Content(&address))
+	assert.Equal(t, "london", address["city"])
+
+	status, err = runner.Status()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, status.Version())
+	require.Len(t, status.Applied, 2)
+	assert.Equal(t, "rename name to fullName", status.Applied[0].Description)
+	assert.Nil(t, status.InProgress)
+
+	res, err = runner.Run(nil)
+	require.NoError(t, err)
+	assert.Empty(t, res.Migrations)
+
+	assert.EqualValues(t, 20, meter.counts[meterNameMigrationChanged+"/1/true"])
+	assert.EqualValues(t, 20, meter.counts[meterNameMigrationChanged+"/1/false"])
+	assert.EqualValues(t, 20, meter.counts[meterNameMigrationScanned+"/2/false"])
+}
+
+func TestRunResumesFromCheckpoint(t *testing.T) {
+	col := newTestCollection(t, 30)
+	runner := NewRunner(col, &RunnerOptions{CheckpointInterval: 1})
+
+	var calls int
+	failAfter := 10
+	require.NoError(t, runner.Register(Migration{
+		Version: 1,
+		Transform: func(doc *Document) (*Change, error) {
+			calls++
+			if failAfter > 0 && calls > failAfter {
+				return nil, errors.New("transform failed")
+			}
+			return renameName(doc)
+		},
+	}))
+
+	res, err := runner.Run(nil)
+	assert.Error(t, err)
+	require.Len(t, res.Migrations, 1)
+	assert.EqualValues(t, 10, res.Migrations[0].DocumentsChanged)
+
+	status, err := runner.Status()
+	require.NoError(t, err)
+	require.NotNil(t, status.InProgress)
+	assert.EqualValues(t, 10, status.InProgress.DocumentsScanned)
+	assert.NotNil(t, status.InProgress.ResumeToken)
+	assert.Empty(t, status.Applied)
+
+	// Resuming sees only the documents after the checkpoint, starting with the one which failed.
+	calls = 0
+	failAfter = 0
+	res, err = runner.Run(nil)
+	require.NoError(t, err)
+	require.Len(t, res.Migrations, 1)
+	assert.True(t, res.Migrations[0].Resumed)
+	assert.Equal(t, 20, calls)
+	assert.EqualValues(t, 30, res.Migrations[0].DocumentsScanned)
+	assert.EqualValues(t, 30, res.Migrations[0].DocumentsChanged)
+
+	status, err = runner.Status()
+	require.NoError(t, err)
+	assert.Nil(t, status.InProgress)
+	require.Len(t, status.Applied, 1)
+	assert.EqualValues(t, 30, status.Applied[0].DocumentsChanged)
+}
+
+func TestRunRetriesOnConflict(t *testing.T) {
+	col := newTestCollection(t, 1)
+	meter := &testMeter{counts: make(map[string]uint64)}
+	runner := NewRunner(col, &RunnerOptions{Meter: meter})
+
+	var calls int
+	require.NoError(t, runner.Register(Migration{
+		Version: 1,
+		Transform: func(doc *Document) (*Change, error) {
+			calls++
+			if calls == 1 {
+				// Change the document underneath the migration, so that its CAS no longer matches.
+				if _, err := col.Upsert(doc.ID, userV1{Name: "renamed"}, nil); err != nil {
+					return nil, err
+				}
+			}
+
+			var user userV1
+			if err := doc.Content(&user); err != nil {
+				return nil, err
+			}
+			return &Change{MutateIn: []gocb.MutateInSpec{gocb.UpsertSpec("seen", user.Name, nil)}}, nil
+		},
+	}))
+
+	_, err := runner.Run(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.EqualValues(t, 1, meter.counts[meterNameMigrationConflicts+"/1/false"])
+
+	var user map[string]interface{}
+	getRes, err := col.Get("user::0", nil)
+	require.NoError(t, err)
+	require.NoError(t, getRes.Content(&user))
+	assert.Equal(t, "renamed", user["seen"])
+}
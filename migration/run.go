@@ -0,0 +1,348 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// migrationRun is a single run of a migration.
+type migrationRun struct {
+	runner    *Runner
+	migration Migration
+	dryRun    bool
+
+	resumeFrom *gocb.ScanResumeToken
+	resumed    bool
+
+	scanned         uint64
+	changed         uint64
+	sinceCheckpoint uint64
+
+	scannedCounter  gocb.Counter
+	changedCounter  gocb.Counter
+	conflictCounter gocb.Counter
+	duration        gocb.ValueRecorder
+}
+
+func (r *Runner) newMigrationRun(migration Migration, checkpoint *Checkpoint, dryRun bool) *migrationRun {
+	run := &migrationRun{
+		runner:    r,
+		migration: migration,
+		dryRun:    dryRun,
+	}
+
+	// A query is always run again in full, so only a scan continues the counts of the checkpoint.
+	if checkpoint != nil && migration.Query == "" {
+		run.resumeFrom = checkpoint.ResumeToken
+		run.resumed = true
+		run.scanned = checkpoint.DocumentsScanned
+		run.changed = checkpoint.DocumentsChanged
+	}
+
+	tags := r.meterTags(migration, dryRun)
+	run.scannedCounter = meterCounter(r.meter, meterNameMigrationScanned, tags)
+	run.changedCounter = meterCounter(r.meter, meterNameMigrationChanged, tags)
+	run.conflictCounter = meterCounter(r.meter, meterNameMigrationConflicts, tags)
+	run.duration = meterValueRecorder(r.meter, meterNameMigrationDuration, tags)
+
+	return run
+}
+
+func (mr *migrationRun) result() MigrationResult {
+	return MigrationResult{
+		Version:          mr.migration.Version,
+		DocumentsScanned: mr.scanned,
+		DocumentsChanged: mr.changed,
+		Resumed:          mr.resumed,
+	}
+}
+
+// run migrates each document and records the migration as applied. Should it fail then the checkpoint taken after
+// the last document to be fully processed is left in place, so that the failed document is seen again on resuming.
+func (mr *migrationRun) run() error {
+	start := time.Now()
+	defer func() {
+		mr.duration.RecordValue(uint64(time.Since(start).Microseconds()))
+	}()
+
+	var err error
+	if mr.migration.Query != "" {
+		err = mr.runQuery()
+	} else {
+		err = mr.runScan()
+	}
+	if err != nil || mr.dryRun {
+		return err
+	}
+
+	return mr.runner.updateStatus(func(status *Status) {
+		status.Applied = append(status.Applied, AppliedMigration{
+			Version:          mr.migration.Version,
+			Description:      mr.migration.Description,
+			AppliedAt:        time.Now().UTC(),
+			DocumentsScanned: mr.scanned,
+			DocumentsChanged: mr.changed,
+		})
+		status.InProgress = nil
+	})
+}
+
+func (mr *migrationRun) runScan() error {
+	scanType := mr.migration.Scan
+	if scanType == nil {
+		scanType = gocb.RangeScan{}
+	}
+
+	res, err := mr.migration.Collection.Scan(scanType, &gocb.ScanOptions{
+		ResumeFrom:  mr.resumeFrom,
+		Concurrency: mr.runner.scanConcurrency,
+	})
+	if err != nil {
+		return err
+	}
+
+	for item := res.Next(); item != nil; item = res.Next() {
+		if err := mr.processDocument(&Document{
+			ID:      item.ID(),
+			Cas:     item.Cas(),
+			content: item.Content,
+		}); err != nil {
+			_ = res.Close()
+			return err
+		}
+
+		// Every item returned so far has been processed, so the resume token of the scan is safe to record.
+		if err := mr.maybeCheckpoint(res.ResumeToken); err != nil {
+			_ = res.Close()
+			return err
+		}
+	}
+
+	return res.Err()
+}
+
+func (mr *migrationRun) runQuery() error {
+	collection := mr.migration.Collection
+	res, err := collection.Bucket().Scope(collection.ScopeName()).Query(mr.migration.Query, mr.migration.QueryOptions)
+	if err != nil {
+		return err
+	}
+
+	for res.Next() {
+		var row struct {
+			ID string `json:"id"`
+		}
+		if err := res.Row(&row); err != nil {
+			_ = res.Close()
+			return err
+		}
+		if row.ID == "" {
+			_ = res.Close()
+			return invalidMigration("query of migration %d must return the id of each document",
+				mr.migration.Version)
+		}
+
+		doc, err := mr.fetch(row.ID)
+		if err != nil {
+			_ = res.Close()
+			return err
+		}
+		if doc == nil {
+			continue
+		}
+
+		if err := mr.processDocument(doc); err != nil {
+			_ = res.Close()
+			return err
+		}
+		if err := mr.maybeCheckpoint(nil); err != nil {
+			_ = res.Close()
+			return err
+		}
+	}
+
+	return res.Err()
+}
+
+func (mr *migrationRun) maybeCheckpoint(resumeToken func() *gocb.ScanResumeToken) error {
+	if mr.dryRun {
+		return nil
+	}
+
+	mr.sinceCheckpoint++
+	if mr.sinceCheckpoint < mr.runner.checkpointInterval {
+		return nil
+	}
+	mr.sinceCheckpoint = 0
+
+	checkpoint := &Checkpoint{
+		Version:          mr.migration.Version,
+		DocumentsScanned: mr.scanned,
+		DocumentsChanged: mr.changed,
+		UpdatedAt:        time.Now().UTC(),
+	}
+	if resumeToken != nil {
+		checkpoint.ResumeToken = resumeToken()
+	}
+
+	return mr.runner.updateStatus(func(status *Status) {
+		status.InProgress = checkpoint
+	})
+}
+
+func (mr *migrationRun) processDocument(doc *Document) error {
+	if mr.runner.isMetadataDocument(mr.migration.Collection, doc.ID) {
+		return nil
+	}
+
+	changed, err := mr.migrateDocument(doc)
+	if err != nil {
+		return err
+	}
+
+	mr.scanned++
+	mr.scannedCounter.IncrementBy(1)
+	if changed {
+		mr.changed++
+		mr.changedCounter.IncrementBy(1)
+	}
+
+	return nil
+}
+
+// migrateDocument transforms the document and writes the change, using the CAS of the document so that a concurrent
+// change is never overwritten. Should the document have been changed then it is fetched and transformed again.
+func (mr *migrationRun) migrateDocument(doc *Document) (bool, error) {
+	for attempt := uint32(1); ; attempt++ {
+		change, err := mr.migration.Transform(doc)
+		if err != nil {
+			return false, fmt.Errorf("transform of document %s failed: %w", doc.ID, err)
+		}
+		if change == nil {
+			return false, nil
+		}
+		if err := change.validate(); err != nil {
+			return false, fmt.Errorf("transform of document %s failed: %w", doc.ID, err)
+		}
+		if mr.dryRun {
+			return true, nil
+		}
+
+		err = mr.write(doc, change)
+		if err == nil {
+			return true, nil
+		}
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			// The document was removed since it was read, so there is nothing left to migrate.
+			return false, nil
+		}
+		// A CAS mismatch on a subdocument mutation is reported as the document existing.
+		if !errors.Is(err, gocb.ErrCasMismatch) && !errors.Is(err, gocb.ErrDocumentExists) {
+			return false, fmt.Errorf("failed to write document %s: %w", doc.ID, err)
+		}
+
+		mr.conflictCounter.IncrementBy(1)
+		if attempt >= mr.runner.maxAttempts {
+			return false, fmt.Errorf("document %s was changed concurrently on each of %d attempts: %w", doc.ID,
+				attempt, err)
+		}
+
+		doc, err = mr.fetch(doc.ID)
+		if err != nil {
+			return false, err
+		}
+		if doc == nil {
+			return false, nil
+		}
+	}
+}
+
+func (mr *migrationRun) write(doc *Document, change *Change) error {
+	collection := mr.migration.Collection
+	timeout := mr.runner.timeout
+
+	for id, value := range change.Upsert {
+		if _, err := collection.Upsert(id, value, &gocb.UpsertOptions{Timeout: timeout}); err != nil {
+			return fmt.Errorf("failed to upsert document %s: %w", id, err)
+		}
+	}
+
+	var err error
+	switch {
+	case change.Replace != nil:
+		_, err = collection.Replace(doc.ID, change.Replace, &gocb.ReplaceOptions{
+			Cas:            doc.Cas,
+			PreserveExpiry: true,
+			Timeout:        timeout,
+		})
+	case len(change.MutateIn) > 0:
+		_, err = collection.MutateIn(doc.ID, change.MutateIn, &gocb.MutateInOptions{
+			Cas:            doc.Cas,
+			PreserveExpiry: true,
+			Timeout:        timeout,
+		})
+	case change.Remove:
+		_, err = collection.Remove(doc.ID, &gocb.RemoveOptions{
+			Cas:     doc.Cas,
+			Timeout: timeout,
+		})
+	}
+
+	return err
+}
+
+// fetch reads the current version of a document, returning nil if it no longer exists.
+func (mr *migrationRun) fetch(id string) (*Document, error) {
+	res, err := mr.migration.Collection.Get(id, &gocb.GetOptions{Timeout: mr.runner.timeout})
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &Document{
+		ID:      id,
+		Cas:     res.Cas(),
+		content: res.Content,
+	}, nil
+}
+
+func (c *Change) validate() error {
+	set := 0
+	if c.Replace != nil {
+		set++
+	}
+	if len(c.MutateIn) > 0 {
+		set++
+	}
+	if c.Remove {
+		set++
+	}
+	if set > 1 {
+		return invalidMigration("a change can only replace, mutate or remove the document")
+	}
+
+	return nil
+}
+
+func meterCounter(meter gocb.Meter, name string, tags map[string]string) gocb.Counter {
+	counter, err := meter.Counter(name, tags)
+	if err != nil {
+		counter, _ = (&gocb.NoopMeter{}).Counter(name, tags)
+	}
+
+	return counter
+}
+
+func meterValueRecorder(meter gocb.Meter, name string, tags map[string]string) gocb.ValueRecorder {
+	recorder, err := meter.ValueRecorder(name, tags)
+	if err != nil {
+		recorder, _ = (&gocb.NoopMeter{}).ValueRecorder(name, tags)
+	}
+
+	return recorder
+}
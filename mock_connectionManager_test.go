@@ -196,6 +196,36 @@ func (_m *mockConnectionManager) getBucketManagementProvider() (bucketManagement
 	return r0, r1
 }
 
+// getChangeStreamProvider provides a mock function with given fields: bucketName
+func (_m *mockConnectionManager) getChangeStreamProvider(bucketName string) (changeStreamProvider, error) {
+	ret := _m.Called(bucketName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for getChangeStreamProvider")
+	}
+
+	var r0 changeStreamProvider
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (changeStreamProvider, error)); ok {
+		return rf(bucketName)
+	}
+	if rf, ok := ret.Get(0).(func(string) changeStreamProvider); ok {
+		r0 = rf(bucketName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(changeStreamProvider)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(bucketName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // getCollectionsManagementProvider provides a mock function with given fields: bucketName
 func (_m *mockConnectionManager) getCollectionsManagementProvider(bucketName string) (collectionsManagementProvider, error) {
 	ret := _m.Called(bucketName)
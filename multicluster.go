@@ -0,0 +1,433 @@
+package gocb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMultiClusterHealthCheckInterval = 5 * time.Second
+	defaultMultiClusterHealthCheckTimeout  = 2 * time.Second
+	defaultMultiClusterFailureThreshold    = 3
+	defaultMultiClusterRecoveryThreshold   = 3
+)
+
+// MultiClusterFailoverMode specifies which operations a MultiCluster will route away from an unhealthy cluster.
+// UNCOMMITTED: This API may change in the future.
+type MultiClusterFailoverMode uint
+
+const (
+	// MultiClusterFailoverReadsAndWrites indicates that both reads and writes fail over. This is the default.
+	MultiClusterFailoverReadsAndWrites MultiClusterFailoverMode = iota
+
+	// MultiClusterFailoverReads indicates that only reads fail over, writes are always sent to the primary.
+	MultiClusterFailoverReads
+
+	// MultiClusterFailoverNone indicates that all operations are always sent to the primary, health is still
+	// tracked and reported.
+	MultiClusterFailoverNone
+)
+
+// MultiClusterMember is a named cluster within a MultiCluster.
+// UNCOMMITTED: This API may change in the future.
+type MultiClusterMember struct {
+	Name    string
+	Cluster *Cluster
+}
+
+// MultiClusterOptions is the set of options available when creating a MultiCluster.
+// UNCOMMITTED: This API may change in the future.
+type MultiClusterOptions struct {
+	FailoverMode MultiClusterFailoverMode
+
+	// DisableFailback keeps traffic on the cluster that was failed over to, rather than returning to a cluster
+	// earlier in the list once it is healthy again.
+	DisableFailback bool
+
+	// HealthCheckInterval is how often every cluster is checked, defaults to 5 seconds.
+	HealthCheckInterval time.Duration
+
+	// HealthCheckTimeout is the timeout used for pinging services, defaults to 2 seconds.
+	HealthCheckTimeout time.Duration
+
+	// HealthCheckServices are the services pinged using Cluster.Ping during a health check. The connection state
+	// reported by Cluster.Diagnostics and the state of the KV circuit breakers are always checked.
+	HealthCheckServices []ServiceType
+
+	// FailureThreshold is the number of consecutive failed health checks or operations before a cluster is
+	// considered unhealthy, defaults to 3.
+	FailureThreshold uint32
+
+	// RecoveryThreshold is the number of consecutive successful health checks or operations before an unhealthy
+	// cluster is considered healthy again, defaults to 3.
+	RecoveryThreshold uint32
+}
+
+// MultiClusterMemberHealth is the health of a cluster within a MultiCluster.
+// UNCOMMITTED: This API may change in the future.
+type MultiClusterMemberHealth struct {
+	Name                 string
+	Healthy              bool
+	Active               bool
+	ConsecutiveFailures  uint32
+	ConsecutiveSuccesses uint32
+	LastError            error
+	LastCheck            time.Time
+}
+
+type multiClusterMember struct {
+	name    string
+	cluster *Cluster
+
+	healthy              bool
+	consecutiveFailures  uint32
+	consecutiveSuccesses uint32
+	lastError            error
+	lastCheck            time.Time
+}
+
+// MultiCluster routes operations across several clusters, such as an active and passive pair linked by XDCR.
+// Operations are sent to the active cluster, which is the first cluster in the list unless it has been found to be
+// unhealthy. Health is determined by periodic health checks and by the outcome of operations.
+// UNCOMMITTED: This API may change in the future.
+type MultiCluster struct {
+	lock    sync.Mutex
+	members []*multiClusterMember
+	active  int
+
+	failoverMode      MultiClusterFailoverMode
+	disableFailback   bool
+	healthInterval    time.Duration
+	healthTimeout     time.Duration
+	healthServices    []ServiceType
+	failureThreshold  uint32
+	recoveryThreshold uint32
+
+	stopCh    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewMultiCluster creates a MultiCluster from the given clusters, in order of priority. The first cluster is the
+// primary. The clusters remain owned by the caller and are not closed by MultiCluster.Close.
+// UNCOMMITTED: This API may change in the future.
+func NewMultiCluster(members []MultiClusterMember, opts *MultiClusterOptions) (*MultiCluster, error) {
+	if opts == nil {
+		opts = &MultiClusterOptions{}
+	}
+	if len(members) == 0 {
+		return nil, makeInvalidArgumentsError("at least one cluster must be provided")
+	}
+
+	mc := &MultiCluster{
+		failoverMode:      opts.FailoverMode,
+		disableFailback:   opts.DisableFailback,
+		healthInterval:    opts.HealthCheckInterval,
+		healthTimeout:     opts.HealthCheckTimeout,
+		healthServices:    opts.HealthCheckServices,
+		failureThreshold:  opts.FailureThreshold,
+		recoveryThreshold: opts.RecoveryThreshold,
+		stopCh:            make(chan struct{}),
+	}
+	if mc.healthInterval == 0 {
+		mc.healthInterval = defaultMultiClusterHealthCheckInterval
+	}
+	if mc.healthTimeout == 0 {
+		mc.healthTimeout = defaultMultiClusterHealthCheckTimeout
+	}
+	if mc.failureThreshold == 0 {
+		mc.failureThreshold = defaultMultiClusterFailureThreshold
+	}
+	if mc.recoveryThreshold == 0 {
+		mc.recoveryThreshold = defaultMultiClusterRecoveryThreshold
+	}
+
+	names := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		if member.Name == "" || member.Cluster == nil {
+			return nil, makeInvalidArgumentsError("each cluster must have a name and a cluster")
+		}
+		if _, ok := names[member.Name]; ok {
+			return nil, makeInvalidArgumentsError("cluster names must be unique")
+		}
+		names[member.Name] = struct{}{}
+
+		mc.members = append(mc.members, &multiClusterMember{
+			name:    member.Name,
+			cluster: member.Cluster,
+			healthy: true,
+		})
+	}
+
+	go mc.healthLoop()
+
+	return mc, nil
+}
+
+// Close stops health checking.
+func (mc *MultiCluster) Close() error {
+	mc.closeOnce.Do(func() {
+		close(mc.stopCh)
+	})
+
+	return nil
+}
+
+// Active returns the name of the cluster that operations are currently routed to. With MultiClusterFailoverReads
+// this is where reads are routed, writes are always sent to the first cluster.
+func (mc *MultiCluster) Active() string {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	return mc.members[mc.active].name
+}
+
+// Health returns the health of each cluster, in order of priority.
+func (mc *MultiCluster) Health() []MultiClusterMemberHealth {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	health := make([]MultiClusterMemberHealth, len(mc.members))
+	for i, member := range mc.members {
+		health[i] = MultiClusterMemberHealth{
+			Name:                 member.name,
+			Healthy:              member.healthy,
+			Active:               i == mc.active,
+			ConsecutiveFailures:  member.consecutiveFailures,
+			ConsecutiveSuccesses: member.consecutiveSuccesses,
+			LastError:            member.lastError,
+			LastCheck:            member.lastCheck,
+		}
+	}
+
+	return health
+}
+
+// Read runs fn against the active cluster, retrying against the other healthy clusters if it fails because the
+// cluster could not be reached and reads are allowed to fail over. The name of the cluster that fn last ran
+// against is returned.
+func (mc *MultiCluster) Read(fn func(name string, cluster *Cluster) error) (string, error) {
+	return mc.route(false, fn)
+}
+
+// Write runs fn against the active cluster, retrying against the other healthy clusters if it fails because the
+// cluster could not be reached and writes are allowed to fail over. Only errors which guarantee that the write
+// was not applied cause a retry, an ambiguous timeout is always returned. The name of the cluster that fn last ran
+// against is returned.
+func (mc *MultiCluster) Write(fn func(name string, cluster *Cluster) error) (string, error) {
+	return mc.route(true, fn)
+}
+
+func (mc *MultiCluster) failsOver(write bool) bool {
+	switch mc.failoverMode {
+	case MultiClusterFailoverReadsAndWrites:
+		return true
+	case MultiClusterFailoverReads:
+		return !write
+	default:
+		return false
+	}
+}
+
+// candidates returns the members to try, in order, for an operation.
+func (mc *MultiCluster) candidates(write bool) []*multiClusterMember {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	if !mc.failsOver(write) {
+		return []*multiClusterMember{mc.members[0]}
+	}
+
+	candidates := []*multiClusterMember{mc.members[mc.active]}
+	for i, member := range mc.members {
+		if i != mc.active && member.healthy {
+			candidates = append(candidates, member)
+		}
+	}
+
+	return candidates
+}
+
+func (mc *MultiCluster) route(write bool, fn func(name string, cluster *Cluster) error) (string, error) {
+	var name string
+	var err error
+	for _, member := range mc.candidates(write) {
+		name = member.name
+		err = fn(member.name, member.cluster)
+		if err == nil {
+			mc.recordResult(member, nil)
+			return name, nil
+		}
+		if !isMultiClusterFailoverError(err, write) {
+			return name, err
+		}
+
+		mc.recordResult(member, err)
+		logDebugf("Operation against cluster %s failed, trying next cluster: %v", member.name, err)
+	}
+
+	return name, err
+}
+
+func isMultiClusterFailoverError(err error, write bool) bool {
+	if errors.Is(err, ErrServiceNotAvailable) || errors.Is(err, ErrCircuitBreakerOpen) ||
+		errors.Is(err, ErrUnambiguousTimeout) {
+		return true
+	}
+
+	return !write && errors.Is(err, ErrTimeout)
+}
+
+// recordResult updates the health of the member and then the active member.
+func (mc *MultiCluster) recordResult(member *multiClusterMember, err error) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	member.lastCheck = time.Now()
+	if err != nil {
+		member.lastError = err
+		member.consecutiveSuccesses = 0
+		member.consecutiveFailures++
+		if member.healthy && member.consecutiveFailures >= mc.failureThreshold {
+			member.healthy = false
+			logWarnf("Cluster %s is now unhealthy: %v", member.name, err)
+		}
+	} else {
+		member.consecutiveFailures = 0
+		member.consecutiveSuccesses++
+		if !member.healthy && member.consecutiveSuccesses >= mc.recoveryThreshold {
+			member.healthy = true
+			member.lastError = nil
+			logInfof("Cluster %s is now healthy", member.name)
+		}
+	}
+
+	mc.updateActiveLocked()
+}
+
+func (mc *MultiCluster) updateActiveLocked() {
+	// Nothing fails over so the first cluster is always active, whatever its health.
+	if mc.failoverMode == MultiClusterFailoverNone {
+		return
+	}
+
+	current := mc.members[mc.active]
+	if current.healthy && mc.disableFailback {
+		return
+	}
+
+	for i, member := range mc.members {
+		if !member.healthy {
+			continue
+		}
+		if i == mc.active {
+			return
+		}
+		if !current.healthy || i < mc.active {
+			logInfof("Routing traffic from cluster %s to cluster %s", current.name, member.name)
+			mc.active = i
+		}
+		return
+	}
+}
+
+func (mc *MultiCluster) healthLoop() {
+	for {
+		select {
+		case <-mc.stopCh:
+			return
+		case <-time.After(mc.healthInterval):
+		}
+
+		mc.checkHealth()
+	}
+}
+
+func (mc *MultiCluster) checkHealth() {
+	var wg sync.WaitGroup
+	for _, member := range mc.members {
+		wg.Add(1)
+		go func(member *multiClusterMember) {
+			defer wg.Done()
+			mc.recordResult(member, mc.checkMemberHealth(member.cluster))
+		}(member)
+	}
+	wg.Wait()
+}
+
+func (mc *MultiCluster) checkMemberHealth(cluster *Cluster) error {
+	diag, err := cluster.Diagnostics(nil)
+	if err != nil {
+		return err
+	}
+	if diag.State == ClusterStateOffline {
+		return wrapError(ErrServiceNotAvailable, "no nodes are reachable")
+	}
+
+	for _, state := range cluster.CircuitBreakers() {
+		if state.Service == ServiceTypeKeyValue && state.State == CircuitBreakerStateOpen {
+			return wrapError(ErrCircuitBreakerOpen, fmt.Sprintf("circuit breaker open for %s%s", state.Bucket,
+				state.Endpoint))
+		}
+	}
+
+	if len(mc.healthServices) == 0 {
+		return nil
+	}
+
+	report, err := cluster.Ping(&PingOptions{
+		ServiceTypes: mc.healthServices,
+		Timeout:      mc.healthTimeout,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, service := range mc.healthServices {
+		var ok bool
+		for _, endpoint := range report.Services[service] {
+			if endpoint.State == PingStateOk {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return wrapError(ErrServiceNotAvailable, fmt.Sprintf("no %s endpoints responded to ping",
+				serviceTypeToString(service)))
+		}
+	}
+
+	return nil
+}
+
+// MultiClusterQueryResult is a QueryResult along with the name of the cluster that served it.
+// UNCOMMITTED: This API may change in the future.
+type MultiClusterQueryResult struct {
+	*QueryResult
+	ClusterName string
+}
+
+// Query executes the query statement against the active cluster. Queries are treated as writes unless
+// QueryOptions.Readonly is set. Only the dispatch of the query can fail over, errors whilst streaming rows are
+// returned by the result.
+func (mc *MultiCluster) Query(statement string, opts *QueryOptions) (*MultiClusterQueryResult, error) {
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+
+	var res *QueryResult
+	name, err := mc.route(!opts.Readonly, func(_ string, cluster *Cluster) error {
+		var err error
+		res, err = cluster.Query(statement, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultiClusterQueryResult{
+		QueryResult: res,
+		ClusterName: name,
+	}, nil
+}
@@ -0,0 +1,118 @@
+package gocb
+
+import "sync"
+
+// MultiClusterGetResult is a GetResult along with the name of the cluster that served it.
+// UNCOMMITTED: This API may change in the future.
+type MultiClusterGetResult struct {
+	*GetResult
+	ClusterName string
+}
+
+// MultiClusterMutationResult is a MutationResult along with the name of the cluster that served it.
+// UNCOMMITTED: This API may change in the future.
+type MultiClusterMutationResult struct {
+	*MutationResult
+	ClusterName string
+}
+
+// MultiClusterCollection performs KV operations against a collection of the same name on each cluster of a
+// MultiCluster.
+// UNCOMMITTED: This API may change in the future.
+type MultiClusterCollection struct {
+	mc             *MultiCluster
+	bucketName     string
+	scopeName      string
+	collectionName string
+
+	lock        sync.Mutex
+	collections map[string]*Collection
+}
+
+// Collection returns a MultiClusterCollection for the given keyspace, which must exist on every cluster.
+func (mc *MultiCluster) Collection(bucketName, scopeName, collectionName string) *MultiClusterCollection {
+	return &MultiClusterCollection{
+		mc:             mc,
+		bucketName:     bucketName,
+		scopeName:      scopeName,
+		collectionName: collectionName,
+		collections:    make(map[string]*Collection),
+	}
+}
+
+// collection lazily opens the bucket on a cluster the first time that it is routed to.
+func (c *MultiClusterCollection) collection(name string, cluster *Cluster) *Collection {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	col, ok := c.collections[name]
+	if !ok {
+		col = cluster.Bucket(c.bucketName).Scope(c.scopeName).Collection(c.collectionName)
+		c.collections[name] = col
+	}
+
+	return col
+}
+
+// Get performs a fetch operation against the active cluster.
+func (c *MultiClusterCollection) Get(id string, opts *GetOptions) (*MultiClusterGetResult, error) {
+	var res *GetResult
+	name, err := c.mc.Read(func(name string, cluster *Cluster) error {
+		var err error
+		res, err = c.collection(name, cluster).Get(id, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultiClusterGetResult{
+		GetResult:   res,
+		ClusterName: name,
+	}, nil
+}
+
+// Insert creates a new document on the active cluster.
+func (c *MultiClusterCollection) Insert(id string, val interface{}, opts *InsertOptions) (*MultiClusterMutationResult, error) {
+	return c.mutate(func(col *Collection) (*MutationResult, error) {
+		return col.Insert(id, val, opts)
+	})
+}
+
+// Upsert creates a new document on the active cluster or replaces an existing one.
+func (c *MultiClusterCollection) Upsert(id string, val interface{}, opts *UpsertOptions) (*MultiClusterMutationResult, error) {
+	return c.mutate(func(col *Collection) (*MutationResult, error) {
+		return col.Upsert(id, val, opts)
+	})
+}
+
+// Replace updates a document on the active cluster.
+func (c *MultiClusterCollection) Replace(id string, val interface{}, opts *ReplaceOptions) (*MultiClusterMutationResult, error) {
+	return c.mutate(func(col *Collection) (*MutationResult, error) {
+		return col.Replace(id, val, opts)
+	})
+}
+
+// Remove removes a document from the active cluster.
+func (c *MultiClusterCollection) Remove(id string, opts *RemoveOptions) (*MultiClusterMutationResult, error) {
+	return c.mutate(func(col *Collection) (*MutationResult, error) {
+		return col.Remove(id, opts)
+	})
+}
+
+func (c *MultiClusterCollection) mutate(fn func(col *Collection) (*MutationResult, error)) (*MultiClusterMutationResult, error) {
+	var res *MutationResult
+	name, err := c.mc.Write(func(name string, cluster *Cluster) error {
+		var err error
+		res, err = fn(c.collection(name, cluster))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultiClusterMutationResult{
+		MutationResult: res,
+		ClusterName:    name,
+	}, nil
+}
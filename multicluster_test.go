@@ -0,0 +1,240 @@
+package gocb
+
+import (
+	"errors"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *UnitTestSuite) newMultiCluster(opts *MultiClusterOptions, names ...string) *MultiCluster {
+	var members []MultiClusterMember
+	for _, name := range names {
+		members = append(members, MultiClusterMember{Name: name, Cluster: &Cluster{}})
+	}
+
+	mc, err := NewMultiCluster(members, opts)
+	suite.Require().Nil(err, err)
+	suite.T().Cleanup(func() {
+		suite.Assert().Nil(mc.Close())
+	})
+
+	return mc
+}
+
+func (suite *UnitTestSuite) TestMultiClusterInvalidArgs() {
+	_, err := NewMultiCluster(nil, nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = NewMultiCluster([]MultiClusterMember{{Name: "a", Cluster: &Cluster{}}, {Name: "a", Cluster: &Cluster{}}}, nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = NewMultiCluster([]MultiClusterMember{{Name: "a"}}, nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
+
+func (suite *UnitTestSuite) TestMultiClusterReadFailsOver() {
+	mc := suite.newMultiCluster(&MultiClusterOptions{FailureThreshold: 2}, "primary", "secondary")
+
+	var attempts []string
+	name, err := mc.Read(func(name string, _ *Cluster) error {
+		attempts = append(attempts, name)
+		if name == "primary" {
+			return ErrAmbiguousTimeout
+		}
+		return nil
+	})
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal("secondary", name)
+	suite.Assert().Equal([]string{"primary", "secondary"}, attempts)
+
+	// The primary is still active until the failure threshold is reached.
+	suite.Assert().Equal("primary", mc.Active())
+
+	_, err = mc.Read(func(name string, _ *Cluster) error {
+		if name == "primary" {
+			return ErrServiceNotAvailable
+		}
+		return nil
+	})
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal("secondary", mc.Active())
+
+	health := mc.Health()
+	suite.Require().Len(health, 2)
+	suite.Assert().False(health[0].Healthy)
+	suite.Assert().Equal(uint32(2), health[0].ConsecutiveFailures)
+	suite.Assert().ErrorIs(health[0].LastError, ErrServiceNotAvailable)
+	suite.Assert().True(health[1].Active)
+
+	// Errors that don't indicate an unhealthy cluster are returned as is.
+	notFound := errors.New("not found")
+	name, err = mc.Read(func(name string, _ *Cluster) error {
+		return notFound
+	})
+	suite.Assert().Equal(notFound, err)
+	suite.Assert().Equal("secondary", name)
+}
+
+func (suite *UnitTestSuite) TestMultiClusterWriteFailover() {
+	mc := suite.newMultiCluster(nil, "primary", "secondary")
+
+	// Ambiguous timeouts on writes must not be retried elsewhere.
+	var attempts int
+	name, err := mc.Write(func(name string, _ *Cluster) error {
+		attempts++
+		return ErrAmbiguousTimeout
+	})
+	suite.Assert().ErrorIs(err, ErrAmbiguousTimeout)
+	suite.Assert().Equal("primary", name)
+	suite.Assert().Equal(1, attempts)
+
+	name, err = mc.Write(func(name string, _ *Cluster) error {
+		if name == "primary" {
+			return ErrUnambiguousTimeout
+		}
+		return nil
+	})
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal("secondary", name)
+
+	readsOnly := suite.newMultiCluster(&MultiClusterOptions{
+		FailoverMode:     MultiClusterFailoverReads,
+		FailureThreshold: 1,
+	}, "primary", "secondary")
+
+	name, err = readsOnly.Write(func(name string, _ *Cluster) error {
+		return ErrUnambiguousTimeout
+	})
+	suite.Assert().ErrorIs(err, ErrUnambiguousTimeout)
+	suite.Assert().Equal("primary", name)
+
+	// Reads go to the secondary, writes stay with the primary.
+	suite.Assert().Equal("secondary", readsOnly.Active())
+	name, _ = readsOnly.Write(func(name string, _ *Cluster) error {
+		return nil
+	})
+	suite.Assert().Equal("primary", name)
+}
+
+func (suite *UnitTestSuite) TestMultiClusterFailoverNone() {
+	mc := suite.newMultiCluster(&MultiClusterOptions{
+		FailoverMode:     MultiClusterFailoverNone,
+		FailureThreshold: 1,
+	}, "primary", "secondary")
+
+	name, err := mc.Read(func(name string, _ *Cluster) error {
+		return ErrServiceNotAvailable
+	})
+	suite.Assert().ErrorIs(err, ErrServiceNotAvailable)
+	suite.Assert().Equal("primary", name)
+
+	// The primary is unhealthy but is still the cluster that operations are sent to.
+	suite.Assert().Equal("primary", mc.Active())
+	health := mc.Health()
+	suite.Assert().False(health[0].Healthy)
+	suite.Assert().True(health[0].Active)
+	suite.Assert().False(health[1].Active)
+
+	name, _ = mc.Read(func(name string, _ *Cluster) error {
+		return nil
+	})
+	suite.Assert().Equal("primary", name)
+}
+
+func (suite *UnitTestSuite) TestMultiClusterFailback() {
+	mc := suite.newMultiCluster(&MultiClusterOptions{FailureThreshold: 1, RecoveryThreshold: 2}, "primary", "secondary")
+	primary := mc.members[0]
+
+	mc.recordResult(primary, ErrServiceNotAvailable)
+	suite.Assert().Equal("secondary", mc.Active())
+
+	mc.recordResult(primary, nil)
+	suite.Assert().Equal("secondary", mc.Active())
+	mc.recordResult(primary, nil)
+	suite.Assert().Equal("primary", mc.Active())
+
+	sticky := suite.newMultiCluster(&MultiClusterOptions{
+		FailureThreshold:  1,
+		RecoveryThreshold: 1,
+		DisableFailback:   true,
+	}, "primary", "secondary")
+
+	sticky.recordResult(sticky.members[0], ErrServiceNotAvailable)
+	sticky.recordResult(sticky.members[0], nil)
+	suite.Assert().Equal("secondary", sticky.Active())
+
+	// Traffic only moves again once the cluster that was failed over to is unhealthy.
+	sticky.recordResult(sticky.members[1], ErrServiceNotAvailable)
+	suite.Assert().Equal("primary", sticky.Active())
+}
+
+func (suite *UnitTestSuite) TestMultiClusterHealthCheck() {
+	newMemberCluster := func(state ClusterState, queryState PingState) *Cluster {
+		diag := new(mockDiagnosticsProvider)
+		diag.On("Diagnostics", mock.AnythingOfType("*gocb.DiagnosticsOptions")).
+			Return(&DiagnosticsResult{State: state}, nil)
+		diag.On("Ping", mock.AnythingOfType("*gocb.PingOptions")).
+			Return(&PingResult{Services: map[ServiceType][]EndpointPingReport{
+				ServiceTypeQuery: {{State: queryState}},
+			}}, nil)
+
+		cli := new(mockConnectionManager)
+		cli.On("getDiagnosticsProvider", "").Return(diag, nil)
+		cli.On("MarkOpBeginning").Return()
+		cli.On("MarkOpCompleted").Return()
+
+		return suite.newCluster(cli)
+	}
+
+	offline := newMemberCluster(ClusterStateOffline, PingStateOk)
+	queryDown := newMemberCluster(ClusterStateOnline, PingStateError)
+	healthy := newMemberCluster(ClusterStateOnline, PingStateOk)
+	breakerOpen := newMemberCluster(ClusterStateOnline, PingStateOk)
+	breakerOpen.circuitBreakers = newCircuitBreakerRegistry(CircuitBreakerConfig{VolumeThreshold: 1}, nil)
+	breakerOpen.circuitBreakers.get(ServiceTypeKeyValue, "default", "", false).record(ErrTimeout)
+
+	mc, err := NewMultiCluster([]MultiClusterMember{
+		{Name: "offline", Cluster: offline},
+		{Name: "querydown", Cluster: queryDown},
+		{Name: "breakeropen", Cluster: breakerOpen},
+		{Name: "healthy", Cluster: healthy},
+	}, &MultiClusterOptions{
+		FailureThreshold:    1,
+		HealthCheckServices: []ServiceType{ServiceTypeQuery},
+	})
+	suite.Require().Nil(err, err)
+	defer mc.Close()
+
+	mc.checkHealth()
+
+	health := mc.Health()
+	suite.Assert().ErrorIs(health[0].LastError, ErrServiceNotAvailable)
+	suite.Assert().ErrorIs(health[1].LastError, ErrServiceNotAvailable)
+	suite.Assert().ErrorIs(health[2].LastError, ErrCircuitBreakerOpen)
+	suite.Assert().True(health[3].Healthy)
+	suite.Assert().Equal("healthy", mc.Active())
+}
+
+func (suite *UnitTestSuite) TestMultiClusterCollectionReportsCluster() {
+	primary := new(mockKvProvider)
+	primary.On("Get", mock.Anything, "someid", mock.AnythingOfType("*gocb.GetOptions")).
+		Return(nil, ErrUnambiguousTimeout)
+	primary.On("Upsert", mock.Anything, "someid", "value", mock.AnythingOfType("*gocb.UpsertOptions")).
+		Return(&MutationResult{}, nil)
+	secondary := new(mockKvProvider)
+	secondary.On("Get", mock.Anything, "someid", mock.AnythingOfType("*gocb.GetOptions")).
+		Return(&GetResult{}, nil)
+
+	mc := suite.newMultiCluster(nil, "primary", "secondary")
+	col := mc.Collection("mock", "_default", "_default")
+	col.collections["primary"] = suite.collection("mock", "_default", "_default", primary)
+	col.collections["secondary"] = suite.collection("mock", "_default", "_default", secondary)
+
+	getRes, err := col.Get("someid", nil)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal("secondary", getRes.ClusterName)
+
+	mutRes, err := col.Upsert("someid", "value", nil)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal("primary", mutRes.ClusterName)
+}
@@ -21,6 +21,7 @@ type queryProviderCore struct {
 	transcoder           Transcoder
 	timeouts             TimeoutsConfig
 	tracer               *tracerWrapper
+	rateLimiter          *serviceRateLimiter
 }
 
 func (qpc *queryProviderCore) Query(statement string, s *Scope, opts *QueryOptions) (*QueryResult, error) {
@@ -68,6 +69,16 @@ func (qpc *queryProviderCore) Query(statement string, s *Scope, opts *QueryOptio
 		}
 	}
 
+	release, err := qpc.rateLimiter.acquire(opts.Context, deadline)
+	if err != nil {
+		return nil, &QueryError{
+			InnerError:      err,
+			Statement:       statement,
+			ClientContextID: maybeGetQueryOption(queryOpts, "client_context_id"),
+		}
+	}
+	retryStrategy = qpc.rateLimiter.wrapRetryStrategy(retryStrategy.forOperation(deadline, span))
+
 	var res queryRowReader
 	var qErr error
 	if opts.Adhoc {
@@ -89,6 +100,8 @@ func (qpc *queryProviderCore) Query(statement string, s *Scope, opts *QueryOptio
 			Endpoint:      opts.Internal.Endpoint,
 		})
 	}
+	// The limiter only governs dispatch, streaming the rows is not counted against it.
+	release(qErr)
 	if qErr != nil {
 		return nil, maybeEnhanceCoreQueryError(qErr)
 	}
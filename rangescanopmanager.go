@@ -36,9 +36,8 @@ type rangeScanOpManager struct {
 	agent  kvProviderCoreProvider
 	tracer *tracerWrapper
 
-	defaultRetryStrategy *coreRetryStrategyWrapper
-	defaultTranscoder    Transcoder
-	defaultTimeout       time.Duration
+	defaultTranscoder Transcoder
+	defaultTimeout    time.Duration
 
 	cid        uint32
 	bucketName string
@@ -51,6 +50,10 @@ type rangeScanOpManager struct {
 	samplingOptions       *gocbcore.RangeScanCreateRandomSamplingConfig
 	vBucketToSnapshotOpts map[uint16]gocbcore.RangeScanCreateSnapshotRequirements
 
+	// resumeToken is the token for the start of the scan, nil for sampling scans.
+	resumeToken *ScanResumeToken
+	resumeFrom  *ScanResumeToken
+
 	numVbuckets        int
 	serverToVbucketMap map[int][]uint16
 	keysOnly           bool
@@ -69,7 +72,8 @@ type rangeScanVbucket struct {
 }
 
 func (p *kvProviderCore) newRangeScanOpManager(c *Collection, scanType ScanType, agent kvProviderCoreProvider,
-	parentSpan RequestSpan, consistentWith *MutationState, keysOnly bool) (*rangeScanOpManager, error) {
+	parentSpan RequestSpan, consistentWith *MutationState, resumeFrom *ScanResumeToken,
+	keysOnly bool) (*rangeScanOpManager, error) {
 
 	span := p.tracer.createSpan(parentSpan, "range_scan", "kv_scan")
 	span.SetAttribute(spanAttribDBNameKey, c.bucket.Name())
@@ -80,8 +84,16 @@ func (p *kvProviderCore) newRangeScanOpManager(c *Collection, scanType ScanType,
 
 	var rangeOptions *gocbcore.RangeScanCreateRangeScanConfig
 	var samplingOptions *gocbcore.RangeScanCreateRandomSamplingConfig
+	var rangeScan *RangeScan
 
 	setRangeScanOpts := func(st RangeScan) error {
+		if resumeFrom != nil {
+			if err := resumeFrom.validate(c, st); err != nil {
+				return err
+			}
+		}
+		rangeScan = &st
+
 		if st.To == nil {
 			st.To = ScanTermMaximum()
 		}
@@ -102,6 +114,9 @@ func (p *kvProviderCore) newRangeScanOpManager(c *Collection, scanType ScanType,
 	}
 
 	setSamplingScanOpts := func(st SamplingScan) error {
+		if resumeFrom != nil {
+			return makeInvalidArgumentsError("sampling scans cannot be resumed")
+		}
 		if st.Seed == 0 {
 			st.Seed = rand.Uint64() // #nosec G404
 		}
@@ -165,6 +180,18 @@ func (p *kvProviderCore) newRangeScanOpManager(c *Collection, scanType ScanType,
 		}
 	}
 
+	// A resumed scan must observe at least the mutations that the scan it resumes from did.
+	for vbID, progress := range resumeFrom.vbucketProgress() {
+		if _, ok := vBucketToSnapshotOpts[vbID]; !ok && progress.snapshot != nil {
+			vBucketToSnapshotOpts[vbID] = *progress.snapshot
+		}
+	}
+
+	var resumeToken *ScanResumeToken
+	if rangeScan != nil {
+		resumeToken = newScanResumeToken(c, *rangeScan, 0, vBucketToSnapshotOpts)
+	}
+
 	m := &rangeScanOpManager{
 		err: err,
 
@@ -173,11 +200,10 @@ func (p *kvProviderCore) newRangeScanOpManager(c *Collection, scanType ScanType,
 
 		cancelCh: make(chan struct{}),
 
-		agent:                agent,
-		defaultTimeout:       c.timeoutsConfig.KVScanTimeout,
-		defaultTranscoder:    c.transcoder,
-		defaultRetryStrategy: c.retryStrategyWrapper,
-		bucketName:           c.Bucket().Name(),
+		agent:             agent,
+		defaultTimeout:    c.timeoutsConfig.KVScanTimeout,
+		defaultTranscoder: c.transcoder,
+		bucketName:        c.Bucket().Name(),
 
 		scopeName:      c.ScopeName(),
 		collectionName: c.Name(),
@@ -185,6 +211,8 @@ func (p *kvProviderCore) newRangeScanOpManager(c *Collection, scanType ScanType,
 		rangeOptions:          rangeOptions,
 		samplingOptions:       samplingOptions,
 		vBucketToSnapshotOpts: vBucketToSnapshotOpts,
+		resumeToken:           resumeToken,
+		resumeFrom:            resumeFrom,
 		keysOnly:              keysOnly,
 	}
 
@@ -206,6 +234,9 @@ func (m *rangeScanOpManager) SetCollectionID(cid uint32) {
 func (m *rangeScanOpManager) SetNumVbuckets(numVbuckets int) {
 	m.numVbuckets = numVbuckets
 	m.span.SetAttribute("num_partitions", numVbuckets)
+	if m.resumeToken != nil {
+		m.resumeToken.numVbuckets = numVbuckets
+	}
 }
 
 func (m *rangeScanOpManager) SetServerToVbucketMap(serverVbucketMap map[int][]uint16) {
@@ -315,6 +346,11 @@ func (m *rangeScanOpManager) CheckReadyForOp() error {
 		return errors.New("range scan op manager had no number of partitions specified")
 	}
 
+	if m.resumeFrom != nil && m.resumeFrom.numVbuckets != m.numVbuckets {
+		return makeInvalidArgumentsError("resume token was created by a scan of a bucket with a different number " +
+			"of vbuckets")
+	}
+
 	return nil
 }
 
@@ -366,6 +402,8 @@ func (m *rangeScanOpManager) Scan(ctx context.Context) (*ScanResult, error) {
 	r := &ScanResult{
 		resultChan: resultCh,
 		cancelFn:   m.Cancel,
+		ctx:        ctx,
+		progress:   newScanProgressTracker(m.resumeToken, m.numVbuckets, m.resumeFrom),
 
 		limit: limit,
 	}
@@ -405,6 +443,7 @@ func (m *rangeScanOpManager) Scan(ctx context.Context) (*ScanResult, error) {
 					if failPoint == scanFailPointCreate {
 						if errors.Is(err, gocbcore.ErrDocumentNotFound) {
 							logDebugf("Ignoring vbid %d as no documents exist for that vbucket", vbucket.id)
+							r.progress.partitionScanned(vbucket.id)
 							continue
 						}
 
@@ -498,7 +537,7 @@ func (m *rangeScanOpManager) scanPartition(ctx context.Context, deadline time.Ti
 	span := m.tracer.createSpan(m.span, "range_scan_partition", "")
 	span.SetAttribute("partition_id", vbID)
 	defer span.End()
-	var lastTermSeen []byte
+	lastTermSeen := m.resumeFrom.lastKey(vbID)
 	rangeOpts := m.RangeOptions()
 	samplingOpts := m.SamplingOptions()
 
@@ -507,7 +546,8 @@ func (m *rangeScanOpManager) scanPartition(ctx context.Context, deadline time.Ti
 		if rangeOpts != nil && len(lastTermSeen) > 0 {
 			// Make a copy of the range options so that we don't affect the manager level ones.
 			newRangeOpts := *rangeOpts
-			newRangeOpts.Start = lastTermSeen
+			newRangeOpts.Start = nil
+			newRangeOpts.ExclusiveStart = lastTermSeen
 			rangeOpts = &newRangeOpts
 		}
 
@@ -527,7 +567,7 @@ func (m *rangeScanOpManager) scanPartition(ctx context.Context, deadline time.Ti
 			items, isComplete, err := m.continueStream(ctx, span, createRes)
 			if err != nil {
 				err = m.EnhanceErr(err)
-				// If the error is NMV or EOF then we should recreate the stream from the last known item.
+				// If the error is NMV or EOF then we should recreate the stream from after the last known item.
 				// Breaking here without calling cancel will trigger us to reloop rather than call Cancel on
 				// the stream and then return.
 				if errors.Is(err, gocbcore.ErrNotMyVBucket) || errors.Is(err, io.EOF) {
@@ -542,6 +582,7 @@ func (m *rangeScanOpManager) scanPartition(ctx context.Context, deadline time.Ti
 					if item.Expiry > 0 {
 						expiry = time.Unix(int64(item.Expiry), 0)
 					}
+					m.result.progress.itemProduced(vbID)
 					select {
 					case <-m.cancelCh:
 						if !isComplete {
@@ -558,12 +599,14 @@ func (m *rangeScanOpManager) scanPartition(ctx context.Context, deadline time.Ti
 						contents:   item.Value,
 						expiryTime: expiry,
 						keysOnly:   m.KeysOnly(),
+						vbID:       vbID,
 					}:
 					}
 				}
 				lastTermSeen = items[len(items)-1].Key
 			}
 			if isComplete {
+				m.result.progress.partitionScanned(vbID)
 				return 0, nil
 			}
 		}
@@ -596,6 +639,7 @@ func (m *rangeScanOpManager) createStream(ctx context.Context, parentSpan Reques
 
 	var createResOut gocbcore.RangeScanCreateResult
 	var errOut error
+	// Range scans are retried by gocbcore itself, they do not take a RetryStrategy.
 	err := opMan.Wait(m.agent.RangeScanCreate(vbID, gocbcore.RangeScanCreateOptions{
 		Deadline:     deadline,
 		CollectionID: m.cid,
@@ -709,7 +753,21 @@ func (m *rangeScanOpManager) createLoadBalancer() *rangeScanLoadBalancer {
 		seed = time.Now().UnixNano()
 	}
 
-	return newRangeScanLoadBalancer(m.serverToVbucketMap, seed)
+	// Vbuckets which were completed by the scan being resumed from are not scanned again.
+	serverToVbucketMap := m.serverToVbucketMap
+	if m.resumeFrom != nil {
+		serverToVbucketMap = make(map[int][]uint16, len(m.serverToVbucketMap))
+		for server, vbuckets := range m.serverToVbucketMap {
+			serverToVbucketMap[server] = []uint16{}
+			for _, vbID := range vbuckets {
+				if !m.resumeFrom.complete(vbID) {
+					serverToVbucketMap[server] = append(serverToVbucketMap[server], vbID)
+				}
+			}
+		}
+	}
+
+	return newRangeScanLoadBalancer(serverToVbucketMap, seed)
 }
 
 type rangeScanLoadBalancer struct {
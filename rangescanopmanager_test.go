@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -384,7 +385,8 @@ func (suite *UnitTestSuite) TestScanNMV() {
 					}, nil)
 				} else {
 					if opts.Range != nil {
-						firstTermAfter = string(opts.Range.Start)
+						suite.Assert().Nil(opts.Range.Start)
+						firstTermAfter = string(opts.Range.ExclusiveStart)
 					}
 					cb(&rangeScanCreateResult{
 						continueRunFunc: func(dataCb gocbcore.RangeScanContinueDataCallback, actionCb gocbcore.RangeScanContinueActionCallback) {
@@ -454,11 +456,94 @@ func (suite *UnitTestSuite) TestScanNMV() {
 		// 3 vbuckets will yield 1 item,  the other will yield 2 items on the first continue, then 1 further on the
 		// continue after the NMV.
 		suite.Assert().Len(ids, 6)
-		// A NMV error should trigger a new create with the start being the last term seen.
+		// A NMV error should trigger a new create starting after the last term seen.
 		suite.Assert().Equal(lastTermSeen, firstTermAfter)
 	})
 }
 
+func (suite *UnitTestSuite) TestScanResume() {
+	var lock sync.Mutex
+	created := make(map[uint16]gocbcore.RangeScanCreateOptions)
+	provider := makeRangeScanProvider(func(args mock.Arguments) {
+		vbID := args.Get(0).(uint16)
+		opts := args.Get(1).(gocbcore.RangeScanCreateOptions)
+		cb := args.Get(2).(gocbcore.RangeScanCreateCallback)
+
+		lock.Lock()
+		created[vbID] = opts
+		lock.Unlock()
+
+		cb(&rangeScanCreateResult{
+			items: []gocbcore.RangeScanItem{
+				{
+					Key:   []byte(fmt.Sprintf("hi-%d", vbID)),
+					Value: []byte("value"),
+				},
+			},
+		}, nil)
+	})
+
+	snap := newMockConfigSnapshot(4, 2)
+	agent := suite.kvProviderCore(provider, &mockConfigSnapshotProvider{snapshot: snap})
+	col := suite.collection("mock", "", "", agent)
+	scan := NewRangeScanForPrefix("hi")
+
+	// Vbucket 0 was completed and vbucket 1 had returned a key by the scan being resumed.
+	token := newScanResumeToken(col, scan, 4, nil)
+	token.vbuckets[0] = scanVbucketProgress{complete: true}
+	token.vbuckets[1] = scanVbucketProgress{
+		lastKey: []byte("hi-0"),
+		snapshot: &gocbcore.RangeScanCreateSnapshotRequirements{
+			VbUUID: 1234,
+			SeqNo:  12,
+		},
+	}
+
+	res, err := agent.Scan(col, scan, &ScanOptions{ResumeFrom: token})
+	suite.Require().NoError(err)
+
+	ids := suite.iterateRangeScan(res)
+	suite.Require().NoError(res.Err())
+	suite.Assert().Equal(map[string]struct{}{"hi-1": {}, "hi-2": {}, "hi-3": {}}, ids)
+
+	suite.Require().Len(created, 3)
+	suite.Assert().NotContains(created, uint16(0))
+	suite.Assert().Nil(created[1].Range.Start)
+	suite.Assert().Equal([]byte("hi-0"), created[1].Range.ExclusiveStart)
+	suite.Assert().Equal(&gocbcore.RangeScanCreateSnapshotRequirements{VbUUID: 1234, SeqNo: 12}, created[1].Snapshot)
+	suite.Assert().Equal([]byte("hi"), created[2].Range.Start)
+	suite.Assert().Nil(created[2].Snapshot)
+
+	suite.Assert().Equal(ScanProgress{
+		VbucketsCompleted: 4,
+		VbucketsTotal:     4,
+		ItemsStreamed:     3,
+		BytesStreamed:     27,
+	}, res.Progress())
+
+	resumeToken := res.ResumeToken()
+	for vbID := uint16(0); vbID < 4; vbID++ {
+		suite.Assert().True(resumeToken.complete(vbID))
+	}
+	suite.Assert().Equal(token.vbuckets[1].snapshot, resumeToken.vbuckets[1].snapshot)
+
+	suite.Run("DifferentRange", func() {
+		_, err := agent.Scan(col, NewRangeScanForPrefix("other"), &ScanOptions{ResumeFrom: token})
+		suite.Assert().ErrorIs(err, ErrInvalidArgument)
+	})
+
+	suite.Run("DifferentNumVbuckets", func() {
+		token := newScanResumeToken(col, scan, 1024, nil)
+		_, err := agent.Scan(col, scan, &ScanOptions{ResumeFrom: token})
+		suite.Assert().ErrorIs(err, ErrInvalidArgument)
+	})
+
+	suite.Run("Sampling", func() {
+		_, err := agent.Scan(col, SamplingScan{Limit: 10}, &ScanOptions{ResumeFrom: token})
+		suite.Assert().ErrorIs(err, ErrInvalidArgument)
+	})
+}
+
 func (suite *UnitTestSuite) iterateRangeScan(res *ScanResult) map[string]struct{} {
 	ids := make(map[string]struct{})
 	for {
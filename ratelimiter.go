@@ -0,0 +1,454 @@
+package gocb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v10"
+)
+
+const (
+	defaultRateLimitDecreaseFactor   = 0.5
+	defaultRateLimitIncreaseInterval = 1 * time.Second
+	defaultRateLimitDecreaseCooldown = 250 * time.Millisecond
+)
+
+// RateLimitConfig specifies options for client side rate limiting of operations. Each service is limited
+// independently, a service with no rate and no concurrency limit configured is not limited at all.
+// Rate limiting is currently only applied to KV operations and to the dispatch of query and search requests, it is
+// not applied when using the couchbase2 protocol.
+// UNCOMMITTED: This API may change in the future.
+type RateLimitConfig struct {
+	KV     ServiceRateLimit
+	Query  ServiceRateLimit
+	Search ServiceRateLimit
+}
+
+// ServiceRateLimit specifies the rate limit applied to a single service.
+// UNCOMMITTED: This API may change in the future.
+type ServiceRateLimit struct {
+	// OpsPerSecond is the maximum sustained rate at which operations are dispatched. When Adaptive is set this is the
+	// ceiling that the rate will grow back to. 0 means that the rate is not limited.
+	OpsPerSecond float64
+
+	// Burst is the number of operations which can be dispatched at once before the rate limit applies.
+	// Defaults to OpsPerSecond, or 1 if OpsPerSecond is less than 1.
+	Burst uint
+
+	// MaxConcurrency is the maximum number of operations in flight at once. 0 means that concurrency is not limited.
+	MaxConcurrency uint
+
+	// Adaptive enables additive-increase/multiplicative-decrease of the rate. The rate is reduced by DecreaseFactor
+	// whenever the server indicates that it is overloaded, via a temporary failure or busy response, and increased
+	// by IncreaseStep for each second without such a response. Requires OpsPerSecond to be set.
+	Adaptive bool
+
+	// MinOpsPerSecond is the lowest rate that Adaptive mode will reduce to.
+	// Defaults to a tenth of OpsPerSecond, or 1 if that is less than 1.
+	MinOpsPerSecond float64
+
+	// IncreaseStep is the number of operations per second added to the rate after each second without the server
+	// indicating that it is overloaded.
+	// Defaults to a twentieth of OpsPerSecond, or 1 if that is less than 1.
+	IncreaseStep float64
+
+	// DecreaseFactor is the multiplier applied to the rate when the server indicates that it is overloaded.
+	// Defaults to 0.5.
+	DecreaseFactor float64
+}
+
+type rateLimiters struct {
+	kv     *serviceRateLimiter
+	query  *serviceRateLimiter
+	search *serviceRateLimiter
+}
+
+func newRateLimiters(config RateLimitConfig, meter Meter) (*rateLimiters, error) {
+	kv, err := newServiceRateLimiter(serviceValueKV, config.KV, meter)
+	if err != nil {
+		return nil, err
+	}
+	query, err := newServiceRateLimiter(serviceValueQuery, config.Query, meter)
+	if err != nil {
+		return nil, err
+	}
+	search, err := newServiceRateLimiter(serviceValueSearch, config.Search, meter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rateLimiters{
+		kv:     kv,
+		query:  query,
+		search: search,
+	}, nil
+}
+
+// serviceRateLimiter combines a token bucket, limiting the rate at which operations are dispatched, with a semaphore
+// limiting the number of operations in flight. A nil serviceRateLimiter does not limit anything.
+type serviceRateLimiter struct {
+	service string
+
+	lock         sync.Mutex
+	rate         float64
+	maxRate      float64
+	minRate      float64
+	burst        float64
+	tokens       float64
+	lastRefill   time.Time
+	lastIncrease time.Time
+	lastDecrease time.Time
+
+	adaptive         bool
+	increaseStep     float64
+	decreaseFactor   float64
+	increaseInterval time.Duration
+	decreaseCooldown time.Duration
+
+	slots chan struct{}
+
+	throttledCounter Counter
+	rejectedCounter  Counter
+	waitRecorder     ValueRecorder
+	rateRecorder     ValueRecorder
+}
+
+func newServiceRateLimiter(service string, config ServiceRateLimit, meter Meter) (*serviceRateLimiter, error) {
+	if config.OpsPerSecond < 0 || config.MinOpsPerSecond < 0 || config.IncreaseStep < 0 || config.DecreaseFactor < 0 ||
+		config.DecreaseFactor >= 1 {
+		return nil, makeInvalidArgumentsError("invalid " + service + " rate limit configuration")
+	}
+	if config.Adaptive && config.OpsPerSecond == 0 {
+		return nil, makeInvalidArgumentsError("adaptive " + service + " rate limiting requires OpsPerSecond to be set")
+	}
+	if config.OpsPerSecond == 0 && config.MaxConcurrency == 0 {
+		return nil, nil
+	}
+
+	l := &serviceRateLimiter{
+		service:          service,
+		rate:             config.OpsPerSecond,
+		maxRate:          config.OpsPerSecond,
+		minRate:          config.MinOpsPerSecond,
+		burst:            float64(config.Burst),
+		adaptive:         config.Adaptive,
+		increaseStep:     config.IncreaseStep,
+		decreaseFactor:   config.DecreaseFactor,
+		increaseInterval: defaultRateLimitIncreaseInterval,
+		decreaseCooldown: defaultRateLimitDecreaseCooldown,
+		throttledCounter: defaultNoopCounter,
+		rejectedCounter:  defaultNoopCounter,
+		waitRecorder:     defaultNoopValueRecorder,
+		rateRecorder:     defaultNoopValueRecorder,
+	}
+	if l.burst == 0 {
+		l.burst = l.rate
+		if l.burst < 1 {
+			l.burst = 1
+		}
+	}
+	if l.minRate == 0 {
+		l.minRate = l.rate / 10
+		if l.minRate < 1 {
+			l.minRate = 1
+		}
+	}
+	if l.minRate > l.maxRate {
+		l.minRate = l.maxRate
+	}
+	if l.increaseStep == 0 {
+		l.increaseStep = l.rate / 20
+		if l.increaseStep < 1 {
+			l.increaseStep = 1
+		}
+	}
+	if l.decreaseFactor == 0 {
+		l.decreaseFactor = defaultRateLimitDecreaseFactor
+	}
+	l.tokens = l.burst
+	if config.MaxConcurrency > 0 {
+		l.slots = make(chan struct{}, config.MaxConcurrency)
+	}
+
+	if meter != nil {
+		tags := map[string]string{
+			meterAttribServiceKey: service,
+		}
+		if counter, err := meter.Counter(meterNameRateLimiterThrottled, tags); err == nil {
+			l.throttledCounter = counter
+		}
+		if counter, err := meter.Counter(meterNameRateLimiterRejected, tags); err == nil {
+			l.rejectedCounter = counter
+		}
+		if recorder, err := meter.ValueRecorder(meterNameRateLimiterWait, tags); err == nil {
+			l.waitRecorder = recorder
+		}
+		if recorder, err := meter.ValueRecorder(meterNameRateLimiterRate, tags); err == nil {
+			l.rateRecorder = recorder
+		}
+	}
+
+	return l, nil
+}
+
+// currentRate returns the current rate limit in operations per second, which may be lower than the configured rate
+// when adaptive mode has backed off.
+func (l *serviceRateLimiter) currentRate() float64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.rate
+}
+
+// refill must be called with the lock held.
+func (l *serviceRateLimiter) refill(now time.Time) {
+	if !l.lastRefill.IsZero() {
+		l.tokens += now.Sub(l.lastRefill).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.lastRefill = now
+}
+
+// reserve takes a token from the bucket, returning how long the caller must wait before it is available. The rate is
+// read under the lock as adaptive mode changes it whilst operations are being dispatched.
+func (l *serviceRateLimiter) reserve(now time.Time) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.rate == 0 {
+		return 0
+	}
+
+	l.refill(now)
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+func (l *serviceRateLimiter) cancelReservation() {
+	l.lock.Lock()
+	l.tokens++
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.lock.Unlock()
+}
+
+func (l *serviceRateLimiter) reject(err error) error {
+	l.rejectedCounter.IncrementBy(1)
+	return err
+}
+
+// acquire blocks until an operation may be dispatched, or until the deadline is reached or the context is done.
+// The returned function must be called with the outcome of the operation once it has completed.
+func (l *serviceRateLimiter) acquire(ctx context.Context, deadline time.Time) (func(error), error) {
+	if l == nil {
+		return func(error) {}, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	start := time.Now()
+	var timer *time.Timer
+	deadlineCh := func() <-chan time.Time {
+		if timer == nil {
+			timer = time.NewTimer(time.Until(deadline))
+		}
+		return timer.C
+	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	waited := false
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			waited = true
+			select {
+			case l.slots <- struct{}{}:
+			case <-deadlineCh():
+				return nil, l.reject(wrapError(ErrUnambiguousTimeout, "timed out waiting for an available "+l.service+
+					" concurrency slot"))
+			case <-ctx.Done():
+				return nil, l.reject(translateRateLimitCtxErr(ctx.Err()))
+			}
+		}
+	}
+
+	releaseSlot := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	if wait := l.reserve(time.Now()); wait > 0 {
+		waited = true
+		if time.Now().Add(wait).After(deadline) {
+			l.cancelReservation()
+			releaseSlot()
+			return nil, l.reject(wrapError(ErrUnambiguousTimeout, "timed out waiting for "+l.service+" rate limit"))
+		}
+
+		waitTimer := time.NewTimer(wait)
+		select {
+		case <-waitTimer.C:
+		case <-ctx.Done():
+			waitTimer.Stop()
+			l.cancelReservation()
+			releaseSlot()
+			return nil, l.reject(translateRateLimitCtxErr(ctx.Err()))
+		}
+	}
+
+	if waited {
+		l.throttledCounter.IncrementBy(1)
+		l.waitRecorder.RecordValue(uint64(time.Since(start).Microseconds()))
+	}
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			releaseSlot()
+			l.observe(err)
+		})
+	}, nil
+}
+
+// observe feeds the outcome of an operation into adaptive mode.
+func (l *serviceRateLimiter) observe(err error) {
+	if !l.adaptive {
+		return
+	}
+
+	if isRateLimitThrottleError(err) {
+		l.decrease(time.Now())
+		return
+	}
+	if err == nil {
+		l.increase(time.Now())
+	}
+}
+
+func (l *serviceRateLimiter) increase(now time.Time) {
+	l.lock.Lock()
+	if l.lastIncrease.IsZero() {
+		l.lastIncrease = now
+	}
+	if l.rate >= l.maxRate || now.Sub(l.lastIncrease) < l.increaseInterval {
+		l.lock.Unlock()
+		return
+	}
+
+	l.refill(now)
+	l.rate += l.increaseStep
+	if l.rate > l.maxRate {
+		l.rate = l.maxRate
+	}
+	l.lastIncrease = now
+	rate := l.rate
+	l.lock.Unlock()
+
+	l.rateRecorder.RecordValue(uint64(rate))
+}
+
+func (l *serviceRateLimiter) decrease(now time.Time) {
+	l.lock.Lock()
+	// Many in flight operations will see the same overload so only back off once per cooldown period.
+	if !l.lastDecrease.IsZero() && now.Sub(l.lastDecrease) < l.decreaseCooldown {
+		l.lock.Unlock()
+		return
+	}
+
+	l.refill(now)
+	l.rate *= l.decreaseFactor
+	if l.rate < l.minRate {
+		l.rate = l.minRate
+	}
+	l.lastDecrease = now
+	l.lastIncrease = now
+	rate := l.rate
+	l.lock.Unlock()
+
+	logDebugf("Reduced %s rate limit to %f ops/s", l.service, rate)
+	l.rateRecorder.RecordValue(uint64(rate))
+}
+
+// wrapRetryStrategy wraps the retry strategy so that retries caused by the server being overloaded are fed into
+// adaptive mode. Operations which are retried internally never surface these errors to the caller.
+func (l *serviceRateLimiter) wrapRetryStrategy(wrapper *coreRetryStrategyWrapper) *coreRetryStrategyWrapper {
+	if l == nil || !l.adaptive || wrapper == nil {
+		return wrapper
+	}
+
+	return &coreRetryStrategyWrapper{
+		wrapped: &rateLimitRetryStrategy{
+			wrapped: wrapper.wrapped,
+			limiter: l,
+		},
+		deadline: wrapper.deadline,
+		span:     wrapper.span,
+	}
+}
+
+type rateLimitRetryStrategy struct {
+	wrapped RetryStrategy
+	limiter *serviceRateLimiter
+}
+
+func (rs *rateLimitRetryStrategy) RetryAfter(req RetryRequest, reason RetryReason) RetryAction {
+	switch reason {
+	case KVTemporaryFailureRetryReason, SearchTooManyRequestsRetryReason, ServiceResponseCodeIndicatedRetryReason:
+		rs.limiter.decrease(time.Now())
+	}
+
+	return rs.wrapped.RetryAfter(req, reason)
+}
+
+func isRateLimitThrottleError(err error) bool {
+	return errors.Is(err, ErrTemporaryFailure) || errors.Is(err, gocbcore.ErrBusy) ||
+		errors.Is(err, ErrRateLimitedFailure)
+}
+
+func translateRateLimitCtxErr(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return wrapError(ErrTimeout, "context deadline exceeded waiting for rate limiter")
+	}
+
+	return wrapError(ErrRequestCanceled, "context canceled waiting for rate limiter")
+}
+
+func (r *rateLimiters) kvLimiter() *serviceRateLimiter {
+	if r == nil {
+		return nil
+	}
+
+	return r.kv
+}
+
+func (r *rateLimiters) queryLimiter() *serviceRateLimiter {
+	if r == nil {
+		return nil
+	}
+
+	return r.query
+}
+
+func (r *rateLimiters) searchLimiter() *serviceRateLimiter {
+	if r == nil {
+		return nil
+	}
+
+	return r.search
+}
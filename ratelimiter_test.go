@@ -0,0 +1,230 @@
+package gocb
+
+import (
+	"context"
+	"time"
+
+	"github.com/couchbase/gocbcore/v10"
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *UnitTestSuite) TestRateLimiterDisabled() {
+	limiter, err := newServiceRateLimiter(serviceValueKV, ServiceRateLimit{}, nil)
+	suite.Require().Nil(err, err)
+	suite.Assert().Nil(limiter)
+
+	release, err := limiter.acquire(context.Background(), time.Now())
+	suite.Require().Nil(err, err)
+	release(nil)
+}
+
+func (suite *UnitTestSuite) TestRateLimiterInvalidConfig() {
+	_, err := newRateLimiters(RateLimitConfig{KV: ServiceRateLimit{Adaptive: true}}, nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = newRateLimiters(RateLimitConfig{Query: ServiceRateLimit{OpsPerSecond: 10, DecreaseFactor: 2}}, nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = newRateLimiters(RateLimitConfig{Search: ServiceRateLimit{OpsPerSecond: -1}}, nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
+
+func (suite *UnitTestSuite) TestRateLimiterTokenBucket() {
+	meter := newTestMeter()
+	limiter, err := newServiceRateLimiter(serviceValueKV, ServiceRateLimit{OpsPerSecond: 20, Burst: 2}, meter)
+	suite.Require().Nil(err, err)
+
+	deadline := time.Now().Add(time.Second)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := limiter.acquire(context.Background(), deadline)
+		suite.Require().Nil(err, err)
+		release(nil)
+	}
+
+	// The burst is consumed by the first two so the third must wait for a token.
+	suite.Assert().GreaterOrEqual(time.Since(start), 40*time.Millisecond)
+	suite.Assert().Equal(uint64(1), meter.counters[meterNameRateLimiterThrottled+":"].count)
+	suite.Assert().Len(meter.recorders[meterNameRateLimiterWait+":"+serviceValueKV].values, 1)
+}
+
+func (suite *UnitTestSuite) TestRateLimiterDeadline() {
+	meter := newTestMeter()
+	limiter, err := newServiceRateLimiter(serviceValueKV, ServiceRateLimit{OpsPerSecond: 1}, meter)
+	suite.Require().Nil(err, err)
+
+	release, err := limiter.acquire(context.Background(), time.Now().Add(time.Second))
+	suite.Require().Nil(err, err)
+	release(nil)
+
+	_, err = limiter.acquire(context.Background(), time.Now().Add(10*time.Millisecond))
+	suite.Assert().ErrorIs(err, ErrUnambiguousTimeout)
+	suite.Assert().Equal(uint64(1), meter.counters[meterNameRateLimiterRejected+":"].count)
+
+	// The rejected operation must not have consumed the token.
+	limiter.lock.Lock()
+	suite.Assert().GreaterOrEqual(limiter.tokens, float64(-0.01))
+	limiter.lock.Unlock()
+}
+
+func (suite *UnitTestSuite) TestRateLimiterConcurrency() {
+	limiter, err := newServiceRateLimiter(serviceValueQuery, ServiceRateLimit{MaxConcurrency: 1}, nil)
+	suite.Require().Nil(err, err)
+
+	release, err := limiter.acquire(context.Background(), time.Now().Add(time.Second))
+	suite.Require().Nil(err, err)
+
+	_, err = limiter.acquire(context.Background(), time.Now().Add(10*time.Millisecond))
+	suite.Assert().ErrorIs(err, ErrUnambiguousTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = limiter.acquire(ctx, time.Now().Add(time.Second))
+	suite.Assert().ErrorIs(err, ErrRequestCanceled)
+
+	release(nil)
+	// Calling release more than once must not free a second slot.
+	release(nil)
+
+	release, err = limiter.acquire(context.Background(), time.Now().Add(time.Second))
+	suite.Require().Nil(err, err)
+	_, err = limiter.acquire(context.Background(), time.Now().Add(10*time.Millisecond))
+	suite.Assert().ErrorIs(err, ErrUnambiguousTimeout)
+	release(nil)
+}
+
+func (suite *UnitTestSuite) TestRateLimiterAdaptive() {
+	meter := newTestMeter()
+	limiter, err := newServiceRateLimiter(serviceValueKV, ServiceRateLimit{
+		OpsPerSecond: 100,
+		Adaptive:     true,
+	}, meter)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal(float64(10), limiter.minRate)
+	suite.Assert().Equal(float64(5), limiter.increaseStep)
+
+	release, err := limiter.acquire(context.Background(), time.Now().Add(time.Second))
+	suite.Require().Nil(err, err)
+	release(ErrTemporaryFailure)
+	suite.Assert().Equal(float64(50), limiter.currentRate())
+
+	// Further failures within the cooldown do not back off again.
+	limiter.observe(gocbcore.ErrBusy)
+	suite.Assert().Equal(float64(50), limiter.currentRate())
+
+	now := time.Now()
+	limiter.decrease(now.Add(limiter.decreaseCooldown))
+	suite.Assert().Equal(float64(25), limiter.currentRate())
+	limiter.decrease(now.Add(2 * limiter.decreaseCooldown))
+	limiter.decrease(now.Add(3 * limiter.decreaseCooldown))
+	limiter.decrease(now.Add(4 * limiter.decreaseCooldown))
+	suite.Assert().Equal(float64(10), limiter.currentRate())
+
+	// Successes only increase the rate once per interval.
+	limiter.observe(nil)
+	suite.Assert().Equal(float64(10), limiter.currentRate())
+
+	later := now.Add(4*limiter.decreaseCooldown + limiter.increaseInterval)
+	limiter.increase(later)
+	suite.Assert().Equal(float64(15), limiter.currentRate())
+	limiter.increase(later.Add(100 * limiter.increaseInterval))
+	limiter.increase(later.Add(100 * limiter.increaseInterval))
+	suite.Assert().Equal(float64(20), limiter.currentRate())
+
+	for i := 1; i < 50; i++ {
+		limiter.increase(later.Add(time.Duration(100+i) * limiter.increaseInterval))
+	}
+	suite.Assert().Equal(float64(100), limiter.currentRate())
+
+	suite.Assert().NotEmpty(meter.recorders[meterNameRateLimiterRate+":"+serviceValueKV].values)
+}
+
+func (suite *UnitTestSuite) TestRateLimiterRetryStrategy() {
+	limiter, err := newServiceRateLimiter(serviceValueKV, ServiceRateLimit{OpsPerSecond: 100, Adaptive: true}, nil)
+	suite.Require().Nil(err, err)
+
+	wrapper := limiter.wrapRetryStrategy(newCoreRetryStrategyWrapper(NewBestEffortRetryStrategy(nil)))
+	action := wrapper.wrapped.RetryAfter(&mockRetryRequest{}, KVNotMyVBucketRetryReason)
+	suite.Assert().NotNil(action)
+	suite.Assert().Equal(float64(100), limiter.currentRate())
+
+	wrapper.wrapped.RetryAfter(&mockRetryRequest{}, KVTemporaryFailureRetryReason)
+	suite.Assert().Equal(float64(50), limiter.currentRate())
+
+	fixed, err := newServiceRateLimiter(serviceValueKV, ServiceRateLimit{OpsPerSecond: 100}, nil)
+	suite.Require().Nil(err, err)
+	original := newCoreRetryStrategyWrapper(NewBestEffortRetryStrategy(nil))
+	suite.Assert().Equal(original, fixed.wrapRetryStrategy(original))
+}
+
+func (suite *UnitTestSuite) TestRateLimiterQueryBacksOff() {
+	provider := new(mockQueryProviderCoreProvider)
+	provider.
+		On("N1QLQuery", nil, mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Return(nil, gocbcore.ErrTemporaryFailure)
+
+	limiter, err := newServiceRateLimiter(serviceValueQuery, ServiceRateLimit{OpsPerSecond: 100, Adaptive: true}, nil)
+	suite.Require().Nil(err, err)
+
+	queryProvider := &queryProviderCore{
+		provider:    provider,
+		rateLimiter: limiter,
+	}
+	cli := new(mockConnectionManager)
+	cli.On("getQueryProvider").Return(queryProvider, nil)
+	cli.On("getMeter").Return(nil)
+	cli.On("MarkOpBeginning").Return()
+	cli.On("MarkOpCompleted").Return()
+
+	cluster := suite.newCluster(cli)
+
+	queryProvider.tracer = newTracerWrapper(&NoopTracer{})
+	queryProvider.retryStrategyWrapper = cluster.retryStrategyWrapper
+	queryProvider.timeouts = cluster.timeoutsConfig
+
+	_, err = cluster.Query("SELECT * FROM dataset", &QueryOptions{
+		Adhoc: true,
+	})
+	suite.Require().ErrorIs(err, ErrTemporaryFailure)
+	suite.Assert().Equal(float64(50), limiter.currentRate())
+}
+
+func (suite *UnitTestSuite) TestRateLimiterBulkOps() {
+	provider := new(mockKvProviderCoreProvider)
+	provider.
+		On("Get", mock.AnythingOfType("gocbcore.GetOptions"), mock.AnythingOfType("gocbcore.GetCallback")).
+		Run(func(args mock.Arguments) {
+			cb := args.Get(1).(gocbcore.GetCallback)
+			go cb(&gocbcore.GetResult{Value: []byte(`1`)}, nil)
+		}).
+		Return(nil, nil)
+
+	limiter, err := newServiceRateLimiter(serviceValueKV, ServiceRateLimit{MaxConcurrency: 1}, nil)
+	suite.Require().Nil(err, err)
+
+	bulkProvider := &kvBulkProviderCore{
+		agent:       provider,
+		tracer:      newTracerWrapper(&NoopTracer{}),
+		meter:       newMeterWrapper(&NoopMeter{}),
+		rateLimiter: limiter,
+	}
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	// Each op must release its concurrency slot as it completes for the later ops to be dispatched.
+	ops := []BulkOp{&GetOp{ID: "a"}, &GetOp{ID: "b"}, &GetOp{ID: "c"}}
+	suite.Require().Nil(bulkProvider.Do(col, ops, &BulkOpOptions{Timeout: time.Second}))
+	for _, op := range ops {
+		suite.Assert().Nil(op.(*GetOp).Err)
+	}
+	provider.AssertNumberOfCalls(suite.T(), "Get", 3)
+
+	// An op which cannot acquire the limit before the timeout is failed without being dispatched.
+	release, err := limiter.acquire(context.Background(), time.Now().Add(time.Second))
+	suite.Require().Nil(err, err)
+	ops = []BulkOp{&GetOp{ID: "d"}}
+	suite.Require().Nil(bulkProvider.Do(col, ops, &BulkOpOptions{Timeout: 10 * time.Millisecond}))
+	suite.Assert().ErrorIs(ops[0].(*GetOp).Err, ErrUnambiguousTimeout)
+	provider.AssertNumberOfCalls(suite.T(), "Get", 3)
+	release(nil)
+}
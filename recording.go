@@ -0,0 +1,943 @@
+package gocb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	gocbcore "github.com/couchbase/gocbcore/v10"
+)
+
+// RecordingMode specifies whether the HTTP based traffic of a cluster is recorded or replayed.
+// UNCOMMITTED: This API may change in the future.
+type RecordingMode uint
+
+const (
+	// RecordingModeNone indicates that traffic is neither recorded nor replayed.
+	RecordingModeNone RecordingMode = iota
+
+	// RecordingModeRecord indicates that every query, search, analytics, view and management request sent to the
+	// cluster is written to the recording directory along with its response.
+	RecordingModeRecord
+
+	// RecordingModeReplay indicates that query, search, analytics, view and management requests are served from
+	// the recording directory rather than sent to a cluster. No connection is made to the cluster at all, key-value
+	// operations are served by the same in-memory store as used by the fake:// scheme.
+	RecordingModeReplay
+)
+
+// RecordingConfig specifies options for recording and replaying the query, search, analytics, view and management
+// traffic of a cluster.
+//
+// Each request and response pair is stored as a JSON file in Directory, named by the service and a hash of the
+// request. Values which differ between otherwise identical requests, such as client context IDs and timeouts, are
+// not part of the hash. When replaying, identical requests are served their recordings in the order they were
+// recorded and, once those are exhausted, the last recording is served again. Requests which were never recorded
+// fail with ErrRecordingNotFound.
+//
+// The values of fields holding credentials, such as the passwords of users created through the UserManager, are
+// redacted from recorded requests, and recordings are only readable by the user which wrote them.
+//
+// Key-value traffic is never recorded. When replaying, key-value operations are served by an in-memory store which
+// starts out empty, so any documents which the replayed code reads must be written by it first.
+//
+// Recording is not supported with the couchbase2:// or fake:// schemes.
+// UNCOMMITTED: This API may change in the future.
+type RecordingConfig struct {
+	Mode RecordingMode
+
+	// Directory is the directory that recordings are written to, or read from when replaying.
+	Directory string
+}
+
+// httpRecorder records and replays the request and response pairs of the HTTP based services.
+type httpRecorder struct {
+	mode RecordingMode
+	dir  string
+
+	lock     sync.Mutex
+	seen     map[string]int
+	recorded map[string]int
+}
+
+func newHTTPRecorder(config RecordingConfig) (*httpRecorder, error) {
+	if config.Mode == RecordingModeNone {
+		return nil, nil
+	}
+
+	if config.Directory == "" {
+		return nil, makeInvalidArgumentsError("recording directory must be set when recording or replaying")
+	}
+
+	r := &httpRecorder{
+		mode:     config.Mode,
+		dir:      config.Directory,
+		seen:     make(map[string]int),
+		recorded: make(map[string]int),
+	}
+
+	switch config.Mode {
+	case RecordingModeRecord:
+		if err := os.MkdirAll(config.Directory, 0o700); err != nil {
+			return nil, err
+		}
+	case RecordingModeReplay:
+		entries, err := os.ReadDir(config.Directory)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			key, n, ok := parseRecordingFileName(entry.Name())
+			if !ok {
+				continue
+			}
+
+			if n+1 > r.recorded[key] {
+				r.recorded[key] = n + 1
+			}
+		}
+	default:
+		return nil, makeInvalidArgumentsError("unknown recording mode")
+	}
+
+	return r, nil
+}
+
+func (r *httpRecorder) recording() bool {
+	return r != nil && r.mode == RecordingModeRecord
+}
+
+func (r *httpRecorder) replaying() bool {
+	return r != nil && r.mode == RecordingModeReplay
+}
+
+func recordingFileName(key string, n int) string {
+	return key + "-" + strconv.Itoa(n) + ".json"
+}
+
+func parseRecordingFileName(name string) (string, int, bool) {
+	if !strings.HasSuffix(name, ".json") {
+		return "", 0, false
+	}
+
+	name = strings.TrimSuffix(name, ".json")
+	idx := strings.LastIndexByte(name, '-')
+	if idx <= 0 {
+		return "", 0, false
+	}
+
+	n, err := strconv.Atoi(name[idx+1:])
+	if err != nil || n < 0 {
+		return "", 0, false
+	}
+
+	return name[:idx], n, true
+}
+
+type recordedInteraction struct {
+	Service  string           `json:"service"`
+	Request  json.RawMessage  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedResponse struct {
+	Endpoint          string            `json:"endpoint,omitempty"`
+	StatusCode        int               `json:"status_code,omitempty"`
+	Body              string            `json:"body,omitempty"`
+	Rows              []json.RawMessage `json:"rows,omitempty"`
+	RowsError         *recordedError    `json:"rows_error,omitempty"`
+	MetaData          json.RawMessage   `json:"meta_data,omitempty"`
+	MetaDataError     string            `json:"meta_data_error,omitempty"`
+	PreparedName      string            `json:"prepared_name,omitempty"`
+	PreparedNameError string            `json:"prepared_name_error,omitempty"`
+	Error             *recordedError    `json:"error,omitempty"`
+}
+
+// recordingRequest identifies a single request, n is the number of identical requests made before it.
+type recordingRequest struct {
+	service string
+	key     string
+	n       int
+	request json.RawMessage
+}
+
+func (r *httpRecorder) begin(service string, request interface{}) (*recordingRequest, error) {
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(requestJSON)
+	key := service + "-" + hex.EncodeToString(hash[:8])
+
+	r.lock.Lock()
+	n := r.seen[key]
+	r.seen[key]++
+	r.lock.Unlock()
+
+	return &recordingRequest{
+		service: service,
+		key:     key,
+		n:       n,
+		request: requestJSON,
+	}, nil
+}
+
+func (r *httpRecorder) record(req *recordingRequest, resp recordedResponse) {
+	data, err := json.MarshalIndent(recordedInteraction{
+		Service:  req.service,
+		Request:  req.request,
+		Response: resp,
+	}, "", "  ")
+	if err != nil {
+		logErrorf("Failed to encode recording of %s request: %v", req.service, err)
+		return
+	}
+
+	err = os.WriteFile(filepath.Join(r.dir, recordingFileName(req.key, req.n)), data, 0o600)
+	if err != nil {
+		logErrorf("Failed to write recording of %s request: %v", req.service, err)
+	}
+}
+
+func (r *httpRecorder) replay(req *recordingRequest) (*recordedResponse, error) {
+	r.lock.Lock()
+	count := r.recorded[req.key]
+	r.lock.Unlock()
+
+	if count == 0 {
+		return nil, wrapError(ErrRecordingNotFound, fmt.Sprintf("no recording of %s request %s", req.service, req.request))
+	}
+
+	n := req.n
+	if n >= count {
+		n = count - 1
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.dir, recordingFileName(req.key, n)))
+	if err != nil {
+		return nil, err
+	}
+
+	var interaction recordedInteraction
+	if err := json.Unmarshal(data, &interaction); err != nil {
+		return nil, err
+	}
+
+	return &interaction.Response, nil
+}
+
+// recordingCredentialFields are the lower case names of the request fields which hold credentials, whose values are
+// redacted from recordings.
+var recordingCredentialFields = map[string]struct{}{
+	"password":              {},
+	"pass":                  {},
+	"secretaccesskey":       {},
+	"sessiontoken":          {},
+	"accountkey":            {},
+	"sharedaccesssignature": {},
+	"clientkey":             {},
+	"clientsecret":          {},
+	"privatekey":            {},
+}
+
+const recordingRedacted = "REDACTED"
+
+func isRecordingCredentialField(name string) bool {
+	_, ok := recordingCredentialFields[strings.ToLower(name)]
+	return ok
+}
+
+// redactRecordingValue replaces the values of any credential fields within a decoded JSON value.
+func redactRecordingValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if isRecordingCredentialField(key) {
+				v[key] = recordingRedacted
+			} else {
+				v[key] = redactRecordingValue(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactRecordingValue(child)
+		}
+	}
+
+	return value
+}
+
+// redactRecordingForm replaces the values of any credential fields of a form encoded payload.
+func redactRecordingForm(payload []byte) string {
+	form, err := url.ParseQuery(string(payload))
+	if err != nil {
+		return string(payload)
+	}
+
+	redacted := false
+	for key := range form {
+		if isRecordingCredentialField(key) {
+			form[key] = []string{recordingRedacted}
+			redacted = true
+		}
+	}
+	if !redacted {
+		return string(payload)
+	}
+
+	return form.Encode()
+}
+
+// recordingPayload returns a request payload without the given top level and nested fields, so that only the parts of
+// the request which determine its response are compared, and with any credentials redacted.
+func recordingPayload(payload []byte, volatile ...string) interface{} {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var value map[string]interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return redactRecordingForm(payload)
+	}
+
+	for _, path := range volatile {
+		parent := value
+		parts := strings.Split(path, ".")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := parent[part].(map[string]interface{})
+			if !ok {
+				parent = nil
+				break
+			}
+			parent = child
+		}
+
+		if parent != nil {
+			delete(parent, parts[len(parts)-1])
+		}
+	}
+
+	return redactRecordingValue(value)
+}
+
+type recordedErrorDesc struct {
+	Code       uint32                 `json:"code,omitempty"`
+	Message    string                 `json:"message"`
+	Retry      bool                   `json:"retry,omitempty"`
+	Reason     map[string]interface{} `json:"reason,omitempty"`
+	SourceNode string                 `json:"source_node,omitempty"`
+}
+
+type recordedError struct {
+	Kind               string              `json:"kind"`
+	Message            string              `json:"message"`
+	Cause              string              `json:"cause,omitempty"`
+	Statement          string              `json:"statement,omitempty"`
+	IndexName          string              `json:"index_name,omitempty"`
+	DesignDocumentName string              `json:"design_document_name,omitempty"`
+	ViewName           string              `json:"view_name,omitempty"`
+	Errors             []recordedErrorDesc `json:"errors,omitempty"`
+	ErrorText          string              `json:"error_text,omitempty"`
+	StatusCode         int                 `json:"status_code,omitempty"`
+	Endpoint           string              `json:"endpoint,omitempty"`
+}
+
+// recordableErrors are the errors that a recorded error can be matched against when replayed, more specific errors
+// must come before those which they wrap.
+var recordableErrors = []error{
+	ErrAmbiguousTimeout,
+	ErrUnambiguousTimeout,
+	ErrTimeout,
+	ErrRequestCanceled,
+	ErrInvalidArgument,
+	ErrServiceNotAvailable,
+	ErrInternalServerFailure,
+	ErrAuthenticationFailure,
+	ErrTemporaryFailure,
+	ErrParsingFailure,
+	ErrCasMismatch,
+	ErrBucketNotFound,
+	ErrScopeNotFound,
+	ErrCollectionNotFound,
+	ErrUnsupportedOperation,
+	ErrFeatureNotAvailable,
+	ErrIndexNotFound,
+	ErrIndexExists,
+	ErrRateLimitedFailure,
+	ErrQuotaLimitedFailure,
+	ErrDocumentNotFound,
+	ErrDocumentExists,
+	ErrPlanningFailure,
+	ErrIndexFailure,
+	ErrPreparedStatementFailure,
+	ErrCompilationFailure,
+	ErrJobQueueFull,
+	ErrDatasetNotFound,
+	ErrDataverseNotFound,
+	ErrDatasetExists,
+	ErrDataverseExists,
+	ErrLinkNotFound,
+	ErrViewNotFound,
+	ErrDesignDocumentNotFound,
+	ErrCollectionExists,
+	ErrScopeExists,
+	ErrUserNotFound,
+	ErrGroupNotFound,
+	ErrBucketExists,
+	ErrUserExists,
+	ErrBucketNotFlushable,
+	ErrEventingFunctionNotFound,
+	ErrEventingFunctionNotDeployed,
+	ErrEventingFunctionCompilationFailure,
+	ErrEventingFunctionIdenticalKeyspace,
+	ErrEventingFunctionNotBootstrapped,
+	ErrEventingFunctionDeployed,
+	ErrOverload,
+	ErrCircuitBreakerOpen,
+}
+
+// recordedInnerError stands in for an error which was recorded, retaining its message and what it wrapped.
+type recordedInnerError struct {
+	message string
+	cause   error
+}
+
+func (e *recordedInnerError) Error() string {
+	return e.message
+}
+
+func (e *recordedInnerError) Unwrap() error {
+	return e.cause
+}
+
+func newRecordedError(err error) *recordedError {
+	var rerr recordedError
+	inner := err
+
+	switch e := err.(type) {
+	case *gocbcore.N1QLError:
+		rerr = recordedError{Kind: "query", Statement: e.Statement, ErrorText: e.ErrorText,
+			StatusCode: e.HTTPResponseCode, Endpoint: e.Endpoint}
+		for _, desc := range e.Errors {
+			rerr.Errors = append(rerr.Errors, recordedErrorDesc{Code: desc.Code, Message: desc.Message,
+				Retry: desc.Retry, Reason: desc.Reason})
+		}
+		inner = e.InnerError
+	case *gocbcore.AnalyticsError:
+		rerr = recordedError{Kind: "analytics", Statement: e.Statement, ErrorText: e.ErrorText,
+			StatusCode: e.HTTPResponseCode, Endpoint: e.Endpoint}
+		for _, desc := range e.Errors {
+			rerr.Errors = append(rerr.Errors, recordedErrorDesc{Code: desc.Code, Message: desc.Message})
+		}
+		inner = e.InnerError
+	case *gocbcore.SearchError:
+		rerr = recordedError{Kind: "search", IndexName: e.IndexName, ErrorText: e.ErrorText,
+			StatusCode: e.HTTPResponseCode, Endpoint: e.Endpoint}
+		inner = e.InnerError
+	case *gocbcore.ViewError:
+		rerr = recordedError{Kind: "view", DesignDocumentName: e.DesignDocumentName, ViewName: e.ViewName,
+			ErrorText: e.ErrorText, StatusCode: e.HTTPResponseCode, Endpoint: e.Endpoint}
+		for _, desc := range e.Errors {
+			rerr.Errors = append(rerr.Errors, recordedErrorDesc{Message: desc.Message, SourceNode: desc.SourceNode})
+		}
+		inner = e.InnerError
+	case *gocbcore.HTTPError:
+		rerr = recordedError{Kind: "http", Endpoint: e.Endpoint}
+		inner = e.InnerError
+	case gocbcore.HTTPError:
+		rerr = recordedError{Kind: "http", Endpoint: e.Endpoint}
+		inner = e.InnerError
+	case *gocbcore.TimeoutError:
+		rerr = recordedError{Kind: "timeout"}
+		inner = e.InnerError
+	default:
+		rerr = recordedError{Kind: "generic"}
+	}
+
+	if inner == nil {
+		inner = err
+	}
+	rerr.Message = inner.Error()
+	for _, cause := range recordableErrors {
+		if errors.Is(inner, cause) {
+			rerr.Cause = cause.Error()
+			break
+		}
+	}
+
+	return &rerr
+}
+
+func (e *recordedError) innerError() error {
+	var cause error
+	for _, recordable := range recordableErrors {
+		if recordable.Error() == e.Cause {
+			cause = recordable
+			break
+		}
+	}
+
+	if cause != nil && cause.Error() == e.Message {
+		return cause
+	}
+
+	return &recordedInnerError{
+		message: e.Message,
+		cause:   cause,
+	}
+}
+
+// coreError rebuilds the error as returned by gocbcore, so that it is translated exactly as the original was.
+func (e *recordedError) coreError() error {
+	inner := e.innerError()
+
+	switch e.Kind {
+	case "query":
+		err := &gocbcore.N1QLError{InnerError: inner, Statement: e.Statement, Endpoint: e.Endpoint,
+			ErrorText: e.ErrorText, HTTPResponseCode: e.StatusCode}
+		for _, desc := range e.Errors {
+			err.Errors = append(err.Errors, gocbcore.N1QLErrorDesc{Code: desc.Code, Message: desc.Message,
+				Retry: desc.Retry, Reason: desc.Reason})
+		}
+		return err
+	case "analytics":
+		err := &gocbcore.AnalyticsError{InnerError: inner, Statement: e.Statement, Endpoint: e.Endpoint,
+			ErrorText: e.ErrorText, HTTPResponseCode: e.StatusCode}
+		for _, desc := range e.Errors {
+			err.Errors = append(err.Errors, gocbcore.AnalyticsErrorDesc{Code: desc.Code, Message: desc.Message})
+		}
+		return err
+	case "search":
+		return &gocbcore.SearchError{InnerError: inner, IndexName: e.IndexName, Endpoint: e.Endpoint,
+			ErrorText: e.ErrorText, HTTPResponseCode: e.StatusCode}
+	case "view":
+		err := &gocbcore.ViewError{InnerError: inner, DesignDocumentName: e.DesignDocumentName, ViewName: e.ViewName,
+			Endpoint: e.Endpoint, ErrorText: e.ErrorText, HTTPResponseCode: e.StatusCode}
+		for _, desc := range e.Errors {
+			err.Errors = append(err.Errors, gocbcore.ViewQueryErrorDesc{Message: desc.Message, SourceNode: desc.SourceNode})
+		}
+		return err
+	case "http":
+		return &gocbcore.HTTPError{InnerError: inner, Endpoint: e.Endpoint}
+	case "timeout":
+		return &gocbcore.TimeoutError{InnerError: inner}
+	default:
+		return inner
+	}
+}
+
+// recordableRowReader is the set of methods shared by the row readers of the query, search, analytics and view
+// services.
+type recordableRowReader interface {
+	NextRow() []byte
+	Err() error
+	MetaData() ([]byte, error)
+	Close() error
+}
+
+// recordingRowReader records the rows read from a reader, writing the recording once the rows are exhausted or the
+// reader is closed.
+type recordingRowReader struct {
+	reader   recordableRowReader
+	recorder *httpRecorder
+	req      *recordingRequest
+	resp     recordedResponse
+	done     bool
+}
+
+func (r *recordingRowReader) NextRow() []byte {
+	row := r.reader.NextRow()
+	if row == nil {
+		r.finish()
+		return nil
+	}
+
+	if !r.done {
+		r.resp.Rows = append(r.resp.Rows, append(json.RawMessage(nil), row...))
+	}
+
+	return row
+}
+
+func (r *recordingRowReader) finish() {
+	if r.done {
+		return
+	}
+	r.done = true
+
+	if err := r.reader.Err(); err != nil {
+		r.resp.RowsError = newRecordedError(err)
+	}
+
+	meta, err := r.reader.MetaData()
+	if err != nil {
+		r.resp.MetaDataError = err.Error()
+	} else {
+		r.resp.MetaData = meta
+	}
+
+	if reader, ok := r.reader.(interface{ PreparedName() (string, error) }); ok {
+		name, err := reader.PreparedName()
+		if err != nil {
+			r.resp.PreparedNameError = err.Error()
+		} else {
+			r.resp.PreparedName = name
+		}
+	}
+
+	r.resp.Endpoint = r.Endpoint()
+	r.recorder.record(r.req, r.resp)
+}
+
+func (r *recordingRowReader) Err() error {
+	return r.reader.Err()
+}
+
+func (r *recordingRowReader) MetaData() ([]byte, error) {
+	return r.reader.MetaData()
+}
+
+func (r *recordingRowReader) Close() error {
+	r.finish()
+	return r.reader.Close()
+}
+
+func (r *recordingRowReader) PreparedName() (string, error) {
+	if reader, ok := r.reader.(interface{ PreparedName() (string, error) }); ok {
+		return reader.PreparedName()
+	}
+
+	return "", errors.New("not a prepared statement")
+}
+
+func (r *recordingRowReader) Endpoint() string {
+	if reader, ok := r.reader.(interface{ Endpoint() string }); ok {
+		return reader.Endpoint()
+	}
+
+	return ""
+}
+
+// replayedRowReader serves the rows of a recorded response.
+type replayedRowReader struct {
+	resp *recordedResponse
+	idx  int
+}
+
+func (r *replayedRowReader) NextRow() []byte {
+	if r.idx == len(r.resp.Rows) {
+		return nil
+	}
+
+	row := r.resp.Rows[r.idx]
+	r.idx++
+
+	return row
+}
+
+func (r *replayedRowReader) Err() error {
+	if r.resp.RowsError != nil {
+		return r.resp.RowsError.coreError()
+	}
+
+	return nil
+}
+
+func (r *replayedRowReader) MetaData() ([]byte, error) {
+	if r.resp.MetaDataError != "" {
+		return nil, errors.New(r.resp.MetaDataError)
+	}
+
+	return r.resp.MetaData, nil
+}
+
+func (r *replayedRowReader) Close() error {
+	return nil
+}
+
+func (r *replayedRowReader) PreparedName() (string, error) {
+	if r.resp.PreparedNameError != "" {
+		return "", errors.New(r.resp.PreparedNameError)
+	}
+
+	return r.resp.PreparedName, nil
+}
+
+func (r *replayedRowReader) Endpoint() string {
+	return r.resp.Endpoint
+}
+
+// rows either replays the response to a request, or performs it using fn and records the response.
+func (r *httpRecorder) rows(service string, request interface{},
+	fn func() (recordableRowReader, error)) (recordableRowReader, error) {
+	req, err := r.begin(service, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.replaying() {
+		resp, err := r.replay(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.Error != nil {
+			return nil, resp.Error.coreError()
+		}
+
+		return &replayedRowReader{resp: resp}, nil
+	}
+
+	reader, err := fn()
+	if err != nil {
+		r.record(req, recordedResponse{Error: newRecordedError(err)})
+		return nil, err
+	}
+
+	return &recordingRowReader{
+		reader:   reader,
+		recorder: r,
+		req:      req,
+	}, nil
+}
+
+type recordedQueryRequest struct {
+	Prepared bool        `json:"prepared,omitempty"`
+	Payload  interface{} `json:"payload"`
+}
+
+type queryProviderRecorder struct {
+	provider queryProviderCoreProvider
+	recorder *httpRecorder
+}
+
+// queryProvider wraps a provider so that its traffic is recorded, or replaces it when replaying.
+func (r *httpRecorder) queryProvider(provider queryProviderCoreProvider) queryProviderCoreProvider {
+	if r == nil {
+		return provider
+	}
+
+	return &queryProviderRecorder{provider: provider, recorder: r}
+}
+
+func (p *queryProviderRecorder) N1QLQuery(ctx context.Context, opts gocbcore.N1QLQueryOptions) (queryRowReader, error) {
+	return p.query(false, opts, func() (queryRowReader, error) {
+		return p.provider.N1QLQuery(ctx, opts)
+	})
+}
+
+func (p *queryProviderRecorder) PreparedN1QLQuery(ctx context.Context, opts gocbcore.N1QLQueryOptions) (queryRowReader, error) {
+	return p.query(true, opts, func() (queryRowReader, error) {
+		return p.provider.PreparedN1QLQuery(ctx, opts)
+	})
+}
+
+func (p *queryProviderRecorder) query(prepared bool, opts gocbcore.N1QLQueryOptions,
+	fn func() (queryRowReader, error)) (queryRowReader, error) {
+	reader, err := p.recorder.rows("query", recordedQueryRequest{
+		Prepared: prepared,
+		Payload:  recordingPayload(opts.Payload, "client_context_id", "timeout"),
+	}, func() (recordableRowReader, error) {
+		return fn()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reader.(queryRowReader), nil
+}
+
+type recordedAnalyticsRequest struct {
+	Priority int         `json:"priority,omitempty"`
+	Payload  interface{} `json:"payload"`
+}
+
+type analyticsProviderRecorder struct {
+	provider analyticsProviderCoreProvider
+	recorder *httpRecorder
+}
+
+// analyticsProvider wraps a provider so that its traffic is recorded, or replaces it when replaying.
+func (r *httpRecorder) analyticsProvider(provider analyticsProviderCoreProvider) analyticsProviderCoreProvider {
+	if r == nil {
+		return provider
+	}
+
+	return &analyticsProviderRecorder{provider: provider, recorder: r}
+}
+
+func (p *analyticsProviderRecorder) AnalyticsQuery(ctx context.Context,
+	opts gocbcore.AnalyticsQueryOptions) (analyticsRowReader, error) {
+	return p.recorder.rows("analytics", recordedAnalyticsRequest{
+		Priority: opts.Priority,
+		Payload:  recordingPayload(opts.Payload, "client_context_id", "timeout"),
+	}, func() (recordableRowReader, error) {
+		return p.provider.AnalyticsQuery(ctx, opts)
+	})
+}
+
+type recordedSearchRequest struct {
+	BucketName string      `json:"bucket_name,omitempty"`
+	ScopeName  string      `json:"scope_name,omitempty"`
+	IndexName  string      `json:"index_name"`
+	Payload    interface{} `json:"payload"`
+}
+
+type searchProviderRecorder struct {
+	provider searchProviderCoreProvider
+	recorder *httpRecorder
+}
+
+// searchProvider wraps a provider so that its traffic is recorded, or replaces it when replaying.
+func (r *httpRecorder) searchProvider(provider searchProviderCoreProvider) searchProviderCoreProvider {
+	if r == nil {
+		return provider
+	}
+
+	return &searchProviderRecorder{provider: provider, recorder: r}
+}
+
+func (p *searchProviderRecorder) SearchQuery(ctx context.Context, opts gocbcore.SearchQueryOptions) (searchRowReader, error) {
+	return p.recorder.rows("search", recordedSearchRequest{
+		BucketName: opts.BucketName,
+		ScopeName:  opts.ScopeName,
+		IndexName:  opts.IndexName,
+		Payload:    recordingPayload(opts.Payload, "ctl.timeout"),
+	}, func() (recordableRowReader, error) {
+		return p.provider.SearchQuery(ctx, opts)
+	})
+}
+
+type recordedViewRequest struct {
+	DesignDocumentName string `json:"design_document_name"`
+	ViewType           string `json:"view_type"`
+	ViewName           string `json:"view_name"`
+	Options            string `json:"options,omitempty"`
+}
+
+type viewProviderRecorder struct {
+	provider viewProviderCoreProvider
+	recorder *httpRecorder
+}
+
+// viewProvider wraps a provider so that its traffic is recorded, or replaces it when replaying.
+func (r *httpRecorder) viewProvider(provider viewProviderCoreProvider) viewProviderCoreProvider {
+	if r == nil {
+		return provider
+	}
+
+	return &viewProviderRecorder{provider: provider, recorder: r}
+}
+
+func (p *viewProviderRecorder) ViewQuery(ctx context.Context, opts gocbcore.ViewQueryOptions) (viewRowReader, error) {
+	return p.recorder.rows("view", recordedViewRequest{
+		DesignDocumentName: opts.DesignDocumentName,
+		ViewType:           opts.ViewType,
+		ViewName:           opts.ViewName,
+		Options:            opts.Options.Encode(),
+	}, func() (recordableRowReader, error) {
+		return p.provider.ViewQuery(ctx, opts)
+	})
+}
+
+type recordedHTTPRequest struct {
+	Service string      `json:"service"`
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Body    interface{} `json:"body,omitempty"`
+}
+
+type httpProviderRecorder struct {
+	provider httpProvider
+	recorder *httpRecorder
+}
+
+// httpProvider wraps a provider so that its traffic is recorded, or replaces it when replaying.
+func (r *httpRecorder) httpProvider(provider httpProvider) httpProvider {
+	if r == nil {
+		return provider
+	}
+
+	return &httpProviderRecorder{provider: provider, recorder: r}
+}
+
+func (p *httpProviderRecorder) DoHTTPRequest(ctx context.Context, req *gocbcore.HTTPRequest) (*gocbcore.HTTPResponse, error) {
+	request := recordedHTTPRequest{
+		Service: serviceTypeToString(ServiceType(req.Service)),
+		Method:  req.Method,
+		Path:    req.Path,
+	}
+	if len(req.Body) > 0 {
+		request.Body = recordingPayload(req.Body)
+	}
+
+	rreq, err := p.recorder.begin("http", request)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.recorder.replaying() {
+		resp, err := p.recorder.replay(rreq)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.Error != nil {
+			return nil, resp.Error.coreError()
+		}
+
+		return &gocbcore.HTTPResponse{
+			Endpoint:      resp.Endpoint,
+			StatusCode:    resp.StatusCode,
+			ContentLength: int64(len(resp.Body)),
+			Body:          io.NopCloser(strings.NewReader(resp.Body)),
+		}, nil
+	}
+
+	resp, err := p.provider.DoHTTPRequest(ctx, req)
+	if err != nil {
+		p.recorder.record(rreq, recordedResponse{Error: newRecordedError(err)})
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if closeErr := resp.Body.Close(); closeErr != nil {
+		logDebugf("Failed to close response body: %v", closeErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p.recorder.record(rreq, recordedResponse{
+		Endpoint:   resp.Endpoint,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+	})
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// replayCapabilityVerifier reports every capability as supported when replaying, leaving it to the recorded
+// responses to report anything that the recorded cluster did not support.
+type replayCapabilityVerifier struct{}
+
+func (v *replayCapabilityVerifier) SearchCapabilityStatus(gocbcore.SearchCapability) gocbcore.CapabilityStatus {
+	return gocbcore.CapabilityStatusSupported
+}
+
+func (v *replayCapabilityVerifier) BucketCapabilityStatus(gocbcore.BucketCapability) gocbcore.CapabilityStatus {
+	return gocbcore.CapabilityStatusSupported
+}
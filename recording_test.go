@@ -0,0 +1,274 @@
+package gocb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v10"
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *UnitTestSuite) recordingQueryCluster(recorder *httpRecorder, provider queryProviderCoreProvider) *Cluster {
+	queryProvider := &queryProviderCore{
+		provider: recorder.queryProvider(provider),
+	}
+
+	cli := new(mockConnectionManager)
+	cli.On("getQueryProvider").Return(queryProvider, nil)
+	cli.On("getMeter").Return(nil)
+	cli.On("MarkOpBeginning").Return()
+	cli.On("MarkOpCompleted").Return()
+
+	cluster := suite.newCluster(cli)
+
+	queryProvider.tracer = newTracerWrapper(&NoopTracer{})
+	queryProvider.retryStrategyWrapper = cluster.retryStrategyWrapper
+	queryProvider.timeouts = cluster.timeoutsConfig
+
+	return cluster
+}
+
+func (suite *UnitTestSuite) replayCluster(dir string) *Cluster {
+	cluster, err := Connect("couchbase://localhost", ClusterOptions{
+		Tracer: &NoopTracer{},
+		Meter:  &NoopMeter{},
+		RecordingConfig: RecordingConfig{
+			Mode:      RecordingModeReplay,
+			Directory: dir,
+		},
+	})
+	suite.Require().NoError(err)
+
+	return cluster
+}
+
+func (suite *UnitTestSuite) TestRecordingReplaysQuery() {
+	var dataset testQueryDataset
+	err := loadJSONTestDataset("beer_sample_query_dataset", &dataset)
+	suite.Require().Nil(err, err)
+
+	dir := suite.T().TempDir()
+	recorder, err := newHTTPRecorder(RecordingConfig{Mode: RecordingModeRecord, Directory: dir})
+	suite.Require().NoError(err)
+
+	reader := &mockQueryRowReader{
+		Dataset: dataset.Results,
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			Meta:  suite.mustConvertToBytes(dataset.jsonQueryResponse),
+			Suite: suite,
+			PName: dataset.jsonQueryResponse.Prepared,
+		},
+	}
+	provider, _ := suite.newMockQueryProvider(false, reader)
+	provider.
+		On("N1QLQuery", nil, mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Return(nil, &gocbcore.N1QLError{
+			InnerError: gocbcore.ErrParsingFailure,
+			Statement:  "SELEC",
+			Errors:     []gocbcore.N1QLErrorDesc{{Code: 3000, Message: "syntax error"}},
+		}).
+		Once()
+
+	cluster := suite.recordingQueryCluster(recorder, provider)
+
+	result, err := cluster.Query("SELECT * FROM dataset", &QueryOptions{Adhoc: true, Timeout: 5 * time.Second})
+	suite.Require().NoError(err)
+	suite.assertQueryBeerResult(dataset, result)
+
+	_, err = cluster.Query("SELEC", &QueryOptions{Adhoc: true})
+	suite.Require().ErrorIs(err, ErrParsingFailure)
+
+	replay := suite.replayCluster(dir)
+	defer replay.Close(nil)
+
+	// Timeouts and client context IDs differ between runs so do not affect which recording is served.
+	result, err = replay.Query("SELECT * FROM dataset", &QueryOptions{Adhoc: true, ClientContextID: "replay"})
+	suite.Require().NoError(err)
+	suite.assertQueryBeerResult(dataset, result)
+
+	// Identical requests beyond those recorded are served the last recording.
+	result, err = replay.Query("SELECT * FROM dataset", &QueryOptions{Adhoc: true})
+	suite.Require().NoError(err)
+	suite.assertQueryBeerResult(dataset, result)
+
+	_, err = replay.Query("SELEC", &QueryOptions{Adhoc: true})
+	suite.Require().ErrorIs(err, ErrParsingFailure)
+	var queryErr *QueryError
+	suite.Require().ErrorAs(err, &queryErr)
+	suite.Assert().Equal("SELEC", queryErr.Statement)
+	suite.Require().Len(queryErr.Errors, 1)
+	suite.Assert().Equal(uint32(3000), queryErr.Errors[0].Code)
+
+	// Prepared statements are recorded separately from adhoc ones.
+	_, err = replay.Query("SELECT * FROM dataset", nil)
+	suite.Assert().ErrorIs(err, ErrRecordingNotFound)
+}
+
+func (suite *UnitTestSuite) TestRecordingReplaysHTTP() {
+	dir := suite.T().TempDir()
+	recorder, err := newHTTPRecorder(RecordingConfig{Mode: RecordingModeRecord, Directory: dir})
+	suite.Require().NoError(err)
+
+	provider := new(mockHttpProvider)
+	provider.
+		On("DoHTTPRequest", mock.Anything, mock.AnythingOfType("*gocbcore.HTTPRequest")).
+		Return(&gocbcore.HTTPResponse{
+			Endpoint:   "http://localhost:8091",
+			StatusCode: http.StatusNotFound,
+			Body:       io.NopCloser(strings.NewReader("Requested resource not found.")),
+		}, nil).
+		Once()
+	provider.
+		On("DoHTTPRequest", mock.Anything, mock.AnythingOfType("*gocbcore.HTTPRequest")).
+		Return(nil, gocbcore.HTTPError{InnerError: gocbcore.ErrServiceNotAvailable}).
+		Once()
+
+	req := &gocbcore.HTTPRequest{
+		Service:  gocbcore.MgmtService,
+		Method:   "GET",
+		Path:     "/pools/default/buckets/missing",
+		UniqueID: "first",
+	}
+
+	resp, err := recorder.httpProvider(provider).DoHTTPRequest(context.Background(), req)
+	suite.Require().NoError(err)
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	suite.Assert().Equal("Requested resource not found.", string(body))
+
+	_, err = recorder.httpProvider(provider).DoHTTPRequest(context.Background(), &gocbcore.HTTPRequest{
+		Service: gocbcore.MgmtService,
+		Method:  "GET",
+		Path:    "/pools/default/buckets",
+	})
+	suite.Require().ErrorIs(err, ErrServiceNotAvailable)
+
+	replay := suite.replayCluster(dir)
+	defer replay.Close(nil)
+
+	_, err = replay.Buckets().GetBucket("missing", nil)
+	suite.Assert().ErrorIs(err, ErrBucketNotFound)
+
+	_, err = replay.Buckets().GetAllBuckets(nil)
+	suite.Assert().ErrorIs(err, ErrServiceNotAvailable)
+
+	_, err = replay.Buckets().GetBucket("other", nil)
+	suite.Assert().ErrorIs(err, ErrRecordingNotFound)
+}
+
+func (suite *UnitTestSuite) TestRecordingRedactsCredentials() {
+	dir := suite.T().TempDir()
+	recorder, err := newHTTPRecorder(RecordingConfig{Mode: RecordingModeRecord, Directory: dir})
+	suite.Require().NoError(err)
+
+	provider := new(mockHttpProvider)
+	provider.
+		On("DoHTTPRequest", mock.Anything, mock.AnythingOfType("*gocbcore.HTTPRequest")).
+		Return(&gocbcore.HTTPResponse{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("")),
+		}, nil)
+
+	upsertUser := func(provider httpProvider, password string) error {
+		_, err := provider.DoHTTPRequest(context.Background(), &gocbcore.HTTPRequest{
+			Service:     gocbcore.MgmtService,
+			Method:      "PUT",
+			Path:        "/settings/rbac/users/local/alice",
+			Body:        []byte("name=Alice&password=" + password + "&roles=admin"),
+			ContentType: "application/x-www-form-urlencoded",
+		})
+		return err
+	}
+	suite.Require().NoError(upsertUser(recorder.httpProvider(provider), "s3cr3t"))
+
+	_, err = recorder.httpProvider(provider).DoHTTPRequest(context.Background(), &gocbcore.HTTPRequest{
+		Service: gocbcore.CbasService,
+		Method:  "POST",
+		Path:    "/analytics/link/scope/link",
+		Body:    []byte(`{"type":"s3","accessKeyId":"id","secretAccessKey":"s3cr3t","nested":[{"Password":"s3cr3t"}]}`),
+	})
+	suite.Require().NoError(err)
+
+	entries, err := os.ReadDir(dir)
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 2)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		suite.Require().NoError(err)
+		suite.Assert().Equal(os.FileMode(0o600), info.Mode().Perm())
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		suite.Require().NoError(err)
+		suite.Assert().NotContains(string(data), "s3cr3t")
+		suite.Assert().Contains(string(data), recordingRedacted)
+	}
+
+	// As credentials are not recorded, requests which differ only by their credentials are served the same recording.
+	replayer, err := newHTTPRecorder(RecordingConfig{Mode: RecordingModeReplay, Directory: dir})
+	suite.Require().NoError(err)
+	suite.Assert().NoError(upsertUser(replayer.httpProvider(nil), "other"))
+}
+
+func (suite *UnitTestSuite) TestRecordingReplayServesKVFromMemory() {
+	replay := suite.replayCluster(suite.T().TempDir())
+	defer replay.Close(nil)
+
+	col := replay.Bucket("default").DefaultCollection()
+	_, err := col.Upsert("doc", "value", nil)
+	suite.Require().NoError(err)
+
+	res, err := col.Get("doc", nil)
+	suite.Require().NoError(err)
+	var value string
+	suite.Require().NoError(res.Content(&value))
+	suite.Assert().Equal("value", value)
+
+	_, err = replay.Diagnostics(nil)
+	suite.Assert().ErrorIs(err, ErrFeatureNotAvailable)
+}
+
+func (suite *UnitTestSuite) TestRecordingConfigValidation() {
+	_, err := newHTTPRecorder(RecordingConfig{Mode: RecordingModeRecord})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	_, err = newHTTPRecorder(RecordingConfig{Mode: RecordingModeReplay, Directory: suite.T().TempDir() + "/missing"})
+	suite.Assert().Error(err)
+
+	recorder, err := newHTTPRecorder(RecordingConfig{})
+	suite.Require().NoError(err)
+	suite.Assert().Nil(recorder)
+
+	_, err = Connect("couchbase2://localhost", ClusterOptions{
+		RecordingConfig: RecordingConfig{Mode: RecordingModeRecord, Directory: suite.T().TempDir()},
+	})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
+
+func (suite *UnitTestSuite) TestRecordingErrorRoundTrip() {
+	wrapped := &gocbcore.SearchError{
+		InnerError:       wrapError(ErrIndexNotFound, "no such index"),
+		IndexName:        "idx",
+		ErrorText:        "index not found",
+		HTTPResponseCode: 400,
+	}
+
+	err := newRecordedError(wrapped).coreError()
+	searchErr, ok := err.(*gocbcore.SearchError)
+	suite.Require().True(ok)
+	suite.Assert().Equal("idx", searchErr.IndexName)
+	suite.Assert().Equal(400, searchErr.HTTPResponseCode)
+	suite.Assert().Equal(wrapped.InnerError.Error(), searchErr.InnerError.Error())
+	suite.Assert().ErrorIs(searchErr, ErrIndexNotFound)
+
+	err = newRecordedError(gocbcore.ErrUnambiguousTimeout).coreError()
+	suite.Assert().Equal(ErrUnambiguousTimeout, err)
+
+	err = newRecordedError(errors.New("unknown")).coreError()
+	suite.Assert().EqualError(err, "unknown")
+}
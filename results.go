@@ -1,6 +1,7 @@
 package gocb
 
 import (
+	"context"
 	"encoding/json"
 	"sync"
 	"sync/atomic"
@@ -27,6 +28,7 @@ type GetResult struct {
 	flags      uint32
 	contents   []byte
 	expiryTime *time.Time
+	replica    bool
 }
 
 // Content assigns the value of the result into the valuePtr using default decoding.
@@ -62,6 +64,12 @@ func (d *GetResult) ExpiryTime() time.Time {
 	return *d.expiryTime
 }
 
+// IsReplica returns whether or not this result came from a replica server, which is only possible when the result
+// was returned from a Get using GetOptions.Hedge.
+func (d *GetResult) IsReplica() bool {
+	return d.replica
+}
+
 func (d *GetResult) fromFullProjection(ops []LookupInSpec, result *LookupInResult, fields []string) error {
 	if len(fields) == 0 {
 		// This is a special case where user specified a full doc fetch with expiration.
@@ -293,6 +301,13 @@ func (lir *LookupInResult) ContentAt(idx uint, valuePtr interface{}) error {
 	return lir.contents[idx].as(valuePtr)
 }
 
+func (lir *LookupInResult) contentErrAt(idx uint) error {
+	if idx >= uint(len(lir.contents)) {
+		return makeInvalidArgumentsError("invalid index")
+	}
+	return lir.contents[idx].err
+}
+
 // Exists verifies that the item at idx exists.
 func (lir *LookupInResult) Exists(idx uint) bool {
 	if idx >= uint(len(lir.contents)) {
@@ -426,6 +441,9 @@ type ScanResult struct {
 	numItems uint64
 
 	peeked unsafe.Pointer
+
+	ctx      context.Context
+	progress *scanProgressTracker
 }
 
 func (sr *ScanResult) setErr(err error) {
@@ -439,6 +457,7 @@ func (sr *ScanResult) Next() *ScanResultItem {
 	peeked := atomic.SwapPointer(&sr.peeked, nil)
 	if peeked != nil {
 		atomic.AddUint64(&sr.numItems, 1)
+		sr.progress.itemConsumed((*ScanResultItem)(peeked))
 		return (*ScanResultItem)(peeked)
 	}
 
@@ -452,6 +471,7 @@ func (sr *ScanResult) Next() *ScanResultItem {
 	// we need to cancel the streams
 	numItems := atomic.AddUint64(&sr.numItems, 1)
 	if sr.limit == 0 || numItems <= sr.limit {
+		sr.progress.itemConsumed(item)
 		return item
 	}
 
@@ -483,6 +503,19 @@ func (sr *ScanResult) Close() error {
 	return nil
 }
 
+// ResumeToken returns a token recording the items returned by Next so far, which can be used with
+// ScanOptions.ResumeFrom to continue the scan after them. Sampling scans cannot be resumed and return nil.
+// UNCOMMITTED: This API may change in the future.
+func (sr *ScanResult) ResumeToken() *ScanResumeToken {
+	return sr.progress.resumeToken()
+}
+
+// Progress returns how far the scan has progressed.
+// UNCOMMITTED: This API may change in the future.
+func (sr *ScanResult) Progress() ScanProgress {
+	return sr.progress.progress()
+}
+
 // ScanResultItem represents an item that is returning on the stream from a Scan operation.
 type ScanResultItem struct {
 	Result
@@ -492,6 +525,7 @@ type ScanResultItem struct {
 	contents   []byte
 	expiryTime time.Time
 	keysOnly   bool
+	vbID       uint16
 }
 
 // IDOnly returns whether the scan generating this item was made with IDsOnly set.
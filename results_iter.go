@@ -0,0 +1,186 @@
+//go:build go1.23
+
+package gocb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"iter"
+)
+
+func translateIterCtxErr(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return wrapError(ErrTimeout, "operation context deadline exceeded whilst iterating results")
+	}
+
+	return wrapError(ErrRequestCanceled, "operation context canceled whilst iterating results")
+}
+
+// resultSeq builds an iterator over a streaming result. The stream is always closed once iteration stops, whether
+// that is because the stream is exhausted, the consumer stopped early, or the operation context was canceled.
+// Errors returned by next are yielded to the consumer, which can choose whether to continue.
+func resultSeq[T any](ctx context.Context, next func() (T, bool, error), closeFn func() error) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for {
+			if ctx != nil {
+				if err := ctx.Err(); err != nil {
+					if closeErr := closeFn(); closeErr != nil {
+						logDebugf("Failed to close result after context cancellation: %s", closeErr)
+					}
+					yield(zero, translateIterCtxErr(err))
+					return
+				}
+			}
+
+			row, ok, err := next()
+			if !ok {
+				break
+			}
+
+			if !yield(row, err) {
+				if closeErr := closeFn(); closeErr != nil {
+					logDebugf("Failed to close result after iteration stopped early: %s", closeErr)
+				}
+				return
+			}
+		}
+
+		if err := closeFn(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+func (r *QueryResult) nextRaw() (json.RawMessage, bool, error) {
+	if !r.Next() {
+		return nil, false, nil
+	}
+
+	return r.rowBytes, true, nil
+}
+
+// All returns an iterator over the remaining rows of the result as raw JSON. The result is closed when iteration
+// completes, including when the loop is exited early. Any error from the stream, or cancellation of the context
+// that the query was executed with, is yielded as the final element.
+// UNCOMMITTED: This API may change in the future.
+func (r *QueryResult) All() iter.Seq2[json.RawMessage, error] {
+	return resultSeq(r.ctx, r.nextRaw, r.Close)
+}
+
+// QueryRowsSeq returns an iterator over the remaining rows of a QueryResult, decoding each into a value of type T.
+// It follows the same semantics as QueryResult.All, rows which cannot be decoded yield a DecodingError.
+// UNCOMMITTED: This API may change in the future.
+func QueryRowsSeq[T any](res *QueryResult) iter.Seq2[T, error] {
+	return resultSeq(res.ctx, func() (T, bool, error) {
+		if !res.Next() {
+			var zero T
+			return zero, false, nil
+		}
+
+		row, err := decodeAs[T](res.Row)
+		return row, true, err
+	}, res.Close)
+}
+
+func (r *AnalyticsResult) nextRaw() (json.RawMessage, bool, error) {
+	if !r.Next() {
+		return nil, false, nil
+	}
+
+	return r.rowBytes, true, nil
+}
+
+// All returns an iterator over the remaining rows of the result as raw JSON. The result is closed when iteration
+// completes, including when the loop is exited early. Any error from the stream, or cancellation of the context
+// that the query was executed with, is yielded as the final element.
+// UNCOMMITTED: This API may change in the future.
+func (r *AnalyticsResult) All() iter.Seq2[json.RawMessage, error] {
+	return resultSeq(r.ctx, r.nextRaw, r.Close)
+}
+
+// AnalyticsRowsSeq returns an iterator over the remaining rows of an AnalyticsResult, decoding each into a value of
+// type T. It follows the same semantics as AnalyticsResult.All, rows which cannot be decoded yield a DecodingError.
+// UNCOMMITTED: This API may change in the future.
+func AnalyticsRowsSeq[T any](res *AnalyticsResult) iter.Seq2[T, error] {
+	return resultSeq(res.ctx, func() (T, bool, error) {
+		if !res.Next() {
+			var zero T
+			return zero, false, nil
+		}
+
+		row, err := decodeAs[T](res.Row)
+		return row, true, err
+	}, res.Close)
+}
+
+// All returns an iterator over the remaining rows of the result. The result is closed when iteration completes,
+// including when the loop is exited early. Any error from the stream, or cancellation of the context that the
+// search was executed with, is yielded as the final element.
+// UNCOMMITTED: This API may change in the future.
+func (r *SearchResult) All() iter.Seq2[SearchRow, error] {
+	return resultSeq(r.ctx, func() (SearchRow, bool, error) {
+		if !r.Next() {
+			return SearchRow{}, false, nil
+		}
+
+		return r.Row(), true, nil
+	}, func() error {
+		if err := r.Close(); err != nil {
+			return err
+		}
+
+		return r.jsonErr
+	})
+}
+
+// All returns an iterator over the remaining rows of the result. The result is closed when iteration completes,
+// including when the loop is exited early. Any error from the stream, or cancellation of the context that the
+// view query was executed with, is yielded as the final element.
+// UNCOMMITTED: This API may change in the future.
+func (r *ViewResult) All() iter.Seq2[ViewRow, error] {
+	return resultSeq(r.ctx, func() (ViewRow, bool, error) {
+		if !r.Next() {
+			return ViewRow{}, false, nil
+		}
+
+		return r.Row(), true, nil
+	}, func() error {
+		if err := r.Close(); err != nil {
+			return err
+		}
+
+		return r.jsonErr
+	})
+}
+
+// All returns an iterator over the remaining items of the scan. The scan is canceled when the loop is exited early
+// and any error from the underlying streams, or cancellation of the context that the scan was started with, is
+// yielded as the final element.
+// UNCOMMITTED: This API may change in the future.
+func (sr *ScanResult) All() iter.Seq2[*ScanResultItem, error] {
+	return resultSeq(sr.ctx, func() (*ScanResultItem, bool, error) {
+		item := sr.Next()
+		if item == nil {
+			return nil, false, nil
+		}
+
+		return item, true, nil
+	}, sr.Close)
+}
+
+// All returns an iterator over the events of the stream. The stream is closed when the loop is exited and the error
+// which caused the stream to fail, or cancellation of the context that the stream was opened with, is yielded as the
+// final element.
+// UNCOMMITTED: This API may change in the future.
+func (s *ChangeStream) All() iter.Seq2[*ChangeEvent, error] {
+	return resultSeq(s.parentCtx, func() (*ChangeEvent, bool, error) {
+		evt := s.Next()
+		if evt == nil {
+			return nil, false, nil
+		}
+
+		return evt, true, nil
+	}, s.Close)
+}
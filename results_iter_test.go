@@ -0,0 +1,219 @@
+//go:build go1.23
+
+package gocb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+type closeTrackingQueryRowReader struct {
+	*mockQueryRowReader
+	closed int
+}
+
+func (r *closeTrackingQueryRowReader) Close() error {
+	r.closed++
+	return r.mockQueryRowReader.Close()
+}
+
+func (suite *UnitTestSuite) TestQueryResultAll() {
+	var dataset testQueryDataset
+	err := loadJSONTestDataset("beer_sample_query_dataset", &dataset)
+	suite.Require().Nil(err, err)
+
+	reader := &closeTrackingQueryRowReader{
+		mockQueryRowReader: &mockQueryRowReader{
+			Dataset: dataset.Results,
+			mockQueryRowReaderBase: mockQueryRowReaderBase{
+				Suite: suite,
+			},
+		},
+	}
+
+	var rows []testBreweryDocument
+	for row, err := range newQueryResult(reader).All() {
+		suite.Require().Nil(err, err)
+
+		var doc testBreweryDocument
+		suite.Require().Nil(json.Unmarshal(row, &doc))
+		rows = append(rows, doc)
+	}
+
+	suite.Assert().Equal(dataset.Results, rows)
+	suite.Assert().Equal(1, reader.closed)
+}
+
+func (suite *UnitTestSuite) TestQueryResultAllBreakCloses() {
+	var dataset testQueryDataset
+	err := loadJSONTestDataset("beer_sample_query_dataset", &dataset)
+	suite.Require().Nil(err, err)
+	suite.Require().Greater(len(dataset.Results), 2)
+
+	reader := &closeTrackingQueryRowReader{
+		mockQueryRowReader: &mockQueryRowReader{
+			Dataset: dataset.Results,
+			mockQueryRowReaderBase: mockQueryRowReaderBase{
+				Suite: suite,
+			},
+		},
+	}
+
+	var count int
+	for _, err := range newQueryResult(reader).All() {
+		suite.Require().Nil(err, err)
+		count++
+		break
+	}
+
+	suite.Assert().Equal(1, count)
+	suite.Assert().Equal(1, reader.closed)
+	// The first row is read when the result is created and the second is buffered by Next.
+	suite.Assert().Equal(2, reader.idx)
+}
+
+func (suite *UnitTestSuite) TestQueryResultAllStreamError() {
+	reader := &mockQueryRowReader{
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			CloseErr: ErrInternalServerFailure,
+			Suite:    suite,
+		},
+	}
+
+	var errs []error
+	for _, err := range newQueryResult(reader).All() {
+		errs = append(errs, err)
+	}
+
+	suite.Require().Len(errs, 1)
+	suite.Assert().ErrorIs(errs[0], ErrInternalServerFailure)
+}
+
+func (suite *UnitTestSuite) TestQueryResultAllContextCanceled() {
+	var dataset testQueryDataset
+	err := loadJSONTestDataset("beer_sample_query_dataset", &dataset)
+	suite.Require().Nil(err, err)
+
+	reader := &closeTrackingQueryRowReader{
+		mockQueryRowReader: &mockQueryRowReader{
+			Dataset: dataset.Results,
+			mockQueryRowReaderBase: mockQueryRowReaderBase{
+				Suite: suite,
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	res := newQueryResult(reader)
+	res.ctx = ctx
+
+	var count int
+	var lastErr error
+	for _, err := range res.All() {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		count++
+		cancel()
+	}
+
+	suite.Assert().Equal(1, count)
+	suite.Assert().ErrorIs(lastErr, ErrRequestCanceled)
+	suite.Assert().Equal(1, reader.closed)
+}
+
+func (suite *UnitTestSuite) TestQueryRowsSeq() {
+	var dataset testQueryDataset
+	err := loadJSONTestDataset("beer_sample_query_dataset", &dataset)
+	suite.Require().Nil(err, err)
+
+	reader := &mockQueryRowReader{
+		Dataset: dataset.Results,
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			Suite: suite,
+		},
+	}
+
+	var rows []testBreweryDocument
+	for row, err := range QueryRowsSeq[testBreweryDocument](newQueryResult(reader)) {
+		suite.Require().Nil(err, err)
+		rows = append(rows, row)
+	}
+
+	suite.Assert().Equal(dataset.Results, rows)
+}
+
+func (suite *UnitTestSuite) TestAnalyticsRowsSeqDecodeFailure() {
+	var dataset testQueryDataset
+	err := loadJSONTestDataset("beer_sample_query_dataset", &dataset)
+	suite.Require().Nil(err, err)
+
+	reader := &mockAnalyticsRowReader{
+		Dataset: dataset.Results,
+		Suite:   suite,
+	}
+
+	var decodeErrs int
+	for _, err := range AnalyticsRowsSeq[int](newAnalyticsResult(reader)) {
+		suite.Require().ErrorIs(err, ErrDecodingFailure)
+		decodeErrs++
+	}
+
+	suite.Assert().Equal(len(dataset.Results), decodeErrs)
+}
+
+func (suite *UnitTestSuite) TestSearchResultAll() {
+	var dataset testSearchDataset
+	err := loadJSONTestDataset("beer_sample_search_dataset", &dataset)
+	suite.Require().Nil(err, err)
+
+	reader := &mockSearchRowReader{
+		Dataset: dataset.Hits,
+		Suite:   suite,
+	}
+
+	var ids []string
+	for row, err := range newSearchResult(reader).All() {
+		suite.Require().Nil(err, err)
+		ids = append(ids, row.ID)
+	}
+
+	suite.Require().Len(ids, len(dataset.Hits))
+	for i, hit := range dataset.Hits {
+		suite.Assert().Equal(hit.ID, ids[i])
+	}
+}
+
+func (suite *UnitTestSuite) TestScanResultAll() {
+	resultCh := make(chan *ScanResultItem, 3)
+	resultCh <- &ScanResultItem{id: "key1"}
+	resultCh <- &ScanResultItem{id: "key2"}
+	resultCh <- &ScanResultItem{id: "key3"}
+	close(resultCh)
+
+	var cancelErr error
+	var cancels int
+	res := &ScanResult{
+		resultChan: resultCh,
+	}
+	res.cancelFn = func(err error) {
+		cancels++
+		cancelErr = err
+	}
+
+	var ids []string
+	for item, err := range res.All() {
+		suite.Require().Nil(err, err)
+		ids = append(ids, item.ID())
+		if len(ids) == 2 {
+			break
+		}
+	}
+
+	suite.Assert().Equal([]string{"key1", "key2"}, ids)
+	suite.Assert().Equal(1, cancels)
+	suite.Assert().True(errors.Is(cancelErr, ErrRequestCanceled))
+}
@@ -0,0 +1,155 @@
+package gocb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// DecodingError is returned by the typed result accessors when the content of a result could not be decoded
+// into the requested type.
+// UNCOMMITTED: This API may change in the future.
+type DecodingError struct {
+	InnerError error
+	TargetType string
+}
+
+// Error returns the string representation of this error.
+func (e DecodingError) Error() string {
+	return fmt.Sprintf("failed to decode content into %s: %s", e.TargetType, e.InnerError.Error())
+}
+
+// Unwrap returns the underlying cause for this error.
+func (e DecodingError) Unwrap() error {
+	return e.InnerError
+}
+
+// Is reports whether the target error is ErrDecodingFailure, this allows a decoding error to be identified without
+// knowing which transcoder produced it.
+func (e DecodingError) Is(target error) bool {
+	return target == ErrDecodingFailure
+}
+
+type contentResult interface {
+	Content(valuePtr interface{}) error
+}
+
+type contentAtResult interface {
+	ContentAt(idx uint, valuePtr interface{}) error
+}
+
+type contentErrAtResult interface {
+	contentErrAt(idx uint) error
+}
+
+type rowResult interface {
+	Next() bool
+	Row(valuePtr interface{}) error
+	Close() error
+}
+
+func decodeAs[T any](decodeFn func(valuePtr interface{}) error) (T, error) {
+	var value T
+	if err := decodeFn(&value); err != nil {
+		var zero T
+		// These errors are about the state of the result rather than its content so there's nothing to
+		// translate.
+		if errors.Is(err, ErrNoResult) || errors.Is(err, ErrInvalidArgument) {
+			return zero, err
+		}
+
+		return zero, DecodingError{
+			InnerError: err,
+			TargetType: reflect.TypeOf(&value).Elem().String(),
+		}
+	}
+
+	return value, nil
+}
+
+// ContentAs decodes the content of a result into a new value of type T using the transcoder that the result was
+// created with. It can be used with any result exposing a Content method, such as GetResult, GetReplicaResult,
+// ScanResultItem and TransactionGetResult.
+// UNCOMMITTED: This API may change in the future.
+func ContentAs[T any](res contentResult) (T, error) {
+	return decodeAs[T](res.Content)
+}
+
+// ContentAtAs decodes the value of the subdocument operation at idx into a new value of type T. It can be used
+// with LookupInResult, LookupInReplicaResult and MutateInResult.
+// UNCOMMITTED: This API may change in the future.
+func ContentAtAs[T any](res contentAtResult, idx uint) (T, error) {
+	if errRes, ok := res.(contentErrAtResult); ok {
+		if err := errRes.contentErrAt(idx); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+
+	return decodeAs[T](func(valuePtr interface{}) error {
+		return res.ContentAt(idx, valuePtr)
+	})
+}
+
+// GetAs performs a Get against the collection and decodes the content of the document into a new value of type T.
+// The GetResult is also returned so that the Cas and expiry of the document remain available.
+// UNCOMMITTED: This API may change in the future.
+func GetAs[T any](c *Collection, id string, opts *GetOptions) (T, *GetResult, error) {
+	res, err := c.Get(id, opts)
+	if err != nil {
+		var zero T
+		return zero, nil, err
+	}
+
+	value, err := ContentAs[T](res)
+	if err != nil {
+		var zero T
+		return zero, res, err
+	}
+
+	return value, res, nil
+}
+
+func rowsAs[T any](res rowResult) ([]T, error) {
+	var rows []T
+	for res.Next() {
+		row, err := decodeAs[T](res.Row)
+		if err != nil {
+			closeErr := res.Close()
+			if closeErr != nil {
+				logDebugf("Failed to close result after decoding failure: %s", closeErr)
+			}
+			return nil, err
+		}
+
+		rows = append(rows, row)
+	}
+
+	if err := res.Close(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// QueryRows reads all remaining rows from a QueryResult, decoding each into a value of type T, and then closes the
+// result. As all rows are held in memory this should only be used for result sets of a known, bounded, size.
+// UNCOMMITTED: This API may change in the future.
+func QueryRows[T any](res *QueryResult) ([]T, error) {
+	return rowsAs[T](res)
+}
+
+// AnalyticsRows reads all remaining rows from an AnalyticsResult, decoding each into a value of type T, and then
+// closes the result. As all rows are held in memory this should only be used for result sets of a known, bounded,
+// size.
+// UNCOMMITTED: This API may change in the future.
+func AnalyticsRows[T any](res *AnalyticsResult) ([]T, error) {
+	return rowsAs[T](res)
+}
+
+// QueryOneAs decodes the first row from a QueryResult into a new value of type T. It has the same semantics as
+// QueryResult.One.
+// UNCOMMITTED: This API may change in the future.
+func QueryOneAs[T any](res *QueryResult) (T, error) {
+	return decodeAs[T](res.One)
+}
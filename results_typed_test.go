@@ -0,0 +1,211 @@
+package gocb
+
+import (
+	"errors"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v10"
+	"github.com/couchbase/gocbcore/v10/memd"
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *UnitTestSuite) TestContentAsGetResult() {
+	dataset, err := loadRawTestDataset("beer_sample_single")
+	suite.Require().Nil(err, err)
+
+	res := &GetResult{
+		contents:   dataset,
+		transcoder: NewJSONTranscoder(),
+		flags:      gocbcore.EncodeCommonFlags(gocbcore.JSONType, gocbcore.NoCompression),
+	}
+
+	var expected testBeerDocument
+	err = res.Content(&expected)
+	suite.Require().Nil(err, err)
+
+	doc, err := ContentAs[testBeerDocument](res)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal(expected, doc)
+
+	_, err = ContentAs[int](res)
+	suite.Require().NotNil(err)
+	suite.Assert().ErrorIs(err, ErrDecodingFailure)
+
+	var decodeErr DecodingError
+	suite.Require().True(errors.As(err, &decodeErr))
+	suite.Assert().Equal("int", decodeErr.TargetType)
+}
+
+func (suite *UnitTestSuite) TestContentAsTranscoderMismatch() {
+	res := &GetResult{
+		contents:   []byte("hello"),
+		transcoder: NewRawStringTranscoder(),
+		flags:      gocbcore.EncodeCommonFlags(gocbcore.StringType, gocbcore.NoCompression),
+	}
+
+	val, err := ContentAs[string](res)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal("hello", val)
+
+	_, err = ContentAs[[]byte](res)
+	suite.Assert().ErrorIs(err, ErrDecodingFailure)
+}
+
+func (suite *UnitTestSuite) TestContentAsScanResultItem() {
+	item := &ScanResultItem{
+		transcoder: NewJSONTranscoder(),
+		contents:   []byte(`{"name":"barry"}`),
+		flags:      gocbcore.EncodeCommonFlags(gocbcore.JSONType, gocbcore.NoCompression),
+		expiryTime: time.Time{},
+	}
+
+	val, err := ContentAs[map[string]string](item)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal(map[string]string{"name": "barry"}, val)
+
+	item.keysOnly = true
+	_, err = ContentAs[map[string]string](item)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+	suite.Assert().NotErrorIs(err, ErrDecodingFailure)
+}
+
+func (suite *UnitTestSuite) TestContentAtAs() {
+	res := &LookupInResult{
+		contents: []lookupInPartial{
+			{data: []byte(`"barry"`), op: memd.SubDocOpGet},
+			{data: []byte(`42`), op: memd.SubDocOpGet},
+			{err: ErrPathNotFound, op: memd.SubDocOpGet},
+		},
+	}
+
+	name, err := ContentAtAs[string](res, 0)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal("barry", name)
+
+	age, err := ContentAtAs[int](res, 1)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal(42, age)
+
+	_, err = ContentAtAs[int](res, 0)
+	suite.Assert().ErrorIs(err, ErrDecodingFailure)
+
+	_, err = ContentAtAs[int](res, 2)
+	suite.Assert().ErrorIs(err, ErrPathNotFound)
+	suite.Assert().NotErrorIs(err, ErrDecodingFailure)
+
+	_, err = ContentAtAs[int](res, 3)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	replicaRes := &LookupInReplicaResult{LookupInResult: res}
+	_, err = ContentAtAs[int](replicaRes, 2)
+	suite.Assert().ErrorIs(err, ErrPathNotFound)
+
+	mutRes := MutateInResult{
+		contents: []mutateInPartial{
+			{data: []byte(`10`)},
+		},
+	}
+	count, err := ContentAtAs[uint64](mutRes, 0)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal(uint64(10), count)
+}
+
+func (suite *UnitTestSuite) TestQueryRows() {
+	var dataset testQueryDataset
+	err := loadJSONTestDataset("beer_sample_query_dataset", &dataset)
+	suite.Require().Nil(err, err)
+
+	reader := &mockQueryRowReader{
+		Dataset: dataset.Results,
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			Meta:  suite.mustConvertToBytes(dataset.jsonQueryResponse),
+			Suite: suite,
+		},
+	}
+
+	rows, err := QueryRows[testBreweryDocument](newQueryResult(reader))
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal(dataset.Results, rows)
+}
+
+func (suite *UnitTestSuite) TestQueryRowsDecodeFailure() {
+	var dataset testQueryDataset
+	err := loadJSONTestDataset("beer_sample_query_dataset", &dataset)
+	suite.Require().Nil(err, err)
+
+	reader := &mockQueryRowReader{
+		Dataset: dataset.Results,
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			Suite: suite,
+		},
+	}
+
+	rows, err := QueryRows[string](newQueryResult(reader))
+	suite.Assert().ErrorIs(err, ErrDecodingFailure)
+	suite.Assert().Nil(rows)
+}
+
+func (suite *UnitTestSuite) TestQueryRowsStreamError() {
+	reader := &mockQueryRowReader{
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			CloseErr: ErrTimeout,
+			Suite:    suite,
+		},
+	}
+
+	_, err := QueryRows[testBreweryDocument](newQueryResult(reader))
+	suite.Assert().ErrorIs(err, ErrTimeout)
+}
+
+func (suite *UnitTestSuite) TestQueryOneAs() {
+	var dataset testQueryDataset
+	err := loadJSONTestDataset("beer_sample_query_dataset", &dataset)
+	suite.Require().Nil(err, err)
+
+	reader := &mockQueryRowReader{
+		Dataset: dataset.Results,
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			Suite: suite,
+		},
+	}
+
+	row, err := QueryOneAs[testBreweryDocument](newQueryResult(reader))
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal(dataset.Results[0], row)
+
+	emptyReader := &mockQueryRowReader{
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			Suite: suite,
+		},
+	}
+	_, err = QueryOneAs[testBreweryDocument](newQueryResult(emptyReader))
+	suite.Assert().ErrorIs(err, ErrNoResult)
+}
+
+func (suite *UnitTestSuite) TestGetAs() {
+	res := &GetResult{
+		Result:     Result{cas: 1234},
+		contents:   []byte(`{"name":"barry"}`),
+		transcoder: NewJSONTranscoder(),
+		flags:      gocbcore.EncodeCommonFlags(gocbcore.JSONType, gocbcore.NoCompression),
+	}
+
+	provider := new(mockKvProvider)
+	provider.On("Get", mock.AnythingOfType("*gocb.Collection"), "mykey", mock.AnythingOfType("*gocb.GetOptions")).
+		Return(res, nil).Once()
+	provider.On("Get", mock.AnythingOfType("*gocb.Collection"), "missing", mock.AnythingOfType("*gocb.GetOptions")).
+		Return(nil, ErrDocumentNotFound).Once()
+
+	col := suite.collection("mock", "", "", provider)
+
+	val, getRes, err := GetAs[map[string]string](col, "mykey", nil)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal(map[string]string{"name": "barry"}, val)
+	suite.Assert().Equal(Cas(1234), getRes.Cas())
+
+	_, getRes, err = GetAs[map[string]string](col, "missing", nil)
+	suite.Assert().ErrorIs(err, ErrDocumentNotFound)
+	suite.Assert().Nil(getRes)
+
+	provider.AssertExpectations(suite.T())
+}
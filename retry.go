@@ -216,6 +216,7 @@ type retriableRequestPs struct {
 	idempotent       bool
 	strategy         RetryStrategy
 	parentSpan       RequestSpan
+	deadline         time.Time
 }
 
 func newRetriableRequestPS(operation string, idempotent bool, parentSpan RequestSpan, traceIdentifier string,
@@ -254,6 +255,14 @@ func (w *retriableRequestPs) Operation() string {
 	return w.operation
 }
 
+func (w *retriableRequestPs) Deadline() time.Time {
+	return w.deadline
+}
+
+func (w *retriableRequestPs) RequestSpan() RequestSpan {
+	return w.parentSpan
+}
+
 func (w *retriableRequestPs) retryStrategy() RetryStrategy {
 	return w.strategy
 }
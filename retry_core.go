@@ -1,6 +1,10 @@
 package gocb
 
-import "github.com/couchbase/gocbcore/v10"
+import (
+	"time"
+
+	"github.com/couchbase/gocbcore/v10"
+)
 
 func translateCoreRetryReasons(reasons []gocbcore.RetryReason) []RetryReason {
 	var reasonsOut []RetryReason
@@ -18,7 +22,9 @@ func translateCoreRetryReasons(reasons []gocbcore.RetryReason) []RetryReason {
 }
 
 type wrappedCoreRetryRequest struct {
-	req gocbcore.RetryRequest
+	req      gocbcore.RetryRequest
+	deadline time.Time
+	span     RequestSpan
 }
 
 func (req *wrappedCoreRetryRequest) RetryAttempts() uint32 {
@@ -37,6 +43,14 @@ func (req *wrappedCoreRetryRequest) RetryReasons() []RetryReason {
 	return translateCoreRetryReasons(req.req.RetryReasons())
 }
 
+func (req *wrappedCoreRetryRequest) Deadline() time.Time {
+	return req.deadline
+}
+
+func (req *wrappedCoreRetryRequest) RequestSpan() RequestSpan {
+	return req.span
+}
+
 func newCoreRetryStrategyWrapper(strategy RetryStrategy) *coreRetryStrategyWrapper {
 	return &coreRetryStrategyWrapper{
 		wrapped: strategy,
@@ -45,12 +59,34 @@ func newCoreRetryStrategyWrapper(strategy RetryStrategy) *coreRetryStrategyWrapp
 
 type coreRetryStrategyWrapper struct {
 	wrapped RetryStrategy
+
+	deadline time.Time
+	span     RequestSpan
+}
+
+// forOperation returns a copy of the wrapper bound to the deadline and span of a single operation, these are only
+// needed by strategies which are operation aware so the wrapper is returned as is for any other strategy.
+func (rs *coreRetryStrategyWrapper) forOperation(deadline time.Time, span RequestSpan) *coreRetryStrategyWrapper {
+	if rs == nil {
+		return nil
+	}
+	if !IsOperationAwareRetryStrategy(rs.wrapped) {
+		return rs
+	}
+
+	return &coreRetryStrategyWrapper{
+		wrapped:  rs.wrapped,
+		deadline: deadline,
+		span:     span,
+	}
 }
 
 // RetryAfter calculates and returns a RetryAction describing how long to wait before retrying an operation.
 func (rs *coreRetryStrategyWrapper) RetryAfter(req gocbcore.RetryRequest, reason gocbcore.RetryReason) gocbcore.RetryAction {
 	wreq := &wrappedCoreRetryRequest{
-		req: req,
+		req:      req,
+		deadline: rs.deadline,
+		span:     rs.span,
 	}
 	wrappedAction := rs.wrapped.RetryAfter(wreq, RetryReason(reason))
 	return gocbcore.RetryAction(wrappedAction)
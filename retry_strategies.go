@@ -0,0 +1,323 @@
+package gocb
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	spanEventRetryScheduled = "retry_scheduled"
+	spanEventRetryRejected  = "retry_rejected"
+
+	// decorrelatedJitterMaxSteps bounds the simulated backoff chain, beyond this the distribution no longer changes.
+	decorrelatedJitterMaxSteps = 64
+)
+
+// DeadlineRetryRequest is implemented by retry requests which know the deadline of the operation being retried.
+// A zero deadline indicates that the deadline is not known.
+// UNCOMMITTED: This API may change in the future.
+type DeadlineRetryRequest interface {
+	Deadline() time.Time
+}
+
+// TracedRetryRequest is implemented by retry requests which expose the span of the operation being retried.
+// UNCOMMITTED: This API may change in the future.
+type TracedRetryRequest interface {
+	RequestSpan() RequestSpan
+}
+
+// OperationAwareRetryStrategy is implemented by strategies which need the requests passed to them to implement
+// DeadlineRetryRequest and TracedRetryRequest, requests passed to any other strategy may not know the deadline or
+// span of the operation. Strategies which wrap another strategy should forward OperationAware to it, for example
+// by returning IsOperationAwareRetryStrategy(wrapped).
+// UNCOMMITTED: This API may change in the future.
+type OperationAwareRetryStrategy interface {
+	RetryStrategy
+	OperationAware() bool
+}
+
+// IsOperationAwareRetryStrategy returns whether strategy is an OperationAwareRetryStrategy which needs to know the
+// deadline and span of operations.
+// UNCOMMITTED: This API may change in the future.
+func IsOperationAwareRetryStrategy(strategy RetryStrategy) bool {
+	aware, ok := strategy.(OperationAwareRetryStrategy)
+	return ok && aware.OperationAware()
+}
+
+func retryRequestDeadline(req RetryRequest) time.Time {
+	if dreq, ok := req.(DeadlineRetryRequest); ok {
+		return dreq.Deadline()
+	}
+
+	return time.Time{}
+}
+
+// traceRetryDecision records the decision made by a strategy as an event on the span of the operation, if known.
+func traceRetryDecision(req RetryRequest, strategy string, reason RetryReason, action RetryAction) {
+	treq, ok := req.(TracedRetryRequest)
+	if !ok {
+		return
+	}
+	span := treq.RequestSpan()
+	if span == nil {
+		return
+	}
+
+	var event string
+	if action != nil && action.Duration() > 0 {
+		event = fmt.Sprintf("%s strategy=%s reason=%s attempt=%d backoff=%s", spanEventRetryScheduled, strategy,
+			reason.Description(), req.RetryAttempts()+1, action.Duration())
+	} else {
+		event = fmt.Sprintf("%s strategy=%s reason=%s attempt=%d", spanEventRetryRejected, strategy,
+			reason.Description(), req.RetryAttempts()+1)
+	}
+	span.AddEvent(event, time.Now())
+}
+
+func randomDuration(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+
+	return min + time.Duration(rand.Int63n(int64(max-min))) // #nosec G404
+}
+
+// FullJitterBackoff returns a BackoffCalculator which picks a random duration between zero and an exponentially
+// increasing ceiling, capped at max.
+// UNCOMMITTED: This API may change in the future.
+func FullJitterBackoff(base, max time.Duration, factor float64) BackoffCalculator {
+	return func(retryAttempts uint32) time.Duration {
+		ceiling := float64(base) * math.Pow(factor, float64(retryAttempts))
+		if ceiling > float64(max) || math.IsInf(ceiling, 0) {
+			ceiling = float64(max)
+		}
+
+		// Never return 0 as that would indicate not to retry.
+		return randomDuration(1, time.Duration(ceiling))
+	}
+}
+
+// DecorrelatedJitterBackoff returns a BackoffCalculator where each duration is picked at random between base and
+// three times the previous duration, capped at max.
+// UNCOMMITTED: This API may change in the future.
+func DecorrelatedJitterBackoff(base, max time.Duration) BackoffCalculator {
+	return func(retryAttempts uint32) time.Duration {
+		// The previous duration isn't known so the chain is simulated, which gives the same distribution for the
+		// given attempt.
+		steps := retryAttempts
+		if steps > decorrelatedJitterMaxSteps {
+			steps = decorrelatedJitterMaxSteps
+		}
+
+		backoff := base
+		for i := uint32(0); i <= steps; i++ {
+			backoff = randomDuration(base, 3*backoff)
+			if backoff > max {
+				backoff = max
+			}
+		}
+
+		return backoff
+	}
+}
+
+// JitterRetryStrategy is a strategy which retries until the operation times out, waiting a randomised duration
+// between attempts so that many clients do not retry in lockstep.
+// UNCOMMITTED: This API may change in the future.
+type JitterRetryStrategy struct {
+	name       string
+	calculator BackoffCalculator
+}
+
+// NewFullJitterRetryStrategy returns a JitterRetryStrategy using FullJitterBackoff with a factor of 2.
+// UNCOMMITTED: This API may change in the future.
+func NewFullJitterRetryStrategy(base, max time.Duration) *JitterRetryStrategy {
+	return &JitterRetryStrategy{
+		name:       "full_jitter",
+		calculator: FullJitterBackoff(base, max, 2),
+	}
+}
+
+// NewDecorrelatedJitterRetryStrategy returns a JitterRetryStrategy using DecorrelatedJitterBackoff.
+// UNCOMMITTED: This API may change in the future.
+func NewDecorrelatedJitterRetryStrategy(base, max time.Duration) *JitterRetryStrategy {
+	return &JitterRetryStrategy{
+		name:       "decorrelated_jitter",
+		calculator: DecorrelatedJitterBackoff(base, max),
+	}
+}
+
+// RetryAfter calculates and returns a RetryAction describing how long to wait before retrying an operation.
+func (rs *JitterRetryStrategy) RetryAfter(req RetryRequest, reason RetryReason) RetryAction {
+	var action RetryAction = &NoRetryRetryAction{}
+	if req.Idempotent() || reason.AllowsNonIdempotentRetry() {
+		action = &WithDurationRetryAction{WithDuration: rs.calculator(req.RetryAttempts())}
+	}
+
+	traceRetryDecision(req, rs.name, reason, action)
+	return action
+}
+
+// OperationAware returns true, the decisions of the strategy are recorded on the span of the operation.
+func (rs *JitterRetryStrategy) OperationAware() bool {
+	return true
+}
+
+// MaxAttemptsRetryStrategy is a strategy which stops retrying once an operation has been retried a number of times,
+// deferring to the wrapped strategy otherwise.
+// UNCOMMITTED: This API may change in the future.
+type MaxAttemptsRetryStrategy struct {
+	maxAttempts uint32
+	wrapped     RetryStrategy
+}
+
+// NewMaxAttemptsRetryStrategy returns a MaxAttemptsRetryStrategy. If wrapped is nil then a BestEffortRetryStrategy
+// is used.
+// UNCOMMITTED: This API may change in the future.
+func NewMaxAttemptsRetryStrategy(maxAttempts uint32, wrapped RetryStrategy) *MaxAttemptsRetryStrategy {
+	if wrapped == nil {
+		wrapped = NewBestEffortRetryStrategy(nil)
+	}
+
+	return &MaxAttemptsRetryStrategy{
+		maxAttempts: maxAttempts,
+		wrapped:     wrapped,
+	}
+}
+
+// RetryAfter calculates and returns a RetryAction describing how long to wait before retrying an operation.
+func (rs *MaxAttemptsRetryStrategy) RetryAfter(req RetryRequest, reason RetryReason) RetryAction {
+	if req.RetryAttempts() >= rs.maxAttempts {
+		action := &NoRetryRetryAction{}
+		traceRetryDecision(req, "max_attempts", reason, action)
+		return action
+	}
+
+	return rs.wrapped.RetryAfter(req, reason)
+}
+
+// OperationAware returns true, the decisions of the strategy are recorded on the span of the operation.
+func (rs *MaxAttemptsRetryStrategy) OperationAware() bool {
+	return true
+}
+
+// RetryPolicy controls how operations failing for a specific RetryReason are retried.
+// UNCOMMITTED: This API may change in the future.
+type RetryPolicy struct {
+	// Never disables retries for the reason.
+	Never bool
+
+	// NeverNonIdempotent disables retries for the reason when the operation is not idempotent, such as most writes.
+	NeverNonIdempotent bool
+
+	// MaxAttempts stops retrying once the operation has been retried this many times. 0 means no limit.
+	MaxAttempts uint32
+
+	// Backoff overrides the backoff of the wrapped strategy for the reason.
+	Backoff BackoffCalculator
+}
+
+// ReasonPolicyRetryStrategy is a strategy which applies a RetryPolicy depending on the RetryReason, deferring to the
+// wrapped strategy for reasons without a policy. Reasons for which AlwaysRetry is true are always retried by the SDK
+// and never reach a retry strategy.
+// UNCOMMITTED: This API may change in the future.
+type ReasonPolicyRetryStrategy struct {
+	policies map[RetryReason]RetryPolicy
+	wrapped  RetryStrategy
+}
+
+// NewReasonPolicyRetryStrategy returns a ReasonPolicyRetryStrategy. If wrapped is nil then a BestEffortRetryStrategy
+// is used.
+// UNCOMMITTED: This API may change in the future.
+func NewReasonPolicyRetryStrategy(policies map[RetryReason]RetryPolicy, wrapped RetryStrategy) *ReasonPolicyRetryStrategy {
+	if wrapped == nil {
+		wrapped = NewBestEffortRetryStrategy(nil)
+	}
+
+	copied := make(map[RetryReason]RetryPolicy, len(policies))
+	for reason, policy := range policies {
+		copied[reason] = policy
+	}
+
+	return &ReasonPolicyRetryStrategy{
+		policies: copied,
+		wrapped:  wrapped,
+	}
+}
+
+// RetryAfter calculates and returns a RetryAction describing how long to wait before retrying an operation.
+func (rs *ReasonPolicyRetryStrategy) RetryAfter(req RetryRequest, reason RetryReason) RetryAction {
+	policy, ok := rs.policies[reason]
+	if !ok {
+		return rs.wrapped.RetryAfter(req, reason)
+	}
+
+	if policy.Never || (policy.NeverNonIdempotent && !req.Idempotent()) ||
+		(policy.MaxAttempts > 0 && req.RetryAttempts() >= policy.MaxAttempts) {
+		action := &NoRetryRetryAction{}
+		traceRetryDecision(req, "reason_policy", reason, action)
+		return action
+	}
+
+	if policy.Backoff == nil {
+		return rs.wrapped.RetryAfter(req, reason)
+	}
+
+	var action RetryAction = &NoRetryRetryAction{}
+	if req.Idempotent() || reason.AllowsNonIdempotentRetry() {
+		action = &WithDurationRetryAction{WithDuration: policy.Backoff(req.RetryAttempts())}
+	}
+
+	traceRetryDecision(req, "reason_policy", reason, action)
+	return action
+}
+
+// OperationAware returns true, the decisions of the strategy are recorded on the span of the operation.
+func (rs *ReasonPolicyRetryStrategy) OperationAware() bool {
+	return true
+}
+
+// DeadlineAwareRetryStrategy is a strategy which refuses any retry that would not be attempted before the deadline
+// of the operation, so that the operation fails with the underlying error rather than a timeout. Retry durations
+// are otherwise provided by the wrapped strategy.
+// UNCOMMITTED: This API may change in the future.
+type DeadlineAwareRetryStrategy struct {
+	wrapped RetryStrategy
+}
+
+// NewDeadlineAwareRetryStrategy returns a DeadlineAwareRetryStrategy. If wrapped is nil then a
+// BestEffortRetryStrategy is used.
+// UNCOMMITTED: This API may change in the future.
+func NewDeadlineAwareRetryStrategy(wrapped RetryStrategy) *DeadlineAwareRetryStrategy {
+	if wrapped == nil {
+		wrapped = NewBestEffortRetryStrategy(nil)
+	}
+
+	return &DeadlineAwareRetryStrategy{
+		wrapped: wrapped,
+	}
+}
+
+// RetryAfter calculates and returns a RetryAction describing how long to wait before retrying an operation.
+func (rs *DeadlineAwareRetryStrategy) RetryAfter(req RetryRequest, reason RetryReason) RetryAction {
+	action := rs.wrapped.RetryAfter(req, reason)
+	if action == nil || action.Duration() == 0 {
+		return action
+	}
+
+	deadline := retryRequestDeadline(req)
+	if deadline.IsZero() || time.Now().Add(action.Duration()).Before(deadline) {
+		return action
+	}
+
+	action = &NoRetryRetryAction{}
+	traceRetryDecision(req, "deadline_aware", reason, action)
+	return action
+}
+
+// OperationAware returns true, the decisions of the strategy are recorded on the span of the operation.
+func (rs *DeadlineAwareRetryStrategy) OperationAware() bool {
+	return true
+}
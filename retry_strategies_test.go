@@ -0,0 +1,320 @@
+package gocb
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocbcore/v10"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockOperationRetryRequest struct {
+	mockRetryRequest
+	deadline time.Time
+	span     RequestSpan
+}
+
+func (mgr *mockOperationRetryRequest) Deadline() time.Time {
+	return mgr.deadline
+}
+
+func (mgr *mockOperationRetryRequest) RequestSpan() RequestSpan {
+	return mgr.span
+}
+
+// forwardingRetryStrategy is a user defined strategy wrapping another strategy.
+type forwardingRetryStrategy struct {
+	wrapped RetryStrategy
+}
+
+func (rs *forwardingRetryStrategy) RetryAfter(req RetryRequest, reason RetryReason) RetryAction {
+	return rs.wrapped.RetryAfter(req, reason)
+}
+
+func (rs *forwardingRetryStrategy) OperationAware() bool {
+	return IsOperationAwareRetryStrategy(rs.wrapped)
+}
+
+func (suite *UnitTestSuite) TestFullJitterBackoff() {
+	calc := FullJitterBackoff(10*time.Millisecond, 100*time.Millisecond, 2)
+	for attempt := uint32(0); attempt < 20; attempt++ {
+		ceiling := 10 * time.Millisecond * time.Duration(uint64(1)<<attempt)
+		if ceiling > 100*time.Millisecond {
+			ceiling = 100 * time.Millisecond
+		}
+		for i := 0; i < 50; i++ {
+			backoff := calc(attempt)
+			suite.Require().Greater(backoff, time.Duration(0))
+			suite.Require().LessOrEqual(backoff, ceiling)
+		}
+	}
+
+	suite.Assert().LessOrEqual(calc(1000), 100*time.Millisecond)
+}
+
+func (suite *UnitTestSuite) TestDecorrelatedJitterBackoff() {
+	calc := DecorrelatedJitterBackoff(10*time.Millisecond, 100*time.Millisecond)
+	for attempt := uint32(0); attempt < 100; attempt++ {
+		backoff := calc(attempt)
+		suite.Require().GreaterOrEqual(backoff, 10*time.Millisecond)
+		suite.Require().LessOrEqual(backoff, 100*time.Millisecond)
+	}
+
+	first := calc(0)
+	suite.Assert().Less(first, 30*time.Millisecond)
+}
+
+func (suite *UnitTestSuite) TestJitterRetryStrategy() {
+	span := newTestSpan("get", nil)
+	strategy := NewFullJitterRetryStrategy(time.Millisecond, 10*time.Millisecond)
+
+	action := strategy.RetryAfter(&mockOperationRetryRequest{
+		mockRetryRequest: mockRetryRequest{idempotent: true},
+		span:             span,
+	}, KVTemporaryFailureRetryReason)
+	suite.Assert().Greater(action.Duration(), time.Duration(0))
+
+	action = strategy.RetryAfter(&mockOperationRetryRequest{span: span}, UnknownRetryReason)
+	suite.Assert().Equal(time.Duration(0), action.Duration())
+
+	suite.Require().Len(span.Events, 2)
+	suite.Assert().True(strings.HasPrefix(span.Events[0],
+		"retry_scheduled strategy=full_jitter reason=KV_TEMPORARY_FAILURE attempt=1 backoff="))
+	suite.Assert().Equal("retry_rejected strategy=full_jitter reason=UNKNOWN attempt=1",
+		span.Events[1])
+
+	// Requests which do not expose a span are still retried.
+	action = NewDecorrelatedJitterRetryStrategy(time.Millisecond, 10*time.Millisecond).
+		RetryAfter(&mockRetryRequest{idempotent: true}, KVTemporaryFailureRetryReason)
+	suite.Assert().Greater(action.Duration(), time.Duration(0))
+}
+
+func (suite *UnitTestSuite) TestMaxAttemptsRetryStrategy() {
+	span := newTestSpan("get", nil)
+	strategy := NewMaxAttemptsRetryStrategy(2, NewBestEffortRetryStrategy(mockBackoffCalculator))
+
+	action := strategy.RetryAfter(&mockOperationRetryRequest{
+		mockRetryRequest: mockRetryRequest{attempts: 1, idempotent: true},
+		span:             span,
+	}, KVTemporaryFailureRetryReason)
+	suite.Assert().Equal(time.Millisecond, action.Duration())
+
+	action = strategy.RetryAfter(&mockOperationRetryRequest{
+		mockRetryRequest: mockRetryRequest{attempts: 2, idempotent: true},
+		span:             span,
+	}, KVTemporaryFailureRetryReason)
+	suite.Assert().Equal(time.Duration(0), action.Duration())
+
+	suite.Require().Len(span.Events, 1)
+	suite.Assert().Equal("retry_rejected strategy=max_attempts reason=KV_TEMPORARY_FAILURE attempt=3", span.Events[0])
+}
+
+func (suite *UnitTestSuite) TestReasonPolicyRetryStrategy() {
+	strategy := NewReasonPolicyRetryStrategy(map[RetryReason]RetryPolicy{
+		KVLockedRetryReason:           {NeverNonIdempotent: true},
+		KVTemporaryFailureRetryReason: {MaxAttempts: 3, Backoff: func(uint32) time.Duration { return time.Second }},
+		KVSyncWriteInProgressRetryReason: {
+			Never: true,
+		},
+	}, NewBestEffortRetryStrategy(mockBackoffCalculator))
+
+	// Reads of a locked document are retried, writes are not.
+	action := strategy.RetryAfter(&mockRetryRequest{attempts: 1, idempotent: true}, KVLockedRetryReason)
+	suite.Assert().Equal(time.Millisecond, action.Duration())
+	action = strategy.RetryAfter(&mockRetryRequest{attempts: 1}, KVLockedRetryReason)
+	suite.Assert().Equal(time.Duration(0), action.Duration())
+
+	action = strategy.RetryAfter(&mockRetryRequest{attempts: 2}, KVTemporaryFailureRetryReason)
+	suite.Assert().Equal(time.Second, action.Duration())
+	action = strategy.RetryAfter(&mockRetryRequest{attempts: 3}, KVTemporaryFailureRetryReason)
+	suite.Assert().Equal(time.Duration(0), action.Duration())
+
+	action = strategy.RetryAfter(&mockRetryRequest{attempts: 1, idempotent: true}, KVSyncWriteInProgressRetryReason)
+	suite.Assert().Equal(time.Duration(0), action.Duration())
+
+	// Reasons without a policy fall through to the wrapped strategy.
+	action = strategy.RetryAfter(&mockRetryRequest{attempts: 4}, KVNotMyVBucketRetryReason)
+	suite.Assert().Equal(4*time.Millisecond, action.Duration())
+}
+
+func (suite *UnitTestSuite) TestDeadlineAwareRetryStrategy() {
+	span := newTestSpan("get", nil)
+	strategy := NewDeadlineAwareRetryStrategy(NewBestEffortRetryStrategy(func(uint32) time.Duration {
+		return 50 * time.Millisecond
+	}))
+
+	action := strategy.RetryAfter(&mockOperationRetryRequest{
+		mockRetryRequest: mockRetryRequest{idempotent: true},
+		deadline:         time.Now().Add(time.Second),
+		span:             span,
+	}, KVTemporaryFailureRetryReason)
+	suite.Assert().Equal(50*time.Millisecond, action.Duration())
+
+	action = strategy.RetryAfter(&mockOperationRetryRequest{
+		mockRetryRequest: mockRetryRequest{idempotent: true},
+		deadline:         time.Now().Add(10 * time.Millisecond),
+		span:             span,
+	}, KVTemporaryFailureRetryReason)
+	suite.Assert().Equal(time.Duration(0), action.Duration())
+
+	suite.Require().Len(span.Events, 1)
+	suite.Assert().Equal("retry_rejected strategy=deadline_aware reason=KV_TEMPORARY_FAILURE attempt=1", span.Events[0])
+
+	// Without a known deadline the wrapped strategy decides.
+	action = strategy.RetryAfter(&mockRetryRequest{idempotent: true}, KVTemporaryFailureRetryReason)
+	suite.Assert().Equal(50*time.Millisecond, action.Duration())
+}
+
+func (suite *UnitTestSuite) TestRetryStrategiesCompose() {
+	strategy := NewDeadlineAwareRetryStrategy(
+		NewMaxAttemptsRetryStrategy(5,
+			NewReasonPolicyRetryStrategy(map[RetryReason]RetryPolicy{
+				KVLockedRetryReason: {NeverNonIdempotent: true},
+			}, NewFullJitterRetryStrategy(time.Millisecond, 5*time.Millisecond))))
+
+	deadline := time.Now().Add(time.Second)
+	action := strategy.RetryAfter(&mockOperationRetryRequest{
+		mockRetryRequest: mockRetryRequest{attempts: 1, idempotent: true},
+		deadline:         deadline,
+	}, KVLockedRetryReason)
+	suite.Assert().Greater(action.Duration(), time.Duration(0))
+
+	action = strategy.RetryAfter(&mockOperationRetryRequest{
+		mockRetryRequest: mockRetryRequest{attempts: 1},
+		deadline:         deadline,
+	}, KVLockedRetryReason)
+	suite.Assert().Equal(time.Duration(0), action.Duration())
+
+	action = strategy.RetryAfter(&mockOperationRetryRequest{
+		mockRetryRequest: mockRetryRequest{attempts: 5, idempotent: true},
+		deadline:         deadline,
+	}, KVTemporaryFailureRetryReason)
+	suite.Assert().Equal(time.Duration(0), action.Duration())
+}
+
+func (suite *UnitTestSuite) TestRetryWrapperForOperation() {
+	span := newTestSpan("get", nil)
+	deadline := time.Now().Add(10 * time.Millisecond)
+
+	// Strategies which don't need the operation are shared rather than copied.
+	bestEffort := newCoreRetryStrategyWrapper(NewBestEffortRetryStrategy(nil))
+	suite.Assert().Same(bestEffort, bestEffort.forOperation(deadline, span))
+
+	var nilWrapper *coreRetryStrategyWrapper
+	suite.Assert().Nil(nilWrapper.forOperation(deadline, span))
+
+	wrapper := newCoreRetryStrategyWrapper(NewDeadlineAwareRetryStrategy(NewBestEffortRetryStrategy(
+		func(uint32) time.Duration {
+			return 50 * time.Millisecond
+		})))
+	bound := wrapper.forOperation(deadline, span)
+	suite.Require().NotSame(wrapper, bound)
+	suite.Assert().True(wrapper.deadline.IsZero())
+
+	action := bound.RetryAfter(&mockGocbcoreRequest{idempotent: true}, gocbcore.KVTemporaryFailureRetryReason)
+	suite.Assert().Equal(time.Duration(0), action.Duration())
+	suite.Assert().Len(span.Events, 1)
+
+	action = wrapper.RetryAfter(&mockGocbcoreRequest{idempotent: true}, gocbcore.KVTemporaryFailureRetryReason)
+	suite.Assert().Equal(50*time.Millisecond, action.Duration())
+}
+
+func (suite *UnitTestSuite) TestRetryWrapperForOperationForwarded() {
+	span := newTestSpan("get", nil)
+	deadline := time.Now().Add(10 * time.Millisecond)
+
+	unaware := newCoreRetryStrategyWrapper(&forwardingRetryStrategy{wrapped: NewBestEffortRetryStrategy(nil)})
+	suite.Assert().Same(unaware, unaware.forOperation(deadline, span))
+
+	// A strategy wrapping a built in strategy is bound to the operation when it forwards OperationAware.
+	wrapper := newCoreRetryStrategyWrapper(&forwardingRetryStrategy{
+		wrapped: NewDeadlineAwareRetryStrategy(NewBestEffortRetryStrategy(func(uint32) time.Duration {
+			return 50 * time.Millisecond
+		})),
+	})
+	bound := wrapper.forOperation(deadline, span)
+	suite.Require().NotSame(wrapper, bound)
+
+	action := bound.RetryAfter(&mockGocbcoreRequest{idempotent: true}, gocbcore.KVTemporaryFailureRetryReason)
+	suite.Assert().Equal(time.Duration(0), action.Duration())
+	suite.Assert().Len(span.Events, 1)
+}
+
+func (suite *UnitTestSuite) TestRetryWrapperForOperationMgmt() {
+	var sent *gocbcore.HTTPRequest
+	provider := new(mockHttpProvider)
+	provider.
+		On("DoHTTPRequest", mock.Anything, mock.AnythingOfType("*gocbcore.HTTPRequest")).
+		Run(func(args mock.Arguments) {
+			sent = args.Get(1).(*gocbcore.HTTPRequest)
+		}).
+		Return(&gocbcore.HTTPResponse{
+			StatusCode: 200,
+			Body:       io.NopCloser(strings.NewReader("{}")),
+		}, nil)
+
+	mgmt := &mgmtProviderCore{
+		provider:             provider,
+		mgmtTimeout:          time.Second,
+		retryStrategyWrapper: newCoreRetryStrategyWrapper(NewBestEffortRetryStrategy(nil)),
+	}
+
+	span := newTestSpan("manager_buckets_get_bucket", nil)
+	resp, err := mgmt.executeMgmtRequest(nil, mgmtRequest{
+		Service:       ServiceTypeManagement,
+		Method:        "GET",
+		Path:          "/pools/default/buckets/default",
+		RetryStrategy: NewDeadlineAwareRetryStrategy(nil),
+		parentSpan:    span,
+	})
+	suite.Require().NoError(err)
+	ensureBodyClosed(resp.Body)
+
+	suite.Require().NotNil(sent)
+	wrapper, ok := sent.RetryStrategy.(*coreRetryStrategyWrapper)
+	suite.Require().True(ok)
+	suite.Assert().Equal(sent.Deadline, wrapper.deadline)
+	suite.Assert().Equal(span, wrapper.span)
+	suite.Assert().Equal(span.Context(), sent.TraceContext)
+}
+
+func (suite *UnitTestSuite) TestRetryWrapperForOperationBulk() {
+	var strategies []gocbcore.RetryStrategy
+	provider := new(mockKvProviderCoreProvider)
+	provider.
+		On("Get", mock.AnythingOfType("gocbcore.GetOptions"), mock.AnythingOfType("gocbcore.GetCallback")).
+		Run(func(args mock.Arguments) {
+			strategies = append(strategies, args.Get(0).(gocbcore.GetOptions).RetryStrategy)
+			cb := args.Get(1).(gocbcore.GetCallback)
+			go cb(&gocbcore.GetResult{Value: []byte(`1`)}, nil)
+		}).
+		Return(nil, nil)
+
+	bulkProvider := &kvBulkProviderCore{
+		agent:  provider,
+		tracer: newTracerWrapper(&NoopTracer{}),
+		meter:  newMeterWrapper(&NoopMeter{}),
+	}
+	cluster, col := suite.fakeCollection()
+	defer cluster.Close(nil)
+
+	ops := []BulkOp{&GetOp{ID: "a"}, &GetOp{ID: "b"}}
+	suite.Require().Nil(bulkProvider.Do(col, ops, &BulkOpOptions{
+		Timeout: 10 * time.Millisecond,
+		RetryStrategy: NewDeadlineAwareRetryStrategy(NewBestEffortRetryStrategy(func(uint32) time.Duration {
+			return 50 * time.Millisecond
+		})),
+	}))
+
+	// Each op is bound to its own deadline, so retries which would not happen before it are refused.
+	suite.Require().Len(strategies, 2)
+	for _, strategy := range strategies {
+		wrapper, ok := strategy.(*coreRetryStrategyWrapper)
+		suite.Require().True(ok)
+		suite.Assert().False(wrapper.deadline.IsZero())
+
+		action := wrapper.RetryAfter(&mockGocbcoreRequest{idempotent: true}, gocbcore.KVTemporaryFailureRetryReason)
+		suite.Assert().Equal(time.Duration(0), action.Duration())
+	}
+}
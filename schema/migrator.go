@@ -0,0 +1,434 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// backend reads the state of, and makes changes to, the cluster.
+type backend interface {
+	getAllBuckets() (map[string]gocb.BucketSettings, error)
+	getAllScopes(bucket string) ([]gocb.ScopeSpec, error)
+	getAllQueryIndexes(ks keyspace) ([]gocb.QueryIndex, error)
+	getAllSearchIndexes(bucket, scope string) ([]gocb.SearchIndex, error)
+
+	createBucket(settings gocb.CreateBucketSettings) error
+	updateBucket(settings gocb.BucketSettings) error
+	createScope(bucket, scope string) error
+	createCollection(ks keyspace, settings gocb.CreateCollectionSettings) error
+	updateCollection(ks keyspace, settings gocb.UpdateCollectionSettings) error
+	createQueryIndex(ks keyspace, index IndexSpec) error
+	buildQueryIndexes(ks keyspace, names []string) error
+	upsertSearchIndex(bucket, scope string, index gocb.SearchIndex) error
+}
+
+// MigratorOptions are the options available when creating a Migrator.
+// UNCOMMITTED: This API may change in the future.
+type MigratorOptions struct {
+	// MetadataCollection is the collection holding the document which records the versions of the spec that have
+	// been applied. Defaults to the default collection of the first bucket in the spec.
+	MetadataCollection *gocb.Collection
+
+	// MetadataDocumentID is the ID of the metadata document. Defaults to _schema.
+	MetadataDocumentID string
+
+	// Timeout is the timeout of each management operation, defaults to the management timeout of the cluster.
+	Timeout time.Duration
+
+	// BucketReadyTimeout is how long to wait for a newly created bucket to become ready. Defaults to 1 minute.
+	BucketReadyTimeout time.Duration
+
+	// IndexBuildTimeout is how long to wait for built query indexes to come online. Defaults to 10 minutes.
+	IndexBuildTimeout time.Duration
+}
+
+// Metadata is the content of the metadata document.
+// UNCOMMITTED: This API may change in the future.
+type Metadata struct {
+	// Version is the version of the spec that was last applied.
+	Version   string           `json:"version"`
+	AppliedAt time.Time        `json:"appliedAt"`
+	History   []AppliedVersion `json:"history"`
+}
+
+// AppliedVersion records a single application of a spec.
+// UNCOMMITTED: This API may change in the future.
+type AppliedVersion struct {
+	Version   string    `json:"version"`
+	AppliedAt time.Time `json:"appliedAt"`
+
+	// Changes describes each of the changes that were made.
+	Changes []string `json:"changes,omitempty"`
+}
+
+// Migrator plans and applies specs against a cluster.
+// UNCOMMITTED: This API may change in the future.
+type Migrator struct {
+	cluster *gocb.Cluster
+	backend backend
+
+	metadataCollection *gocb.Collection
+	metadataID         string
+}
+
+// NewMigrator creates a Migrator for a cluster.
+// UNCOMMITTED: This API may change in the future.
+func NewMigrator(cluster *gocb.Cluster, opts *MigratorOptions) *Migrator {
+	if opts == nil {
+		opts = &MigratorOptions{}
+	}
+
+	backend := &clusterBackend{
+		cluster:            cluster,
+		timeout:            opts.Timeout,
+		bucketReadyTimeout: opts.BucketReadyTimeout,
+		indexBuildTimeout:  opts.IndexBuildTimeout,
+	}
+	if backend.bucketReadyTimeout == 0 {
+		backend.bucketReadyTimeout = time.Minute
+	}
+	if backend.indexBuildTimeout == 0 {
+		backend.indexBuildTimeout = 10 * time.Minute
+	}
+
+	return newMigrator(cluster, backend, opts)
+}
+
+func newMigrator(cluster *gocb.Cluster, backend backend, opts *MigratorOptions) *Migrator {
+	m := &Migrator{
+		cluster:            cluster,
+		backend:            backend,
+		metadataCollection: opts.MetadataCollection,
+		metadataID:         opts.MetadataDocumentID,
+	}
+	if m.metadataID == "" {
+		m.metadataID = "_schema"
+	}
+
+	return m
+}
+
+// Plan compares the spec against the cluster and returns the changes which Apply would make to bring the cluster to
+// the state of the spec. An error wrapping gocb.ErrInvalidArgument is returned if the spec is invalid, or if the
+// cluster differs from the spec in a way that cannot be changed without dropping something, such as the type of a
+// bucket or the keys of a query index.
+// UNCOMMITTED: This API may change in the future.
+func (m *Migrator) Plan(spec *Spec) (*Plan, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	state, err := m.readState(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := diff(spec, state)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Version: spec.Version,
+		Changes: changes,
+	}
+
+	plan.metadata = m.metadataCollection
+	if plan.metadata == nil && len(spec.Buckets) > 0 && spec.Buckets[0].Type != "memcached" {
+		plan.metadata = m.cluster.Bucket(spec.Buckets[0].Name).DefaultCollection()
+	}
+
+	// A metadata document can only exist in a bucket which already exists, and fetching from a bucket which does not
+	// would wait for it until timing out.
+	if plan.metadata != nil {
+		if _, ok := state.buckets[plan.metadata.Bucket().Name()]; ok || m.metadataCollection != nil {
+			metadata, err := m.readMetadata(plan.metadata)
+			if err != nil {
+				return nil, err
+			}
+			plan.AppliedVersion = metadata.Version
+		}
+	}
+
+	return plan, nil
+}
+
+// Apply makes the changes of a plan, in order, and then records the version of the spec in the metadata document.
+// Should a change fail then Apply stops and returns the error, the changes which were made before it are left in place
+// and the version is not recorded. Planning and applying the spec again continues from where it stopped.
+// UNCOMMITTED: This API may change in the future.
+func (m *Migrator) Apply(plan *Plan) error {
+	applied := make([]string, 0, len(plan.Changes))
+	for _, change := range plan.Changes {
+		if err := m.applyChange(change); err != nil {
+			return fmt.Errorf("failed to %s: %w", change, err)
+		}
+		applied = append(applied, change.String())
+	}
+
+	if plan.metadata == nil {
+		return nil
+	}
+
+	// Reapplying a version which made no changes is not worth a new history entry.
+	if len(applied) == 0 && plan.AppliedVersion == plan.Version {
+		return nil
+	}
+
+	return m.recordVersion(plan.metadata, AppliedVersion{
+		Version:   plan.Version,
+		AppliedAt: time.Now().UTC(),
+		Changes:   applied,
+	})
+}
+
+// Migrate plans the spec and applies the plan.
+// UNCOMMITTED: This API may change in the future.
+func (m *Migrator) Migrate(spec *Spec) (*Plan, error) {
+	plan, err := m.Plan(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, m.Apply(plan)
+}
+
+func (m *Migrator) applyChange(change Change) error {
+	ks := keyspace{bucket: change.Bucket, scope: change.Scope, collection: change.Collection}
+	switch {
+	case change.createBucket != nil:
+		return m.backend.createBucket(*change.createBucket)
+	case change.updateBucket != nil:
+		return m.backend.updateBucket(*change.updateBucket)
+	case change.Kind == ResourceScope:
+		return m.backend.createScope(change.Bucket, change.Scope)
+	case change.createCollection != nil:
+		return m.backend.createCollection(ks, *change.createCollection)
+	case change.updateCollection != nil:
+		return m.backend.updateCollection(ks, *change.updateCollection)
+	case change.queryIndex != nil:
+		return m.backend.createQueryIndex(ks, *change.queryIndex)
+	case change.Kind == ResourceQueryIndex && change.Action == ActionBuild:
+		return m.backend.buildQueryIndexes(ks, change.Name)
+	case change.searchIndex != nil:
+		return m.backend.upsertSearchIndex(change.Bucket, change.Scope, *change.searchIndex)
+	default:
+		return fmt.Errorf("unsupported change")
+	}
+}
+
+func (m *Migrator) readState(spec *Spec) (*liveState, error) {
+	state := newLiveState()
+
+	buckets, err := m.backend.getAllBuckets()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, bucketSpec := range spec.Buckets {
+		settings, ok := buckets[bucketSpec.Name]
+		if !ok {
+			continue
+		}
+		state.buckets[bucketSpec.Name] = settings
+		if settings.BucketType == gocb.MemcachedBucketType {
+			continue
+		}
+
+		scopes, err := m.backend.getAllScopes(bucketSpec.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, scope := range scopes {
+			state.scopes[scopeKey{bucket: bucketSpec.Name, scope: scope.Name}] = struct{}{}
+			for _, collection := range scope.Collections {
+				state.collections[keyspace{bucket: bucketSpec.Name, scope: scope.Name, collection: collection.Name}] =
+					collection
+			}
+		}
+
+		for _, scopeSpec := range bucketSpec.Scopes {
+			if !state.hasScope(bucketSpec.Name, scopeSpec.Name) {
+				continue
+			}
+
+			for _, collectionSpec := range scopeSpec.Collections {
+				ks := keyspace{bucket: bucketSpec.Name, scope: scopeSpec.Name, collection: collectionSpec.Name}
+				if _, ok := state.collections[ks]; !ok || len(collectionSpec.Indexes) == 0 {
+					continue
+				}
+
+				indexes, err := m.backend.getAllQueryIndexes(ks)
+				if err != nil {
+					return nil, err
+				}
+				state.queryIndexes[ks] = indexes
+			}
+
+			if len(scopeSpec.SearchIndexes) > 0 {
+				indexes, err := m.backend.getAllSearchIndexes(bucketSpec.Name, scopeSpec.Name)
+				if err != nil {
+					return nil, err
+				}
+				state.searchIndexes[scopeKey{bucket: bucketSpec.Name, scope: scopeSpec.Name}] = indexes
+			}
+		}
+	}
+
+	return state, nil
+}
+
+func (m *Migrator) readMetadata(collection *gocb.Collection) (*Metadata, error) {
+	res, err := collection.Get(m.metadataID, nil)
+	if err != nil {
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			return &Metadata{}, nil
+		}
+		return nil, err
+	}
+
+	var metadata Metadata
+	if err := res.Content(&metadata); err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
+func (m *Migrator) recordVersion(collection *gocb.Collection, version AppliedVersion) error {
+	_, err := collection.Update(m.metadataID, func(current *gocb.GetResult) (interface{}, error) {
+		var metadata Metadata
+		if current != nil {
+			if err := current.Content(&metadata); err != nil {
+				return nil, err
+			}
+		}
+
+		metadata.Version = version.Version
+		metadata.AppliedAt = version.AppliedAt
+		metadata.History = append(metadata.History, version)
+		return metadata, nil
+	}, &gocb.UpdateOptions{
+		InsertIfMissing: true,
+	})
+
+	return err
+}
+
+// Metadata returns the metadata document from the given collection, or the default collection of the first bucket in
+// the spec if no MetadataCollection was set in the options. An empty Metadata is returned if nothing has been applied.
+// UNCOMMITTED: This API may change in the future.
+func (m *Migrator) Metadata(spec *Spec) (*Metadata, error) {
+	collection := m.metadataCollection
+	if collection == nil {
+		if len(spec.Buckets) == 0 {
+			return nil, invalidSpec("spec has no buckets to hold the metadata document")
+		}
+		collection = m.cluster.Bucket(spec.Buckets[0].Name).DefaultCollection()
+	}
+
+	return m.readMetadata(collection)
+}
+
+// clusterBackend is the backend which uses the management APIs of the cluster.
+type clusterBackend struct {
+	cluster            *gocb.Cluster
+	timeout            time.Duration
+	bucketReadyTimeout time.Duration
+	indexBuildTimeout  time.Duration
+}
+
+func (b *clusterBackend) getAllBuckets() (map[string]gocb.BucketSettings, error) {
+	return b.cluster.Buckets().GetAllBuckets(&gocb.GetAllBucketsOptions{Timeout: b.timeout})
+}
+
+func (b *clusterBackend) getAllScopes(bucket string) ([]gocb.ScopeSpec, error) {
+	return b.cluster.Bucket(bucket).CollectionsV2().GetAllScopes(&gocb.GetAllScopesOptions{Timeout: b.timeout})
+}
+
+func (b *clusterBackend) getAllQueryIndexes(ks keyspace) ([]gocb.QueryIndex, error) {
+	return b.collection(ks).QueryIndexes().GetAllIndexes(&gocb.GetAllQueryIndexesOptions{Timeout: b.timeout})
+}
+
+func (b *clusterBackend) getAllSearchIndexes(bucket, scope string) ([]gocb.SearchIndex, error) {
+	return b.cluster.Bucket(bucket).Scope(scope).SearchIndexes().GetAllIndexes(
+		&gocb.GetAllSearchIndexOptions{Timeout: b.timeout})
+}
+
+func (b *clusterBackend) createBucket(settings gocb.CreateBucketSettings) error {
+	err := b.cluster.Buckets().CreateBucket(settings, &gocb.CreateBucketOptions{Timeout: b.timeout})
+	if err != nil {
+		return err
+	}
+
+	return b.cluster.Bucket(settings.Name).WaitUntilReady(b.bucketReadyTimeout, nil)
+}
+
+func (b *clusterBackend) updateBucket(settings gocb.BucketSettings) error {
+	return b.cluster.Buckets().UpdateBucket(settings, &gocb.UpdateBucketOptions{Timeout: b.timeout})
+}
+
+func (b *clusterBackend) createScope(bucket, scope string) error {
+	err := b.cluster.Bucket(bucket).CollectionsV2().CreateScope(scope, &gocb.CreateScopeOptions{Timeout: b.timeout})
+	if errors.Is(err, gocb.ErrScopeExists) {
+		return nil
+	}
+
+	return err
+}
+
+func (b *clusterBackend) createCollection(ks keyspace, settings gocb.CreateCollectionSettings) error {
+	err := b.cluster.Bucket(ks.bucket).CollectionsV2().CreateCollection(ks.scope, ks.collection, &settings,
+		&gocb.CreateCollectionOptions{Timeout: b.timeout})
+	if errors.Is(err, gocb.ErrCollectionExists) {
+		return nil
+	}
+
+	return err
+}
+
+func (b *clusterBackend) updateCollection(ks keyspace, settings gocb.UpdateCollectionSettings) error {
+	return b.cluster.Bucket(ks.bucket).CollectionsV2().UpdateCollection(ks.scope, ks.collection, settings,
+		&gocb.UpdateCollectionOptions{Timeout: b.timeout})
+}
+
+func (b *clusterBackend) createQueryIndex(ks keyspace, index IndexSpec) error {
+	mgr := b.collection(ks).QueryIndexes()
+	if index.Primary {
+		return mgr.CreatePrimaryIndex(&gocb.CreatePrimaryQueryIndexOptions{
+			IgnoreIfExists: true,
+			Deferred:       true,
+			CustomName:     index.Name,
+			NumReplicas:    index.NumReplicas,
+			Timeout:        b.timeout,
+		})
+	}
+
+	return mgr.CreateIndex(index.Name, index.Keys, &gocb.CreateQueryIndexOptions{
+		IgnoreIfExists: true,
+		Deferred:       true,
+		NumReplicas:    index.NumReplicas,
+		Timeout:        b.timeout,
+	})
+}
+
+func (b *clusterBackend) buildQueryIndexes(ks keyspace, names []string) error {
+	mgr := b.collection(ks).QueryIndexes()
+	if _, err := mgr.BuildDeferredIndexes(&gocb.BuildDeferredQueryIndexOptions{Timeout: b.timeout}); err != nil {
+		return err
+	}
+
+	return mgr.WatchIndexes(names, b.indexBuildTimeout, nil)
+}
+
+func (b *clusterBackend) upsertSearchIndex(bucket, scope string, index gocb.SearchIndex) error {
+	return b.cluster.Bucket(bucket).Scope(scope).SearchIndexes().UpsertIndex(index,
+		&gocb.UpsertSearchIndexOptions{Timeout: b.timeout})
+}
+
+func (b *clusterBackend) collection(ks keyspace) *gocb.Collection {
+	return b.cluster.Bucket(ks.bucket).Scope(ks.scope).Collection(ks.collection)
+}
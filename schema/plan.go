@@ -0,0 +1,575 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// Action is the kind of change made to a resource.
+// UNCOMMITTED: This API may change in the future.
+type Action uint8
+
+const (
+	// ActionCreate creates a resource which does not exist.
+	ActionCreate Action = iota + 1
+
+	// ActionUpdate changes the settings of an existing resource.
+	ActionUpdate
+
+	// ActionBuild builds the deferred query indexes of a collection, waiting for them to come online.
+	ActionBuild
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionCreate:
+		return "create"
+	case ActionUpdate:
+		return "update"
+	case ActionBuild:
+		return "build"
+	default:
+		return fmt.Sprintf("unknown action %d", uint8(a))
+	}
+}
+
+// ResourceKind is the kind of resource that a change is made to.
+// UNCOMMITTED: This API may change in the future.
+type ResourceKind uint8
+
+const (
+	ResourceBucket ResourceKind = iota + 1
+	ResourceScope
+	ResourceCollection
+	ResourceQueryIndex
+	ResourceSearchIndex
+)
+
+func (k ResourceKind) String() string {
+	switch k {
+	case ResourceBucket:
+		return "bucket"
+	case ResourceScope:
+		return "scope"
+	case ResourceCollection:
+		return "collection"
+	case ResourceQueryIndex:
+		return "query index"
+	case ResourceSearchIndex:
+		return "search index"
+	default:
+		return fmt.Sprintf("unknown resource %d", uint8(k))
+	}
+}
+
+// Change is a single change that Apply makes to the cluster.
+// UNCOMMITTED: This API may change in the future.
+type Change struct {
+	Action Action
+	Kind   ResourceKind
+
+	Bucket     string
+	Scope      string
+	Collection string
+
+	// Name is the name of the index for index changes, or the names of the indexes which are built for an
+	// ActionBuild change.
+	Name []string
+
+	// Detail describes the settings which are changed by an ActionUpdate change.
+	Detail string
+
+	createBucket     *gocb.CreateBucketSettings
+	updateBucket     *gocb.BucketSettings
+	createCollection *gocb.CreateCollectionSettings
+	updateCollection *gocb.UpdateCollectionSettings
+	queryIndex       *IndexSpec
+	searchIndex      *gocb.SearchIndex
+}
+
+func (c Change) String() string {
+	path := c.Bucket
+	if c.Scope != "" {
+		path += "." + c.Scope
+	}
+	if c.Collection != "" {
+		path += "." + c.Collection
+	}
+
+	var s string
+	switch c.Kind {
+	case ResourceQueryIndex:
+		noun := "query index"
+		if len(c.Name) > 1 {
+			noun = "query indexes"
+		}
+		s = fmt.Sprintf("%s %s %s on %s", c.Action, noun, strings.Join(c.Name, ", "), path)
+	case ResourceSearchIndex:
+		s = fmt.Sprintf("%s search index %s on %s", c.Action, strings.Join(c.Name, ", "), path)
+	default:
+		s = fmt.Sprintf("%s %s %s", c.Action, c.Kind, path)
+	}
+	if c.Detail != "" {
+		s += " (" + c.Detail + ")"
+	}
+
+	return s
+}
+
+// Plan is the ordered set of changes which bring the cluster to the state of a spec.
+// UNCOMMITTED: This API may change in the future.
+type Plan struct {
+	// Version is the version of the spec that the plan was made from.
+	Version string
+
+	// AppliedVersion is the version of the spec that was last applied to the cluster, empty if none has been.
+	AppliedVersion string
+
+	Changes []Change
+
+	metadata *gocb.Collection
+}
+
+// Empty returns whether the cluster already matches the spec.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+func (p *Plan) String() string {
+	if p.Empty() {
+		return "no changes"
+	}
+
+	lines := make([]string, len(p.Changes))
+	for i, change := range p.Changes {
+		lines[i] = change.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+type keyspace struct {
+	bucket     string
+	scope      string
+	collection string
+}
+
+type scopeKey struct {
+	bucket string
+	scope  string
+}
+
+// liveState is the state of the parts of the cluster referred to by a spec.
+type liveState struct {
+	buckets       map[string]gocb.BucketSettings
+	collections   map[keyspace]gocb.CollectionSpec
+	scopes        map[scopeKey]struct{}
+	queryIndexes  map[keyspace][]gocb.QueryIndex
+	searchIndexes map[scopeKey][]gocb.SearchIndex
+}
+
+func newLiveState() *liveState {
+	return &liveState{
+		buckets:       make(map[string]gocb.BucketSettings),
+		collections:   make(map[keyspace]gocb.CollectionSpec),
+		scopes:        make(map[scopeKey]struct{}),
+		queryIndexes:  make(map[keyspace][]gocb.QueryIndex),
+		searchIndexes: make(map[scopeKey][]gocb.SearchIndex),
+	}
+}
+
+func (s *liveState) hasScope(bucket, scope string) bool {
+	if _, ok := s.buckets[bucket]; !ok {
+		return false
+	}
+	if scope == "_default" {
+		return true
+	}
+
+	_, ok := s.scopes[scopeKey{bucket: bucket, scope: scope}]
+	return ok
+}
+
+// diff returns the changes which bring the live state to the spec. Changes are ordered so that every resource exists
+// before anything which depends on it is created, and every query index is created before any are built, so that
+// the indexes of a collection are built together.
+func diff(spec *Spec, state *liveState) ([]Change, error) {
+	var buckets, scopes, collections, queryIndexes, builds, searchIndexes []Change
+	for _, bucketSpec := range spec.Buckets {
+		change, err := diffBucket(bucketSpec, state)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			buckets = append(buckets, *change)
+		}
+
+		for _, scopeSpec := range bucketSpec.Scopes {
+			if !state.hasScope(bucketSpec.Name, scopeSpec.Name) {
+				scopes = append(scopes, Change{
+					Action: ActionCreate,
+					Kind:   ResourceScope,
+					Bucket: bucketSpec.Name,
+					Scope:  scopeSpec.Name,
+				})
+			}
+
+			for _, collectionSpec := range scopeSpec.Collections {
+				ks := keyspace{bucket: bucketSpec.Name, scope: scopeSpec.Name, collection: collectionSpec.Name}
+				if change := diffCollection(ks, collectionSpec, state); change != nil {
+					collections = append(collections, *change)
+				}
+
+				creates, build, err := diffQueryIndexes(ks, collectionSpec.Indexes, state.queryIndexes[ks])
+				if err != nil {
+					return nil, err
+				}
+				queryIndexes = append(queryIndexes, creates...)
+				if build != nil {
+					builds = append(builds, *build)
+				}
+			}
+
+			changes, err := diffSearchIndexes(bucketSpec.Name, scopeSpec,
+				state.searchIndexes[scopeKey{bucket: bucketSpec.Name, scope: scopeSpec.Name}])
+			if err != nil {
+				return nil, err
+			}
+			searchIndexes = append(searchIndexes, changes...)
+		}
+	}
+
+	var changes []Change
+	for _, phase := range [][]Change{buckets, scopes, collections, queryIndexes, builds, searchIndexes} {
+		changes = append(changes, phase...)
+	}
+
+	return changes, nil
+}
+
+func diffBucket(spec BucketSpec, state *liveState) (*Change, error) {
+	bucketType, err := bucketType(spec.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	current, ok := state.buckets[spec.Name]
+	if !ok {
+		settings := gocb.CreateBucketSettings{
+			BucketSettings: gocb.BucketSettings{
+				Name:           spec.Name,
+				BucketType:     bucketType,
+				RAMQuotaMB:     spec.RAMQuotaMB,
+				NumReplicas:    1,
+				StorageBackend: spec.StorageBackend,
+			},
+		}
+		if settings.RAMQuotaMB == 0 {
+			settings.RAMQuotaMB = 100
+		}
+		if spec.NumReplicas != nil {
+			settings.NumReplicas = *spec.NumReplicas
+		}
+		if spec.FlushEnabled != nil {
+			settings.FlushEnabled = *spec.FlushEnabled
+		}
+		if spec.MaxExpiry != nil {
+			settings.MaxExpiry = time.Duration(*spec.MaxExpiry)
+		}
+
+		return &Change{
+			Action:       ActionCreate,
+			Kind:         ResourceBucket,
+			Bucket:       spec.Name,
+			createBucket: &settings,
+		}, nil
+	}
+
+	if current.BucketType != bucketType {
+		return nil, conflict("bucket %s is of type %s, which cannot be changed", spec.Name, current.BucketType)
+	}
+	if spec.StorageBackend != "" && current.StorageBackend != spec.StorageBackend {
+		return nil, conflict("bucket %s has storage backend %s, which cannot be changed", spec.Name,
+			current.StorageBackend)
+	}
+
+	settings := current
+	var details []string
+	if spec.RAMQuotaMB != 0 && current.RAMQuotaMB != spec.RAMQuotaMB {
+		settings.RAMQuotaMB = spec.RAMQuotaMB
+		details = append(details, fmt.Sprintf("ramQuotaMB %d -> %d", current.RAMQuotaMB, spec.RAMQuotaMB))
+	}
+	if spec.NumReplicas != nil && current.NumReplicas != *spec.NumReplicas {
+		settings.NumReplicas = *spec.NumReplicas
+		details = append(details, fmt.Sprintf("numReplicas %d -> %d", current.NumReplicas, *spec.NumReplicas))
+	}
+	if spec.FlushEnabled != nil && current.FlushEnabled != *spec.FlushEnabled {
+		settings.FlushEnabled = *spec.FlushEnabled
+		details = append(details, fmt.Sprintf("flushEnabled %t -> %t", current.FlushEnabled, *spec.FlushEnabled))
+	}
+	if spec.MaxExpiry != nil && current.MaxExpiry != time.Duration(*spec.MaxExpiry) {
+		settings.MaxExpiry = time.Duration(*spec.MaxExpiry)
+		details = append(details, fmt.Sprintf("maxExpiry %s -> %s", current.MaxExpiry, settings.MaxExpiry))
+	}
+	if len(details) == 0 {
+		return nil, nil
+	}
+
+	return &Change{
+		Action:       ActionUpdate,
+		Kind:         ResourceBucket,
+		Bucket:       spec.Name,
+		Detail:       strings.Join(details, ", "),
+		updateBucket: &settings,
+	}, nil
+}
+
+func diffCollection(ks keyspace, spec CollectionSpec, state *liveState) *Change {
+	current, ok := state.collections[ks]
+	if !ok && !(ks.scope == "_default" && ks.collection == "_default" && state.hasScope(ks.bucket, ks.scope)) {
+		settings := &gocb.CreateCollectionSettings{}
+		if spec.MaxExpiry != nil {
+			settings.MaxExpiry = time.Duration(*spec.MaxExpiry)
+		}
+		if spec.History != nil {
+			settings.History = &gocb.CollectionHistorySettings{Enabled: *spec.History}
+		}
+
+		return &Change{
+			Action:           ActionCreate,
+			Kind:             ResourceCollection,
+			Bucket:           ks.bucket,
+			Scope:            ks.scope,
+			Collection:       ks.collection,
+			createCollection: settings,
+		}
+	}
+
+	settings := &gocb.UpdateCollectionSettings{
+		MaxExpiry: current.MaxExpiry,
+		History:   current.History,
+	}
+	var details []string
+	if spec.MaxExpiry != nil && current.MaxExpiry != time.Duration(*spec.MaxExpiry) {
+		settings.MaxExpiry = time.Duration(*spec.MaxExpiry)
+		details = append(details, fmt.Sprintf("maxExpiry %s -> %s", current.MaxExpiry, settings.MaxExpiry))
+	}
+	currentHistory := current.History != nil && current.History.Enabled
+	if spec.History != nil && currentHistory != *spec.History {
+		settings.History = &gocb.CollectionHistorySettings{Enabled: *spec.History}
+		details = append(details, fmt.Sprintf("history %t -> %t", currentHistory, *spec.History))
+	}
+	if len(details) == 0 {
+		return nil
+	}
+
+	return &Change{
+		Action:           ActionUpdate,
+		Kind:             ResourceCollection,
+		Bucket:           ks.bucket,
+		Scope:            ks.scope,
+		Collection:       ks.collection,
+		Detail:           strings.Join(details, ", "),
+		updateCollection: settings,
+	}
+}
+
+// diffQueryIndexes returns the changes creating the missing indexes of a collection, which are always created
+// deferred, and the change building every deferred index of the collection which is in the spec.
+func diffQueryIndexes(ks keyspace, specs []IndexSpec, current []gocb.QueryIndex) ([]Change, *Change, error) {
+	existing := make(map[string]gocb.QueryIndex, len(current))
+	for _, index := range current {
+		existing[index.Name] = index
+	}
+
+	var creates []Change
+	var build []string
+	for _, spec := range specs {
+		spec := spec
+		index, ok := existing[spec.name()]
+		if !ok {
+			creates = append(creates, Change{
+				Action:     ActionCreate,
+				Kind:       ResourceQueryIndex,
+				Bucket:     ks.bucket,
+				Scope:      ks.scope,
+				Collection: ks.collection,
+				Name:       []string{spec.name()},
+				queryIndex: &spec,
+			})
+			build = append(build, spec.name())
+			continue
+		}
+
+		if index.IsPrimary != spec.Primary || !equalIndexKeys(index.IndexKey, spec.Keys) {
+			return nil, nil, conflict("query index %s on %s.%s.%s has different keys, it must be dropped or the "+
+				"index renamed", spec.name(), ks.bucket, ks.scope, ks.collection)
+		}
+		if index.State == "deferred" || index.State == "created" {
+			build = append(build, spec.name())
+		}
+	}
+
+	if len(build) == 0 {
+		return creates, nil, nil
+	}
+
+	return creates, &Change{
+		Action:     ActionBuild,
+		Kind:       ResourceQueryIndex,
+		Bucket:     ks.bucket,
+		Scope:      ks.scope,
+		Collection: ks.collection,
+		Name:       build,
+	}, nil
+}
+
+// equalIndexKeys compares the keys of an index as returned by the server, which are escaped, with those of a spec.
+func equalIndexKeys(current, spec []string) bool {
+	if len(current) != len(spec) {
+		return false
+	}
+	for i := range current {
+		if strings.ReplaceAll(current[i], "`", "") != strings.ReplaceAll(spec[i], "`", "") {
+			return false
+		}
+	}
+
+	return true
+}
+
+func diffSearchIndexes(bucketName string, scope ScopeSpec, current []gocb.SearchIndex) ([]Change, error) {
+	existing := make(map[string]gocb.SearchIndex, len(current))
+	for _, index := range current {
+		existing[index.Name] = index
+	}
+
+	var changes []Change
+	for _, spec := range scope.SearchIndexes {
+		index := gocb.SearchIndex{
+			Name:         spec.Name,
+			SourceName:   bucketName,
+			SourceType:   "couchbase",
+			Type:         spec.Type,
+			Params:       spec.Params,
+			SourceParams: spec.SourceParams,
+			PlanParams:   spec.PlanParams,
+		}
+		if index.Type == "" {
+			index.Type = "fulltext-index"
+		}
+
+		change := Change{
+			Action: ActionCreate,
+			Kind:   ResourceSearchIndex,
+			Bucket: bucketName,
+			Scope:  scope.Name,
+			Name:   []string{spec.Name},
+		}
+
+		currentIndex, ok := existing[spec.Name]
+		if ok {
+			same, err := sameSearchIndex(currentIndex, index)
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				continue
+			}
+			index.UUID = currentIndex.UUID
+			change.Action = ActionUpdate
+			change.Detail = "definition changed"
+		}
+
+		change.searchIndex = &index
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// sameSearchIndex returns whether the parameters set in the desired index all have the same values in the current
+// index.
+func sameSearchIndex(current, desired gocb.SearchIndex) (bool, error) {
+	if current.Type != desired.Type {
+		return false, nil
+	}
+
+	for _, params := range [][2]map[string]interface{}{
+		{current.Params, desired.Params},
+		{current.SourceParams, desired.SourceParams},
+		{current.PlanParams, desired.PlanParams},
+	} {
+		currentParams, err := normalizeJSON(params[0])
+		if err != nil {
+			return false, err
+		}
+		desiredParams, err := normalizeJSON(params[1])
+		if err != nil {
+			return false, err
+		}
+		if !containsJSON(currentParams, desiredParams) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// normalizeJSON converts a value to the types that encoding/json would decode it to, so that values loaded from YAML
+// can be compared with those returned by the server.
+func normalizeJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
+
+// containsJSON returns whether every field of desired is present, with the same value, in current.
+func containsJSON(current, desired interface{}) bool {
+	switch d := desired.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		c, ok := current.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range d {
+			currentValue, ok := c[key]
+			if !ok || !containsJSON(currentValue, value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		c, ok := current.([]interface{})
+		if !ok || len(c) != len(d) {
+			return false
+		}
+		for i := range d {
+			if !containsJSON(c[i], d[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(current, desired)
+	}
+}
+
+func conflict(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: schema conflict: %s", gocb.ErrInvalidArgument, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,414 @@
+package schema
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/couchbase/gocb/v2/gocbtest"
+)
+
+const testSpec = `
+version: "2"
+buckets:
+  - name: travel
+    ramQuotaMB: 256
+    numReplicas: 0
+    scopes:
+      - name: inventory
+        collections:
+          - name: airline
+            maxExpiry: 24h
+            indexes:
+              - primary: true
+              - name: idx_name
+                keys: [name, country]
+          - name: route
+            history: true
+        searchIndexes:
+          - name: fts_airline
+            params:
+              mapping:
+                default_analyzer: standard
+                default_mapping:
+                  enabled: true
+`
+
+// recordingBackend is an in-memory cluster which records the changes made to it.
+type recordingBackend struct {
+	buckets       map[string]gocb.BucketSettings
+	scopes        map[string][]gocb.ScopeSpec
+	queryIndexes  map[keyspace][]gocb.QueryIndex
+	searchIndexes map[scopeKey][]gocb.SearchIndex
+
+	changes []string
+	failOn  string
+}
+
+func newRecordingBackend() *recordingBackend {
+	return &recordingBackend{
+		buckets:       make(map[string]gocb.BucketSettings),
+		scopes:        make(map[string][]gocb.ScopeSpec),
+		queryIndexes:  make(map[keyspace][]gocb.QueryIndex),
+		searchIndexes: make(map[scopeKey][]gocb.SearchIndex),
+	}
+}
+
+func (b *recordingBackend) record(format string, args ...interface{}) error {
+	change := fmt.Sprintf(format, args...)
+	if change == b.failOn {
+		return gocb.ErrInternalServerFailure
+	}
+	b.changes = append(b.changes, change)
+	return nil
+}
+
+func (b *recordingBackend) getAllBuckets() (map[string]gocb.BucketSettings, error) {
+	return b.buckets, nil
+}
+
+func (b *recordingBackend) getAllScopes(bucket string) ([]gocb.ScopeSpec, error) {
+	return b.scopes[bucket], nil
+}
+
+func (b *recordingBackend) getAllQueryIndexes(ks keyspace) ([]gocb.QueryIndex, error) {
+	return b.queryIndexes[ks], nil
+}
+
+func (b *recordingBackend) getAllSearchIndexes(bucket, scope string) ([]gocb.SearchIndex, error) {
+	return b.searchIndexes[scopeKey{bucket: bucket, scope: scope}], nil
+}
+
+func (b *recordingBackend) createBucket(settings gocb.CreateBucketSettings) error {
+	if err := b.record("create bucket %s", settings.Name); err != nil {
+		return err
+	}
+	b.buckets[settings.Name] = settings.BucketSettings
+	b.scopes[settings.Name] = []gocb.ScopeSpec{{
+		Name:        "_default",
+		Collections: []gocb.CollectionSpec{{Name: "_default", ScopeName: "_default"}},
+	}}
+	return nil
+}
+
+func (b *recordingBackend) updateBucket(settings gocb.BucketSettings) error {
+	if err := b.record("update bucket %s", settings.Name); err != nil {
+		return err
+	}
+	b.buckets[settings.Name] = settings
+	return nil
+}
+
+func (b *recordingBackend) createScope(bucket, scope string) error {
+	if err := b.record("create scope %s.%s", bucket, scope); err != nil {
+		return err
+	}
+	b.scopes[bucket] = append(b.scopes[bucket], gocb.ScopeSpec{Name: scope})
+	return nil
+}
+
+func (b *recordingBackend) createCollection(ks keyspace, settings gocb.CreateCollectionSettings) error {
+	if err := b.record("create collection %s.%s.%s", ks.bucket, ks.scope, ks.collection); err != nil {
+		return err
+	}
+	return b.setCollection(ks, gocb.CollectionSpec{
+		Name:      ks.collection,
+		ScopeName: ks.scope,
+		MaxExpiry: settings.MaxExpiry,
+		History:   settings.History,
+	})
+}
+
+func (b *recordingBackend) updateCollection(ks keyspace, settings gocb.UpdateCollectionSettings) error {
+	if err := b.record("update collection %s.%s.%s", ks.bucket, ks.scope, ks.collection); err != nil {
+		return err
+	}
+	return b.setCollection(ks, gocb.CollectionSpec{
+		Name:      ks.collection,
+		ScopeName: ks.scope,
+		MaxExpiry: settings.MaxExpiry,
+		History:   settings.History,
+	})
+}
+
+func (b *recordingBackend) setCollection(ks keyspace, spec gocb.CollectionSpec) error {
+	scopes := b.scopes[ks.bucket]
+	for i, scope := range scopes {
+		if scope.Name != ks.scope {
+			continue
+		}
+		for j, collection := range scope.Collections {
+			if collection.Name == ks.collection {
+				scopes[i].Collections[j] = spec
+				return nil
+			}
+		}
+		scopes[i].Collections = append(scopes[i].Collections, spec)
+		return nil
+	}
+
+	return gocb.ErrScopeNotFound
+}
+
+func (b *recordingBackend) createQueryIndex(ks keyspace, index IndexSpec) error {
+	if err := b.record("create query index %s on %s.%s.%s", index.name(), ks.bucket, ks.scope, ks.collection); err != nil {
+		return err
+	}
+	keys := make([]string, len(index.Keys))
+	for i, key := range index.Keys {
+		keys[i] = "`" + key + "`"
+	}
+	b.queryIndexes[ks] = append(b.queryIndexes[ks], gocb.QueryIndex{
+		Name:      index.name(),
+		IsPrimary: index.Primary,
+		IndexKey:  keys,
+		State:     "deferred",
+	})
+	return nil
+}
+
+func (b *recordingBackend) buildQueryIndexes(ks keyspace, names []string) error {
+	if err := b.record("build query indexes %v on %s.%s.%s", names, ks.bucket, ks.scope, ks.collection); err != nil {
+		return err
+	}
+	for i := range b.queryIndexes[ks] {
+		b.queryIndexes[ks][i].State = "online"
+	}
+	return nil
+}
+
+func (b *recordingBackend) upsertSearchIndex(bucket, scope string, index gocb.SearchIndex) error {
+	if err := b.record("upsert search index %s on %s.%s uuid=%s", index.Name, bucket, scope, index.UUID); err != nil {
+		return err
+	}
+
+	// The server adds its own defaults to the definition, and returns numbers as float64.
+	index.UUID = "uuid-" + index.Name
+	params, err := normalizeJSON(index.Params)
+	if err != nil {
+		return err
+	}
+	index.Params = params.(map[string]interface{})
+	index.Params["store"] = map[string]interface{}{"indexType": "scorch"}
+
+	key := scopeKey{bucket: bucket, scope: scope}
+	for i, existing := range b.searchIndexes[key] {
+		if existing.Name == index.Name {
+			b.searchIndexes[key][i] = index
+			return nil
+		}
+	}
+	b.searchIndexes[key] = append(b.searchIndexes[key], index)
+	return nil
+}
+
+func newTestMigrator(t *testing.T, backend backend) *Migrator {
+	cluster, _, err := gocbtest.Connect(nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = cluster.Close(nil)
+	})
+
+	return newMigrator(cluster, backend, &MigratorOptions{
+		MetadataCollection: cluster.Bucket("default").DefaultCollection(),
+	})
+}
+
+func TestLoad(t *testing.T) {
+	spec, err := Load([]byte(testSpec))
+	require.NoError(t, err)
+	assert.Equal(t, "2", spec.Version)
+	require.Len(t, spec.Buckets, 1)
+	assert.EqualValues(t, 0, *spec.Buckets[0].NumReplicas)
+	airline := spec.Buckets[0].Scopes[0].Collections[0]
+	assert.Equal(t, Duration(24*time.Hour), *airline.MaxExpiry)
+	assert.Equal(t, "#primary", airline.Indexes[0].name())
+
+	spec, err = Load([]byte(`{"version": "1", "buckets": [{"name": "b", "type": "ephemeral", "maxExpiry": "1h"}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, "ephemeral", spec.Buckets[0].Type)
+
+	for name, data := range map[string]string{
+		"UnknownField":     `buckets: [{name: b, ramQuota: 100}]`,
+		"BadDuration":      `buckets: [{name: b, maxExpiry: forever}]`,
+		"UnknownType":      `buckets: [{name: b, type: magma}]`,
+		"DuplicateBucket":  `buckets: [{name: b}, {name: b}]`,
+		"MemcachedScopes":  `buckets: [{name: b, type: memcached, scopes: [{name: s}]}]`,
+		"EmptyCollection":  `buckets: [{name: b, scopes: [{name: s, collections: [{}]}]}]`,
+		"IndexWithoutKeys": `buckets: [{name: b, scopes: [{name: s, collections: [{name: c, indexes: [{name: i}]}]}]}]`,
+		"PrimaryWithKeys": `buckets: [{name: b, scopes: [{name: s, collections: [{name: c, ` +
+			`indexes: [{primary: true, keys: [a]}]}]}]}]`,
+		"DuplicateSearchIndex": `buckets: [{name: b, scopes: [{name: s, searchIndexes: [{name: f}, {name: f}]}]}]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load([]byte(data))
+			assert.Error(t, err)
+		})
+	}
+
+	_, err = Load([]byte(`buckets: [{name: b, type: magma}]`))
+	assert.ErrorIs(t, err, gocb.ErrInvalidArgument)
+}
+
+func TestPlanAndApply(t *testing.T) {
+	spec, err := Load([]byte(testSpec))
+	require.NoError(t, err)
+
+	backend := newRecordingBackend()
+	migrator := newTestMigrator(t, backend)
+
+	plan, err := migrator.Plan(spec)
+	require.NoError(t, err)
+	assert.Equal(t, "2", plan.Version)
+	assert.Empty(t, plan.AppliedVersion)
+	assert.Equal(t, []string{
+		"create bucket travel",
+		"create scope travel.inventory",
+		"create collection travel.inventory.airline",
+		"create collection travel.inventory.route",
+		"create query index #primary on travel.inventory.airline",
+		"create query index idx_name on travel.inventory.airline",
+		"build query indexes #primary, idx_name on travel.inventory.airline",
+		"create search index fts_airline on travel.inventory",
+	}, stringChanges(plan))
+	assert.Empty(t, backend.changes)
+
+	require.NoError(t, migrator.Apply(plan))
+	assert.Equal(t, []string{
+		"create bucket travel",
+		"create scope travel.inventory",
+		"create collection travel.inventory.airline",
+		"create collection travel.inventory.route",
+		"create query index #primary on travel.inventory.airline",
+		"create query index idx_name on travel.inventory.airline",
+		"build query indexes [#primary idx_name] on travel.inventory.airline",
+		"upsert search index fts_airline on travel.inventory uuid=",
+	}, backend.changes)
+
+	settings := backend.buckets["travel"]
+	assert.EqualValues(t, 256, settings.RAMQuotaMB)
+	assert.Zero(t, settings.NumReplicas)
+
+	metadata, err := migrator.Metadata(spec)
+	require.NoError(t, err)
+	assert.Equal(t, "2", metadata.Version)
+	require.Len(t, metadata.History, 1)
+	assert.Len(t, metadata.History[0].Changes, 8)
+
+	// Applying the same spec again changes nothing, and does not add to the history.
+	plan, err = migrator.Plan(spec)
+	require.NoError(t, err)
+	assert.True(t, plan.Empty(), plan.String())
+	assert.Equal(t, "2", plan.AppliedVersion)
+	require.NoError(t, migrator.Apply(plan))
+
+	metadata, err = migrator.Metadata(spec)
+	require.NoError(t, err)
+	assert.Len(t, metadata.History, 1)
+
+	// Changed settings are updated, and new resources are created alongside the existing ones.
+	spec.Version = "3"
+	spec.Buckets[0].RAMQuotaMB = 512
+	collections := spec.Buckets[0].Scopes[0].Collections
+	collections[0].MaxExpiry = nil
+	history := false
+	collections[1].History = &history
+	collections[1].Indexes = []IndexSpec{{Name: "idx_dest", Keys: []string{"destinationairport"}}}
+	spec.Buckets[0].Scopes[0].SearchIndexes[0].Params["mapping"].(map[string]interface{})["default_analyzer"] =
+		"keyword"
+
+	backend.changes = nil
+	plan, err = migrator.Migrate(spec)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"update bucket travel (ramQuotaMB 256 -> 512)",
+		"update collection travel.inventory.route (history true -> false)",
+		"create query index idx_dest on travel.inventory.route",
+		"build query index idx_dest on travel.inventory.route",
+		"update search index fts_airline on travel.inventory (definition changed)",
+	}, stringChanges(plan))
+	assert.Equal(t, "upsert search index fts_airline on travel.inventory uuid=uuid-fts_airline", backend.changes[4])
+
+	metadata, err = migrator.Metadata(spec)
+	require.NoError(t, err)
+	assert.Equal(t, "3", metadata.Version)
+	assert.Len(t, metadata.History, 2)
+}
+
+func TestApplyResumesAfterFailure(t *testing.T) {
+	spec, err := Load([]byte(testSpec))
+	require.NoError(t, err)
+
+	backend := newRecordingBackend()
+	backend.failOn = "create collection travel.inventory.route"
+	migrator := newTestMigrator(t, backend)
+
+	_, err = migrator.Migrate(spec)
+	assert.ErrorIs(t, err, gocb.ErrInternalServerFailure)
+	assert.Len(t, backend.changes, 3)
+
+	metadata, err := migrator.Metadata(spec)
+	require.NoError(t, err)
+	assert.Empty(t, metadata.Version)
+
+	backend.failOn = ""
+	backend.changes = nil
+	plan, err := migrator.Migrate(spec)
+	require.NoError(t, err)
+	assert.Equal(t, "create collection travel.inventory.route", backend.changes[0])
+	assert.Len(t, plan.Changes, 5)
+}
+
+func TestPlanConflicts(t *testing.T) {
+	spec, err := Load([]byte(testSpec))
+	require.NoError(t, err)
+
+	backend := newRecordingBackend()
+	migrator := newTestMigrator(t, backend)
+	_, err = migrator.Migrate(spec)
+	require.NoError(t, err)
+
+	// An existing deferred index is built rather than created.
+	ks := keyspace{bucket: "travel", scope: "inventory", collection: "airline"}
+	backend.queryIndexes[ks][1].State = "deferred"
+	plan, err := migrator.Plan(spec)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build query index idx_name on travel.inventory.airline"}, stringChanges(plan))
+
+	backend.queryIndexes[ks][1].IndexKey = []string{"`name`"}
+	_, err = migrator.Plan(spec)
+	assert.ErrorIs(t, err, gocb.ErrInvalidArgument)
+	backend.queryIndexes[ks][1].IndexKey = []string{"`name`", "`country`"}
+
+	settings := backend.buckets["travel"]
+	settings.BucketType = gocb.EphemeralBucketType
+	backend.buckets["travel"] = settings
+	_, err = migrator.Plan(spec)
+	assert.ErrorIs(t, err, gocb.ErrInvalidArgument)
+}
+
+func TestContainsJSON(t *testing.T) {
+	current := map[string]interface{}{
+		"a": 1.0,
+		"b": map[string]interface{}{"c": "d", "e": []interface{}{1.0, "f"}},
+	}
+
+	assert.True(t, containsJSON(current, map[string]interface{}{"a": 1.0}))
+	assert.True(t, containsJSON(current, map[string]interface{}{"b": map[string]interface{}{"c": "d"}}))
+	assert.True(t, containsJSON(current, nil))
+	assert.False(t, containsJSON(current, map[string]interface{}{"a": 2.0}))
+	assert.False(t, containsJSON(current, map[string]interface{}{"z": 1.0}))
+	assert.False(t, containsJSON(current, map[string]interface{}{"b": map[string]interface{}{"e": []interface{}{1.0}}}))
+}
+
+func stringChanges(plan *Plan) []string {
+	changes := make([]string, len(plan.Changes))
+	for i, change := range plan.Changes {
+		changes[i] = change.String()
+	}
+	return changes
+}
@@ -0,0 +1,246 @@
+// Package schema provisions buckets, scopes, collections, query indexes and search indexes from a declarative
+// specification of the desired state of a cluster.
+//
+// A Spec is loaded from YAML or JSON, compared against the live cluster by Migrator.Plan to produce the changes needed
+// to reach it, and those changes are made by Migrator.Apply. Applying a spec is idempotent: anything which already
+// matches the spec is left unchanged, as is anything on the cluster which is not in the spec, so nothing is ever
+// dropped.
+// UNCOMMITTED: This API may change in the future.
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// Duration is a time.Duration which is written in specs as a string such as "24h" or "-1s".
+// UNCOMMITTED: This API may change in the future.
+type Duration time.Duration
+
+// UnmarshalText parses the duration.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText formats the duration.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Spec is the desired state of a cluster.
+// UNCOMMITTED: This API may change in the future.
+type Spec struct {
+	// Version identifies this revision of the spec, it is recorded in the metadata document once applied.
+	Version string       `json:"version" yaml:"version"`
+	Buckets []BucketSpec `json:"buckets" yaml:"buckets"`
+}
+
+// BucketSpec is the desired state of a bucket. Settings which are not set are left at the server default when the
+// bucket is created, and are not changed on an existing bucket.
+// UNCOMMITTED: This API may change in the future.
+type BucketSpec struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Type is one of couchbase, ephemeral or memcached. Defaults to couchbase.
+	Type           string              `json:"type,omitempty" yaml:"type,omitempty"`
+	RAMQuotaMB     uint64              `json:"ramQuotaMB,omitempty" yaml:"ramQuotaMB,omitempty"`
+	NumReplicas    *uint32             `json:"numReplicas,omitempty" yaml:"numReplicas,omitempty"`
+	FlushEnabled   *bool               `json:"flushEnabled,omitempty" yaml:"flushEnabled,omitempty"`
+	MaxExpiry      *Duration           `json:"maxExpiry,omitempty" yaml:"maxExpiry,omitempty"`
+	StorageBackend gocb.StorageBackend `json:"storageBackend,omitempty" yaml:"storageBackend,omitempty"`
+
+	Scopes []ScopeSpec `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+}
+
+// ScopeSpec is the desired state of a scope.
+// UNCOMMITTED: This API may change in the future.
+type ScopeSpec struct {
+	Name          string            `json:"name" yaml:"name"`
+	Collections   []CollectionSpec  `json:"collections,omitempty" yaml:"collections,omitempty"`
+	SearchIndexes []SearchIndexSpec `json:"searchIndexes,omitempty" yaml:"searchIndexes,omitempty"`
+}
+
+// CollectionSpec is the desired state of a collection.
+// UNCOMMITTED: This API may change in the future.
+type CollectionSpec struct {
+	Name string `json:"name" yaml:"name"`
+
+	// MaxExpiry is the maximum expiry of the documents in the collection, -1s meaning that documents never expire.
+	// Defaults to the setting of the bucket.
+	MaxExpiry *Duration `json:"maxExpiry,omitempty" yaml:"maxExpiry,omitempty"`
+	History   *bool     `json:"history,omitempty" yaml:"history,omitempty"`
+
+	Indexes []IndexSpec `json:"indexes,omitempty" yaml:"indexes,omitempty"`
+}
+
+// IndexSpec is a query (GSI) index on a collection.
+// UNCOMMITTED: This API may change in the future.
+type IndexSpec struct {
+	// Name is the name of the index. Defaults to #primary for a primary index.
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
+	Primary bool   `json:"primary,omitempty" yaml:"primary,omitempty"`
+
+	// Keys are the fields which are indexed, which must be set for any index other than a primary index.
+	Keys        []string `json:"keys,omitempty" yaml:"keys,omitempty"`
+	NumReplicas int      `json:"numReplicas,omitempty" yaml:"numReplicas,omitempty"`
+}
+
+func (s IndexSpec) name() string {
+	if s.Name == "" && s.Primary {
+		return "#primary"
+	}
+
+	return s.Name
+}
+
+// SearchIndexSpec is a search (FTS) index on a scope. Only the parameters which are set are compared against an
+// existing index, so the server may add its own defaults without the index being seen as changed.
+// UNCOMMITTED: This API may change in the future.
+type SearchIndexSpec struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Type defaults to fulltext-index.
+	Type         string                 `json:"type,omitempty" yaml:"type,omitempty"`
+	Params       map[string]interface{} `json:"params,omitempty" yaml:"params,omitempty"`
+	SourceParams map[string]interface{} `json:"sourceParams,omitempty" yaml:"sourceParams,omitempty"`
+	PlanParams   map[string]interface{} `json:"planParams,omitempty" yaml:"planParams,omitempty"`
+}
+
+// Load parses a spec from YAML or JSON, JSON being a subset of YAML, and validates it.
+// UNCOMMITTED: This API may change in the future.
+func Load(data []byte) (*Spec, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var spec Spec
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("failed to parse schema spec: %w", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+// LoadFile reads and parses a spec from a YAML or JSON file.
+// UNCOMMITTED: This API may change in the future.
+func LoadFile(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Load(data)
+}
+
+// Validate checks that the spec is well formed, without reference to any cluster.
+func (s *Spec) Validate() error {
+	buckets := make(map[string]struct{})
+	for _, bucket := range s.Buckets {
+		if bucket.Name == "" {
+			return invalidSpec("bucket name cannot be empty")
+		}
+		if _, ok := buckets[bucket.Name]; ok {
+			return invalidSpec("bucket %s is specified more than once", bucket.Name)
+		}
+		buckets[bucket.Name] = struct{}{}
+		if _, err := bucketType(bucket.Type); err != nil {
+			return err
+		}
+		if bucket.Type == "memcached" && len(bucket.Scopes) > 0 {
+			return invalidSpec("memcached bucket %s cannot have scopes", bucket.Name)
+		}
+
+		scopes := make(map[string]struct{})
+		for _, scope := range bucket.Scopes {
+			if scope.Name == "" {
+				return invalidSpec("scope name in bucket %s cannot be empty", bucket.Name)
+			}
+			if _, ok := scopes[scope.Name]; ok {
+				return invalidSpec("scope %s.%s is specified more than once", bucket.Name, scope.Name)
+			}
+			scopes[scope.Name] = struct{}{}
+
+			if err := validateScope(bucket.Name, scope); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateScope(bucketName string, scope ScopeSpec) error {
+	collections := make(map[string]struct{})
+	for _, collection := range scope.Collections {
+		path := bucketName + "." + scope.Name + "." + collection.Name
+		if collection.Name == "" {
+			return invalidSpec("collection name in scope %s.%s cannot be empty", bucketName, scope.Name)
+		}
+		if _, ok := collections[collection.Name]; ok {
+			return invalidSpec("collection %s is specified more than once", path)
+		}
+		collections[collection.Name] = struct{}{}
+
+		indexes := make(map[string]struct{})
+		for _, index := range collection.Indexes {
+			if index.name() == "" {
+				return invalidSpec("index name on %s cannot be empty", path)
+			}
+			if !index.Primary && len(index.Keys) == 0 {
+				return invalidSpec("index %s on %s must have keys", index.name(), path)
+			}
+			if index.Primary && len(index.Keys) > 0 {
+				return invalidSpec("primary index %s on %s cannot have keys", index.name(), path)
+			}
+			if _, ok := indexes[index.name()]; ok {
+				return invalidSpec("index %s on %s is specified more than once", index.name(), path)
+			}
+			indexes[index.name()] = struct{}{}
+		}
+	}
+
+	searchIndexes := make(map[string]struct{})
+	for _, index := range scope.SearchIndexes {
+		if index.Name == "" {
+			return invalidSpec("search index name in scope %s.%s cannot be empty", bucketName, scope.Name)
+		}
+		if _, ok := searchIndexes[index.Name]; ok {
+			return invalidSpec("search index %s in scope %s.%s is specified more than once", index.Name, bucketName,
+				scope.Name)
+		}
+		searchIndexes[index.Name] = struct{}{}
+	}
+
+	return nil
+}
+
+func bucketType(name string) (gocb.BucketType, error) {
+	switch name {
+	case "", "couchbase":
+		return gocb.CouchbaseBucketType, nil
+	case "ephemeral":
+		return gocb.EphemeralBucketType, nil
+	case "memcached":
+		return gocb.MemcachedBucketType, nil
+	default:
+		return "", invalidSpec("unknown bucket type %s", name)
+	}
+}
+
+func invalidSpec(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: invalid schema spec: %s", gocb.ErrInvalidArgument, fmt.Sprintf(format, args...))
+}
@@ -7,6 +7,12 @@ func (s *Scope) AnalyticsQuery(statement string, opts *AnalyticsOptions) (*Analy
 			opts = &AnalyticsOptions{}
 		}
 
-		return provider.AnalyticsQuery(statement, s, opts)
+		res, err := provider.AnalyticsQuery(statement, s, opts)
+		if err != nil {
+			return nil, err
+		}
+		res.ctx = opts.Context
+
+		return res, nil
 	})
 }
@@ -11,6 +11,17 @@ func (s *Scope) Query(statement string, opts *QueryOptions) (*QueryResult, error
 			return s.getTransactions().singleQuery(statement, s, *opts)
 		}
 
-		return provider.Query(statement, s, opts)
+		finished := s.bucket.queryPlanCapture.begin(statement, opts, s, s.ExplainQuery, s.AdviseQuery)
+		res, err := provider.Query(statement, s, opts)
+		if err != nil {
+			if finished != nil {
+				finished(0)
+			}
+			return nil, err
+		}
+		res.ctx = opts.Context
+		res.finished = finished
+
+		return res, nil
 	})
 }
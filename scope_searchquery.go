@@ -11,6 +11,12 @@ func (s *Scope) Search(indexName string, request SearchRequest, opts *SearchOpti
 			opts = &SearchOptions{}
 		}
 
-		return provider.Search(s, indexName, request, opts)
+		res, err := provider.Search(s, indexName, request, opts)
+		if err != nil {
+			return nil, err
+		}
+		res.ctx = opts.Context
+
+		return res, nil
 	})
 }
@@ -44,7 +44,7 @@ func (sm *searchIndexProviderCore) GetAllIndexes(scope *Scope, opts *GetAllSearc
 		IsIdempotent:  true,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 	resp, err := sm.doMgmtRequest(opts.Context, req)
 	if err != nil {
@@ -104,7 +104,7 @@ func (sm *searchIndexProviderCore) GetIndex(scope *Scope, indexName string, opts
 		IsIdempotent:  true,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 	resp, err := sm.doMgmtRequest(opts.Context, req)
 	if err != nil {
@@ -236,7 +236,7 @@ func (sm *searchIndexProviderCore) UpsertIndex(scope *Scope, indexDefinition Sea
 		Body:          b,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 	resp, err := sm.doMgmtRequest(opts.Context, req)
 	if err != nil {
@@ -280,7 +280,7 @@ func (sm *searchIndexProviderCore) DropIndex(scope *Scope, indexName string, opt
 		Path:          path,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 	resp, err := sm.doMgmtRequest(opts.Context, req)
 	if err != nil {
@@ -331,7 +331,7 @@ func (sm *searchIndexProviderCore) AnalyzeDocument(scope *Scope, indexName strin
 		IsIdempotent:  true,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 	resp, err := sm.doMgmtRequest(opts.Context, req)
 	if err != nil {
@@ -386,7 +386,7 @@ func (sm *searchIndexProviderCore) GetIndexedDocumentsCount(scope *Scope, indexN
 		IsIdempotent:  true,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 	resp, err := sm.doMgmtRequest(opts.Context, req)
 	if err != nil {
@@ -435,7 +435,7 @@ func (sm *searchIndexProviderCore) PauseIngest(scope *Scope, indexName string, o
 
 	return sm.performControlRequest(
 		opts.Context,
-		span.Context(),
+		span,
 		"POST",
 		path,
 		opts.Timeout,
@@ -462,7 +462,7 @@ func (sm *searchIndexProviderCore) ResumeIngest(scope *Scope, indexName string,
 
 	return sm.performControlRequest(
 		opts.Context,
-		span.Context(),
+		span,
 		"POST",
 		path,
 		opts.Timeout,
@@ -489,7 +489,7 @@ func (sm *searchIndexProviderCore) AllowQuerying(scope *Scope, indexName string,
 
 	return sm.performControlRequest(
 		opts.Context,
-		span.Context(),
+		span,
 		"POST",
 		path,
 		opts.Timeout,
@@ -516,7 +516,7 @@ func (sm *searchIndexProviderCore) DisallowQuerying(scope *Scope, indexName stri
 
 	return sm.performControlRequest(
 		opts.Context,
-		span.Context(),
+		span,
 		"POST",
 		path,
 		opts.Timeout,
@@ -543,7 +543,7 @@ func (sm *searchIndexProviderCore) FreezePlan(scope *Scope, indexName string, op
 
 	return sm.performControlRequest(
 		opts.Context,
-		span.Context(),
+		span,
 		"POST",
 		path,
 		opts.Timeout,
@@ -570,7 +570,7 @@ func (sm *searchIndexProviderCore) UnfreezePlan(scope *Scope, indexName string,
 
 	return sm.performControlRequest(
 		opts.Context,
-		span.Context(),
+		span,
 		"POST",
 		path,
 		opts.Timeout,
@@ -595,7 +595,7 @@ func (sm *searchIndexProviderCore) vectorSearchUnsupported() bool {
 
 func (sm *searchIndexProviderCore) performControlRequest(
 	ctx context.Context,
-	tracectx RequestSpanContext,
+	span RequestSpan,
 	method, uri string,
 	timeout time.Duration,
 	retryStrategy RetryStrategy,
@@ -607,7 +607,7 @@ func (sm *searchIndexProviderCore) performControlRequest(
 		IsIdempotent:  true,
 		Timeout:       timeout,
 		RetryStrategy: retryStrategy,
-		parentSpanCtx: tracectx,
+		parentSpan:    span,
 	}
 
 	resp, err := sm.doMgmtRequest(ctx, req)
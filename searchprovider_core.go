@@ -60,6 +60,7 @@ type searchProviderCore struct {
 	transcoder           Transcoder
 	timeouts             TimeoutsConfig
 	tracer               *tracerWrapper
+	rateLimiter          *serviceRateLimiter
 }
 
 func (search *searchProviderCore) Search(scope *Scope, indexName string, request SearchRequest, opts *SearchOptions) (*SearchResult, error) {
@@ -171,7 +172,18 @@ func (search *searchProviderCore) execSearchQuery(
 		coreOpts.ScopeName = scope.scopeName
 	}
 
+	release, err := search.rateLimiter.acquire(ctx, deadline)
+	if err != nil {
+		return nil, &SearchError{
+			InnerError: err,
+			Query:      maybeGetSearchOptionQuery(options),
+		}
+	}
+	coreOpts.RetryStrategy = search.rateLimiter.wrapRetryStrategy(retryStrategy.forOperation(deadline, span))
+
 	res, err := search.provider.SearchQuery(ctx, coreOpts)
+	// The limiter only governs dispatch, streaming the rows is not counted against it.
+	release(err)
 	if err != nil {
 		return nil, maybeEnhanceSearchError(err)
 	}
@@ -293,6 +305,8 @@ type SearchResult struct {
 
 	currentRow SearchRow
 	jsonErr    error
+
+	ctx context.Context
 }
 
 func newSearchResult(reader searchRowReader) *SearchResult {
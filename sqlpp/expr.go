@@ -0,0 +1,556 @@
+// Package sqlpp builds SQL++ (N1QL) statements for Cluster.Query and Scope.Query.
+//
+// Values are never written into the statement itself, they are always passed as positional or named parameters in the
+// QueryOptions returned by Build, and identifiers are always escaped with backticks. Strings are treated as field
+// paths where an operand is expected, such as on the left of a comparison, and as parameters where a value is
+// expected, such as on the right:
+//
+//	statement, opts, err := sqlpp.Select("name", sqlpp.As(sqlpp.MetaID("u"), "id")).
+//		From(sqlpp.KeyspaceOf(collection).As("u")).
+//		Where(sqlpp.Eq("u.address.city", city), sqlpp.Gte("u.age", 18)).
+//		OrderBy(sqlpp.Desc("u.age")).
+//		Limit(10).
+//		Build(nil)
+//
+// UNCOMMITTED: This API may change in the future.
+package sqlpp
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// Expr is an expression within a statement.
+// UNCOMMITTED: This API may change in the future.
+type Expr interface {
+	buildExpr(b *builder)
+}
+
+// builder accumulates the text and parameters of a statement.
+type builder struct {
+	sb    strings.Builder
+	args  []interface{}
+	named map[string]interface{}
+	err   error
+}
+
+func (b *builder) write(s string) {
+	b.sb.WriteString(s)
+}
+
+func (b *builder) fail(format string, args ...interface{}) {
+	if b.err == nil {
+		b.err = fmt.Errorf("%w: %s", gocb.ErrInvalidArgument, fmt.Sprintf(format, args...))
+	}
+}
+
+func (b *builder) ident(name string) {
+	if name == "" {
+		b.fail("identifier cannot be empty")
+		return
+	}
+
+	b.write(Escape(name))
+}
+
+func (b *builder) positional(value interface{}) {
+	b.args = append(b.args, value)
+	b.write("$" + strconv.Itoa(len(b.args)))
+}
+
+func (b *builder) namedParam(name string, value interface{}) {
+	if !identifierPattern.MatchString(name) {
+		b.fail("invalid parameter name %q", name)
+		return
+	}
+	if b.named == nil {
+		b.named = make(map[string]interface{})
+	}
+	if existing, ok := b.named[name]; ok && !reflect.DeepEqual(existing, value) {
+		b.fail("parameter %s is given two different values", name)
+		return
+	}
+
+	b.named[name] = value
+	b.write("$" + name)
+}
+
+// operand writes a value which is an expression, with strings being field paths.
+func (b *builder) operand(value interface{}) {
+	switch v := value.(type) {
+	case Expr:
+		v.buildExpr(b)
+	case string:
+		Field(v).buildExpr(b)
+	default:
+		b.fail("%v must be a field path or an expression", value)
+	}
+}
+
+// value writes a value which is a parameter, unless it is an expression.
+func (b *builder) value(value interface{}) {
+	if expr, ok := value.(Expr); ok {
+		expr.buildExpr(b)
+		return
+	}
+
+	b.positional(value)
+}
+
+// list writes each of the items, separated by commas.
+func (b *builder) list(items []interface{}, write func(item interface{})) {
+	for i, item := range items {
+		if i > 0 {
+			b.write(", ")
+		}
+		write(item)
+	}
+}
+
+var (
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	pathSegment       = regexp.MustCompile(`^([^\[\]]+)((?:\[[0-9]+\])*)$`)
+)
+
+// Escape escapes an identifier with backticks, doubling any backticks within it.
+// UNCOMMITTED: This API may change in the future.
+func Escape(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+type fieldExpr struct {
+	path string
+}
+
+func (e fieldExpr) buildExpr(b *builder) {
+	if e.path == "" {
+		b.fail("field path cannot be empty")
+		return
+	}
+
+	for i, segment := range strings.Split(e.path, ".") {
+		if i > 0 {
+			b.write(".")
+		}
+
+		match := pathSegment.FindStringSubmatch(segment)
+		if match == nil {
+			b.fail("invalid field path %q", e.path)
+			return
+		}
+		b.ident(match[1])
+		b.write(match[2])
+	}
+}
+
+// Field is a field path, such as "address.city" or "items[0].name", whose segments are each escaped.
+// UNCOMMITTED: This API may change in the future.
+func Field(path string) Expr {
+	return fieldExpr{path: path}
+}
+
+type identExpr struct {
+	parts []string
+}
+
+func (e identExpr) buildExpr(b *builder) {
+	for i, part := range e.parts {
+		if i > 0 {
+			b.write(".")
+		}
+		b.ident(part)
+	}
+}
+
+// Ident is a path of identifiers which are each escaped as they are, so may contain dots or brackets.
+// UNCOMMITTED: This API may change in the future.
+func Ident(parts ...string) Expr {
+	return identExpr{parts: parts}
+}
+
+type paramExpr struct {
+	value interface{}
+}
+
+func (e paramExpr) buildExpr(b *builder) {
+	b.positional(e.value)
+}
+
+// Param is a positional parameter. Any value which is not an Expr is already treated as one where a value is expected,
+// so Param is only needed where an operand is expected.
+// UNCOMMITTED: This API may change in the future.
+func Param(value interface{}) Expr {
+	return paramExpr{value: value}
+}
+
+type namedParamExpr struct {
+	name  string
+	value interface{}
+}
+
+func (e namedParamExpr) buildExpr(b *builder) {
+	b.namedParam(e.name, e.value)
+}
+
+// Named is a named parameter, which can be used more than once within a statement.
+// UNCOMMITTED: This API may change in the future.
+func Named(name string, value interface{}) Expr {
+	return namedParamExpr{name: name, value: value}
+}
+
+type rawExpr struct {
+	sql string
+}
+
+func (e rawExpr) buildExpr(b *builder) {
+	b.write(e.sql)
+}
+
+// Raw is SQL++ which is written into the statement as it is. It must never contain input from users.
+// UNCOMMITTED: This API may change in the future.
+func Raw(sql string) Expr {
+	return rawExpr{sql: sql}
+}
+
+type metaExpr struct {
+	alias string
+	field string
+}
+
+func (e metaExpr) buildExpr(b *builder) {
+	b.write("META(")
+	if e.alias != "" {
+		b.ident(e.alias)
+	}
+	b.write(")." + e.field)
+}
+
+// MetaID is the ID of the document with the given alias, or of the only keyspace if the alias is empty.
+// UNCOMMITTED: This API may change in the future.
+func MetaID(alias string) Expr {
+	return metaExpr{alias: alias, field: "id"}
+}
+
+// MetaCas is the CAS of the document with the given alias, or of the only keyspace if the alias is empty.
+// UNCOMMITTED: This API may change in the future.
+func MetaCas(alias string) Expr {
+	return metaExpr{alias: alias, field: "cas"}
+}
+
+type binaryExpr struct {
+	op    string
+	left  interface{}
+	right interface{}
+}
+
+func (e binaryExpr) buildExpr(b *builder) {
+	b.operand(e.left)
+	b.write(" " + e.op + " ")
+	b.value(e.right)
+}
+
+// Eq compares left and right for equality.
+// UNCOMMITTED: This API may change in the future.
+func Eq(left, right interface{}) Expr {
+	return binaryExpr{op: "=", left: left, right: right}
+}
+
+// Ne compares left and right for inequality.
+// UNCOMMITTED: This API may change in the future.
+func Ne(left, right interface{}) Expr {
+	return binaryExpr{op: "!=", left: left, right: right}
+}
+
+// Lt compares whether left is less than right.
+// UNCOMMITTED: This API may change in the future.
+func Lt(left, right interface{}) Expr {
+	return binaryExpr{op: "<", left: left, right: right}
+}
+
+// Lte compares whether left is less than or equal to right.
+// UNCOMMITTED: This API may change in the future.
+func Lte(left, right interface{}) Expr {
+	return binaryExpr{op: "<=", left: left, right: right}
+}
+
+// Gt compares whether left is greater than right.
+// UNCOMMITTED: This API may change in the future.
+func Gt(left, right interface{}) Expr {
+	return binaryExpr{op: ">", left: left, right: right}
+}
+
+// Gte compares whether left is greater than or equal to right.
+// UNCOMMITTED: This API may change in the future.
+func Gte(left, right interface{}) Expr {
+	return binaryExpr{op: ">=", left: left, right: right}
+}
+
+// Like matches left against the pattern right.
+// UNCOMMITTED: This API may change in the future.
+func Like(left, right interface{}) Expr {
+	return binaryExpr{op: "LIKE", left: left, right: right}
+}
+
+// NotLike matches left against the pattern right, being true if it does not match.
+// UNCOMMITTED: This API may change in the future.
+func NotLike(left, right interface{}) Expr {
+	return binaryExpr{op: "NOT LIKE", left: left, right: right}
+}
+
+// In tests whether left is within the array right, which can be a slice passed as a single parameter or a subquery.
+// UNCOMMITTED: This API may change in the future.
+func In(left, right interface{}) Expr {
+	return binaryExpr{op: "IN", left: left, right: right}
+}
+
+// NotIn tests whether left is not within the array right.
+// UNCOMMITTED: This API may change in the future.
+func NotIn(left, right interface{}) Expr {
+	return binaryExpr{op: "NOT IN", left: left, right: right}
+}
+
+// Concat concatenates the strings left and right.
+// UNCOMMITTED: This API may change in the future.
+func Concat(left, right interface{}) Expr {
+	return binaryExpr{op: "||", left: left, right: right}
+}
+
+type betweenExpr struct {
+	operand interface{}
+	low     interface{}
+	high    interface{}
+}
+
+func (e betweenExpr) buildExpr(b *builder) {
+	b.operand(e.operand)
+	b.write(" BETWEEN ")
+	b.value(e.low)
+	b.write(" AND ")
+	b.value(e.high)
+}
+
+// Between tests whether operand is between low and high inclusive.
+// UNCOMMITTED: This API may change in the future.
+func Between(operand, low, high interface{}) Expr {
+	return betweenExpr{operand: operand, low: low, high: high}
+}
+
+type postfixExpr struct {
+	operand interface{}
+	op      string
+}
+
+func (e postfixExpr) buildExpr(b *builder) {
+	b.operand(e.operand)
+	b.write(" " + e.op)
+}
+
+// IsNull tests whether operand is null.
+// UNCOMMITTED: This API may change in the future.
+func IsNull(operand interface{}) Expr {
+	return postfixExpr{operand: operand, op: "IS NULL"}
+}
+
+// IsNotNull tests whether operand is not null.
+// UNCOMMITTED: This API may change in the future.
+func IsNotNull(operand interface{}) Expr {
+	return postfixExpr{operand: operand, op: "IS NOT NULL"}
+}
+
+// IsMissing tests whether operand is missing.
+// UNCOMMITTED: This API may change in the future.
+func IsMissing(operand interface{}) Expr {
+	return postfixExpr{operand: operand, op: "IS MISSING"}
+}
+
+// IsNotMissing tests whether operand is present, even if it is null.
+// UNCOMMITTED: This API may change in the future.
+func IsNotMissing(operand interface{}) Expr {
+	return postfixExpr{operand: operand, op: "IS NOT MISSING"}
+}
+
+// IsValued tests whether operand is neither missing nor null.
+// UNCOMMITTED: This API may change in the future.
+func IsValued(operand interface{}) Expr {
+	return postfixExpr{operand: operand, op: "IS VALUED"}
+}
+
+// IsNotValued tests whether operand is missing or null.
+// UNCOMMITTED: This API may change in the future.
+func IsNotValued(operand interface{}) Expr {
+	return postfixExpr{operand: operand, op: "IS NOT VALUED"}
+}
+
+type logicalExpr struct {
+	op    string
+	exprs []Expr
+}
+
+func (e logicalExpr) buildExpr(b *builder) {
+	if len(e.exprs) == 0 {
+		if e.op == "AND" {
+			b.write("TRUE")
+		} else {
+			b.write("FALSE")
+		}
+		return
+	}
+	if len(e.exprs) == 1 {
+		e.exprs[0].buildExpr(b)
+		return
+	}
+
+	b.write("(")
+	for i, expr := range e.exprs {
+		if i > 0 {
+			b.write(" " + e.op + " ")
+		}
+		expr.buildExpr(b)
+	}
+	b.write(")")
+}
+
+// And is true if every one of exprs is true.
+// UNCOMMITTED: This API may change in the future.
+func And(exprs ...Expr) Expr {
+	return logicalExpr{op: "AND", exprs: exprs}
+}
+
+// Or is true if any one of exprs is true.
+// UNCOMMITTED: This API may change in the future.
+func Or(exprs ...Expr) Expr {
+	return logicalExpr{op: "OR", exprs: exprs}
+}
+
+type notExpr struct {
+	expr Expr
+}
+
+func (e notExpr) buildExpr(b *builder) {
+	b.write("NOT (")
+	e.expr.buildExpr(b)
+	b.write(")")
+}
+
+// Not negates expr.
+// UNCOMMITTED: This API may change in the future.
+func Not(expr Expr) Expr {
+	return notExpr{expr: expr}
+}
+
+type funcExpr struct {
+	name string
+	args []interface{}
+}
+
+func (e funcExpr) buildExpr(b *builder) {
+	if !identifierPattern.MatchString(e.name) {
+		b.fail("invalid function name %q", e.name)
+		return
+	}
+
+	b.write(strings.ToUpper(e.name) + "(")
+	b.list(e.args, b.value)
+	b.write(")")
+}
+
+// Func calls the named function. Arguments which are not an Expr are passed as parameters, so fields must be given
+// with Field.
+// UNCOMMITTED: This API may change in the future.
+func Func(name string, args ...interface{}) Expr {
+	return funcExpr{name: name, args: args}
+}
+
+type rangeExpr struct {
+	quantifier string
+	variable   string
+	array      interface{}
+	satisfies  Expr
+}
+
+func (e rangeExpr) buildExpr(b *builder) {
+	b.write(e.quantifier + " ")
+	b.ident(e.variable)
+	b.write(" IN ")
+	b.operand(e.array)
+	b.write(" SATISFIES ")
+	e.satisfies.buildExpr(b)
+	b.write(" END")
+}
+
+// Any is true if satisfies is true for any element of array, each of which is named variable.
+// UNCOMMITTED: This API may change in the future.
+func Any(variable string, array interface{}, satisfies Expr) Expr {
+	return rangeExpr{quantifier: "ANY", variable: variable, array: array, satisfies: satisfies}
+}
+
+// Every is true if satisfies is true for every element of array, each of which is named variable.
+// UNCOMMITTED: This API may change in the future.
+func Every(variable string, array interface{}, satisfies Expr) Expr {
+	return rangeExpr{quantifier: "EVERY", variable: variable, array: array, satisfies: satisfies}
+}
+
+type aliasExpr struct {
+	expr  interface{}
+	alias string
+}
+
+func (e aliasExpr) buildExpr(b *builder) {
+	b.operand(e.expr)
+	b.write(" AS ")
+	b.ident(e.alias)
+}
+
+// As names the result of expr, which is a field path if it is a string.
+// UNCOMMITTED: This API may change in the future.
+func As(expr interface{}, alias string) Expr {
+	return aliasExpr{expr: expr, alias: alias}
+}
+
+type orderExpr struct {
+	expr      interface{}
+	direction string
+}
+
+func (e orderExpr) buildExpr(b *builder) {
+	b.operand(e.expr)
+	b.write(" " + e.direction)
+}
+
+// Asc orders by expr, which is a field path if it is a string, in ascending order.
+// UNCOMMITTED: This API may change in the future.
+func Asc(expr interface{}) Expr {
+	return orderExpr{expr: expr, direction: "ASC"}
+}
+
+// Desc orders by expr, which is a field path if it is a string, in descending order.
+// UNCOMMITTED: This API may change in the future.
+func Desc(expr interface{}) Expr {
+	return orderExpr{expr: expr, direction: "DESC"}
+}
+
+// projection writes an item of a SELECT or RETURNING clause, where "*" and paths ending in ".*" select every field.
+func (b *builder) projection(item interface{}) {
+	s, ok := item.(string)
+	if !ok {
+		b.operand(item)
+		return
+	}
+
+	if s == "*" {
+		b.write("*")
+		return
+	}
+	if strings.HasSuffix(s, ".*") {
+		Field(strings.TrimSuffix(s, ".*")).buildExpr(b)
+		b.write(".*")
+		return
+	}
+
+	Field(s).buildExpr(b)
+}
@@ -0,0 +1,258 @@
+package sqlpp
+
+import (
+	"strconv"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+type keyValue struct {
+	key   interface{}
+	value interface{}
+}
+
+// InsertBuilder builds an INSERT or UPSERT statement.
+// UNCOMMITTED: This API may change in the future.
+type InsertBuilder struct {
+	verb      string
+	keyspace  Keyspace
+	values    []keyValue
+	selectKV  *keyValue
+	query     *SelectBuilder
+	returning []interface{}
+}
+
+// InsertInto starts an INSERT statement into keyspace, which fails for documents which already exist.
+// UNCOMMITTED: This API may change in the future.
+func InsertInto(keyspace Keyspace) *InsertBuilder {
+	return &InsertBuilder{verb: "INSERT", keyspace: keyspace}
+}
+
+// UpsertInto starts an UPSERT statement into keyspace, which replaces documents which already exist.
+// UNCOMMITTED: This API may change in the future.
+func UpsertInto(keyspace Keyspace) *InsertBuilder {
+	return &InsertBuilder{verb: "UPSERT", keyspace: keyspace}
+}
+
+// Values adds a document with the given ID and content. As with the right of a comparison they are parameters unless
+// they are an Expr.
+func (i *InsertBuilder) Values(key, value interface{}) *InsertBuilder {
+	i.values = append(i.values, keyValue{key: key, value: value})
+	return i
+}
+
+// Select writes a document for each row of query, with the ID and content given by key and value. These are field
+// paths or expressions, usually referring to the items selected by query.
+func (i *InsertBuilder) Select(key, value interface{}, query *SelectBuilder) *InsertBuilder {
+	i.selectKV = &keyValue{key: key, value: value}
+	i.query = query
+	return i
+}
+
+// Returning returns the given items of each document written, as with the items of Select.
+func (i *InsertBuilder) Returning(items ...interface{}) *InsertBuilder {
+	i.returning = append(i.returning, items...)
+	return i
+}
+
+// Build returns the statement along with the options to run it with.
+func (i *InsertBuilder) Build(opts *gocb.QueryOptions) (string, *gocb.QueryOptions, error) {
+	return build(i, opts)
+}
+
+func (i *InsertBuilder) buildStatement(b *builder) {
+	if (len(i.values) > 0) == (i.query != nil) {
+		b.fail("%s requires either values or a query, but not both", i.verb)
+		return
+	}
+
+	b.write(i.verb + " INTO ")
+	i.keyspace.build(b)
+
+	if i.query != nil {
+		b.write(" (KEY ")
+		b.operand(i.selectKV.key)
+		b.write(", VALUE ")
+		b.operand(i.selectKV.value)
+		b.write(") ")
+		i.query.buildStatement(b)
+	} else {
+		b.write(" (KEY, VALUE) VALUES ")
+		for idx, kv := range i.values {
+			if idx > 0 {
+				b.write(", ")
+			}
+			b.write("(")
+			b.value(kv.key)
+			b.write(", ")
+			b.value(kv.value)
+			b.write(")")
+		}
+	}
+
+	b.returning(i.returning)
+}
+
+type setClause struct {
+	path  string
+	value interface{}
+}
+
+// UpdateBuilder builds an UPDATE statement.
+// UNCOMMITTED: This API may change in the future.
+type UpdateBuilder struct {
+	keyspace  Keyspace
+	useKeys   []string
+	set       []setClause
+	unset     []string
+	where     []Expr
+	limit     *uint64
+	returning []interface{}
+}
+
+// Update starts an UPDATE statement of keyspace.
+// UNCOMMITTED: This API may change in the future.
+func Update(keyspace Keyspace) *UpdateBuilder {
+	return &UpdateBuilder{keyspace: keyspace}
+}
+
+// UseKeys restricts the documents updated to those with the given IDs.
+func (u *UpdateBuilder) UseKeys(keys ...string) *UpdateBuilder {
+	if u.useKeys == nil {
+		u.useKeys = []string{}
+	}
+	u.useKeys = append(u.useKeys, keys...)
+	return u
+}
+
+// Set sets the field at path to value, which is a parameter unless it is an Expr.
+func (u *UpdateBuilder) Set(path string, value interface{}) *UpdateBuilder {
+	u.set = append(u.set, setClause{path: path, value: value})
+	return u
+}
+
+// Unset removes the fields at the given paths.
+func (u *UpdateBuilder) Unset(paths ...string) *UpdateBuilder {
+	u.unset = append(u.unset, paths...)
+	return u
+}
+
+// Where filters the documents updated by conditions, all of which must be true.
+func (u *UpdateBuilder) Where(conditions ...Expr) *UpdateBuilder {
+	u.where = append(u.where, conditions...)
+	return u
+}
+
+// Limit sets the maximum number of documents updated.
+func (u *UpdateBuilder) Limit(limit uint64) *UpdateBuilder {
+	u.limit = &limit
+	return u
+}
+
+// Returning returns the given items of each document updated, as with the items of Select.
+func (u *UpdateBuilder) Returning(items ...interface{}) *UpdateBuilder {
+	u.returning = append(u.returning, items...)
+	return u
+}
+
+// Build returns the statement along with the options to run it with.
+func (u *UpdateBuilder) Build(opts *gocb.QueryOptions) (string, *gocb.QueryOptions, error) {
+	return build(u, opts)
+}
+
+func (u *UpdateBuilder) buildStatement(b *builder) {
+	if len(u.set) == 0 && len(u.unset) == 0 {
+		b.fail("UPDATE requires at least one field to set or unset")
+		return
+	}
+
+	b.write("UPDATE ")
+	u.keyspace.build(b)
+	b.useKeys(u.useKeys)
+
+	if len(u.set) > 0 {
+		b.write(" SET ")
+		for i, set := range u.set {
+			if i > 0 {
+				b.write(", ")
+			}
+			Field(set.path).buildExpr(b)
+			b.write(" = ")
+			b.value(set.value)
+		}
+	}
+	if len(u.unset) > 0 {
+		b.write(" UNSET ")
+		for i, path := range u.unset {
+			if i > 0 {
+				b.write(", ")
+			}
+			Field(path).buildExpr(b)
+		}
+	}
+
+	b.conditions(" WHERE ", u.where)
+	if u.limit != nil {
+		b.write(" LIMIT " + strconv.FormatUint(*u.limit, 10))
+	}
+	b.returning(u.returning)
+}
+
+// DeleteBuilder builds a DELETE statement.
+// UNCOMMITTED: This API may change in the future.
+type DeleteBuilder struct {
+	keyspace  Keyspace
+	useKeys   []string
+	where     []Expr
+	limit     *uint64
+	returning []interface{}
+}
+
+// DeleteFrom starts a DELETE statement of keyspace.
+// UNCOMMITTED: This API may change in the future.
+func DeleteFrom(keyspace Keyspace) *DeleteBuilder {
+	return &DeleteBuilder{keyspace: keyspace}
+}
+
+// UseKeys restricts the documents deleted to those with the given IDs.
+func (d *DeleteBuilder) UseKeys(keys ...string) *DeleteBuilder {
+	if d.useKeys == nil {
+		d.useKeys = []string{}
+	}
+	d.useKeys = append(d.useKeys, keys...)
+	return d
+}
+
+// Where filters the documents deleted by conditions, all of which must be true.
+func (d *DeleteBuilder) Where(conditions ...Expr) *DeleteBuilder {
+	d.where = append(d.where, conditions...)
+	return d
+}
+
+// Limit sets the maximum number of documents deleted.
+func (d *DeleteBuilder) Limit(limit uint64) *DeleteBuilder {
+	d.limit = &limit
+	return d
+}
+
+// Returning returns the given items of each document deleted, as with the items of Select.
+func (d *DeleteBuilder) Returning(items ...interface{}) *DeleteBuilder {
+	d.returning = append(d.returning, items...)
+	return d
+}
+
+// Build returns the statement along with the options to run it with.
+func (d *DeleteBuilder) Build(opts *gocb.QueryOptions) (string, *gocb.QueryOptions, error) {
+	return build(d, opts)
+}
+
+func (d *DeleteBuilder) buildStatement(b *builder) {
+	b.write("DELETE FROM ")
+	d.keyspace.build(b)
+	b.useKeys(d.useKeys)
+	b.conditions(" WHERE ", d.where)
+	if d.limit != nil {
+		b.write(" LIMIT " + strconv.FormatUint(*d.limit, 10))
+	}
+	b.returning(d.returning)
+}
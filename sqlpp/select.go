@@ -0,0 +1,251 @@
+package sqlpp
+
+import (
+	"strconv"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+type joinClause struct {
+	kind     string
+	keyspace Keyspace
+	on       Expr
+}
+
+type unnestClause struct {
+	kind  string
+	array interface{}
+	alias string
+}
+
+type letClause struct {
+	name  string
+	value interface{}
+}
+
+// SelectBuilder builds a SELECT statement.
+// UNCOMMITTED: This API may change in the future.
+type SelectBuilder struct {
+	distinct bool
+	raw      bool
+	items    []interface{}
+	from     *Keyspace
+	useKeys  []string
+	joins    []interface{}
+	lets     []letClause
+	where    []Expr
+	groupBy  []interface{}
+	having   []Expr
+	orderBy  []interface{}
+	limit    *uint64
+	offset   *uint64
+}
+
+// Select starts a SELECT statement of the given items. Strings are field paths, where "*" and paths ending in ".*"
+// select every field, and anything else must be an Expr.
+// UNCOMMITTED: This API may change in the future.
+func Select(items ...interface{}) *SelectBuilder {
+	return &SelectBuilder{items: items}
+}
+
+// SelectRaw starts a SELECT RAW statement, returning the value of item for each row rather than an object.
+// UNCOMMITTED: This API may change in the future.
+func SelectRaw(item interface{}) *SelectBuilder {
+	return &SelectBuilder{raw: true, items: []interface{}{item}}
+}
+
+// Distinct removes duplicate rows from the results.
+func (s *SelectBuilder) Distinct() *SelectBuilder {
+	s.distinct = true
+	return s
+}
+
+// From sets the keyspace being selected from.
+func (s *SelectBuilder) From(keyspace Keyspace) *SelectBuilder {
+	s.from = &keyspace
+	return s
+}
+
+// UseKeys restricts the documents of the keyspace to those with the given IDs.
+func (s *SelectBuilder) UseKeys(keys ...string) *SelectBuilder {
+	if s.useKeys == nil {
+		s.useKeys = []string{}
+	}
+	s.useKeys = append(s.useKeys, keys...)
+	return s
+}
+
+// Join performs an inner join with keyspace on the condition on.
+func (s *SelectBuilder) Join(keyspace Keyspace, on Expr) *SelectBuilder {
+	s.joins = append(s.joins, joinClause{kind: "JOIN", keyspace: keyspace, on: on})
+	return s
+}
+
+// LeftJoin performs a left outer join with keyspace on the condition on.
+func (s *SelectBuilder) LeftJoin(keyspace Keyspace, on Expr) *SelectBuilder {
+	s.joins = append(s.joins, joinClause{kind: "LEFT JOIN", keyspace: keyspace, on: on})
+	return s
+}
+
+// Nest nests the documents of keyspace matching the condition on into an array, named by the alias of keyspace.
+func (s *SelectBuilder) Nest(keyspace Keyspace, on Expr) *SelectBuilder {
+	s.joins = append(s.joins, joinClause{kind: "NEST", keyspace: keyspace, on: on})
+	return s
+}
+
+// LeftNest is as Nest, but keeps documents which have no matching documents to nest.
+func (s *SelectBuilder) LeftNest(keyspace Keyspace, on Expr) *SelectBuilder {
+	s.joins = append(s.joins, joinClause{kind: "LEFT NEST", keyspace: keyspace, on: on})
+	return s
+}
+
+// Unnest joins each document with each element of array, a field path or an Expr, which is named alias.
+func (s *SelectBuilder) Unnest(array interface{}, alias string) *SelectBuilder {
+	s.joins = append(s.joins, unnestClause{kind: "UNNEST", array: array, alias: alias})
+	return s
+}
+
+// LeftUnnest is as Unnest, but keeps documents for which array is empty or missing.
+func (s *SelectBuilder) LeftUnnest(array interface{}, alias string) *SelectBuilder {
+	s.joins = append(s.joins, unnestClause{kind: "LEFT UNNEST", array: array, alias: alias})
+	return s
+}
+
+// Let names the value of an expression for use within the rest of the statement. As with the right of a comparison a
+// value which is not an Expr is a parameter, so fields must be given with Field.
+func (s *SelectBuilder) Let(name string, value interface{}) *SelectBuilder {
+	s.lets = append(s.lets, letClause{name: name, value: value})
+	return s
+}
+
+// Where filters the documents by conditions, all of which must be true. Calling Where again adds further conditions.
+func (s *SelectBuilder) Where(conditions ...Expr) *SelectBuilder {
+	s.where = append(s.where, conditions...)
+	return s
+}
+
+// GroupBy groups the documents by the given field paths or expressions.
+func (s *SelectBuilder) GroupBy(items ...interface{}) *SelectBuilder {
+	s.groupBy = append(s.groupBy, items...)
+	return s
+}
+
+// Having filters the groups by conditions, all of which must be true.
+func (s *SelectBuilder) Having(conditions ...Expr) *SelectBuilder {
+	s.having = append(s.having, conditions...)
+	return s
+}
+
+// OrderBy orders the results by the given field paths or expressions, which can be wrapped by Asc or Desc.
+func (s *SelectBuilder) OrderBy(items ...interface{}) *SelectBuilder {
+	s.orderBy = append(s.orderBy, items...)
+	return s
+}
+
+// Limit sets the maximum number of results.
+func (s *SelectBuilder) Limit(limit uint64) *SelectBuilder {
+	s.limit = &limit
+	return s
+}
+
+// Offset sets the number of results to skip.
+func (s *SelectBuilder) Offset(offset uint64) *SelectBuilder {
+	s.offset = &offset
+	return s
+}
+
+// Build returns the statement along with the options to run it with.
+func (s *SelectBuilder) Build(opts *gocb.QueryOptions) (string, *gocb.QueryOptions, error) {
+	return build(s, opts)
+}
+
+// buildExpr writes the statement as a subquery.
+func (s *SelectBuilder) buildExpr(b *builder) {
+	b.write("(")
+	s.buildStatement(b)
+	b.write(")")
+}
+
+func (s *SelectBuilder) buildStatement(b *builder) {
+	if len(s.items) == 0 {
+		b.fail("SELECT requires at least one item")
+		return
+	}
+	if s.from == nil && (s.useKeys != nil || len(s.joins) > 0) {
+		b.fail("USE KEYS, joins, nests and unnests require a keyspace to select from")
+		return
+	}
+
+	b.write("SELECT ")
+	if s.distinct {
+		b.write("DISTINCT ")
+	}
+	if s.raw {
+		b.write("RAW ")
+	}
+	b.list(s.items, b.projection)
+
+	if s.from != nil {
+		b.write(" FROM ")
+		s.from.build(b)
+		b.useKeys(s.useKeys)
+	}
+
+	for _, join := range s.joins {
+		switch j := join.(type) {
+		case joinClause:
+			if j.on == nil {
+				b.fail("%s requires a condition", j.kind)
+				return
+			}
+			b.write(" " + j.kind + " ")
+			j.keyspace.build(b)
+			b.write(" ON ")
+			j.on.buildExpr(b)
+		case unnestClause:
+			b.write(" " + j.kind + " ")
+			b.operand(j.array)
+			if j.alias != "" {
+				b.write(" AS ")
+				b.ident(j.alias)
+			}
+		}
+	}
+
+	if len(s.lets) > 0 {
+		b.write(" LET ")
+		for i, let := range s.lets {
+			if i > 0 {
+				b.write(", ")
+			}
+			b.ident(let.name)
+			b.write(" = ")
+			b.value(let.value)
+		}
+	}
+
+	b.conditions(" WHERE ", s.where)
+
+	if len(s.groupBy) > 0 {
+		b.write(" GROUP BY ")
+		b.list(s.groupBy, b.operand)
+	}
+	if len(s.having) > 0 {
+		if len(s.groupBy) == 0 {
+			b.fail("HAVING requires GROUP BY")
+			return
+		}
+		b.conditions(" HAVING ", s.having)
+	}
+
+	if len(s.orderBy) > 0 {
+		b.write(" ORDER BY ")
+		b.list(s.orderBy, b.operand)
+	}
+	if s.limit != nil {
+		b.write(" LIMIT " + strconv.FormatUint(*s.limit, 10))
+	}
+	if s.offset != nil {
+		b.write(" OFFSET " + strconv.FormatUint(*s.offset, 10))
+	}
+}
@@ -0,0 +1,151 @@
+package sqlpp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/couchbase/gocb/v2/gocbtest"
+)
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, "`name`", Escape("name"))
+	assert.Equal(t, "`we``ird`", Escape("we`ird"))
+
+	statement, _, err := Select("address.ci`ty", "items[0].name", "u.*").
+		From(NewKeyspace("travel-sample", "inventory", "air`line").As("u")).
+		Build(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT `address`.`ci``ty`, `items`[0].`name`, `u`.* "+
+		"FROM `travel-sample`.`inventory`.`air``line` AS `u`", statement)
+
+	statement, _, err = Select(Ident("a.b", "c")).From(NewKeyspace("users")).Build(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT `a.b`.`c` FROM `users`", statement)
+}
+
+func TestSelect(t *testing.T) {
+	cluster, _, err := gocbtest.Connect(nil)
+	require.NoError(t, err)
+	defer cluster.Close(nil)
+	collection := cluster.Bucket("travel-sample").Scope("inventory").Collection("route")
+
+	statement, opts, err := Select("r.airline", As(MetaID("r"), "id"), As(Func("count", Field("s")), "flights")).
+		From(KeyspaceOf(collection).As("r")).
+		Unnest("r.schedule", "s").
+		Let("minStops", Named("stops", 0)).
+		Where(
+			Eq("r.sourceairport", "LHR"),
+			Or(Gte("r.distance", 1000), Like("r.destinationairport", "J%")),
+			IsNotMissing("r.equipment"),
+			Eq("r.stops", Named("stops", 0)),
+		).
+		GroupBy("r.airline", MetaID("r")).
+		Having(Gt(Func("count", Field("s")), 2)).
+		OrderBy(Desc("flights"), "r.airline").
+		Limit(10).
+		Offset(20).
+		Build(&gocb.QueryOptions{Timeout: time.Second, Adhoc: true})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT `r`.`airline`, META(`r`).id AS `id`, COUNT(`s`) AS `flights` "+
+		"FROM `travel-sample`.`inventory`.`route` AS `r` UNNEST `r`.`schedule` AS `s` LET `minStops` = $stops "+
+		"WHERE `r`.`sourceairport` = $1 AND (`r`.`distance` >= $2 OR `r`.`destinationairport` LIKE $3) "+
+		"AND `r`.`equipment` IS NOT MISSING AND `r`.`stops` = $stops "+
+		"GROUP BY `r`.`airline`, META(`r`).id HAVING COUNT(`s`) > $4 "+
+		"ORDER BY `flights` DESC, `r`.`airline` LIMIT 10 OFFSET 20", statement)
+	assert.Equal(t, []interface{}{"LHR", 1000, "J%", 2}, opts.PositionalParameters)
+	assert.Equal(t, map[string]interface{}{"stops": 0}, opts.NamedParameters)
+	assert.Equal(t, time.Second, opts.Timeout)
+	assert.True(t, opts.Adhoc)
+
+	statement, opts, err = SelectRaw("name").Distinct().
+		From(NewKeyspace("airline")).
+		UseKeys("airline_10", "airline_137").
+		Where(In("country", Select(Field("c.name")).From(NewKeyspace("country").As("c")).Where(Eq("c.eu", true)))).
+		Build(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT DISTINCT RAW `name` FROM `airline` USE KEYS $1 "+
+		"WHERE `country` IN (SELECT `c`.`name` FROM `country` AS `c` WHERE `c`.`eu` = $2)", statement)
+	assert.Equal(t, []interface{}{[]string{"airline_10", "airline_137"}, true}, opts.PositionalParameters)
+	assert.Nil(t, opts.NamedParameters)
+
+	statement, _, err = Select("*").
+		From(NewKeyspace("hotel").As("h")).
+		Nest(NewKeyspace("review").As("reviews"), Eq(Field("reviews.hotel"), MetaID("h"))).
+		LeftJoin(NewKeyspace("city").As("c"), Eq("c.name", Field("h.city"))).
+		Where(Any("r", "reviews", Gte("r.rating", 4)), Not(IsNull("h.name"))).
+		Build(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM `hotel` AS `h` NEST `review` AS `reviews` ON `reviews`.`hotel` = META(`h`).id "+
+		"LEFT JOIN `city` AS `c` ON `c`.`name` = `h`.`city` "+
+		"WHERE ANY `r` IN `reviews` SATISFIES `r`.`rating` >= $1 END AND NOT (`h`.`name` IS NULL)", statement)
+}
+
+func TestMutations(t *testing.T) {
+	statement, opts, err := UpsertInto(NewKeyspace("users")).
+		Values("user::1", map[string]string{"name": "Alice"}).
+		Values("user::2", map[string]string{"name": "Bob"}).
+		Returning(MetaID("")).
+		Build(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "UPSERT INTO `users` (KEY, VALUE) VALUES ($1, $2), ($3, $4) RETURNING META().id", statement)
+	assert.Len(t, opts.PositionalParameters, 4)
+
+	statement, opts, err = InsertInto(NewKeyspace("archive")).
+		Select(Concat(Named("prefix", "archive::"), Field("id")), "doc",
+			Select(As(MetaID("u"), "id"), As(Field("u"), "doc")).
+				From(NewKeyspace("users").As("u")).
+				Where(Lt("u.lastLogin", "2020-01-01"))).
+		Returning("*").
+		Build(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO `archive` (KEY $prefix || `id`, VALUE `doc`) "+
+		"SELECT META(`u`).id AS `id`, `u` AS `doc` FROM `users` AS `u` WHERE `u`.`lastLogin` < $1 RETURNING *",
+		statement)
+	assert.Equal(t, []interface{}{"2020-01-01"}, opts.PositionalParameters)
+	assert.Equal(t, map[string]interface{}{"prefix": "archive::"}, opts.NamedParameters)
+
+	statement, opts, err = Update(NewKeyspace("users")).
+		UseKeys("user::1").
+		Set("address.city", "paris").
+		Set("updatedAt", Func("NOW_STR")).
+		Unset("legacy").
+		Returning("address").
+		Build(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "UPDATE `users` USE KEYS $1 SET `address`.`city` = $2, `updatedAt` = NOW_STR() "+
+		"UNSET `legacy` RETURNING `address`", statement)
+	assert.Equal(t, []interface{}{"user::1", "paris"}, opts.PositionalParameters)
+
+	statement, _, err = DeleteFrom(NewKeyspace("users")).
+		Where(Between("age", 10, 20), IsNotValued("email")).
+		Limit(5).
+		Returning(MetaID("")).
+		Build(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "DELETE FROM `users` WHERE `age` BETWEEN $1 AND $2 AND `email` IS NOT VALUED LIMIT 5 "+
+		"RETURNING META().id", statement)
+}
+
+func TestInvalidStatements(t *testing.T) {
+	for name, stmt := range map[string]Statement{
+		"no items":          Select(),
+		"bad path":          Select("a..b").From(NewKeyspace("users")),
+		"bad keyspace":      Select("*").From(NewKeyspace("bucket", "scope")),
+		"bad function":      Select(Func("count(*); DROP", Field("a"))).From(NewKeyspace("users")),
+		"bad parameter":     Select("*").From(NewKeyspace("users")).Where(Eq("a", Named("a b", 1))),
+		"clashing named":    Select("*").From(NewKeyspace("users")).Where(Eq("a", Named("x", 1)), Eq("b", Named("x", 2))),
+		"value as operand":  Select("*").From(NewKeyspace("users")).Where(Eq(1, 2)),
+		"empty use keys":    Select("*").From(NewKeyspace("users")).UseKeys(),
+		"unnest no from":    Select("*").Unnest("items", "i"),
+		"having no group":   Select("*").From(NewKeyspace("users")).Having(Gt("a", 1)),
+		"no values":         UpsertInto(NewKeyspace("users")),
+		"nothing to update": Update(NewKeyspace("users")),
+	} {
+		_, _, err := stmt.Build(nil)
+		assert.ErrorIs(t, err, gocb.ErrInvalidArgument, name)
+	}
+}
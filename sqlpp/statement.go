@@ -0,0 +1,122 @@
+package sqlpp
+
+import (
+	"github.com/couchbase/gocb/v2"
+)
+
+// Keyspace is a bucket, a collection within a bucket, or a collection within the scope being queried, optionally with
+// an alias.
+// UNCOMMITTED: This API may change in the future.
+type Keyspace struct {
+	path  []string
+	alias string
+}
+
+// NewKeyspace creates a keyspace from its path: a bucket, a bucket, scope and collection, or a collection name alone
+// for queries run by Scope.Query.
+// UNCOMMITTED: This API may change in the future.
+func NewKeyspace(path ...string) Keyspace {
+	return Keyspace{path: path}
+}
+
+// KeyspaceOf is the fully qualified keyspace of a collection, which can be queried by either Cluster.Query or
+// Scope.Query.
+// UNCOMMITTED: This API may change in the future.
+func KeyspaceOf(collection *gocb.Collection) Keyspace {
+	return NewKeyspace(collection.Bucket().Name(), collection.ScopeName(), collection.Name())
+}
+
+// As returns the keyspace with the given alias.
+// UNCOMMITTED: This API may change in the future.
+func (k Keyspace) As(alias string) Keyspace {
+	k.alias = alias
+	return k
+}
+
+func (k Keyspace) build(b *builder) {
+	if len(k.path) == 0 || len(k.path) == 2 || len(k.path) > 3 {
+		b.fail("keyspace must be a bucket, a collection, or a bucket, scope and collection, not %v", k.path)
+		return
+	}
+
+	for i, part := range k.path {
+		if i > 0 {
+			b.write(".")
+		}
+		b.ident(part)
+	}
+
+	if k.alias != "" {
+		b.write(" AS ")
+		b.ident(k.alias)
+	}
+}
+
+// Statement is a statement which can be built for Cluster.Query or Scope.Query.
+// UNCOMMITTED: This API may change in the future.
+type Statement interface {
+	// Build returns the statement along with the options to run it with, which are a copy of opts, or new options
+	// if opts is nil, with the PositionalParameters and NamedParameters of the statement.
+	Build(opts *gocb.QueryOptions) (string, *gocb.QueryOptions, error)
+
+	buildStatement(b *builder)
+}
+
+func build(stmt Statement, opts *gocb.QueryOptions) (string, *gocb.QueryOptions, error) {
+	b := &builder{}
+	stmt.buildStatement(b)
+	if b.err != nil {
+		return "", nil, b.err
+	}
+
+	built := &gocb.QueryOptions{}
+	if opts != nil {
+		*built = *opts
+	}
+	built.PositionalParameters = b.args
+	built.NamedParameters = b.named
+
+	return b.sb.String(), built, nil
+}
+
+// useKeys writes a USE KEYS clause, passing the keys as a single parameter.
+func (b *builder) useKeys(keys []string) {
+	if keys == nil {
+		return
+	}
+	if len(keys) == 0 {
+		b.fail("USE KEYS requires at least one key")
+		return
+	}
+
+	b.write(" USE KEYS ")
+	if len(keys) == 1 {
+		b.positional(keys[0])
+		return
+	}
+	b.positional(keys)
+}
+
+// conditions writes a clause such as WHERE, in which all of the conditions must be true.
+func (b *builder) conditions(clause string, conditions []Expr) {
+	if len(conditions) == 0 {
+		return
+	}
+
+	b.write(clause)
+	for i, condition := range conditions {
+		if i > 0 {
+			b.write(" AND ")
+		}
+		condition.buildExpr(b)
+	}
+}
+
+func (b *builder) returning(items []interface{}) {
+	if len(items) == 0 {
+		return
+	}
+
+	b.write(" RETURNING ")
+	b.list(items, b.projection)
+}
@@ -147,7 +147,7 @@ func (mt *MutationState) UnmarshalJSON(data []byte) error {
 			if err != nil {
 				return err
 			}
-			vbUUID, err := strconv.Atoi(stateToken.VbUUID)
+			vbUUID, err := strconv.ParseUint(stateToken.VbUUID, 10, 64)
 			if err != nil {
 				return err
 			}
@@ -11,6 +11,7 @@ type testSpan struct {
 	Finished      bool
 	ParentContext RequestSpanContext
 	Spans         map[RequestSpanContext][]*testSpan
+	Events        []string
 }
 
 func (ts *testSpan) End() {
@@ -35,6 +36,7 @@ func (ts *testSpan) SetAttribute(key string, value interface{}) {
 }
 
 func (ts *testSpan) AddEvent(key string, timestamp time.Time) {
+	ts.Events = append(ts.Events, key)
 }
 
 type testTracer struct {
@@ -150,7 +150,7 @@ func (c *TransactionAttemptContext) get(collection *Collection, id string, serve
 				collection: collection,
 				docID:      id,
 
-				transcoder: NewJSONTranscoder(),
+				transcoder: c.transcoder,
 				flags:      2 << 24,
 
 				coreRes: res,
@@ -182,7 +182,6 @@ func (c *TransactionAttemptContext) get(collection *Collection, id string, serve
 
 // Replace will replace the contents of a document, failing if the document does not already exist.
 func (c *TransactionAttemptContext) Replace(doc *TransactionGetResult, value interface{}) (*TransactionGetResult, error) {
-	// TODO: Use Transcoder here
 	valueBytes, _, err := c.transcoder.Encode(value)
 	if err != nil {
 		return nil, err
@@ -218,7 +217,7 @@ func (c *TransactionAttemptContext) replace(doc *TransactionGetResult, valueByte
 				collection: collection,
 				docID:      id,
 
-				transcoder: NewJSONTranscoder(),
+				transcoder: c.transcoder,
 				flags:      2 << 24,
 
 				coreRes: res,
@@ -239,7 +238,6 @@ func (c *TransactionAttemptContext) replace(doc *TransactionGetResult, valueByte
 
 // Insert will insert a new document, failing if the document already exists.
 func (c *TransactionAttemptContext) Insert(collection *Collection, id string, value interface{}) (*TransactionGetResult, error) {
-	// TODO: Use Transcoder here
 	valueBytes, _, err := c.transcoder.Encode(value)
 	if err != nil {
 		return nil, err
@@ -280,7 +278,7 @@ func (c *TransactionAttemptContext) insert(collection *Collection, id string, va
 				collection: collection,
 				docID:      id,
 
-				transcoder: NewJSONTranscoder(),
+				transcoder: c.transcoder,
 				flags:      2 << 24,
 
 				coreRes: res,
@@ -94,7 +94,7 @@ func (c *TransactionAttemptContext) getQueryMode(collection *Collection, id stri
 		collection: collection,
 		docID:      id,
 
-		transcoder: NewJSONTranscoder(),
+		transcoder: c.transcoder,
 		flags:      2 << 24,
 
 		txnMeta: row.TxnMeta,
@@ -187,7 +187,7 @@ func (c *TransactionAttemptContext) replaceQueryMode(doc *TransactionGetResult,
 		collection: doc.collection,
 		docID:      doc.docID,
 
-		transcoder: NewJSONTranscoder(),
+		transcoder: c.transcoder,
 		flags:      2 << 24,
 
 		coreRes: &gocbcore.TransactionGetResult{
@@ -261,7 +261,7 @@ func (c *TransactionAttemptContext) insertQueryMode(collection *Collection, id s
 		collection: collection,
 		docID:      id,
 
-		transcoder: NewJSONTranscoder(),
+		transcoder: c.transcoder,
 		flags:      2 << 24,
 
 		coreRes: &gocbcore.TransactionGetResult{
@@ -46,6 +46,12 @@ type TransactionsConfig struct {
 	// CleanupConfig specifies cleanup configuration to use in transactions.
 	CleanupConfig TransactionsCleanupConfig
 
+	// Transcoder specifies the transcoder used to encode values written, and decode values read, within
+	// transactions. Transactions only support JSON documents so the transcoder must produce JSON.
+	// Defaults to JSONTranscoder.
+	// UNCOMMITTED: This API may change in the future.
+	Transcoder Transcoder
+
 	// Internal specifies a set of options for internal use.
 	// Internal: This should never be used and is not supported.
 	Internal struct {
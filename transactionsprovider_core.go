@@ -122,7 +122,10 @@ func (t *transactionsProviderCore) Init(config TransactionsConfig, c *Cluster) e
 
 	t.cluster = c
 	t.config = config
-	t.transcoder = NewJSONTranscoder()
+	t.transcoder = config.Transcoder
+	if t.transcoder == nil {
+		t.transcoder = NewJSONTranscoder()
+	}
 	t.hooksWrapper = hooksWrapper
 	t.cleanupHooksWrapper = cleanupHooksWrapper
 	t.cleanupCollections = cleanupLocs
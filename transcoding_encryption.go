@@ -0,0 +1,556 @@
+package gocb
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	gocbcore "github.com/couchbase/gocbcore/v10"
+)
+
+// FieldEncryptionAlgorithmAES256GCM is the algorithm identifier written into the envelope of fields encrypted by the
+// EncryptingTranscoder. It is specific to this SDK: the other Couchbase SDKs use AEAD_AES_256_CBC_HMAC_SHA512, and
+// cannot read fields encrypted with it.
+const FieldEncryptionAlgorithmAES256GCM = "AES_256_GCM"
+
+const defaultEncryptedFieldPrefix = "encrypted$"
+
+const (
+	// encryptedPathElements is the path segment matching every element of an array.
+	encryptedPathElements = "[]"
+
+	// encryptedPathValues is the path segment matching every value of an object.
+	encryptedPathValues = "*"
+)
+
+// Keyring provides access to the keys used for field level encryption.
+// UNCOMMITTED: This API may change in the future.
+type Keyring interface {
+	// Get returns the key identified by keyID.
+	Get(keyID string) ([]byte, error)
+}
+
+// StaticKeyring implements a Keyring backed by a fixed set of keys.
+// UNCOMMITTED: This API may change in the future.
+type StaticKeyring map[string][]byte
+
+// Get returns the key identified by keyID.
+func (k StaticKeyring) Get(keyID string) ([]byte, error) {
+	key, ok := k[keyID]
+	if !ok {
+		return nil, makeInvalidArgumentsError(fmt.Sprintf("no key found for key id %s", keyID))
+	}
+
+	return key, nil
+}
+
+// EncryptingTranscoderOptions are the options available when creating an EncryptingTranscoder.
+// UNCOMMITTED: This API may change in the future.
+type EncryptingTranscoderOptions struct {
+	// Paths specifies additional fields to encrypt, in addition to those tagged with `cb:"encrypted"`. Nested fields
+	// are separated using '.', e.g. "address.line1". The segment "[]" matches every element of an array and "*" every
+	// value of an object, e.g. "cards.[].number".
+	Paths []string
+
+	// FieldPrefix specifies the prefix which is applied to the name of encrypted fields. Defaults to "encrypted$".
+	FieldPrefix string
+}
+
+// EncryptingTranscoder wraps another Transcoder, applying client side field level encryption to JSON documents.
+//
+// When encoding, fields of structs tagged with `cb:"encrypted"`, including those of structs within slices, arrays and
+// maps, and any fields listed in Paths are encrypted using
+// AES-256-GCM with the key identified by the key id, with the path of the field as additional authenticated data so
+// that an encrypted value cannot be moved to a different field. Each encrypted field is renamed using the field
+// prefix and its value replaced with an envelope containing the algorithm, key id and ciphertext.
+//
+// The envelope format is not compatible with the field level encryption of the other Couchbase SDKs, which use the
+// AEAD_AES_256_CBC_HMAC_SHA512 algorithm. Documents encrypted by this transcoder can only be decrypted by it, and
+// it cannot decrypt documents encrypted by the other SDKs.
+//
+// When decoding, any field using the field prefix which contains an envelope is decrypted using the key from the
+// keyring identified within the envelope, before the document is passed to the wrapped transcoder.
+// UNCOMMITTED: This API may change in the future.
+type EncryptingTranscoder struct {
+	transcoder  Transcoder
+	keyring     Keyring
+	keyID       string
+	paths       []string
+	fieldPrefix string
+
+	typePaths sync.Map
+}
+
+// NewEncryptingTranscoder returns a new EncryptingTranscoder. If transcoder is nil then a JSONTranscoder is used.
+// UNCOMMITTED: This API may change in the future.
+func NewEncryptingTranscoder(transcoder Transcoder, keyring Keyring, keyID string,
+	opts *EncryptingTranscoderOptions) *EncryptingTranscoder {
+	if opts == nil {
+		opts = &EncryptingTranscoderOptions{}
+	}
+	if transcoder == nil {
+		transcoder = NewJSONTranscoder()
+	}
+
+	fieldPrefix := opts.FieldPrefix
+	if fieldPrefix == "" {
+		fieldPrefix = defaultEncryptedFieldPrefix
+	}
+
+	return &EncryptingTranscoder{
+		transcoder:  transcoder,
+		keyring:     keyring,
+		keyID:       keyID,
+		paths:       opts.Paths,
+		fieldPrefix: fieldPrefix,
+	}
+}
+
+type fieldEncryptionEnvelope struct {
+	Algorithm  string `json:"alg"`
+	KeyID      string `json:"kid"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// Encode encodes the value using the wrapped transcoder and then encrypts any fields requiring encryption.
+func (t *EncryptingTranscoder) Encode(value interface{}) ([]byte, uint32, error) {
+	valueBytes, flags, err := t.transcoder.Encode(value)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	structPaths, err := t.structPaths(reflect.TypeOf(value))
+	if err != nil {
+		return nil, 0, err
+	}
+	paths := make([]string, 0, len(structPaths)+len(t.paths))
+	paths = append(paths, structPaths...)
+	paths = append(paths, t.paths...)
+	if len(paths) == 0 {
+		return valueBytes, flags, nil
+	}
+
+	valueType, _ := gocbcore.DecodeCommonFlags(flags)
+	if valueType != gocbcore.JSONType {
+		return nil, 0, wrapError(ErrEncodingFailure, "field level encryption is only supported for JSON values")
+	}
+
+	doc, err := t.unmarshalDoc(valueBytes)
+	if err != nil {
+		return nil, 0, wrapError(ErrEncodingFailure, fmt.Sprintf("failed to parse encoded value: %s", err))
+	}
+
+	docMap, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, 0, wrapError(ErrEncodingFailure, "field level encryption is only supported for JSON objects")
+	}
+
+	for _, path := range paths {
+		if err := t.encryptPath(docMap, strings.Split(path, "."), ""); err != nil {
+			return nil, 0, wrapError(ErrEncodingFailure, fmt.Sprintf("failed to encrypt field %s: %s", path, err))
+		}
+	}
+
+	valueBytes, err = json.Marshal(docMap)
+	if err != nil {
+		return nil, 0, wrapError(ErrEncodingFailure, fmt.Sprintf("failed to marshal encrypted value: %s", err))
+	}
+
+	return valueBytes, flags, nil
+}
+
+// Decode decrypts any encrypted fields and then decodes the value using the wrapped transcoder.
+func (t *EncryptingTranscoder) Decode(valueBytes []byte, flags uint32, out interface{}) error {
+	valueType, _ := gocbcore.DecodeCommonFlags(flags)
+	if valueType != gocbcore.JSONType || !bytes.Contains(valueBytes, []byte(`"`+t.fieldPrefix)) {
+		return t.transcoder.Decode(valueBytes, flags, out)
+	}
+
+	doc, err := t.unmarshalDoc(valueBytes)
+	if err != nil {
+		return t.transcoder.Decode(valueBytes, flags, out)
+	}
+
+	doc, decrypted, err := t.decryptValue(doc, "")
+	if err != nil {
+		return wrapError(ErrDecodingFailure, err.Error())
+	}
+	if !decrypted {
+		return t.transcoder.Decode(valueBytes, flags, out)
+	}
+
+	valueBytes, err = json.Marshal(doc)
+	if err != nil {
+		return wrapError(ErrDecodingFailure, fmt.Sprintf("failed to marshal decrypted value: %s", err))
+	}
+
+	return t.transcoder.Decode(valueBytes, flags, out)
+}
+
+func (t *EncryptingTranscoder) unmarshalDoc(valueBytes []byte) (interface{}, error) {
+	// Use numbers so that values which we do not touch are written back exactly as they were.
+	decoder := json.NewDecoder(bytes.NewReader(valueBytes))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// encryptPath encrypts the fields matching path within value. fieldPath is the path of value in the document, with
+// the actual names of any object values matched by "*" so that it is the same path that decryption sees.
+func (t *EncryptingTranscoder) encryptPath(value interface{}, path []string, fieldPath string) error {
+	if len(path) == 0 {
+		return nil
+	}
+
+	switch path[0] {
+	case encryptedPathElements:
+		elems, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+
+		for _, elem := range elems {
+			if err := t.encryptPath(elem, path[1:], encryptedFieldPath(fieldPath, encryptedPathElements)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case encryptedPathValues:
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		for name, child := range values {
+			if err := t.encryptPath(child, path[1:], encryptedFieldPath(fieldPath, name)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	doc, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	child, ok := doc[path[0]]
+	if !ok {
+		return nil
+	}
+
+	fieldPath = encryptedFieldPath(fieldPath, path[0])
+	if len(path) > 1 {
+		return t.encryptPath(child, path[1:], fieldPath)
+	}
+
+	envelope, err := t.encrypt(child, fieldPath)
+	if err != nil {
+		return err
+	}
+
+	delete(doc, path[0])
+	doc[t.fieldPrefix+path[0]] = envelope
+	return nil
+}
+
+// encrypt encrypts the value of the field at fieldPath, which is authenticated along with the value.
+func (t *EncryptingTranscoder) encrypt(value interface{}, fieldPath string) (*fieldEncryptionEnvelope, error) {
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := t.cipher(t.keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return &fieldEncryptionEnvelope{
+		Algorithm:  FieldEncryptionAlgorithmAES256GCM,
+		KeyID:      t.keyID,
+		Ciphertext: base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, []byte(fieldPath))),
+	}, nil
+}
+
+// decryptValue returns the value with any encrypted fields within it decrypted, along with whether there were any.
+// Values containing encrypted fields are copied rather than modified. path is the path of the value in the document.
+func (t *EncryptingTranscoder) decryptValue(value interface{}, path string) (interface{}, bool, error) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		var decryptedAny bool
+		decryptedMap := make(map[string]interface{}, len(typedValue))
+		for name, fieldValue := range typedValue {
+			if strings.HasPrefix(name, t.fieldPrefix) {
+				if envelope, ok := t.asEnvelope(fieldValue); ok {
+					plainName := strings.TrimPrefix(name, t.fieldPrefix)
+					plain, err := t.decrypt(envelope, encryptedFieldPath(path, plainName))
+					if err != nil {
+						return nil, false, fmt.Errorf("failed to decrypt field %s: %s", name, err)
+					}
+
+					decryptedMap[plainName] = plain
+					decryptedAny = true
+					continue
+				}
+			}
+
+			newValue, decrypted, err := t.decryptValue(fieldValue, encryptedFieldPath(path, name))
+			if err != nil {
+				return nil, false, err
+			}
+			if decrypted {
+				decryptedAny = true
+			}
+			decryptedMap[name] = newValue
+		}
+
+		if decryptedAny {
+			return decryptedMap, true, nil
+		}
+	case []interface{}:
+		var decryptedSlice []interface{}
+		for i, elem := range typedValue {
+			newValue, decrypted, err := t.decryptValue(elem, encryptedFieldPath(path, encryptedPathElements))
+			if err != nil {
+				return nil, false, err
+			}
+			if decrypted {
+				if decryptedSlice == nil {
+					decryptedSlice = append([]interface{}(nil), typedValue...)
+				}
+				decryptedSlice[i] = newValue
+			}
+		}
+
+		if decryptedSlice != nil {
+			return decryptedSlice, true, nil
+		}
+	}
+
+	return value, false, nil
+}
+
+func encryptedFieldPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+
+	return parent + "." + name
+}
+
+func (t *EncryptingTranscoder) asEnvelope(value interface{}) (*fieldEncryptionEnvelope, bool) {
+	fields, ok := value.(map[string]interface{})
+	if !ok || len(fields) != 3 {
+		return nil, false
+	}
+
+	alg, algOk := fields["alg"].(string)
+	kid, kidOk := fields["kid"].(string)
+	ciphertext, ctOk := fields["ciphertext"].(string)
+	if !algOk || !kidOk || !ctOk {
+		return nil, false
+	}
+
+	return &fieldEncryptionEnvelope{
+		Algorithm:  alg,
+		KeyID:      kid,
+		Ciphertext: ciphertext,
+	}, true
+}
+
+func (t *EncryptingTranscoder) decrypt(envelope *fieldEncryptionEnvelope, fieldPath string) (interface{}, error) {
+	if envelope.Algorithm != FieldEncryptionAlgorithmAES256GCM {
+		return nil, fmt.Errorf("unsupported encryption algorithm %s", envelope.Algorithm)
+	}
+
+	aead, err := t.cipher(envelope.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(fieldPath))
+	if err != nil {
+		return nil, err
+	}
+
+	return t.unmarshalDoc(plaintext)
+}
+
+func (t *EncryptingTranscoder) cipher(keyID string) (cipher.AEAD, error) {
+	if t.keyring == nil {
+		return nil, makeInvalidArgumentsError("no keyring configured")
+	}
+
+	key, err := t.keyring.Get(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, makeInvalidArgumentsError(fmt.Sprintf("key %s must be 32 bytes for AES-256", keyID))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+type encryptedTypePaths struct {
+	paths []string
+	err   error
+}
+
+// structPaths returns the paths of all fields tagged for encryption within the type, caching them per type.
+func (t *EncryptingTranscoder) structPaths(typ reflect.Type) ([]string, error) {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	if cached, ok := t.typePaths.Load(typ); ok {
+		typePaths := cached.(*encryptedTypePaths)
+		return typePaths.paths, typePaths.err
+	}
+
+	paths, err := encryptedStructPaths(typ, "", map[reflect.Type]bool{})
+	t.typePaths.Store(typ, &encryptedTypePaths{paths: paths, err: err})
+
+	return paths, err
+}
+
+// encryptedElemType returns the type of the values held by typ, along with the path segments which reach them, for
+// pointers, slices, arrays and maps.
+func encryptedElemType(typ reflect.Type) (reflect.Type, string) {
+	var segments string
+	for {
+		switch typ.Kind() {
+		case reflect.Ptr:
+		case reflect.Slice, reflect.Array:
+			segments += "." + encryptedPathElements
+		case reflect.Map:
+			segments += "." + encryptedPathValues
+		default:
+			return typ, segments
+		}
+		typ = typ.Elem()
+	}
+}
+
+func encryptedStructPaths(typ reflect.Type, prefix string, seen map[reflect.Type]bool) ([]string, error) {
+	if seen[typ] {
+		// Paths cannot describe every depth of a recursive type, so any encrypted fields within it would be left in
+		// plaintext.
+		if encryptedTypeHasFields(typ, map[reflect.Type]bool{}) {
+			return nil, makeInvalidArgumentsError(fmt.Sprintf("encrypted fields within recursive type %s are not supported",
+				typ))
+		}
+		return nil, nil
+	}
+	seen[typ] = true
+	defer delete(seen, typ)
+
+	var paths []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name := field.Name
+		jsonTag := field.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		if tagName, _, _ := strings.Cut(jsonTag, ","); tagName != "" {
+			name = tagName
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		if field.Anonymous && jsonTag == "" && fieldType.Kind() == reflect.Struct {
+			embedded, err := encryptedStructPaths(fieldType, prefix, seen)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, embedded...)
+			continue
+		}
+
+		if cbTag, _, _ := strings.Cut(field.Tag.Get("cb"), ","); cbTag == "encrypted" {
+			paths = append(paths, prefix+name)
+			continue
+		}
+
+		elemType, segments := encryptedElemType(fieldType)
+		if elemType.Kind() == reflect.Struct {
+			nested, err := encryptedStructPaths(elemType, prefix+name+segments+".", seen)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, nested...)
+		}
+	}
+
+	return paths, nil
+}
+
+// encryptedTypeHasFields returns whether any field reachable from the struct typ is tagged for encryption.
+func encryptedTypeHasFields(typ reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[typ] {
+		return false
+	}
+	visited[typ] = true
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if (field.PkgPath != "" && !field.Anonymous) || field.Tag.Get("json") == "-" {
+			continue
+		}
+		if cbTag, _, _ := strings.Cut(field.Tag.Get("cb"), ","); cbTag == "encrypted" {
+			return true
+		}
+
+		elemType, _ := encryptedElemType(field.Type)
+		if elemType.Kind() == reflect.Struct && encryptedTypeHasFields(elemType, visited) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,249 @@
+package gocb
+
+import (
+	"bytes"
+	"encoding/json"
+
+	gocbcore "github.com/couchbase/gocbcore/v10"
+)
+
+type testEncryptedAddress struct {
+	Line1    string `json:"line1" cb:"encrypted"`
+	Postcode string `json:"postcode"`
+}
+
+type testEncryptedDoc struct {
+	Name    string                `json:"name"`
+	SSN     string                `json:"ssn" cb:"encrypted"`
+	Card    []int                 `json:"card,omitempty" cb:"encrypted"`
+	Address *testEncryptedAddress `json:"address,omitempty"`
+	Notes   string                `json:"notes,omitempty"`
+	Ignored string                `json:"-" cb:"encrypted"`
+}
+
+type testEncryptedCard struct {
+	Number string `json:"number" cb:"encrypted"`
+	Name   string `json:"name"`
+}
+
+type testEncryptedWallet struct {
+	Cards   []testEncryptedCard            `json:"cards"`
+	ByName  map[string]testEncryptedCard   `json:"byName"`
+	Backups *[]*testEncryptedCard          `json:"backups"`
+	Nested  [][2]testEncryptedCard         `json:"nested"`
+	Grouped map[string][]testEncryptedCard `json:"grouped"`
+}
+
+type testEncryptedNode struct {
+	Secret   string              `json:"secret" cb:"encrypted"`
+	Children []testEncryptedNode `json:"children"`
+}
+
+func (suite *UnitTestSuite) testKeyring() StaticKeyring {
+	return StaticKeyring{
+		"key1": bytes.Repeat([]byte{1}, 32),
+		"key2": bytes.Repeat([]byte{2}, 32),
+	}
+}
+
+func (suite *UnitTestSuite) TestEncryptingTranscoderRoundTrip() {
+	transcoder := NewEncryptingTranscoder(nil, suite.testKeyring(), "key1", nil)
+
+	doc := testEncryptedDoc{
+		Name: "barry",
+		SSN:  "123-45-6789",
+		Card: []int{4, 1, 1, 1},
+		Address: &testEncryptedAddress{
+			Line1:    "1 Secret Street",
+			Postcode: "AB1 2CD",
+		},
+	}
+
+	encoded, flags, err := transcoder.Encode(&doc)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal(gocbcore.EncodeCommonFlags(gocbcore.JSONType, gocbcore.NoCompression), flags)
+	suite.Assert().NotContains(string(encoded), "123-45-6789")
+	suite.Assert().NotContains(string(encoded), "Secret Street")
+
+	var raw map[string]json.RawMessage
+	suite.Require().Nil(json.Unmarshal(encoded, &raw))
+	suite.Assert().Contains(raw, "name")
+	suite.Assert().Contains(raw, "encrypted$ssn")
+	suite.Assert().Contains(raw, "encrypted$card")
+	suite.Assert().NotContains(raw, "ssn")
+
+	var envelope fieldEncryptionEnvelope
+	suite.Require().Nil(json.Unmarshal(raw["encrypted$ssn"], &envelope))
+	suite.Assert().Equal(FieldEncryptionAlgorithmAES256GCM, envelope.Algorithm)
+	suite.Assert().Equal("key1", envelope.KeyID)
+
+	var address map[string]json.RawMessage
+	suite.Require().Nil(json.Unmarshal(raw["address"], &address))
+	suite.Assert().Contains(address, "encrypted$line1")
+	suite.Assert().Contains(address, "postcode")
+
+	var decoded testEncryptedDoc
+	suite.Require().Nil(transcoder.Decode(encoded, flags, &decoded))
+	suite.Assert().Equal(doc, decoded)
+}
+
+func (suite *UnitTestSuite) TestEncryptingTranscoderCollections() {
+	transcoder := NewEncryptingTranscoder(nil, suite.testKeyring(), "key1", nil)
+
+	backups := []*testEncryptedCard{{Number: "4444", Name: "backup"}}
+	wallet := testEncryptedWallet{
+		Cards: []testEncryptedCard{{Number: "1111", Name: "first"}, {Number: "2222", Name: "second"}},
+		ByName: map[string]testEncryptedCard{
+			"visa": {Number: "3333", Name: "visa"},
+		},
+		Backups: &backups,
+		Nested:  [][2]testEncryptedCard{{{Number: "5555"}, {Number: "6666"}}},
+		Grouped: map[string][]testEncryptedCard{"work": {{Number: "7777"}}},
+	}
+
+	encoded, flags, err := transcoder.Encode(&wallet)
+	suite.Require().Nil(err, err)
+	for _, number := range []string{"1111", "2222", "3333", "4444", "5555", "6666", "7777"} {
+		suite.Assert().NotContains(string(encoded), number)
+	}
+
+	var raw struct {
+		Cards  []map[string]json.RawMessage          `json:"cards"`
+		ByName map[string]map[string]json.RawMessage `json:"byName"`
+	}
+	suite.Require().Nil(json.Unmarshal(encoded, &raw))
+	suite.Require().Len(raw.Cards, 2)
+	suite.Assert().Contains(raw.Cards[0], "encrypted$number")
+	suite.Assert().Equal(json.RawMessage(`"first"`), raw.Cards[0]["name"])
+	suite.Assert().Contains(raw.ByName["visa"], "encrypted$number")
+
+	var decoded testEncryptedWallet
+	suite.Require().Nil(transcoder.Decode(encoded, flags, &decoded))
+	suite.Assert().Equal(wallet, decoded)
+
+	// The key of a map is part of the authenticated path, so an encrypted value cannot be moved to another key.
+	var moved map[string]interface{}
+	suite.Require().Nil(json.Unmarshal(encoded, &moved))
+	byName := moved["byName"].(map[string]interface{})
+	byName["amex"] = byName["visa"]
+	delete(byName, "visa")
+	movedBytes, err := json.Marshal(moved)
+	suite.Require().Nil(err, err)
+	err = transcoder.Decode(movedBytes, flags, &decoded)
+	suite.Assert().ErrorIs(err, ErrDecodingFailure)
+
+	pathTranscoder := NewEncryptingTranscoder(nil, suite.testKeyring(), "key1", &EncryptingTranscoderOptions{
+		Paths: []string{"cards.[].number", "byName.*.number"},
+	})
+	encoded, flags, err = pathTranscoder.Encode(map[string]interface{}{
+		"cards":  []interface{}{map[string]interface{}{"number": "1111"}},
+		"byName": map[string]interface{}{"visa": map[string]interface{}{"number": "3333"}},
+	})
+	suite.Require().Nil(err, err)
+	suite.Assert().NotContains(string(encoded), "1111")
+	suite.Assert().NotContains(string(encoded), "3333")
+
+	// Paths and tags produce the same documents.
+	var decodedWallet testEncryptedWallet
+	suite.Require().Nil(transcoder.Decode(encoded, flags, &decodedWallet))
+	suite.Assert().Equal("1111", decodedWallet.Cards[0].Number)
+	suite.Assert().Equal("3333", decodedWallet.ByName["visa"].Number)
+}
+
+func (suite *UnitTestSuite) TestEncryptingTranscoderRecursiveType() {
+	transcoder := NewEncryptingTranscoder(nil, suite.testKeyring(), "key1", nil)
+
+	// Encrypted fields at every depth of a recursive type can't be found, so encoding fails rather than writing
+	// some of them in plaintext.
+	_, _, err := transcoder.Encode(testEncryptedNode{
+		Secret:   "root",
+		Children: []testEncryptedNode{{Secret: "child"}},
+	})
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
+
+func (suite *UnitTestSuite) TestEncryptingTranscoderPaths() {
+	transcoder := NewEncryptingTranscoder(nil, suite.testKeyring(), "key1", &EncryptingTranscoderOptions{
+		Paths:       []string{"notes", "address.postcode", "missing.field"},
+		FieldPrefix: "enc_",
+	})
+
+	encoded, flags, err := transcoder.Encode(map[string]interface{}{
+		"notes":   "private",
+		"count":   12345678901234567,
+		"address": map[string]interface{}{"postcode": "AB1 2CD"},
+	})
+	suite.Require().Nil(err, err)
+	suite.Assert().NotContains(string(encoded), "private")
+	suite.Assert().Contains(string(encoded), `"enc_notes"`)
+	suite.Assert().Contains(string(encoded), `"enc_postcode"`)
+
+	var decoded map[string]interface{}
+	suite.Require().Nil(transcoder.Decode(encoded, flags, &decoded))
+	suite.Assert().Equal("private", decoded["notes"])
+	suite.Assert().Equal(map[string]interface{}{"postcode": "AB1 2CD"}, decoded["address"])
+	suite.Assert().Equal(float64(12345678901234567), decoded["count"])
+}
+
+func (suite *UnitTestSuite) TestEncryptingTranscoderDecodeWithRotatedKey() {
+	keyring := suite.testKeyring()
+	oldTranscoder := NewEncryptingTranscoder(nil, keyring, "key1", nil)
+	newTranscoder := NewEncryptingTranscoder(nil, keyring, "key2", nil)
+
+	encoded, flags, err := oldTranscoder.Encode(testEncryptedDoc{Name: "barry", SSN: "123"})
+	suite.Require().Nil(err, err)
+
+	// The key id is carried in the envelope so documents written with an older key can still be read.
+	var decoded testEncryptedDoc
+	suite.Require().Nil(newTranscoder.Decode(encoded, flags, &decoded))
+	suite.Assert().Equal("123", decoded.SSN)
+}
+
+func (suite *UnitTestSuite) TestEncryptingTranscoderDecodeFailures() {
+	transcoder := NewEncryptingTranscoder(nil, suite.testKeyring(), "key1", nil)
+
+	encoded, flags, err := transcoder.Encode(testEncryptedDoc{Name: "barry", SSN: "123"})
+	suite.Require().Nil(err, err)
+
+	wrongKey := NewEncryptingTranscoder(nil, StaticKeyring{"key1": bytes.Repeat([]byte{9}, 32)}, "key1", nil)
+	var decoded testEncryptedDoc
+	err = wrongKey.Decode(encoded, flags, &decoded)
+	suite.Assert().ErrorIs(err, ErrDecodingFailure)
+
+	missingKey := NewEncryptingTranscoder(nil, StaticKeyring{}, "key1", nil)
+	err = missingKey.Decode(encoded, flags, &decoded)
+	suite.Assert().ErrorIs(err, ErrDecodingFailure)
+
+	// The path of a field is authenticated, so its encrypted value cannot be moved to another field.
+	var raw map[string]json.RawMessage
+	suite.Require().Nil(json.Unmarshal(encoded, &raw))
+	raw["encrypted$notes"] = raw["encrypted$ssn"]
+	delete(raw, "encrypted$ssn")
+	moved, err := json.Marshal(raw)
+	suite.Require().Nil(err, err)
+	err = transcoder.Decode(moved, flags, &decoded)
+	suite.Assert().ErrorIs(err, ErrDecodingFailure)
+}
+
+func (suite *UnitTestSuite) TestEncryptingTranscoderEncodeFailures() {
+	badKey := NewEncryptingTranscoder(nil, StaticKeyring{"key1": []byte("short")}, "key1", nil)
+	_, _, err := badKey.Encode(testEncryptedDoc{SSN: "123"})
+	suite.Assert().ErrorIs(err, ErrEncodingFailure)
+
+	stringTranscoder := NewEncryptingTranscoder(NewRawStringTranscoder(), suite.testKeyring(), "key1",
+		&EncryptingTranscoderOptions{Paths: []string{"field"}})
+	_, _, err = stringTranscoder.Encode("a string")
+	suite.Assert().ErrorIs(err, ErrEncodingFailure)
+}
+
+func (suite *UnitTestSuite) TestEncryptingTranscoderPassthrough() {
+	transcoder := NewEncryptingTranscoder(NewLegacyTranscoder(), suite.testKeyring(), "key1", nil)
+
+	encoded, flags, err := transcoder.Encode([]byte("binary"))
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal([]byte("binary"), encoded)
+
+	var decoded []byte
+	suite.Require().Nil(transcoder.Decode(encoded, flags, &decoded))
+	suite.Assert().Equal([]byte("binary"), decoded)
+}
@@ -0,0 +1,193 @@
+package gocb
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// CommonFlagsPrivateFormat is the common flags format reserved for application specific encodings. Codecs for formats
+// such as msgpack or protobuf should be registered against this format combined with an application chosen value in
+// the lower bits, for example CommonFlagsPrivateFormat|0x01.
+const CommonFlagsPrivateFormat uint32 = 0x01 << 24
+
+// commonFlagsFormatMask masks out the format portion of a common flags value.
+const commonFlagsFormatMask uint32 = 0xff << 24
+
+// Codec provides an interface for encoding and decoding values of a single storage format. Codecs are registered
+// against a RegistryTranscoder which is responsible for dispatching to the correct Codec.
+// UNCOMMITTED: This API may change in the future.
+type Codec interface {
+	// Decode decodes the bytes into the value pointer.
+	Decode(bytes []byte, valuePtr interface{}) error
+
+	// Encode encodes the value into bytes.
+	Encode(value interface{}) ([]byte, error)
+}
+
+type registeredCodec struct {
+	flags uint32
+	codec Codec
+}
+
+// RegistryTranscoder implements a transcoder which dispatches to registered codecs based on the flags of a document
+// when decoding and the Go type of a value when encoding. This allows documents written in different formats by
+// different services to be read through the same Collection.
+//
+// When decoding a codec registered against the exact flags of the document is used first, followed by a codec
+// registered against the common flags format of the document. If no codec matches, or the codec fails, then the
+// default transcoder is tried followed by each fallback transcoder in order.
+//
+// When encoding a codec registered against the type of the value is used, otherwise the default transcoder is used.
+// UNCOMMITTED: This API may change in the future.
+type RegistryTranscoder struct {
+	lock      sync.RWMutex
+	byFlags   map[uint32]Codec
+	byType    map[reflect.Type]registeredCodec
+	fallbacks []Transcoder
+
+	defaultTranscoder Transcoder
+}
+
+// NewRegistryTranscoder returns a new RegistryTranscoder. If defaultTranscoder is nil then a LegacyTranscoder is used.
+// UNCOMMITTED: This API may change in the future.
+func NewRegistryTranscoder(defaultTranscoder Transcoder) *RegistryTranscoder {
+	if defaultTranscoder == nil {
+		defaultTranscoder = NewLegacyTranscoder()
+	}
+
+	return &RegistryTranscoder{
+		byFlags:           make(map[uint32]Codec),
+		byType:            make(map[reflect.Type]registeredCodec),
+		defaultTranscoder: defaultTranscoder,
+	}
+}
+
+// RegisterCodec registers a codec to be used for documents stored with the flags provided. If the flags provided
+// contain only a common flags format, such as CommonFlagsPrivateFormat, then the codec is used for any document of
+// that format which does not have a more specific codec registered.
+func (t *RegistryTranscoder) RegisterCodec(flags uint32, codec Codec) error {
+	if codec == nil {
+		return makeInvalidArgumentsError("codec cannot be nil")
+	}
+
+	t.lock.Lock()
+	t.byFlags[flags] = codec
+	t.lock.Unlock()
+
+	return nil
+}
+
+// RegisterType registers the Go type of value to be encoded with the codec registered against flags. Both the type
+// and a pointer to the type will be matched when encoding.
+func (t *RegistryTranscoder) RegisterType(valueType reflect.Type, flags uint32) error {
+	if valueType == nil {
+		return makeInvalidArgumentsError("value type cannot be nil")
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	codec, ok := t.byFlags[flags]
+	if !ok {
+		return makeInvalidArgumentsError(fmt.Sprintf("no codec registered for flags 0x%08x", flags))
+	}
+
+	if valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+	t.byType[valueType] = registeredCodec{
+		flags: flags,
+		codec: codec,
+	}
+
+	return nil
+}
+
+// AddFallback adds a transcoder to the end of the chain of transcoders which are tried when decoding a document
+// which could not be decoded by a registered codec or the default transcoder.
+func (t *RegistryTranscoder) AddFallback(transcoder Transcoder) {
+	if transcoder == nil {
+		return
+	}
+
+	t.lock.Lock()
+	t.fallbacks = append(t.fallbacks, transcoder)
+	t.lock.Unlock()
+}
+
+func (t *RegistryTranscoder) codecForFlags(flags uint32) (Codec, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if codec, ok := t.byFlags[flags]; ok {
+		return codec, true
+	}
+
+	codec, ok := t.byFlags[flags&commonFlagsFormatMask]
+	return codec, ok
+}
+
+func (t *RegistryTranscoder) codecForValue(value interface{}) (registeredCodec, bool) {
+	valueType := reflect.TypeOf(value)
+	if valueType == nil {
+		return registeredCodec{}, false
+	}
+	if valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+
+	t.lock.RLock()
+	codec, ok := t.byType[valueType]
+	t.lock.RUnlock()
+
+	return codec, ok
+}
+
+// Decode dispatches to the codec registered for the flags provided, falling back to the default and then fallback
+// transcoders.
+func (t *RegistryTranscoder) Decode(bytes []byte, flags uint32, out interface{}) error {
+	var firstErr error
+	if codec, ok := t.codecForFlags(flags); ok {
+		err := codec.Decode(bytes, out)
+		if err == nil {
+			return nil
+		}
+
+		logDebugf("Codec for flags 0x%08x failed to decode value, trying fallbacks: %s", flags, err)
+		firstErr = err
+	}
+
+	t.lock.RLock()
+	transcoders := make([]Transcoder, 0, len(t.fallbacks)+1)
+	transcoders = append(transcoders, t.defaultTranscoder)
+	transcoders = append(transcoders, t.fallbacks...)
+	t.lock.RUnlock()
+
+	for _, transcoder := range transcoders {
+		err := transcoder.Decode(bytes, flags, out)
+		if err == nil {
+			return nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return wrapError(firstErr, fmt.Sprintf("no codec could decode value with flags 0x%08x", flags))
+}
+
+// Encode dispatches to the codec registered for the type of value, falling back to the default transcoder.
+func (t *RegistryTranscoder) Encode(value interface{}) ([]byte, uint32, error) {
+	if codec, ok := t.codecForValue(value); ok {
+		bytes, err := codec.codec.Encode(value)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return bytes, codec.flags, nil
+	}
+
+	return t.defaultTranscoder.Encode(value)
+}
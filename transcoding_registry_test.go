@@ -0,0 +1,133 @@
+package gocb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"reflect"
+
+	gocbcore "github.com/couchbase/gocbcore/v10"
+)
+
+type testGobCodec struct{}
+
+func (c testGobCodec) Decode(data []byte, valuePtr interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(valuePtr)
+}
+
+func (c testGobCodec) Encode(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type testFailingCodec struct{}
+
+func (c testFailingCodec) Decode(data []byte, valuePtr interface{}) error {
+	return errors.New("failing codec")
+}
+
+func (c testFailingCodec) Encode(value interface{}) ([]byte, error) {
+	return nil, errors.New("failing codec")
+}
+
+type testRegistryDoc struct {
+	Name string
+	Age  int
+}
+
+func (suite *UnitTestSuite) TestRegistryTranscoderEncodeByType() {
+	gobFlags := CommonFlagsPrivateFormat | 0x01
+
+	transcoder := NewRegistryTranscoder(nil)
+	suite.Require().Nil(transcoder.RegisterCodec(gobFlags, testGobCodec{}))
+	suite.Require().Nil(transcoder.RegisterType(reflect.TypeOf(testRegistryDoc{}), gobFlags))
+
+	doc := testRegistryDoc{Name: "barry", Age: 21}
+	for _, value := range []interface{}{doc, &doc} {
+		encoded, flags, err := transcoder.Encode(value)
+		suite.Require().Nil(err, err)
+		suite.Assert().Equal(gobFlags, flags)
+
+		var decoded testRegistryDoc
+		suite.Require().Nil(transcoder.Decode(encoded, flags, &decoded))
+		suite.Assert().Equal(doc, decoded)
+	}
+
+	// Unregistered types use the default transcoder.
+	encoded, flags, err := transcoder.Encode(map[string]string{"name": "barry"})
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal(gocbcore.EncodeCommonFlags(gocbcore.JSONType, gocbcore.NoCompression), flags)
+	suite.Assert().Equal([]byte(`{"name":"barry"}`), encoded)
+}
+
+func (suite *UnitTestSuite) TestRegistryTranscoderDecodeByFormat() {
+	transcoder := NewRegistryTranscoder(nil)
+	suite.Require().Nil(transcoder.RegisterCodec(CommonFlagsPrivateFormat, testGobCodec{}))
+
+	encoded, err := testGobCodec{}.Encode(testRegistryDoc{Name: "barry"})
+	suite.Require().Nil(err, err)
+
+	var decoded testRegistryDoc
+	suite.Require().Nil(transcoder.Decode(encoded, CommonFlagsPrivateFormat|0x7f, &decoded))
+	suite.Assert().Equal("barry", decoded.Name)
+}
+
+func (suite *UnitTestSuite) TestRegistryTranscoderDecodeMixedFormats() {
+	gobFlags := CommonFlagsPrivateFormat | 0x01
+
+	transcoder := NewRegistryTranscoder(NewJSONTranscoder())
+	suite.Require().Nil(transcoder.RegisterCodec(gobFlags, testGobCodec{}))
+	transcoder.AddFallback(NewRawStringTranscoder())
+
+	gobBytes, err := testGobCodec{}.Encode(testRegistryDoc{Name: "gob"})
+	suite.Require().Nil(err, err)
+
+	var gobDoc testRegistryDoc
+	suite.Require().Nil(transcoder.Decode(gobBytes, gobFlags, &gobDoc))
+	suite.Assert().Equal("gob", gobDoc.Name)
+
+	var jsonDoc testRegistryDoc
+	suite.Require().Nil(transcoder.Decode([]byte(`{"Name":"json"}`),
+		gocbcore.EncodeCommonFlags(gocbcore.JSONType, gocbcore.NoCompression), &jsonDoc))
+	suite.Assert().Equal("json", jsonDoc.Name)
+
+	// The JSON transcoder cannot decode strings so this must come from the fallback.
+	var str string
+	suite.Require().Nil(transcoder.Decode([]byte("hello"),
+		gocbcore.EncodeCommonFlags(gocbcore.StringType, gocbcore.NoCompression), &str))
+	suite.Assert().Equal("hello", str)
+}
+
+func (suite *UnitTestSuite) TestRegistryTranscoderFallbackOnCodecFailure() {
+	transcoder := NewRegistryTranscoder(nil)
+	suite.Require().Nil(transcoder.RegisterCodec(gocbcore.EncodeCommonFlags(gocbcore.JSONType, gocbcore.NoCompression),
+		testFailingCodec{}))
+
+	var doc testRegistryDoc
+	err := transcoder.Decode([]byte(`{"Name":"json"}`),
+		gocbcore.EncodeCommonFlags(gocbcore.JSONType, gocbcore.NoCompression), &doc)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal("json", doc.Name)
+}
+
+func (suite *UnitTestSuite) TestRegistryTranscoderNoMatchingCodec() {
+	transcoder := NewRegistryTranscoder(nil)
+
+	var doc testRegistryDoc
+	err := transcoder.Decode([]byte("data"), CommonFlagsPrivateFormat|0x02, &doc)
+	suite.Assert().NotNil(err)
+}
+
+func (suite *UnitTestSuite) TestRegistryTranscoderInvalidRegistration() {
+	transcoder := NewRegistryTranscoder(nil)
+
+	err := transcoder.RegisterCodec(CommonFlagsPrivateFormat, nil)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+
+	err = transcoder.RegisterType(reflect.TypeOf(testRegistryDoc{}), CommonFlagsPrivateFormat)
+	suite.Assert().ErrorIs(err, ErrInvalidArgument)
+}
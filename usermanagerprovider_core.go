@@ -69,7 +69,7 @@ func (um *userManagerProviderCore) GetAllUsers(opts *GetAllUsersOptions) ([]User
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := um.provider.executeMgmtRequest(opts.Context, req)
@@ -126,7 +126,7 @@ func (um *userManagerProviderCore) GetUser(name string, opts *GetUserOptions) (*
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := um.provider.executeMgmtRequest(opts.Context, req)
@@ -237,7 +237,7 @@ func (um *userManagerProviderCore) UpsertUser(user User, opts *UpsertUserOptions
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := um.provider.executeMgmtRequest(opts.Context, req)
@@ -278,7 +278,7 @@ func (um *userManagerProviderCore) DropUser(name string, opts *DropUserOptions)
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := um.provider.executeMgmtRequest(opts.Context, req)
@@ -315,7 +315,7 @@ func (um *userManagerProviderCore) GetRoles(opts *GetRolesOptions) ([]RoleAndDes
 		IsIdempotent:  true,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := um.provider.executeMgmtRequest(opts.Context, req)
@@ -371,7 +371,7 @@ func (um *userManagerProviderCore) GetGroup(groupName string, opts *GetGroupOpti
 		IsIdempotent:  true,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := um.provider.executeMgmtRequest(opts.Context, req)
@@ -422,7 +422,7 @@ func (um *userManagerProviderCore) GetAllGroups(opts *GetAllGroupsOptions) ([]Gr
 		IsIdempotent:  true,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := um.provider.executeMgmtRequest(opts.Context, req)
@@ -493,7 +493,7 @@ func (um *userManagerProviderCore) UpsertGroup(group Group, opts *UpsertGroupOpt
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := um.provider.executeMgmtRequest(opts.Context, req)
@@ -534,7 +534,7 @@ func (um *userManagerProviderCore) DropGroup(groupName string, opts *DropGroupOp
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := um.provider.executeMgmtRequest(opts.Context, req)
@@ -580,7 +580,7 @@ func (um *userManagerProviderCore) ChangePassword(newPassword string, opts *Chan
 		RetryStrategy: opts.RetryStrategy,
 		UniqueID:      uuid.New().String(),
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 	}
 
 	resp, err := um.provider.executeMgmtRequest(opts.Context, req)
@@ -118,7 +118,7 @@ func (vm *viewIndexProviderCore) getDesignDocument(name string, namespace Design
 		IsIdempotent:  true,
 		RetryStrategy: opts.RetryStrategy,
 		Timeout:       opts.Timeout,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 		UniqueID:      uuid.New().String(),
 	}
 	resp, err := vm.doMgmtRequest(opts.Context, req)
@@ -173,7 +173,7 @@ func (vm *viewIndexProviderCore) GetAllDesignDocuments(namespace DesignDocumentN
 		IsIdempotent:  true,
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 		UniqueID:      uuid.New().String(),
 	}
 	resp, err := vm.doMgmtRequest(opts.Context, req)
@@ -282,7 +282,7 @@ func (vm *viewIndexProviderCore) upsertDesignDocument(
 		Body:          data,
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpanCtx: span.Context(),
+		parentSpan:    span,
 		UniqueID:      uuid.New().String(),
 	}
 	resp, err := vm.doMgmtRequest(opts.Context, req)
@@ -314,10 +314,10 @@ func (vm *viewIndexProviderCore) DropDesignDocument(name string, namespace Desig
 	span.SetAttribute("db.name", vm.bucketName)
 	defer span.End()
 
-	return vm.dropDesignDocument(span.Context(), name, namespace, opts)
+	return vm.dropDesignDocument(span, name, namespace, opts)
 }
 
-func (vm *viewIndexProviderCore) dropDesignDocument(tracectx RequestSpanContext, name string, namespace DesignDocumentNamespace, opts *DropDesignDocumentOptions) error {
+func (vm *viewIndexProviderCore) dropDesignDocument(span RequestSpan, name string, namespace DesignDocumentNamespace, opts *DropDesignDocumentOptions) error {
 
 	name = vm.ddocName(name, namespace)
 
@@ -327,7 +327,7 @@ func (vm *viewIndexProviderCore) dropDesignDocument(tracectx RequestSpanContext,
 		Method:        "DELETE",
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpanCtx: tracectx,
+		parentSpan:    span,
 		UniqueID:      uuid.New().String(),
 	}
 	resp, err := vm.doMgmtRequest(opts.Context, req)
@@ -57,7 +57,7 @@ func (v *viewProviderCore) ViewQuery(designDoc string, viewName string, opts *Vi
 	}
 
 	return v.execViewQuery(opts.Context, span.Context(), "_view", designDoc, viewName, *urlValues, deadline,
-		retryWrapper, opts.Internal.User)
+		retryWrapper.forOperation(deadline, span), opts.Internal.User)
 }
 
 func (v *viewProviderCore) execViewQuery(